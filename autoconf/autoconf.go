@@ -0,0 +1,262 @@
+package autoconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("autoconf")
+
+// signaturePrefix is prefixed to the payload signed, so that the signatures
+// of the document cannot be reused for other messages.
+const signaturePrefix = "kubo-autoconf:"
+
+// maxDocumentSize bounds the document fetched.
+const maxDocumentSize = 1 << 20
+
+var stateKey = datastore.NewKey("/local/autoconf")
+
+// Values are the network defaults of the document.
+type Values struct {
+	// Version orders the documents.
+	Version          uint64
+	Bootstrap        []string          `json:",omitempty"`
+	DelegatedRouters []string          `json:",omitempty"`
+	DNSResolvers     map[string]string `json:",omitempty"`
+}
+
+// Document is the signed document served at AutoConf.URL.
+type Document struct {
+	Payload    []byte
+	Signatures []Signature
+}
+
+// Signature is the signature of the payload by the key of a peer ID.
+type Signature struct {
+	Key       string
+	Signature []byte
+}
+
+// Sign returns the document of v signed with keys.
+func Sign(v *Values, keys ...crypto.PrivKey) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := Document{Payload: payload}
+	for _, sk := range keys {
+		id, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := sk.Sign(append([]byte(signaturePrefix), payload...))
+		if err != nil {
+			return nil, err
+		}
+		doc.Signatures = append(doc.Signatures, Signature{Key: id.String(), Signature: sig})
+	}
+	return json.Marshal(doc)
+}
+
+// TrustedKeys returns the public keys of AutoConf.TrustedKeys.
+func TrustedKeys(cfg config.AutoConf) (map[peer.ID]crypto.PubKey, error) {
+	if len(cfg.TrustedKeys) == 0 {
+		return nil, errors.New("AutoConf.TrustedKeys must list the keys the document is signed with")
+	}
+	keys := make(map[peer.ID]crypto.PubKey, len(cfg.TrustedKeys))
+	for _, s := range cfg.TrustedKeys {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("AutoConf.TrustedKeys: invalid peer ID %q: %w", s, err)
+		}
+		pk, err := id.ExtractPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("AutoConf.TrustedKeys: the public key of %s is not embedded in its peer ID", s)
+		}
+		keys[id] = pk
+	}
+	return keys, nil
+}
+
+// Verify returns the values of the document data, signed by one of the
+// trusted keys.
+func Verify(data []byte, trusted map[peer.ID]crypto.PubKey) (*Values, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	signed := append([]byte(signaturePrefix), doc.Payload...)
+	for _, sig := range doc.Signatures {
+		id, err := peer.Decode(sig.Key)
+		if err != nil {
+			continue
+		}
+		pk, ok := trusted[id]
+		if !ok {
+			continue
+		}
+		if valid, err := pk.Verify(signed, sig.Signature); err != nil || !valid {
+			return nil, fmt.Errorf("invalid signature by %s", id)
+		}
+		var v Values
+		if err := json.Unmarshal(doc.Payload, &v); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		return &v, nil
+	}
+	return nil, errors.New("the document is not signed by any of AutoConf.TrustedKeys")
+}
+
+// Fetch returns the values of the document of AutoConf.URL.
+func Fetch(ctx context.Context, cfg config.AutoConf) (*Values, error) {
+	trusted, err := TrustedKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("AutoConf.URL is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded %s", cfg.URL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDocumentSize {
+		return nil, fmt.Errorf("the document is larger than %d bytes", maxDocumentSize)
+	}
+	return Verify(data, trusted)
+}
+
+// State is the values of the last document fetched, and the outcome of the
+// last fetch, kept in the datastore.
+type State struct {
+	Values      *Values   `json:",omitempty"`
+	Fetched     time.Time `json:",omitempty"`
+	LastAttempt time.Time `json:",omitempty"`
+	LastError   string    `json:",omitempty"`
+}
+
+// LoadState returns the state kept in ds, empty when no document was
+// fetched.
+func LoadState(ctx context.Context, ds datastore.Datastore) (*State, error) {
+	data, err := ds.Get(ctx, stateKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid autoconf state: %w", err)
+	}
+	return &s, nil
+}
+
+func saveState(ctx context.Context, ds datastore.Datastore, s *State) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ds.Put(ctx, stateKey, data)
+}
+
+// Refresh fetches the document of AutoConf.URL, and keeps its values in ds
+// unless they are older than the ones kept. The outcome is kept in the
+// state.
+func Refresh(ctx context.Context, ds datastore.Datastore, cfg config.AutoConf) (*State, error) {
+	s, err := LoadState(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	s.LastAttempt = time.Now()
+	v, err := Fetch(ctx, cfg)
+	if err == nil && s.Values != nil && v.Version < s.Values.Version {
+		err = fmt.Errorf("the version %d of the document is older than the version %d kept", v.Version, s.Values.Version)
+	}
+	if err != nil {
+		s.LastError = err.Error()
+	} else {
+		s.Values, s.Fetched, s.LastError = v, s.LastAttempt, ""
+	}
+	if serr := saveState(ctx, ds, s); serr != nil {
+		return nil, serr
+	}
+	return s, err
+}
+
+// Load returns the values kept in ds, or nil when AutoConf is disabled or
+// no document was fetched, in which case the "auto" values of the config
+// are replaced by the built-in defaults.
+func Load(ctx context.Context, cfg *config.Config, ds datastore.Datastore) *Values {
+	if !cfg.AutoConf.Enabled.WithDefault(config.DefaultAutoConfEnabled) {
+		return nil
+	}
+	s, err := LoadState(ctx, ds)
+	if err != nil {
+		log.Errorf("loading the network defaults: %s", err)
+		return nil
+	}
+	return s.Values
+}
+
+// BootstrapPeers returns the peers of Bootstrap, with "auto" replaced by the
+// bootstrap peers of v, or by the default ones.
+func (v *Values) BootstrapPeers(cfg *config.Config) ([]peer.AddrInfo, error) {
+	if v == nil || len(v.Bootstrap) == 0 {
+		return cfg.BootstrapPeers()
+	}
+	return config.ParseBootstrapPeers(config.ExpandAuto(cfg.Bootstrap, v.Bootstrap))
+}
+
+// Routers returns Routing.DelegatedRouters, with "auto" replaced by
+// the delegated routers of v. Without them, "auto" is removed, so that the
+// default routers are used when no other is set.
+func (v *Values) Routers(cfg *config.Config) []string {
+	var routers []string
+	if v != nil {
+		routers = v.DelegatedRouters
+	}
+	return config.ExpandAuto(cfg.Routing.DelegatedRouters, routers)
+}
+
+// Resolvers returns DNS.Resolvers, with the "auto" resolvers replaced by
+// the resolvers of v for the same domain. Without them, the domain is
+// resolved by the default resolver.
+func (v *Values) Resolvers(cfg *config.Config) map[string]string {
+	resolvers := maps.Clone(cfg.DNS.Resolvers)
+	for domain, url := range resolvers {
+		if url != config.AutoPlaceholder {
+			continue
+		}
+		if v != nil && v.DNSResolvers[domain] != "" {
+			resolvers[domain] = v.DNSResolvers[domain]
+		} else {
+			delete(resolvers, domain)
+		}
+	}
+	return resolvers
+}
@@ -0,0 +1,123 @@
+package autoconf
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBootstrap = "/ip4/127.0.0.1/tcp/4001/p2p/12D3KooWGC6TvWhfapngX6wvJHMYvKpDMXPb3ZnCZ6dMoaMtimQ5"
+
+func newTestKey(t *testing.T) (crypto.PrivKey, string) {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	return sk, id.String()
+}
+
+func TestVerify(t *testing.T) {
+	trustedKey, trustedID := newTestKey(t)
+	otherKey, _ := newTestKey(t)
+	trusted, err := TrustedKeys(config.AutoConf{TrustedKeys: []string{trustedID}})
+	require.NoError(t, err)
+
+	v := &Values{Version: 2, Bootstrap: []string{testBootstrap}}
+	doc, err := Sign(v, otherKey, trustedKey)
+	require.NoError(t, err)
+	got, err := Verify(doc, trusted)
+	require.NoError(t, err)
+	assert.Equal(t, v, got)
+
+	doc, err = Sign(v, otherKey)
+	require.NoError(t, err)
+	_, err = Verify(doc, trusted)
+	assert.ErrorContains(t, err, "not signed by any of AutoConf.TrustedKeys")
+
+	// the signature of another payload is refused
+	doc, err = Sign(v, trustedKey)
+	require.NoError(t, err)
+	var tampered Document
+	require.NoError(t, json.Unmarshal(doc, &tampered))
+	tampered.Payload, err = json.Marshal(&Values{Version: 3, Bootstrap: v.Bootstrap})
+	require.NoError(t, err)
+	doc, err = json.Marshal(tampered)
+	require.NoError(t, err)
+	_, err = Verify(doc, trusted)
+	assert.ErrorContains(t, err, "invalid signature")
+
+	// the public key of an RSA peer ID is not embedded in it
+	_, err = TrustedKeys(config.AutoConf{TrustedKeys: []string{"QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"}})
+	assert.ErrorContains(t, err, "not embedded")
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	sk, id := newTestKey(t)
+	var doc []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(doc)
+	}))
+	t.Cleanup(srv.Close)
+	ac := config.AutoConf{Enabled: config.True, URL: srv.URL, TrustedKeys: []string{id}}
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	var err error
+	doc, err = Sign(&Values{
+		Version:          2,
+		Bootstrap:        []string{testBootstrap},
+		DelegatedRouters: []string{"https://router.example.net"},
+		DNSResolvers:     map[string]string{"eth.": "https://dns.example.net/dns-query"},
+	}, sk)
+	require.NoError(t, err)
+	s, err := Refresh(ctx, ds, ac)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), s.Values.Version)
+	assert.False(t, s.Fetched.IsZero())
+
+	// an older document is refused
+	doc, err = Sign(&Values{Version: 1}, sk)
+	require.NoError(t, err)
+	_, err = Refresh(ctx, ds, ac)
+	assert.ErrorContains(t, err, "older")
+	s, err = LoadState(ctx, ds)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), s.Values.Version)
+	assert.Contains(t, s.LastError, "older")
+
+	cfg := &config.Config{AutoConf: ac}
+	cfg.Bootstrap = []string{config.AutoPlaceholder, config.DefaultBootstrapAddresses[4]}
+	cfg.Routing.DelegatedRouters = []string{config.AutoPlaceholder}
+	cfg.DNS.Resolvers = map[string]string{"eth.": config.AutoPlaceholder, "crypto.": config.AutoPlaceholder, "example.": "https://example.net/dns-query"}
+
+	v := Load(ctx, cfg, ds)
+	peers, err := v.BootstrapPeers(cfg)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{testBootstrap, config.DefaultBootstrapAddresses[4]}, config.BootstrapPeerStrings(peers))
+	assert.Equal(t, []string{"https://router.example.net"}, v.Routers(cfg))
+	assert.Equal(t, map[string]string{"eth.": "https://dns.example.net/dns-query", "example.": "https://example.net/dns-query"}, v.Resolvers(cfg))
+	assert.Equal(t, config.AutoPlaceholder, cfg.DNS.Resolvers["eth."], "the config is not modified")
+
+	// without AutoConf, "auto" is replaced by the built-in defaults
+	cfg.AutoConf.Enabled = config.False
+	v = Load(ctx, cfg, ds)
+	assert.Nil(t, v)
+	peers, err = v.BootstrapPeers(cfg)
+	require.NoError(t, err)
+	defaults, err := config.DefaultBootstrapPeers()
+	require.NoError(t, err)
+	assert.Len(t, peers, len(defaults))
+	assert.Empty(t, v.Routers(cfg))
+	assert.Equal(t, map[string]string{"example.": "https://example.net/dns-query"}, v.Resolvers(cfg))
+}
@@ -0,0 +1,14 @@
+// Package autoconf keeps the network defaults of the node up to date, as
+// configured by AutoConf: its bootstrap peers, delegated routers and DNS
+// resolvers are fetched from a JSON document signed by one of the trusted
+// keys, and replace the "auto" values of the config.
+//
+// The document is a JSON object with the Payload, the JSON of the Values
+// encoded in base64, and its Signatures, by the keys named by their peer ID:
+//
+//	{"Payload": "eyJWZXJzaW9uIjox...", "Signatures": [{"Key": "12D3KooW...", "Signature": "..."}]}
+//
+// The signed bytes are the payload prefixed with "kubo-autoconf:". The
+// Version of the values only increases: the documents older than the last one
+// fetched are refused, so that an attacker cannot serve an outdated list.
+package autoconf
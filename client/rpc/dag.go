@@ -3,6 +3,7 @@ package rpc
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 
@@ -10,7 +11,10 @@ import (
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	format "github.com/ipfs/go-ipld-format"
+	iface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/core/coreiface/options"
+	gocarv2 "github.com/ipld/go-car/v2"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
 	multicodec "github.com/multiformats/go-multicodec"
 )
 
@@ -129,6 +133,98 @@ func (api *HttpDagServ) RemoveMany(ctx context.Context, cids []cid.Cid) error {
 	return nil
 }
 
+func (api *HttpDagServ) Export(ctx context.Context, root path.Path, opts ...options.DagExportOption) (io.ReadCloser, error) {
+	settings, err := options.DagExportOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Selector != selectorparse.CommonSelector_ExploreAllRecursively {
+		return nil, fmt.Errorf("dag export with a selector: %w", iface.ErrNotSupported)
+	}
+
+	resp, err := api.core().Request("dag/export", root.String()).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, parseErrNotFoundWithFallbackToError(resp.Error)
+	}
+	return resp.Output, nil
+}
+
+func (api *HttpDagServ) Import(ctx context.Context, r io.Reader, opts ...options.DagImportOption) (iface.DagImportResult, error) {
+	var res iface.DagImportResult
+	settings, err := options.DagImportOptions(opts...)
+	if err != nil {
+		return res, err
+	}
+	if settings.OnBlock != nil {
+		return res, fmt.Errorf("dag import with a block hook: %w", iface.ErrNotSupported)
+	}
+
+	// the daemon only reports the roots it pins, read the others from the
+	// CAR header before sending it
+	var unpinned []cid.Cid
+	if !settings.PinRoots {
+		var header bytes.Buffer
+		car, err := gocarv2.NewBlockReader(io.TeeReader(r, &header))
+		if err != nil {
+			return res, err
+		}
+		seen := cid.NewSet()
+		for _, c := range car.Roots {
+			if seen.Visit(c) {
+				unpinned = append(unpinned, c)
+			}
+		}
+		r = io.MultiReader(&header, r)
+	}
+
+	resp, err := api.core().Request("dag/import").
+		Option("pin-roots", settings.PinRoots).
+		Option("stats", true).
+		FileBody(r).
+		Send(ctx)
+	if err != nil {
+		return res, err
+	}
+	if resp.Error != nil {
+		return res, resp.Error
+	}
+	defer resp.Close()
+
+	for _, c := range unpinned {
+		res.Roots = append(res.Roots, iface.DagImportRoot{Cid: c})
+	}
+
+	dec := json.NewDecoder(resp.Output)
+	for {
+		var out struct {
+			Root *struct {
+				Cid         cid.Cid
+				PinErrorMsg string
+			}
+			Stats *struct {
+				BlockCount      uint64
+				BlockBytesCount uint64
+			}
+		}
+		if err := dec.Decode(&out); err != nil {
+			if err == io.EOF {
+				return res, nil
+			}
+			return res, err
+		}
+		if out.Root != nil {
+			res.Roots = append(res.Roots, iface.DagImportRoot{Cid: out.Root.Cid, PinErrorMsg: out.Root.PinErrorMsg})
+		}
+		if out.Stats != nil {
+			res.BlockCount = out.Stats.BlockCount
+			res.BlockBytesCount = out.Stats.BlockBytesCount
+		}
+	}
+}
+
 func (api *httpNodeAdder) core() *HttpApi {
 	return (*HttpApi)(api)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/boxo/path"
@@ -140,6 +141,31 @@ func (api *KeyAPI) Remove(ctx context.Context, name string) (iface.Key, error) {
 	return newKey(out.Keys[0].Name, out.Keys[0].Id)
 }
 
+func (api *KeyAPI) Import(ctx context.Context, name string, data []byte, opts ...caopts.KeyImportOption) (iface.Key, error) {
+	options, err := caopts.KeyImportOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var out keyOutput
+	err = api.core().Request("key/import", name).
+		Option("format", options.Format).
+		Option("allow-any-key-type", options.AllowAnyType).
+		FileBody(bytes.NewReader(data)).
+		Exec(ctx, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(out.Name, out.Id)
+}
+
+// Export is not supported: the private keys are not served by the RPC API,
+// see 'ipfs key export', which reads the keystore of the repo.
+func (api *KeyAPI) Export(ctx context.Context, name string, opts ...caopts.KeyExportOption) ([]byte, error) {
+	return nil, fmt.Errorf("key export over the RPC API: %w", iface.ErrNotSupported)
+}
+
 func (api *KeyAPI) core() *HttpApi {
 	return (*HttpApi)(api)
 }
@@ -0,0 +1,326 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/merkledag/dagutils"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multicodec"
+)
+
+// PatchAPI edits dag-pb and dag-cbor nodes on the client: the nodes are read
+// and written with the dag API of the node, so it works with every version of
+// the RPC API. It replaces the removed 'ipfs object patch' commands.
+type PatchAPI HttpApi
+
+// Patch returns the PatchAPI of the node.
+func (api *HttpApi) Patch() *PatchAPI {
+	return (*PatchAPI)(api)
+}
+
+type PatchSettings struct {
+	Create    bool
+	Pin       bool
+	UpdatePin bool
+}
+
+type PatchOption func(*PatchSettings) error
+
+func PatchOptions(opts ...PatchOption) (*PatchSettings, error) {
+	options := &PatchSettings{
+		Create:    false,
+		Pin:       false,
+		UpdatePin: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type patchOpts struct{}
+
+var Patch patchOpts
+
+// Create is an option for Patch.AddLink which specifies whether to create the
+// missing dag-pb directories of a link path. Default is false
+func (patchOpts) Create(create bool) PatchOption {
+	return func(settings *PatchSettings) error {
+		settings.Create = create
+		return nil
+	}
+}
+
+// Pin is an option for the PatchAPI methods which specifies whether to pin
+// the patched node recursively. Default is false
+func (patchOpts) Pin(pin bool) PatchOption {
+	return func(settings *PatchSettings) error {
+		settings.Pin = pin
+		return nil
+	}
+}
+
+// UpdatePin is an option for the PatchAPI methods which specifies whether to
+// move the recursive pin of the base node to the patched node, like
+// 'ipfs pin update'. Default is false
+func (patchOpts) UpdatePin(update bool) PatchOption {
+	return func(settings *PatchSettings) error {
+		settings.UpdatePin = update
+		return nil
+	}
+}
+
+// AddLink adds a link named name to child. In dag-pb nodes, name can be a
+// path of links, created with Patch.Create. In dag-cbor nodes, name is a key
+// of the map node, replaced if it exists.
+func (api *PatchAPI) AddLink(ctx context.Context, base path.Path, name string, child path.Path, opts ...PatchOption) (path.ImmutablePath, error) {
+	options, err := PatchOptions(opts...)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	childPath, _, err := api.core().ResolvePath(ctx, child)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	return api.patch(ctx, base, options,
+		func(nd *dag.ProtoNode) (cid.Cid, error) {
+			childNd, err := api.core().Dag().Get(ctx, childPath.RootCid())
+			if err != nil {
+				return cid.Undef, err
+			}
+			var createfunc func() *dag.ProtoNode
+			if options.Create {
+				createfunc = ft.EmptyDirNode
+			}
+			e := dagutils.NewDagEditor(nd, api.core().Dag())
+			if err := e.InsertNodeAtPath(ctx, name, childNd, createfunc); err != nil {
+				return cid.Undef, err
+			}
+			return finalize(ctx, api, e)
+		},
+		func(fields map[string]datamodel.Node) error {
+			if strings.Contains(name, "/") {
+				return fmt.Errorf("invalid dag-cbor field name %q", name)
+			}
+			fields[name] = basicnode.NewLink(cidlink.Link{Cid: childPath.RootCid()})
+			return nil
+		},
+	)
+}
+
+// RmLink removes the link named name. In dag-pb nodes, name can be a path of
+// links. In dag-cbor nodes, name is a key of the map node.
+func (api *PatchAPI) RmLink(ctx context.Context, base path.Path, name string, opts ...PatchOption) (path.ImmutablePath, error) {
+	options, err := PatchOptions(opts...)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	return api.patch(ctx, base, options,
+		func(nd *dag.ProtoNode) (cid.Cid, error) {
+			e := dagutils.NewDagEditor(nd, api.core().Dag())
+			if err := e.RmLink(ctx, name); err != nil {
+				return cid.Undef, err
+			}
+			return finalize(ctx, api, e)
+		},
+		func(fields map[string]datamodel.Node) error {
+			if _, ok := fields[name]; !ok {
+				return fmt.Errorf("no field named %q", name)
+			}
+			delete(fields, name)
+			return nil
+		},
+	)
+}
+
+// SetData replaces the data of a dag-pb node.
+func (api *PatchAPI) SetData(ctx context.Context, base path.Path, data io.Reader, opts ...PatchOption) (path.ImmutablePath, error) {
+	options, err := PatchOptions(opts...)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	return api.patch(ctx, base, options,
+		func(nd *dag.ProtoNode) (cid.Cid, error) {
+			nd.SetData(b)
+			if err := api.core().Dag().Add(ctx, nd); err != nil {
+				return cid.Undef, err
+			}
+			return nd.Cid(), nil
+		},
+		nil,
+	)
+}
+
+// SetField sets the key field of a dag-cbor map node to value.
+func (api *PatchAPI) SetField(ctx context.Context, base path.Path, field string, value datamodel.Node, opts ...PatchOption) (path.ImmutablePath, error) {
+	options, err := PatchOptions(opts...)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+
+	return api.patch(ctx, base, options,
+		nil,
+		func(fields map[string]datamodel.Node) error {
+			fields[field] = value
+			return nil
+		},
+	)
+}
+
+func finalize(ctx context.Context, api *PatchAPI, e *dagutils.Editor) (cid.Cid, error) {
+	nd, err := e.Finalize(ctx, api.core().Dag())
+	if err != nil {
+		return cid.Undef, err
+	}
+	return nd.Cid(), nil
+}
+
+// patch applies editPB to dag-pb nodes and editCBOR to the fields of dag-cbor
+// map nodes, and pins the patched node as set in options. A nil edit function
+// means the codec is not supported.
+func (api *PatchAPI) patch(ctx context.Context, base path.Path, options *PatchSettings,
+	editPB func(*dag.ProtoNode) (cid.Cid, error),
+	editCBOR func(map[string]datamodel.Node) error,
+) (path.ImmutablePath, error) {
+	if options.Pin && options.UpdatePin {
+		return path.ImmutablePath{}, errors.New("the Pin and UpdatePin patch options are mutually exclusive")
+	}
+
+	basePath, rest, err := api.core().ResolvePath(ctx, base)
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	if len(rest) > 0 {
+		return path.ImmutablePath{}, fmt.Errorf("%s does not resolve to a node", base)
+	}
+	baseCid := basePath.RootCid()
+
+	var patched cid.Cid
+	switch codec := multicodec.Code(baseCid.Type()); {
+	case codec == multicodec.DagPb && editPB != nil:
+		nd, err := api.core().Dag().Get(ctx, baseCid)
+		if err != nil {
+			return path.ImmutablePath{}, err
+		}
+		pbnd, ok := nd.(*dag.ProtoNode)
+		if !ok {
+			return path.ImmutablePath{}, dag.ErrNotProtobuf
+		}
+		patched, err = editPB(pbnd)
+		if err != nil {
+			return path.ImmutablePath{}, err
+		}
+	case codec == multicodec.DagCbor && editCBOR != nil:
+		patched, err = api.patchCBOR(ctx, baseCid, editCBOR)
+		if err != nil {
+			return path.ImmutablePath{}, err
+		}
+	default:
+		return path.ImmutablePath{}, fmt.Errorf("cannot patch %s nodes", codec)
+	}
+
+	p := path.FromCid(patched)
+	switch {
+	case options.Pin:
+		err = api.core().Pin().Add(ctx, p)
+	case options.UpdatePin:
+		err = api.core().Pin().Update(ctx, basePath, p)
+	}
+	if err != nil {
+		return path.ImmutablePath{}, err
+	}
+	return p, nil
+}
+
+func (api *PatchAPI) patchCBOR(ctx context.Context, c cid.Cid, edit func(map[string]datamodel.Node) error) (cid.Cid, error) {
+	r, err := api.core().Block().Get(ctx, path.FromCid(c))
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := dagcbor.Decode(nb, r); err != nil {
+		return cid.Undef, err
+	}
+	nd := nb.Build()
+	if nd.Kind() != datamodel.Kind_Map {
+		return cid.Undef, fmt.Errorf("cannot patch dag-cbor %s nodes, only maps", nd.Kind())
+	}
+
+	fields := make(map[string]datamodel.Node, nd.Length())
+	for it := nd.MapIterator(); !it.Done(); {
+		k, v, err := it.Next()
+		if err != nil {
+			return cid.Undef, err
+		}
+		key, err := k.AsString()
+		if err != nil {
+			return cid.Undef, err
+		}
+		fields[key] = v
+	}
+	if err := edit(fields); err != nil {
+		return cid.Undef, err
+	}
+
+	mb := basicnode.Prototype.Map.NewBuilder()
+	ma, err := mb.BeginMap(int64(len(fields)))
+	if err != nil {
+		return cid.Undef, err
+	}
+	for k, v := range fields {
+		if err := ma.AssembleKey().AssignString(k); err != nil {
+			return cid.Undef, err
+		}
+		if err := ma.AssembleValue().AssignNode(v); err != nil {
+			return cid.Undef, err
+		}
+	}
+	if err := ma.Finish(); err != nil {
+		return cid.Undef, err
+	}
+
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(mb.Build(), &buf); err != nil {
+		return cid.Undef, err
+	}
+	prefix := c.Prefix()
+	stat, err := api.core().Block().Put(ctx, &buf,
+		caopts.Block.Hash(prefix.MhType, prefix.MhLength),
+		caopts.Block.CidCodec("dag-cbor"),
+	)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return stat.Path().RootCid(), nil
+}
+
+func (api *PatchAPI) core() *HttpApi {
+	return (*HttpApi)(api)
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	node := harness.NewT(t).NewNode().Init().StartDaemon("--offline")
+	defer node.StopDaemon()
+	api, err := NewApi(node.APIAddr())
+	require.NoError(t, err)
+
+	child, err := path.NewPath("/ipfs/" + node.IPFSAddStr("child"))
+	require.NoError(t, err)
+
+	t.Run("dag-pb", func(t *testing.T) {
+		// the empty directory added by ipfs init
+		empty, err := path.NewPath("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+		require.NoError(t, err)
+
+		_, err = api.Patch().AddLink(ctx, empty, "a/b", child)
+		require.Error(t, err, "missing directories are not created without Create")
+
+		p, err := api.Patch().AddLink(ctx, empty, "a/b", child, Patch.Create(true), Patch.Pin(true))
+		require.NoError(t, err)
+		require.Equal(t, "child", node.IPFS("cat", p.String()+"/a/b").Stdout.String())
+		require.Contains(t, node.IPFS("pin", "ls", "--type=recursive").Stdout.String(), p.RootCid().String())
+
+		removed, err := api.Patch().RmLink(ctx, p, "a/b", Patch.UpdatePin(true))
+		require.NoError(t, err)
+		pins := node.IPFS("pin", "ls", "--type=recursive").Stdout.String()
+		require.Contains(t, pins, removed.RootCid().String())
+		require.NotContains(t, pins, p.RootCid().String())
+
+		withData, err := api.Patch().SetData(ctx, empty, strings.NewReader("data"))
+		require.NoError(t, err)
+		nd, err := api.Dag().Get(ctx, withData.RootCid())
+		require.NoError(t, err)
+		require.Contains(t, string(nd.RawData()), "data")
+
+		_, err = api.Patch().SetField(ctx, empty, "field", basicnode.NewString("value"))
+		require.ErrorContains(t, err, "cannot patch dag-pb nodes")
+	})
+
+	t.Run("dag-cbor", func(t *testing.T) {
+		base, err := path.NewPath("/ipfs/" + node.PipeStrToIPFS(`{"name":"base"}`, "dag", "put").Stdout.Trimmed())
+		require.NoError(t, err)
+
+		p, err := api.Patch().AddLink(ctx, base, "child", child)
+		require.NoError(t, err)
+		p, err = api.Patch().SetField(ctx, p, "name", basicnode.NewString("patched"))
+		require.NoError(t, err)
+		require.JSONEq(t, `"patched"`, node.IPFS("dag", "get", p.String()+"/name").Stdout.String())
+		require.Equal(t, "child", node.IPFS("cat", p.String()+"/child").Stdout.String())
+
+		p, err = api.Patch().RmLink(ctx, p, "child")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"name":"patched"}`, node.IPFS("dag", "get", p.String()).Stdout.String())
+
+		_, err = api.Patch().RmLink(ctx, p, "child")
+		require.ErrorContains(t, err, `no field named "child"`)
+		_, err = api.Patch().SetData(ctx, p, strings.NewReader("data"))
+		require.ErrorContains(t, err, "cannot patch dag-cbor nodes")
+	})
+}
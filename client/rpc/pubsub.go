@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"sync"
+	"time"
 
 	iface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
@@ -71,8 +74,11 @@ func (api *PubsubAPI) Publish(ctx context.Context, topic string, message []byte)
 type pubsubSub struct {
 	messages chan pubsubMessage
 
-	done    chan struct{}
-	rcloser func() error
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu   sync.Mutex
+	resp *Response
 }
 
 type pubsubMessage struct {
@@ -144,6 +150,10 @@ func (s *pubsubSub) Next(ctx context.Context) (iface.PubSubMessage, error) {
 	}
 }
 
+// Subscribe to messages on a given topic. When the stream of the subscription
+// breaks, e.g. when the daemon restarts, it is transparently re-established,
+// skipping the messages that were already delivered. Next returns the error of
+// the last attempt once the daemon cannot be reached for a while.
 func (api *PubsubAPI) Subscribe(ctx context.Context, topic string, opts ...caopts.PubSubSubscribeOption) (iface.PubSubSubscription, error) {
 	/* right now we have no options (discover got deprecated)
 	options, err := caopts.PubSubSubscribeOptions(opts...)
@@ -151,41 +161,46 @@ func (api *PubsubAPI) Subscribe(ctx context.Context, topic string, opts ...caopt
 		return nil, err
 	}
 	*/
-	resp, err := api.core().Request("pubsub/sub", toMultibase([]byte(topic))).Send(ctx)
+	resp, err := api.subscribe(ctx, topic)
 	if err != nil {
 		return nil, err
 	}
-	if resp.Error != nil {
-		return nil, resp.Error
-	}
 
 	sub := &pubsubSub{
 		messages: make(chan pubsubMessage),
 		done:     make(chan struct{}),
-		rcloser: func() error {
-			return resp.Cancel()
-		},
+		resp:     resp,
 	}
 
-	dec := json.NewDecoder(resp.Output)
-
 	go func() {
 		defer close(sub.messages)
 
+		seen := newSeenMessages(pubsubSeenMessages)
+		dec := json.NewDecoder(resp.Output)
 		for {
 			var msg pubsubMessage
 			if err := dec.Decode(&msg); err != nil {
-				if err == io.EOF {
+				if sub.closed(ctx) {
 					return
 				}
-				msg.err = err
+				resp, err := sub.reconnect(ctx, api, topic)
+				if err != nil {
+					sub.send(ctx, pubsubMessage{err: err})
+					return
+				}
+				if resp == nil {
+					return
+				}
+				dec = json.NewDecoder(resp.Output)
+				continue
+			}
+			// a message may be delivered again by the stream that
+			// replaced a broken one
+			if !seen.add(msg.JFrom + "/" + msg.JSeqno) {
+				continue
 			}
 
-			select {
-			case sub.messages <- msg:
-			case <-sub.done:
-				return
-			case <-ctx.Done():
+			if !sub.send(ctx, msg) {
 				return
 			}
 		}
@@ -194,12 +209,117 @@ func (api *PubsubAPI) Subscribe(ctx context.Context, topic string, opts ...caopt
 	return sub, nil
 }
 
+const (
+	pubsubReconnectAttempts   = 10
+	pubsubReconnectMinBackoff = 100 * time.Millisecond
+	pubsubReconnectMaxBackoff = 5 * time.Second
+	pubsubSeenMessages        = 1024
+)
+
+func (api *PubsubAPI) subscribe(ctx context.Context, topic string) (*Response, error) {
+	resp, err := api.core().Request("pubsub/sub", toMultibase([]byte(topic))).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp, nil
+}
+
+// reconnect re-establishes the stream of the subscription, backing off
+// between attempts. Errors returned by the daemon are not retried. It returns
+// a nil response when the subscription is closed meanwhile.
+func (s *pubsubSub) reconnect(ctx context.Context, api *PubsubAPI, topic string) (*Response, error) {
+	backoff := pubsubReconnectMinBackoff
+	var err error
+	for i := 0; i < pubsubReconnectAttempts; i++ {
+		select {
+		case <-time.After(backoff):
+		case <-s.done:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, nil
+		}
+		backoff = min(2*backoff, pubsubReconnectMaxBackoff)
+
+		var resp *Response
+		resp, err = api.subscribe(ctx, topic)
+		if e := (*Error)(nil); errors.As(err, &e) {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.closed(ctx) {
+			resp.Cancel()
+			return nil, nil
+		}
+		s.resp = resp
+		return resp, nil
+	}
+	return nil, err
+}
+
+func (s *pubsubSub) send(ctx context.Context, msg pubsubMessage) bool {
+	select {
+	case s.messages <- msg:
+		return true
+	case <-s.done:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *pubsubSub) closed(ctx context.Context) bool {
+	select {
+	case <-s.done:
+		return true
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *pubsubSub) Close() error {
-	if s.done != nil {
+	var err error
+	s.closeOnce.Do(func() {
 		close(s.done)
-		s.done = nil
+		s.mu.Lock()
+		err = s.resp.Cancel()
+		s.mu.Unlock()
+	})
+	return err
+}
+
+// seenMessages remembers the last size messages delivered by a subscription.
+type seenMessages struct {
+	ids   map[string]struct{}
+	order []string
+	size  int
+}
+
+func newSeenMessages(size int) *seenMessages {
+	return &seenMessages{ids: make(map[string]struct{}, size), size: size}
+}
+
+// add returns false if id was seen already.
+func (s *seenMessages) add(id string) bool {
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+	if len(s.order) == s.size {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
 	}
-	return s.rcloser()
+	s.ids[id] = struct{}{}
+	s.order = append(s.order, id)
+	return true
 }
 
 func (api *PubsubAPI) core() *HttpApi {
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubsubReconnect(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	node := harness.NewT(t).NewNode().Init().StartDaemon("--enable-pubsub-experiment")
+	defer node.StopDaemon()
+	api, err := NewApi(node.APIAddr())
+	require.NoError(t, err)
+
+	sub, err := api.PubSub().Subscribe(ctx, "topic")
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// the subscription is never reported as ready, publish until the first
+	// message is received
+	publish := func(data string) {
+		received := make(chan struct{})
+		defer close(received)
+		go func() {
+			for {
+				_ = api.PubSub().Publish(ctx, "topic", []byte(data))
+				select {
+				case <-received:
+					return
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+		}()
+		msg, err := sub.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, data, string(msg.Data()))
+	}
+
+	publish("before restart")
+	// restart on the same port, the subscription reconnects to it
+	apiAddr := node.APIAddr().String()
+	node.StopDaemon()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Addresses.API = []string{apiAddr}
+	})
+	node.StartDaemon("--enable-pubsub-experiment")
+	publish("after restart")
+
+	sub.Close()
+	_, err = sub.Next(ctx)
+	require.Error(t, err)
+}
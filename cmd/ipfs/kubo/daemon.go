@@ -1,6 +1,7 @@
 package kubo
 
 import (
+	"crypto/tls"
 	"errors"
 	_ "expvar"
 	"fmt"
@@ -8,8 +9,12 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,11 +33,14 @@ import (
 	corehttp "github.com/ipfs/kubo/core/corehttp"
 	options "github.com/ipfs/kubo/core/coreiface/options"
 	corerepo "github.com/ipfs/kubo/core/corerepo"
+	corenode "github.com/ipfs/kubo/core/node"
 	libp2p "github.com/ipfs/kubo/core/node/libp2p"
 	nodeMount "github.com/ipfs/kubo/fuse/node"
+	kubologging "github.com/ipfs/kubo/logging"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations/ipfsfetcher"
+	"github.com/ipfs/kubo/sandbox"
 	goprocess "github.com/jbenet/goprocess"
 	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
 	pnet "github.com/libp2p/go-libp2p/core/pnet"
@@ -62,16 +70,20 @@ const (
 	routingOptionDHTKwd        = "dht"
 	routingOptionDHTServerKwd  = "dhtserver"
 	routingOptionNoneKwd       = "none"
+	routingOptionDelegatedKwd  = "delegated"
 	routingOptionCustomKwd     = "custom"
 	routingOptionDefaultKwd    = "default"
 	routingOptionAutoKwd       = "auto"
 	routingOptionAutoClientKwd = "autoclient"
 	unencryptTransportKwd      = "disable-transport-encryption"
 	unrestrictedAPIAccessKwd   = "unrestricted-api"
+	apiOriginIsolationKwd      = "api-origin-isolation"
 	enablePubSubKwd            = "enable-pubsub-experiment"
 	enableIPNSPubSubKwd        = "enable-namesys-pubsub"
 	enableMultiplexKwd         = "enable-mplex-experiment"
 	agentVersionSuffix         = "agent-version-suffix"
+	bitswapRecordOnlyKwd       = "bitswap-record-only"
+	singleCommandServerKwd     = "single-command-server"
 	// apiAddrKwd    = "address-api"
 	// swarmAddrKwd  = "address-swarm".
 )
@@ -137,6 +149,24 @@ or send a SIGTERM signal to it (e.g. with 'kill'). It may take a while for the
 daemon to shutdown gracefully, but it can be killed forcibly by sending a
 second signal.
 
+Single command server
+
+On constrained devices, such as routers and NAS boxes, the daemon can run
+for a single purpose, leaving out the subsystems the purpose does not need
+to reduce its memory footprint:
+
+  ipfs daemon --single-command-server=gateway-only
+  ipfs daemon --single-command-server=pin-worker-only
+
+A gateway-only daemon serves the gateway and the RPC API, but does not
+announce content to the routing system nor reprovide it, republish IPNS
+names, run retrieval probes, resume interrupted pins, index content for
+'ipfs search' and 'ipfs dag query', pin MFS remotely or run the scheduled
+tasks of Schedule.Tasks. A pin-worker-only daemon builds no gateway, neither
+on Addresses.Gateway nor for the WebUI of the RPC API, nor the gateway
+extensions such as Gateway.AccessLog, and only keeps pinning, remote MFS
+pinning and the scheduled tasks.
+
 IPFS_PATH environment variable
 
 ipfs uses a repository in the local file system. By default, the repo is
@@ -166,6 +196,7 @@ Headers.
 		cmds.StringOption(ipfsMountKwd, "Path to the mountpoint for IPFS (if using --mount). Defaults to config setting."),
 		cmds.StringOption(ipnsMountKwd, "Path to the mountpoint for IPNS (if using --mount). Defaults to config setting."),
 		cmds.BoolOption(unrestrictedAPIAccessKwd, "Allow API access to unlisted hashes"),
+		cmds.BoolOption(apiOriginIsolationKwd, "Serve the RPC API only on the restricted listeners of API.Listeners, not on Addresses.API"),
 		cmds.BoolOption(unencryptTransportKwd, "Disable transport encryption (for debugging protocols)"),
 		cmds.BoolOption(enableGCKwd, "Enable automatic periodic repo garbage collection"),
 		cmds.BoolOption(adjustFDLimitKwd, "Check and raise file descriptor limits if needed").WithDefault(true),
@@ -174,6 +205,8 @@ Headers.
 		cmds.BoolOption(enableIPNSPubSubKwd, "Enable IPNS over pubsub. Implicitly enables pubsub, overrides Ipns.UsePubsub config."),
 		cmds.BoolOption(enableMultiplexKwd, "DEPRECATED"),
 		cmds.StringOption(agentVersionSuffix, "Optional suffix to the AgentVersion presented by `ipfs id` and exposed via libp2p identify protocol."),
+		cmds.BoolOption(bitswapRecordOnlyKwd, "Record the Bitswap wants of other peers without serving any block. See 'ipfs bitswap recorded-wants'."),
+		cmds.StringOption(singleCommandServerKwd, "Only start the subsystems needed to serve a single purpose: gateway-only or pin-worker-only."),
 
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmds.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -203,6 +236,12 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		log.Errorf("Injecting prometheus handler for metrics failed with message: %s\n", err.Error())
 	}
 
+	// Security.Sandbox restarts the daemon restricted to the paths it needs,
+	// before it does anything
+	if err := enterSandbox(req, env.(*oldcmds.Context).ConfigRoot); err != nil {
+		return fmt.Errorf("Security.Sandbox: %w", err)
+	}
+
 	// let the user know we're going.
 	fmt.Printf("Initializing daemon...\n")
 
@@ -360,6 +399,11 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	offline, _ := req.Options[offlineKwd].(bool)
 	ipnsps, ipnsPsSet := req.Options[enableIPNSPubSubKwd].(bool)
 	pubsub, psSet := req.Options[enablePubSubKwd].(bool)
+	bitswapRecordOnly, _ := req.Options[bitswapRecordOnlyKwd].(bool)
+	purpose, _ := req.Options[singleCommandServerKwd].(string)
+	if purpose != "" && !slices.Contains(corenode.Purposes(), purpose) {
+		return cmds.Errorf(cmds.ErrClient, "--%s must be one of %q", singleCommandServerKwd, corenode.Purposes())
+	}
 
 	if _, hasMplex := req.Options[enableMultiplexKwd]; hasMplex {
 		log.Errorf("The mplex multiplexer has been enabled by default and the experimental %s flag has been removed.")
@@ -371,6 +415,18 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return err
 	}
 
+	logFile, err := kubologging.Setup(cfg.Logging, cctx.ConfigRoot)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	// Resources.MaxMemoryMB is the soft memory limit of the Go runtime,
+	// unless one is set with GOMEMLIMIT
+	if mb := cfg.Resources.MaxMemoryMB.WithDefault(0); mb > 0 && os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(mb << 20)
+	}
+
 	if !psSet {
 		pubsub = cfg.Pubsub.Enabled.WithDefault(false)
 	}
@@ -384,9 +440,11 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		Permanent:                   true, // It is temporary way to signify that node is permanent
 		Online:                      !offline,
 		DisableEncryptedConnections: unencrypted,
+		Purpose:                     purpose,
 		ExtraOpts: map[string]bool{
-			"pubsub": pubsub,
-			"ipnsps": ipnsps,
+			"pubsub":            pubsub,
+			"ipnsps":            ipnsps,
+			"bitswaprecordonly": bitswapRecordOnly,
 		},
 		// TODO(Kubuxu): refactor Online vs Offline by adding Permanent vs Ephemeral
 	}
@@ -421,17 +479,22 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		ncfg.Routing = libp2p.DHTOption
 	case routingOptionDHTServerKwd:
 		ncfg.Routing = libp2p.DHTServerOption
+	case routingOptionDelegatedKwd:
+		ncfg.Routing = libp2p.ConstructDelegatedOnlyRouting(cfg)
 	case routingOptionNoneKwd:
 		ncfg.Routing = libp2p.NilRouterOption
 	case routingOptionCustomKwd:
 		if cfg.Routing.AcceleratedDHTClient.WithDefault(config.DefaultAcceleratedDHTClient) {
 			return fmt.Errorf("Routing.AcceleratedDHTClient option is set even tho Routing.Type is custom, using custom .AcceleratedDHTClient needs to be set on DHT routers individually")
 		}
+		// the HTTP addresses of the gateway are announced with the libp2p ones
+		addrs := cfg.Addresses
+		addrs.AppendAnnounce = append(slices.Clip(addrs.AppendAnnounce), cfg.Bitswap.HTTPAnnounce...)
 		ncfg.Routing = libp2p.ConstructDelegatedRouting(
 			cfg.Routing.Routers,
 			cfg.Routing.Methods,
 			cfg.Identity.PeerID,
-			cfg.Addresses,
+			addrs,
 			cfg.Identity.PrivKey,
 		)
 	default:
@@ -460,6 +523,9 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	}
 
 	printSwarmAddrs(node)
+	if purpose != "" {
+		fmt.Printf("Running as a single command server: %s\n", purpose)
+	}
 
 	if node.PrivateKey.Type() == p2pcrypto.RSA {
 		fmt.Print(`
@@ -544,16 +610,17 @@ take effect.
 		}
 	}
 
-	// construct http gateway
-	gwErrc, err := serveHTTPGateway(req, cctx)
-	if err != nil {
-		return err
-	}
-
-	// add trustless gateway over libp2p
-	p2pGwErrc, err := serveTrustlessGatewayOverLibp2p(cctx)
-	if err != nil {
-		return err
+	// construct http gateway, and add trustless gateway over libp2p
+	var gwErrc, p2pGwErrc <-chan error
+	if purpose != corenode.PurposePinWorkerOnly {
+		gwErrc, err = serveHTTPGateway(req, cctx)
+		if err != nil {
+			return err
+		}
+		p2pGwErrc, err = serveTrustlessGatewayOverLibp2p(cctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Add ipfs version info to prometheus metrics
@@ -572,8 +639,14 @@ take effect.
 	// initialize metrics collector
 	prometheus.MustRegister(&corehttp.IpfsNodeCollector{Node: node})
 
-	// start MFS pinning thread
-	startPinMFS(daemonConfigPollInterval, cctx, &ipfsPinMFSNode{node})
+	if purpose != corenode.PurposeGatewayOnly {
+		// start MFS pinning thread
+		startPinMFS(daemonConfigPollInterval, cctx, &ipfsPinMFSNode{node})
+
+		if err := startScheduler(cctx, node); err != nil {
+			return err
+		}
+	}
 
 	// The daemon is *finally* ready.
 	fmt.Printf("Daemon is ready\n")
@@ -638,55 +711,86 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		return nil, fmt.Errorf("serveHTTPApi: GetConfig() failed: %s", err)
 	}
 
-	listeners, err := sockets.TakeListeners("io.ipfs.api")
-	if err != nil {
-		return nil, fmt.Errorf("serveHTTPApi: socket activation failed: %s", err)
+	// with origin isolation, only the restricted listeners of API.Listeners
+	// are served
+	isolated, _ := req.Options[apiOriginIsolationKwd].(bool)
+	if isolated && len(cfg.API.Listeners) == 0 {
+		return nil, errors.New("serveHTTPApi: --api-origin-isolation requires API.Listeners to be set")
 	}
 
-	apiAddrs := make([]string, 0, 2)
-	apiAddr, _ := req.Options[commands.ApiOption].(string)
-	if apiAddr == "" {
-		apiAddrs = cfg.Addresses.API
-	} else {
-		apiAddrs = append(apiAddrs, apiAddr)
-	}
+	var listeners []manet.Listener
+	if !isolated {
+		listeners, err = sockets.TakeListeners("io.ipfs.api")
+		if err != nil {
+			return nil, fmt.Errorf("serveHTTPApi: socket activation failed: %s", err)
+		}
 
-	listenerAddrs := make(map[string]bool, len(listeners))
-	for _, listener := range listeners {
-		listenerAddrs[string(listener.Multiaddr().Bytes())] = true
-	}
+		apiAddrs := make([]string, 0, 2)
+		apiAddr, _ := req.Options[commands.ApiOption].(string)
+		if apiAddr == "" {
+			apiAddrs = cfg.Addresses.API
+		} else {
+			apiAddrs = append(apiAddrs, apiAddr)
+		}
 
-	for _, addr := range apiAddrs {
-		apiMaddr, err := ma.NewMultiaddr(addr)
-		if err != nil {
-			return nil, fmt.Errorf("serveHTTPApi: invalid API address: %q (err: %s)", addr, err)
+		listenerAddrs := make(map[string]bool, len(listeners))
+		for _, listener := range listeners {
+			listenerAddrs[string(listener.Multiaddr().Bytes())] = true
 		}
-		if listenerAddrs[string(apiMaddr.Bytes())] {
-			continue
+
+		for _, addr := range apiAddrs {
+			apiMaddr, err := ma.NewMultiaddr(addr)
+			if err != nil {
+				return nil, fmt.Errorf("serveHTTPApi: invalid API address: %q (err: %s)", addr, err)
+			}
+			if listenerAddrs[string(apiMaddr.Bytes())] {
+				continue
+			}
+
+			apiLis, err := manet.Listen(apiMaddr)
+			if err != nil {
+				return nil, fmt.Errorf("serveHTTPApi: manet.Listen(%s) failed: %s", apiMaddr, err)
+			}
+
+			listenerAddrs[string(apiMaddr.Bytes())] = true
+			listeners = append(listeners, apiLis)
 		}
+	}
 
-		apiLis, err := manet.Listen(apiMaddr)
+	restricted := make([]manet.Listener, len(cfg.API.Listeners))
+	for i, l := range cfg.API.Listeners {
+		apiMaddr, err := ma.NewMultiaddr(l.Address)
+		if err != nil {
+			return nil, fmt.Errorf("serveHTTPApi: invalid address of API.Listeners %q: %q (err: %s)", l.Name, l.Address, err)
+		}
+		restricted[i], err = manet.Listen(apiMaddr)
 		if err != nil {
 			return nil, fmt.Errorf("serveHTTPApi: manet.Listen(%s) failed: %s", apiMaddr, err)
 		}
-
-		listenerAddrs[string(apiMaddr.Bytes())] = true
-		listeners = append(listeners, apiLis)
 	}
 
-	if len(cfg.API.Authorizations) > 0 && len(listeners) > 0 {
+	if len(cfg.API.Authorizations) > 0 && len(listeners)+len(restricted) > 0 {
 		fmt.Printf("RPC API access is limited by the rules defined in API.Authorizations\n")
 	}
 
+	// a pin-worker-only daemon builds no gateway backend, not even the one
+	// of the WebUI on the RPC API
+	purpose, _ := req.Options[singleCommandServerKwd].(string)
+	webUI := purpose != corenode.PurposePinWorkerOnly
 	for _, listener := range listeners {
 		// we might have listened to /tcp/0 - let's see what we are listing on
 		fmt.Printf("RPC API server listening on %s\n", listener.Multiaddr())
 		// Browsers require TCP.
 		switch listener.Addr().Network() {
 		case "tcp", "tcp4", "tcp6":
-			fmt.Printf("WebUI: http://%s/webui\n", listener.Addr())
+			if webUI {
+				fmt.Printf("WebUI: http://%s/webui\n", listener.Addr())
+			}
 		}
 	}
+	for i, listener := range restricted {
+		fmt.Printf("RPC API server %q listening on %s, limited to %s\n", cfg.API.Listeners[i].Name, listener.Multiaddr(), strings.Join(cfg.API.Listeners[i].AllowedPaths, ", "))
+	}
 
 	// by default, we don't let you load arbitrary ipfs objects through the api,
 	// because this would open up the api to scripting vulnerabilities.
@@ -700,22 +804,26 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 
 	opts := []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("api"),
+		corehttp.SlowLogOption(corenode.SlowOpAPI),
 		corehttp.MetricsOpenCensusCollectionOption(),
 		corehttp.MetricsOpenCensusDefaultPrometheusRegistry(),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsOption(*cctx),
-		corehttp.WebUIOption,
-		gatewayOpt,
 		corehttp.VersionOption(),
 		defaultMux("/debug/vars"),
 		defaultMux("/debug/pprof/"),
 		defaultMux("/debug/stack"),
 		corehttp.MutexFractionOption("/debug/pprof-mutex/"),
 		corehttp.BlockProfileRateOption("/debug/pprof-block/"),
+		corehttp.TestClockOption("/debug/clock"),
 		corehttp.MetricsScrapingOption("/debug/metrics/prometheus"),
 		corehttp.LogOption(),
 	}
 
+	if webUI {
+		opts = append(opts, corehttp.WebUIOption, gatewayOpt)
+	}
+
 	if len(cfg.Gateway.RootRedirect) > 0 {
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
@@ -725,9 +833,16 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		return nil, fmt.Errorf("serveHTTPApi: ConstructNode() failed: %s", err)
 	}
 
-	if len(listeners) > 0 {
-		// Only add an api file if the API is running.
-		if err := node.Repo.SetAPIAddr(rewriteMaddrToUseLocalhostIfItsAny(listeners[0].Multiaddr())); err != nil {
+	// Only add an api file if the API is running.
+	var apiFileAddr ma.Multiaddr
+	switch {
+	case len(listeners) > 0:
+		apiFileAddr = listeners[0].Multiaddr()
+	case len(restricted) > 0:
+		apiFileAddr = restricted[0].Multiaddr()
+	}
+	if apiFileAddr != nil {
+		if err := node.Repo.SetAPIAddr(rewriteMaddrToUseLocalhostIfItsAny(apiFileAddr)); err != nil {
 			return nil, fmt.Errorf("serveHTTPApi: SetAPIAddr() failed: %w", err)
 		}
 	}
@@ -741,6 +856,15 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 			errc <- corehttp.Serve(node, manet.NetListener(lis), opts...)
 		}(apiLis)
 	}
+	for i, apiLis := range restricted {
+		l := cfg.API.Listeners[i]
+		lopts := append([]corehttp.ServeOption{corehttp.AllowedPathsOption(l.Name, l.AllowedPaths)}, opts...)
+		wg.Add(1)
+		go func(lis manet.Listener) {
+			defer wg.Done()
+			errc <- corehttp.Serve(node, manet.NetListener(lis), lopts...)
+		}(apiLis)
+	}
 
 	go func() {
 		wg.Wait()
@@ -811,6 +935,9 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		listenerAddrs[string(listener.Multiaddr().Bytes())] = true
 	}
 
+	// the addresses ending with /tls/http are served with HTTPS, see
+	// Gateway.TLS
+	tlsListeners := make(map[manet.Listener]bool)
 	gatewayAddrs := cfg.Addresses.Gateway
 	for _, addr := range gatewayAddrs {
 		gatewayMaddr, err := ma.NewMultiaddr(addr)
@@ -822,22 +949,32 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 			continue
 		}
 
-		gwLis, err := manet.Listen(gatewayMaddr)
+		listenMaddr, isTLS := corenode.SplitGatewayTLSAddress(gatewayMaddr)
+		gwLis, err := manet.Listen(listenMaddr)
 		if err != nil {
-			return nil, fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err)
+			return nil, fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", listenMaddr, err)
 		}
 		listenerAddrs[string(gatewayMaddr.Bytes())] = true
 		listeners = append(listeners, gwLis)
+		tlsListeners[gwLis] = isTLS
 	}
 
 	// we might have listened to /tcp/0 - let's see what we are listing on
 	for _, listener := range listeners {
+		if tlsListeners[listener] {
+			fmt.Printf("Gateway server listening on %s/tls/http\n", listener.Multiaddr())
+			continue
+		}
 		fmt.Printf("Gateway server listening on %s\n", listener.Multiaddr())
 	}
 
 	if cfg.Gateway.ExposeRoutingAPI.WithDefault(config.DefaultExposeRoutingAPI) {
 		for _, listener := range listeners {
-			fmt.Printf("Routing V1 API exposed at http://%s/routing/v1\n", listener.Addr())
+			scheme := "http"
+			if tlsListeners[listener] {
+				scheme = "https"
+			}
+			fmt.Printf("Routing V1 API exposed at %s://%s/routing/v1\n", scheme, listener.Addr())
 		}
 	}
 
@@ -846,11 +983,23 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 
 	opts := []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("gateway"),
+		corehttp.SlowLogOption(corenode.SlowOpGateway),
 		corehttp.HostnameOption(),
+	}
+
+	if cfg.Gateway.Transform.Enabled.WithDefault(config.DefaultGatewayTransformEnabled) {
+		images, err := corehttp.NewImageTransformer(cfg.Gateway.Transform)
+		if err != nil {
+			return nil, fmt.Errorf("serveHTTPGateway: %w", err)
+		}
+		opts = append(opts, corehttp.GatewayTransformOption(images))
+	}
+
+	opts = append(opts,
 		corehttp.GatewayOption("/ipfs", "/ipns"),
 		corehttp.VersionOption(),
 		corehttp.CheckVersionOption(),
-	}
+	)
 
 	if cfg.Experimental.P2pHttpProxy {
 		opts = append(opts, corehttp.P2PProxyOption())
@@ -879,14 +1028,26 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		}
 	}
 
+	gatewayListeners := make([]net.Listener, len(listeners))
+	for i, lis := range listeners {
+		gatewayListeners[i] = manet.NetListener(lis)
+		if !tlsListeners[lis] {
+			continue
+		}
+		if node.GatewayTLS == nil {
+			return nil, fmt.Errorf("serveHTTPGateway: %s/tls/http: Gateway.TLS is not available", lis.Multiaddr())
+		}
+		gatewayListeners[i] = tls.NewListener(gatewayListeners[i], node.GatewayTLS.TLSConfig())
+	}
+
 	errc := make(chan error)
 	var wg sync.WaitGroup
-	for _, lis := range listeners {
+	for i := range gatewayListeners {
 		wg.Add(1)
-		go func(lis manet.Listener) {
+		go func(lis net.Listener) {
 			defer wg.Done()
-			errc <- corehttp.Serve(node, manet.NetListener(lis), opts...)
-		}(lis)
+			errc <- corehttp.Serve(node, lis, opts...)
+		}(gatewayListeners[i])
 	}
 
 	go func() {
@@ -917,6 +1078,7 @@ func serveTrustlessGatewayOverLibp2p(cctx *oldcmds.Context) (<-chan error, error
 
 	opts := []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("libp2p-gateway"),
+		corehttp.SlowLogOption(corenode.SlowOpGateway),
 		corehttp.Libp2pGatewayOption(),
 		corehttp.VersionOption(),
 	}
@@ -948,6 +1110,30 @@ func serveTrustlessGatewayOverLibp2p(cctx *oldcmds.Context) (<-chan error, error
 	return errc, nil
 }
 
+// enterSandbox restarts the daemon in the sandbox of Security.Sandbox, when
+// it is enabled. The daemon initializing its repo with --init is only
+// sandboxed once restarted.
+func enterSandbox(req *cmds.Request, repoPath string) error {
+	if !fsrepo.IsInitialized(repoPath) {
+		return nil
+	}
+	configFileOpt, _ := req.Options[commands.ConfigFileOption].(string)
+	filename, err := config.Filename(repoPath, configFileOpt)
+	if err != nil {
+		return err
+	}
+	// an invalid config is reported once the repo is opened
+	cfg, err := cserial.Load(filename)
+	if err != nil || !cfg.Security.Sandbox.Enabled.WithDefault(config.DefaultSecuritySandboxEnabled) {
+		return nil
+	}
+	if mount, _ := req.Options[mountKwd].(bool); mount {
+		return fmt.Errorf("--mount: %w", sandbox.ErrMount)
+	}
+	// the config is written with a temporary file in its directory
+	return sandbox.Enter(cfg, repoPath, sandbox.Rule{Path: filepath.Dir(filename), Write: true})
+}
+
 // collects options and opens the fuse mountpoint.
 func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 	cfg, err := cctx.GetConfig()
@@ -0,0 +1,55 @@
+package kubo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-ipfs-cmds/cli"
+
+	oldcmds "github.com/ipfs/kubo/commands"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/commands"
+)
+
+// startScheduler runs the Schedule.Tasks of the daemon. Commands are executed
+// in-process against the RPC command tree, as if they were sent to the API.
+func startScheduler(cctx *oldcmds.Context, node *core.IpfsNode) error {
+	cfg, err := cctx.GetConfig()
+	if err != nil {
+		return err
+	}
+	for _, t := range cfg.Schedule.Tasks {
+		if _, err := cli.Parse(cctx.Context(), t.Command, nil, commands.Root); err != nil {
+			return fmt.Errorf("Schedule.Tasks %q: %w", t.Name, err)
+		}
+	}
+
+	return node.Scheduler.Start(func(ctx context.Context, command []string) error {
+		return runScheduledCommand(ctx, cctx, command)
+	})
+}
+
+func runScheduledCommand(ctx context.Context, cctx *oldcmds.Context, command []string) error {
+	req, err := cli.Parse(ctx, command, nil, commands.Root)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	re, err := cli.NewResponseEmitter(io.Discard, &stderr, req)
+	if err != nil {
+		return err
+	}
+	if err := cmds.NewExecutor(commands.Root).Execute(req, re, cctx); err != nil {
+		return err
+	}
+	if re.Status() != 0 {
+		return errors.New(strings.TrimPrefix(strings.TrimSpace(stderr.String()), "Error: "))
+	}
+	return nil
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/ipfs/kubo/core"
 	corecmds "github.com/ipfs/kubo/core/commands"
 	"github.com/ipfs/kubo/core/corehttp"
+	"github.com/ipfs/kubo/core/node"
 	"github.com/ipfs/kubo/plugin/loader"
 	"github.com/ipfs/kubo/repo"
 	"github.com/ipfs/kubo/repo/fsrepo"
@@ -368,6 +369,11 @@ func (twe tracingWrappedExecutor) Execute(req *cmds.Request, re cmds.ResponseEmi
 	ctx, span := tracer.Start(req.Context, "cmds."+strings.Join(req.Path, "."), trace.WithAttributes(attribute.StringSlice("Arguments", req.Arguments)))
 	defer span.End()
 	req.Context = ctx
+	// the commands run by the daemon get it from the request of the RPC, and
+	// the uses of the daemon itself are recorded without command
+	if req.Command != daemonCmd {
+		req.Context = node.WithCommand(ctx, strings.Join(req.Path, " "))
+	}
 
 	err := twe.exec.Execute(req, re, env)
 	if err != nil {
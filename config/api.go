@@ -19,6 +19,27 @@ type RPCAuthScope struct {
 	// AllowedPaths is an explicit list of RPC path prefixes to allow.
 	// By default, none are allowed. ["/api/v0"] exposes all RPCs.
 	AllowedPaths []string
+
+	// Quotas limits the resources that requests made with this secret can
+	// consume. Nil means unlimited.
+	Quotas *RPCAuthQuotas `json:",omitempty"`
+}
+
+// RPCAuthQuotas are per-token resource limits enforced by the RPC API.
+type RPCAuthQuotas struct {
+	// RequestsPerSecond is the sustained rate of RPC requests allowed.
+	RequestsPerSecond *OptionalInteger `json:",omitempty"`
+
+	// MaxConcurrentAdds is the number of 'ipfs add' requests that may run
+	// at the same time.
+	MaxConcurrentAdds *OptionalInteger `json:",omitempty"`
+
+	// MaxPins is the number of recursive pins attributable to the token.
+	MaxPins *OptionalInteger `json:",omitempty"`
+
+	// MaxPinnedBytes is the total size of the DAGs pinned by the token,
+	// e.g. "10GiB".
+	MaxPinnedBytes *OptionalString `json:",omitempty"`
 }
 
 type API struct {
@@ -29,6 +50,24 @@ type API struct {
 	// If the map is empty, then the RPC API is exposed to everyone. Check the
 	// documentation for more details.
 	Authorizations map[string]*RPCAuthScope `json:",omitempty"`
+
+	// Listeners are additional RPC API listeners, each exposing only its
+	// AllowedPaths, e.g. an admin listener on localhost and a user listener
+	// limited to adding and reading content.
+	Listeners []APIListener `json:",omitempty"`
+}
+
+// APIListener is an RPC API listener restricted to a set of paths.
+type APIListener struct {
+	// Name identifies the listener in logs.
+	Name string
+
+	// Address is the multiaddr the listener is bound to.
+	Address string
+
+	// AllowedPaths is an explicit list of path prefixes served by the
+	// listener. ["/api/v0"] exposes all RPCs.
+	AllowedPaths []string
 }
 
 // ConvertAuthSecret converts the given secret in the format "type:value" into an
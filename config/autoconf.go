@@ -0,0 +1,45 @@
+package config
+
+import "time"
+
+const (
+	DefaultAutoConfEnabled         = false
+	DefaultAutoConfRefreshInterval = 24 * time.Hour
+)
+
+// AutoPlaceholder is the value of Bootstrap, Routing.DelegatedRouters and
+// DNS.Resolvers replaced by the network defaults of AutoConf.
+const AutoPlaceholder = "auto"
+
+// AutoConf keeps the network defaults of the node up to date: its bootstrap
+// peers, delegated routers and DNS resolvers are fetched from a JSON document
+// signed by one of the TrustedKeys, and replace the AutoPlaceholder values
+// of the config.
+type AutoConf struct {
+	Enabled Flag `json:",omitempty"`
+	// URL is the HTTP URL of the signed document.
+	URL string `json:",omitempty"`
+	// TrustedKeys are the peer IDs of the keys the document may be signed
+	// with, such as "12D3KooW...". Their public key must be embedded in the
+	// peer ID, as for the Ed25519 keys.
+	TrustedKeys []string `json:",omitempty"`
+	// RefreshInterval is how often the document is fetched.
+	RefreshInterval *OptionalDuration `json:",omitempty"`
+}
+
+// ExpandAuto returns values with AutoPlaceholder replaced by auto.
+func ExpandAuto(values, auto []string) []string {
+	expanded := make([]string, 0, len(values))
+	var done bool
+	for _, v := range values {
+		switch {
+		case v != AutoPlaceholder:
+			expanded = append(expanded, v)
+		case !done:
+			// the placeholder is expanded once
+			expanded = append(expanded, auto...)
+			done = true
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	DefaultBitswapEnabled                      = true
+	DefaultBitswapLibp2pEnabled                = true
+	DefaultBitswapServeStrategy                = "all"
+	DefaultBitswapServeStrategyRefreshInterval = 5 * time.Minute
+	DefaultBitswapPersistWantlist              = false
+	DefaultBitswapPeerMetricsTopN              = 0
+	DefaultBitswapReputationEnabled            = false
+	DefaultBitswapReputationMaxPenalty         = 500 * time.Millisecond
+	DefaultBitswapBlockVerificationEnabled     = true
+	DefaultBitswapBlockVerificationBanDuration = 10 * time.Minute
+
+	DefaultBitswapRemoteBlockstoreMethod                = "GET"
+	DefaultBitswapRemoteBlockstoreTimeout               = 30 * time.Second
+	DefaultBitswapRemoteBlockstoreMaxConcurrentRequests = 16
+)
+
+// Bitswap includes configuration for the Bitswap server and client.
+type Bitswap struct {
+	// Enabled controls whether Bitswap runs at all. When disabled, blocks
+	// missing from the local blockstore fail with an error instead of being
+	// fetched from the network.
+	Enabled Flag `json:",omitempty"`
+	// Libp2pEnabled controls whether Bitswap runs over libp2p. When disabled,
+	// the node provides its blocks over HTTP only: it announces them to the
+	// routing system and serves them on its gateway.
+	Libp2pEnabled Flag `json:",omitempty"`
+	// HTTPAnnounce are the HTTP multiaddrs of the gateway of the node, e.g.
+	// "/dns4/example.com/tcp/443/https", announced with its libp2p addresses.
+	HTTPAnnounce []string `json:",omitempty"`
+	// ServeStrategy limits which locally stored blocks are served to other
+	// peers: "all", "pinned", "mfs" or "pinned+mfs".
+	ServeStrategy *OptionalString `json:",omitempty"`
+	// ServeStrategyRefreshInterval is how often the set of servable blocks is
+	// recomputed when ServeStrategy is not "all".
+	ServeStrategyRefreshInterval *OptionalDuration `json:",omitempty"`
+	// PriorityClasses schedules local block fetches by priority class so
+	// interactive requests preempt background ones.
+	PriorityClasses BitswapPriorityClasses
+	// PersistWantlist saves the outstanding wants to the datastore and
+	// re-issues them after a restart.
+	PersistWantlist Flag `json:",omitempty"`
+	// PeerMetricsTopN is the number of peers, busiest first, for which
+	// per-peer Bitswap traffic metrics are exported. 0 disables them.
+	PeerMetricsTopN *OptionalInteger `json:",omitempty"`
+	// PrivateNetwork runs a second Bitswap instance on a separate libp2p
+	// host in a private network, next to the public one.
+	PrivateNetwork *BitswapPrivateNetwork `json:",omitempty"`
+	// BlockPolicy limits the size and codecs of the blocks received and
+	// served over Bitswap.
+	BlockPolicy *BitswapBlockPolicy `json:",omitempty"`
+	// Reputation scores peers on the blocks they deliver and makes Bitswap
+	// prefer the well scored ones.
+	Reputation *BitswapReputation `json:",omitempty"`
+	// BlockVerification bans the peers sending blocks that fail CID
+	// verification and retries their wants with other peers.
+	BlockVerification *BitswapBlockVerification `json:",omitempty"`
+	// RemoteBlockstore is an HTTP blockstore the blocks missing locally are
+	// read from when Enabled is false.
+	RemoteBlockstore *BitswapRemoteBlockstore `json:",omitempty"`
+}
+
+// BitswapRemoteBlockstore configures the HTTP blockstore read by a node
+// without Bitswap.
+type BitswapRemoteBlockstore struct {
+	// URL is the URL of a block, where "{cid}" is replaced with the CID of
+	// the block as requested and "{cidv1}" with its base32 CIDv1.
+	URL string
+	// Method is the HTTP method of the requests, "GET" or "POST".
+	Method *OptionalString `json:",omitempty"`
+	// Headers are added to the requests, for example for authorization.
+	Headers map[string]string `json:",omitempty"`
+	// Timeout bounds each request.
+	Timeout *OptionalDuration `json:",omitempty"`
+	// MaxConcurrentRequests is the number of requests made at once.
+	MaxConcurrentRequests *OptionalInteger `json:",omitempty"`
+}
+
+// BitswapReputation configures the scoring of peers on their block delivery
+// success rate, latency and garbage rate.
+type BitswapReputation struct {
+	// Enabled turns peer scoring on.
+	Enabled Flag `json:",omitempty"`
+	// MaxPenalty is how long the HAVE responses and provider records of the
+	// worst scored peers are held back, letting better peers go first.
+	MaxPenalty *OptionalDuration `json:",omitempty"`
+}
+
+// BitswapBlockVerification configures the ban of the peers sending blocks
+// that fail CID verification.
+type BitswapBlockVerification struct {
+	// Enabled turns the verification on.
+	Enabled Flag `json:",omitempty"`
+	// BanDuration is how long the blocks and HAVEs of a banned peer are
+	// dropped and the wants sent to it retried with other peers.
+	BanDuration *OptionalDuration `json:",omitempty"`
+}
+
+// BitswapBlockPolicy rejects the Bitswap blocks that are too large or of an
+// unwanted codec. Blocks received against it are dropped and their wants
+// retried with other peers; blocks served against it are answered with
+// DONT_HAVE.
+type BitswapBlockPolicy struct {
+	// MaxBlockSize is the size of the largest block accepted, such as
+	// "1MiB". Unset means no limit besides the libp2p message size.
+	MaxBlockSize *OptionalString `json:",omitempty"`
+	// Codecs are rules for the blocks of a codec, keyed by multicodec name
+	// such as "dag-pb" or "raw".
+	Codecs map[string]BitswapCodecPolicy `json:",omitempty"`
+}
+
+// BitswapCodecPolicy is the acceptance rule of the blocks of a codec.
+type BitswapCodecPolicy struct {
+	// Reject refuses all the blocks of the codec.
+	Reject Flag `json:",omitempty"`
+	// MaxBlockSize overrides BitswapBlockPolicy.MaxBlockSize for the codec.
+	MaxBlockSize *OptionalString `json:",omitempty"`
+}
+
+// BitswapPrivateNetwork configures the Bitswap instance of a private network,
+// whose peers share a swarm key.
+type BitswapPrivateNetwork struct {
+	// SwarmKeyFile is the path of the swarm key of the private network.
+	SwarmKeyFile string
+	// ListenAddrs are the TCP addresses the private host listens on.
+	ListenAddrs []string
+	// Peers are the members of the private network to stay connected to.
+	Peers []peer.AddrInfo `json:",omitempty"`
+	// ServeStrategy is the Bitswap.ServeStrategy of the private network.
+	ServeStrategy *OptionalString `json:",omitempty"`
+}
+
+// BitswapPriorityClasses configures fetch scheduling between the
+// "interactive" (gateway page loads), "normal" and "background" (pinning)
+// classes.
+type BitswapPriorityClasses struct {
+	// MaxConcurrentFetches is the number of block fetches allowed in flight.
+	// Scheduling is disabled when unset or 0.
+	MaxConcurrentFetches *OptionalInteger `json:",omitempty"`
+	// InteractiveReserved is the number of fetch slots only usable by
+	// interactive fetches. Defaults to a quarter of MaxConcurrentFetches.
+	InteractiveReserved *OptionalInteger `json:",omitempty"`
+}
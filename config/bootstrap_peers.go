@@ -26,8 +26,11 @@ var DefaultBootstrapAddresses = []string{
 // ErrInvalidPeerAddr signals an address is not a valid peer address.
 var ErrInvalidPeerAddr = errors.New("invalid peer address")
 
+// BootstrapPeers returns the peers of Bootstrap, with AutoPlaceholder
+// replaced by the DefaultBootstrapAddresses. The daemon replaces it by the
+// bootstrap peers of AutoConf instead, once fetched.
 func (c *Config) BootstrapPeers() ([]peer.AddrInfo, error) {
-	return ParseBootstrapPeers(c.Bootstrap)
+	return ParseBootstrapPeers(ExpandAuto(c.Bootstrap, DefaultBootstrapAddresses))
 }
 
 // DefaultBootstrapPeers returns the (parsed) set of default bootstrap peers.
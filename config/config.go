@@ -33,10 +33,21 @@ type Config struct {
 
 	Provider     Provider
 	Reprovider   Reprovider
+	Bitswap      Bitswap
 	Experimental Experiments
 	Plugins      Plugins
 	Pinning      Pinning
 	Import       Import
+	Schedule     Schedule
+	Probes       Probes
+	Search       Search
+	DagIndex     DagIndex
+	Logging      Logging
+	Resources    Resources
+	Security     Security
+	AutoConf     AutoConf
+
+	ContentEquivalence ContentEquivalence
 
 	Internal Internal // experimental/unstable options
 }
@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+const DefaultContentEquivalenceFetchTimeout = 30 * time.Second
+
+// ContentEquivalence configures where the node looks for alternative CIDs of
+// the same bytes as a block that cannot be fetched, when
+// Experimental.ContentEquivalence is set.
+type ContentEquivalence struct {
+	// URL is the equivalence service queried for a CID, where "{cid}" is
+	// replaced with the CID. It responds with a ContentEquivalent in JSON,
+	// or 404 when it knows none.
+	URL string `json:",omitempty"`
+	// Index maps CIDs to their equivalents locally, and is consulted before
+	// URL.
+	Index map[string]ContentEquivalent `json:",omitempty"`
+	// FetchTimeout is how long a block is waited for before its equivalents
+	// are looked up.
+	FetchTimeout *OptionalDuration `json:",omitempty"`
+}
+
+// ContentEquivalent lists UnixFS files with the same bytes as a CID, and the
+// import settings that chunk them into that CID.
+type ContentEquivalent struct {
+	// Cids are the UnixFS files with the same bytes.
+	Cids []string
+	// Chunker is the chunker of the CID, as in ipfs add --chunker.
+	Chunker *OptionalString `json:",omitempty"`
+	// RawLeaves is whether the leaves of the CID are raw blocks. It defaults
+	// to true for CIDv1 and false for CIDv0, as in ipfs add.
+	RawLeaves Flag `json:",omitempty"`
+	// Trickle is whether the CID uses the trickle layout.
+	Trickle Flag `json:",omitempty"`
+}
@@ -0,0 +1,16 @@
+package config
+
+const DefaultDagIndexEnabled = false
+
+// DagIndex configures the index of the fields of the dag-cbor and dag-json
+// documents imported with ipfs dag put and ipfs dag import, queried by ipfs
+// dag query.
+type DagIndex struct {
+	// Enabled indexes the fields matching Fields of the documents imported.
+	Enabled Flag `json:",omitempty"`
+
+	// Fields are the selectors of the fields indexed: the map keys and list
+	// indexes from the root of the document separated with '/', where '*'
+	// matches any key or index, such as "title" or "authors/*/name".
+	Fields []string `json:",omitempty"`
+}
@@ -1,9 +1,15 @@
 package config
 
+const DefaultMDNSUseForRouting = false
+
 type Discovery struct {
 	MDNS MDNS
 }
 
 type MDNS struct {
 	Enabled bool
+
+	// UseForRouting asks the peers discovered with mDNS whether they have a
+	// block before looking its providers up in the other routers.
+	UseForRouting Flag `json:",omitempty"`
 }
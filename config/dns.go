@@ -1,5 +1,12 @@
 package config
 
+import "time"
+
+const (
+	DefaultDNSCacheSize = 1024
+	DefaultDNSCacheTTL  = time.Minute
+)
+
 // DNS specifies DNS resolution rules using custom resolvers.
 type DNS struct {
 	// Resolvers is a map of FQDNs to URLs for custom DNS resolution.
@@ -14,4 +21,10 @@ type DNS struct {
 	Resolvers map[string]string
 	// MaxCacheTTL is the maximum duration DNS entries are valid in the cache.
 	MaxCacheTTL *OptionalDuration `json:",omitempty"`
+	// CacheSize is the number of lookups cached by the daemon, whatever the
+	// resolver, so that they can be purged with 'ipfs dns purge'. 0
+	// disables the cache.
+	CacheSize *OptionalInteger `json:",omitempty"`
+	// CacheTTL is how long a lookup is cached, at most MaxCacheTTL.
+	CacheTTL *OptionalDuration `json:",omitempty"`
 }
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stability levels of the features of Experimental.
+const (
+	FeatureExperimental = "experimental"
+	// FeatureRemoved features are no longer used, and can only be disabled.
+	FeatureRemoved = "removed"
+)
+
+// Feature describes a flag of Experimental, as listed by ipfs features ls.
+type Feature struct {
+	// Name is the key of the flag under Experimental.
+	Name        string
+	Description string
+	Stability   string
+	// Runtime features take effect when toggled while the daemon runs, the
+	// others on its next start.
+	Runtime bool
+	// Requires are the features that must be enabled along with this one.
+	Requires []string
+
+	enabled func(*Experiments) bool
+}
+
+// Enabled tells whether the feature is enabled in e.
+func (f Feature) Enabled(e *Experiments) bool {
+	return f.enabled(e)
+}
+
+// Features are the flags of Experimental. The other options of Experimental,
+// such as OptimisticProvideJobsPoolSize, tune one of them.
+var Features = []Feature{
+	{
+		Name:        "FilestoreEnabled",
+		Description: "Adds files with ipfs add --nocopy without copying them in the repo.",
+		Stability:   FeatureExperimental,
+		enabled:     func(e *Experiments) bool { return e.FilestoreEnabled },
+	},
+	{
+		Name:        "UrlstoreEnabled",
+		Description: "Adds files from URLs with ipfs urlstore add without copying them in the repo.",
+		Stability:   FeatureExperimental,
+		enabled:     func(e *Experiments) bool { return e.UrlstoreEnabled },
+	},
+	{
+		Name:        "Libp2pStreamMounting",
+		Description: "Forwards libp2p streams to local sockets with ipfs p2p.",
+		Stability:   FeatureExperimental,
+		Runtime:     true,
+		enabled:     func(e *Experiments) bool { return e.Libp2pStreamMounting },
+	},
+	{
+		Name:        "P2pHttpProxy",
+		Description: "Proxies HTTP requests to peers over libp2p streams on the gateway at /p2p/.",
+		Stability:   FeatureExperimental,
+		Requires:    []string{"Libp2pStreamMounting"},
+		enabled:     func(e *Experiments) bool { return e.P2pHttpProxy },
+	},
+	{
+		Name:        "StrategicProviding",
+		Description: "Disables the provider system, for nodes announcing their content by other means.",
+		Stability:   FeatureExperimental,
+		enabled:     func(e *Experiments) bool { return e.StrategicProviding },
+	},
+	{
+		Name:        "OptimisticProvide",
+		Description: "Stores provider records on the DHT without waiting for the closest peers to be found.",
+		Stability:   FeatureExperimental,
+		enabled:     func(e *Experiments) bool { return e.OptimisticProvide },
+	},
+	{
+		Name:        "GatewayOverLibp2p",
+		Description: "Serves the trustless gateway over libp2p.",
+		Stability:   FeatureExperimental,
+		enabled:     func(e *Experiments) bool { return e.GatewayOverLibp2p },
+	},
+	{
+		Name:        "ContentEquivalence",
+		Description: "Rebuilds unavailable files from files with the same bytes, see ContentEquivalence.",
+		Stability:   FeatureExperimental,
+		enabled:     func(e *Experiments) bool { return e.ContentEquivalence },
+	},
+	{
+		Name:        "ShardingEnabled",
+		Description: "Replaced by automatic sharding, see Internal.UnixFSShardingSizeThreshold.",
+		Stability:   FeatureRemoved,
+		enabled:     func(e *Experiments) bool { return e.ShardingEnabled },
+	},
+}
+
+// FeatureByName returns the feature of Experimental named name, ignoring
+// case.
+func FeatureByName(name string) (Feature, bool) {
+	for _, f := range Features {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return Feature{}, false
+}
+
+// CheckFeatureToggle returns an error when enabling or disabling the feature
+// would leave the features of e with unmet requirements.
+func CheckFeatureToggle(e *Experiments, f Feature, enable bool) error {
+	if !enable {
+		for _, other := range Features {
+			if other.Enabled(e) && other.Name != f.Name {
+				for _, r := range other.Requires {
+					if r == f.Name {
+						return fmt.Errorf("Experimental.%s requires Experimental.%s, disable it first", other.Name, f.Name)
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if f.Stability == FeatureRemoved {
+		return fmt.Errorf("Experimental.%s was removed: %s", f.Name, f.Description)
+	}
+	for _, r := range f.Requires {
+		req, ok := FeatureByName(r)
+		if ok && !req.Enabled(e) {
+			return fmt.Errorf("Experimental.%s requires Experimental.%s, enable it first", f.Name, r)
+		}
+	}
+	return nil
+}
@@ -1,10 +1,54 @@
 package config
 
+import "time"
+
 const (
 	DefaultInlineDNSLink         = false
 	DefaultDeserializedResponses = true
 	DefaultDisableHTMLErrors     = false
 	DefaultExposeRoutingAPI      = false
+	DefaultIPNSPublishing        = false
+	DefaultTrustlessResponses    = true
+	DefaultIPNSResolution        = true
+	DefaultDirectoryListing      = true
+
+	DefaultGatewayAuthorizationCacheTTL = time.Minute
+	DefaultGatewayAuthorizationTimeout  = 5 * time.Second
+
+	DefaultGatewayAccessLogEnabled = false
+	DefaultGatewayAccessLogFile    = "gateway-access.log"
+
+	DefaultGatewayResponseCacheEnabled = false
+	DefaultGatewayResponseCacheMaxSize = "64MiB"
+	DefaultGatewayResponseCacheTTL     = time.Minute
+
+	DefaultGatewayDirectoryIndexCacheEnabled    = false
+	DefaultGatewayDirectoryIndexCacheMaxSize    = "256MiB"
+	DefaultGatewayDirectoryIndexCacheMinEntries = 100
+
+	DefaultGatewayRangeCoalescingEnabled     = false
+	DefaultGatewayRangeCoalescingMaxPlanSize = "64MiB"
+
+	DefaultGatewayShadowPercent       = 10
+	DefaultGatewayShadowTimeout       = 30 * time.Second
+	DefaultGatewayShadowMaxConcurrent = 64
+
+	DefaultGatewayNotificationsSlowThreshold = 10 * time.Second
+	DefaultGatewayNotificationsTimeout       = 10 * time.Second
+	DefaultGatewayNotificationsMaxConcurrent = 16
+
+	DefaultGatewayTransformEnabled       = false
+	DefaultGatewayTransformMaxInputSize  = "16MiB"
+	DefaultGatewayTransformMaxPixels     = 16_000_000
+	DefaultGatewayTransformMaxDimension  = 4096
+	DefaultGatewayTransformMaxConcurrent = 4
+
+	DefaultGatewayReceiptsEnabled          = false
+	DefaultGatewayReceiptsMaxTrailerBlocks = 64
+
+	DefaultGatewayACMEEnabled   = false
+	DefaultGatewayACMECA        = "https://acme-v02.api.letsencrypt.org/directory"
+	DefaultGatewayACMEChallenge = "tls-alpn-01"
 )
 
 type GatewaySpec struct {
@@ -35,6 +79,44 @@ type GatewaySpec struct {
 	// responses. Disabling this option enables a Trustless Gateway, as per:
 	// https://specs.ipfs.tech/http-gateways/trustless-gateway/.
 	DeserializedResponses Flag
+
+	// TrustlessResponses configures this gateway to respond with raw blocks,
+	// CARs and IPNS records. Disabling it, while keeping
+	// DeserializedResponses, serves the content as websites only.
+	TrustlessResponses Flag
+
+	// IPNSResolution configures this gateway to resolve the /ipns/ paths and
+	// subdomains. The DNSLink websites of the hostname are governed by
+	// NoDNSLink.
+	IPNSResolution Flag
+
+	// DirectoryListing configures this gateway to list the directories
+	// without an index.html.
+	DirectoryListing Flag
+
+	// Authorization configures an HTTP endpoint deciding whether each
+	// request to this gateway is served, denied or redirected.
+	Authorization *GatewayAuthorization `json:",omitempty"`
+
+	// Denylists are the paths of denylist files, relative to the repo, whose
+	// rules only apply to the requests for this hostname, in addition to the
+	// ones of the denylists directories. They are reloaded when they change.
+	Denylists []string `json:",omitempty"`
+}
+
+// GatewayAuthorization describes each request of a public gateway to an HTTP
+// endpoint, which allows it with a 2xx response, redirects it with a 3xx one,
+// or denies it with a 4xx one, such as 402 Payment Required.
+type GatewayAuthorization struct {
+	// Endpoint is the URL the requests are POSTed to.
+	Endpoint string
+
+	// CacheTTL is how long a decision is reused for identical requests with
+	// the same credentials.
+	CacheTTL *OptionalDuration `json:",omitempty"`
+
+	// Timeout bounds the requests to the endpoint.
+	Timeout *OptionalDuration `json:",omitempty"`
 }
 
 // Gateway contains options for the HTTP gateway server.
@@ -73,4 +155,248 @@ type Gateway struct {
 	// ExposeRoutingAPI configures the gateway port to expose
 	// routing system as HTTP API at /routing/v1 (https://specs.ipfs.tech/routing/http-routing-v1/).
 	ExposeRoutingAPI Flag
+
+	// IPNSPublishing accepts the signed IPNS records PUT to /ipns/{name},
+	// as application/vnd.ipfs.ipns-record, and puts them to the routing
+	// system, like 'ipfs routing put'.
+	IPNSPublishing Flag
+
+	// RateLimit bounds the requests of each client, and the requests for
+	// each CID, answering the requests over the limits with 429 Too Many
+	// Requests.
+	RateLimit *GatewayRateLimit `json:",omitempty"`
+
+	// AccessLog writes a JSON record of each request to a file.
+	AccessLog GatewayAccessLog
+
+	// ResponseCache keeps the responses of the gateway in memory, to serve
+	// the repeated requests without resolving their paths again.
+	ResponseCache GatewayResponseCache
+
+	// DirectoryIndexCache keeps the entries of the large directories listed
+	// by the gateway in the datastore, so they are not enumerated again,
+	// including after a restart.
+	DirectoryIndexCache GatewayDirectoryIndexCache
+
+	// RangeCoalescing shares the block fetches of the concurrent requests,
+	// and fetches the blocks of the byte ranges of large files ahead.
+	RangeCoalescing GatewayRangeCoalescing
+
+	// Shadow mirrors a share of the requests to another gateway and compares
+	// its responses, to validate a migration before switching to it.
+	Shadow *GatewayShadow `json:",omitempty"`
+
+	// Notifications POSTs the events of the gateway, such as the content not
+	// found and the slow retrievals, to a webhook.
+	Notifications *GatewayNotifications `json:",omitempty"`
+
+	// Transform resizes and converts the images served by the gateway, as
+	// requested by the query parameters, such as ?w=200&fm=png.
+	Transform GatewayTransform
+
+	// Receipts reports the blocks read to serve the responses of the
+	// gateway, in a trailer or in a receipt signed by the node, to audit the
+	// gateways behind a CDN.
+	Receipts GatewayReceipts
+
+	// TLS configures the certificates of the gateway addresses ending with
+	// /tls/http, on which the gateway serves HTTPS itself.
+	TLS GatewayTLS
+}
+
+// GatewayRangeCoalescing configures the coalescing of the block fetches of
+// the gateway: the requests fetching the same block, such as the clients
+// requesting overlapping ranges of a large file, wait for a single fetch.
+type GatewayRangeCoalescing struct {
+	Enabled Flag `json:",omitempty"`
+	// MaxPlanSize bounds the bytes of a range request whose blocks are
+	// fetched ahead of the reads, such as "64MiB".
+	MaxPlanSize *OptionalString `json:",omitempty"`
+}
+
+// GatewayShadow configures the mirroring of the GET and HEAD requests of the
+// gateway to a secondary gateway. The responses of the secondary gateway are
+// compared to the ones of the node in the background, and discarded.
+type GatewayShadow struct {
+	// URL is the base URL of the secondary gateway, such as
+	// "http://127.0.0.1:8081".
+	URL string
+	// Percent is the percentage of the requests mirrored.
+	Percent *OptionalInteger `json:",omitempty"`
+	// Timeout bounds the requests to the secondary gateway.
+	Timeout *OptionalDuration `json:",omitempty"`
+	// MaxConcurrent is the number of mirrored requests in flight, over which
+	// the requests are not mirrored.
+	MaxConcurrent *OptionalInteger `json:",omitempty"`
+}
+
+// GatewayNotifications configures the events of the gateway POSTed as JSON
+// to a webhook, to track the health of the content served.
+type GatewayNotifications struct {
+	// URL is the webhook, such as "https://alerts.example.net/ipfs".
+	URL string
+	// Headers are set on the requests to the webhook, such as its
+	// Authorization.
+	Headers map[string]string `json:",omitempty"`
+	// Events are the events sent, among "not-found", "retrieval-failed",
+	// "slow" and "blocked". All are sent when empty.
+	Events []string `json:",omitempty"`
+	// SlowThreshold is the time to the response headers over which a
+	// request is slow.
+	SlowThreshold *OptionalDuration `json:",omitempty"`
+	// Timeout bounds the requests to the webhook.
+	Timeout *OptionalDuration `json:",omitempty"`
+	// MaxConcurrent is the number of requests to the webhook in flight, over
+	// which the events are dropped.
+	MaxConcurrent *OptionalInteger `json:",omitempty"`
+}
+
+// GatewayTransform configures the transformation of the deserialized
+// responses of the gateway. The transformations are bounded, as they decode
+// content chosen by the clients.
+type GatewayTransform struct {
+	Enabled Flag `json:",omitempty"`
+	// MaxInputSize bounds the content transformed, such as "16MiB".
+	MaxInputSize *OptionalString `json:",omitempty"`
+	// MaxPixels bounds the width times the height of the images decoded,
+	// checked from their header before they are decoded.
+	MaxPixels *OptionalInteger `json:",omitempty"`
+	// MaxDimension bounds the width and the height requested.
+	MaxDimension *OptionalInteger `json:",omitempty"`
+	// MaxConcurrent is the number of transformations in progress, over which
+	// the requests are answered with 503 Service Unavailable.
+	MaxConcurrent *OptionalInteger `json:",omitempty"`
+}
+
+// GatewayReceipts configures the receipts of the gateway, the CIDs of the
+// blocks read to serve a response and the roots they verify against.
+type GatewayReceipts struct {
+	Enabled Flag `json:",omitempty"`
+	// MaxTrailerBlocks bounds the CIDs listed in the X-Ipfs-Receipt trailer,
+	// over which it only has their number and digest.
+	MaxTrailerBlocks *OptionalInteger `json:",omitempty"`
+}
+
+// GatewayTLS configures where the certificates of the HTTPS gateway come
+// from: either CertFile and KeyFile, or ACME.
+type GatewayTLS struct {
+	// CertFile and KeyFile are the paths of a PEM certificate chain and of
+	// its private key, relative to the repo. They are reloaded when they
+	// change, such as when they are renewed by another tool.
+	CertFile *OptionalString `json:",omitempty"`
+	KeyFile  *OptionalString `json:",omitempty"`
+
+	// ACME obtains and renews the certificates from an ACME certificate
+	// authority, such as Let's Encrypt.
+	ACME GatewayACME
+}
+
+// GatewayACME configures the certificates obtained with ACME. The account
+// and the certificates are kept in the gateway-tls directory of the repo.
+type GatewayACME struct {
+	Enabled Flag `json:",omitempty"`
+	// CA is the directory URL of the ACME certificate authority.
+	CA *OptionalString `json:",omitempty"`
+	// Email is the contact of the ACME account, warned about the
+	// certificates which failed to renew.
+	Email *OptionalString `json:",omitempty"`
+	// Domains are the names certified. They default to the hostnames of
+	// PublicGateways without a wildcard, with *.ipfs.<hostname> and
+	// *.ipns.<hostname> for the subdomain gateways.
+	Domains []string `json:",omitempty"`
+	// Challenge is how the names are validated, either "tls-alpn-01" on the
+	// port 443 of the gateway, or "dns-01", required by the wildcard names.
+	Challenge *OptionalString `json:",omitempty"`
+	// DNS publishes the TXT records of the dns-01 challenges.
+	DNS GatewayACMEDNS
+}
+
+// GatewayACMEDNS configures the RFC 2136 dynamic updates of the TXT records
+// of the dns-01 challenges, accepted by most authoritative DNS servers.
+type GatewayACMEDNS struct {
+	// Server is the host:port of the DNS server accepting the updates.
+	Server string `json:",omitempty"`
+	// Zone is the DNS zone updated, such as "example.com.".
+	Zone string `json:",omitempty"`
+	// TSIGKey is the name of the TSIG key signing the updates, and
+	// TSIGSecretFile the path of the file of its base64 secret, relative to
+	// the repo. TSIGAlgorithm defaults to hmac-sha256.
+	TSIGKey        string          `json:",omitempty"`
+	TSIGSecretFile string          `json:",omitempty"`
+	TSIGAlgorithm  *OptionalString `json:",omitempty"`
+}
+
+// GatewayDirectoryIndexCache configures the cache of the entries of the
+// directories listed by the gateway, keyed by the CID of the directory.
+type GatewayDirectoryIndexCache struct {
+	Enabled Flag `json:",omitempty"`
+	// MaxSize is the space used by the cached directories in the datastore,
+	// such as "256MiB". The least recently listed directories are evicted
+	// first.
+	MaxSize *OptionalString `json:",omitempty"`
+	// MinEntries is the number of entries from which a directory is cached.
+	// The smaller directories are cheap to enumerate again.
+	MinEntries *OptionalInteger `json:",omitempty"`
+}
+
+// GatewayResponseCache configures the cache of the responses rendered by the
+// gateway, such as directory listings, CAR exports and the content of IPNS
+// names.
+type GatewayResponseCache struct {
+	Enabled Flag `json:",omitempty"`
+	// MaxSize is the memory used by the cached responses, such as "64MiB".
+	// The least recently used responses are evicted first.
+	MaxSize *OptionalString `json:",omitempty"`
+	// TTL is how long a response is served from the cache, which bounds how
+	// stale the responses for IPNS names and DNSLinks may be.
+	TTL *OptionalDuration `json:",omitempty"`
+}
+
+// GatewayAccessLog configures the access log of the gateway, written apart
+// from the logs of the daemon, one JSON record per line. The file is rotated
+// like Logging.File, with the same defaults.
+type GatewayAccessLog struct {
+	Enabled Flag `json:",omitempty"`
+	// File is the path of the access log, relative to the repo.
+	File *OptionalString `json:",omitempty"`
+	// MaxFileSize is the size at which the file is rotated, such as "100MiB".
+	MaxFileSize *OptionalString `json:",omitempty"`
+	// RotateInterval is how long the file is written to before it is
+	// rotated. Zero only rotates the file by size.
+	RotateInterval *OptionalDuration `json:",omitempty"`
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups *OptionalInteger `json:",omitempty"`
+}
+
+// GatewayRateLimit configures the rate limits of the gateway.
+type GatewayRateLimit struct {
+	// PerIP limits the requests of each client IP address.
+	PerIP *GatewayRateLimitPolicy `json:",omitempty"`
+
+	// PerCID limits the requests for each CID or IPNS name, whichever the
+	// client.
+	PerCID *GatewayRateLimitPolicy `json:",omitempty"`
+
+	// Exempt lists the IP addresses and CIDR networks, such as the ones of a
+	// monitoring service, which are not limited.
+	Exempt []string `json:",omitempty"`
+}
+
+// GatewayRateLimitPolicy is the set of limits of a client or a CID. Unset or
+// zero limits are not enforced.
+type GatewayRateLimitPolicy struct {
+	// RequestsPerSecond is the sustained rate of requests allowed.
+	RequestsPerSecond *OptionalInteger `json:",omitempty"`
+
+	// Burst is the number of requests allowed at once above
+	// RequestsPerSecond. It defaults to RequestsPerSecond.
+	Burst *OptionalInteger `json:",omitempty"`
+
+	// MaxConcurrentRanges is the number of requests with a Range header
+	// that may be served at the same time.
+	MaxConcurrentRanges *OptionalInteger `json:",omitempty"`
+
+	// BytesPerSecond is the sustained rate of response bytes allowed, e.g.
+	// "10MiB".
+	BytesPerSecond *OptionalString `json:",omitempty"`
 }
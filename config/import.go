@@ -5,6 +5,8 @@ const (
 	DefaultUnixFSRawLeaves = false
 	DefaultUnixFSChunker   = "size-262144"
 	DefaultHashFunction    = "sha2-256"
+	DefaultImportSymlinks  = "preserve"
+	DefaultGetSymlinks     = "restore"
 )
 
 // Import configures the default options for ingesting data. This affects commands
@@ -14,4 +16,10 @@ type Import struct {
 	UnixFSRawLeaves Flag
 	UnixFSChunker   OptionalString
 	HashFunction    OptionalString
+	// Symlinks is the default symlink policy of 'ipfs add': "preserve",
+	// "follow" or "forbid".
+	Symlinks OptionalString
+	// GetSymlinks is the default symlink policy of 'ipfs get': "restore" or
+	// "skip".
+	GetSymlinks OptionalString
 }
@@ -14,4 +14,53 @@ type InternalBitswap struct {
 	EngineTaskWorkerCount       OptionalInteger
 	MaxOutstandingBytesPerPeer  OptionalInteger
 	ProviderSearchDelay         OptionalDuration
+	ClientTimeouts              *InternalBitswapClientTimeouts `json:",omitempty"`
+	ProviderQuery               *InternalBitswapProviderQuery  `json:",omitempty"`
+	ServerFairness              *InternalBitswapServerFairness `json:",omitempty"`
+	FilestoreReads              *InternalBitswapFilestoreReads `json:",omitempty"`
+}
+
+// InternalBitswapFilestoreReads limits the reads of the files and URLs
+// referenced by the filestore and the urlstore, when Bitswap serves them.
+type InternalBitswapFilestoreReads struct {
+	// Concurrency is the number of files and URLs read at once.
+	Concurrency *OptionalInteger `json:",omitempty"`
+	// ReadAhead is the number of bytes read at once from a file or a URL,
+	// to serve the following blocks from memory, or "0" to only read the
+	// requested block.
+	ReadAhead *OptionalString `json:",omitempty"`
+}
+
+// InternalBitswapServerFairness groups the peers served by Bitswap by network,
+// and applies MaxOutstandingBytesPerPeer to each group.
+type InternalBitswapServerFairness struct {
+	// Mode is "off", "ip-prefix" to group peers by IP prefix, or "asn" to
+	// group IPv6 peers by autonomous system.
+	Mode *OptionalString `json:",omitempty"`
+	// IPv4PrefixLength and IPv6PrefixLength are the lengths of the IP
+	// prefixes grouping peers.
+	IPv4PrefixLength *OptionalInteger `json:",omitempty"`
+	IPv6PrefixLength *OptionalInteger `json:",omitempty"`
+}
+
+// InternalBitswapProviderQuery limits the provider queries the Bitswap client
+// makes with content routing.
+type InternalBitswapProviderQuery struct {
+	// MaxProviders is the number of providers a query looks for.
+	MaxProviders *OptionalInteger `json:",omitempty"`
+	// MaxConcurrentFinds is the number of queries running at once.
+	MaxConcurrentFinds *OptionalInteger `json:",omitempty"`
+	// Timeout bounds the duration of a query.
+	Timeout *OptionalDuration `json:",omitempty"`
+}
+
+// InternalBitswapClientTimeouts tunes how long the Bitswap client waits on
+// peers before looking elsewhere.
+type InternalBitswapClientTimeouts struct {
+	// RebroadcastInterval is the period at which a random want is
+	// rebroadcast and searched for with content routing.
+	RebroadcastInterval *OptionalDuration `json:",omitempty"`
+	// SimulateDontHaves treats peers that do not answer a want in time as
+	// if they had replied DONT_HAVE.
+	SimulateDontHaves Flag `json:",omitempty"`
 }
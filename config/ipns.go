@@ -6,7 +6,11 @@ import (
 )
 
 const (
-	DefaultIpnsMaxCacheTTL = time.Duration(math.MaxInt64)
+	DefaultIpnsMaxCacheTTL         = time.Duration(math.MaxInt64)
+	DefaultIpnsPersistResolveCache = false
+
+	DefaultIpnsRepublishThirdPartyPeriod = 4 * time.Hour
+	DefaultIpnsPublishQuorum             = 0
 )
 
 type Ipns struct {
@@ -18,6 +22,22 @@ type Ipns struct {
 	// MaxCacheTTL is the maximum duration IPNS entries are valid in the cache.
 	MaxCacheTTL *OptionalDuration `json:",omitempty"`
 
+	// PersistResolveCache saves the resolution cache to the datastore, so
+	// that it survives restarts.
+	PersistResolveCache Flag `json:",omitempty"`
+
+	// RepublishThirdParty are the IPNS names, not owned by the node, whose
+	// latest signed records are kept and republished to the routing systems
+	// every RepublishThirdPartyPeriod, until they expire.
+	RepublishThirdParty       []string          `json:",omitempty"`
+	RepublishThirdPartyPeriod *OptionalDuration `json:",omitempty"`
+
+	// PublishQuorum is the number of routing targets, such as the DHT, pubsub
+	// and the delegated publishers, that must accept a record published with
+	// ipfs name publish for it to succeed. 0 keeps the default behavior,
+	// where only the DHT must accept it.
+	PublishQuorum *OptionalInteger `json:",omitempty"`
+
 	// Enable namesys pubsub (--enable-namesys-pubsub)
 	UsePubsub Flag `json:",omitempty"`
 }
@@ -0,0 +1,71 @@
+package config
+
+import "time"
+
+const (
+	DefaultLoggingMaxFileSize    = "100MiB"
+	DefaultLoggingRotateInterval = 24 * time.Hour
+	DefaultLoggingMaxBackups     = 7
+
+	DefaultLoggingRateLimitEnabled  = false
+	DefaultLoggingRateLimitInterval = time.Minute
+	DefaultLoggingRateLimitBurst    = 5
+
+	DefaultLoggingSlowLogEnabled             = false
+	DefaultLoggingSlowLogAPIThreshold        = 5 * time.Second
+	DefaultLoggingSlowLogGatewayThreshold    = 5 * time.Second
+	DefaultLoggingSlowLogBlockFetchThreshold = 10 * time.Second
+	DefaultLoggingSlowLogRoutingThreshold    = 10 * time.Second
+	DefaultLoggingSlowLogMaxEntries          = 1000
+	DefaultLoggingSlowLogStackSampleRate     = 10
+)
+
+// Logging configures the log output of the daemon, in addition to the
+// GOLOG_* environment variables.
+type Logging struct {
+	// File is the path of a file the logs are written to, relative to the
+	// repo. The logs are only written to stderr when it is unset.
+	File *OptionalString `json:",omitempty"`
+	// MaxFileSize is the size at which the file is rotated, such as "100MiB".
+	MaxFileSize *OptionalString `json:",omitempty"`
+	// RotateInterval is how long the file is written to before it is
+	// rotated. Zero only rotates the file by size.
+	RotateInterval *OptionalDuration `json:",omitempty"`
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups *OptionalInteger `json:",omitempty"`
+
+	RateLimit LoggingRateLimit
+	SlowLog   LoggingSlowLog
+}
+
+// LoggingRateLimit suppresses the repeated warnings and errors of each
+// subsystem. Past Burst identical entries in an Interval, the entry is
+// dropped until the end of the Interval, when it is logged once more with the
+// number of times it was repeated.
+type LoggingRateLimit struct {
+	Enabled  Flag              `json:",omitempty"`
+	Interval *OptionalDuration `json:",omitempty"`
+	Burst    *OptionalInteger  `json:",omitempty"`
+}
+
+// LoggingSlowLog records the operations of the daemon that take longer than
+// their threshold, reported by 'ipfs diag slowlog'. A threshold of zero
+// disables the recording of its operations.
+type LoggingSlowLog struct {
+	Enabled Flag `json:",omitempty"`
+	// APIThreshold and GatewayThreshold apply to the requests made to the
+	// RPC API and to the gateway.
+	APIThreshold     *OptionalDuration `json:",omitempty"`
+	GatewayThreshold *OptionalDuration `json:",omitempty"`
+	// BlockFetchThreshold applies to the blocks requested from the network,
+	// and RoutingThreshold to the routing queries and provides.
+	BlockFetchThreshold *OptionalDuration `json:",omitempty"`
+	RoutingThreshold    *OptionalDuration `json:",omitempty"`
+	// MaxEntries is the number of slow operations kept, the oldest are
+	// forgotten first.
+	MaxEntries *OptionalInteger `json:",omitempty"`
+	// StackSampleRate captures the stacks of the goroutines of one in
+	// StackSampleRate slow operations, when they reach their threshold.
+	// Zero never captures them.
+	StackSampleRate *OptionalInteger `json:",omitempty"`
+}
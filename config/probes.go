@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+const (
+	DefaultRetrievalProbeEnabled  = false
+	DefaultRetrievalProbeInterval = 10 * time.Minute
+	DefaultRetrievalProbeTimeout  = 5 * time.Minute
+)
+
+// Probes configures the checks the daemon runs against its own network
+// reachability.
+type Probes struct {
+	Retrieval RetrievalProbe
+}
+
+// RetrievalProbe periodically publishes a random block and measures how long
+// it takes until the block can be retrieved from outside this node.
+type RetrievalProbe struct {
+	Enabled Flag `json:",omitempty"`
+	// Gateways are the trustless gateways the block is retrieved from, such
+	// as a public gateway or the gateway of a second node run as a probe.
+	Gateways []string `json:",omitempty"`
+	// Interval is the time between two probes.
+	Interval *OptionalDuration `json:",omitempty"`
+	// Timeout is how long a gateway may take to return the block before the
+	// probe fails.
+	Timeout *OptionalDuration `json:",omitempty"`
+}
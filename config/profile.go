@@ -173,19 +173,19 @@ This profile may only be applied when first initializing the node.`,
 		Description: `Reduces daemon overhead on the system. May affect node
 functionality - performance of content discovery and data
 fetching may be degraded.
+`,
+		Transform: lowPower,
+	},
+	"lowpower-constrained": {
+		Description: `Applies the lowpower profile, and sizes the daemon for
+devices with 1GB of RAM or less, such as routers and NAS boxes, by setting
+Resources.MaxMemoryMB.
 `,
 		Transform: func(c *Config) error {
-			c.Routing.Type = NewOptionalString("autoclient")
-			c.AutoNAT.ServiceMode = AutoNATServiceDisabled
-			c.Reprovider.Interval = NewOptionalDuration(0)
-
-			lowWater := int64(20)
-			highWater := int64(40)
-			gracePeriod := time.Minute
-			c.Swarm.ConnMgr.Type = NewOptionalString("basic")
-			c.Swarm.ConnMgr.LowWater = &OptionalInteger{value: &lowWater}
-			c.Swarm.ConnMgr.HighWater = &OptionalInteger{value: &highWater}
-			c.Swarm.ConnMgr.GracePeriod = &OptionalDuration{&gracePeriod}
+			if err := lowPower(c); err != nil {
+				return err
+			}
+			c.Resources.MaxMemoryMB = NewOptionalInteger(512)
 			return nil
 		},
 	},
@@ -274,3 +274,18 @@ func mapKeys(m map[string]struct{}) []string {
 	}
 	return out
 }
+
+func lowPower(c *Config) error {
+	c.Routing.Type = NewOptionalString("autoclient")
+	c.AutoNAT.ServiceMode = AutoNATServiceDisabled
+	c.Reprovider.Interval = NewOptionalDuration(0)
+
+	lowWater := int64(20)
+	highWater := int64(40)
+	gracePeriod := time.Minute
+	c.Swarm.ConnMgr.Type = NewOptionalString("basic")
+	c.Swarm.ConnMgr.LowWater = &OptionalInteger{value: &lowWater}
+	c.Swarm.ConnMgr.HighWater = &OptionalInteger{value: &highWater}
+	c.Swarm.ConnMgr.GracePeriod = &OptionalDuration{&gracePeriod}
+	return nil
+}
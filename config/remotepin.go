@@ -5,8 +5,13 @@ var (
 	PinningConcealSelector = []string{"Pinning", "RemoteServices", "*", "API", "Key"}
 )
 
+const DefaultPinningResumeInterrupted = false
+
 type Pinning struct {
 	RemoteServices map[string]RemotePinningService
+	// ResumeInterrupted saves the pins being added and resumes those
+	// interrupted by a restart of the daemon.
+	ResumeInterrupted Flag `json:",omitempty"`
 }
 
 type RemotePinningService struct {
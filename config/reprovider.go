@@ -5,9 +5,46 @@ import "time"
 const (
 	DefaultReproviderInterval = time.Hour * 22 // https://github.com/ipfs/kubo/pull/9326
 	DefaultReproviderStrategy = "all"
+
+	DefaultReproviderReactiveTTL = 7 * 24 * time.Hour
+
+	DefaultReproviderFilterBase    = "all"
+	DefaultReproviderFilterTimeout = 10 * time.Second
 )
 
 type Reprovider struct {
 	Interval *OptionalDuration `json:",omitempty"` // Time period to reprovide locally stored objects to the network
 	Strategy *OptionalString   `json:",omitempty"` // Which keys to announce
+	// Filter selects the keys announced by the "custom" strategy.
+	Filter *ReproviderFilter `json:",omitempty"`
+	// ReactiveTTL is how long the "reactive" strategy keeps reproviding a
+	// key after the last request for it.
+	ReactiveTTL *OptionalDuration `json:",omitempty"`
+}
+
+// ReproviderFilter announces the keys of a base strategy that match every
+// criterion set.
+type ReproviderFilter struct {
+	// Base is the strategy whose keys are filtered: "all", "pinned",
+	// "roots", "roots+entry-points" or "flat".
+	Base *OptionalString `json:",omitempty"`
+	// Codecs are the multicodec names, such as "dag-cbor", of the keys
+	// announced.
+	Codecs []string `json:",omitempty"`
+	// Prefixes are glob patterns, such as "bafyrei*", matched against the
+	// CIDs announced.
+	Prefixes []string `json:",omitempty"`
+	// MinSize and MaxSize bound the size of the blocks announced, such as
+	// "1MiB".
+	MinSize *OptionalString `json:",omitempty"`
+	MaxSize *OptionalString `json:",omitempty"`
+	// Command is a program started for each reprovide, which is written the
+	// remaining CIDs one per line and answers each with a "yes" or "no"
+	// line.
+	Command []string `json:",omitempty"`
+	// URL is POSTed batches of the remaining CIDs, one per line, and
+	// answers with a "yes" or "no" line per CID.
+	URL *OptionalString `json:",omitempty"`
+	// Timeout bounds each answer of Command and each request to URL.
+	Timeout *OptionalDuration `json:",omitempty"`
 }
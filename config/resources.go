@@ -0,0 +1,26 @@
+package config
+
+// DefaultResourcesReferenceMemoryMB is the memory the defaults of the daemon
+// are sized for. With a lower Resources.MaxMemoryMB, they are scaled down in
+// proportion.
+const DefaultResourcesReferenceMemoryMB = 4096
+
+// Resources tunes the daemon to the memory of the device it runs on.
+type Resources struct {
+	// MaxMemoryMB is the memory, in MiB, the daemon should stay within. It
+	// sets GOMEMLIMIT, and scales down the defaults of the caches, of the
+	// Bitswap workers, of the connection manager and of the libp2p resource
+	// manager.
+	MaxMemoryMB *OptionalInteger `json:",omitempty"`
+}
+
+// Scale returns def in proportion to Resources.MaxMemoryMB, and no lower than
+// min. def is returned as is when Resources.MaxMemoryMB is not set, or is not
+// lower than DefaultResourcesReferenceMemoryMB.
+func (r Resources) Scale(def, min int64) int64 {
+	mb := r.MaxMemoryMB.WithDefault(0)
+	if mb <= 0 || mb >= DefaultResourcesReferenceMemoryMB {
+		return def
+	}
+	return max(def*mb/DefaultResourcesReferenceMemoryMB, min)
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourcesScale(t *testing.T) {
+	require := require.New(t)
+
+	var r Resources
+	require.Equal(int64(128), r.Scale(128, 8), "unset")
+
+	r.MaxMemoryMB = NewOptionalInteger(8192)
+	require.Equal(int64(128), r.Scale(128, 8), "above the reference memory")
+
+	r.MaxMemoryMB = NewOptionalInteger(1024)
+	require.Equal(int64(32), r.Scale(128, 8))
+
+	r.MaxMemoryMB = NewOptionalInteger(128)
+	require.Equal(int64(8), r.Scale(128, 8), "no lower than min")
+
+	c := &Config{}
+	require.NoError(Profiles["lowpower-constrained"].Transform(c))
+	require.Equal(int64(512), c.Resources.MaxMemoryMB.WithDefault(0))
+	require.Equal("autoclient", c.Routing.Type.WithDefault(""))
+}
@@ -4,31 +4,147 @@ import (
 	"encoding/json"
 	"fmt"
 	"runtime"
+	"time"
 )
 
 var (
-	DefaultAcceleratedDHTClient      = false
-	DefaultLoopbackAddressesOnLanDHT = false
+	DefaultAcceleratedDHTClient       = false
+	DefaultLoopbackAddressesOnLanDHT  = false
+	DefaultDelegatedPublishing        = false
+	DefaultServerModePressure         = false
+	DefaultServerModePressureCheck    = 30 * time.Second
+	DefaultServerModePressureCooldown = 10 * time.Minute
+	DefaultPersistRoutingTable        = false
+	DefaultMaxProviderRecords         = int64(0)
 )
 
 // Routing defines configuration options for libp2p routing.
 type Routing struct {
 	// Type sets default daemon routing mode.
 	//
-	// Can be one of "auto", "autoclient", "dht", "dhtclient", "dhtserver", "none", or "custom".
+	// Can be one of "auto", "autoclient", "dht", "dhtclient", "dhtserver", "delegated", "none", or "custom".
 	// When unset or set to "auto", DHT and implicit routers are used.
+	// When "delegated" is set, only Routing.DelegatedRouters are used.
 	// When "custom" is set, user-provided Routing.Routers is used.
 	Type *OptionalString `json:",omitempty"`
 
-	AcceleratedDHTClient Flag `json:",omitempty"`
+	// DelegatedRouters are the HTTP routing V1 endpoints used with Type
+	// "auto", "autoclient" and "delegated", instead of the implicit ones.
+	DelegatedRouters []string `json:",omitempty"`
+
+	// DelegatedPublishing sends the provider records and IPNS records of the
+	// node to the delegated routers, which are only read by default.
+	DelegatedPublishing Flag `json:",omitempty"`
+
+	// AcceleratedDHTClient uses the accelerated DHT client, which crawls the
+	// whole DHT: for every operation when true, and only to provide when
+	// "provide-only".
+	AcceleratedDHTClient AcceleratedDHTClientFlag `json:",omitempty"`
 
 	LoopbackAddressesOnLanDHT Flag `json:",omitempty"`
 
+	// IgnoreProviders are the providers that Bitswap never asks for blocks:
+	// peer IDs, IP prefixes such as "192.0.2.0/24" or
+	// "/ip4/192.0.2.0/ipcidr/24", and autonomous systems such as "AS64496".
+	IgnoreProviders []string `json:",omitempty"`
+
+	// StaticProviders is the path, relative to the repo, of a JSON or CAR
+	// index of the providers of CIDs, consulted before the other routers.
+	StaticProviders *OptionalString `json:",omitempty"`
+
+	// PersistTable saves the routing table of the DHT in the repo on
+	// shutdown, and preloads it on startup.
+	PersistTable Flag `json:",omitempty"`
+
+	// ServerModePressure stops serving the DHT while the node is short on
+	// memory or file descriptors.
+	ServerModePressure ServerModePressure
+
+	// MaxProviderRecords caps the provider records stored by the DHTs of the
+	// node. The new records of other peers over it are refused, the stored
+	// ones are still refreshed. Zero is unlimited.
+	MaxProviderRecords *OptionalInteger `json:",omitempty"`
+
 	Routers Routers
 
 	Methods Methods
 }
 
+// ServerModePressure demotes the DHT servers of the node to clients when the
+// memory or the file descriptors in use cross a threshold, and promotes them
+// back once the usage stayed below the thresholds for Cooldown.
+type ServerModePressure struct {
+	Enabled Flag `json:",omitempty"`
+	// MaxMemory is the memory used by the process above which the node
+	// demotes itself, for example "3GiB". Defaults to 75% of the system
+	// memory.
+	MaxMemory *OptionalString `json:",omitempty"`
+	// MaxFileDescriptors is the number of open file descriptors above which
+	// the node demotes itself. Defaults to 75% of the file descriptor limit.
+	MaxFileDescriptors *OptionalInteger `json:",omitempty"`
+	// CheckInterval is the time between two measures of the usage.
+	CheckInterval *OptionalDuration `json:",omitempty"`
+	// Cooldown is how long the usage must stay below 80% of the thresholds
+	// before the node serves the DHT again.
+	Cooldown *OptionalDuration `json:",omitempty"`
+}
+
+// AcceleratedDHTClientFlag is a Flag that can also be "provide-only", to use
+// the accelerated DHT client to provide while the standard client does the
+// lookups.
+type AcceleratedDHTClientFlag Flag
+
+// AcceleratedDHTClientProvideOnly is the "provide-only" value of
+// Routing.AcceleratedDHTClient.
+const AcceleratedDHTClientProvideOnly AcceleratedDHTClientFlag = 2
+
+const acceleratedDHTClientProvideOnlyString = "provide-only"
+
+// WithDefault reports whether the accelerated DHT client is used, for every
+// operation or only to provide.
+func (f AcceleratedDHTClientFlag) WithDefault(defaultValue bool) bool {
+	if f == AcceleratedDHTClientProvideOnly {
+		return true
+	}
+	return Flag(f).WithDefault(defaultValue)
+}
+
+// ProvideOnly reports whether the accelerated DHT client is only used to
+// provide.
+func (f AcceleratedDHTClientFlag) ProvideOnly() bool {
+	return f == AcceleratedDHTClientProvideOnly
+}
+
+func (f AcceleratedDHTClientFlag) MarshalJSON() ([]byte, error) {
+	if f == AcceleratedDHTClientProvideOnly {
+		return json.Marshal(acceleratedDHTClientProvideOnlyString)
+	}
+	return Flag(f).MarshalJSON()
+}
+
+func (f *AcceleratedDHTClientFlag) UnmarshalJSON(input []byte) error {
+	if string(input) == `"`+acceleratedDHTClientProvideOnlyString+`"` {
+		*f = AcceleratedDHTClientProvideOnly
+		return nil
+	}
+	if err := (*Flag)(f).UnmarshalJSON(input); err != nil {
+		return fmt.Errorf("failed to unmarshal %q into Routing.AcceleratedDHTClient: must be null/undefined, true, false, or %q", string(input), acceleratedDHTClientProvideOnlyString)
+	}
+	return nil
+}
+
+func (f AcceleratedDHTClientFlag) String() string {
+	if f == AcceleratedDHTClientProvideOnly {
+		return acceleratedDHTClientProvideOnlyString
+	}
+	return Flag(f).String()
+}
+
+var (
+	_ json.Unmarshaler = (*AcceleratedDHTClientFlag)(nil)
+	_ json.Marshaler   = (*AcceleratedDHTClientFlag)(nil)
+)
+
 type Router struct {
 	// Router type ID. See RouterType for more info.
 	Type RouterType
@@ -84,6 +200,7 @@ func (r *RouterParser) UnmarshalJSON(b []byte) error {
 	}
 	raw := out.Parameters.(*json.RawMessage)
 
+	// the parameters of the router types of plugins are decoded as a map
 	var p interface{}
 	switch out.Type {
 	case RouterTypeHTTP:
@@ -96,8 +213,10 @@ func (r *RouterParser) UnmarshalJSON(b []byte) error {
 		p = &ComposableRouterParams{}
 	}
 
-	if err := json.Unmarshal(*raw, &p); err != nil {
-		return err
+	if len(*raw) > 0 {
+		if err := json.Unmarshal(*raw, &p); err != nil {
+			return err
+		}
 	}
 
 	r.Router.Type = out.Type
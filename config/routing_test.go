@@ -144,3 +144,30 @@ func TestMethods(t *testing.T) {
 
 	require.Error(methodsMissing.Check())
 }
+
+func TestAcceleratedDHTClientFlag(t *testing.T) {
+	for _, tc := range []struct {
+		json        string
+		flag        AcceleratedDHTClientFlag
+		enabled     bool
+		provideOnly bool
+	}{
+		{json: `null`, flag: AcceleratedDHTClientFlag(Default)},
+		{json: `false`, flag: AcceleratedDHTClientFlag(False)},
+		{json: `true`, flag: AcceleratedDHTClientFlag(True), enabled: true},
+		{json: `"provide-only"`, flag: AcceleratedDHTClientProvideOnly, enabled: true, provideOnly: true},
+	} {
+		var f AcceleratedDHTClientFlag
+		require.NoError(t, json.Unmarshal([]byte(tc.json), &f))
+		require.Equal(t, tc.flag, f)
+		require.Equal(t, tc.enabled, f.WithDefault(false))
+		require.Equal(t, tc.provideOnly, f.ProvideOnly())
+
+		out, err := json.Marshal(f)
+		require.NoError(t, err)
+		require.Equal(t, tc.json, string(out))
+	}
+
+	var f AcceleratedDHTClientFlag
+	require.Error(t, json.Unmarshal([]byte(`"lookup-only"`), &f))
+}
@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+const DefaultScheduleTaskInterval = 24 * time.Hour
+
+// Schedule configures recurring maintenance tasks run by the daemon.
+type Schedule struct {
+	Tasks []ScheduleTask `json:",omitempty"`
+}
+
+// ScheduleTask is an ipfs command the daemon runs periodically.
+type ScheduleTask struct {
+	// Name identifies the task in 'ipfs schedule ls'. It must be unique.
+	Name string
+	// Command is the command line to run without the leading "ipfs", for
+	// example ["repo", "gc"].
+	Command []string
+	// At is the local time of day ("15:04") of the first run. When unset the
+	// first run happens one Interval after the daemon starts.
+	At *OptionalString `json:",omitempty"`
+	// Interval is the time between the start of two runs.
+	Interval *OptionalDuration `json:",omitempty"`
+}
@@ -0,0 +1,11 @@
+package config
+
+const DefaultSearchEnabled = false
+
+// Search configures the index of the filenames of the pinned and MFS content
+// queried by ipfs search.
+type Search struct {
+	// Enabled maintains the index of the paths of the UnixFS directories
+	// pinned recursively or in MFS, updated as pins and MFS change.
+	Enabled Flag `json:",omitempty"`
+}
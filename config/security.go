@@ -0,0 +1,42 @@
+package config
+
+import "time"
+
+const (
+	DefaultSecuritySandboxEnabled = false
+
+	DefaultSecurityKeyAuditEnabled   = false
+	DefaultSecurityKeyAuditRetention = 365 * 24 * time.Hour
+)
+
+// Security configures the hardening of the daemon.
+type Security struct {
+	Sandbox  SecuritySandbox
+	KeyAudit SecurityKeyAudit
+}
+
+// SecuritySandbox restricts the filesystem access of the daemon, with
+// Landlock on Linux, to the repo, to the files and directories of its
+// configuration, such as the mount points, the log files and the TLS
+// certificates, and to the system files it reads, such as /etc/resolv.conf.
+// The daemon restarts itself in the sandbox before it opens the repo.
+type SecuritySandbox struct {
+	Enabled Flag `json:",omitempty"`
+	// ReadOnlyPaths and ReadWritePaths are the other files and directories,
+	// relative to the repo, the daemon may access, such as the datastores
+	// outside of the repo.
+	ReadOnlyPaths  []string `json:",omitempty"`
+	ReadWritePaths []string `json:",omitempty"`
+}
+
+// SecurityKeyAudit records the uses of the keys of the keystore, and of the
+// identity key by the commands, in the datastore: the IPNS records published,
+// the messages signed, the keys generated, imported, exported, renamed and
+// removed, with the command and the API.Authorizations token which used them.
+// They are listed by 'ipfs key audit'. The uses of the identity by libp2p
+// are not recorded.
+type SecurityKeyAudit struct {
+	Enabled Flag `json:",omitempty"`
+	// Retention is how long the uses are kept.
+	Retention *OptionalDuration `json:",omitempty"`
+}
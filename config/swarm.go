@@ -1,5 +1,25 @@
 package config
 
+import "time"
+
+const (
+	DefaultDialPreferTransport = "quic"
+	DefaultDialPreferIPVersion = "ip6"
+	DefaultDialPublicDelay     = 250 * time.Millisecond
+	DefaultDialPrivateDelay    = 30 * time.Millisecond
+	DefaultDialRelayDelay      = 500 * time.Millisecond
+
+	DefaultSwarmAllowlistEnabled         = false
+	DefaultSwarmAllowlistRefreshInterval = time.Minute
+
+	DefaultPeerstorePersist        = false
+	DefaultPeerstoreRetention      = 72 * time.Hour
+	DefaultPeerstoreMaxPeers       = 1000
+	DefaultPeerstoreMinConfidence  = 20
+	DefaultPeerstoreSaveInterval   = 5 * time.Minute
+	DefaultPeerstoreReconnectPeers = 16
+)
+
 type SwarmConfig struct {
 	// AddrFilters specifies a set libp2p addresses that we should never
 	// dial or receive connections from.
@@ -32,6 +52,17 @@ type SwarmConfig struct {
 
 	// ResourceMgr configures the libp2p Network Resource Manager
 	ResourceMgr ResourceMgr
+
+	// DialPolicy configures how outgoing connection attempts are ranked
+	// and limited.
+	DialPolicy DialPolicy
+
+	// Allowlist restricts the peers the node connects with.
+	Allowlist SwarmAllowlist
+
+	// Peerstore keeps the addresses of the peers in the repo across
+	// restarts.
+	Peerstore SwarmPeerstore
 }
 
 type RelayClient struct {
@@ -112,6 +143,63 @@ type ConnMgr struct {
 	GracePeriod *OptionalDuration `json:",omitempty"`
 }
 
+// DialPolicy defines the happy-eyeballs style ranking of outgoing dials.
+// When every field is unset, the libp2p default dial ranker is used.
+type DialPolicy struct {
+	// PreferTransport selects the transport family dialed first, "quic"
+	// (QUIC, WebTransport, WebRTC) or "tcp" (TCP, WebSocket).
+	PreferTransport *OptionalString `json:",omitempty"`
+	// PreferIPVersion selects the IP version dialed first, "ip6" or "ip4".
+	PreferIPVersion *OptionalString `json:",omitempty"`
+	// PublicDelay is the delay between successive dials, and before
+	// falling back to the other transport family, for public addresses.
+	PublicDelay *OptionalDuration `json:",omitempty"`
+	// PrivateDelay is the same as PublicDelay for LAN and loopback addresses.
+	PrivateDelay *OptionalDuration `json:",omitempty"`
+	// RelayDelay postpones relay dials when direct addresses are known.
+	RelayDelay *OptionalDuration `json:",omitempty"`
+}
+
+// IsDefault returns true when no dial policy knob has been set.
+func (d DialPolicy) IsDefault() bool {
+	return d.PreferTransport.IsDefault() && d.PreferIPVersion.IsDefault() &&
+		d.PublicDelay.IsDefault() && d.PrivateDelay.IsDefault() && d.RelayDelay.IsDefault()
+}
+
+// SwarmAllowlist only lets the listed peers connect with the node. The peers
+// are the union of Peers, and of the peer IDs listed one per line by File and
+// URL, which are reloaded every RefreshInterval.
+type SwarmAllowlist struct {
+	Enabled Flag     `json:",omitempty"`
+	Peers   []string `json:",omitempty"`
+	// File is the path of a file of peer IDs.
+	File *OptionalString `json:",omitempty"`
+	// URL is an HTTP(S) URL returning peer IDs.
+	URL             *OptionalString   `json:",omitempty"`
+	RefreshInterval *OptionalDuration `json:",omitempty"`
+}
+
+// SwarmPeerstore saves the addresses and metadata of the peers the node
+// connected with in the repo, so that it reconnects with them after a
+// restart. Each address has a confidence, in percent, that rises when a dial
+// to it succeeds and falls when it fails.
+type SwarmPeerstore struct {
+	Persist Flag `json:",omitempty"`
+	// Retention is how long a peer or an address not connected with is
+	// kept.
+	Retention *OptionalDuration `json:",omitempty"`
+	// MaxPeers caps the number of peers saved, the most recently connected
+	// ones being kept.
+	MaxPeers *OptionalInteger `json:",omitempty"`
+	// MinConfidence is the confidence below which an address is pruned.
+	MinConfidence *OptionalInteger `json:",omitempty"`
+	// SaveInterval is how often the peers are saved, besides on shutdown.
+	SaveInterval *OptionalDuration `json:",omitempty"`
+	// ReconnectPeers is the number of saved peers dialed on startup, the
+	// most confident first.
+	ReconnectPeers *OptionalInteger `json:",omitempty"`
+}
+
 // ResourceMgr defines configuration options for the libp2p Network Resource Manager
 // <https://github.com/libp2p/go-libp2p/tree/master/p2p/host/resource-manager#readme>
 type ResourceMgr struct {
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,11 +11,13 @@ import (
 
 	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
 
 	"github.com/cheggaaa/pb"
 	"github.com/ipfs/boxo/files"
 	mfs "github.com/ipfs/boxo/mfs"
 	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	ipld "github.com/ipfs/go-ipld-format"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
@@ -136,6 +139,17 @@ IPFS software to use the same import parameters as Kubo.
 If you need to back up or transport content-addressed data using a non-IPFS
 medium, CID can be preserved with CAR files.
 See 'dag export' and 'dag import' for more information.
+
+Symlinks inside added directories are stored as UnixFS symlinks by default.
+Use '--symlinks=follow' to add the files and directories they point to
+instead, or '--symlinks=forbid' to fail when a symlink is found. The default
+can be set with the 'Import.Symlinks' config option. Symlinks are followed by
+the ipfs command line before the files are sent to the daemon.
+
+Extended attributes and POSIX ACLs are not part of UnixFS. With
+'--preserve-xattrs', the ipfs command line records them in a
+'.ipfs-xattrs.json' file added in each directory, or in the wrapping
+directory with '-w'. 'ipfs get --preserve-xattrs' restores them.
 `,
 	},
 
@@ -166,8 +180,22 @@ See 'dag export' and 'dag import' for more information.
 		cmds.IntOption(inlineLimitOptionName, "Maximum block size to inline. (experimental)").WithDefault(32),
 		cmds.BoolOption(pinOptionName, "Pin locally to protect added files from garbage collection.").WithDefault(true),
 		cmds.StringOption(toFilesOptionName, "Add reference to Files API (MFS) at the provided path."),
+		cmds.StringOption(symlinksOptionName, "How to handle symlinks: preserve, follow or forbid. Default: Import.Symlinks"),
+		cmds.BoolOption(preserveXattrsOptionName, "Record extended attributes and POSIX ACLs in a .ipfs-xattrs.json file of each added directory, or of the wrapping directory. Linux only."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		if addSymlinksPolicy(req, env) == symlinksFollow {
+			if err := followSymlinks(req, os.Args[1:]); err != nil {
+				return err
+			}
+		}
+		if xattrs, _ := req.Options[preserveXattrsOptionName].(bool); xattrs {
+			wrap, _ := req.Options[wrapOptionName].(bool)
+			if err := recordXattrs(req, os.Args[1:], wrap); err != nil {
+				return err
+			}
+		}
+
 		quiet, _ := req.Options[quietOptionName].(bool)
 		quieter, _ := req.Options[quieterOptionName].(bool)
 		quiet = quiet || quieter
@@ -217,6 +245,12 @@ See 'dag export' and 'dag import' for more information.
 		inline, _ := req.Options[inlineOptionName].(bool)
 		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
 		toFilesStr, toFilesSet := req.Options[toFilesOptionName].(string)
+		symlinks, _ := req.Options[symlinksOptionName].(string)
+
+		// Pins counted against an RPC token quota are created once the
+		// added DAG is known to fit.
+		quotaUser, pinQuota := node.RPCUserFromContext(req.Context)
+		pinQuota = pinQuota && dopin && !onlyHash && nd.RPCQuotas.HasPinQuota(quotaUser)
 
 		if chunker == "" {
 			chunker = cfg.Import.UnixFSChunker.WithDefault(config.DefaultUnixFSChunker)
@@ -236,6 +270,14 @@ See 'dag export' and 'dag import' for more information.
 			rawblks = cfg.Import.UnixFSRawLeaves.WithDefault(config.DefaultUnixFSRawLeaves)
 		}
 
+		if symlinks == "" {
+			symlinks = cfg.Import.Symlinks.WithDefault(config.DefaultImportSymlinks)
+		}
+		symlinksFn, err := addSymlinksFunc(symlinks)
+		if err != nil {
+			return err
+		}
+
 		if onlyHash && toFilesSet {
 			return fmt.Errorf("%s and %s options are not compatible", onlyHashOptionName, toFilesOptionName)
 		}
@@ -251,9 +293,16 @@ See 'dag export' and 'dag import' for more information.
 		}
 
 		toadd := req.Files
+		if symlinksFn != nil {
+			filtered, err := filterSymlinks(toadd, "", symlinksFn)
+			if err != nil {
+				return err
+			}
+			toadd = filtered.(files.Directory)
+		}
 		if wrap {
 			toadd = files.NewSliceDirectory([]files.DirEntry{
-				files.FileEntry("", req.Files),
+				files.FileEntry("", toadd),
 			})
 		}
 
@@ -265,7 +314,7 @@ See 'dag export' and 'dag import' for more information.
 
 			options.Unixfs.Chunker(chunker),
 
-			options.Unixfs.Pin(dopin),
+			options.Unixfs.Pin(dopin && !pinQuota),
 			options.Unixfs.HashOnly(onlyHash),
 			options.Unixfs.FsCache(fscache),
 			options.Unixfs.Nocopy(nocopy),
@@ -310,6 +359,16 @@ See 'dag export' and 'dag import' for more information.
 					return
 				}
 
+				if pinQuota {
+					err = nd.RPCQuotas.ChargePins(req.Context, quotaUser, []cid.Cid{pathAdded.RootCid()}, func(ctx context.Context) error {
+						return api.Pin().Add(ctx, pathAdded)
+					})
+					if err != nil {
+						errCh <- err
+						return
+					}
+				}
+
 				// creating MFS pointers when optional --to-files is set
 				if toFilesSet {
 					if toFilesStr == "" {
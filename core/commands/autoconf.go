@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/autoconf"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+var AutoConfCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the network defaults fetched with AutoConf.",
+		ShortDescription: `
+With AutoConf enabled, the daemon fetches the bootstrap peers, delegated
+routers and DNS resolvers of the network from a document signed by one of
+AutoConf.TrustedKeys, every AutoConf.RefreshInterval. They replace the "auto"
+values of Bootstrap, Routing.DelegatedRouters and DNS.Resolvers.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status": autoConfStatusCmd,
+		"diff":   autoConfDiffCmd,
+	},
+}
+
+type autoConfStatusOutput struct {
+	Enabled bool
+	URL     string
+	autoconf.State
+	// Bootstrap, DelegatedRouters and DNSResolvers are the values of the
+	// config in use, with "auto" replaced.
+	Bootstrap        []string
+	DelegatedRouters []string
+	DNSResolvers     map[string]string
+}
+
+var autoConfStatusCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the network defaults fetched and the values in use.",
+		ShortDescription: `
+'ipfs autoconf status' shows the version of the last document fetched from
+AutoConf.URL, when it was fetched, the error of the last attempt, and the
+bootstrap peers, delegated routers and DNS resolvers of the config, with the
+"auto" values replaced. The delegated routers and the DNS resolvers fetched
+are used from the next start of the daemon.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+		s, err := autoconf.LoadState(req.Context, n.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+
+		v := autoconf.Load(req.Context, cfg, n.Repo.Datastore())
+		peers, err := v.BootstrapPeers(cfg)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &autoConfStatusOutput{
+			Enabled:          cfg.AutoConf.Enabled.WithDefault(config.DefaultAutoConfEnabled),
+			URL:              cfg.AutoConf.URL,
+			State:            *s,
+			Bootstrap:        config.BootstrapPeerStrings(peers),
+			DelegatedRouters: v.Routers(cfg),
+			DNSResolvers:     v.Resolvers(cfg),
+		})
+	},
+	Type: autoConfStatusOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *autoConfStatusOutput) error {
+			if !out.Enabled {
+				fmt.Fprintln(w, "AutoConf is disabled, the built-in defaults are used")
+			} else {
+				fmt.Fprintf(w, "URL: %s\n", out.URL)
+			}
+			if out.Values != nil {
+				fmt.Fprintf(w, "Version: %d, fetched %s\n", out.Values.Version, out.Fetched.Format(time.RFC3339))
+			}
+			if out.LastError != "" {
+				fmt.Fprintf(w, "Last attempt: %s (%s)\n", out.LastAttempt.Format(time.RFC3339), out.LastError)
+			}
+			for _, p := range out.Bootstrap {
+				fmt.Fprintf(w, "bootstrap %s\n", p)
+			}
+			for _, r := range out.DelegatedRouters {
+				fmt.Fprintf(w, "router %s\n", r)
+			}
+			for _, r := range resolverStrings(out.DNSResolvers) {
+				fmt.Fprintf(w, "resolver %s\n", r)
+			}
+			return nil
+		}),
+	},
+}
+
+// autoConfChanges are the values added and removed by a document.
+type autoConfChanges struct {
+	Added   []string
+	Removed []string
+}
+
+type autoConfDiffOutput struct {
+	Version          uint64
+	NewVersion       uint64
+	Bootstrap        autoConfChanges
+	DelegatedRouters autoConfChanges
+	// DNSResolvers are listed as "domain url".
+	DNSResolvers autoConfChanges
+}
+
+var autoConfDiffCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the changes of the document served at AutoConf.URL.",
+		ShortDescription: `
+'ipfs autoconf diff' fetches the document of AutoConf.URL, verifies it with
+AutoConf.TrustedKeys, and lists the bootstrap peers, delegated routers and DNS
+resolvers it adds and removes from the last document fetched. Nothing is
+applied, so a document can be reviewed before AutoConf is enabled.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+		s, err := autoconf.LoadState(req.Context, n.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+		fetched, err := autoconf.Fetch(req.Context, cfg.AutoConf)
+		if err != nil {
+			return err
+		}
+
+		kept := s.Values
+		if kept == nil {
+			kept = &autoconf.Values{}
+		}
+		return cmds.EmitOnce(res, &autoConfDiffOutput{
+			Version:          kept.Version,
+			NewVersion:       fetched.Version,
+			Bootstrap:        diffAutoConfValues(kept.Bootstrap, fetched.Bootstrap),
+			DelegatedRouters: diffAutoConfValues(kept.DelegatedRouters, fetched.DelegatedRouters),
+			DNSResolvers:     diffAutoConfValues(resolverStrings(kept.DNSResolvers), resolverStrings(fetched.DNSResolvers)),
+		})
+	},
+	Type: autoConfDiffOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *autoConfDiffOutput) error {
+			fmt.Fprintf(w, "version %d -> %d", out.Version, out.NewVersion)
+			if out.NewVersion < out.Version {
+				fmt.Fprint(w, " (older, the document would be refused)")
+			}
+			fmt.Fprintln(w)
+			for _, c := range []struct {
+				name    string
+				changes autoConfChanges
+			}{
+				{"bootstrap", out.Bootstrap},
+				{"router", out.DelegatedRouters},
+				{"resolver", out.DNSResolvers},
+			} {
+				for _, v := range c.changes.Removed {
+					fmt.Fprintf(w, "- %s %s\n", c.name, v)
+				}
+				for _, v := range c.changes.Added {
+					fmt.Fprintf(w, "+ %s %s\n", c.name, v)
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+func diffAutoConfValues(kept, fetched []string) autoConfChanges {
+	changes := autoConfChanges{Added: []string{}, Removed: []string{}}
+	for _, v := range fetched {
+		if !slices.Contains(kept, v) {
+			changes.Added = append(changes.Added, v)
+		}
+	}
+	for _, v := range kept {
+		if !slices.Contains(fetched, v) {
+			changes.Removed = append(changes.Removed, v)
+		}
+	}
+	return changes
+}
+
+func resolverStrings(resolvers map[string]string) []string {
+	s := make([]string, 0, len(resolvers))
+	for domain, url := range resolvers {
+		s = append(s, domain+" "+url)
+	}
+	sort.Strings(s)
+	return s
+}
@@ -3,9 +3,13 @@ package commands
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	e "github.com/ipfs/kubo/core/commands/e"
+	"github.com/ipfs/kubo/core/node"
 
 	humanize "github.com/dustin/go-humanize"
 	bitswap "github.com/ipfs/boxo/bitswap"
@@ -22,10 +26,15 @@ var BitswapCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"stat":      bitswapStatCmd,
-		"wantlist":  showWantlistCmd,
-		"ledger":    ledgerCmd,
-		"reprovide": reprovideCmd,
+		"stat":               bitswapStatCmd,
+		"wantlist":           showWantlistCmd,
+		"ledger":             ledgerCmd,
+		"reprovide":          reprovideCmd,
+		"reprovide-wantlist": reprovideWantlistCmd,
+		"recorded-wants":     recordedWantsCmd,
+		"sessions":           bitswapSessionsCmd,
+		"reputation":         bitswapReputationCmd,
+		"queue":              bitswapQueueCmd,
 	},
 }
 
@@ -53,7 +62,7 @@ Print out all blocks currently on the bitswap wantlist for the local peer.`,
 			return ErrNotOnline
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		bs, ok := node.PublicExchange(nd.Exchange).(*bitswap.Bitswap)
 		if !ok {
 			return e.TypeErr(bs, nd.Exchange)
 		}
@@ -92,16 +101,40 @@ const (
 	bitswapHumanOptionName   = "human"
 )
 
+// bitswapStat is the output of 'ipfs bitswap stat'. Client is only set with
+// --verbose.
+type bitswapStat struct {
+	bitswap.Stat
+	Verifier *node.BitswapVerifierStats    `json:",omitempty"`
+	Policy   *node.BitswapBlockPolicyStats `json:",omitempty"`
+	Client   *node.BitswapClientStats      `json:",omitempty"`
+}
+
 var bitswapStatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline:          "Show some diagnostic information on the bitswap agent.",
-		ShortDescription: ``,
+		Tagline: "Show some diagnostic information on the bitswap agent.",
+		ShortDescription: `
+Blocks received from peers they were not requested from are invalid: Bitswap
+derives the CID of a block from its data, so corrupted data does not match the
+requested CID. Such peers are banned for Bitswap.BlockVerification.BanDuration,
+and the wants sent to them are retried with other peers. 'invalid blocks
+received' counts these blocks, and 'wants retried after bans' the wants
+retried.
+
+When Bitswap.BlockPolicy is set, the blocks received and served against it are
+counted as refused and not served.
+
+With --verbose, the partners are listed along with histograms of the time from
+sending a want to receiving the block, of the time provider queries take to
+find a first provider and to end, and the duplicate blocks received by the
+most recent sessions.
+`,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(bitswapVerboseOptionName, "v", "Print extra information"),
 		cmds.BoolOption(bitswapHumanOptionName, "Print sizes in human readable format (e.g., 1K 234M 2G)"),
 	},
-	Type: bitswap.Stat{},
+	Type: bitswapStat{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
 		if err != nil {
@@ -112,7 +145,7 @@ var bitswapStatCmd = &cmds.Command{
 			return cmds.Errorf(cmds.ErrClient, ErrNotOnline.Error())
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		bs, ok := node.PublicExchange(nd.Exchange).(*bitswap.Bitswap)
 		if !ok {
 			return e.TypeErr(bs, nd.Exchange)
 		}
@@ -122,10 +155,25 @@ var bitswapStatCmd = &cmds.Command{
 			return err
 		}
 
-		return cmds.EmitOnce(res, st)
+		out := &bitswapStat{Stat: *st}
+		if nd.BitswapVerifier != nil {
+			vst := nd.BitswapVerifier.Stats()
+			out.Verifier = &vst
+		}
+		if nd.BitswapBlockFilter != nil {
+			pst := nd.BitswapBlockFilter.Stats()
+			out.Policy = &pst
+		}
+		verbose, _ := req.Options[bitswapVerboseOptionName].(bool)
+		if verbose && nd.BitswapStats != nil {
+			client := nd.BitswapStats.Stats()
+			out.Client = &client
+		}
+
+		return cmds.EmitOnce(res, out)
 	},
 	Encoders: cmds.EncoderMap{
-		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *bitswap.Stat) error {
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *bitswapStat) error {
 			enc, err := cmdenv.GetLowLevelCidEncoder(req)
 			if err != nil {
 				return err
@@ -150,6 +198,15 @@ var bitswapStatCmd = &cmds.Command{
 			} else {
 				fmt.Fprintf(w, "\tdup data received: %d\n", s.DupDataReceived)
 			}
+			if v := s.Verifier; v != nil {
+				fmt.Fprintf(w, "\tinvalid blocks received: %d\n", v.InvalidBlocks)
+				fmt.Fprintf(w, "\tpeers banned for invalid blocks: %d (%d bans)\n", v.BannedPeers, v.Bans)
+				fmt.Fprintf(w, "\twants retried after bans: %d\n", v.RetriedWants)
+			}
+			if p := s.Policy; p != nil {
+				fmt.Fprintf(w, "\tblocks refused by policy: %d oversized, %d of rejected codecs\n", p.ReceivedOversized, p.ReceivedRejectedCodec)
+				fmt.Fprintf(w, "\tblocks not served by policy: %d oversized, %d of rejected codecs\n", p.ServedOversized, p.ServedRejectedCodec)
+			}
 			fmt.Fprintf(w, "\twantlist [%d keys]\n", len(s.Wantlist))
 			for _, k := range s.Wantlist {
 				fmt.Fprintf(w, "\t\t%s\n", enc.Encode(k))
@@ -162,11 +219,41 @@ var bitswapStatCmd = &cmds.Command{
 				}
 			}
 
+			if c := s.Client; c != nil {
+				printBitswapHistogram(w, "want to block latency", c.BlockLatency)
+				printBitswapHistogram(w, "provider query time to first provider", c.ProviderQueryFirst)
+				printBitswapHistogram(w, "provider query duration", c.ProviderQueryDuration)
+				fmt.Fprintf(w, "\tprovider queries without providers: %d\n", c.ProviderQueriesEmpty)
+				fmt.Fprintf(w, "\tsessions [%d]\n", len(c.Sessions))
+				for _, ss := range c.Sessions {
+					state := "ended"
+					if ss.Active {
+						state = "active"
+					}
+					fmt.Fprintf(w, "\t\t%d (%s): %d blocks, %d dup blocks (%.1f%%)\n", ss.ID, state, ss.Blocks, ss.DupBlocks, 100*float64(ss.DupBlocks)/float64(ss.Blocks+ss.DupBlocks))
+				}
+			}
+
 			return nil
 		}),
 	},
 }
 
+func printBitswapHistogram(w io.Writer, name string, h node.BitswapHistogram) {
+	var avg time.Duration
+	if h.Count > 0 {
+		avg = h.Sum / time.Duration(h.Count)
+	}
+	fmt.Fprintf(w, "\t%s [%d, avg %s]\n", name, h.Count, avg)
+	for _, b := range h.Buckets {
+		if b.UpperBound == 0 {
+			fmt.Fprintf(w, "\t\t> %s: %d\n", h.Buckets[len(h.Buckets)-2].UpperBound, b.Count)
+		} else {
+			fmt.Fprintf(w, "\t\t<= %s: %d\n", b.UpperBound, b.Count)
+		}
+	}
+}
+
 var ledgerCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Show the current ledger for a peer.",
@@ -190,7 +277,7 @@ prints the ledger associated with a given peer.
 			return ErrNotOnline
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		bs, ok := node.PublicExchange(nd.Exchange).(*bitswap.Bitswap)
 		if !ok {
 			return e.TypeErr(bs, nd.Exchange)
 		}
@@ -241,3 +328,324 @@ Trigger reprovider to announce our data to network.
 		return nil
 	},
 }
+
+type reprovideWantlistOutput struct {
+	Wanted  int
+	Fetched int
+}
+
+var reprovideWantlistCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Re-issue the persisted wantlist.",
+		ShortDescription: `
+Re-issue the wants saved by Bitswap.PersistWantlist and wait until they are
+retrieved. Wants for blocks already in the blockstore are skipped.
+
+The daemon does this on startup; use this command to retry wants that were
+not found then. Only the wants outstanding at the time the wantlist was saved
+are re-issued, so interrupted 'ipfs pin add' calls still need to be rerun to
+create the pins, but will not download the retrieved blocks again.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		wants, err := node.LoadPersistedWantlist(req.Context, nd.Repo.Datastore(), nd.Blockstore)
+		if err != nil {
+			return err
+		}
+		fetched := node.ReissueWantlist(req.Context, nd.Blocks, wants)
+
+		return cmds.EmitOnce(res, &reprovideWantlistOutput{Wanted: len(wants), Fetched: fetched})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *reprovideWantlistOutput) error {
+			_, err := fmt.Fprintf(w, "retrieved %d of %d persisted wants\n", out.Fetched, out.Wanted)
+			return err
+		}),
+	},
+	Type: reprovideWantlistOutput{},
+}
+
+var recordedWantsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the wants recorded in record-only mode.",
+		ShortDescription: `
+When the daemon runs with --bitswap-record-only, Bitswap answers every want of
+other peers as if the block was missing and counts them instead. This command
+reports those counts, to estimate the demand the node would see before it
+starts serving blocks:
+
+  - the peers that sent wants and the distinct CIDs wanted
+  - the want-have, want-block and cancel entries received
+  - the wants for blocks stored locally, and the bytes that would have been
+    sent for their want-block entries
+
+Individual wants are logged at debug level by the 'core:constructor' subsystem.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(bitswapHumanOptionName, "Print sizes in human readable format (e.g., 1K 234M 2G)"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.BitswapRecorder == nil {
+			return cmds.Errorf(cmds.ErrClient, "bitswap is not in record-only mode, start the daemon with --bitswap-record-only")
+		}
+
+		st := nd.BitswapRecorder.Stats()
+		return cmds.EmitOnce(res, &st)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *node.BitswapRecordedWants) error {
+			human, _ := req.Options[bitswapHumanOptionName].(bool)
+
+			fmt.Fprintf(w, "recorded since: %s\n", s.Since.Format(time.DateTime))
+			fmt.Fprintf(w, "\tpeers: %d\n", s.Peers)
+			fmt.Fprintf(w, "\tunique cids: %d\n", s.UniqueCids)
+			fmt.Fprintf(w, "\twant-have: %d\n", s.WantHaves)
+			fmt.Fprintf(w, "\twant-block: %d\n", s.WantBlocks)
+			fmt.Fprintf(w, "\tcancels: %d\n", s.Cancels)
+			fmt.Fprintf(w, "\tlocal wants: %d\n", s.LocalWants)
+			if human {
+				fmt.Fprintf(w, "\tlocal data: %s\n", humanize.Bytes(s.LocalBytes))
+			} else {
+				fmt.Fprintf(w, "\tlocal data: %d\n", s.LocalBytes)
+			}
+			return nil
+		}),
+	},
+	Type: node.BitswapRecordedWants{},
+}
+
+type bitswapSessionsOutput struct {
+	Sessions []node.BitswapSessionStats
+}
+
+var bitswapSessionsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the active bitswap sessions.",
+		ShortDescription: `
+Lists the Bitswap sessions that have not ended, oldest first. Commands fetching
+DAGs, such as 'ipfs pin add' or 'ipfs get', use a session for each DAG. For each
+session, the command prints:
+
+  - its ID and age
+  - the first block it requested
+  - the blocks it requested and has not received yet
+  - the peers that sent blocks to it
+  - the blocks and duplicate blocks it received
+
+Use 'ipfs bitswap sessions cancel' to end a session that no longer makes
+progress.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"cancel": bitswapSessionsCancelCmd,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		out := &bitswapSessionsOutput{Sessions: []node.BitswapSessionStats{}}
+		if nd.BitswapStats != nil {
+			out.Sessions = nd.BitswapStats.ActiveSessions()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *bitswapSessionsOutput) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tAge\tRoot\tWanted\tPeers\tBlocks\tDup blocks")
+			for _, s := range out.Sessions {
+				root := "-"
+				if s.Root.Defined() {
+					root = enc.Encode(s.Root)
+				}
+				age := time.Since(s.Started).Truncate(time.Second)
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%d\t%d\n", s.ID, age, root, s.Wanted, s.Peers, s.Blocks, s.DupBlocks)
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: bitswapSessionsOutput{},
+}
+
+var bitswapSessionsCancelCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel an active bitswap session.",
+		ShortDescription: `
+Ends the session with the given ID, as listed by 'ipfs bitswap sessions'. The
+wants of the session are cancelled and the command that started it fails.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("id", true, false, "The ID of the session to cancel."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		id, err := strconv.ParseUint(req.Arguments[0], 10, 64)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid session ID %q", req.Arguments[0])
+		}
+		if nd.BitswapStats == nil {
+			return cmds.Errorf(cmds.ErrClient, "no active bitswap session %d", id)
+		}
+		if err := nd.BitswapStats.CancelSession(id); err != nil {
+			return cmds.Errorf(cmds.ErrClient, err.Error())
+		}
+		return nil
+	},
+}
+
+type bitswapReputationOutput struct {
+	Peers []node.PeerReputation
+}
+
+var bitswapReputationCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the reputation of the bitswap peers.",
+		ShortDescription: `
+Lists the peers scored by Bitswap.Reputation, best first. For each peer, the
+command prints:
+
+  - its score, between 0 and 1; peers without history score 0.5
+  - the blocks it delivered for the wants sent to it
+  - the want-blocks it answered with DONT_HAVE
+  - the blocks it sent that were not requested from it
+  - the average time from a want to its block
+
+The HAVE responses and provider records of the peers scored under 0.5 are held
+back for up to Bitswap.Reputation.MaxPenalty, so that better scored peers are
+used first. The scores are kept in the datastore across restarts.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.BitswapReputation == nil {
+			return cmds.Errorf(cmds.ErrClient, "peer reputation is disabled, see Bitswap.Reputation.Enabled")
+		}
+
+		return cmds.EmitOnce(res, &bitswapReputationOutput{Peers: nd.BitswapReputation.Reputations()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *bitswapReputationOutput) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Peer\tScore\tDelivered\tFailed\tGarbage\tLatency")
+			for _, p := range out.Peers {
+				fmt.Fprintf(tw, "%s\t%.2f\t%d\t%d\t%d\t%s\n", p.Peer, p.Score, p.Delivered, p.Failed, p.Garbage, p.Latency.Round(time.Millisecond))
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: bitswapReputationOutput{},
+}
+
+const bitswapQueuePrioritiesOptionName = "priorities"
+
+var bitswapQueueCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the backlog of the bitswap server.",
+		ShortDescription: `
+Prints the tasks queued by the bitswap engine: the wants received from peers
+that were not answered yet, the size of the blocks queued to be sent, and the
+backlog of the highest want priorities, served first.
+
+Orchestrators can poll this command to throttle the blocks they add or
+announce while the node is busy serving.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(bitswapQueuePrioritiesOptionName, "Number of the highest priorities to list.").WithDefault(10),
+		cmds.BoolOption(bitswapHumanOptionName, "Print sizes in human readable format (e.g., 1K 234M 2G)"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.BitswapQueue == nil {
+			return cmds.Errorf(cmds.ErrClient, "bitswap is disabled")
+		}
+
+		priorities, _ := req.Options[bitswapQueuePrioritiesOptionName].(int)
+		if priorities < 0 {
+			return cmds.Errorf(cmds.ErrClient, "--%s must not be negative", bitswapQueuePrioritiesOptionName)
+		}
+		stat, err := nd.BitswapQueue.Stat(req.Context, priorities)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &stat)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *node.BitswapQueueStat) error {
+			human, _ := req.Options[bitswapHumanOptionName].(bool)
+			size := func(n uint64) string {
+				if human {
+					return humanize.Bytes(n)
+				}
+				return strconv.FormatUint(n, 10)
+			}
+
+			fmt.Fprintln(w, "bitswap queue")
+			fmt.Fprintf(w, "\ttasks: %d (%d want-block, %d want-have)\n", out.Tasks, out.BlockTasks, out.HaveTasks)
+			fmt.Fprintf(w, "\tpeers: %d\n", out.Peers)
+			fmt.Fprintf(w, "\tqueued bytes: %s\n", size(out.Bytes))
+			if len(out.Priorities) == 0 {
+				return nil
+			}
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "\tpriority\ttasks\tbytes")
+			for _, p := range out.Priorities {
+				fmt.Fprintf(tw, "\t%d\t%d\t%s\n", p.Priority, p.Tasks, size(p.Bytes))
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: node.BitswapQueueStat{},
+}
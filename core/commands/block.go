@@ -1,12 +1,15 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
 
 	"github.com/ipfs/kubo/config"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
@@ -203,6 +206,10 @@ only for backward compatibility when a legacy CIDv0 is required (--format=v0).
 		}
 
 		pin, _ := req.Options[pinOptionName].(bool)
+		// the pins made with a token are charged against its quota once
+		// the blocks are stored
+		quotaUser, pinQuota := nd.RPCQuotas.PinQuotaUser(req.Context)
+		pinQuota = pinQuota && pin
 
 		it := req.Files.Entries()
 		for it.Next() {
@@ -215,7 +222,7 @@ only for backward compatibility when a legacy CIDv0 is required (--format=v0).
 				options.Block.Hash(mhtval, mhlen),
 				options.Block.CidCodec(cidCodec),
 				options.Block.Format(format),
-				options.Block.Pin(pin))
+				options.Block.Pin(pin && !pinQuota))
 			if err != nil {
 				return err
 			}
@@ -224,6 +231,21 @@ only for backward compatibility when a legacy CIDv0 is required (--format=v0).
 				return err
 			}
 
+			if pinQuota {
+				// like 'ipfs pin add', the DAG has to be local to be
+				// charged
+				c := p.Path().RootCid()
+				if err := dag.FetchGraph(req.Context, c, api.Dag()); err != nil {
+					return err
+				}
+				err = nd.RPCQuotas.ChargePins(req.Context, quotaUser, []cid.Cid{c}, func(ctx context.Context) error {
+					return api.Pin().Add(ctx, p.Path())
+				})
+				if err != nil {
+					return err
+				}
+			}
+
 			err = res.Emit(&BlockStat{
 				Key:  p.Path().RootCid().String(),
 				Size: p.Size(),
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+type cancelOutput struct {
+	Cid       string
+	Cancelled int
+}
+
+var CancelCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel the retrieval of a CID.",
+		ShortDescription: `
+Ends the Bitswap sessions fetching the DAG rooted at the given CID, and the
+other Bitswap requests for that block. Their wants are cancelled, their
+provider queries aborted, and the commands and gateway requests that made them
+fail, for example to stop an 'ipfs pin add' of a larger DAG than expected
+without restarting the daemon.
+
+Sessions are matched on the first block they requested: the root of a DAG
+fetched with 'ipfs pin add', 'ipfs get' or the gateway. 'ipfs bitswap
+sessions' lists the active sessions with their root, and 'ipfs bitswap
+sessions cancel' ends a single session.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, false, "The CID whose retrieval to cancel."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		c, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid CID %q: %s", req.Arguments[0], err)
+		}
+
+		var n int
+		if nd.BitswapStats != nil {
+			n = nd.BitswapStats.CancelRetrievals(c)
+		}
+		if n == 0 {
+			return cmds.Errorf(cmds.ErrClient, "no retrieval of %s in progress", c)
+		}
+		return cmds.EmitOnce(res, &cancelOutput{Cid: c.String(), Cancelled: n})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *cancelOutput) error {
+			_, err := fmt.Fprintf(w, "cancelled %d retrievals of %s\n", out.Cancelled, out.Cid)
+			return err
+		}),
+	},
+	Type: cancelOutput{},
+}
@@ -18,9 +18,18 @@ func collectPaths(prefix string, cmd *cmds.Command, out map[string]struct{}) {
 func TestCommands(t *testing.T) {
 	list := []string{
 		"/add",
+		"/autoconf",
+		"/autoconf/diff",
+		"/autoconf/status",
 		"/bitswap",
 		"/bitswap/ledger",
+		"/bitswap/queue",
 		"/bitswap/reprovide",
+		"/bitswap/reprovide-wantlist",
+		"/bitswap/recorded-wants",
+		"/bitswap/reputation",
+		"/bitswap/sessions",
+		"/bitswap/sessions/cancel",
 		"/bitswap/stat",
 		"/bitswap/wantlist",
 		"/block",
@@ -34,6 +43,7 @@ func TestCommands(t *testing.T) {
 		"/bootstrap/list",
 		"/bootstrap/rm",
 		"/bootstrap/rm/all",
+		"/cancel",
 		"/cat",
 		"/cid",
 		"/cid/base32",
@@ -47,6 +57,7 @@ func TestCommands(t *testing.T) {
 		"/commands/completion/fish",
 		"/commands/completion/zsh",
 		"/config",
+		"/config/defaults",
 		"/config/edit",
 		"/config/profile",
 		"/config/profile/apply",
@@ -56,7 +67,9 @@ func TestCommands(t *testing.T) {
 		"/dag/export",
 		"/dag/get",
 		"/dag/import",
+		"/dag/prefetch",
 		"/dag/put",
+		"/dag/query",
 		"/dag/resolve",
 		"/dag/stat",
 		"/dht",
@@ -69,15 +82,24 @@ func TestCommands(t *testing.T) {
 		"/routing",
 		"/routing/put",
 		"/routing/get",
+		"/routing/events",
+		"/routing/inspect",
 		"/routing/findpeer",
 		"/routing/findprovs",
 		"/routing/provide",
+		"/routing/reprovide",
+		"/routing/table",
+		"/routing/table/export",
+		"/routing/table/import",
 		"/diag",
 		"/diag/cmds",
 		"/diag/cmds/clear",
 		"/diag/cmds/set-time",
 		"/diag/profile",
+		"/diag/slowlog",
 		"/diag/sys",
+		"/dns",
+		"/dns/purge",
 		"/files",
 		"/files/chcid",
 		"/files/cp",
@@ -93,9 +115,25 @@ func TestCommands(t *testing.T) {
 		"/filestore/dups",
 		"/filestore/ls",
 		"/filestore/verify",
+		"/fetch",
+		"/fetch/plan",
 		"/get",
+		"/heal",
+		"/features",
+		"/features/disable",
+		"/features/enable",
+		"/features/ls",
+		"/gateway",
+		"/gateway/denylist",
+		"/gateway/denylist/status",
+		"/gateway/dirindex",
+		"/gateway/dirindex/ls",
+		"/gateway/dirindex/purge",
+		"/gateway/redirects",
+		"/gateway/redirects/test",
 		"/id",
 		"/key",
+		"/key/audit",
 		"/key/export",
 		"/key/gen",
 		"/key/import",
@@ -117,6 +155,10 @@ func TestCommands(t *testing.T) {
 		"/multibase/transcode",
 		"/multibase/list",
 		"/name",
+		"/name/cache",
+		"/name/cache/ls",
+		"/name/cache/purge",
+		"/name/thirdparty",
 		"/name/inspect",
 		"/name/publish",
 		"/name/pubsub",
@@ -160,6 +202,11 @@ func TestCommands(t *testing.T) {
 		"/pin/update",
 		"/pin/verify",
 		"/ping",
+		"/provide",
+		"/provide/add",
+		"/provide/ls",
+		"/provide/rm",
+		"/provide/stat",
 		"/pubsub",
 		"/pubsub/ls",
 		"/pubsub/peers",
@@ -168,25 +215,35 @@ func TestCommands(t *testing.T) {
 		"/refs",
 		"/refs/local",
 		"/repo",
+		"/repo/export-car",
 		"/repo/gc",
+		"/repo/import-car",
 		"/repo/migrate",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
 		"/repo/ls",
 		"/resolve",
+		"/schedule",
+		"/schedule/ls",
+		"/search",
 		"/shutdown",
 		"/stats",
+		"/stats/api",
 		"/stats/bitswap",
 		"/stats/bw",
 		"/stats/dht",
+		"/stats/peers",
 		"/stats/provide",
+		"/stats/providers",
+		"/stats/quota",
 		"/stats/repo",
 		"/swarm",
 		"/swarm/addrs",
 		"/swarm/addrs/listen",
 		"/swarm/addrs/local",
 		"/swarm/connect",
+		"/swarm/dial",
 		"/swarm/disconnect",
 		"/swarm/filters",
 		"/swarm/filters/add",
@@ -196,6 +253,8 @@ func TestCommands(t *testing.T) {
 		"/swarm/peering/add",
 		"/swarm/peering/ls",
 		"/swarm/peering/rm",
+		"/swarm/peerstore",
+		"/swarm/peerstore/stats",
 		"/swarm/resources",
 		"/update",
 		"/version",
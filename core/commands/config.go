@@ -58,10 +58,11 @@ Set the value of the 'Datastore.Path' key:
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"show":    configShowCmd,
-		"edit":    configEditCmd,
-		"replace": configReplaceCmd,
-		"profile": configProfileCmd,
+		"show":     configShowCmd,
+		"defaults": configDefaultsCmd,
+		"edit":     configEditCmd,
+		"replace":  configReplaceCmd,
+		"profile":  configProfileCmd,
 	},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "The key of the config entry (e.g. \"Addresses.API\")."),
@@ -0,0 +1,305 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+// configDefault is the value used by the daemon for a config key left unset.
+type configDefault struct {
+	Key   string
+	Value interface{}
+	// zero tells whether the zero value of the key, and not only its absence,
+	// selects the default.
+	zero bool
+}
+
+func durationDefault(d time.Duration) string {
+	return d.String()
+}
+
+// configDefaults are the implicit defaults reported by 'ipfs config defaults'.
+// Defaults derived from the host, such as the resource manager limits, and
+// those of optional sections, such as Bitswap.RemoteBlockstore, are not listed.
+var configDefaults = []configDefault{
+	{Key: "AutoConf.Enabled", Value: config.DefaultAutoConfEnabled},
+	{Key: "AutoConf.RefreshInterval", Value: durationDefault(config.DefaultAutoConfRefreshInterval)},
+
+	{Key: "Bitswap.Enabled", Value: config.DefaultBitswapEnabled},
+	{Key: "Bitswap.Libp2pEnabled", Value: config.DefaultBitswapLibp2pEnabled},
+	{Key: "Bitswap.ServeStrategy", Value: config.DefaultBitswapServeStrategy},
+	{Key: "Bitswap.ServeStrategyRefreshInterval", Value: durationDefault(config.DefaultBitswapServeStrategyRefreshInterval)},
+	{Key: "Bitswap.PersistWantlist", Value: config.DefaultBitswapPersistWantlist},
+	{Key: "Bitswap.PeerMetricsTopN", Value: config.DefaultBitswapPeerMetricsTopN},
+	{Key: "Bitswap.Reputation.Enabled", Value: config.DefaultBitswapReputationEnabled},
+	{Key: "Bitswap.Reputation.MaxPenalty", Value: durationDefault(config.DefaultBitswapReputationMaxPenalty)},
+
+	{Key: "DagIndex.Enabled", Value: config.DefaultDagIndexEnabled},
+
+	{Key: "Discovery.MDNS.UseForRouting", Value: config.DefaultMDNSUseForRouting},
+
+	{Key: "DNS.CacheSize", Value: config.DefaultDNSCacheSize},
+	{Key: "DNS.CacheTTL", Value: durationDefault(config.DefaultDNSCacheTTL)},
+
+	{Key: "Gateway.DeserializedResponses", Value: config.DefaultDeserializedResponses},
+	{Key: "Gateway.DisableHTMLErrors", Value: config.DefaultDisableHTMLErrors},
+	{Key: "Gateway.ExposeRoutingAPI", Value: config.DefaultExposeRoutingAPI},
+	{Key: "Gateway.IPNSPublishing", Value: config.DefaultIPNSPublishing},
+	{Key: "Gateway.AccessLog.Enabled", Value: config.DefaultGatewayAccessLogEnabled},
+	{Key: "Gateway.AccessLog.File", Value: config.DefaultGatewayAccessLogFile},
+	{Key: "Gateway.AccessLog.MaxFileSize", Value: config.DefaultLoggingMaxFileSize},
+	{Key: "Gateway.AccessLog.RotateInterval", Value: durationDefault(config.DefaultLoggingRotateInterval)},
+	{Key: "Gateway.AccessLog.MaxBackups", Value: config.DefaultLoggingMaxBackups},
+	{Key: "Gateway.ResponseCache.Enabled", Value: config.DefaultGatewayResponseCacheEnabled},
+	{Key: "Gateway.ResponseCache.MaxSize", Value: config.DefaultGatewayResponseCacheMaxSize},
+	{Key: "Gateway.ResponseCache.TTL", Value: durationDefault(config.DefaultGatewayResponseCacheTTL)},
+	{Key: "Gateway.DirectoryIndexCache.Enabled", Value: config.DefaultGatewayDirectoryIndexCacheEnabled},
+	{Key: "Gateway.DirectoryIndexCache.MaxSize", Value: config.DefaultGatewayDirectoryIndexCacheMaxSize},
+	{Key: "Gateway.DirectoryIndexCache.MinEntries", Value: config.DefaultGatewayDirectoryIndexCacheMinEntries},
+	{Key: "Gateway.RangeCoalescing.Enabled", Value: config.DefaultGatewayRangeCoalescingEnabled},
+	{Key: "Gateway.RangeCoalescing.MaxPlanSize", Value: config.DefaultGatewayRangeCoalescingMaxPlanSize},
+	{Key: "Gateway.Shadow.Percent", Value: config.DefaultGatewayShadowPercent},
+	{Key: "Gateway.Shadow.Timeout", Value: durationDefault(config.DefaultGatewayShadowTimeout)},
+	{Key: "Gateway.Shadow.MaxConcurrent", Value: config.DefaultGatewayShadowMaxConcurrent},
+	{Key: "Gateway.Notifications.SlowThreshold", Value: durationDefault(config.DefaultGatewayNotificationsSlowThreshold)},
+	{Key: "Gateway.Notifications.Timeout", Value: durationDefault(config.DefaultGatewayNotificationsTimeout)},
+	{Key: "Gateway.Notifications.MaxConcurrent", Value: config.DefaultGatewayNotificationsMaxConcurrent},
+	{Key: "Gateway.Transform.Enabled", Value: config.DefaultGatewayTransformEnabled},
+	{Key: "Gateway.Transform.MaxInputSize", Value: config.DefaultGatewayTransformMaxInputSize},
+	{Key: "Gateway.Transform.MaxPixels", Value: config.DefaultGatewayTransformMaxPixels},
+	{Key: "Gateway.Transform.MaxDimension", Value: config.DefaultGatewayTransformMaxDimension},
+	{Key: "Gateway.Transform.MaxConcurrent", Value: config.DefaultGatewayTransformMaxConcurrent},
+	{Key: "Gateway.Receipts.Enabled", Value: config.DefaultGatewayReceiptsEnabled},
+	{Key: "Gateway.Receipts.MaxTrailerBlocks", Value: config.DefaultGatewayReceiptsMaxTrailerBlocks},
+	{Key: "Gateway.TLS.ACME.Enabled", Value: config.DefaultGatewayACMEEnabled},
+	{Key: "Gateway.TLS.ACME.CA", Value: config.DefaultGatewayACMECA},
+	{Key: "Gateway.TLS.ACME.Challenge", Value: config.DefaultGatewayACMEChallenge},
+
+	{Key: "Import.CidVersion", Value: config.DefaultCidVersion},
+	{Key: "Import.UnixFSRawLeaves", Value: config.DefaultUnixFSRawLeaves},
+	{Key: "Import.UnixFSChunker", Value: config.DefaultUnixFSChunker},
+	{Key: "Import.HashFunction", Value: config.DefaultHashFunction},
+	{Key: "Import.Symlinks", Value: config.DefaultImportSymlinks},
+	{Key: "Import.GetSymlinks", Value: config.DefaultGetSymlinks},
+
+	{Key: "Internal.Bitswap.TaskWorkerCount", Value: node.DefaultTaskWorkerCount},
+	{Key: "Internal.Bitswap.EngineBlockstoreWorkerCount", Value: node.DefaultEngineBlockstoreWorkerCount},
+	{Key: "Internal.Bitswap.EngineTaskWorkerCount", Value: node.DefaultEngineTaskWorkerCount},
+	{Key: "Internal.Bitswap.MaxOutstandingBytesPerPeer", Value: node.DefaultMaxOutstandingBytesPerPeer},
+	{Key: "Internal.Bitswap.ProviderSearchDelay", Value: durationDefault(node.DefaultProviderSearchDelay)},
+	{Key: "Internal.Bitswap.ClientTimeouts.RebroadcastInterval", Value: durationDefault(node.DefaultRebroadcastInterval)},
+	{Key: "Internal.Bitswap.ClientTimeouts.SimulateDontHaves", Value: node.DefaultSimulateDontHaves},
+	{Key: "Internal.Bitswap.ProviderQuery.MaxProviders", Value: node.DefaultProviderQueryMaxProviders},
+	{Key: "Internal.Bitswap.ProviderQuery.MaxConcurrentFinds", Value: node.DefaultProviderQueryMaxConcurrentFinds},
+	{Key: "Internal.Bitswap.ProviderQuery.Timeout", Value: durationDefault(node.DefaultProviderQueryTimeout)},
+	{Key: "Internal.Bitswap.ServerFairness.Mode", Value: node.DefaultServerFairnessMode},
+	{Key: "Internal.Bitswap.ServerFairness.IPv4PrefixLength", Value: node.DefaultServerFairnessIPv4PrefixLength},
+	{Key: "Internal.Bitswap.ServerFairness.IPv6PrefixLength", Value: node.DefaultServerFairnessIPv6PrefixLength},
+	{Key: "Internal.Bitswap.FilestoreReads.Concurrency", Value: node.DefaultFilestoreReadsConcurrency},
+	{Key: "Internal.Bitswap.FilestoreReads.ReadAhead", Value: node.DefaultFilestoreReadsReadAhead},
+	{Key: "Internal.UnixFSShardingSizeThreshold", Value: "256kiB"},
+
+	{Key: "Ipns.ResolveCacheSize", Value: node.DefaultIpnsCacheSize, zero: true},
+	{Key: "Ipns.PersistResolveCache", Value: config.DefaultIpnsPersistResolveCache},
+	{Key: "Ipns.RepublishThirdPartyPeriod", Value: durationDefault(config.DefaultIpnsRepublishThirdPartyPeriod)},
+	{Key: "Ipns.PublishQuorum", Value: config.DefaultIpnsPublishQuorum},
+	{Key: "Ipns.UsePubsub", Value: false},
+
+	{Key: "Logging.MaxFileSize", Value: config.DefaultLoggingMaxFileSize},
+	{Key: "Logging.RotateInterval", Value: durationDefault(config.DefaultLoggingRotateInterval)},
+	{Key: "Logging.MaxBackups", Value: config.DefaultLoggingMaxBackups},
+	{Key: "Logging.RateLimit.Enabled", Value: config.DefaultLoggingRateLimitEnabled},
+	{Key: "Logging.RateLimit.Interval", Value: durationDefault(config.DefaultLoggingRateLimitInterval)},
+	{Key: "Logging.RateLimit.Burst", Value: config.DefaultLoggingRateLimitBurst},
+	{Key: "Logging.SlowLog.Enabled", Value: config.DefaultLoggingSlowLogEnabled},
+	{Key: "Logging.SlowLog.APIThreshold", Value: durationDefault(config.DefaultLoggingSlowLogAPIThreshold)},
+	{Key: "Logging.SlowLog.GatewayThreshold", Value: durationDefault(config.DefaultLoggingSlowLogGatewayThreshold)},
+	{Key: "Logging.SlowLog.BlockFetchThreshold", Value: durationDefault(config.DefaultLoggingSlowLogBlockFetchThreshold)},
+	{Key: "Logging.SlowLog.RoutingThreshold", Value: durationDefault(config.DefaultLoggingSlowLogRoutingThreshold)},
+	{Key: "Logging.SlowLog.MaxEntries", Value: config.DefaultLoggingSlowLogMaxEntries},
+	{Key: "Logging.SlowLog.StackSampleRate", Value: config.DefaultLoggingSlowLogStackSampleRate},
+
+	{Key: "Pinning.ResumeInterrupted", Value: config.DefaultPinningResumeInterrupted},
+
+	{Key: "Probes.Retrieval.Enabled", Value: config.DefaultRetrievalProbeEnabled},
+	{Key: "Probes.Retrieval.Interval", Value: durationDefault(config.DefaultRetrievalProbeInterval)},
+	{Key: "Probes.Retrieval.Timeout", Value: durationDefault(config.DefaultRetrievalProbeTimeout)},
+
+	{Key: "Pubsub.Enabled", Value: false},
+	{Key: "Pubsub.SeenMessagesStrategy", Value: config.DefaultSeenMessagesStrategy},
+
+	{Key: "Reprovider.Interval", Value: durationDefault(config.DefaultReproviderInterval)},
+	{Key: "Reprovider.Strategy", Value: config.DefaultReproviderStrategy},
+	{Key: "Reprovider.ReactiveTTL", Value: durationDefault(config.DefaultReproviderReactiveTTL)},
+	{Key: "Reprovider.Filter.Base", Value: config.DefaultReproviderFilterBase},
+	{Key: "Reprovider.Filter.Timeout", Value: durationDefault(config.DefaultReproviderFilterTimeout)},
+
+	{Key: "Routing.Type", Value: "auto"},
+	{Key: "Routing.AcceleratedDHTClient", Value: config.DefaultAcceleratedDHTClient},
+	{Key: "Routing.LoopbackAddressesOnLanDHT", Value: config.DefaultLoopbackAddressesOnLanDHT},
+	{Key: "Routing.DelegatedPublishing", Value: config.DefaultDelegatedPublishing},
+	{Key: "Routing.PersistTable", Value: config.DefaultPersistRoutingTable},
+	{Key: "Routing.ServerModePressure.Enabled", Value: config.DefaultServerModePressure},
+	{Key: "Routing.ServerModePressure.CheckInterval", Value: durationDefault(config.DefaultServerModePressureCheck)},
+	{Key: "Routing.ServerModePressure.Cooldown", Value: durationDefault(config.DefaultServerModePressureCooldown)},
+
+	{Key: "Search.Enabled", Value: config.DefaultSearchEnabled},
+
+	{Key: "Security.Sandbox.Enabled", Value: config.DefaultSecuritySandboxEnabled},
+	{Key: "Security.KeyAudit.Enabled", Value: config.DefaultSecurityKeyAuditEnabled},
+	{Key: "Security.KeyAudit.Retention", Value: durationDefault(config.DefaultSecurityKeyAuditRetention)},
+
+	{Key: "Swarm.ConnMgr.Type", Value: config.DefaultConnMgrType},
+	{Key: "Swarm.ConnMgr.LowWater", Value: config.DefaultConnMgrLowWater},
+	{Key: "Swarm.ConnMgr.HighWater", Value: config.DefaultConnMgrHighWater},
+	{Key: "Swarm.ConnMgr.GracePeriod", Value: durationDefault(config.DefaultConnMgrGracePeriod)},
+	{Key: "Swarm.ResourceMgr.Enabled", Value: true},
+	{Key: "Swarm.Allowlist.Enabled", Value: config.DefaultSwarmAllowlistEnabled},
+	{Key: "Swarm.Allowlist.RefreshInterval", Value: durationDefault(config.DefaultSwarmAllowlistRefreshInterval)},
+	{Key: "Swarm.Peerstore.Persist", Value: config.DefaultPeerstorePersist},
+	{Key: "Swarm.Peerstore.Retention", Value: durationDefault(config.DefaultPeerstoreRetention)},
+	{Key: "Swarm.Peerstore.MaxPeers", Value: config.DefaultPeerstoreMaxPeers},
+	{Key: "Swarm.Peerstore.MinConfidence", Value: config.DefaultPeerstoreMinConfidence},
+	{Key: "Swarm.Peerstore.SaveInterval", Value: durationDefault(config.DefaultPeerstoreSaveInterval)},
+	{Key: "Swarm.Peerstore.ReconnectPeers", Value: config.DefaultPeerstoreReconnectPeers},
+}
+
+// ConfigDefaultsOutput is the output of 'ipfs config defaults'.
+type ConfigDefaultsOutput struct {
+	Config map[string]interface{}
+	// Defaults are the keys of Config set to their implicit default.
+	Defaults []string
+}
+
+var configDefaultsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Output the config with the implicit defaults filled in.",
+		ShortDescription: `
+Outputs the config file with the values used for the keys it leaves unset, such
+as the Internal.Bitswap worker counts, marked with "(default)". Defaults
+derived from the host, such as the resource manager limits, are not included.
+
+With --enc=json, the config is output as a JSON object, and the keys set to
+their default are listed separately.
+
+NOTE: like 'ipfs config show', this command omits your private key and remote
+services.
+`,
+	},
+	Type: ConfigDefaultsOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+		configFileOpt, _ := req.Options[ConfigFileOption].(string)
+		fname, err := config.Filename(cfgRoot, configFileOpt)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+
+		var cfg map[string]interface{}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return err
+		}
+		if cfg, err = scrubValue(cfg, []string{config.IdentityTag, config.PrivKeyTag}); err != nil {
+			return err
+		}
+		if cfg, err = scrubValue(cfg, []string{config.APITag, config.AuthorizationTag}); err != nil {
+			return err
+		}
+		if cfg, err = scrubOptionalValue(cfg, config.PinningConcealSelector); err != nil {
+			return err
+		}
+
+		out := &ConfigDefaultsOutput{Config: cfg}
+		for _, d := range configDefaults {
+			if setDefault(cfg, strings.Split(d.Key, "."), d) {
+				out.Defaults = append(out.Defaults, d.Key)
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ConfigDefaultsOutput) error {
+			defaults := make(map[string]bool, len(out.Defaults))
+			for _, k := range out.Defaults {
+				defaults[k] = true
+			}
+			return writeConfigLeaves(w, "", out.Config, defaults)
+		}),
+	},
+}
+
+// setDefault sets the key at path to the default d unless it is set, and
+// tells whether it did.
+func setDefault(m map[string]interface{}, path []string, d configDefault) bool {
+	v, ok := m[path[0]]
+	if len(path) > 1 {
+		sub, isMap := v.(map[string]interface{})
+		if !isMap {
+			if ok && v != nil {
+				return false
+			}
+			sub = map[string]interface{}{}
+			m[path[0]] = sub
+		}
+		return setDefault(sub, path[1:], d)
+	}
+	if ok && v != nil && !(d.zero && isZeroJSON(v)) {
+		return false
+	}
+	m[path[0]] = d.Value
+	return true
+}
+
+func isZeroJSON(v interface{}) bool {
+	n, ok := v.(float64)
+	return ok && n == 0
+}
+
+// writeConfigLeaves writes a "Key = value" line per value of m, sorted by key.
+func writeConfigLeaves(w io.Writer, prefix string, m map[string]interface{}, defaults map[string]bool) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := prefix + k
+		if sub, ok := m[k].(map[string]interface{}); ok && len(sub) > 0 {
+			if err := writeConfigLeaves(w, key+".", sub, defaults); err != nil {
+				return err
+			}
+			continue
+		}
+		val, err := json.Marshal(m[k])
+		if err != nil {
+			return err
+		}
+		if defaults[key] {
+			_, err = fmt.Fprintf(w, "%s = %s (default)\n", key, val)
+		} else {
+			_, err = fmt.Fprintf(w, "%s = %s\n", key, val)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
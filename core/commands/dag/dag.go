@@ -34,12 +34,14 @@ the existing 'ipfs object' command moving forward.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"put":     DagPutCmd,
-		"get":     DagGetCmd,
-		"resolve": DagResolveCmd,
-		"import":  DagImportCmd,
-		"export":  DagExportCmd,
-		"stat":    DagStatCmd,
+		"put":      DagPutCmd,
+		"get":      DagGetCmd,
+		"resolve":  DagResolveCmd,
+		"import":   DagImportCmd,
+		"export":   DagExportCmd,
+		"stat":     DagStatCmd,
+		"prefetch": DagPrefetchCmd,
+		"query":    DagQueryCmd,
 	},
 }
 
@@ -111,6 +113,10 @@ var DagGetCmd = &cmds.Command{
 		ShortDescription: `
 'ipfs dag get' fetches a DAG node from IPFS and prints it out in the specified
 format.
+
+The segments of the paths within dag-cbor and dag-json nodes are map keys and
+list indexes, such as /ipfs/<cid>/a/b/0. The '/' and '%' in map keys are
+escaped as %2F and %25.
 `,
 	},
 	Arguments: []cmds.Argument{
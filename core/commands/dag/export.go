@@ -1,7 +1,6 @@
 package dagcmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,16 +8,11 @@ import (
 	"time"
 
 	"github.com/cheggaaa/pb"
-	blocks "github.com/ipfs/go-block-format"
-	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
-	iface "github.com/ipfs/kubo/core/coreiface"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
-	gocar "github.com/ipld/go-car"
-	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
 )
 
 func dagExport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -33,13 +27,14 @@ func dagExport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 		return err
 	}
 
-	// Resolve path and confirm the root block is available, fail fast if not
-	b, err := api.Block().Stat(req.Context, p)
+	r, err := api.Dag().Export(req.Context, p)
 	if err != nil {
 		return err
 	}
-	c := b.Path().RootCid()
+	defer r.Close()
 
+	// relay through our own pipe so the walk error is reported once the
+	// response is drained, not whenever the consumer reads it
 	pipeR, pipeW := io.Pipe()
 
 	errCh := make(chan error, 2) // we only report the 1st error
@@ -51,12 +46,7 @@ func dagExport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 			close(errCh)
 		}()
 
-		store := dagStore{dag: api.Dag(), ctx: req.Context}
-		dag := gocar.Dag{Root: c, Selector: selectorparse.CommonSelector_ExploreAllRecursively}
-		// TraverseLinksOnlyOnce is safe for an exhaustive selector but won't be when we allow
-		// arbitrary selectors here
-		car := gocar.NewSelectiveCar(req.Context, store, []gocar.Dag{dag}, gocar.TraverseLinksOnlyOnce())
-		if err := car.Write(pipeW); err != nil {
+		if _, err := io.Copy(pipeW, r); err != nil {
 			errCh <- err
 		}
 	}()
@@ -66,21 +56,23 @@ func dagExport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 		return err
 	}
 
-	err = <-errCh
-
-	// minimal user friendliness
-	if ipld.IsNotFound(err) {
-		explicitOffline, _ := req.Options["offline"].(bool)
-		if explicitOffline {
-			err = fmt.Errorf("%s (currently offline, perhaps retry without the offline flag)", err)
-		} else {
-			node, envErr := cmdenv.GetNode(env)
-			if envErr == nil && !node.IsOnline {
-				err = fmt.Errorf("%s (currently offline, perhaps retry after attaching to the network)", err)
-			}
-		}
-	}
+	return explainExportError(req, env, <-errCh)
+}
 
+// explainExportError hints at the offline mode when a block is missing:
+// minimal user friendliness.
+func explainExportError(req *cmds.Request, env cmds.Environment, err error) error {
+	if err == nil || !ipld.IsNotFound(err) {
+		return err
+	}
+	explicitOffline, _ := req.Options["offline"].(bool)
+	if explicitOffline {
+		return fmt.Errorf("%s (currently offline, perhaps retry without the offline flag)", err)
+	}
+	node, envErr := cmdenv.GetNode(env)
+	if envErr == nil && !node.IsOnline {
+		return fmt.Errorf("%s (currently offline, perhaps retry after attaching to the network)", err)
+	}
 	return err
 }
 
@@ -138,13 +130,3 @@ func finishCLIExport(res cmds.Response, re cmds.ResponseEmitter) error {
 		}
 	}
 }
-
-// FIXME(@Jorropo): https://github.com/ipld/go-car/issues/315
-type dagStore struct {
-	dag iface.APIDagService
-	ctx context.Context
-}
-
-func (ds dagStore) Get(_ context.Context, c cid.Cid) (blocks.Block, error) {
-	return ds.dag.Get(ds.ctx, c)
-}
@@ -4,14 +4,13 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/ipfs/boxo/path"
 	ipldlegacy "github.com/ipfs/go-ipld-legacy"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/core/node"
 
 	"github.com/ipld/go-ipld-prime"
 	"github.com/ipld/go-ipld-prime/multicodec"
-	"github.com/ipld/go-ipld-prime/traversal"
 	mc "github.com/multiformats/go-multicodec"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -52,9 +51,7 @@ func dagGet(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) e
 	finalNode := universal.(ipld.Node)
 
 	if len(remainder) > 0 {
-		remainderPath := ipld.ParsePath(path.SegmentsToString(remainder...))
-
-		finalNode, err = traversal.Get(finalNode, remainderPath)
+		finalNode, err = node.LookupCodecPath(finalNode, rp, remainder)
 		if err != nil {
 			return err
 		}
@@ -1,18 +1,15 @@
 package dagcmd
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"io"
 
 	"github.com/ipfs/boxo/files"
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	cmds "github.com/ipfs/go-ipfs-cmds"
-	ipld "github.com/ipfs/go-ipld-format"
 	ipldlegacy "github.com/ipfs/go-ipld-legacy"
 	"github.com/ipfs/kubo/core/coreiface/options"
-	gocarv2 "github.com/ipld/go-car/v2"
 
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
@@ -52,24 +49,19 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 		defer unlocker.Unlock(req.Context)
 	}
 
-	// this is *not* a transaction
-	// it is simply a way to relieve pressure on the blockstore
-	// similar to pinner.Pin/pinner.Flush
-	batch := ipld.NewBatch(req.Context, api.Dag())
-
 	roots := cid.NewSet()
 	var blockCount, blockBytesCount uint64
 
-	// remember last valid block and provide a meaningful error message
-	// when a truncated/mangled CAR is being imported
-	importError := func(previous blocks.Block, current blocks.Block, err error) error {
-		if current != nil {
-			return fmt.Errorf("import failed at block %q: %w", current.Cid(), err)
+	// the blocks are checked and indexed as they are imported, and the roots
+	// are only pinned once all the files are
+	onBlock := func(ctx context.Context, block blocks.Block) error {
+		if err := cmdutils.CheckBlockSize(req, uint64(len(block.RawData()))); err != nil {
+			return err
 		}
-		if previous != nil {
-			return fmt.Errorf("import failed after block %q: %w", previous.Cid(), err)
+		if node.DagIndex != nil {
+			return node.DagIndex.Index(ctx, block)
 		}
-		return fmt.Errorf("import failed: %w", err)
+		return nil
 	}
 
 	it := req.Files.Entries()
@@ -79,60 +71,23 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 			return errors.New("expected a file handle")
 		}
 
-		// import blocks
-		err = func() error {
-			// wrap a defer-closer-scope
-			//
-			// every single file in it() is already open before we start
-			// just close here sooner rather than later for neatness
-			// and to surface potential errors writing on closed fifos
-			// this won't/can't help with not running out of handles
-			defer file.Close()
-
-			var previous blocks.Block
-
-			car, err := gocarv2.NewBlockReader(file)
-			if err != nil {
-				return err
-			}
-
-			for _, c := range car.Roots {
-				roots.Add(c)
-			}
-
-			for {
-				block, err := car.Next()
-				if err != nil && err != io.EOF {
-					return importError(previous, block, err)
-				} else if block == nil {
-					break
-				}
-				if err := cmdutils.CheckBlockSize(req, uint64(len(block.RawData()))); err != nil {
-					return importError(previous, block, err)
-				}
-
-				// the double-decode is suboptimal, but we need it for batching
-				nd, err := blockDecoder.DecodeNode(req.Context, block)
-				if err != nil {
-					return importError(previous, block, err)
-				}
-
-				if err := batch.Add(req.Context, nd); err != nil {
-					return importError(previous, block, err)
-				}
-				blockCount++
-				blockBytesCount += uint64(len(block.RawData()))
-				previous = block
-			}
-			return nil
-		}()
+		// every single file in it() is already open before we start
+		// just close here sooner rather than later for neatness
+		// and to surface potential errors writing on closed fifos
+		// this won't/can't help with not running out of handles
+		imported, err := api.Dag().Import(req.Context, file, options.Dag.PinRoots(false), options.Dag.OnBlock(onBlock))
+		file.Close()
 		if err != nil {
 			return err
 		}
+		for _, root := range imported.Roots {
+			roots.Add(root.Cid)
+		}
+		blockCount += imported.BlockCount
+		blockBytesCount += imported.BlockBytesCount
 	}
-
-	if err := batch.Commit(); err != nil {
-		return err
+	if it.Err() != nil {
+		return it.Err()
 	}
 
 	// It is not guaranteed that a root in a header is actually present in the same ( or any )
@@ -142,19 +97,35 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 
 	// opportunistic pinning: try whatever sticks
 	if doPinRoots {
+		quotaUser, pinQuota := node.RPCQuotas.PinQuotaUser(req.Context)
 		err = roots.ForEach(func(c cid.Cid) error {
 			ret := RootMeta{Cid: c}
 
 			// This will trigger a full read of the DAG in the pinner, to make sure we have all blocks.
 			// Ideally we would do colloring of the pinning state while importing the blocks
 			// and ensure the gray bucket is empty at the end (or use the network to download missing blocks).
-			if block, err := node.Blockstore.Get(req.Context, c); err != nil {
-				ret.PinErrorMsg = err.Error()
-			} else if nd, err := blockDecoder.DecodeNode(req.Context, block); err != nil {
-				ret.PinErrorMsg = err.Error()
-			} else if err := node.Pinning.Pin(req.Context, nd, true, ""); err != nil {
-				ret.PinErrorMsg = err.Error()
-			} else if err := node.Pinning.Flush(req.Context); err != nil {
+			pin := func(ctx context.Context) error {
+				block, err := node.Blockstore.Get(ctx, c)
+				if err != nil {
+					return err
+				}
+				nd, err := blockDecoder.DecodeNode(ctx, block)
+				if err != nil {
+					return err
+				}
+				if err := node.Pinning.Pin(ctx, nd, true, ""); err != nil {
+					return err
+				}
+				return node.Pinning.Flush(ctx)
+			}
+			// the roots pinned with a token are charged against its quota
+			var err error
+			if pinQuota {
+				err = node.RPCQuotas.ChargePins(req.Context, quotaUser, []cid.Cid{c}, pin)
+			} else {
+				err = pin(req.Context)
+			}
+			if err != nil {
 				ret.PinErrorMsg = err.Error()
 			}
 
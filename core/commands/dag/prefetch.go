@@ -0,0 +1,154 @@
+package dagcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	humanize "github.com/dustin/go-humanize"
+	mdag "github.com/ipfs/boxo/ipld/merkledag"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	prefetchDepthOptionName    = "depth"
+	prefetchSessionsOptionName = "sessions"
+
+	defaultPrefetchSessions = 4
+)
+
+// DagPrefetchOutput is the output type of the 'dag prefetch' command.
+type DagPrefetchOutput struct {
+	Blocks int
+	Size   uint64
+	// Depth is the depth of the deepest blocks fetched, the root being at
+	// depth 0.
+	Depth int
+}
+
+// DagPrefetchCmd is a command for fetching the blocks of a DAG into the
+// blockstore
+var DagPrefetchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Fetch the blocks of a DAG into the local blockstore.",
+		ShortDescription: `
+'ipfs dag prefetch' fetches the blocks of the DAG rooted at the given CID, down
+to --depth, without pinning them, for example to warm the cache of a gateway.
+The blocks are kept until the next garbage collection.
+
+The DAG is fetched level by level, each level being split between --sessions
+Bitswap sessions fetching in parallel. Blocks linked several times are only
+fetched once.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "CID or path of the DAG to prefetch."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(prefetchDepthOptionName, "Depth of the links to follow, -1 for the whole DAG.").WithDefault(-1),
+		cmds.IntOption(prefetchSessionsOptionName, "Number of Bitswap sessions fetching in parallel.").WithDefault(defaultPrefetchSessions),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		depth, _ := req.Options[prefetchDepthOptionName].(int)
+		sessions, _ := req.Options[prefetchSessionsOptionName].(int)
+		if sessions < 1 {
+			return cmds.Errorf(cmds.ErrClient, "--%s must be at least 1", prefetchSessionsOptionName)
+		}
+
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, _, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		getters := make([]ipld.NodeGetter, sessions)
+		for i := range getters {
+			getters[i] = mdag.NewSession(req.Context, api.Dag())
+		}
+		out, err := prefetchDAG(req.Context, getters, rp.RootCid(), depth)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DagPrefetchOutput) error {
+			_, err := fmt.Fprintf(w, "prefetched %d blocks (%s) to depth %d\n", out.Blocks, humanize.Bytes(out.Size), out.Depth)
+			return err
+		}),
+	},
+	Type: DagPrefetchOutput{},
+}
+
+// prefetchDAG fetches the DAG rooted at root down to maxDepth, or entirely
+// when maxDepth is negative. Each level is split between the getters.
+func prefetchDAG(ctx context.Context, getters []ipld.NodeGetter, root cid.Cid, maxDepth int) (*DagPrefetchOutput, error) {
+	out := &DagPrefetchOutput{}
+	seen := cid.NewSet()
+	seen.Add(root)
+	level := []cid.Cid{root}
+
+	for depth := 0; len(level) > 0; depth++ {
+		var (
+			mu   sync.Mutex
+			next []cid.Cid
+		)
+		g, gctx := errgroup.WithContext(ctx)
+		for i, getter := range getters {
+			var share []cid.Cid
+			for j := i; j < len(level); j += len(getters) {
+				share = append(share, level[j])
+			}
+			if len(share) == 0 {
+				break
+			}
+			getter := getter
+			g.Go(func() error {
+				got := 0
+				for opt := range getter.GetMany(gctx, share) {
+					if opt.Err != nil {
+						return opt.Err
+					}
+					got++
+					mu.Lock()
+					out.Blocks++
+					out.Size += uint64(len(opt.Node.RawData()))
+					if maxDepth < 0 || depth < maxDepth {
+						for _, l := range opt.Node.Links() {
+							if seen.Visit(l.Cid) {
+								next = append(next, l.Cid)
+							}
+						}
+					}
+					mu.Unlock()
+				}
+				if got < len(share) {
+					if err := gctx.Err(); err != nil {
+						return err
+					}
+					return fmt.Errorf("fetched %d of %d blocks at depth %d", got, len(share), depth)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		out.Depth = depth
+		level = next
+	}
+	return out, nil
+}
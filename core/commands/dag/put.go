@@ -2,6 +2,7 @@ package dagcmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 
 	blocks "github.com/ipfs/go-block-format"
@@ -14,6 +15,8 @@ import (
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
 
 	"github.com/ipfs/boxo/files"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/path"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	ipld "github.com/ipfs/go-ipld-format"
 	mc "github.com/multiformats/go-multicodec"
@@ -81,10 +84,15 @@ func dagPut(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) e
 		return err
 	}
 
+	// the pins made with a token are charged against its quota once the
+	// nodes are added
+	quotaUser, pinQuota := nd.RPCQuotas.PinQuotaUser(req.Context)
+	pinQuota = pinQuota && dopin
 	var adder ipld.NodeAdder = api.Dag()
-	if dopin {
+	if dopin && !pinQuota {
 		adder = api.Dag().Pinning()
 	}
+	var added []cid.Cid
 	b := ipld.NewBatch(req.Context, adder)
 
 	it := req.Files.Entries()
@@ -125,8 +133,14 @@ func dagPut(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) e
 		if err := b.Add(req.Context, &ln); err != nil {
 			return err
 		}
+		if nd.DagIndex != nil {
+			if err := nd.DagIndex.Index(req.Context, blk); err != nil {
+				return err
+			}
+		}
 
 		cid := ln.Cid()
+		added = append(added, cid)
 		if err := res.Emit(&OutputObject{Cid: cid}); err != nil {
 			return err
 		}
@@ -139,5 +153,21 @@ func dagPut(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) e
 		return err
 	}
 
+	if pinQuota {
+		// like 'ipfs pin add', the DAGs have to be local to be charged
+		for _, c := range added {
+			if err := dag.FetchGraph(req.Context, c, api.Dag()); err != nil {
+				return err
+			}
+		}
+		return nd.RPCQuotas.ChargePins(req.Context, quotaUser, added, func(ctx context.Context) error {
+			for _, c := range added {
+				if err := api.Pin().Add(ctx, path.FromCid(c)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
 	return nil
 }
@@ -0,0 +1,65 @@
+package dagcmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+// DagQueryCmd queries the index of the fields of the documents imported.
+var DagQueryCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Find the dag-cbor and dag-json documents by the value of their fields.",
+		ShortDescription: `
+'ipfs dag query' prints the CIDs of the documents imported with 'ipfs dag put'
+and 'ipfs dag import' matching all the terms of the query, from the index of
+the fields selected with DagIndex.Fields kept with DagIndex.Enabled.
+
+A term is a list of words searched in any of the indexed fields, or
+"field:words" to search them in one of them only. Words are matched ignoring
+case, and a link matches the CID it points to.
+
+  > ipfs config --json DagIndex.Fields '["title", "authors/*/name"]'
+  > ipfs dag query 'title:bridge design' 'authors/*/name:ammann'
+  bafyreihhhtxc5vuc4dnqsoh2xcsc3nkz3sdjp2pjtnzeq2yzpbhxbeevsq
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("query", true, true, "Terms the documents must all match."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.DagIndex == nil {
+			return errors.New("the documents are only indexed with DagIndex.Enabled set")
+		}
+
+		found, err := nd.DagIndex.Query(req.Context, req.Arguments)
+		if err != nil {
+			return err
+		}
+		for _, c := range found {
+			if err := res.Emit(&OutputObject{Cid: c}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: OutputObject{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *OutputObject) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, enc.Encode(out.Cid))
+			return nil
+		}),
+	},
+}
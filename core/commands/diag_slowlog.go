@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+const (
+	slowLogSinceOptionName  = "since"
+	slowLogKindOptionName   = "kind"
+	slowLogStacksOptionName = "stacks"
+)
+
+type slowLogOutput struct {
+	Operations []node.SlowOperation
+}
+
+var sysSlowLogCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the operations that exceeded their latency threshold.",
+		ShortDescription: `
+'ipfs diag slowlog' lists the API and gateway requests, block fetches and
+routing operations of the daemon that took longer than their threshold in
+Logging.SlowLog, oldest first. The stacks of the goroutines of some of them,
+captured when they reached their threshold, are shown with --stacks.
+
+Only the operations that ended are listed, and only the most recent
+Logging.SlowLog.MaxEntries are kept.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(slowLogSinceOptionName, "Only list the operations started in this past duration, like 1h."),
+		cmds.StringOption(slowLogKindOptionName, "Only list the operations of this kind: api, gateway, blockfetch or routing."),
+		cmds.BoolOption(slowLogStacksOptionName, "Show the stacks captured for the operations."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.SlowLog == nil {
+			return errors.New("the slow log is only available while the daemon runs with Logging.SlowLog.Enabled")
+		}
+
+		var since time.Time
+		if s, _ := req.Options[slowLogSinceOptionName].(string); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", slowLogSinceOptionName, err)
+			}
+			since = time.Now().Add(-d)
+		}
+		kind, _ := req.Options[slowLogKindOptionName].(string)
+		switch kind {
+		case "", node.SlowOpAPI, node.SlowOpGateway, node.SlowOpBlockFetch, node.SlowOpRouting:
+		default:
+			return fmt.Errorf("invalid --%s %q: must be api, gateway, blockfetch or routing", slowLogKindOptionName, kind)
+		}
+
+		out := &slowLogOutput{Operations: []node.SlowOperation{}}
+		for _, op := range n.SlowLog.Operations(since) {
+			if kind == "" || op.Kind == kind {
+				out.Operations = append(out.Operations, op)
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: slowLogOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *slowLogOutput) error {
+			stacks, _ := req.Options[slowLogStacksOptionName].(bool)
+			for _, op := range out.Operations {
+				fmt.Fprintf(w, "%s %-10s %8s %s", op.Started.Format(time.RFC3339), op.Kind, op.Duration.Truncate(time.Millisecond), op.Operation)
+				if op.Error != "" {
+					fmt.Fprintf(w, " (%s)", op.Error)
+				}
+				fmt.Fprintln(w)
+				if stacks && op.Stacks != "" {
+					for _, line := range strings.Split(strings.TrimRight(op.Stacks, "\n"), "\n") {
+						fmt.Fprintf(w, "    %s\n", line)
+					}
+				}
+			}
+			return nil
+		}),
+	},
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+var DNSCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the DNS resolution of the daemon.",
+		ShortDescription: `
+The DNS lookups of DNSLink names and /dns* multiaddrs go through the
+resolvers of DNS.Resolvers, and are cached for DNS.CacheTTL.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"purge": dnsPurgeCmd,
+	},
+}
+
+type dnsPurgeOutput struct {
+	Purged int
+}
+
+var dnsPurgeCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove lookups from the DNS cache.",
+		ShortDescription: `
+'ipfs dns purge' removes the cached lookups of the given domains and of their
+subdomains, such as the _dnslink subdomain of a DNSLink name, so that the next
+resolution reads the updated records:
+
+  > ipfs dns purge example.com
+  Purged 2 lookups
+
+Without domains, every cached lookup is removed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("domain", false, true, "Domains whose lookups are removed."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.DNSCache == nil {
+			return errors.New("the DNS cache is disabled with DNS.CacheSize set to 0")
+		}
+		return cmds.EmitOnce(res, &dnsPurgeOutput{Purged: n.DNSCache.Purge(req.Arguments...)})
+	},
+	Type: dnsPurgeOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *dnsPurgeOutput) error {
+			_, err := fmt.Fprintf(w, "Purged %d lookups\n", out.Purged)
+			return err
+		}),
+	},
+}
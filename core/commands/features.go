@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/repo/fsrepo"
+)
+
+type featureOutput struct {
+	Name        string
+	Description string
+	Stability   string
+	Enabled     bool
+	Runtime     bool
+	Requires    []string `json:",omitempty"`
+}
+
+type featuresOutput struct {
+	Features []featureOutput
+}
+
+var FeaturesCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List and toggle the experimental features.",
+		ShortDescription: `
+The experimental features are the flags of the Experimental section of the
+config. 'ipfs features ls' lists them with their stability and their state,
+and 'ipfs features enable' and 'ipfs features disable' toggle them after
+checking the features they require.
+
+The runtime features take effect right away when the daemon is running, the
+others on its next start.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":      featuresLsCmd,
+		"enable":  featuresToggleCmd(true),
+		"disable": featuresToggleCmd(false),
+	},
+}
+
+var featuresLsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the experimental features and their state.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+		r, err := fsrepo.Open(cfgRoot)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			return err
+		}
+
+		out := &featuresOutput{Features: make([]featureOutput, 0, len(config.Features))}
+		for _, f := range config.Features {
+			out.Features = append(out.Features, featureOutput{
+				Name:        f.Name,
+				Description: f.Description,
+				Stability:   f.Stability,
+				Enabled:     f.Enabled(&cfg.Experimental),
+				Runtime:     f.Runtime,
+				Requires:    f.Requires,
+			})
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: featuresOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *featuresOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tSTABILITY\tSTATE\tTAKES EFFECT\tREQUIRES")
+			for _, f := range out.Features {
+				state, effect := "disabled", "on restart"
+				if f.Enabled {
+					state = "enabled"
+				}
+				if f.Runtime {
+					effect = "at runtime"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Name, f.Stability, state, effect, strings.Join(f.Requires, ", "))
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+type featureToggleOutput struct {
+	Name    string
+	Enabled bool
+	Runtime bool
+}
+
+func featuresToggleCmd(enable bool) *cmds.Command {
+	verb := "Disable"
+	if enable {
+		verb = "Enable"
+	}
+	return &cmds.Command{
+		Status: cmds.Experimental,
+		Helptext: cmds.HelpText{
+			Tagline: verb + " an experimental feature.",
+		},
+		Arguments: []cmds.Argument{
+			cmds.StringArg("name", true, false, "The name of the feature, as listed by 'ipfs features ls'."),
+		},
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			f, ok := config.FeatureByName(req.Arguments[0])
+			if !ok {
+				return cmds.Errorf(cmds.ErrClient, "unknown feature %q, see 'ipfs features ls'", req.Arguments[0])
+			}
+
+			cfgRoot, err := cmdenv.GetConfigRoot(env)
+			if err != nil {
+				return err
+			}
+			r, err := fsrepo.Open(cfgRoot)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			cfg, err := r.Config()
+			if err != nil {
+				return err
+			}
+			if err := config.CheckFeatureToggle(&cfg.Experimental, f, enable); err != nil {
+				return cmds.Errorf(cmds.ErrClient, err.Error())
+			}
+			if err := r.SetConfigKey("Experimental."+f.Name, enable); err != nil {
+				return err
+			}
+			return cmds.EmitOnce(res, &featureToggleOutput{Name: f.Name, Enabled: enable, Runtime: f.Runtime})
+		},
+		Type: featureToggleOutput{},
+		Encoders: cmds.EncoderMap{
+			cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *featureToggleOutput) error {
+				state := "disabled"
+				if out.Enabled {
+					state = "enabled"
+				}
+				if !out.Runtime {
+					state += ", restart the daemon for the change to take effect"
+				}
+				_, err := fmt.Fprintf(w, "Experimental.%s %s\n", out.Name, state)
+				return err
+			}),
+		},
+	}
+}
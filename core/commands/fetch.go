@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/core/node"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	fetchPlanNumProvidersOptionName = "num-providers"
+	fetchPlanTimeoutOptionName      = "probe-timeout"
+	fetchPlanVerboseOptionName      = "verbose"
+)
+
+const (
+	defaultFetchPlanNumProviders = 20
+	defaultFetchPlanTimeout      = "10s"
+)
+
+var FetchCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Plan the retrieval of DAGs.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"plan": fetchPlanCmd,
+	},
+}
+
+var fetchPlanCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Estimate the retrieval of a DAG without fetching it.",
+		ShortDescription: `
+'ipfs fetch plan' asks the providers of a DAG and the connected peers which
+subtrees of its root they have, with Bitswap want-have requests only, and
+reports how the retrieval would be spread over them: the peers each subtree
+would be fetched from, the number of peers fetched from in parallel, and the
+bytes no peer has. The size of large retrievals can be predicted before
+committing bandwidth to them:
+
+  > ipfs fetch plan bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi
+  Root:         bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi
+  Size:         1.2 GiB in 12 subtrees
+  Probed:       23 peers, 4 have the root
+  Parallelism:  4 peers
+  Missing:      0 B
+
+  PEER                                                  HAS           PLANNED
+  12D3KooWHHzSeKaY8xuZVzkLbKFfvNgPPeKhFBGrMbNzbm5akpqu  12 (1.2 GiB)  3 (310 MiB)
+  ...
+
+The root block is fetched, when it is not in the repo, to list its subtrees,
+and the cumulative sizes of the subtrees are the ones of its links. The peers
+that do not answer within --probe-timeout are counted as not having the
+blocks. Use --verbose to list the peers of each subtree.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "Path or CID of the root of the DAG."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(fetchPlanNumProvidersOptionName, "n", "Number of providers of the root to probe, besides the connected peers.").WithDefault(defaultFetchPlanNumProviders),
+		cmds.StringOption(fetchPlanTimeoutOptionName, "Time to find the providers and wait for their answers.").WithDefault(defaultFetchPlanTimeout),
+		cmds.BoolOption(fetchPlanVerboseOptionName, "v", "List the subtrees and the peers that have them."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.FetchPlanner == nil {
+			return errors.New("fetch planning requires Bitswap over libp2p, see Bitswap.Enabled and Bitswap.Libp2pEnabled")
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		numProviders, _ := req.Options[fetchPlanNumProvidersOptionName].(int)
+		if numProviders < 0 {
+			return cmds.Errorf(cmds.ErrClient, "--%s must not be negative", fetchPlanNumProvidersOptionName)
+		}
+		timeoutStr, _ := req.Options[fetchPlanTimeoutOptionName].(string)
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			return cmds.Errorf(cmds.ErrClient, "invalid --%s %q", fetchPlanTimeoutOptionName, timeoutStr)
+		}
+
+		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, _, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+		root := rp.RootCid()
+		rootNode, err := nd.DAG.Get(req.Context, root)
+		if err != nil {
+			return err
+		}
+		var subtrees []node.FetchPlanSubtree
+		cids := []cid.Cid{root}
+		for _, l := range rootNode.Links() {
+			subtrees = append(subtrees, node.FetchPlanSubtree{Cid: l.Cid, Size: l.Size})
+			cids = append(cids, l.Cid)
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context, timeout)
+		defer cancel()
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			haves = make(map[peer.ID]map[cid.Cid]bool)
+		)
+		probe := func(ai peer.AddrInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if ai.ID == nd.Identity || haves[ai.ID] != nil {
+				return
+			}
+			haves[ai.ID] = map[cid.Cid]bool{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				have, err := nd.FetchPlanner.Probe(ctx, ai, cids)
+				if err != nil {
+					log.Debugf("fetch plan: probing %s: %s", ai.ID, err)
+					return
+				}
+				mu.Lock()
+				haves[ai.ID] = have
+				mu.Unlock()
+			}()
+		}
+		for _, p := range nd.PeerHost.Network().Peers() {
+			probe(peer.AddrInfo{ID: p})
+		}
+		if numProviders > 0 {
+			for ai := range nd.Routing.FindProvidersAsync(ctx, root, numProviders) {
+				probe(ai)
+			}
+		}
+		wg.Wait()
+
+		plan := node.PlanFetch(root, uint64(len(rootNode.RawData())), subtrees, haves)
+		return cmds.EmitOnce(res, plan)
+	},
+	Type: node.FetchPlan{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, plan *node.FetchPlan) error {
+			verbose, _ := req.Options[fetchPlanVerboseOptionName].(bool)
+			tw := tabwriter.NewWriter(w, 1, 2, 2, ' ', 0)
+			fmt.Fprintf(tw, "Root:\t%s\n", plan.Root)
+			fmt.Fprintf(tw, "Size:\t%s in %d subtrees\n", humanize.IBytes(plan.TotalBytes), len(plan.Subtrees))
+			fmt.Fprintf(tw, "Probed:\t%d peers, %d have the root\n", plan.Probed, len(plan.RootPeers))
+			fmt.Fprintf(tw, "Parallelism:\t%d peers\n", plan.Parallelism)
+			fmt.Fprintf(tw, "Missing:\t%s\n", humanize.IBytes(plan.MissingBytes))
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if len(plan.Peers) > 0 {
+				fmt.Fprintln(w)
+				fmt.Fprintln(tw, "PEER\tHAS\tPLANNED")
+				for _, p := range plan.Peers {
+					fmt.Fprintf(tw, "%s\t%d (%s)\t%d (%s)\n", p.ID, p.Subtrees, humanize.IBytes(p.Bytes), p.PlannedSubtrees, humanize.IBytes(p.PlannedBytes))
+				}
+				if err := tw.Flush(); err != nil {
+					return err
+				}
+			}
+			if verbose && len(plan.Subtrees) > 0 {
+				fmt.Fprintln(w)
+				fmt.Fprintln(tw, "SUBTREE\tSIZE\tPEERS\tPLANNED FROM")
+				for _, st := range plan.Subtrees {
+					from := "-"
+					if st.Peer != "" {
+						from = st.Peer.String()
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", st.Cid, humanize.IBytes(st.Size), len(st.Peers), from)
+				}
+				return tw.Flush()
+			}
+			return nil
+		}),
+	},
+}
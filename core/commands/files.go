@@ -19,6 +19,7 @@ import (
 	offline "github.com/ipfs/boxo/exchange/offline"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
 	ft "github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
 	mfs "github.com/ipfs/boxo/mfs"
 	"github.com/ipfs/boxo/path"
 	cid "github.com/ipfs/go-cid"
@@ -447,6 +448,7 @@ type filesLsOutput struct {
 const (
 	longOptionName     = "long"
 	dontSortOptionName = "U"
+	filesDuOptionName  = "du"
 )
 
 var filesLsCmd = &cmds.Command{
@@ -468,6 +470,22 @@ Examples:
     $ ipfs files ls /myfiles/a/b/c/d
     foo
     bar
+
+With --recursive, the whole tree below the directory is listed with paths
+relative to it. Entries are streamed in directory order as they are read, so
+large trees can be listed without holding them in memory; use --enc=json to
+get one JSON object per line.
+
+With --du, the size of every directory is the cumulative size of the files
+below it, and directories are listed after their content:
+
+    $ ipfs files ls --recursive --du /myfiles
+    4	a/b/c/d/foo
+    6	a/b/c/d/bar
+    10	a/b/c/d
+    10	a/b/c
+    10	a/b
+    10	a
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -476,6 +494,8 @@ Examples:
 	Options: []cmds.Option{
 		cmds.BoolOption(longOptionName, "l", "Use long listing format."),
 		cmds.BoolOption(dontSortOptionName, "Do not sort; list entries in directory order."),
+		cmds.BoolOption(recursiveOptionName, "R", "List subdirectories recursively, streaming the entries."),
+		cmds.BoolOption(filesDuOptionName, "Show the cumulative size of the files below each directory."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		var arg string
@@ -502,12 +522,25 @@ Examples:
 		}
 
 		long, _ := req.Options[longOptionName].(bool)
+		recursive, _ := req.Options[recursiveOptionName].(bool)
+		du, _ := req.Options[filesDuOptionName].(bool)
 
 		enc, err := cmdenv.GetCidEncoder(req)
 		if err != nil {
 			return err
 		}
 
+		if dir, ok := fsn.(*mfs.Directory); ok && (recursive || du) {
+			dirNode, err := dir.GetNode()
+			if err != nil {
+				return err
+			}
+			_, err = walkFilesLs(req.Context, nd.DAG, dirNode, "", recursive, du, enc, func(entry mfs.NodeListing) error {
+				return res.Emit(&filesLsOutput{[]mfs.NodeListing{entry}})
+			})
+			return err
+		}
+
 		switch fsn := fsn.(type) {
 		case *mfs.Directory:
 			if !long {
@@ -532,7 +565,7 @@ Examples:
 		case *mfs.File:
 			_, name := gopath.Split(path)
 			out := &filesLsOutput{[]mfs.NodeListing{{Name: name}}}
-			if long {
+			if long || du {
 				out.Entries[0].Type = int(fsn.Type())
 
 				size, err := fsn.Size()
@@ -540,6 +573,8 @@ Examples:
 					return err
 				}
 				out.Entries[0].Size = size
+			}
+			if long {
 
 				nd, err := fsn.GetNode()
 				if err != nil {
@@ -562,12 +597,15 @@ Examples:
 			}
 
 			long, _ := req.Options[longOptionName].(bool)
+			du, _ := req.Options[filesDuOptionName].(bool)
 			for _, o := range out.Entries {
 				if long {
 					if o.Type == int(mfs.TDir) {
 						o.Name += "/"
 					}
 					fmt.Fprintf(w, "%s\t%s\t%d\n", o.Name, o.Hash, o.Size)
+				} else if du {
+					fmt.Fprintf(w, "%d\t%s\n", o.Size, o.Name)
 				} else {
 					fmt.Fprintf(w, "%s\n", o.Name)
 				}
@@ -579,6 +617,68 @@ Examples:
 	Type: filesLsOutput{},
 }
 
+// walkFilesLs lists the unixfs directory nd, naming entries relative to the
+// listed directory. Subdirectories are only passed to emit when recursive is
+// set. With du, directories come after their content with the cumulative
+// size of the files below them, which walkFilesLs returns for nd.
+func walkFilesLs(ctx context.Context, dagserv ipld.DAGService, nd ipld.Node, prefix string, recursive, du bool, enc cidenc.Encoder, emit func(mfs.NodeListing) error) (int64, error) {
+	dir, err := uio.NewDirectoryFromNode(dagserv, nd)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	err = dir.ForEachLink(ctx, func(l *ipld.Link) error {
+		child, err := l.GetNode(ctx, dagserv)
+		if err != nil {
+			return err
+		}
+		entry := mfs.NodeListing{
+			Name: gopath.Join(prefix, l.Name),
+			Type: int(mfs.TFile),
+			Hash: enc.Encode(l.Cid),
+		}
+
+		switch child := child.(type) {
+		case *dag.RawNode:
+			entry.Size = int64(len(child.RawData()))
+		case *dag.ProtoNode:
+			fsNode, err := ft.FSNodeFromBytes(child.Data())
+			if err != nil {
+				return err
+			}
+			switch fsNode.Type() {
+			case ft.TDirectory, ft.THAMTShard:
+				entry.Type = int(mfs.TDir)
+				if !du {
+					// only reached when recursive: list the directory before its content
+					if err := emit(entry); err != nil {
+						return err
+					}
+					_, err := walkFilesLs(ctx, dagserv, child, entry.Name, recursive, du, enc, emit)
+					return err
+				}
+				childEmit := emit
+				if !recursive {
+					childEmit = func(mfs.NodeListing) error { return nil }
+				}
+				entry.Size, err = walkFilesLs(ctx, dagserv, child, entry.Name, recursive, du, enc, childEmit)
+				if err != nil {
+					return err
+				}
+			default:
+				entry.Size = int64(fsNode.FileSize())
+			}
+		default:
+			return fmt.Errorf("%s: unsupported node type %T", entry.Name, child)
+		}
+
+		total += entry.Size
+		return emit(entry)
+	})
+	return total, err
+}
+
 const (
 	filesOffsetOptionName = "offset"
 	filesCountOptionName  = "count"
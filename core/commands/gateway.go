@@ -0,0 +1,350 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/path/resolver"
+	"github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/core/node"
+)
+
+var GatewayCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the HTTP gateway of the daemon.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"denylist":  gatewayDenylistCmd,
+		"dirindex":  gatewayDirIndexCmd,
+		"redirects": gatewayRedirectsCmd,
+	},
+}
+
+var gatewayDenylistCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the denylists of the gateway hostnames.",
+		ShortDescription: `
+Each hostname of Gateway.PublicGateways can block content with its own
+denylist files, listed in its Denylists, in addition to the denylists
+directories which apply to the whole node. The files are reloaded when they
+change.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status": gatewayDenylistStatusCmd,
+	},
+}
+
+type gatewayDenylistStatusOutput struct {
+	Denylists []node.GatewayDenylistStatus
+}
+
+var gatewayDenylistStatusCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the denylists of the gateway hostnames and their matches.",
+		ShortDescription: `
+Lists every denylist of the Gateway.PublicGateways hostnames with the number
+of rules loaded, the requests it blocked and allowed since the daemon started,
+and when it was last loaded. When a change of a file could not be loaded, its
+previous rules are still applied and the error is shown.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		out := &gatewayDenylistStatusOutput{Denylists: []node.GatewayDenylistStatus{}}
+		if nd.GatewayDenylists != nil {
+			out.Denylists = nd.GatewayDenylists.Status()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *gatewayDenylistStatusOutput) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+
+			fmt.Fprintln(tw, "HOSTNAME\tFILE\tENTRIES\tBLOCKED\tALLOWED\tLOADED\tSTATUS")
+			for _, d := range out.Denylists {
+				status := "ok"
+				if d.Error != "" {
+					status = "error: " + d.Error
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n",
+					d.Hostname,
+					d.File,
+					d.Entries,
+					d.Blocked,
+					d.Allowed,
+					d.Loaded.Format(time.DateTime),
+					status,
+				)
+			}
+			return nil
+		}),
+	},
+	Type: gatewayDenylistStatusOutput{},
+}
+
+var gatewayDirIndexCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect and purge the directory index cache of the gateway.",
+		ShortDescription: `
+With Gateway.DirectoryIndexCache enabled, the entries of the directories of at
+least MinEntries entries listed by the gateway are saved to the datastore, so
+they are listed again without enumerating the directory, including after a
+restart. The cache holds at most MaxSize bytes.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":    gatewayDirIndexLsCmd,
+		"purge": gatewayDirIndexPurgeCmd,
+	},
+}
+
+var errGatewayDirIndexDisabled = errors.New("the directory index cache is only available with Gateway.DirectoryIndexCache enabled")
+
+type gatewayDirIndexList struct {
+	Directories []node.GatewayDirectoryIndexEntry
+}
+
+type gatewayDirIndexPurge struct {
+	Purged int
+}
+
+var gatewayDirIndexLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the cached directories, the most recently listed first.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.GatewayDirectoryIndex == nil {
+			return errGatewayDirIndexDisabled
+		}
+		return cmds.EmitOnce(res, &gatewayDirIndexList{Directories: nd.GatewayDirectoryIndex.List()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *gatewayDirIndexList) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+
+			fmt.Fprintln(tw, "CID\tENTRIES\tSIZE")
+			for _, d := range list.Directories {
+				fmt.Fprintf(tw, "%s\t%d\t%s\n", d.Cid, d.Entries, humanize.IBytes(d.Size))
+			}
+			return nil
+		}),
+	},
+	Type: gatewayDirIndexList{},
+}
+
+var gatewayDirIndexPurgeCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove directories from the cache.",
+		ShortDescription: `
+Removes the cached entries of the given directories, or of every directory
+when none is given, so that they are enumerated again on their next listing.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", false, true, "CID of the directory to purge."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.GatewayDirectoryIndex == nil {
+			return errGatewayDirIndexDisabled
+		}
+
+		if len(req.Arguments) == 0 {
+			return cmds.EmitOnce(res, &gatewayDirIndexPurge{Purged: nd.GatewayDirectoryIndex.Clear(req.Context)})
+		}
+		cids := make([]cid.Cid, 0, len(req.Arguments))
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return fmt.Errorf("invalid CID %q: %w", arg, err)
+			}
+			cids = append(cids, c)
+		}
+		out := &gatewayDirIndexPurge{}
+		for _, c := range cids {
+			if nd.GatewayDirectoryIndex.Remove(req.Context, c) {
+				out.Purged++
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, p *gatewayDirIndexPurge) error {
+			_, err := fmt.Fprintf(w, "purged %d directories\n", p.Purged)
+			return err
+		}),
+	},
+	Type: gatewayDirIndexPurge{},
+}
+
+var gatewayRedirectsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Evaluate the _redirects files of the gateway.",
+		ShortDescription: `
+The gateway applies the rules of the _redirects file at the root of a DAG to
+the paths missing from it, when the DAG is served with origin isolation: by a
+subdomain gateway or as a DNSLink website. The rules applied are counted by
+the ipfs_http_gw_redirects_rules_total metric.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"test": gatewayRedirectsTestCmd,
+	},
+}
+
+type gatewayRedirectsTestOutput struct {
+	// Path is the path evaluated, under Root.
+	Path string
+	Root string
+	// Exists is true when the path exists in the DAG, which is then served
+	// without evaluating the rules.
+	Exists bool
+	// NoRedirects is true when the DAG has no _redirects file.
+	NoRedirects bool `json:",omitempty"`
+	// Rule is the rule applied, if any.
+	Rule *node.GatewayRedirect `json:",omitempty"`
+	// Status is the status of the response of the gateway.
+	Status int
+	// Target is the path served, or the Location of the redirects.
+	Target string `json:",omitempty"`
+}
+
+var gatewayRedirectsTestCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the _redirects rule the gateway applies to a path.",
+		ShortDescription: `
+Evaluates the _redirects file of a DAG for a path the same way the gateway
+does, and prints the rule matched and the response of the gateway, without
+sending requests to it:
+
+  $ ipfs gateway redirects test bafy... /app/settings
+  rule 2: /app/* /index.html 200
+  200 OK: /ipfs/bafy.../index.html
+
+The rules only apply to the paths missing from the DAG, and the gateway only
+applies them with origin isolation, such as on a subdomain gateway.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "CID or path of the root of the DAG."),
+		cmds.StringArg("path", true, false, "Path requested, such as /app/settings."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, _, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+		root := path.FromCid(rp.RootCid())
+		segments := strings.Split(strings.Trim(req.Arguments[1], "/"), "/")
+		full, err := path.Join(rp, segments...)
+		if err != nil {
+			return err
+		}
+		out := &gatewayRedirectsTestOutput{Path: "/" + strings.Trim(req.Arguments[1], "/"), Root: root.String()}
+
+		// like the gateway, the rules are only evaluated for the paths which
+		// do not exist
+		_, _, err = api.ResolvePath(req.Context, full)
+		switch {
+		case err == nil:
+			out.Exists, out.Status = true, http.StatusOK
+			return cmds.EmitOnce(res, out)
+		case !errors.Is(err, &resolver.ErrNoLink{}):
+			return err
+		}
+
+		redirectsPath, urlPath, ok := node.GatewayRedirectsPath(full)
+		if !ok {
+			return fmt.Errorf("%s is the root of the DAG", full)
+		}
+		nd, err := api.Unixfs().Get(req.Context, redirectsPath)
+		if errors.Is(err, &resolver.ErrNoLink{}) {
+			out.NoRedirects, out.Status = true, http.StatusNotFound
+			return cmds.EmitOnce(res, out)
+		}
+		if err != nil {
+			return err
+		}
+		defer nd.Close()
+		f, ok := nd.(files.File)
+		if !ok {
+			return errors.New("_redirects is not a file")
+		}
+		rules, err := redirects.Parse(f)
+		if err != nil {
+			return fmt.Errorf("could not parse _redirects: %w", err)
+		}
+
+		out.Rule = node.MatchGatewayRedirects(rules, urlPath)
+		switch {
+		case out.Rule == nil:
+			out.Status = http.StatusNotFound
+		case out.Rule.IsRewrite() || out.Rule.IsCustomError():
+			out.Status, out.Target = out.Rule.Status, root.String()+out.Rule.To
+		default:
+			out.Status, out.Target = out.Rule.Status, out.Rule.To
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *gatewayRedirectsTestOutput) error {
+			switch {
+			case out.Exists:
+				fmt.Fprintf(w, "%s exists, the _redirects rules are not evaluated\n", out.Path)
+			case out.NoRedirects:
+				fmt.Fprintf(w, "%s has no _redirects file\n", out.Root)
+			case out.Rule == nil:
+				fmt.Fprintf(w, "no rule matches %s\n", out.Path)
+			default:
+				fmt.Fprintf(w, "rule %d: %s %s %d\n", out.Rule.Rule, out.Rule.From, out.Rule.To, out.Rule.Status)
+			}
+			status := fmt.Sprintf("%d %s", out.Status, http.StatusText(out.Status))
+			switch {
+			case out.Target == "":
+				fmt.Fprintln(w, status)
+			case out.Rule.IsRewrite() || out.Rule.IsCustomError():
+				fmt.Fprintf(w, "%s: %s\n", status, out.Target)
+			default:
+				fmt.Fprintf(w, "%s: redirect to %s\n", status, out.Target)
+			}
+			return nil
+		}),
+	},
+	Type: gatewayRedirectsTestOutput{},
+}
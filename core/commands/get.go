@@ -6,11 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	gopath "path"
 	"path/filepath"
 	"strings"
 
+	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
 	"github.com/ipfs/kubo/core/commands/e"
@@ -28,6 +30,7 @@ const (
 	archiveOptionName          = "archive"
 	compressOptionName         = "compress"
 	compressionLevelOptionName = "compression-level"
+	sparseOptionName           = "sparse"
 )
 
 var GetCmd = &cmds.Command{
@@ -43,6 +46,21 @@ To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
+
+Use '--sparse' to write the zeroed regions of extracted files as holes, which
+saves disk space for mostly empty files such as VM images. Holes are punched
+once each file is written and are only supported on Linux.
+
+Use '--preserve-xattrs' to restore the extended attributes and POSIX ACLs
+recorded by 'ipfs add --preserve-xattrs' in '.ipfs-xattrs.json' files, which
+are removed once applied. Only the user.* attributes and the POSIX ACLs are
+restored, as the content may come from anyone: the other namespaces, such as
+security.capability or trusted.*, grant privileges when restored by root, and
+are only restored with '--privileged-xattrs', for content you trust. Only
+supported on Linux.
+
+Symlinks are restored as symlinks by default. Use '--symlinks=skip' to leave
+them out, or set the default with the 'Import.GetSymlinks' config option.
 `,
 	},
 
@@ -55,10 +73,29 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		cmds.BoolOption(compressOptionName, "C", "Compress the output with GZIP compression."),
 		cmds.IntOption(compressionLevelOptionName, "l", "The level of compression (1-9)."),
 		cmds.BoolOption(progressOptionName, "p", "Stream progress data.").WithDefault(true),
+		cmds.StringOption(symlinksOptionName, "How to handle symlinks: restore or skip. Default: Import.GetSymlinks"),
+		cmds.BoolOption(sparseOptionName, "Write zeroed regions of extracted files as holes."),
+		cmds.BoolOption(preserveXattrsOptionName, "Restore extended attributes and POSIX ACLs recorded by 'ipfs add --preserve-xattrs'."),
+		cmds.BoolOption(privilegedXattrsOptionName, "With --preserve-xattrs, also restore the attributes other than user.* and the POSIX ACLs, such as security.capability. Only for trusted content."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
-		_, err := getCompressOptions(req)
-		return err
+		cmplvl, err := getCompressOptions(req)
+		if err != nil {
+			return err
+		}
+		archive, _ := req.Options[archiveOptionName].(bool)
+		sparse, _ := req.Options[sparseOptionName].(bool)
+		if sparse && (archive || cmplvl != gzip.NoCompression) {
+			return fmt.Errorf("--%s only applies to extracted files, not to archives", sparseOptionName)
+		}
+		xattrs, _ := req.Options[preserveXattrsOptionName].(bool)
+		if xattrs && (archive || cmplvl != gzip.NoCompression) {
+			return fmt.Errorf("--%s only applies to extracted files, not to archives", preserveXattrsOptionName)
+		}
+		if privileged, _ := req.Options[privilegedXattrsOptionName].(bool); privileged && !xattrs {
+			return fmt.Errorf("--%s requires --%s", privilegedXattrsOptionName, preserveXattrsOptionName)
+		}
+		return nil
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		ctx := req.Context
@@ -89,6 +126,29 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 
 		res.SetLength(uint64(size))
 
+		symlinks, _ := req.Options[symlinksOptionName].(string)
+		if symlinks == "" {
+			nd, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			cfg, err := nd.Repo.Config()
+			if err != nil {
+				return err
+			}
+			symlinks = cfg.Import.GetSymlinks.WithDefault(config.DefaultGetSymlinks)
+		}
+		symlinksFn, err := getSymlinksFunc(symlinks)
+		if err != nil {
+			return err
+		}
+		if symlinksFn != nil {
+			file, err = filterSymlinks(file, gopath.Base(p.String()), symlinksFn)
+			if err != nil {
+				return err
+			}
+		}
+
 		archive, _ := req.Options[archiveOptionName].(bool)
 		reader, err := fileArchive(file, p.String(), archive, cmplvl)
 		if err != nil {
@@ -127,14 +187,20 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 
 			archive, _ := req.Options[archiveOptionName].(bool)
 			progress, _ := req.Options[progressOptionName].(bool)
+			sparse, _ := req.Options[sparseOptionName].(bool)
+			xattrs, _ := req.Options[preserveXattrsOptionName].(bool)
+			privilegedXattrs, _ := req.Options[privilegedXattrsOptionName].(bool)
 
 			gw := getWriter{
-				Out:         os.Stdout,
-				Err:         os.Stderr,
-				Archive:     archive,
-				Compression: cmplvl,
-				Size:        int64(res.Length()),
-				Progress:    progress,
+				Out:              os.Stdout,
+				Err:              os.Stderr,
+				Archive:          archive,
+				Compression:      cmplvl,
+				Size:             int64(res.Length()),
+				Progress:         progress,
+				Sparse:           sparse,
+				Xattrs:           xattrs,
+				PrivilegedXattrs: privilegedXattrs,
 			}
 
 			return gw.Write(outReader, outPath)
@@ -196,6 +262,11 @@ type getWriter struct {
 	Compression int
 	Size        int64
 	Progress    bool
+	Sparse      bool
+	Xattrs      bool
+	// PrivilegedXattrs restores the attributes outside of user.* and the
+	// POSIX ACLs too.
+	PrivilegedXattrs bool
 }
 
 func (gw *getWriter) Write(r io.Reader, fpath string) error {
@@ -251,7 +322,29 @@ func (gw *getWriter) writeExtracted(r io.Reader, fpath string) error {
 	}
 
 	extractor := &tar.Extractor{Path: fpath, Progress: progressCb}
-	return extractor.Extract(r)
+	if err := extractor.Extract(r); err != nil {
+		return err
+	}
+	if gw.Sparse {
+		if err := sparsify(fpath); err != nil {
+			return err
+		}
+	}
+	if gw.Xattrs {
+		return restoreXattrs(fpath, gw.PrivilegedXattrs, gw.Err)
+	}
+	return nil
+}
+
+// sparsify punches holes in the zeroed regions of the regular files at or
+// below fpath.
+func sparsify(fpath string) error {
+	return filepath.WalkDir(fpath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		return punchHoles(p)
+	})
 }
 
 func getCompressOptions(req *cmds.Request) (int, error) {
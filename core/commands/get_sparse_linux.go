@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+package commands
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	unix "golang.org/x/sys/unix"
+)
+
+// sparseBlockSize is the granularity at which zeroed regions are turned into
+// holes.
+const sparseBlockSize = 4096
+
+// punchHoles deallocates the regions of the file at fpath that only contain
+// zeros, keeping its size and content.
+func punchHoles(fpath string) error {
+	f, err := os.OpenFile(fpath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zeros := make([]byte, sparseBlockSize)
+	buf := make([]byte, sparseBlockSize)
+	var off, holeStart, holeLen int64
+	punch := func() error {
+		if holeLen == 0 {
+			return nil
+		}
+		err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, holeStart, holeLen)
+		holeLen = 0
+		return err
+	}
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n == sparseBlockSize && bytes.Equal(buf, zeros) {
+			if holeLen == 0 {
+				holeStart = off
+			}
+			holeLen += int64(n)
+		} else if perr := punch(); perr != nil {
+			return perr
+		}
+		off += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return punch()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
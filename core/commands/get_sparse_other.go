@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package commands
+
+import "errors"
+
+func punchHoles(string) error {
+	return errors.New("sparse files are only supported on Linux")
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	irouting "github.com/ipfs/kubo/routing"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	healMinProvidersOptionName = "min-providers"
+	healDepthOptionName        = "depth"
+	healDryRunOptionName       = "dry-run"
+)
+
+const defaultHealMinProviders = 3
+
+type healOutput struct {
+	Cid   string
+	Depth int
+	// Subtree is whether the providers of the root of the subtree stand for
+	// the whole subtree, or only for the block at Depth < --depth.
+	Subtree bool
+	// ProvidersBefore and ProvidersAfter are counted up to --min-providers.
+	ProvidersBefore int
+	ProvidersAfter  int `json:",omitempty"`
+	// Healed is the number of blocks fetched and announced.
+	Healed int `json:",omitempty"`
+}
+
+var HealCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Replicate the under-replicated parts of a DAG.",
+		ShortDescription: `
+'ipfs heal' looks for the providers of the blocks of a DAG down to --depth,
+and the subtrees whose root has fewer than --min-providers providers are
+fetched into the repo and announced, so that the node becomes one of their
+providers. The providers of each block are counted again once it is
+announced, to report how its replication improved:
+
+  > ipfs heal --depth=1 bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi
+  bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi: 3 providers
+  bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku: 1 -> 2 providers, 1 blocks healed
+  bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354: 3 providers
+
+The blocks above --depth are checked one by one, and the DAG under each block
+at --depth is assumed to be as replicated as its root. A deeper --depth finds
+under-replicated parts more precisely, at the cost of more provider lookups.
+
+With --dry-run, the under-replicated subtrees are only reported, but the
+blocks above --depth are still fetched to walk their links.
+
+The healed blocks are not pinned: pin the DAG to keep them through garbage
+collections, and to keep them announced with the "pinned" Reprovider.Strategy.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "Path or CID of the root of the DAG to heal."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(healMinProvidersOptionName, "Number of providers under which a subtree is healed.").WithDefault(defaultHealMinProviders),
+		cmds.IntOption(healDepthOptionName, "Depth of the subtrees whose providers are looked up.").WithDefault(0),
+		cmds.BoolOption(healDryRunOptionName, "Only report the under-replicated subtrees."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		minProviders, _ := req.Options[healMinProvidersOptionName].(int)
+		if minProviders < 1 {
+			return cmds.Errorf(cmds.ErrClient, "--%s must be at least 1", healMinProvidersOptionName)
+		}
+		maxDepth, _ := req.Options[healDepthOptionName].(int)
+		if maxDepth < 0 {
+			return cmds.Errorf(cmds.ErrClient, "--%s must not be negative", healDepthOptionName)
+		}
+		dryRun, _ := req.Options[healDryRunOptionName].(bool)
+
+		p, err := cmdutils.PathOrCidPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, _, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		h := &healer{
+			router:       nd.Routing,
+			minProviders: minProviders,
+			maxDepth:     maxDepth,
+			dryRun:       dryRun,
+			visited:      cid.NewSet(),
+			emit:         res.Emit,
+		}
+		h.getLinks = dag.GetLinksDirect(nd.DAG)
+		return h.heal(req.Context, rp.RootCid(), 0)
+	},
+	Type: healOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *healOutput) error {
+			if out.Healed > 0 {
+				_, err := fmt.Fprintf(w, "%s: %d -> %d providers, %d blocks healed\n", out.Cid, out.ProvidersBefore, out.ProvidersAfter, out.Healed)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "%s: %d providers\n", out.Cid, out.ProvidersBefore)
+			return err
+		}),
+	},
+}
+
+// healer walks a DAG down to maxDepth, and fetches and announces the blocks
+// and subtrees with fewer than minProviders providers.
+type healer struct {
+	router       irouting.ProvideManyRouter
+	getLinks     dag.GetLinks
+	minProviders int
+	maxDepth     int
+	dryRun       bool
+	visited      *cid.Set
+	emit         func(any) error
+}
+
+func (h *healer) heal(ctx context.Context, c cid.Cid, depth int) error {
+	if !h.visited.Visit(c) {
+		return nil
+	}
+
+	out := &healOutput{
+		Cid:             c.String(),
+		Depth:           depth,
+		Subtree:         depth == h.maxDepth,
+		ProvidersBefore: h.countProviders(ctx, c),
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if out.ProvidersBefore < h.minProviders && !h.dryRun {
+		keys := []cid.Cid{c}
+		if out.Subtree {
+			kset := cid.NewSet()
+			if err := dag.Walk(ctx, h.getLinks, c, kset.Visit); err != nil {
+				return err
+			}
+			keys = kset.Keys()
+		} else if _, err := h.getLinks(ctx, c); err != nil {
+			// fetches the block, whose links are walked below
+			return err
+		}
+		mhs := make([]multihash.Multihash, len(keys))
+		for i, k := range keys {
+			mhs[i] = k.Hash()
+		}
+		if err := h.router.ProvideMany(ctx, mhs); err != nil {
+			return err
+		}
+		out.Healed = len(keys)
+		out.ProvidersAfter = h.countProviders(ctx, c)
+	}
+	if err := h.emit(out); err != nil {
+		return err
+	}
+
+	if out.Subtree {
+		return nil
+	}
+	links, err := h.getLinks(ctx, c)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if err := h.heal(ctx, l.Cid, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countProviders returns the number of providers of c, up to minProviders.
+func (h *healer) countProviders(ctx context.Context, c cid.Cid) int {
+	seen := make(map[peer.ID]struct{})
+	for p := range h.router.FindProvidersAsync(ctx, c, h.minProviders) {
+		seen[p.ID] = struct{}{}
+	}
+	return len(seen)
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+const keyAuditSinceOptionName = "since"
+
+type keyAuditOutput struct {
+	Entries []node.KeyAuditEntry
+}
+
+var keyAuditCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the uses of a key.",
+		ShortDescription: `
+'ipfs key audit' lists the uses of a key recorded with Security.KeyAudit,
+oldest first: its generation, import, export, renaming and removal, the
+messages signed and the IPNS records published with it, by the commands and
+by the IPNS republisher. Each use lists the command and the
+API.Authorizations entry of the RPC request which made it.
+
+The identity key is named 'self'. Its uses by libp2p are not recorded.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the key."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(keyAuditSinceOptionName, "Only list the uses in this past duration, like 24h."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.KeyAudit == nil {
+			return errors.New("the uses of the keys are only recorded with Security.KeyAudit.Enabled")
+		}
+
+		var since time.Time
+		if s, _ := req.Options[keyAuditSinceOptionName].(string); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", keyAuditSinceOptionName, err)
+			}
+			since = time.Now().Add(-d)
+		}
+
+		entries, err := n.KeyAudit.Entries(req.Context, req.Arguments[0], since)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &keyAuditOutput{Entries: entries})
+	},
+	Type: keyAuditOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *keyAuditOutput) error {
+			for _, e := range out.Entries {
+				fmt.Fprintf(w, "%s %-12s", e.Time.Format(time.RFC3339), e.Operation)
+				if e.Detail != "" {
+					fmt.Fprintf(w, " %s", cmdenv.EscNonPrint(e.Detail))
+				}
+				if e.Command != "" {
+					fmt.Fprintf(w, " by 'ipfs %s'", e.Command)
+				}
+				if e.User != "" {
+					fmt.Fprintf(w, " as %s", e.User)
+				}
+				if e.Error != "" {
+					fmt.Fprintf(w, " (%s)", e.Error)
+				}
+				fmt.Fprintln(w)
+			}
+			return nil
+		}),
+	},
+}
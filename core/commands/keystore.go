@@ -23,7 +23,6 @@ import (
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	migrations "github.com/ipfs/kubo/repo/fsrepo/migrations"
 	"github.com/libp2p/go-libp2p/core/crypto"
-	peer "github.com/libp2p/go-libp2p/core/peer"
 	mbase "github.com/multiformats/go-multibase"
 )
 
@@ -45,6 +44,7 @@ publish'.
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
+		"audit":  keyAuditCmd,
 		"gen":    keyGenCmd,
 		"export": keyExportCmd,
 		"import": keyImportCmd,
@@ -142,8 +142,8 @@ var keyGenCmd = &cmds.Command{
 const (
 	// Key format options used both for importing and exporting.
 	keyFormatOptionName            = "format"
-	keyFormatPemCleartextOption    = "pem-pkcs8-cleartext"
-	keyFormatLibp2pCleartextOption = "libp2p-protobuf-cleartext"
+	keyFormatPemCleartextOption    = options.KeyFormatPemCleartext
+	keyFormatLibp2pCleartextOption = options.KeyFormatLibp2pCleartext
 	keyAllowAnyTypeOptionName      = "allow-any-key-type"
 )
 
@@ -327,10 +327,9 @@ The PEM format allows for key generation outside of the IPFS node:
 		cmds.FileArg("key", true, false, "key provided by generate or export"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		name := req.Arguments[0]
-
-		if name == "self" {
-			return fmt.Errorf("cannot import key with name 'self'")
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
 		}
 
 		keyEnc, err := ke.KeyEncoderFromString(req.Options[ke.OptionIPNSBase.Name()].(string))
@@ -350,91 +349,18 @@ The PEM format allows for key generation outside of the IPFS node:
 		}
 
 		importFormat, _ := req.Options[keyFormatOptionName].(string)
-		var sk crypto.PrivKey
-		switch importFormat {
-		case keyFormatPemCleartextOption:
-			pemBlock, rest := pem.Decode(data)
-			if pemBlock == nil {
-				return fmt.Errorf("PEM block not found in input data:\n%s", rest)
-			}
-
-			if pemBlock.Type != "PRIVATE KEY" {
-				return fmt.Errorf("expected PRIVATE KEY type in PEM block but got: %s", pemBlock.Type)
-			}
-
-			stdKey, err := x509.ParsePKCS8PrivateKey(pemBlock.Bytes)
-			if err != nil {
-				return fmt.Errorf("parsing PKCS8 format: %w", err)
-			}
-
-			// In case ed25519.PrivateKey is returned we need the pointer for
-			// conversion to libp2p (see export command for more details).
-			if ed25519KeyPointer, ok := stdKey.(ed25519.PrivateKey); ok {
-				stdKey = &ed25519KeyPointer
-			}
-
-			sk, _, err = crypto.KeyPairFromStdKey(stdKey)
-			if err != nil {
-				return fmt.Errorf("converting std Go key to libp2p key: %w", err)
-			}
-		case keyFormatLibp2pCleartextOption:
-			sk, err = crypto.UnmarshalPrivateKey(data)
-			if err != nil {
-				// check if data is PEM, if so, provide user with hint
-				pemBlock, _ := pem.Decode(data)
-				if pemBlock != nil {
-					return fmt.Errorf("unexpected PEM block for format=%s: try again with format=%s", keyFormatLibp2pCleartextOption, keyFormatPemCleartextOption)
-				}
-				return fmt.Errorf("unable to unmarshall format=%s: %w", keyFormatLibp2pCleartextOption, err)
-			}
-
-		default:
-			return fmt.Errorf("unrecognized import format: %s", importFormat)
-		}
-
-		// We only allow importing keys of the same type we generate (see list in
-		// https://github.com/ipfs/interface-go-ipfs-core/blob/1c3d8fc/options/key.go#L58-L60),
-		// unless explicitly stated by the user.
 		allowAnyKeyType, _ := req.Options[keyAllowAnyTypeOptionName].(bool)
-		if !allowAnyKeyType {
-			switch t := sk.(type) {
-			case *crypto.RsaPrivateKey, *crypto.Ed25519PrivateKey:
-			default:
-				return fmt.Errorf("key type %T is not allowed to be imported, only RSA or Ed25519;"+
-					" use flag --%s if you are sure of what you're doing",
-					t, keyAllowAnyTypeOptionName)
-			}
-		}
-
-		cfgRoot, err := cmdenv.GetConfigRoot(env)
-		if err != nil {
-			return err
-		}
-
-		r, err := fsrepo.Open(cfgRoot)
-		if err != nil {
-			return err
-		}
-		defer r.Close()
-
-		_, err = r.Keystore().Get(name)
-		if err == nil {
-			return fmt.Errorf("key with name '%s' already exists", name)
-		}
-
-		err = r.Keystore().Put(name, sk)
-		if err != nil {
-			return err
-		}
-
-		pid, err := peer.IDFromPrivateKey(sk)
+		key, err := api.Key().Import(req.Context, req.Arguments[0], data,
+			options.Key.ImportFormat(importFormat),
+			options.Key.AllowAnyType(allowAnyKeyType),
+		)
 		if err != nil {
 			return err
 		}
 
 		return cmds.EmitOnce(res, &KeyOutput{
-			Name: name,
-			Id:   keyEnc.FormatID(pid),
+			Name: key.Name(),
+			Id:   keyEnc.FormatID(key.ID()),
 		})
 	},
 	Encoders: cmds.EncoderMap{
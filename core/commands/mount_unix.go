@@ -6,10 +6,12 @@ package commands
 import (
 	"fmt"
 	"io"
+	"os"
 
 	oldcmds "github.com/ipfs/kubo/commands"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	nodeMount "github.com/ipfs/kubo/fuse/node"
+	"github.com/ipfs/kubo/sandbox"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	config "github.com/ipfs/kubo/config"
@@ -97,6 +99,9 @@ baz
 		if !nd.IsOnline {
 			return ErrNotOnline
 		}
+		if os.Getenv(sandbox.EnvSandboxed) != "" {
+			return sandbox.ErrMount
+		}
 
 		fsdir, found := req.Options[mountIPFSPathOptionName].(string)
 		if !found {
@@ -0,0 +1,101 @@
+package name
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+type ipnsCacheList struct {
+	Entries []node.IpnsCacheEntry
+}
+
+type ipnsCachePurge struct {
+	Purged int
+}
+
+// IpnsCacheCmd inspects the cache of IPNS and DNSLink resolutions.
+var IpnsCacheCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect and purge the IPNS resolution cache.",
+		ShortDescription: `
+Names resolved by the daemon are cached for the TTL of their resolution, at
+most Ipns.MaxCacheTTL, in a cache of Ipns.ResolveCacheSize entries. The cache
+is saved to the datastore when Ipns.PersistResolveCache is enabled.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":    ipnsCacheLsCmd,
+		"purge": ipnsCachePurgeCmd,
+	},
+}
+
+var errIpnsCacheDisabled = errors.New("the IPNS resolution cache is only available while the daemon runs with a positive Ipns.ResolveCacheSize")
+
+var ipnsCacheLsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the cached resolutions.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.IpnsCache == nil {
+			return errIpnsCacheDisabled
+		}
+
+		return cmds.EmitOnce(res, &ipnsCacheList{Entries: n.IpnsCache.Entries()})
+	},
+	Type: ipnsCacheList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *ipnsCacheList) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tVALUE\tTTL\tEXPIRES IN")
+			for _, e := range list.Entries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Name, e.Value, e.TTL, time.Until(e.Expires).Truncate(time.Second))
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var ipnsCachePurgeCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove resolutions from the cache.",
+		ShortDescription: `
+Removes the cached resolutions of the given names, or of every name when none
+is given, so that they are resolved again on their next use.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", false, true, "IPNS name or DNSLink domain to purge."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.IpnsCache == nil {
+			return errIpnsCacheDisabled
+		}
+
+		return cmds.EmitOnce(res, &ipnsCachePurge{Purged: n.IpnsCache.Purge(req.Arguments...)})
+	},
+	Type: ipnsCachePurge{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, p *ipnsCachePurge) error {
+			_, err := fmt.Fprintf(w, "purged %d entries\n", p.Purged)
+			return err
+		}),
+	},
+}
@@ -3,7 +3,9 @@ package name
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
@@ -14,6 +16,17 @@ import (
 
 type ipnsPubsubState struct {
 	Enabled bool
+	Names   []ipnsPubsubName `json:",omitempty"`
+}
+
+type ipnsPubsubName struct {
+	Name           string
+	Subscribed     time.Time
+	ResolveLatency time.Duration `json:",omitempty"`
+	ResolvedBy     string        `json:",omitempty"`
+	Sequence       uint64        `json:",omitempty"`
+	LastSeen       time.Time     `json:",omitempty"`
+	Updates        int           `json:",omitempty"`
 }
 
 type ipnsPubsubCancel struct {
@@ -46,14 +59,48 @@ var ipnspsStateCmd = &cmds.Command{
 	Status: cmds.Experimental,
 	Helptext: cmds.HelpText{
 		Tagline: "Query the state of IPNS pubsub.",
+		ShortDescription: `
+'ipfs name pubsub state' reports whether IPNS pubsub is enabled. With
+--enc=json, it also reports the names subscribed to: how long their first
+record took to arrive, and whether it came from pubsub or from the lookup
+made with the other routers when subscribing ("fetch"), along with the
+sequence number of their latest record, when it was received, and the
+number of records received.
+`,
+	},
+	Options: []cmds.Option{
+		ke.OptionIPNSBase,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		keyEnc, err := ke.KeyEncoderFromString(req.Options[ke.OptionIPNSBase.Name()].(string))
+		if err != nil {
+			return err
+		}
+
 		n, err := cmdenv.GetNode(env)
 		if err != nil {
 			return err
 		}
 
-		return cmds.EmitOnce(res, &ipnsPubsubState{n.PSRouter != nil})
+		state := &ipnsPubsubState{Enabled: n.PSRouter != nil}
+		if n.IpnsPubsub != nil {
+			names := n.IpnsPubsub.Names()
+			sort.Slice(names, func(i, j int) bool {
+				return names[i].Subscribed.Before(names[j].Subscribed)
+			})
+			for _, name := range names {
+				state.Names = append(state.Names, ipnsPubsubName{
+					Name:           "/ipns/" + keyEnc.FormatID(name.ID),
+					Subscribed:     name.Subscribed,
+					ResolveLatency: name.ResolveLatency,
+					ResolvedBy:     name.ResolvedBy,
+					Sequence:       name.Sequence,
+					LastSeen:       name.LastSeen,
+					Updates:        name.Updates,
+				})
+			}
+		}
+		return cmds.EmitOnce(res, state)
 	},
 	Type: ipnsPubsubState{},
 	Encoders: cmds.EncoderMap{
@@ -18,6 +18,17 @@ import (
 type IpnsEntry struct {
 	Name  string
 	Value string
+	// Targets are the routing targets the record was published to.
+	Targets []IpnsPublishTarget `json:",omitempty"`
+}
+
+// IpnsPublishTarget is the result of the publication of a record to a
+// routing target, such as the DHT or a delegated publisher.
+type IpnsPublishTarget struct {
+	Target   string
+	Acked    bool
+	Duration time.Duration
+	Error    string `json:",omitempty"`
 }
 
 var NameCmd = &cmds.Command{
@@ -70,10 +81,12 @@ Resolve the value of a dnslink:
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"publish": PublishCmd,
-		"resolve": IpnsCmd,
-		"pubsub":  IpnsPubsubCmd,
-		"inspect": IpnsInspectCmd,
+		"publish":    PublishCmd,
+		"resolve":    IpnsCmd,
+		"pubsub":     IpnsPubsubCmd,
+		"inspect":    IpnsInspectCmd,
+		"cache":      IpnsCacheCmd,
+		"thirdparty": IpnsThirdPartyCmd,
 	},
 }
 
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
+	"github.com/ipfs/kubo/config"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
 
@@ -14,6 +16,7 @@ import (
 	ke "github.com/ipfs/kubo/core/commands/keyencode"
 	iface "github.com/ipfs/kubo/core/coreiface"
 	options "github.com/ipfs/kubo/core/coreiface/options"
+	irouting "github.com/ipfs/kubo/routing"
 )
 
 var errAllowOffline = errors.New("can't publish while offline: pass `--allow-offline` to override")
@@ -27,6 +30,8 @@ const (
 	keyOptionName          = "key"
 	quieterOptionName      = "quieter"
 	v1compatOptionName     = "v1compat"
+	quorumOptionName       = "quorum"
+	verboseOptionName      = "verbose"
 )
 
 var PublishCmd = &cmds.Command{
@@ -66,6 +71,18 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
  > ipfs name publish --key=QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
   Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+The record is published in parallel to the routing targets of the node: the
+DHT, pubsub with --enable-namesys-pubsub, and the delegated publishers of
+Routing.DelegatedPublishing. By default, the publication fails only when the
+DHT rejects the record. With --quorum=K, or Ipns.PublishQuorum, it succeeds
+when at least K targets accepted the record, and fails otherwise, although the
+targets that accepted it keep it. --verbose prints the result of each target:
+
+  > ipfs name publish --quorum=2 --verbose /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  dht: acked in 1.2s
+  https://example.com: acked in 350ms
+
 `,
 	},
 
@@ -80,6 +97,8 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 		cmds.BoolOption(quieterOptionName, "Q", "Write only final IPNS Name encoded as CIDv1 (for use in /ipns content paths)."),
 		cmds.BoolOption(v1compatOptionName, "Produce a backward-compatible IPNS Record by including fields for both V1 and V2 signatures.").WithDefault(true),
 		cmds.BoolOption(allowOfflineOptionName, "When --offline, save the IPNS record to the local datastore without broadcasting to the network (instead of failing)."),
+		cmds.IntOption(quorumOptionName, "Number of routing targets that must accept the record. Default: Ipns.PublishQuorum."),
+		cmds.BoolOption(verboseOptionName, "v", "Print the result of the publication to each routing target."),
 		ke.OptionIPNSBase,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -87,6 +106,21 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 		if err != nil {
 			return err
 		}
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		cfg, err := nd.Repo.Config()
+		if err != nil {
+			return err
+		}
+		quorum, found := req.Options[quorumOptionName].(int)
+		if !found {
+			quorum = int(cfg.Ipns.PublishQuorum.WithDefault(config.DefaultIpnsPublishQuorum))
+		}
+		if quorum < 0 {
+			return cmds.Errorf(cmds.ErrClient, "--quorum must be positive")
+		}
 
 		allowOffline, _ := req.Options[allowOfflineOptionName].(bool)
 		compatibleWithV1, _ := req.Options[v1compatOptionName].(bool)
@@ -126,17 +160,40 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 			}
 		}
 
-		name, err := api.Name().Publish(req.Context, p, opts...)
-		if err != nil {
+		ctx, trace := irouting.WithTrace(req.Context)
+		name, err := api.Name().Publish(ctx, p, opts...)
+		targets := publishTargets(trace.Events())
+		// with a quorum, the routing errors are only reported when too
+		// few targets accepted the record
+		if err != nil && (quorum == 0 || len(targets) == 0) {
 			if err == iface.ErrOffline {
 				err = errAllowOffline
 			}
 			return err
 		}
+		if quorum > 0 && nd.IsOnline {
+			var acks int
+			var failures []string
+			for _, t := range targets {
+				if t.Acked {
+					acks++
+				} else {
+					failures = append(failures, t.Target+": "+t.Error)
+				}
+			}
+			if acks < quorum {
+				msg := fmt.Sprintf("the record was accepted by %d of %d routing targets, %d required", acks, len(targets), quorum)
+				if len(failures) > 0 {
+					msg += ": " + strings.Join(failures, "; ")
+				}
+				return errors.New(msg)
+			}
+		}
 
 		return cmds.EmitOnce(res, &IpnsEntry{
-			Name:  name.String(),
-			Value: p.String(),
+			Name:    name.String(),
+			Value:   p.String(),
+			Targets: targets,
 		})
 	},
 	Encoders: cmds.EncoderMap{
@@ -148,8 +205,47 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 			} else {
 				_, err = fmt.Fprintf(w, "Published to %s: %s\n", cmdenv.EscNonPrint(ie.Name), cmdenv.EscNonPrint(ie.Value))
 			}
-			return err
+			if verbose, _ := req.Options[verboseOptionName].(bool); err != nil || !verbose {
+				return err
+			}
+			for _, t := range ie.Targets {
+				if t.Acked {
+					_, err = fmt.Fprintf(w, "%s: acked in %s\n", t.Target, t.Duration.Round(time.Millisecond))
+				} else {
+					_, err = fmt.Fprintf(w, "%s: failed in %s: %s\n", t.Target, t.Duration.Round(time.Millisecond), t.Error)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
 		}),
 	},
 	Type: IpnsEntry{},
 }
+
+// publishTargets returns the result of the publication to each routing
+// target from the calls to PutValue of a Trace. A target accepted the record
+// when all its calls, for the record and the public key, succeeded.
+func publishTargets(events []irouting.RouterEvent) []IpnsPublishTarget {
+	var targets []IpnsPublishTarget
+	index := make(map[string]int)
+	for _, ev := range events {
+		if ev.Method != "PutValue" {
+			continue
+		}
+		i, ok := index[ev.Router]
+		if !ok {
+			i = len(targets)
+			index[ev.Router] = i
+			targets = append(targets, IpnsPublishTarget{Target: ev.Router, Acked: true})
+		}
+		t := &targets[i]
+		t.Duration = max(t.Duration, ev.Duration)
+		if ev.Error != "" && t.Acked {
+			t.Acked = false
+			t.Error = ev.Error
+		}
+	}
+	return targets
+}
@@ -0,0 +1,60 @@
+package name
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+type ipnsThirdPartyList struct {
+	Records []node.IpnsThirdPartyRecord
+}
+
+// IpnsThirdPartyCmd lists the names of Ipns.RepublishThirdParty.
+var IpnsThirdPartyCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the third-party IPNS names republished by the daemon.",
+		ShortDescription: `
+The latest signed records of the names of Ipns.RepublishThirdParty are kept in
+the datastore and republished every Ipns.RepublishThirdPartyPeriod, until they
+expire. Their value, sequence number, validity and last republish are listed,
+with the error of the last round when it failed.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.IpnsThirdParty == nil {
+			return errors.New("third-party names are only republished while the daemon runs with Ipns.RepublishThirdParty set")
+		}
+
+		return cmds.EmitOnce(res, &ipnsThirdPartyList{Records: n.IpnsThirdParty.Records()})
+	},
+	Type: ipnsThirdPartyList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *ipnsThirdPartyList) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tVALUE\tSEQUENCE\tEXPIRES IN\tLAST REPUBLISH\tERROR")
+			for _, r := range list.Records {
+				expires, republished := "-", "never"
+				if !r.Validity.IsZero() {
+					expires = time.Until(r.Validity).Truncate(time.Second).String()
+				}
+				if !r.LastRepublish.IsZero() {
+					republished = r.LastRepublish.Format(time.RFC3339)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", r.Name, r.Value, r.Sequence, expires, republished, r.Error)
+			}
+			return tw.Flush()
+		}),
+	},
+}
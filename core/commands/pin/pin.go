@@ -8,13 +8,17 @@ import (
 	"os"
 	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	bserv "github.com/ipfs/boxo/blockservice"
 	offline "github.com/ipfs/boxo/exchange/offline"
 	dag "github.com/ipfs/boxo/ipld/merkledag"
+	pinner "github.com/ipfs/boxo/pinning/pinner"
 	verifcid "github.com/ipfs/boxo/verifcid"
 	cid "github.com/ipfs/go-cid"
 	cidenc "github.com/ipfs/go-cidutil/cidenc"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	options "github.com/ipfs/kubo/core/coreiface/options"
 
@@ -22,6 +26,7 @@ import (
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
 	e "github.com/ipfs/kubo/core/commands/e"
+	"github.com/ipfs/kubo/core/node"
 )
 
 var PinCmd = &cmds.Command{
@@ -94,6 +99,11 @@ It may take some time. Pass '--progress' to track the progress.
 			return err
 		}
 
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
 		// set recursive flag
 		recursive, _ := req.Options[pinRecursiveOptionName].(bool)
 		name, _ := req.Options[pinNameOptionName].(string)
@@ -109,7 +119,7 @@ It may take some time. Pass '--progress' to track the progress.
 		}
 
 		if !showProgress {
-			added, err := pinAddMany(req.Context, api, enc, req.Arguments, recursive, name)
+			added, err := pinAddMany(req.Context, api, nd.RPCQuotas, nd.PendingPins, enc, req.Arguments, recursive, name)
 			if err != nil {
 				return err
 			}
@@ -127,7 +137,7 @@ It may take some time. Pass '--progress' to track the progress.
 
 		ch := make(chan pinResult, 1)
 		go func() {
-			added, err := pinAddMany(ctx, api, enc, req.Arguments, recursive, name)
+			added, err := pinAddMany(ctx, api, nd.RPCQuotas, nd.PendingPins, enc, req.Arguments, recursive, name)
 			ch <- pinResult{pins: added, err: err}
 		}()
 
@@ -203,7 +213,10 @@ It may take some time. Pass '--progress' to track the progress.
 	},
 }
 
-func pinAddMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder, paths []string, recursive bool, name string) ([]string, error) {
+func pinAddMany(ctx context.Context, api coreiface.CoreAPI, quotas *node.RPCQuotaTracker, pending *node.PendingPins, enc cidenc.Encoder, paths []string, recursive bool, name string) ([]string, error) {
+	user, hasQuota := node.RPCUserFromContext(ctx)
+	hasQuota = hasQuota && recursive && quotas.HasPinQuota(user)
+
 	added := make([]string, len(paths))
 	for i, b := range paths {
 		p, err := cmdutils.PathOrCidPath(b)
@@ -216,7 +229,30 @@ func pinAddMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder,
 			return nil, err
 		}
 
-		if err := api.Pin().Add(ctx, rp, options.Pin.Recursive(recursive), options.Pin.Name(name)); err != nil {
+		if pending != nil {
+			if err := pending.Add(ctx, node.PendingPin{Cid: rp.RootCid(), Recursive: recursive, Name: name}); err != nil {
+				return nil, err
+			}
+		}
+		pin := func(ctx context.Context) error {
+			return api.Pin().Add(ctx, rp, options.Pin.Recursive(recursive), options.Pin.Name(name))
+		}
+		if hasQuota {
+			// The DAG has to be local to be charged against the quota
+			// before it is pinned.
+			if err = dag.FetchGraph(ctx, rp.RootCid(), api.Dag()); err == nil {
+				err = quotas.ChargePins(ctx, user, []cid.Cid{rp.RootCid()}, pin)
+			}
+		} else {
+			err = pin(ctx)
+		}
+		if pending != nil {
+			// the request may be canceled, the pending pin is still forgotten
+			if err := pending.Done(context.WithoutCancel(ctx), rp.RootCid(), err); err != nil {
+				return nil, err
+			}
+		}
+		if err != nil {
 			return nil, err
 		}
 		added[i] = enc.Encode(rp.RootCid())
@@ -582,9 +618,15 @@ func pinLsAll(req *cmds.Request, typeStr string, detailed bool, name string, api
 }
 
 const (
-	pinUnpinOptionName = "unpin"
+	pinUnpinOptionName    = "unpin"
+	pinDiffOnlyOptionName = "diff-only"
 )
 
+type UpdatePinOutput struct {
+	Pins []string
+	Diff *PinUpdateDiff `json:",omitempty"`
+}
+
 var updatePinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Update a recursive pin.",
@@ -597,6 +639,10 @@ derivative of an existing one, particularly for large objects. This allows a mor
 efficient DAG-traversal which fully skips already-pinned branches from the old
 object. As a requirement, the old object needs to be an existing recursive
 pin.
+
+With --diff-only, the blocks of the new object found in the old one are read
+locally, only the others are fetched and announced, and a summary of the
+blocks reused from the old object and of the new ones is printed.
 `,
 	},
 
@@ -606,8 +652,9 @@ pin.
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(pinUnpinOptionName, "Remove the old pin.").WithDefault(true),
+		cmds.BoolOption(pinDiffOnlyOptionName, "Only fetch and announce the blocks not in the old object, and summarize them."),
 	},
-	Type: PinOutput{},
+	Type: UpdatePinOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
@@ -620,6 +667,7 @@ pin.
 		}
 
 		unpin, _ := req.Options[pinUnpinOptionName].(bool)
+		diffOnly, _ := req.Options[pinDiffOnlyOptionName].(bool)
 
 		fromPath, err := cmdutils.PathOrCidPath(req.Arguments[0])
 		if err != nil {
@@ -641,21 +689,152 @@ pin.
 			return err
 		}
 
-		err = api.Pin().Update(req.Context, from, to, options.Pin.Unpin(unpin))
+		n, err := cmdenv.GetNode(env)
 		if err != nil {
 			return err
 		}
 
-		return cmds.EmitOnce(res, &PinOutput{Pins: []string{enc.Encode(from.RootCid()), enc.Encode(to.RootCid())}})
+		pins := []string{enc.Encode(from.RootCid()), enc.Encode(to.RootCid())}
+		if diffOnly {
+			diff, err := pinUpdateDiff(req.Context, n, from.RootCid(), to.RootCid(), unpin)
+			if err != nil {
+				return err
+			}
+			return cmds.EmitOnce(res, &UpdatePinOutput{Pins: pins, Diff: diff})
+		}
+
+		update := func(ctx context.Context) error {
+			return api.Pin().Update(ctx, from, to, options.Pin.Unpin(unpin))
+		}
+		if user, ok := n.RPCQuotas.PinQuotaUser(req.Context); ok {
+			// like 'ipfs pin add', the new DAG has to be local to be
+			// charged against the quota
+			if err = dag.FetchGraph(req.Context, to.RootCid(), api.Dag()); err == nil {
+				err = n.RPCQuotas.ChargePinUpdate(req.Context, user, from.RootCid(), to.RootCid(), unpin, update)
+			}
+		} else {
+			err = update(req.Context)
+		}
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &UpdatePinOutput{Pins: pins})
 	},
 	Encoders: cmds.EncoderMap{
-		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinOutput) error {
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *UpdatePinOutput) error {
 			fmt.Fprintf(w, "updated %s to %s\n", out.Pins[0], out.Pins[1])
+			if d := out.Diff; d != nil {
+				fmt.Fprintf(w, "reused %d blocks, %d new blocks (%s), fetched %d, announced %d\n",
+					d.Reused, d.New, humanize.Bytes(d.NewBytes), d.Fetched, d.Announced)
+			}
 			return nil
 		}),
 	},
 }
 
+// PinUpdateDiff summarizes the blocks of the new object of
+// 'ipfs pin update --diff-only'.
+type PinUpdateDiff struct {
+	// Reused is the number of blocks also in the old object.
+	Reused int
+	// New is the number of blocks not in the old object, and NewBytes their
+	// size.
+	New      int
+	NewBytes uint64
+	// Fetched is the number of new blocks fetched from the network.
+	Fetched int
+	// Announced is the number of new blocks announced.
+	Announced int
+}
+
+// pinUpdateDiff replaces the recursive pin of from with to, reading the blocks
+// of from locally and fetching and announcing only the others.
+func pinUpdateDiff(ctx context.Context, n *core.IpfsNode, from, to cid.Cid, unpin bool) (*PinUpdateDiff, error) {
+	if _, ok := node.FetchPriorityFromContext(ctx); !ok {
+		ctx = node.WithFetchPriority(ctx, node.FetchPriorityBackground)
+	}
+	defer n.Blockstore.PinLock(ctx).Unlock(ctx)
+
+	if _, pinned, err := n.Pinning.IsPinnedWithType(ctx, from, pinner.Recursive); err != nil {
+		return nil, err
+	} else if !pinned {
+		return nil, fmt.Errorf("%s is not pinned recursively", from)
+	}
+
+	// the blocks of a recursive pin are all local
+	localDAG := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+	old := make(map[string]struct{})
+	set := cid.NewSet()
+	err := dag.Walk(ctx, dag.GetLinksDirect(localDAG), from, func(c cid.Cid) bool {
+		if !set.Visit(c) {
+			return false
+		}
+		old[string(c.Hash())] = struct{}{}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading the old object: %w", err)
+	}
+
+	diff := &PinUpdateDiff{}
+	var added []cid.Cid
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		if _, ok := old[string(c.Hash())]; ok {
+			diff.Reused++
+			return dag.GetLinksDirect(localDAG)(ctx, c)
+		}
+		local, err := n.Blockstore.Has(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		nd, err := n.DAG.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		diff.New++
+		diff.NewBytes += uint64(len(nd.RawData()))
+		if !local {
+			diff.Fetched++
+		}
+		added = append(added, c)
+		return nd.Links(), nil
+	}
+	if err := dag.Walk(ctx, getLinks, to, cid.NewSet().Visit); err != nil {
+		return nil, fmt.Errorf("fetching the new object: %w", err)
+	}
+
+	update := func(ctx context.Context) error {
+		if err := n.Pinning.Update(ctx, from, to, unpin); err != nil {
+			return err
+		}
+		return n.Pinning.Flush(ctx)
+	}
+	if user, ok := n.RPCQuotas.PinQuotaUser(ctx); ok {
+		err = n.RPCQuotas.ChargePinUpdate(ctx, user, from, to, unpin, update)
+	} else {
+		err = update(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg, err := n.Repo.Config(); err == nil && cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy) == "roots" {
+		added = added[:0]
+		if _, ok := old[string(to.Hash())]; !ok {
+			added = append(added, to)
+		}
+	}
+	for _, c := range added {
+		if err := n.Provider.Provide(c); err != nil {
+			log.Warnf("announcing %s: %s", c, err)
+			continue
+		}
+		diff.Announced++
+	}
+	return diff, nil
+}
+
 const (
 	pinVerboseOptionName = "verbose"
 )
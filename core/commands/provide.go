@@ -0,0 +1,258 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+var ProvideCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the announcements of the provider system.",
+		ShortDescription: `
+The provide list holds CIDs reprovided in addition to the keys selected by
+Reprovider.Strategy, whether they are pinned or not. Entries may expire, to
+announce a rotating subset of the content of the node without pinning and
+unpinning it.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":  provideAddCmd,
+		"rm":   provideRmCmd,
+		"ls":   provideLsCmd,
+		"stat": provideStatCmd,
+	},
+}
+
+const provideTTLOptionName = "ttl"
+
+type ProvideEntry struct {
+	Cid     string
+	Expires *time.Time `json:",omitempty"`
+}
+
+func newProvideEntry(e node.ProvideListEntry) ProvideEntry {
+	out := ProvideEntry{Cid: e.Cid.String()}
+	if !e.Expires.IsZero() {
+		out.Expires = &e.Expires
+	}
+	return out
+}
+
+func encodeProvideEntry(req *cmds.Request, w io.Writer, e *ProvideEntry) error {
+	if e.Expires == nil {
+		_, err := fmt.Fprintf(w, "%s\tnever\n", e.Cid)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s\t%s\n", e.Cid, e.Expires.Format(time.RFC3339))
+	return err
+}
+
+var provideAddCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Add CIDs to the provide list.",
+		ShortDescription: `
+Adds CIDs to the provide list and, when the daemon is running, announces them
+right away. They are reprovided with the other keys until removed with
+'ipfs provide rm', or until their --ttl elapses. Adding a listed CID again
+updates its expiry.
+
+The blocks must be stored locally, but are not pinned: they may be removed by
+the garbage collector, after which the node announces content it cannot serve.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, true, "CIDs to add to the provide list.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(provideTTLOptionName, "How long to provide the CIDs for, such as 24h. Provided until removed by default."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		var ttl time.Duration
+		if s, ok := req.Options[provideTTLOptionName].(string); ok {
+			if ttl, err = time.ParseDuration(s); err != nil {
+				return fmt.Errorf("invalid --%s: %w", provideTTLOptionName, err)
+			}
+			if ttl <= 0 {
+				return fmt.Errorf("invalid --%s: must be positive", provideTTLOptionName)
+			}
+		}
+
+		cids := make([]cid.Cid, 0, len(req.Arguments))
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return err
+			}
+			has, err := nd.Blockstore.Has(req.Context, c)
+			if err != nil {
+				return err
+			}
+			if !has {
+				return fmt.Errorf("block %s not found locally, cannot provide", c)
+			}
+			cids = append(cids, c)
+		}
+
+		for _, c := range cids {
+			e, err := nd.ProvideList.Add(req.Context, c, ttl)
+			if err != nil {
+				return err
+			}
+			if nd.IsOnline {
+				if err := nd.Provider.Provide(c); err != nil {
+					return err
+				}
+			}
+			if err := res.Emit(newProvideEntry(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(encodeProvideEntry),
+	},
+	Type: ProvideEntry{},
+}
+
+var provideRmCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Remove CIDs from the provide list.",
+		ShortDescription: `
+Removes CIDs from the provide list. They are no longer reprovided, unless
+selected by Reprovider.Strategy. Provider records already announced expire on
+their own.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, true, "CIDs to remove from the provide list.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return err
+			}
+			err = nd.ProvideList.Remove(req.Context, c)
+			if errors.Is(err, datastore.ErrNotFound) {
+				return fmt.Errorf("%s is not in the provide list", c)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var provideLsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "List the provide list.",
+		ShortDescription: `
+Lists the CIDs of the provide list, with the time they expire at. Expired
+entries are removed.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		entries, err := nd.ProvideList.Entries(req.Context)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := res.Emit(newProvideEntry(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(encodeProvideEntry),
+	},
+	Type: ProvideEntry{},
+}
+
+var provideStatCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the progress of the reprovide sweep.",
+		ShortDescription: `
+The keys selected by Reprovider.Strategy are reannounced in small batches
+spread evenly over Reprovider.Interval, in a sweep. 'ipfs provide stat' shows
+the progress of the current sweep, or of the last one between sweeps: the keys
+announced so far, those that could not be, and an estimate of the remaining
+ones, based on the size of the last sweep.
+
+This interface is not stable and may change from release to release.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		sweeper, ok := nd.Provider.(*node.SweepingProvider)
+		if !ok {
+			return errors.New("reprovide sweeps are not running, see Experimental.StrategicProviding and 'ipfs daemon --single-command-server'")
+		}
+		return cmds.EmitOnce(res, sweeper.SweepStat())
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *node.SweepStat) error {
+			wtr := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer wtr.Flush()
+
+			switch {
+			case s.Running:
+				fmt.Fprintf(wtr, "Sweep:\trunning since %s\n", s.Started.Format(time.RFC3339))
+			case s.Started.IsZero():
+				fmt.Fprintf(wtr, "Sweep:\tnot started\n")
+			default:
+				fmt.Fprintf(wtr, "Sweep:\tlast started %s\n", s.Started.Format(time.RFC3339))
+			}
+			fmt.Fprintf(wtr, "Provided:\t%s\n", humanNumber(s.Provided))
+			fmt.Fprintf(wtr, "Failed:\t%s\n", humanNumber(s.Failed))
+			fmt.Fprintf(wtr, "Remaining:\t%s\n", humanNumber(s.Remaining))
+			fmt.Fprintf(wtr, "AvgAnnounceLatency:\t%s\n", humanDuration(s.AvgAnnounceLatency))
+			fmt.Fprintf(wtr, "LastSweepKeys:\t%s\n", humanNumber(s.LastSweepKeys))
+			fmt.Fprintf(wtr, "LastSweepDuration:\t%s\n", humanDuration(s.LastSweepDuration))
+			if !s.NextSweep.IsZero() && !s.Running {
+				fmt.Fprintf(wtr, "NextSweep:\t%s\n", s.NextSweep.Format(time.RFC3339))
+			}
+			return nil
+		}),
+	},
+	Type: node.SweepStat{},
+}
@@ -37,12 +37,14 @@ var RepoCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"stat":    repoStatCmd,
-		"gc":      repoGcCmd,
-		"version": repoVersionCmd,
-		"verify":  repoVerifyCmd,
-		"migrate": repoMigrateCmd,
-		"ls":      RefsLocalCmd,
+		"stat":       repoStatCmd,
+		"gc":         repoGcCmd,
+		"version":    repoVersionCmd,
+		"verify":     repoVerifyCmd,
+		"migrate":    repoMigrateCmd,
+		"ls":         RefsLocalCmd,
+		"export-car": repoExportCarCmd,
+		"import-car": repoImportCarCmd,
 	},
 }
 
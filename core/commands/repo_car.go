@@ -0,0 +1,456 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/ipfs/boxo/blockservice"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipldlegacy "github.com/ipfs/go-ipld-legacy"
+	gocar "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	gocarv2 "github.com/ipld/go-car/v2"
+	carblockstore "github.com/ipld/go-car/v2/blockstore"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreiface/options"
+)
+
+const (
+	repoCarAllPinnedOptionName = "all-pinned"
+	repoCarOutputOptionName    = "output"
+	repoCarShardSizeOptionName = "shard-size"
+
+	// pinManifestType identifies the manifest block written by
+	// 'ipfs repo export-car'.
+	pinManifestType = "kubo-pin-manifest"
+)
+
+// PinManifest describes the pins contained in a backup written by
+// 'ipfs repo export-car'. It is stored as a raw block, listed as the first
+// root of the CAR.
+type PinManifest struct {
+	Type   string
+	Pins   []PinManifestEntry
+	Shards []string `json:",omitempty"`
+}
+
+// PinManifestEntry is a single pin of a PinManifest.
+type PinManifestEntry struct {
+	Cid  string
+	Type string
+	Name string `json:",omitempty"`
+}
+
+var repoExportCarCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export pinned content as CAR for backup.",
+		ShortDescription: `
+'ipfs repo export-car --all-pinned' streams every recursively and directly
+pinned DAG into a single CAR. The first root of the CAR is a manifest block
+listing the pins, their types and names; the other roots are the pinned CIDs.
+Blocks shared by several DAGs are written once.
+
+By default a CARv1 stream is written to stdout. With --output the CLI writes
+indexed CARv2 files instead, together with a '.manifest.json' copy of the
+manifest next to them. --shard-size splits the backup into several CARv2
+files of roughly that size, named <output>.0.car, <output>.1.car, and so on.
+
+Restore a backup with 'ipfs repo import-car'.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(repoCarAllPinnedOptionName, "Export every recursive and direct pin."),
+		cmds.StringOption(repoCarOutputOptionName, "o", "Write indexed CARv2 file(s) to this path instead of a CARv1 stream to stdout."),
+		cmds.StringOption(repoCarShardSizeOptionName, "Split the CARv2 output into files of about this size, e.g. '4GiB'. Requires --output."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		allPinned, _ := req.Options[repoCarAllPinnedOptionName].(bool)
+		if !allPinned {
+			return cmds.Errorf(cmds.ErrClient, "--%s is required", repoCarAllPinnedOptionName)
+		}
+
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		// Keep GC from removing pinned blocks halfway through the export.
+		unlocker := n.Blockstore.PinLock(req.Context)
+		defer unlocker.Unlock(req.Context)
+
+		manifest := PinManifest{Type: pinManifestType}
+		roots := []cid.Cid{}
+		for _, typ := range []options.PinLsOption{options.Pin.Ls.Recursive(), options.Pin.Ls.Direct()} {
+			pins, err := api.Pin().Ls(req.Context, typ, options.Pin.Ls.Detailed(true))
+			if err != nil {
+				return err
+			}
+			for p := range pins {
+				if err := p.Err(); err != nil {
+					return err
+				}
+				c := p.Path().RootCid()
+				manifest.Pins = append(manifest.Pins, PinManifestEntry{Cid: c.String(), Type: p.Type(), Name: p.Name()})
+				roots = append(roots, c)
+			}
+		}
+
+		manifestData, err := json.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+		manifestBlock, err := newRawBlock(manifestData)
+		if err != nil {
+			return err
+		}
+
+		bs := n.Blockstore
+		dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+
+		pipeR, pipeW := io.Pipe()
+		errCh := make(chan error, 1)
+		go func() {
+			err := func() error {
+				header := &gocar.CarHeader{Roots: append([]cid.Cid{manifestBlock.Cid()}, roots...), Version: 1}
+				if err := gocar.WriteHeader(header, pipeW); err != nil {
+					return err
+				}
+				if err := carutil.LdWrite(pipeW, manifestBlock.Cid().Bytes(), manifestBlock.RawData()); err != nil {
+					return err
+				}
+
+				seen := cid.NewSet()
+				var walkErr error
+				write := func(c cid.Cid) bool {
+					if walkErr != nil || !seen.Visit(c) {
+						return false
+					}
+					blk, err := bs.Get(req.Context, c)
+					if err != nil {
+						walkErr = err
+						return false
+					}
+					walkErr = carutil.LdWrite(pipeW, c.Bytes(), blk.RawData())
+					return walkErr == nil
+				}
+				for i, c := range roots {
+					if manifest.Pins[i].Type == "direct" {
+						write(c)
+					} else if err := merkledag.Walk(req.Context, merkledag.GetLinksWithDAG(dag), c, write); err != nil {
+						return err
+					}
+					if walkErr != nil {
+						return walkErr
+					}
+				}
+				return nil
+			}()
+			pipeW.CloseWithError(err)
+			errCh <- err
+		}()
+
+		if err := res.Emit(pipeR); err != nil {
+			pipeR.Close()
+			return err
+		}
+		return <-errCh
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			output, _ := res.Request().Options[repoCarOutputOptionName].(string)
+			shardSizeStr, _ := res.Request().Options[repoCarShardSizeOptionName].(string)
+			if output == "" {
+				if shardSizeStr != "" {
+					return fmt.Errorf("--%s requires --%s", repoCarShardSizeOptionName, repoCarOutputOptionName)
+				}
+				return cmds.Copy(re, res)
+			}
+
+			var shardSize uint64
+			if shardSizeStr != "" {
+				var err error
+				shardSize, err = humanize.ParseBytes(shardSizeStr)
+				if err != nil {
+					return fmt.Errorf("invalid --%s: %w", repoCarShardSizeOptionName, err)
+				}
+			}
+
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			r, ok := v.(io.Reader)
+			if !ok {
+				return errors.New("unexpected non-stream response")
+			}
+
+			manifest, err := writeCarShards(r, output, shardSize)
+			if err != nil {
+				return err
+			}
+			for _, shard := range manifest.Shards {
+				fmt.Fprintln(os.Stdout, shard)
+			}
+			return nil
+		},
+	},
+}
+
+// writeCarShards writes the CARv1 backup stream r to indexed CARv2 files at
+// output, starting a new file whenever shardSize bytes of blocks were
+// written. It returns the manifest of the backup, also written as JSON next
+// to the CARv2 files.
+func writeCarShards(r io.Reader, output string, shardSize uint64) (*PinManifest, error) {
+	car, err := gocarv2.NewBlockReader(r)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(output, ".car")
+
+	var manifest PinManifest
+	var shard *carblockstore.ReadWrite
+	var shardBytes uint64
+	closeShard := func() error {
+		if shard == nil {
+			return nil
+		}
+		err := shard.Finalize()
+		shard = nil
+		return err
+	}
+	defer closeShard()
+
+	ctx := context.Background()
+	for first := true; ; first = false {
+		blk, err := car.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first {
+			if len(car.Roots) == 0 || blk.Cid() != car.Roots[0] {
+				return nil, errors.New("backup stream does not start with a pin manifest")
+			}
+			if err := json.Unmarshal(blk.RawData(), &manifest); err != nil || manifest.Type != pinManifestType {
+				return nil, errors.New("backup stream does not start with a pin manifest")
+			}
+		}
+
+		if shard != nil && shardSize > 0 && shardBytes >= shardSize {
+			if err := closeShard(); err != nil {
+				return nil, err
+			}
+		}
+		if shard == nil {
+			name := output
+			if shardSize > 0 {
+				name = fmt.Sprintf("%s.%d.car", base, len(manifest.Shards))
+			}
+			f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			shard, err = carblockstore.OpenReadWriteFile(f, car.Roots)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			manifest.Shards = append(manifest.Shards, name)
+			shardBytes = 0
+		}
+
+		if err := shard.Put(ctx, blk); err != nil {
+			return nil, err
+		}
+		shardBytes += uint64(len(blk.RawData()))
+	}
+	if err := closeShard(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(base+".manifest.json", data, 0o644); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func newRawBlock(data []byte) (blocks.Block, error) {
+	c, err := cid.V1Builder{Codec: cid.Raw, MhType: mh.SHA2_256}.Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// RepoCarImportOutput is the result of restoring one pin with
+// 'ipfs repo import-car'.
+type RepoCarImportOutput struct {
+	PinManifestEntry
+	Error string `json:",omitempty"`
+}
+
+var repoImportCarCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restore a backup made with 'ipfs repo export-car'.",
+		ShortDescription: `
+'ipfs repo import-car' imports the blocks of the given CAR files (all shards
+of a backup) and re-creates every pin listed in the backup manifest, with
+its type and name. Blocks of pinned DAGs that are missing from the CAR
+files are fetched from the network when the daemon is online.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("path", true, true, "The CAR files of the backup.").EnableStdin(),
+	},
+	Type: RepoCarImportOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		unlocker := n.Blockstore.PinLock(req.Context)
+		defer unlocker.Unlock(req.Context)
+
+		var manifestCid cid.Cid
+		it := req.Files.Entries()
+		for it.Next() {
+			file := files.FileFromEntry(it)
+			if file == nil {
+				return errors.New("expected a file handle")
+			}
+			roots, err := importCarBlocks(req.Context, n.Blocks, file)
+			if err != nil {
+				return fmt.Errorf("importing %s: %w", it.Name(), err)
+			}
+			if !manifestCid.Defined() && len(roots) > 0 {
+				manifestCid = roots[0]
+			}
+		}
+		if it.Err() != nil {
+			return it.Err()
+		}
+
+		if !manifestCid.Defined() {
+			return errors.New("no pin manifest found in the CAR files")
+		}
+		blk, err := n.Blockstore.Get(req.Context, manifestCid)
+		if err != nil {
+			return fmt.Errorf("reading pin manifest: %w", err)
+		}
+		var manifest PinManifest
+		if err := json.Unmarshal(blk.RawData(), &manifest); err != nil || manifest.Type != pinManifestType {
+			return errors.New("the first root of the CAR files is not a pin manifest, use 'ipfs dag import' for other CARs")
+		}
+
+		decoder := ipldlegacy.NewDecoder()
+		quotaUser, pinQuota := n.RPCQuotas.PinQuotaUser(req.Context)
+		for _, entry := range manifest.Pins {
+			out := RepoCarImportOutput{PinManifestEntry: entry}
+			err := func() error {
+				c, err := cid.Decode(entry.Cid)
+				if err != nil {
+					return err
+				}
+				blk, err := n.Blockstore.Get(req.Context, c)
+				if err != nil {
+					return err
+				}
+				nd, err := decoder.DecodeNode(req.Context, blk)
+				if err != nil {
+					return err
+				}
+				recursive := entry.Type == "recursive"
+				pin := func(ctx context.Context) error {
+					return n.Pinning.Pin(ctx, nd, recursive, entry.Name)
+				}
+				// like 'ipfs pin add', recursive pins made with a token
+				// are charged against its quota once their DAG is local
+				if pinQuota && recursive {
+					if err := merkledag.FetchGraph(req.Context, c, n.DAG); err != nil {
+						return err
+					}
+					return n.RPCQuotas.ChargePins(req.Context, quotaUser, []cid.Cid{c}, pin)
+				}
+				return pin(req.Context)
+			}()
+			if err != nil {
+				out.Error = err.Error()
+			}
+			if err := res.Emit(&out); err != nil {
+				return err
+			}
+		}
+		return n.Pinning.Flush(req.Context)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RepoCarImportOutput) error {
+			if out.Error != "" {
+				_, err := fmt.Fprintf(w, "failed to pin %s %s: %s\n", out.Cid, out.Type, out.Error)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "pinned %s %s\n", out.Cid, out.Type)
+			return err
+		}),
+	},
+}
+
+// importCarBlocks adds every block of a CARv1 or CARv2 file and returns the
+// roots listed in its header.
+func importCarBlocks(ctx context.Context, bserv blockservice.BlockService, r io.Reader) ([]cid.Cid, error) {
+	// Files sent over the RPC API implement io.Seeker but fail to seek; hide
+	// it so the CARv2 header is skipped by reading instead.
+	car, err := gocarv2.NewBlockReader(struct{ io.Reader }{r})
+	if err != nil {
+		return nil, err
+	}
+
+	const batchSize = 256
+	batch := make([]blocks.Block, 0, batchSize)
+	for {
+		blk, err := car.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, blk)
+		if len(batch) == batchSize {
+			if err := bserv.AddBlocks(ctx, batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := bserv.AddBlocks(ctx, batch); err != nil {
+			return nil, err
+		}
+	}
+	return car.Roots, nil
+}
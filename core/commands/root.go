@@ -60,8 +60,10 @@ ADVANCED COMMANDS
   name          Publish and resolve IPNS names
   key           Create and list IPNS name keypairs
   pin           Pin objects to local storage
+  search        Find pinned and MFS files by name (experimental)
   repo          Manipulate the IPFS repository
   stats         Various operational stats
+  schedule      Inspect the tasks scheduled in the daemon
   p2p           Libp2p stream mounting (experimental)
   filestore     Manage the filestore (experimental)
   mount         Mount an IPFS read-only mount point (experimental)
@@ -69,15 +71,21 @@ ADVANCED COMMANDS
 NETWORK COMMANDS
   id            Show info about IPFS peers
   bootstrap     Add or remove bootstrap peers
+  autoconf      Inspect the network defaults fetched with AutoConf
   swarm         Manage connections to the p2p network
   dht           Query the DHT for values or peers
   routing       Issue routing commands
+  provide       Manage the announcements of the provider system
+  gateway       Inspect the HTTP gateway
   ping          Measure the latency of a connection
   bitswap       Inspect bitswap state
+  cancel        Cancel the retrieval of a CID
+  fetch         Plan the retrieval of a DAG from the peers that have it
   pubsub        Send and receive messages via pubsub
 
 TOOL COMMANDS
   config        Manage configuration
+  features      List and toggle the experimental features
   version       Show IPFS version information
   diag          Generate diagnostic reports
   update        Download and apply go-ipfs updates
@@ -129,19 +137,28 @@ var rootSubcommands = map[string]*cmds.Command{
 	"bitswap":   BitswapCmd,
 	"block":     BlockCmd,
 	"cat":       CatCmd,
+	"cancel":    CancelCmd,
 	"commands":  CommandsDaemonCmd,
 	"files":     FilesCmd,
 	"filestore": FileStoreCmd,
+	"fetch":     FetchCmd,
 	"get":       GetCmd,
 	"pubsub":    PubsubCmd,
 	"repo":      RepoCmd,
 	"stats":     StatsCmd,
 	"bootstrap": BootstrapCmd,
+	"autoconf":  AutoConfCmd,
 	"config":    ConfigCmd,
 	"dag":       dag.DagCmd,
 	"dht":       DhtCmd,
 	"routing":   RoutingCmd,
+	"schedule":  ScheduleCmd,
+	"search":    SearchCmd,
 	"diag":      DiagCmd,
+	"features":  FeaturesCmd,
+	"gateway":   GatewayCmd,
+	"dns":       DNSCmd,
+	"heal":      HealCmd,
 	"id":        IDCmd,
 	"key":       KeyCmd,
 	"log":       LogCmd,
@@ -151,6 +168,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"object":    ocmd.ObjectCmd,
 	"pin":       pin.PinCmd,
 	"ping":      PingCmd,
+	"provide":   ProvideCmd,
 	"p2p":       P2PCmd,
 	"refs":      RefsCmd,
 	"resolve":   ResolveCmd,
@@ -18,8 +18,10 @@ import (
 	ipld "github.com/ipfs/go-ipld-format"
 	iface "github.com/ipfs/kubo/core/coreiface"
 	"github.com/ipfs/kubo/core/coreiface/options"
+	irouting "github.com/ipfs/kubo/routing"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 	routing "github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
 )
 
 var errAllowOffline = errors.New("can't put while offline: pass `--allow-offline` to override")
@@ -37,11 +39,15 @@ var RoutingCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
+		"events":    eventsRoutingCmd,
 		"findprovs": findProvidersRoutingCmd,
 		"findpeer":  findPeerRoutingCmd,
 		"get":       getValueRoutingCmd,
+		"inspect":   inspectRoutingCmd,
 		"put":       putValueRoutingCmd,
 		"provide":   provideRefRoutingCmd,
+		"reprovide": reprovideRoutingCmd,
+		"table":     routingTableCmd,
 	},
 }
 
@@ -132,13 +138,23 @@ var findProvidersRoutingCmd = &cmds.Command{
 }
 
 const (
-	recursiveOptionName = "recursive"
+	recursiveOptionName        = "recursive"
+	provideBatchSizeOptionName = "batch-size"
+	provideProgressOptionName  = "progress"
 )
 
 var provideRefRoutingCmd = &cmds.Command{
 	Status: cmds.Experimental,
 	Helptext: cmds.HelpText{
 		Tagline: "Announce to the network that you are providing given values.",
+		ShortDescription: `
+Announces the given keys to the routing system.
+
+With --recursive, the blocks of the DAGs of the keys are announced in batches
+of --batch-size, which the routing system announces together when it supports
+it (the accelerated DHT client and HTTP routers do). --progress prints the
+number of blocks announced after each batch.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -147,6 +163,8 @@ var provideRefRoutingCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption(dhtVerboseOptionName, "v", "Print extra information."),
 		cmds.BoolOption(recursiveOptionName, "r", "Recursively provide entire graph."),
+		cmds.IntOption(provideBatchSizeOptionName, "Number of blocks announced together with --recursive.").WithDefault(1000),
+		cmds.BoolOption(provideProgressOptionName, "Print the number of blocks announced with --recursive."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
@@ -170,6 +188,11 @@ var provideRefRoutingCmd = &cmds.Command{
 		}
 
 		rec, _ := req.Options[recursiveOptionName].(bool)
+		batchSize, _ := req.Options[provideBatchSizeOptionName].(int)
+		if batchSize < 1 {
+			return fmt.Errorf("%s must be greater than 0", provideBatchSizeOptionName)
+		}
+		progress, _ := req.Options[provideProgressOptionName].(bool)
 
 		var cids []cid.Cid
 		for _, arg := range req.Arguments {
@@ -197,7 +220,7 @@ var provideRefRoutingCmd = &cmds.Command{
 		go func() {
 			defer cancel()
 			if rec {
-				provideErr = provideKeysRec(ctx, nd.Routing, nd.DAG, cids)
+				provideErr = provideKeysRec(ctx, nd.Routing, nd.DAG, cids, batchSize, progress)
 			} else {
 				provideErr = provideKeys(ctx, nd.Routing, cids)
 			}
@@ -226,6 +249,11 @@ var provideRefRoutingCmd = &cmds.Command{
 					}
 					return nil
 				},
+				routing.Value: func(obj *routing.QueryEvent, out io.Writer, verbose bool) error {
+					// progress of --recursive
+					fmt.Fprintln(out, obj.Extra)
+					return nil
+				},
 			}
 
 			verbose, _ := req.Options[dhtVerboseOptionName].(bool)
@@ -245,29 +273,35 @@ func provideKeys(ctx context.Context, r routing.Routing, cids []cid.Cid) error {
 	return nil
 }
 
-func provideKeysRec(ctx context.Context, r routing.Routing, dserv ipld.DAGService, cids []cid.Cid) error {
-	provided := cid.NewSet()
+// provideKeysRec announces the blocks of the DAGs of cids, batchSize at a
+// time. With progress, the number of blocks announced is published as a
+// routing.Value query event after each batch.
+func provideKeysRec(ctx context.Context, r irouting.ProvideManyRouter, dserv ipld.DAGService, cids []cid.Cid, batchSize int, progress bool) error {
+	kset := cid.NewSet()
 	for _, c := range cids {
-		kset := cid.NewSet()
-
 		err := dag.Walk(ctx, dag.GetLinksDirect(dserv), c, kset.Visit)
 		if err != nil {
 			return err
 		}
+	}
 
-		for _, k := range kset.Keys() {
-			if provided.Has(k) {
-				continue
-			}
-
-			err = r.Provide(ctx, k, true)
-			if err != nil {
-				return err
-			}
-			provided.Add(k)
+	keys := kset.Keys()
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+		mhs := make([]multihash.Multihash, len(batch))
+		for i, k := range batch {
+			mhs[i] = k.Hash()
+		}
+		if err := r.ProvideMany(ctx, mhs); err != nil {
+			return err
+		}
+		if progress {
+			routing.PublishQueryEvent(ctx, &routing.QueryEvent{
+				Type:  routing.Value,
+				Extra: fmt.Sprintf("provided %d of %d blocks", start+len(batch), len(keys)),
+			})
 		}
 	}
-
 	return nil
 }
 
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	irouting "github.com/ipfs/kubo/routing"
+)
+
+const routingEventsFollowOptionName = "follow"
+
+var eventsRoutingCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Print the events of the routing operations of the daemon.",
+		ShortDescription: `
+Prints the last routing operations of the daemon: the provides, the lookups of
+providers, peers and values, with the time they took, the number of results
+they returned and their error, if any.
+
+With --follow, the events of the operations started from now on are streamed
+until the command is interrupted: the operations starting and finishing, and
+the query events of the DHT, such as the peers queried, the closer peers they
+returned and the providers they knew. Use --enc=json to get one JSON object
+per line. Events are dropped when they are not read fast enough.
+
+  > ipfs routing events --follow --enc=json | jq 'select(.Type == "finished")'
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(routingEventsFollowOptionName, "f", "Stream the events of the new operations."),
+	},
+	NoLocal: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline || nd.RoutingEvents == nil {
+			return ErrNotOnline
+		}
+
+		if follow, _ := req.Options[routingEventsFollowOptionName].(bool); !follow {
+			for _, ev := range nd.RoutingEvents.History() {
+				if err := res.Emit(&ev); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for ev := range nd.RoutingEvents.Follow(req.Context) {
+			if err := res.Emit(&ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: irouting.Event{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ev *irouting.Event) error {
+			line := fmt.Sprintf("%s %d %s %s %s", ev.Time.Format(time.StampMilli), ev.Op, ev.Method, ev.Key, ev.Type)
+			switch {
+			case ev.Type == irouting.EventFinished:
+				line += fmt.Sprintf(" in %s, %d results", ev.Duration.Round(time.Millisecond), ev.Results)
+			case ev.Peer != "":
+				line += " " + ev.Peer
+			}
+			if len(ev.Peers) > 0 {
+				line += ": " + strings.Join(ev.Peers, " ")
+			}
+			if ev.Error != "" {
+				line += ": " + ev.Error
+			}
+			_, err := fmt.Fprintln(w, line)
+			return err
+		}),
+	},
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	irouting "github.com/ipfs/kubo/routing"
+)
+
+const routingInspectTestOptionName = "test"
+
+type routingInspectOutput struct {
+	irouting.Inspection
+	// Test is the CID given with --test, Trace the routers queried for its
+	// providers and Providers the number of providers found.
+	Test      string                 `json:",omitempty"`
+	Trace     []irouting.RouterEvent `json:",omitempty"`
+	Providers int                    `json:",omitempty"`
+}
+
+var inspectRoutingCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Validate and print the custom routing configuration.",
+		ShortDescription: `
+Validates Routing.Routers and Routing.Methods as the daemon does with
+Routing.Type set to "custom", and prints the router tree used by each method:
+the parallel and sequential routers, the timeouts, delays and ignored errors of
+the routers they compose, the HTTP endpoints and the DHT modes. Valid settings
+that are likely mistakes, such as unused routers, are reported as warnings.
+
+The configuration is read from the repo, so changes are inspected before the
+daemon is restarted. The --routing option of 'ipfs daemon' is not taken into
+account.
+
+With --test, the running daemon looks for providers of the given CID, and the
+routers queried are printed with the time they took and the number of
+providers they returned.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(routingInspectTestOptionName, "Find providers of a CID and trace the routers queried."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := nd.Repo.Config()
+		if err != nil {
+			return err
+		}
+		if typ := cfg.Routing.Type.WithDefault("auto"); typ != "custom" {
+			return fmt.Errorf("Routing.Type is %q, only custom routing is composed from Routing.Routers", typ)
+		}
+		inspection, err := irouting.Inspect(cfg.Routing.Routers, cfg.Routing.Methods)
+		if err != nil {
+			return fmt.Errorf("invalid custom routing: %w", err)
+		}
+		out := &routingInspectOutput{Inspection: *inspection}
+
+		test, _ := req.Options[routingInspectTestOptionName].(string)
+		if test == "" {
+			return cmds.EmitOnce(res, out)
+		}
+		c, err := cid.Decode(test)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid CID %q: %s", test, err)
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		ctx, trace := irouting.WithTrace(req.Context)
+		for range nd.Routing.FindProvidersAsync(ctx, c, 0) {
+			out.Providers++
+		}
+		out.Test = c.String()
+		out.Trace = trace.Events()
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *routingInspectOutput) error {
+			for _, m := range out.Methods {
+				fmt.Fprintf(w, "%s:\n", m.Method)
+				writeRouterTree(w, m.Router, 1)
+			}
+			for _, warning := range out.Warnings {
+				fmt.Fprintf(w, "warning: %s\n", warning)
+			}
+			if out.Test == "" {
+				return nil
+			}
+			fmt.Fprintf(w, "\nfind-providers %s:\n", out.Test)
+			for _, ev := range out.Trace {
+				fmt.Fprintf(w, "  %s: %d providers in %s", ev.Router, ev.Results, ev.Duration)
+				if ev.Error != "" {
+					fmt.Fprintf(w, " (%s)", ev.Error)
+				}
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "found %d providers\n", out.Providers)
+			return nil
+		}),
+	},
+	Type: routingInspectOutput{},
+}
+
+func writeRouterTree(w io.Writer, t *irouting.RouterTree, depth int) {
+	details := []string{string(t.Type)}
+	if t.Endpoint != "" {
+		details = append(details, t.Endpoint)
+	}
+	if t.Mode != "" {
+		details = append(details, "mode "+t.Mode)
+	}
+	if t.Timeout > 0 {
+		details = append(details, "timeout "+t.Timeout.String())
+	}
+	if t.ExecuteAfter > 0 {
+		details = append(details, "after "+t.ExecuteAfter.String())
+	}
+	if t.IgnoreErrors {
+		details = append(details, "ignore errors")
+	}
+	fmt.Fprintf(w, "%s%s (%s)\n", strings.Repeat("  ", depth), t.Name, strings.Join(details, ", "))
+	for _, sub := range t.Routers {
+		writeRouterTree(w, sub, depth+1)
+	}
+}
@@ -0,0 +1,396 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/exchange/offline"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	irouting "github.com/ipfs/kubo/routing"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	ddht "github.com/libp2p/go-libp2p-kad-dht/dual"
+	"github.com/libp2p/go-libp2p-kad-dht/fullrt"
+	dhtpb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	routing "github.com/libp2p/go-libp2p/core/routing"
+	"github.com/libp2p/go-msgio"
+	"github.com/multiformats/go-multihash"
+)
+
+const reprovideVerifyOptionName = "verify"
+
+// reprovideRouterResult is the outcome of 'ipfs routing reprovide' for one
+// router.
+type reprovideRouterResult struct {
+	Router string
+	// Announced is the number of blocks announced to the router, and
+	// AnnounceError why they could not be. Both are unset for routers only
+	// used to find providers.
+	Announced     int    `json:",omitempty"`
+	AnnounceError string `json:",omitempty"`
+	// Verified tells whether the router was queried for the providers of
+	// the root, and Found whether it returned this node. For DHTs, Queried
+	// closest peers of the root are asked for their records directly, and
+	// FoundOn is the number of them holding the record of this node.
+	Verified    bool
+	Found       bool
+	Queried     int    `json:",omitempty"`
+	FoundOn     int    `json:",omitempty"`
+	VerifyError string `json:",omitempty"`
+}
+
+type reprovideOutput struct {
+	Cid     string
+	Blocks  int
+	Routers []reprovideRouterResult
+}
+
+var reprovideRoutingCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Announce a CID now and verify it can be found.",
+		ShortDescription: `
+Announces the given root and the blocks below it selected by
+Reprovider.Strategy to each router, and then checks that each router returns
+this node as a provider of the root, for example to answer reports of content
+that cannot be found.
+
+With the "roots" strategy only the root is announced. Otherwise the blocks of
+the DAG present in the local blockstore are announced too.
+
+DHTs are verified by asking the closest peers of the root for their provider
+records, without using the records stored by this node. The results are
+reported separately for each router, including the routers only used to find
+providers, such as the default HTTP routers.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, false, "The root to announce."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(reprovideVerifyOptionName, "Check that the routers return this node as provider.").WithDefault(true),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		root, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid CID %q: %s", req.Arguments[0], err)
+		}
+		has, err := nd.Blockstore.Has(req.Context, root)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return fmt.Errorf("block %s not found locally, cannot provide", root)
+		}
+
+		cfg, err := nd.Repo.Config()
+		if err != nil {
+			return err
+		}
+		keys := []cid.Cid{root}
+		if cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy) != "roots" {
+			offlineDag := dag.NewDAGService(blockservice.New(nd.Blockstore, offline.Exchange(nd.Blockstore)))
+			keys, err = localDAGKeys(req.Context, offlineDag, root)
+			if err != nil {
+				return err
+			}
+		}
+
+		out := &reprovideOutput{Cid: root.String(), Blocks: len(keys)}
+		verify, _ := req.Options[reprovideVerifyOptionName].(bool)
+		for _, r := range leafRouters(nd.Routing) {
+			result := reprovideRouterResult{Router: r.name}
+			if r.announce {
+				result.Announced, err = announce(req.Context, r.router, keys)
+				if err != nil {
+					result.AnnounceError = err.Error()
+				}
+			}
+			if verify && r.find {
+				result.Verified = true
+				if err := verifyProvider(req.Context, nd.PeerHost, r, root, &result); err != nil {
+					result.VerifyError = err.Error()
+				}
+			}
+			if r.announce || result.Verified {
+				out.Routers = append(out.Routers, result)
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *reprovideOutput) error {
+			fmt.Fprintf(w, "%s: %d blocks\n", out.Cid, out.Blocks)
+			for _, r := range out.Routers {
+				fmt.Fprintf(w, "  %s:", r.Router)
+				if r.AnnounceError != "" {
+					fmt.Fprintf(w, " announced %d blocks (%s);", r.Announced, r.AnnounceError)
+				} else if r.Announced > 0 {
+					fmt.Fprintf(w, " announced %d blocks;", r.Announced)
+				}
+				switch {
+				case !r.Verified:
+				case r.Queried > 0:
+					fmt.Fprintf(w, " record on %d of %d closest peers", r.FoundOn, r.Queried)
+				case r.Found:
+					fmt.Fprint(w, " found")
+				default:
+					fmt.Fprint(w, " not found")
+				}
+				if r.VerifyError != "" {
+					fmt.Fprintf(w, " (%s)", r.VerifyError)
+				}
+				fmt.Fprintln(w)
+			}
+			return nil
+		}),
+	},
+	Type: reprovideOutput{},
+}
+
+// localDAGKeys returns root and the blocks below it found in dserv.
+func localDAGKeys(ctx context.Context, dserv ipld.DAGService, root cid.Cid) ([]cid.Cid, error) {
+	var keys []cid.Cid
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		links, err := dag.GetLinksDirect(dserv)(ctx, c)
+		if ipld.IsNotFound(err) {
+			return nil, nil
+		}
+		if err == nil {
+			keys = append(keys, c)
+		}
+		return links, err
+	}
+	if err := dag.Walk(ctx, getLinks, root, cid.NewSet().Visit); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+type leafRouter struct {
+	name   string
+	router routing.ContentRouting
+	// protocol is the DHT protocol of DHT routers.
+	protocol protocol.ID
+
+	announce, find bool
+}
+
+// leafRouters returns the routers composed in r, and whether they are used
+// to announce and to find providers.
+func leafRouters(r routing.Routing) []*leafRouter {
+	var leaves []*leafRouter
+	seen := make(map[routing.ContentRouting]*leafRouter)
+	var walk func(r routing.ContentRouting, announce bool, name string, proto protocol.ID)
+	walk = func(r routing.ContentRouting, announce bool, name string, proto protocol.ID) {
+		switch r := r.(type) {
+		case nil, routinghelpers.Null, *routinghelpers.Null:
+			return
+		case *irouting.Composer:
+			if announce {
+				walk(r.ProvideRouter, true, "", "")
+			} else {
+				walk(r.FindProvidersRouter, false, "", "")
+			}
+			return
+		case *routinghelpers.Compose:
+			walk(r.ContentRouting, announce, "", "")
+			return
+		case *ddht.DHT:
+			walk(r.WAN, announce, "dht-wan", dht.ProtocolDHT)
+			walk(r.LAN, announce, "dht-lan", dht.DefaultPrefix+ddht.LanExtension+"/kad/1.0.0")
+			return
+		case interface{ Unwrap() routing.Routing }:
+			walk(r.Unwrap(), announce, name, proto)
+			return
+		case routinghelpers.ComposableRouter:
+			for _, sub := range r.Routers() {
+				walk(sub, announce, "", "")
+			}
+			return
+		}
+
+		if reflect.ValueOf(r).Kind() != reflect.Pointer {
+			// Values cannot be told apart.
+			leaves = append(leaves, &leafRouter{name: routerName(r, name), router: r, announce: announce, find: !announce})
+			return
+		}
+		leaf, ok := seen[r]
+		if !ok {
+			leaf = &leafRouter{name: routerName(r, name), router: r, protocol: proto}
+			if _, ok := r.(*fullrt.FullRT); ok {
+				leaf.protocol = dht.ProtocolDHT
+			}
+			seen[r] = leaf
+			leaves = append(leaves, leaf)
+		}
+		if announce {
+			leaf.announce = true
+		} else {
+			leaf.find = true
+		}
+	}
+	walk(r, true, "", "")
+	walk(r, false, "", "")
+	return leaves
+}
+
+func routerName(r routing.ContentRouting, name string) string {
+	if name != "" {
+		return name
+	}
+	switch r := r.(type) {
+	case *dht.IpfsDHT:
+		return "dht"
+	case *fullrt.FullRT:
+		return "dht-accelerated"
+	case interface{ Endpoint() string }:
+		return r.Endpoint()
+	}
+	return fmt.Sprintf("%T", r)
+}
+
+// announce provides keys with r, and returns the number of keys provided.
+func announce(ctx context.Context, r routing.ContentRouting, keys []cid.Cid) (int, error) {
+	if pm, ok := r.(routinghelpers.ProvideManyRouter); ok {
+		mhs := make([]multihash.Multihash, len(keys))
+		for i, k := range keys {
+			mhs[i] = k.Hash()
+		}
+		if err := pm.ProvideMany(ctx, mhs); err != nil {
+			return 0, err
+		}
+		return len(keys), nil
+	}
+	for i, k := range keys {
+		if err := r.Provide(ctx, k, true); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
+
+// verifyProvider checks that r returns self as provider of root. DHTs are
+// verified by asking the closest peers of root for their records.
+func verifyProvider(ctx context.Context, h host.Host, r *leafRouter, root cid.Cid, result *reprovideRouterResult) error {
+	closest, ok := r.router.(interface {
+		GetClosestPeers(context.Context, string) ([]peer.ID, error)
+	})
+	if !ok || r.protocol == "" {
+		for p := range r.router.FindProvidersAsync(ctx, root, 0) {
+			if p.ID == h.ID() {
+				result.Found = true
+			}
+		}
+		return ctx.Err()
+	}
+
+	peers, err := closest.GetClosestPeers(ctx, string(root.Hash()))
+	if err != nil {
+		return err
+	}
+	messenger, err := dhtpb.NewProtocolMessenger(&dhtRequester{host: h, protocol: r.protocol})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range peers {
+		result.Queried++
+		provs, _, err := messenger.GetProviders(ctx, p, root.Hash())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p, err))
+			continue
+		}
+		for _, prov := range provs {
+			if prov.ID == h.ID() {
+				result.FoundOn++
+				break
+			}
+		}
+	}
+	result.Found = result.FoundOn > 0
+	if len(errs) == len(peers) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// dhtRequester sends single DHT requests over new streams.
+type dhtRequester struct {
+	host     host.Host
+	protocol protocol.ID
+}
+
+func (r *dhtRequester) SendRequest(ctx context.Context, p peer.ID, pmes *dhtpb.Message) (*dhtpb.Message, error) {
+	s, err := r.host.NewStream(ctx, p, r.protocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	data, err := pmes.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err := msgio.NewVarintWriter(s).WriteMsg(data); err != nil {
+		return nil, err
+	}
+	reader := msgio.NewVarintReaderSize(s, network.MessageSizeMax)
+	data, err = reader.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.ReleaseMsg(data)
+	resp := new(dhtpb.Message)
+	if err := resp.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *dhtRequester) SendMessage(ctx context.Context, p peer.ID, pmes *dhtpb.Message) error {
+	s, err := r.host.NewStream(ctx, p, r.protocol)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	data, err := pmes.Marshal()
+	if err != nil {
+		return err
+	}
+	return msgio.NewVarintWriter(s).WriteMsg(data)
+}
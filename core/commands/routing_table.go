@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node/libp2p"
+)
+
+var routingTableCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Export and import the routing table of the DHT.",
+		ShortDescription: `
+Short-lived nodes spend their first minutes filling the routing table of the
+DHT before their lookups and provides succeed. The routing table can be
+exported from a node that ran for a while and imported into a new one:
+
+  ipfs routing table export > routing-table.json
+  ipfs routing table import routing-table.json
+
+With Routing.PersistTable, the daemon saves its routing table in the repo on
+shutdown and preloads it on startup.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"export": routingTableExportCmd,
+		"import": routingTableImportCmd,
+	},
+}
+
+var routingTableExportCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Print the routing table of the DHT, with the addresses of its peers.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		snapshot, err := libp2p.SnapshotRoutingTable(nd.PeerHost, nd.DHT)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &snapshot)
+	},
+	Type: libp2p.RoutingTableSnapshot{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *libp2p.RoutingTableSnapshot) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		}),
+	},
+}
+
+type routingTableImportOutput struct {
+	Added int
+}
+
+var routingTableImportCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Add the peers of an exported routing table to the routing table of the DHT.",
+		ShortDescription: `
+The peers are added to the routing table without being dialed. Those that
+turn out unreachable are evicted by the DHT as usual.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("file", true, false, "The routing table printed by 'ipfs routing table export'.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		var snapshot libp2p.RoutingTableSnapshot
+		if err := json.NewDecoder(file).Decode(&snapshot); err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid routing table: %s", err)
+		}
+
+		added, err := libp2p.RestoreRoutingTable(nd.PeerHost, nd.DHT, snapshot)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &routingTableImportOutput{Added: added})
+	},
+	Type: routingTableImportOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *routingTableImportOutput) error {
+			_, err := fmt.Fprintf(w, "Added %d peers to the routing table\n", out.Added)
+			return err
+		}),
+	},
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+var ScheduleCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the tasks scheduled in the daemon.",
+		ShortDescription: `
+The daemon runs the commands listed in the Schedule.Tasks config
+periodically, for example 'repo gc' every night or 'pin verify' once a week.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls": scheduleLsCmd,
+	},
+}
+
+type scheduleLsOutput struct {
+	Tasks []node.ScheduledTaskStatus
+}
+
+var scheduleLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List scheduled tasks and the status of their last run.",
+		ShortDescription: `
+Lists every Schedule.Tasks entry with its next and last run and the error of
+the last run, if any. A run is skipped, not started concurrently, when the
+previous one of the same task is still running.
+
+Tasks only run in the daemon: without it no next run is shown.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &scheduleLsOutput{Tasks: nd.Scheduler.Status()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *scheduleLsOutput) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+
+			fmt.Fprintln(tw, "NAME\tCOMMAND\tNEXT RUN\tLAST RUN\tRUNS\tSKIPPED\tSTATUS")
+			for _, t := range out.Tasks {
+				status := "ok"
+				switch {
+				case t.Running:
+					status = "running"
+				case t.Runs == 0:
+					status = "-"
+				case t.LastError != "":
+					status = "error: " + t.LastError
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+					t.Name,
+					strings.Join(t.Command, " "),
+					scheduleTime(t.NextRun),
+					scheduleTime(t.LastRun),
+					t.Runs,
+					t.Skipped,
+					status,
+				)
+			}
+			return nil
+		}),
+	},
+	Type: scheduleLsOutput{},
+}
+
+func scheduleTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/core/node"
+)
+
+const searchRootOptionName = "root"
+
+var SearchCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Find pinned and MFS files by name.",
+		ShortDescription: `
+Prints the files and directories whose name matches the given glob pattern,
+from the index of the UnixFS directories pinned recursively or in MFS kept
+with Search.Enabled. The index is updated in the background as pins and MFS
+change, and only the blocks in the repo are indexed.
+
+The pattern uses the syntax of Go's path.Match, where '*' matches any
+sequence of characters but '/'. A pattern with a '/' is matched against the
+whole path of the entries under their root instead of their name.
+
+  > ipfs search 'report*.pdf' --root=bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi
+  /ipfs/bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi/2024/report-q1.pdf
+
+Without --root, all the pinned directories are searched, and the entries
+found in MFS are printed with their MFS path.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("pattern", true, false, "Glob pattern of the names to find."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(searchRootOptionName, "Only search under this pinned or MFS directory."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.SearchIndex == nil {
+			return errors.New("the search index is only kept with Search.Enabled set")
+		}
+
+		var root cid.Cid
+		var prefix string
+		if r, _ := req.Options[searchRootOptionName].(string); r != "" {
+			api, err := cmdenv.GetApi(env, req)
+			if err != nil {
+				return err
+			}
+			p, err := cmdutils.PathOrCidPath(r)
+			if err != nil {
+				return err
+			}
+			rp, _, err := api.ResolvePath(req.Context, p)
+			if err != nil {
+				return err
+			}
+			root, prefix = rp.RootCid(), p.String()
+		}
+
+		return nd.SearchIndex.Search(req.Context, req.Arguments[0], root, prefix, func(r node.SearchResult) error {
+			return res.Emit(&r)
+		})
+	},
+	Type: node.SearchResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, r *node.SearchResult) error {
+			_, err := fmt.Fprintln(w, cmdenv.EscNonPrint(r.Path))
+			return err
+		}),
+	},
+}
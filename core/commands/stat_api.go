@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+type apiStatOutput struct {
+	Commands []node.RPCCommandStat
+}
+
+var statAPICmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Report the calls of the RPC API commands.",
+		ShortDescription: `
+Reports, for every RPC command called since the daemon started, the number of
+calls, how many of them failed, and the 50th, 90th and 99th percentiles of the
+latency of the last 1000 calls, the most called commands first. The JSON
+output also counts the calls made with each API.Authorizations token.
+
+The same counters are exposed as the ipfs_rpc_command_duration_seconds and
+ipfs_rpc_command_errors_total Prometheus metrics.
+
+This interface is not stable and may change from release to release.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &apiStatOutput{Commands: nd.RPCStats.Stats()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *apiStatOutput) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+
+			fmt.Fprintln(tw, "COMMAND\tCALLS\tERRORS\tP50\tP90\tP99")
+			for _, c := range out.Commands {
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n",
+					c.Command, c.Calls, c.Errors,
+					humanDuration(c.P50), humanDuration(c.P90), humanDuration(c.P99))
+			}
+			return nil
+		}),
+	},
+	Type: apiStatOutput{},
+}
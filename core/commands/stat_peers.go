@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node/libp2p"
+)
+
+const statPeersWindowOptionName = "window"
+
+var statPeersCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Report peer churn statistics.",
+		ShortDescription: `
+Reports how many peer sessions opened and closed within a time window, the
+churn rate, the median and 90th percentile session lifetime, and why
+sessions ended.
+
+A session spans from the first connection to a peer opening until the last
+one closing. Disconnect causes are:
+
+  connmgr    closed right after a connection manager trim
+  transient  a limited (relayed) session ended
+  shutdown   the node was shutting down
+  other      remote closes, timeouts and other network errors
+
+A high share of 'connmgr' disconnects with short lifetimes suggests
+Swarm.ConnMgr watermarks are too low; a high share of 'other' points at
+network problems. Attribution to the connection manager is based on timing
+and is a best effort.
+
+History covers at most the last 24 hours.
+
+This interface is not stable and may change from release to release.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(statPeersWindowOptionName, "w", "Time window to report on.").WithDefault("1h"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		windowStr, _ := req.Options[statPeersWindowOptionName].(string)
+		window, err := time.ParseDuration(windowStr)
+		if err != nil {
+			return cmds.Errorf(cmds.ErrClient, "invalid window %q: %s", windowStr, err)
+		}
+		if window <= 0 {
+			return cmds.Errorf(cmds.ErrClient, "window must be positive")
+		}
+
+		if nd.PeerChurn == nil {
+			return fmt.Errorf("peer churn tracking is not available")
+		}
+
+		return res.Emit(nd.PeerChurn.Stats(window))
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *libp2p.PeerChurnStats) error {
+			wtr := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer wtr.Flush()
+
+			fmt.Fprintf(wtr, "Window:\t%s\n", s.Window)
+			fmt.Fprintf(wtr, "Connected:\t%s\n", humanNumber(s.Connected))
+			fmt.Fprintf(wtr, "Opened:\t%s\n", humanNumber(s.Opened))
+			fmt.Fprintf(wtr, "Closed:\t%s\n", humanNumber(s.Closed))
+			fmt.Fprintf(wtr, "ChurnPerMinute:\t%.2f\n", s.ChurnPerMinute)
+			fmt.Fprintf(wtr, "MedianLifetime:\t%s\n", humanDuration(s.MedianLifetime))
+			fmt.Fprintf(wtr, "P90Lifetime:\t%s\n", humanDuration(s.P90Lifetime))
+
+			causes := make([]string, 0, len(s.Causes))
+			for c := range s.Causes {
+				causes = append(causes, c)
+			}
+			sort.Strings(causes)
+			fmt.Fprintf(wtr, "DisconnectCauses:\n")
+			for _, c := range causes {
+				fmt.Fprintf(wtr, "  %s:\t%s\n", c, humanNumber(s.Causes[c]))
+			}
+			return nil
+		}),
+	},
+	Type: libp2p.PeerChurnStats{},
+}
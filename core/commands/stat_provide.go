@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"io"
+	"sort"
 	"text/tabwriter"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/ipfs/boxo/provider"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
 	"golang.org/x/exp/constraints"
 )
 
@@ -19,6 +21,12 @@ var statProvideCmd = &cmds.Command{
 		ShortDescription: `
 Returns statistics about the content the node is advertising.
 
+When the reprovides are swept over Reprovider.Interval, the statistics also
+include the number of new keys waiting to be announced, the announcements
+that failed by cause, and the keys listed by each source of
+Reprovider.Strategy during the last sweep. With --enc=json, they can be
+collected by monitoring systems, to alert when reproviding falls behind.
+
 This interface is not stable and may change from release to release.
 `,
 	},
@@ -38,15 +46,19 @@ This interface is not stable and may change from release to release.
 		if err != nil {
 			return err
 		}
-
-		if err := res.Emit(stats); err != nil {
-			return err
+		out := &ProvideStatsOutput{ReproviderStats: stats}
+		if sweeper, ok := nd.Provider.(*node.SweepingProvider); ok {
+			ps, err := sweeper.ProvideStats(req.Context)
+			if err != nil {
+				return err
+			}
+			out.ProvideStats = &ps
 		}
 
-		return nil
+		return res.Emit(out)
 	},
 	Encoders: cmds.EncoderMap{
-		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *provider.ReproviderStats) error {
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *ProvideStatsOutput) error {
 			wtr := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
 			defer wtr.Flush()
 
@@ -54,10 +66,38 @@ This interface is not stable and may change from release to release.
 			fmt.Fprintf(wtr, "AvgProvideDuration:\t%s\n", humanDuration(s.AvgProvideDuration))
 			fmt.Fprintf(wtr, "LastReprovideDuration:\t%s\n", humanDuration(s.LastReprovideDuration))
 			fmt.Fprintf(wtr, "LastReprovideBatchSize:\t%s\n", humanNumber(s.LastReprovideBatchSize))
+			if s.ProvideStats == nil {
+				return nil
+			}
+			fmt.Fprintf(wtr, "QueueDepth:\t%s\n", humanNumber(s.QueueDepth))
+			fmt.Fprintf(wtr, "Announced:\t%s\n", humanNumber(s.Announced))
+			fmt.Fprintf(wtr, "Failures:\n")
+			fmt.Fprintf(wtr, "  Timeout:\t%s\n", humanNumber(s.Failures.Timeout))
+			fmt.Fprintf(wtr, "  NoPeers:\t%s\n", humanNumber(s.Failures.NoPeers))
+			fmt.Fprintf(wtr, "  Quota:\t%s\n", humanNumber(s.Failures.Quota))
+			fmt.Fprintf(wtr, "  Other:\t%s\n", humanNumber(s.Failures.Other))
+			if len(s.Keys) > 0 {
+				sources := make([]string, 0, len(s.Keys))
+				for source := range s.Keys {
+					sources = append(sources, source)
+				}
+				sort.Strings(sources)
+				fmt.Fprintf(wtr, "Keys:\n")
+				for _, source := range sources {
+					fmt.Fprintf(wtr, "  %s:\t%s\n", source, humanNumber(s.Keys[source]))
+				}
+			}
 			return nil
 		}),
 	},
-	Type: provider.ReproviderStats{},
+	Type: ProvideStatsOutput{},
+}
+
+// ProvideStatsOutput is the output of ipfs stats provide. The details of the
+// announcements are only known when the reprovides are swept.
+type ProvideStatsOutput struct {
+	provider.ReproviderStats
+	*node.ProvideStats `json:",omitempty"`
 }
 
 func humanDuration(val time.Duration) string {
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node/libp2p"
+)
+
+var statProvidersCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Report the provider records received by the DHT servers.",
+		ShortDescription: `
+Reports the provider records other peers sent to the DHT servers of the node:
+the records received, accepted and rejected since the daemon started, and the
+records accepted per second.
+
+The records stored, the CIDs they provide and the space they use in the
+datastore are counted every 10 minutes, and the records accepted since are
+added to the count. The records expired between two counts estimate the
+eviction rate.
+
+Routing.MaxProviderRecords caps the records stored: the new records over it
+are rejected, while the stored ones are still refreshed.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.DHT == nil || nd.ProviderRecords == nil {
+			return errors.New("the node runs no DHT, see Routing.Type")
+		}
+		stats := nd.ProviderRecords.Stats()
+		return cmds.EmitOnce(res, &stats)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *libp2p.ProviderRecordsStats) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+
+			maxRecords := "unlimited"
+			if s.MaxRecords > 0 {
+				maxRecords = humanNumber(s.MaxRecords)
+			}
+			fmt.Fprintf(tw, "Received:\t%s\n", humanNumber(s.Received))
+			fmt.Fprintf(tw, "Accepted:\t%s\n", humanNumber(s.Accepted))
+			fmt.Fprintf(tw, "Rejected:\t%s\n", humanNumber(s.Rejected))
+			fmt.Fprintf(tw, "Rate:\t%.2f records/s\n", s.Rate)
+			fmt.Fprintf(tw, "Records:\t%s of %s\n", humanNumber(s.Records), maxRecords)
+			fmt.Fprintf(tw, "UniqueCids:\t%s\n", humanNumber(s.UniqueCids))
+			fmt.Fprintf(tw, "Storage:\t%s\n", humanize.IBytes(s.Bytes))
+			fmt.Fprintf(tw, "Evicted:\t%s\n", humanNumber(s.Evicted))
+			fmt.Fprintf(tw, "EvictionRate:\t%.2f records/s\n", s.EvictionRate)
+			if s.LastScan.IsZero() {
+				fmt.Fprintf(tw, "LastScan:\tpending\n")
+			} else {
+				fmt.Fprintf(tw, "LastScan:\t%s ago\n", time.Since(s.LastScan).Truncate(time.Second))
+			}
+			return nil
+		}),
+	},
+	Type: libp2p.ProviderRecordsStats{},
+}
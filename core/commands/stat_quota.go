@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node"
+)
+
+type quotaStatOutput struct {
+	Tokens []node.RPCQuotaUsage
+}
+
+var statQuotaCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Report usage of the RPC API token quotas.",
+		ShortDescription: `
+Reports the limits and current usage of every API.Authorizations entry that
+has Quotas configured: the request rate, running adds, and the pins
+attributed to the token. Unlimited quotas are shown as '-' (0 in JSON).
+
+Rejected counts the requests refused for exceeding a quota since the daemon
+started.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		usage, err := nd.RPCQuotas.Usage(req.Context)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &quotaStatOutput{Tokens: usage})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *quotaStatOutput) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+
+			fmt.Fprintln(tw, "TOKEN\tREQ/S\tADDS\tPINS\tPINNED\tREJECTED")
+			for _, u := range out.Tokens {
+				fmt.Fprintf(tw, "%s\t%s\t%d/%s\t%d/%s\t%s/%s\t%d\n",
+					u.User,
+					quotaLimit(u.RequestsPerSecond),
+					u.ActiveAdds, quotaLimit(u.MaxConcurrentAdds),
+					u.Pins, quotaLimit(u.MaxPins),
+					humanize.IBytes(u.PinnedBytes), quotaBytesLimit(u.MaxPinnedBytes),
+					u.Rejected,
+				)
+			}
+			return nil
+		}),
+	},
+	Type: quotaStatOutput{},
+}
+
+func quotaLimit(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+func quotaBytesLimit(n uint64) string {
+	if n == 0 {
+		return "-"
+	}
+	return humanize.IBytes(n)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
 	mamask "github.com/whyrusleeping/multiaddr-filter"
@@ -56,10 +57,12 @@ ipfs peers in the internet.
 	Subcommands: map[string]*cmds.Command{
 		"addrs":      swarmAddrsCmd,
 		"connect":    swarmConnectCmd,
+		"dial":       swarmDialCmd,
 		"disconnect": swarmDisconnectCmd,
 		"filters":    swarmFiltersCmd,
 		"peers":      swarmPeersCmd,
 		"peering":    swarmPeeringCmd,
+		"peerstore":  swarmPeerstoreCmd,
 		"resources":  swarmResourcesCmd, // libp2p Network Resource Manager
 
 	},
@@ -73,6 +76,7 @@ const (
 	swarmResetLimitsOptionName       = "reset"
 	swarmUsedResourcesPercentageName = "min-used-limit-perc"
 	swarmIdentifyOptionName          = "identify"
+	swarmExplainOptionName           = "explain"
 )
 
 type peeringResult struct {
@@ -762,6 +766,193 @@ it will reconnect.
 	Type: stringList{},
 }
 
+type dialAddrExplain struct {
+	Address string
+	Delay   time.Duration
+	Result  string
+	Error   string `json:",omitempty"`
+}
+
+type dialExplainOutput struct {
+	ID        string
+	Connected bool
+	Address   string `json:",omitempty"`
+	Error     string `json:",omitempty"`
+	Addrs     []dialAddrExplain
+}
+
+const (
+	dialResultConnected    = "connected"
+	dialResultFailed       = "failed"
+	dialResultNotAttempted = "not attempted"
+	dialResultUndialable   = "undialable"
+)
+
+var swarmDialCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Dial a peer, optionally explaining address selection.",
+		ShortDescription: `
+'ipfs swarm dial' opens a connection to a peer given its peer ID or a
+multiaddr ending in /p2p/<peer-id>. When only a peer ID is given, known and
+routed addresses are used.
+
+With --explain, the candidate addresses are listed in the order the dialer
+ranks them, together with the delay after which each one is attempted and
+the outcome of the attempt (connected, failed with a reason, undialable, or
+not attempted because another address won first). The dial backoff for the
+peer is cleared so every address is tried anew. The command fails, after the
+explanation, when the peer could not be dialed.
+
+  > ipfs swarm dial --explain 12D3KooWA...
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "Peer ID or multiaddr of the peer to dial."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(swarmExplainOptionName, "Show candidate addresses, their ranking and per-address results."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		node, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !node.IsOnline {
+			return ErrNotOnline
+		}
+
+		var pi peer.AddrInfo
+		if pid, err := peer.Decode(req.Arguments[0]); err == nil {
+			pi.ID = pid
+		} else {
+			pis, err := parseAddresses(req.Context, req.Arguments, node.DNSResolver)
+			if err != nil {
+				return err
+			}
+			pi = pis[0]
+		}
+		if pi.ID == node.Identity {
+			return errors.New("cannot dial self")
+		}
+
+		explain, _ := req.Options[swarmExplainOptionName].(bool)
+		if !explain {
+			if err := node.PeerHost.Connect(req.Context, pi); err != nil {
+				return fmt.Errorf("dial %s failure: %w", pi.ID, err)
+			}
+			out := &dialExplainOutput{ID: pi.ID.String(), Connected: true}
+			if conns := node.PeerHost.Network().ConnsToPeer(pi.ID); len(conns) > 0 {
+				out.Address = conns[0].RemoteMultiaddr().String()
+			}
+			return cmds.EmitOnce(res, out)
+		}
+
+		network := node.PeerHost.Network()
+		if len(network.ConnsToPeer(pi.ID)) > 0 {
+			return fmt.Errorf("already connected to %s, run 'ipfs swarm disconnect' first to explain a fresh dial", pi.ID)
+		}
+		sw, _ := network.(*swarm.Swarm)
+		if sw != nil {
+			sw.Backoff().Clear(pi.ID)
+		}
+
+		node.Peerstore.AddAddrs(pi.ID, pi.Addrs, pstore.TempAddrTTL)
+		if len(node.Peerstore.Addrs(pi.ID)) == 0 {
+			// Resolve addresses up front so they can be ranked below.
+			if found, err := node.Routing.FindPeer(req.Context, pi.ID); err == nil {
+				node.Peerstore.AddAddrs(pi.ID, found.Addrs, pstore.TempAddrTTL)
+			}
+		}
+		candidates := node.Peerstore.Addrs(pi.ID)
+
+		dialErr := node.PeerHost.Connect(req.Context, peer.AddrInfo{ID: pi.ID})
+
+		out := &dialExplainOutput{ID: pi.ID.String()}
+		var connAddr ma.Multiaddr
+		if conns := network.ConnsToPeer(pi.ID); len(conns) > 0 {
+			connAddr = conns[0].RemoteMultiaddr()
+			out.Connected = true
+			out.Address = connAddr.String()
+		}
+		failures := make(map[string]error)
+		if dialErr != nil {
+			out.Error = dialErr.Error()
+			var de *swarm.DialError
+			if errors.As(dialErr, &de) {
+				for _, te := range de.DialErrors {
+					failures[string(te.Address.Bytes())] = te.Cause
+				}
+			}
+		}
+
+		var dialable, undialable []ma.Multiaddr
+		for _, addr := range candidates {
+			if sw != nil && sw.TransportForDialing(addr) == nil {
+				undialable = append(undialable, addr)
+				continue
+			}
+			dialable = append(dialable, addr)
+		}
+
+		cfg, err := node.Repo.Config()
+		if err != nil {
+			return err
+		}
+		ranker, err := libp2p.DialRanker(cfg.Swarm.DialPolicy)
+		if err != nil {
+			return err
+		}
+		ranked := ranker(dialable)
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Delay < ranked[j].Delay })
+		for _, ad := range ranked {
+			e := dialAddrExplain{Address: ad.Addr.String(), Delay: ad.Delay, Result: dialResultNotAttempted}
+			if cause, failed := failures[string(ad.Addr.Bytes())]; failed {
+				e.Result = dialResultFailed
+				e.Error = cause.Error()
+			} else if connAddr != nil && ad.Addr.Equal(connAddr) {
+				e.Result = dialResultConnected
+			}
+			out.Addrs = append(out.Addrs, e)
+		}
+		for _, addr := range undialable {
+			out.Addrs = append(out.Addrs, dialAddrExplain{Address: addr.String(), Result: dialResultUndialable, Error: "no transport for address"})
+		}
+
+		if err := res.Emit(out); err != nil {
+			return err
+		}
+		if dialErr != nil {
+			return fmt.Errorf("dial %s failure: %w", pi.ID, dialErr)
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *dialExplainOutput) error {
+			if len(out.Addrs) > 0 {
+				tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "RANK\tDELAY\tRESULT\tADDRESS\tREASON")
+				for i, a := range out.Addrs {
+					fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, a.Delay, a.Result, a.Address, a.Error)
+				}
+				if err := tw.Flush(); err != nil {
+					return err
+				}
+			}
+			// the failures are reported by the error of the command
+			if !out.Connected {
+				return nil
+			}
+			if out.Address == "" {
+				_, err := fmt.Fprintf(w, "dial %s success\n", out.ID)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "dial %s success via %s\n", out.ID, out.Address)
+			return err
+		}),
+	},
+	Type: dialExplainOutput{},
+}
+
 // parseAddresses is a function that takes in a slice of string peer addresses
 // (multiaddr + peerid) and returns a slice of properly constructed peers
 func parseAddresses(ctx context.Context, addrs []string, rslv *madns.Resolver) ([]peer.AddrInfo, error) {
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node/libp2p"
+)
+
+var swarmPeerstoreCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the peerstore.",
+		ShortDescription: `
+'ipfs swarm peerstore' reports on the peerstore, which keeps the addresses
+and metadata of the known peers, and on the peers saved in the repo with
+Swarm.Peerstore.Persist.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"stats": swarmPeerstoreStatsCmd,
+	},
+}
+
+type peerstoreStatsOutput struct {
+	// Peers and PeersWithAddrs count the peers in the peerstore.
+	Peers                  int
+	PeersWithAddrs         int
+	Persist                bool
+	*libp2p.PeerstoreStats `json:",omitempty"`
+}
+
+var swarmPeerstoreStatsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Report the peers of the peerstore and the ones saved in the repo.",
+		ShortDescription: `
+'ipfs swarm peerstore stats' counts the peers in the peerstore, and the ones
+with addresses. With Swarm.Peerstore.Persist, it also reports the peers and
+addresses saved in the repo, how many were restored and reconnected with on
+startup, how many were pruned since, and when they were last saved.
+
+An address is pruned when it was not seen within Swarm.Peerstore.Retention or
+when its confidence, the estimated chance that a dial to it succeeds, falls
+below Swarm.Peerstore.MinConfidence.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		out := peerstoreStatsOutput{
+			Peers:          nd.Peerstore.Peers().Len(),
+			PeersWithAddrs: nd.Peerstore.PeersWithAddrs().Len(),
+		}
+		if nd.PersistentPeerstore != nil {
+			stats := nd.PersistentPeerstore.Stats()
+			out.Persist = true
+			out.PeerstoreStats = &stats
+		}
+		return cmds.EmitOnce(res, &out)
+	},
+	Type: peerstoreStatsOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *peerstoreStatsOutput) error {
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+
+			fmt.Fprintf(tw, "Peers:\t%s\n", humanNumber(out.Peers))
+			fmt.Fprintf(tw, "PeersWithAddrs:\t%s\n", humanNumber(out.PeersWithAddrs))
+			if out.PeerstoreStats == nil {
+				fmt.Fprintf(tw, "Persist:\tdisabled\n")
+				return nil
+			}
+			lastSave := "never"
+			if !out.LastSave.IsZero() {
+				lastSave = out.LastSave.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "SavedPeers:\t%s\n", humanNumber(out.SavedPeers))
+			fmt.Fprintf(tw, "SavedAddrs:\t%s\n", humanNumber(out.SavedAddrs))
+			fmt.Fprintf(tw, "Restored:\t%s\n", humanNumber(out.Restored))
+			fmt.Fprintf(tw, "Reconnected:\t%s\n", humanNumber(out.Reconnected))
+			fmt.Fprintf(tw, "PrunedPeers:\t%s\n", humanNumber(out.PrunedPeers))
+			fmt.Fprintf(tw, "PrunedAddrs:\t%s\n", humanNumber(out.PrunedAddrs))
+			fmt.Fprintf(tw, "LastSave:\t%s\n", lastSave)
+			return nil
+		}),
+	},
+}
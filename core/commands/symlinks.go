@@ -0,0 +1,339 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/kubo/config"
+	serialize "github.com/ipfs/kubo/config/serialize"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+
+	"github.com/ipfs/boxo/files"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const symlinksOptionName = "symlinks"
+
+// Symlink policies of 'ipfs add'.
+const (
+	symlinksPreserve = "preserve"
+	symlinksFollow   = "follow"
+	symlinksForbid   = "forbid"
+)
+
+// Symlink policies of 'ipfs get'.
+const (
+	symlinksRestore = "restore"
+	symlinksSkip    = "skip"
+)
+
+// errSkipSymlink is returned by a symlinkFunc to leave a symlink out of a
+// directory.
+var errSkipSymlink = errors.New("skip symlink")
+
+// symlinkFunc is called by filterSymlinks for every symlink found, with its
+// slash separated path.
+type symlinkFunc func(fpath string) error
+
+// filterSymlinks wraps nd so that fn is called for every symlink met while
+// walking it. Symlinks for which fn returns errSkipSymlink are left out,
+// other errors abort the walk.
+func filterSymlinks(nd files.Node, fpath string, fn symlinkFunc) (files.Node, error) {
+	switch nd := nd.(type) {
+	case *files.Symlink:
+		if err := fn(fpath); err != nil {
+			if err == errSkipSymlink {
+				return nil, fmt.Errorf("%s is a symlink", fpath)
+			}
+			return nil, err
+		}
+		return nd, nil
+	case files.Directory:
+		return &symlinkFilterDir{Directory: nd, path: fpath, fn: fn}, nil
+	default:
+		return nd, nil
+	}
+}
+
+type symlinkFilterDir struct {
+	files.Directory
+	path string
+	fn   symlinkFunc
+}
+
+func (d *symlinkFilterDir) Entries() files.DirIterator {
+	return &symlinkFilterIterator{DirIterator: d.Directory.Entries(), dir: d}
+}
+
+type symlinkFilterIterator struct {
+	files.DirIterator
+	dir  *symlinkFilterDir
+	node files.Node
+	err  error
+}
+
+func (it *symlinkFilterIterator) Next() bool {
+	for it.err == nil && it.DirIterator.Next() {
+		fpath := gopath.Join(it.dir.path, it.Name())
+		switch nd := it.DirIterator.Node().(type) {
+		case *files.Symlink:
+			if err := it.dir.fn(fpath); err != nil {
+				if err == errSkipSymlink {
+					continue
+				}
+				it.err = err
+				return false
+			}
+			it.node = nd
+		case files.Directory:
+			it.node = &symlinkFilterDir{Directory: nd, path: fpath, fn: it.dir.fn}
+		default:
+			it.node = nd
+		}
+		return true
+	}
+	return false
+}
+
+func (it *symlinkFilterIterator) Node() files.Node {
+	return it.node
+}
+
+func (it *symlinkFilterIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.DirIterator.Err()
+}
+
+// addSymlinksPolicy returns the symlink policy of an 'ipfs add' request on the
+// client, where the node may not be available: the config is read from the
+// local repo, if any.
+func addSymlinksPolicy(req *cmds.Request, env cmds.Environment) string {
+	if policy, ok := req.Options[symlinksOptionName].(string); ok {
+		return policy
+	}
+	cfgRoot, err := cmdenv.GetConfigRoot(env)
+	if err != nil {
+		return config.DefaultImportSymlinks
+	}
+	fname, err := config.Filename(cfgRoot, "")
+	if err != nil {
+		return config.DefaultImportSymlinks
+	}
+	cfg, err := serialize.Load(fname)
+	if err != nil {
+		return config.DefaultImportSymlinks
+	}
+	return cfg.Import.Symlinks.WithDefault(config.DefaultImportSymlinks)
+}
+
+// addSymlinksFunc returns how 'ipfs add' treats the symlinks it receives
+// with the given policy, or nil when they are stored as they are.
+func addSymlinksFunc(policy string) (symlinkFunc, error) {
+	switch policy {
+	case symlinksPreserve:
+		return nil, nil
+	case symlinksForbid:
+		return func(fpath string) error {
+			return fmt.Errorf("%s is a symlink, which is not allowed with --%s=%s", fpath, symlinksOptionName, symlinksForbid)
+		}, nil
+	case symlinksFollow:
+		// The client dereferences symlinks before sending the files, any
+		// symlink left could not be followed.
+		return func(fpath string) error {
+			return fmt.Errorf("symlink %s was not followed by the client, --%s=%s is only supported when adding files with the ipfs command line", fpath, symlinksOptionName, symlinksFollow)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized symlink policy %q, must be %q, %q or %q", policy, symlinksPreserve, symlinksFollow, symlinksForbid)
+	}
+}
+
+// getSymlinksFunc returns how 'ipfs get' treats symlinks with the given
+// policy, or nil when they are restored.
+func getSymlinksFunc(policy string) (symlinkFunc, error) {
+	switch policy {
+	case symlinksRestore:
+		return nil, nil
+	case symlinksSkip:
+		return func(string) error { return errSkipSymlink }, nil
+	default:
+		return nil, fmt.Errorf("unrecognized symlink policy %q, must be %q or %q", policy, symlinksRestore, symlinksSkip)
+	}
+}
+
+// followSymlinks replaces the entries of req.Files read from the paths given
+// on the command line with ones that dereference the symlinks below them.
+func followSymlinks(req *cmds.Request, args []string) error {
+	if req.Files == nil {
+		return nil
+	}
+	paths, err := cliFilePaths(args)
+	if err != nil {
+		return err
+	}
+	filter, err := cliFileFilter(req)
+	if err != nil {
+		return err
+	}
+
+	var entries []files.DirEntry
+	it := req.Files.Entries()
+	for it.Next() {
+		nd := it.Node()
+		fpath, ok := paths[it.Name()]
+		if ok {
+			switch nd.(type) {
+			case *files.Symlink, files.Directory:
+				followed, err := followNode(fpath, filter, nil)
+				if err != nil {
+					return err
+				}
+				nd = followed
+			}
+		}
+		entries = append(entries, files.FileEntry(it.Name(), nd))
+	}
+	if it.Err() != nil {
+		return it.Err()
+	}
+
+	req.Files = files.NewSliceDirectory(entries)
+	return nil
+}
+
+// cliFilePaths maps the names of the entries of req.Files to the paths given
+// on the command line they were read from. The command line parser names the
+// files after the base of their path.
+func cliFilePaths(args []string) (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		fpath := filepath.Clean(arg)
+		if fpath == "." {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			fpath = cwd
+		}
+		name := filepath.Base(fpath)
+		if _, ok := paths[name]; ok {
+			continue
+		}
+		if _, err := os.Lstat(fpath); err == nil {
+			paths[name] = fpath
+		}
+	}
+	return paths, nil
+}
+
+// cliFileFilter returns the filter the command line parser read the files of
+// req with.
+func cliFileFilter(req *cmds.Request) (*files.Filter, error) {
+	rulesFile, _ := req.Options[cmds.IgnoreRules].(string)
+	ignoreRules, _ := req.Options[cmds.Ignore].([]string)
+	hidden, _ := req.Options[cmds.Hidden].(bool)
+	return files.NewFilter(rulesFile, ignoreRules, hidden)
+}
+
+// followNode reads fpath like files.NewSerialFileWithFilter does, following
+// symlinks. ancestors holds the resolved paths of the directories above it,
+// to detect symlink cycles.
+func followNode(fpath string, filter *files.Filter, ancestors []string) (files.Node, error) {
+	stat, err := os.Stat(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot follow symlink: %w", err)
+	}
+	if !stat.IsDir() {
+		return files.NewSerialFileWithFilter(fpath, filter, stat)
+	}
+
+	resolved, err := filepath.EvalSymlinks(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot follow symlink: %w", err)
+	}
+	for _, a := range ancestors {
+		if a == resolved {
+			return nil, fmt.Errorf("cannot follow symlink %s: it points to one of its parent directories", fpath)
+		}
+	}
+
+	nd, err := files.NewSerialFileWithFilter(fpath, filter, stat)
+	if err != nil {
+		return nil, err
+	}
+	return &followDir{
+		Directory: nd.(files.Directory),
+		path:      fpath,
+		filter:    filter,
+		ancestors: append(ancestors[:len(ancestors):len(ancestors)], resolved),
+	}, nil
+}
+
+type followDir struct {
+	files.Directory
+	path      string
+	filter    *files.Filter
+	ancestors []string
+}
+
+func (d *followDir) Entries() files.DirIterator {
+	return &followIterator{DirIterator: d.Directory.Entries(), dir: d}
+}
+
+func (d *followDir) Size() (int64, error) {
+	var du int64
+	it := d.Entries()
+	for it.Next() {
+		size, err := it.Node().Size()
+		it.Node().Close()
+		if err != nil {
+			return 0, err
+		}
+		du += size
+	}
+	return du, it.Err()
+}
+
+type followIterator struct {
+	files.DirIterator
+	dir  *followDir
+	node files.Node
+	err  error
+}
+
+func (it *followIterator) Next() bool {
+	if it.err != nil || !it.DirIterator.Next() {
+		return false
+	}
+	nd := it.DirIterator.Node()
+	switch nd.(type) {
+	case *files.Symlink, files.Directory:
+		followed, err := followNode(filepath.Join(it.dir.path, it.Name()), it.dir.filter, it.dir.ancestors)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		nd = followed
+	}
+	it.node = nd
+	return true
+}
+
+func (it *followIterator) Node() files.Node {
+	return it.node
+}
+
+func (it *followIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.DirIterator.Err()
+}
@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/boxo/files"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const (
+	preserveXattrsOptionName   = "preserve-xattrs"
+	privilegedXattrsOptionName = "privileged-xattrs"
+
+	// xattrsSidecarName is the file recording the extended attributes of the
+	// directory it is in and of the files below it.
+	xattrsSidecarName = ".ipfs-xattrs.json"
+)
+
+// xattrsSidecar is the content of an xattrsSidecarName file.
+type xattrsSidecar struct {
+	Version int
+	// Entries maps slash separated paths, relative to the directory of the
+	// sidecar, to their extended attributes. POSIX ACLs are the
+	// system.posix_acl_access and system.posix_acl_default attributes.
+	Entries map[string]map[string][]byte
+}
+
+// recordXattrs adds sidecars with the extended attributes of the files given
+// on the command line to req.Files: at the top when the files are wrapped in
+// a directory, in each directory added otherwise.
+func recordXattrs(req *cmds.Request, args []string, wrap bool) error {
+	if req.Files == nil {
+		return nil
+	}
+	paths, err := cliFilePaths(args)
+	if err != nil {
+		return err
+	}
+	filter, err := cliFileFilter(req)
+	if err != nil {
+		return err
+	}
+
+	top := xattrsSidecar{Version: 1, Entries: make(map[string]map[string][]byte)}
+	var entries []files.DirEntry
+	it := req.Files.Entries()
+	for it.Next() {
+		nd := it.Node()
+		fpath, ok := paths[it.Name()]
+		if !ok {
+			entries = append(entries, files.FileEntry(it.Name(), nd))
+			continue
+		}
+		if wrap {
+			if err := readXattrs(fpath, it.Name(), filter, top.Entries); err != nil {
+				return err
+			}
+			entries = append(entries, files.FileEntry(it.Name(), nd))
+			continue
+		}
+
+		dir, ok := nd.(files.Directory)
+		if !ok {
+			return fmt.Errorf("--%s records the attributes of %s in its directory, use --%s to add files", preserveXattrsOptionName, fpath, wrapOptionName)
+		}
+		sidecar := xattrsSidecar{Version: 1, Entries: make(map[string]map[string][]byte)}
+		if err := readXattrs(fpath, ".", filter, sidecar.Entries); err != nil {
+			return err
+		}
+		dir, err = withXattrsSidecar(dir, sidecar)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, files.FileEntry(it.Name(), dir))
+	}
+	if it.Err() != nil {
+		return it.Err()
+	}
+
+	if wrap {
+		data, err := json.Marshal(top)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, files.FileEntry(xattrsSidecarName, files.NewBytesFile(data)))
+	}
+	req.Files = files.NewSliceDirectory(entries)
+	return nil
+}
+
+// readXattrs records the extended attributes of fpath and of the files below
+// it not excluded by filter, under paths starting with name.
+func readXattrs(fpath, name string, filter *files.Filter, entries map[string]map[string][]byte) error {
+	return filepath.WalkDir(fpath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != fpath {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if filter.ShouldExclude(fi) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		attrs, err := getXattrs(p)
+		if err != nil {
+			return fmt.Errorf("reading extended attributes of %s: %w", p, err)
+		}
+		if len(attrs) == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(fpath, p)
+		if err != nil {
+			return err
+		}
+		entries[gopath.Join(name, filepath.ToSlash(rel))] = attrs
+		return nil
+	})
+}
+
+// withXattrsSidecar returns dir with an xattrsSidecarName file holding
+// sidecar as last entry.
+func withXattrsSidecar(dir files.Directory, sidecar xattrsSidecar) (files.Directory, error) {
+	data, err := json.Marshal(sidecar)
+	if err != nil {
+		return nil, err
+	}
+	return &sidecarDir{Directory: dir, data: data}, nil
+}
+
+type sidecarDir struct {
+	files.Directory
+	data []byte
+}
+
+func (d *sidecarDir) Entries() files.DirIterator {
+	return &sidecarIterator{DirIterator: d.Directory.Entries(), data: d.data}
+}
+
+func (d *sidecarDir) Size() (int64, error) {
+	size, err := d.Directory.Size()
+	return size + int64(len(d.data)), err
+}
+
+type sidecarIterator struct {
+	files.DirIterator
+	data    []byte
+	sidecar bool
+}
+
+func (it *sidecarIterator) Next() bool {
+	if it.sidecar {
+		return false
+	}
+	if it.DirIterator.Next() {
+		return true
+	}
+	if it.DirIterator.Err() != nil {
+		return false
+	}
+	it.sidecar = true
+	return true
+}
+
+func (it *sidecarIterator) Name() string {
+	if it.sidecar {
+		return xattrsSidecarName
+	}
+	return it.DirIterator.Name()
+}
+
+func (it *sidecarIterator) Node() files.Node {
+	if it.sidecar {
+		return files.NewBytesFile(it.data)
+	}
+	return it.DirIterator.Node()
+}
+
+// restoreXattrs sets the extended attributes recorded by the sidecars found
+// at or below fpath, and removes the sidecars. Unless privileged is set, only
+// the user.* attributes and the POSIX ACLs are set, as the sidecars come with
+// the content: the other namespaces, such as security.capability, grant
+// privileges when set by root. The attributes skipped are reported to warn.
+func restoreXattrs(fpath string, privileged bool, warn io.Writer) error {
+	return filepath.WalkDir(fpath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != xattrsSidecarName {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var sidecar xattrsSidecar
+		if err := json.Unmarshal(data, &sidecar); err != nil {
+			return fmt.Errorf("invalid %s: %w", p, err)
+		}
+		dir, err := filepath.EvalSymlinks(filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		for rel, attrs := range sidecar.Entries {
+			// Recorded paths may not lead out of the directory of the
+			// sidecar, through symlinks either.
+			if !filepath.IsLocal(filepath.FromSlash(rel)) {
+				return fmt.Errorf("invalid %s: path %q is outside of its directory", p, rel)
+			}
+			target := filepath.Join(dir, filepath.FromSlash(rel))
+			parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+			if err != nil {
+				return err
+			}
+			target = filepath.Join(parent, filepath.Base(target))
+			if target != dir && !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+				return fmt.Errorf("invalid %s: path %q is outside of its directory", p, rel)
+			}
+			for name, value := range attrs {
+				if !privileged && !isUnprivilegedXattr(name) {
+					fmt.Fprintf(warn, "skipped extended attribute %s of %s, use --%s to restore it\n", name, target, privilegedXattrsOptionName)
+					continue
+				}
+				if err := setXattr(target, name, value); err != nil {
+					return fmt.Errorf("restoring extended attribute %s of %s: %w", name, target, err)
+				}
+			}
+		}
+		return os.Remove(p)
+	})
+}
+
+// isUnprivilegedXattr tells whether the attribute name is restored without
+// --privileged-xattrs: the user.* attributes and the POSIX ACLs.
+func isUnprivilegedXattr(name string) bool {
+	return strings.HasPrefix(name, "user.") || name == "system.posix_acl_access" || name == "system.posix_acl_default"
+}
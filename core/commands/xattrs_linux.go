@@ -0,0 +1,51 @@
+//go:build linux
+// +build linux
+
+package commands
+
+import (
+	"bytes"
+	"errors"
+
+	unix "golang.org/x/sys/unix"
+)
+
+// getXattrs returns the extended attributes of fpath, without following
+// symlinks.
+func getXattrs(fpath string) (map[string][]byte, error) {
+	size, err := unix.Llistxattr(fpath, nil)
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	}
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(fpath, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		vsize, err := unix.Lgetxattr(fpath, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, vsize)
+		vsize, err = unix.Lgetxattr(fpath, string(name), value)
+		if err != nil {
+			return nil, err
+		}
+		attrs[string(name)] = value[:vsize]
+	}
+	return attrs, nil
+}
+
+// setXattr sets an extended attribute of fpath, without following symlinks.
+func setXattr(fpath, name string, value []byte) error {
+	return unix.Lsetxattr(fpath, name, value, 0)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package commands
+
+import "errors"
+
+var errXattrsNotSupported = errors.New("extended attributes are only supported on Linux")
+
+func getXattrs(string) (map[string][]byte, error) {
+	return nil, errXattrsNotSupported
+}
+
+func setXattr(string, string, []byte) error {
+	return errXattrsNotSupported
+}
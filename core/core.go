@@ -19,6 +19,7 @@ import (
 	pin "github.com/ipfs/boxo/pinning/pinner"
 	"github.com/ipfs/go-datastore"
 
+	"github.com/benbjohnson/clock"
 	bserv "github.com/ipfs/boxo/blockservice"
 	bstore "github.com/ipfs/boxo/blockstore"
 	exchange "github.com/ipfs/boxo/exchange"
@@ -50,6 +51,7 @@ import (
 	"github.com/ipfs/boxo/namesys"
 	ipnsrp "github.com/ipfs/boxo/namesys/republisher"
 	"github.com/ipfs/boxo/peering"
+	"github.com/ipfs/kubo/autoconf"
 	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/node"
 	"github.com/ipfs/kubo/core/node/libp2p"
@@ -66,7 +68,8 @@ type IpfsNode struct {
 	// Self
 	Identity peer.ID // the local node's identity
 
-	Repo repo.Repo
+	Repo  repo.Repo
+	Clock clock.Clock // the clock of the time-dependent subsystems, see node.Clock
 
 	// Local node
 	Pinning         pin.Pinner             // the pinning manager
@@ -90,26 +93,58 @@ type IpfsNode struct {
 	Discovery                   mdns.Service              `optional:"true"`
 	FilesRoot                   *mfs.Root
 	RecordValidator             record.Validator
+	RPCQuotas                   *node.RPCQuotaTracker
+	RPCStats                    *node.RPCStats      // reported by ipfs stats api
+	ProvideList                 *node.ProvideList   // CIDs reprovided with ipfs provide add
+	Scheduler                   *node.TaskScheduler // runs Schedule.Tasks in the daemon
 
 	// Online
-	PeerHost                  p2phost.Host               `optional:"true"` // the network host (server+client)
-	Peering                   *peering.PeeringService    `optional:"true"`
-	Filters                   *ma.Filters                `optional:"true"`
-	Bootstrapper              io.Closer                  `optional:"true"` // the periodic bootstrapper
-	Routing                   irouting.ProvideManyRouter `optional:"true"` // the routing system. recommend ipfs-dht
-	DNSResolver               *madns.Resolver            // the DNS resolver
-	IPLDPathResolver          pathresolver.Resolver      `name:"ipldPathResolver"`          // The IPLD path resolver
-	UnixFSPathResolver        pathresolver.Resolver      `name:"unixFSPathResolver"`        // The UnixFS path resolver
-	OfflineIPLDPathResolver   pathresolver.Resolver      `name:"offlineIpldPathResolver"`   // The IPLD path resolver that uses only locally available blocks
-	OfflineUnixFSPathResolver pathresolver.Resolver      `name:"offlineUnixFSPathResolver"` // The UnixFS path resolver that uses only locally available blocks
-	Exchange                  exchange.Interface         // the block exchange + strategy (bitswap)
-	Namesys                   namesys.NameSystem         // the name system, resolves paths to hashes
-	Provider                  provider.System            // the value provider system
-	IpnsRepub                 *ipnsrp.Republisher        `optional:"true"`
-	ResourceManager           network.ResourceManager    `optional:"true"`
-
-	PubSub   *pubsub.PubSub             `optional:"true"`
-	PSRouter *psrouter.PubsubValueStore `optional:"true"`
+	PeerHost                  p2phost.Host                `optional:"true"` // the network host (server+client)
+	Peering                   *peering.PeeringService     `optional:"true"`
+	PeerChurn                 *libp2p.PeerChurnTracker    `optional:"true"` // records peer session lifetimes and disconnect causes
+	PersistentPeerstore       *libp2p.PersistentPeerstore `optional:"true"` // saves the peers in the repo, see Swarm.Peerstore
+	ProviderRecords           *libp2p.ProviderRecords     `optional:"true"` // reported by ipfs stats providers
+	Filters                   *ma.Filters                 `optional:"true"`
+	Bootstrapper              io.Closer                   `optional:"true"` // the periodic bootstrapper
+	Routing                   irouting.ProvideManyRouter  `optional:"true"` // the routing system. recommend ipfs-dht
+	RoutingEvents             *irouting.Events            `optional:"true"` // reported by ipfs routing events
+	DNSCache                  *node.DNSCache              `optional:"true"` // caches the lookups of DNSResolver, see ipfs dns purge
+	DNSResolver               *madns.Resolver             // the DNS resolver
+	IPLDPathResolver          pathresolver.Resolver       `name:"ipldPathResolver"`          // The IPLD path resolver
+	UnixFSPathResolver        pathresolver.Resolver       `name:"unixFSPathResolver"`        // The UnixFS path resolver
+	OfflineIPLDPathResolver   pathresolver.Resolver       `name:"offlineIpldPathResolver"`   // The IPLD path resolver that uses only locally available blocks
+	OfflineUnixFSPathResolver pathresolver.Resolver       `name:"offlineUnixFSPathResolver"` // The UnixFS path resolver that uses only locally available blocks
+	Exchange                  exchange.Interface          // the block exchange + strategy (bitswap)
+	Namesys                   namesys.NameSystem          // the name system, resolves paths to hashes
+	IpnsCache                 *node.IpnsCache             `optional:"true"` // caches the resolutions of Namesys, see ipfs name cache
+	IpnsThirdParty            *node.IpnsThirdParty        `optional:"true"` // republishes Ipns.RepublishThirdParty, see ipfs name thirdparty
+	Provider                  provider.System             // the value provider system
+	ReactiveProvider          *node.ReactiveProvider      `optional:"true"` // the keys of the "reactive" Reprovider.Strategy
+	IpnsRepub                 *ipnsrp.Republisher         `optional:"true"`
+	ResourceManager           network.ResourceManager     `optional:"true"`
+	BitswapPeers              *node.BitswapPeerTracker    `optional:"true"` // per-peer bitswap traffic, see Bitswap.PeerMetricsTopN
+	BitswapRecorder           *node.BitswapWantRecorder   `optional:"true"` // wants received with ipfs daemon --bitswap-record-only
+	PrivateBitswap            *node.PrivateBitswap        `optional:"true"` // bitswap of Bitswap.PrivateNetwork
+	BitswapStats              *node.BitswapStatsCollector `optional:"true"` // reported by ipfs bitswap stat --verbose
+	BitswapVerifier           *node.BitswapVerifier       `optional:"true"` // bans peers sending invalid blocks
+	BitswapBlockFilter        *node.BitswapBlockFilter    `optional:"true"` // applies Bitswap.BlockPolicy
+	BitswapReputation         *node.BitswapReputation     `optional:"true"` // scores Bitswap peers
+	BitswapQueue              *node.BitswapQueue          `optional:"true"` // reported by ipfs bitswap queue
+	FetchPlanner              *node.FetchPlanner          `optional:"true"` // probes the peers for ipfs fetch plan
+	RetrievalProber           *node.RetrievalProber       `optional:"true"` // runs Probes.Retrieval
+	PendingPins               *node.PendingPins           `optional:"true"` // pins resumed after a restart, see Pinning.ResumeInterrupted
+	SearchIndex               *node.SearchIndex           `optional:"true"` // queried by ipfs search
+	DagIndex                  node.DagIndexer             `optional:"true"` // queried by ipfs dag query
+	SlowLog                   *node.SlowLog               `optional:"true"` // reported by ipfs diag slowlog
+	GatewayAccessLog          *node.GatewayAccessLog      `optional:"true"` // written by the gateway when Gateway.AccessLog is enabled
+	GatewayDenylists          *node.GatewayDenylists      `optional:"true"` // denylists of Gateway.PublicGateways hostnames
+	GatewayDirectoryIndex     *node.GatewayDirectoryIndex `optional:"true"` // entries of the directories listed by the gateway
+	GatewayTLS                *node.GatewayTLS            `optional:"true"` // certificates of the gateway addresses ending with /tls/http
+	KeyAudit                  *node.KeyAudit              `optional:"true"` // uses of the keys, see ipfs key audit
+
+	PubSub     *pubsub.PubSub             `optional:"true"`
+	PSRouter   *psrouter.PubsubValueStore `optional:"true"`
+	IpnsPubsub *libp2p.IpnsPubsubNames    `optional:"true"` // reported by ipfs name pubsub state
 
 	DHT       *ddht.DHT       `optional:"true"`
 	DHTClient routing.Routing `name:"dhtc" optional:"true"`
@@ -209,7 +244,7 @@ func (n *IpfsNode) loadBootstrapPeers() ([]peer.AddrInfo, error) {
 		return nil, err
 	}
 
-	return cfg.BootstrapPeers()
+	return autoconf.Load(n.Context(), cfg, n.Repo.Datastore()).BootstrapPeers(cfg)
 }
 
 func (n *IpfsNode) saveTempBootstrapPeers(ctx context.Context, peerList []peer.AddrInfo) error {
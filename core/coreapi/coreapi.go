@@ -15,6 +15,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/benbjohnson/clock"
 	bserv "github.com/ipfs/boxo/blockservice"
 	blockstore "github.com/ipfs/boxo/blockstore"
 	exchange "github.com/ipfs/boxo/exchange"
@@ -74,6 +75,10 @@ type CoreAPI struct {
 
 	pubSub *pubsub.PubSub
 
+	clock clock.Clock
+
+	keyAudit *node.KeyAudit
+
 	checkPublishAllowed func() error
 	checkOnline         func(allowOffline bool) error
 
@@ -177,7 +182,7 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 
 		peerstore:          n.Peerstore,
 		peerHost:           n.PeerHost,
-		namesys:            n.Namesys,
+		namesys:            n.KeyAudit.NameSystem(n.Namesys, n.PrivateKey, n.Repo),
 		recordValidator:    n.RecordValidator,
 		exchange:           n.Exchange,
 		routing:            n.Routing,
@@ -189,10 +194,20 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 
 		pubSub: n.PubSub,
 
+		clock: n.Clock,
+
+		keyAudit: n.KeyAudit,
+
 		nd:         n,
 		parentOpts: settings,
 	}
 
+	// the "reactive" Reprovider.Strategy only announces the blocks once
+	// requested by peers, not when added or pinned
+	if n.ReactiveProvider != nil {
+		subAPI.provider = provider.NewNoopProvider()
+	}
+
 	subAPI.checkOnline = func(allowOffline bool) error {
 		if !n.IsOnline && !allowOffline {
 			return coreiface.ErrOffline
@@ -230,10 +245,11 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 
 		subAPI.routing = offlineroute.NewOfflineRouter(subAPI.repo.Datastore(), subAPI.recordValidator)
 
-		subAPI.namesys, err = namesys.NewNameSystem(subAPI.routing, nsOptions...)
+		ns, err := namesys.NewNameSystem(subAPI.routing, nsOptions...)
 		if err != nil {
 			return nil, fmt.Errorf("error constructing namesys: %w", err)
 		}
+		subAPI.namesys = subAPI.keyAudit.NameSystem(ns, subAPI.privateKey, subAPI.repo)
 
 		subAPI.provider = provider.NewNoopProvider()
 
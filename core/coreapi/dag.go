@@ -2,14 +2,24 @@ package coreapi
 
 import (
 	"context"
+	"fmt"
+	"io"
 
 	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/path"
 	pin "github.com/ipfs/boxo/pinning/pinner"
+	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	ipldlegacy "github.com/ipfs/go-ipld-legacy"
+	gocar "github.com/ipld/go-car"
+	gocarv2 "github.com/ipld/go-car/v2"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	coreiface "github.com/ipfs/kubo/core/coreiface"
+	"github.com/ipfs/kubo/core/coreiface/options"
 	"github.com/ipfs/kubo/tracing"
 )
 
@@ -68,6 +78,152 @@ func (api *dagAPI) Session(ctx context.Context) ipld.NodeGetter {
 	return dag.NewSession(ctx, api.DAGService)
 }
 
+// carStore adapts a NodeGetter to the block store read by go-car
+type carStore struct {
+	ipld.NodeGetter
+}
+
+func (s carStore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return s.NodeGetter.Get(ctx, c)
+}
+
+func (api *dagAPI) Export(ctx context.Context, root path.Path, opts ...options.DagExportOption) (io.ReadCloser, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.DagAPI", "Export", trace.WithAttributes(attribute.String("root", root.String())))
+	defer span.End()
+
+	settings, err := options.DagExportOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	rp, _, err := api.core.ResolvePath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	c := rp.RootCid()
+
+	// confirm the root block is available, fail fast if not
+	getter := dag.NewSession(ctx, api.DAGService)
+	if _, err := getter.Get(ctx, c); err != nil {
+		return nil, err
+	}
+
+	var carOpts []gocar.Option
+	// TraverseLinksOnlyOnce is safe for an exhaustive selector but not for
+	// arbitrary ones
+	if settings.Selector == selectorparse.CommonSelector_ExploreAllRecursively {
+		carOpts = append(carOpts, gocar.TraverseLinksOnlyOnce())
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		d := gocar.Dag{Root: c, Selector: settings.Selector}
+		car := gocar.NewSelectiveCar(ctx, carStore{getter}, []gocar.Dag{d}, carOpts...)
+		pw.CloseWithError(car.Write(pw))
+	}()
+	return pr, nil
+}
+
+func (api *dagAPI) Import(ctx context.Context, r io.Reader, opts ...options.DagImportOption) (coreiface.DagImportResult, error) {
+	ctx, span := tracing.Span(ctx, "CoreAPI.DagAPI", "Import")
+	defer span.End()
+
+	var res coreiface.DagImportResult
+	settings, err := options.DagImportOptions(opts...)
+	if err != nil {
+		return res, err
+	}
+	span.SetAttributes(attribute.Bool("pinroots", settings.PinRoots))
+
+	// grab a pinlock ( which doubles as a GC lock ) so that nothing will
+	// disappear on us before we had a chance to pin the roots
+	if settings.PinRoots {
+		defer api.core.blockstore.PinLock(ctx).Unlock(ctx)
+	}
+
+	car, err := gocarv2.NewBlockReader(r)
+	if err != nil {
+		return res, err
+	}
+
+	// remember last valid block and provide a meaningful error message
+	// when a truncated/mangled CAR is being imported
+	var previous blocks.Block
+	importError := func(current blocks.Block, err error) error {
+		if current != nil {
+			return fmt.Errorf("import failed at block %q: %w", current.Cid(), err)
+		}
+		if previous != nil {
+			return fmt.Errorf("import failed after block %q: %w", previous.Cid(), err)
+		}
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	decoder := ipldlegacy.NewDecoder()
+	// this is *not* a transaction
+	// it is simply a way to relieve pressure on the blockstore
+	// similar to pinner.Pin/pinner.Flush
+	batch := ipld.NewBatch(ctx, api.DAGService)
+	for {
+		block, err := car.Next()
+		if err != nil && err != io.EOF {
+			return res, importError(block, err)
+		} else if block == nil {
+			break
+		}
+		if settings.OnBlock != nil {
+			if err := settings.OnBlock(ctx, block); err != nil {
+				return res, importError(block, err)
+			}
+		}
+		// the double-decode is suboptimal, but we need it for batching
+		nd, err := decoder.DecodeNode(ctx, block)
+		if err != nil {
+			return res, importError(block, err)
+		}
+		if err := batch.Add(ctx, nd); err != nil {
+			return res, importError(block, err)
+		}
+		res.BlockCount++
+		res.BlockBytesCount += uint64(len(block.RawData()))
+		previous = block
+	}
+	if err := batch.Commit(); err != nil {
+		return res, err
+	}
+
+	seen := cid.NewSet()
+	for _, c := range car.Roots {
+		if !seen.Visit(c) {
+			continue
+		}
+		root := coreiface.DagImportRoot{Cid: c}
+		// opportunistic pinning: roots missing from both the CAR and the
+		// blockstore are not fetched
+		if settings.PinRoots {
+			if err := api.pinRoot(ctx, decoder, c); err != nil {
+				root.PinErrorMsg = err.Error()
+			}
+		}
+		res.Roots = append(res.Roots, root)
+	}
+	return res, nil
+}
+
+func (api *dagAPI) pinRoot(ctx context.Context, decoder *ipldlegacy.Decoder, c cid.Cid) error {
+	block, err := api.core.blockstore.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	nd, err := decoder.DecodeNode(ctx, block)
+	if err != nil {
+		return err
+	}
+	if err := api.core.pinning.Pin(ctx, nd, true, ""); err != nil {
+		return err
+	}
+	return api.core.pinning.Flush(ctx)
+}
+
 var (
 	_ ipld.DAGService  = (*dagAPI)(nil)
 	_ dag.SessionMaker = (*dagAPI)(nil)
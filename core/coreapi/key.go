@@ -2,7 +2,10 @@ package coreapi
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"sort"
@@ -11,6 +14,7 @@ import (
 	"github.com/ipfs/boxo/path"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/node"
 	"github.com/ipfs/kubo/tracing"
 	crypto "github.com/libp2p/go-libp2p/core/crypto"
 	peer "github.com/libp2p/go-libp2p/core/peer"
@@ -55,9 +59,10 @@ func (k *key) ID() peer.ID {
 
 // Generate generates new key, stores it in the keystore under the specified
 // name and returns a base58 encoded multihash of its public key.
-func (api *KeyAPI) Generate(ctx context.Context, name string, opts ...caopts.KeyGenerateOption) (coreiface.Key, error) {
+func (api *KeyAPI) Generate(ctx context.Context, name string, opts ...caopts.KeyGenerateOption) (_ coreiface.Key, err error) {
 	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Generate", trace.WithAttributes(attribute.String("name", name)))
 	defer span.End()
+	defer func() { api.keyAudit.Record(ctx, name, node.KeyOpGenerate, "", err) }()
 
 	options, err := caopts.KeyGenerateOptions(opts...)
 	if err != nil {
@@ -155,9 +160,16 @@ func (api *KeyAPI) List(ctx context.Context) ([]coreiface.Key, error) {
 
 // Rename renames `oldName` to `newName`. Returns the key and whether another
 // key was overwritten, or an error.
-func (api *KeyAPI) Rename(ctx context.Context, oldName string, newName string, opts ...caopts.KeyRenameOption) (coreiface.Key, bool, error) {
+func (api *KeyAPI) Rename(ctx context.Context, oldName string, newName string, opts ...caopts.KeyRenameOption) (_ coreiface.Key, _ bool, err error) {
 	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Rename", trace.WithAttributes(attribute.String("oldname", oldName), attribute.String("newname", newName)))
 	defer span.End()
+	// the renamed key is audited under both names
+	defer func() {
+		api.keyAudit.Record(ctx, oldName, node.KeyOpRename, "to "+newName, err)
+		if err == nil {
+			api.keyAudit.Record(ctx, newName, node.KeyOpRename, "from "+oldName, nil)
+		}
+	}()
 
 	options, err := caopts.KeyRenameOptions(opts...)
 	if err != nil {
@@ -225,9 +237,10 @@ func (api *KeyAPI) Rename(ctx context.Context, oldName string, newName string, o
 }
 
 // Remove removes keys from keystore. Returns ipns path of the removed key.
-func (api *KeyAPI) Remove(ctx context.Context, name string) (coreiface.Key, error) {
+func (api *KeyAPI) Remove(ctx context.Context, name string) (_ coreiface.Key, err error) {
 	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Remove", trace.WithAttributes(attribute.String("name", name)))
 	defer span.End()
+	defer func() { api.keyAudit.Record(ctx, name, node.KeyOpRemove, "", err) }()
 
 	ks := api.repo.Keystore()
 
@@ -255,6 +268,144 @@ func (api *KeyAPI) Remove(ctx context.Context, name string) (coreiface.Key, erro
 	return newKey("", pid)
 }
 
+// Import stores the private key in data under the specified name and returns
+// the imported key.
+func (api *KeyAPI) Import(ctx context.Context, name string, data []byte, opts ...caopts.KeyImportOption) (_ coreiface.Key, err error) {
+	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Import", trace.WithAttributes(attribute.String("name", name)))
+	defer span.End()
+	defer func() { api.keyAudit.Record(ctx, name, node.KeyOpImport, "", err) }()
+
+	options, err := caopts.KeyImportOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("format", options.Format), attribute.Bool("allowanytype", options.AllowAnyType))
+
+	if name == "self" {
+		return nil, fmt.Errorf("cannot import key with name 'self'")
+	}
+
+	var sk crypto.PrivKey
+	switch options.Format {
+	case caopts.KeyFormatPemCleartext:
+		pemBlock, rest := pem.Decode(data)
+		if pemBlock == nil {
+			return nil, fmt.Errorf("PEM block not found in input data:\n%s", rest)
+		}
+
+		if pemBlock.Type != "PRIVATE KEY" {
+			return nil, fmt.Errorf("expected PRIVATE KEY type in PEM block but got: %s", pemBlock.Type)
+		}
+
+		stdKey, err := x509.ParsePKCS8PrivateKey(pemBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PKCS8 format: %w", err)
+		}
+
+		// In case ed25519.PrivateKey is returned we need the pointer for
+		// conversion to libp2p (see Export for more details).
+		if ed25519KeyPointer, ok := stdKey.(ed25519.PrivateKey); ok {
+			stdKey = &ed25519KeyPointer
+		}
+
+		sk, _, err = crypto.KeyPairFromStdKey(stdKey)
+		if err != nil {
+			return nil, fmt.Errorf("converting std Go key to libp2p key: %w", err)
+		}
+	case caopts.KeyFormatLibp2pCleartext:
+		sk, err = crypto.UnmarshalPrivateKey(data)
+		if err != nil {
+			// check if data is PEM, if so, provide user with hint
+			pemBlock, _ := pem.Decode(data)
+			if pemBlock != nil {
+				return nil, fmt.Errorf("unexpected PEM block for format=%s: try again with format=%s", caopts.KeyFormatLibp2pCleartext, caopts.KeyFormatPemCleartext)
+			}
+			return nil, fmt.Errorf("unable to unmarshall format=%s: %w", caopts.KeyFormatLibp2pCleartext, err)
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized import format: %s", options.Format)
+	}
+
+	// We only allow importing keys of the same type we generate, unless
+	// explicitly stated by the user.
+	if !options.AllowAnyType {
+		switch t := sk.(type) {
+		case *crypto.RsaPrivateKey, *crypto.Ed25519PrivateKey:
+		default:
+			return nil, fmt.Errorf("key type %T is not allowed to be imported, only RSA or Ed25519;"+
+				" use flag --allow-any-key-type if you are sure of what you're doing", t)
+		}
+	}
+
+	ks := api.repo.Keystore()
+	_, err = ks.Get(name)
+	if err == nil {
+		return nil, fmt.Errorf("key with name '%s' already exists", name)
+	}
+
+	err = ks.Put(name, sk)
+	if err != nil {
+		return nil, err
+	}
+
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(name, pid)
+}
+
+// Export returns the private key stored under the specified name.
+func (api *KeyAPI) Export(ctx context.Context, name string, opts ...caopts.KeyExportOption) (_ []byte, err error) {
+	_, span := tracing.Span(ctx, "CoreAPI.KeyAPI", "Export", trace.WithAttributes(attribute.String("name", name)))
+	defer span.End()
+	defer func() { api.keyAudit.Record(ctx, name, node.KeyOpExport, "", err) }()
+
+	options, err := caopts.KeyExportOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("format", options.Format))
+
+	if name == "self" {
+		return nil, fmt.Errorf("cannot export key with name 'self'")
+	}
+
+	sk, err := api.repo.Keystore().Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("key with name '%s' doesn't exist", name)
+	}
+
+	switch options.Format {
+	case caopts.KeyFormatPemCleartext:
+		stdKey, err := crypto.PrivKeyToStdKey(sk)
+		if err != nil {
+			return nil, fmt.Errorf("converting libp2p private key to std Go key: %w", err)
+		}
+		// For some reason the ed25519.PrivateKey does not use pointer
+		// receivers, so we need to convert it for MarshalPKCS8PrivateKey.
+		// (We should probably change this upstream in PrivKeyToStdKey).
+		if ed25519KeyPointer, ok := stdKey.(*ed25519.PrivateKey); ok {
+			stdKey = *ed25519KeyPointer
+		}
+		// This function supports a restricted list of public key algorithms,
+		// but we generate and use only the RSA and ed25519 types that are on that list.
+		der, err := x509.MarshalPKCS8PrivateKey(stdKey)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling key to PKCS8 format: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}), nil
+	case caopts.KeyFormatLibp2pCleartext:
+		return crypto.MarshalPrivateKey(sk)
+	default:
+		return nil, fmt.Errorf("unrecognized export format: %s", options.Format)
+	}
+}
+
 func (api *KeyAPI) Self(ctx context.Context) (coreiface.Key, error) {
 	if api.identity == "" {
 		return nil, errors.New("identity not loaded")
@@ -265,13 +416,13 @@ func (api *KeyAPI) Self(ctx context.Context) (coreiface.Key, error) {
 
 const signedMessagePrefix = "libp2p-key signed message:"
 
-func (api *KeyAPI) Sign(ctx context.Context, name string, data []byte) (coreiface.Key, []byte, error) {
-	var (
-		sk  crypto.PrivKey
-		err error
-	)
+func (api *KeyAPI) Sign(ctx context.Context, name string, data []byte) (_ coreiface.Key, _ []byte, err error) {
+	var sk crypto.PrivKey
 	if name == "" || name == "self" {
 		name = "self"
+	}
+	defer func() { api.keyAudit.Record(ctx, name, node.KeyOpSign, "", err) }()
+	if name == "self" {
 		sk = api.privateKey
 	} else {
 		sk, err = api.repo.Keystore().Get(name)
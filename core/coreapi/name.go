@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/ipfs/boxo/ipns"
 	keystore "github.com/ipfs/boxo/keystore"
@@ -54,7 +53,7 @@ func (api *NameAPI) Publish(ctx context.Context, p path.Path, opts ...caopts.Nam
 		return ipns.Name{}, err
 	}
 
-	eol := time.Now().Add(options.ValidTime)
+	eol := api.clock.Now().Add(options.ValidTime)
 
 	publishOptions := []namesys.PublishOption{
 		namesys.PublishWithEOL(eol),
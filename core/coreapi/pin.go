@@ -13,6 +13,7 @@ import (
 	"github.com/ipfs/go-cid"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
 	caopts "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/node"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
@@ -25,6 +26,10 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Add", trace.WithAttributes(attribute.String("path", p.String())))
 	defer span.End()
 
+	if _, ok := node.FetchPriorityFromContext(ctx); !ok {
+		ctx = node.WithFetchPriority(ctx, node.FetchPriorityBackground)
+	}
+
 	dagNode, err := api.core().ResolveNode(ctx, p)
 	if err != nil {
 		return fmt.Errorf("pin: %s", err)
@@ -130,6 +135,10 @@ func (api *PinAPI) Update(ctx context.Context, from path.Path, to path.Path, opt
 	))
 	defer span.End()
 
+	if _, ok := node.FetchPriorityFromContext(ctx); !ok {
+		ctx = node.WithFetchPriority(ctx, node.FetchPriorityBackground)
+	}
+
 	settings, err := caopts.PinUpdateOptions(opts...)
 	if err != nil {
 		return err
@@ -0,0 +1,18 @@
+//go:build !testclock
+
+package corehttp
+
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/kubo/core"
+)
+
+// TestClockOption serves the mock clock of the node in the builds with the
+// testclock tag, and nothing in the others.
+func TestClockOption(path string) ServeOption {
+	return func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		return mux, nil
+	}
+}
@@ -0,0 +1,52 @@
+//go:build testclock
+
+package corehttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	core "github.com/ipfs/kubo/core"
+)
+
+// TestClockOption exposes the mock clock of a node started with
+// IPFS_TEST_CLOCK: a GET request returns its time, and a POST request with
+// parameter 'advance', a duration, moves it forward and fires the timers that
+// came due. Nothing is served when the node uses the real clock.
+func TestClockOption(path string) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mock, ok := n.Clock.(*clock.Mock)
+		if !ok {
+			return mux, nil
+		}
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+			case http.MethodPost:
+				if err := r.ParseForm(); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				d, err := time.ParseDuration(r.Form.Get("advance"))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if d < 0 {
+					http.Error(w, "the clock can only be advanced", http.StatusBadRequest)
+					return
+				}
+				log.Infof("Advancing the test clock by %s", d)
+				mock.Add(d)
+			default:
+				http.Error(w, "only GET and POST allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			fmt.Fprintln(w, mock.Now().Format(time.RFC3339Nano))
+		})
+		return mux, nil
+	}
+}
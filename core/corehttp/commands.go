@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	cmdsHttp "github.com/ipfs/go-ipfs-cmds/http"
@@ -16,6 +17,7 @@ import (
 	config "github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core"
 	corecommands "github.com/ipfs/kubo/core/commands"
+	"github.com/ipfs/kubo/core/node"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
@@ -140,9 +142,16 @@ func commandsOption(cctx oldcmds.Context, command *cmds.Command) ServeOption {
 		patchCORSVars(cfg, l.Addr())
 
 		cmdHandler := cmdsHttp.NewHandler(&cctx, command, cfg)
+		if n.KeyAudit != nil {
+			cmdHandler = withCommandContext(command, cmdHandler)
+		}
+		if n.RPCStats != nil {
+			cmdHandler = withRPCStats(n.RPCStats, command, cmdHandler)
+		}
 
 		if len(rcfg.API.Authorizations) > 0 {
 			authorizations := convertAuthorizationsMap(rcfg.API.Authorizations)
+			cmdHandler = withRPCQuotas(n.RPCQuotas, cmdHandler)
 			cmdHandler = withAuthSecrets(authorizations, cmdHandler)
 		}
 
@@ -179,6 +188,8 @@ func withAuthSecrets(authorizations map[string]rpcAuthScopeWithUser, next http.H
 		auth, ok := authorizations[authorizationHeader]
 
 		if ok {
+			r = r.WithContext(node.WithRPCUser(r.Context(), auth.User))
+
 			// version check is implicitly allowed
 			if r.URL.Path == "/api/v0/version" {
 				next.ServeHTTP(w, r)
@@ -197,6 +208,102 @@ func withAuthSecrets(authorizations map[string]rpcAuthScopeWithUser, next http.H
 	})
 }
 
+// withRPCQuotas enforces the request rate and concurrent add quotas of the
+// token a request was authorized with. Pin quotas are enforced by the pinning
+// commands themselves.
+func withRPCQuotas(quotas *node.RPCQuotaTracker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := node.RPCUserFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !quotas.AllowRequest(user) {
+			http.Error(w, "Kubo RPC quota exceeded: too many requests for this authorization token.", http.StatusTooManyRequests)
+			return
+		}
+
+		if r.URL.Path == APIPath+"/add" {
+			release, ok := quotas.AcquireAdd(user)
+			if !ok {
+				http.Error(w, "Kubo RPC quota exceeded: too many concurrent adds for this authorization token.", http.StatusTooManyRequests)
+				return
+			}
+			defer release()
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRPCStats counts the calls of the commands, their failures and their
+// latency, for ipfs stats api.
+func withRPCStats(stats *node.RPCStats, root *cmds.Command, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		command := rpcCommandPath(root, strings.TrimPrefix(r.URL.Path, APIPath))
+		if command == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, _ := node.RPCUserFromContext(r.Context())
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		// errors happening while streaming the response are sent in a trailer
+		failed := sw.status >= http.StatusBadRequest || w.Header().Get(cmdsHttp.StreamErrHeader) != ""
+		stats.Record(command, user, time.Since(start), failed)
+	})
+}
+
+// withCommandContext sets the command of a request in its context, so that
+// the uses of the keys are recorded with it, see ipfs key audit.
+func withCommandContext(root *cmds.Command, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if command := rpcCommandPath(root, strings.TrimPrefix(r.URL.Path, APIPath)); command != "" {
+			r = r.WithContext(node.WithCommand(r.Context(), strings.ReplaceAll(command, "/", " ")))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rpcCommandPath returns the path of the command called at p, or "" when p
+// is not a command, so that unknown paths are not counted.
+func rpcCommandPath(root *cmds.Command, p string) string {
+	var names []string
+	cmd := root
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		sub, ok := cmd.Subcommands[name]
+		if !ok {
+			break
+		}
+		names = append(names, name)
+		cmd = sub
+	}
+	return strings.Join(names, "/")
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // CommandsOption constructs a ServerOption for hooking the commands into the
 // HTTP server. It will NOT allow GET requests.
 func CommandsOption(cctx oldcmds.Context) ServeOption {
@@ -231,3 +338,28 @@ func CheckVersionOption() ServeOption {
 		return mux, nil
 	}
 }
+
+// AllowedPathsOption returns a ServeOption that only serves the paths under
+// one of prefixes, for RPC API listeners exposing a subset of the commands.
+// Prefixes match whole path segments: /api/v0/pin does not allow
+// /api/v0/ping. The version check is implicitly allowed.
+func AllowedPathsOption(name string, prefixes []string) ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, parent *http.ServeMux) (*http.ServeMux, error) {
+		mux := http.NewServeMux()
+		parent.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == APIPath+"/version" {
+				mux.ServeHTTP(w, r)
+				return
+			}
+			for _, prefix := range prefixes {
+				prefix = strings.TrimSuffix(prefix, "/")
+				if r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/") {
+					mux.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, fmt.Sprintf("Kubo RPC Access Denied: %s is not exposed by the %q listener defined in API.Listeners.", r.URL.Path, name), http.StatusForbidden)
+		})
+		return mux, nil
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/ipfs/boxo/blockservice"
 	"github.com/ipfs/boxo/exchange/offline"
 	"github.com/ipfs/boxo/files"
@@ -33,13 +35,68 @@ func GatewayOption(paths ...string) ServeOption {
 			return nil, err
 		}
 
+		auth, err := newGatewayAuthorizerFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		limiter, err := newGatewayRateLimiterFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		cache, err := newGatewayResponseCacheFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		features, err := newGatewayFeaturesFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		shadow, err := newGatewayShadowFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		notifier, err := newGatewayNotifierFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		receipts, err := newGatewayReceiptsFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		publishing, err := gatewayIPNSPublishingFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+		if publishing {
+			headers = withPutAllowed(headers)
+		}
+
 		backend, err := newGatewayBackend(n)
 		if err != nil {
 			return nil, err
 		}
 
 		handler := gateway.NewHandler(config, backend)
+		handler = withCodecPaths(backend, n.OfflineUnixFSPathResolver, handler)
+		handler = withGatewayResponseCache(cache, handler)
+		handler = withGatewayReceipts(receipts, handler)
+		handler = withGatewayIPNSPublishing(n, publishing, handler)
+		handler = withGatewayDenylists(n.GatewayDenylists, handler)
+		handler = withGatewayNotifications(notifier, handler)
+		handler = withGatewayFeatures(features, handler)
+		handler = withGatewayShadow(shadow, handler)
+		handler = withGatewayAuthorization(auth, handler)
+		handler = withGatewayRateLimit(limiter, handler)
 		handler = gateway.NewHeaders(headers).ApplyCors().Wrap(handler)
+		handler = withFetchPriority(handler)
+		handler = withGatewayAccessLog(n.GatewayAccessLog, handler)
 		handler = otelhttp.NewHandler(handler, "Gateway")
 
 		for _, p := range paths {
@@ -57,6 +114,16 @@ func HostnameOption() ServeOption {
 			return nil, err
 		}
 
+		auth, err := newGatewayAuthorizerFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		limiter, err := newGatewayRateLimiterFromNode(n)
+		if err != nil {
+			return nil, err
+		}
+
 		backend, err := newGatewayBackend(n)
 		if err != nil {
 			return nil, err
@@ -66,7 +133,11 @@ func HostnameOption() ServeOption {
 
 		var handler http.Handler
 		handler = gateway.NewHostnameHandler(config, backend, childMux)
+		handler = withGatewayAuthorization(auth, handler)
+		handler = withGatewayRateLimit(limiter, handler)
 		handler = gateway.NewHeaders(headers).ApplyCors().Wrap(handler)
+		handler = withFetchPriority(handler)
+		handler = withGatewayAccessLog(n.GatewayAccessLog, handler)
 		handler = otelhttp.NewHandler(handler, "HostnameGateway")
 
 		mux.Handle("/", handler)
@@ -113,6 +184,18 @@ func Libp2pGatewayOption() ServeOption {
 	}
 }
 
+// withFetchPriority marks block fetches of page loads (navigations and HTML
+// requests) as interactive so they preempt background work such as pinning.
+func withFetchPriority(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		priority := node.FetchPriorityNormal
+		if r.Header.Get("Sec-Fetch-Dest") == "document" || strings.Contains(r.Header.Get("Accept"), "text/html") {
+			priority = node.FetchPriorityInteractive
+		}
+		next.ServeHTTP(w, r.WithContext(node.WithFetchPriority(r.Context(), priority)))
+	})
+}
+
 func newGatewayBackend(n *core.IpfsNode) (gateway.IPFSBackend, error) {
 	cfg, err := n.Repo.Config()
 	if err != nil {
@@ -153,6 +236,20 @@ func newGatewayBackend(n *core.IpfsNode) (gateway.IPFSBackend, error) {
 		pathResolver = n.OfflineUnixFSPathResolver
 	}
 
+	var coalescing *coalescingBlockService
+	var maxPlanSize uint64
+	if cfg.Gateway.RangeCoalescing.Enabled.WithDefault(config.DefaultGatewayRangeCoalescingEnabled) {
+		maxPlanSize, err = humanize.ParseBytes(cfg.Gateway.RangeCoalescing.MaxPlanSize.WithDefault(config.DefaultGatewayRangeCoalescingMaxPlanSize))
+		if err != nil {
+			return nil, fmt.Errorf("Gateway.RangeCoalescing.MaxPlanSize: %w", err)
+		}
+		coalescing = newCoalescingBlockService(bserv)
+		bserv = coalescing
+	}
+	if cfg.Gateway.Receipts.Enabled.WithDefault(config.DefaultGatewayReceiptsEnabled) {
+		bserv = newReceiptBlockService(bserv)
+	}
+
 	backend, err := gateway.NewBlocksBackend(bserv,
 		gateway.WithValueStore(vsRouting),
 		gateway.WithNameSystem(nsys),
@@ -161,7 +258,8 @@ func newGatewayBackend(n *core.IpfsNode) (gateway.IPFSBackend, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &offlineGatewayErrWrapper{gwimpl: backend}, nil
+	return withGatewayRedirectsMetrics(withGatewayDirectoryIndex(n.GatewayDirectoryIndex, bserv,
+		withGatewayRangePlanning(coalescing, maxPlanSize, &offlineGatewayErrWrapper{gwimpl: backend}))), nil
 }
 
 type offlineGatewayErrWrapper struct {
@@ -246,6 +344,46 @@ var defaultKnownGateways = map[string]*gateway.PublicGateway{
 	"localhost": subdomainGatewaySpec,
 }
 
+func newGatewayAuthorizerFromNode(n *core.IpfsNode) (*gatewayAuthorizer, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return newGatewayAuthorizer(cfg)
+}
+
+func newGatewayRateLimiterFromNode(n *core.IpfsNode) (*gatewayRateLimiter, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return newGatewayRateLimiter(cfg)
+}
+
+func newGatewayResponseCacheFromNode(n *core.IpfsNode) (*gatewayResponseCache, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return newGatewayResponseCache(cfg)
+}
+
+func newGatewayShadowFromNode(n *core.IpfsNode) (*gatewayShadow, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return newGatewayShadow(cfg)
+}
+
+func newGatewayNotifierFromNode(n *core.IpfsNode) (*gatewayNotifier, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return newGatewayNotifier(cfg)
+}
+
 func getGatewayConfig(n *core.IpfsNode) (gateway.Config, map[string][]string, error) {
 	cfg, err := n.Repo.Config()
 	if err != nil {
@@ -0,0 +1,108 @@
+package corehttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/core/node"
+)
+
+type gatewayAccessLoggedKey struct{}
+
+// withGatewayAccessLog writes the record of each request to the access log of
+// the gateway, when Gateway.AccessLog is enabled.
+func withGatewayAccessLog(l *node.GatewayAccessLog, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the hostname gateway and the path gateway wrap the same requests
+		if r.Context().Value(gatewayAccessLoggedKey{}) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the hostname gateway rewrites the path of the requests it serves
+		rec := &node.GatewayAccessRecord{
+			Time:      time.Now(),
+			Client:    gatewayRequestIP(r),
+			Method:    r.Method,
+			Host:      r.Host,
+			Path:      r.URL.Path,
+			Cache:     node.GatewayCacheHit,
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+		}
+		content := gatewayRequestContent(r)
+
+		ctx, fetches := l.Track(context.WithValue(r.Context(), gatewayAccessLoggedKey{}, true))
+		aw := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r.WithContext(ctx))
+
+		rec.Duration = time.Since(rec.Time)
+		rec.Status, rec.Root, rec.Bytes = aw.status, aw.root, aw.bytes
+		if rec.Status == 0 {
+			rec.Status = http.StatusOK
+		}
+		if rec.Root == "" {
+			// the CID of an /ipfs/ path is its root, even if unavailable
+			if c, err := cid.Decode(strings.TrimPrefix(content, "ipfs/")); err == nil {
+				rec.Root = c.String()
+			}
+		}
+		if aw.firstByte.IsZero() {
+			rec.TimeToFirstByte = rec.Duration
+		} else {
+			rec.TimeToFirstByte = aw.firstByte.Sub(rec.Time)
+		}
+		rec.BlocksFetched, rec.Peers = fetches.Result()
+		if rec.BlocksFetched > 0 {
+			rec.Cache = node.GatewayCacheMiss
+		}
+		l.Write(rec)
+	})
+}
+
+// accessLogWriter records the status, the resolved root CID and the size of
+// a response, and when its first byte was written.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status    int
+	root      string
+	bytes     int64
+	firstByte time.Time
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		// the first of the roots of the path segments
+		w.root, _, _ = strings.Cut(w.Header().Get("X-Ipfs-Roots"), ",")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.firstByte.IsZero() {
+		w.firstByte = time.Now()
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,210 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/ipfs/kubo/config"
+)
+
+const (
+	// gatewayAuthorizationCacheSize is the number of decisions cached per
+	// public gateway.
+	gatewayAuthorizationCacheSize = 10000
+	// gatewayAuthorizationMaxBody bounds the body of denials, such as a
+	// paywall page, forwarded to the client.
+	gatewayAuthorizationMaxBody = 64 << 10
+)
+
+// GatewayAuthorizationRequest is the description of a gateway request POSTed
+// to Gateway.PublicGateways[host].Authorization.Endpoint.
+type GatewayAuthorizationRequest struct {
+	Host          string
+	Method        string
+	URI           string
+	RemoteAddr    string
+	Authorization string `json:",omitempty"`
+	Cookie        string `json:",omitempty"`
+}
+
+// gatewayDecision is the response of an authorization endpoint, replayed to
+// the clients of a denied or redirected request.
+type gatewayDecision struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// forwardedDecisionHeaders are the headers of the endpoint response sent to
+// the client of a denied or redirected request.
+var forwardedDecisionHeaders = []string{"Content-Type", "Location", "WWW-Authenticate", "Retry-After"}
+
+// gatewayAuthorizer consults the authorization endpoints of the public
+// gateways before their requests are served.
+type gatewayAuthorizer struct {
+	gateways map[string]*gatewayAuthorization
+	// subdomains tells whether the gateways of the same hostname use
+	// subdomains, to match the requests to their subdomains.
+	subdomains map[string]bool
+}
+
+type gatewayAuthorization struct {
+	endpoint string
+	client   *http.Client
+	cache    *expirable.LRU[string, *gatewayDecision]
+}
+
+type gatewayAuthorizedKey struct{}
+
+// newGatewayAuthorizer returns the authorizer of the public gateways with an
+// Authorization, or nil if there is none.
+func newGatewayAuthorizer(cfg *config.Config) (*gatewayAuthorizer, error) {
+	a := &gatewayAuthorizer{
+		gateways:   map[string]*gatewayAuthorization{},
+		subdomains: map[string]bool{},
+	}
+	for hostname, gw := range cfg.Gateway.PublicGateways {
+		if gw == nil || gw.Authorization == nil {
+			continue
+		}
+		auth := gw.Authorization
+		if auth.Endpoint == "" {
+			return nil, fmt.Errorf("Gateway.PublicGateways[%q].Authorization: missing Endpoint", hostname)
+		}
+		a.gateways[hostname] = &gatewayAuthorization{
+			endpoint: auth.Endpoint,
+			client: &http.Client{
+				Timeout: auth.Timeout.WithDefault(config.DefaultGatewayAuthorizationTimeout),
+				// redirects are decisions, sent to the client
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			},
+			cache: expirable.NewLRU[string, *gatewayDecision](gatewayAuthorizationCacheSize, nil, auth.CacheTTL.WithDefault(config.DefaultGatewayAuthorizationCacheTTL)),
+		}
+		a.subdomains[hostname] = gw.UseSubdomains
+	}
+	if len(a.gateways) == 0 {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// lookup returns the authorization of the public gateway serving host, which
+// is either its hostname or, for subdomain gateways, one of its subdomains.
+func (a *gatewayAuthorizer) lookup(host string) *gatewayAuthorization {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if auth, ok := a.gateways[host]; ok {
+		return auth
+	}
+	for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+		if auth, ok := a.gateways[host]; ok && a.subdomains[host] {
+			return auth
+		}
+	}
+	return nil
+}
+
+// withGatewayAuthorization serves the requests of the public gateways with an
+// Authorization only once their endpoint allowed them.
+func withGatewayAuthorization(a *gatewayAuthorizer, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := a.lookup(r.Host)
+		// the hostname gateway and the path gateway wrap the same requests
+		if auth == nil || r.Context().Value(gatewayAuthorizedKey{}) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d, err := auth.decide(r)
+		if err != nil {
+			log.Errorf("gateway authorization of %s%s: %s", r.Host, r.URL.Path, err)
+			http.Error(w, "gateway authorization failed", http.StatusBadGateway)
+			return
+		}
+		if d.status >= 200 && d.status < 300 {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), gatewayAuthorizedKey{}, true)))
+			return
+		}
+		for k, v := range d.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(d.status)
+		_, _ = w.Write(d.body)
+	})
+}
+
+// decide returns the decision of the endpoint for r, cached for identical
+// requests from the same client address. The port of the client is not part
+// of the cache key, so that the decisions outlive its connections.
+func (a *gatewayAuthorization) decide(r *http.Request) (*gatewayDecision, error) {
+	req := GatewayAuthorizationRequest{
+		Host:          r.Host,
+		Method:        r.Method,
+		URI:           r.URL.RequestURI(),
+		RemoteAddr:    r.RemoteAddr,
+		Authorization: r.Header.Get("Authorization"),
+		Cookie:        r.Header.Get("Cookie"),
+	}
+	remoteIP := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteIP); err == nil {
+		remoteIP = host
+	}
+	key := strings.Join([]string{req.Host, req.Method, req.URI, remoteIP, req.Authorization, req.Cookie}, "\n")
+	if d, ok := a.cache.Get(key); ok {
+		return d, nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, a.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	res, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	d := &gatewayDecision{status: res.StatusCode, header: http.Header{}}
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+	case res.StatusCode >= 300 && res.StatusCode < 400:
+		if res.Header.Get("Location") == "" {
+			return nil, fmt.Errorf("redirect without Location")
+		}
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		if d.body, err = io.ReadAll(io.LimitReader(res.Body, gatewayAuthorizationMaxBody)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected HTTP status %d", res.StatusCode)
+	}
+	for _, h := range forwardedDecisionHeaders {
+		if v, ok := res.Header[h]; ok {
+			d.header[h] = v
+		}
+	}
+
+	if !strings.Contains(res.Header.Get("Cache-Control"), "no-store") {
+		a.cache.Add(key, d)
+	}
+	return d, nil
+}
@@ -0,0 +1,57 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayAuthorizationCache(t *testing.T) {
+	// the endpoint allows the requests from a single client address
+	var calls int
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req GatewayAuthorizationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !strings.HasPrefix(req.RemoteAddr, "10.0.0.1:") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	cfg := &config.Config{}
+	cfg.Gateway.PublicGateways = map[string]*config.GatewaySpec{
+		"example.com": {Authorization: &config.GatewayAuthorization{Endpoint: endpoint.URL}},
+	}
+	a, err := newGatewayAuthorizer(cfg)
+	require.NoError(t, err)
+	auth := a.lookup("example.com")
+	require.NotNil(t, auth)
+
+	decide := func(remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/ipfs/content", nil)
+		r.RemoteAddr = remoteAddr
+		d, err := auth.decide(r)
+		require.NoError(t, err)
+		return d.status
+	}
+
+	assert.Equal(t, http.StatusNoContent, decide("10.0.0.1:1000"))
+	// the decisions of a client are not reused for the others
+	assert.Equal(t, http.StatusForbidden, decide("10.0.0.2:1000"))
+	assert.Equal(t, 2, calls)
+	// but are for its other connections
+	assert.Equal(t, http.StatusNoContent, decide("10.0.0.1:2000"))
+	assert.Equal(t, 2, calls)
+}
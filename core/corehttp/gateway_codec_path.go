@@ -0,0 +1,178 @@
+package corehttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/path"
+	pathresolver "github.com/ipfs/boxo/path/resolver"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	basicnode "github.com/ipld/go-ipld-prime/node/basicnode"
+	mc "github.com/multiformats/go-multicodec"
+
+	_ "github.com/ipld/go-ipld-prime/codec/cbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	_ "github.com/ipld/go-ipld-prime/codec/json"
+)
+
+// codecPathFormats are the codecs the paths within the data of a block can be
+// returned in, by the value of the format parameter.
+var codecPathFormats = map[string]mc.Code{
+	"dag-json": mc.DagJson,
+	"dag-cbor": mc.DagCbor,
+	"json":     mc.Json,
+	"cbor":     mc.Cbor,
+}
+
+var codecPathContentTypes = map[mc.Code]string{
+	mc.DagJson: "application/vnd.ipld.dag-json",
+	mc.DagCbor: "application/vnd.ipld.dag-cbor",
+	mc.Json:    "application/json",
+	mc.Cbor:    "application/cbor",
+}
+
+// withCodecPaths serves the paths within the data of the blocks of IPLD
+// codecs, such as /ipfs/<dag-cbor cid>/a/b/0, which the gateway handler only
+// serves when they end at a link. The map keys escaped in the URL, such as
+// %2F, are kept escaped in the paths resolved by the gateway. The segments
+// missing from the data of a block are reported by the offline resolver, whose
+// blocks were fetched by the resolution of the backend.
+func withCodecPaths(backend gateway.IPFSBackend, offline pathresolver.Resolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.EscapedPath(), "/ipfs/")
+		if !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		segments := strings.Split(rest, "/")
+		c, err := cid.Decode(segments[0])
+		if err != nil || !node.IsCodecBlock(c) || len(segments) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for i, segment := range segments[1:] {
+			segments[i+1] = node.EscapePathSegment(node.UnescapePathSegment(segment))
+		}
+		r = r.Clone(r.Context())
+		r.URL.Path = "/ipfs/" + strings.Join(segments, "/")
+		r.URL.RawPath = ""
+
+		format, ok := codecPathFormat(r, c)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, err := path.NewPath(strings.TrimSuffix(r.URL.Path, "/"))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		imPath, err := path.NewImmutablePath(p)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		md, err := backend.ResolvePath(r.Context(), imPath)
+		if errors.Is(err, &pathresolver.ErrNoLink{}) {
+			var codecErr *node.ErrCodecPath
+			if _, _, err := offline.ResolveToLastNode(r.Context(), imPath); errors.As(err, &codecErr) {
+				http.Error(w, codecErr.Error(), http.StatusNotFound)
+				return
+			}
+		}
+		if err != nil || len(md.LastSegmentRemainder) == 0 {
+			// the other errors are reported by the gateway handler
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		data, err := encodeCodecPath(backend, r, md, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var roots []string
+		for _, root := range md.PathSegmentRoots {
+			roots = append(roots, root.String())
+		}
+		roots = append(roots, md.LastSegment.RootCid().String())
+		w.Header().Set("X-Ipfs-Path", r.URL.Path)
+		w.Header().Set("X-Ipfs-Roots", strings.Join(roots, ","))
+		w.Header().Set("Content-Type", codecPathContentTypes[format])
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	})
+}
+
+// codecPathFormat returns the codec requested for a path within the data of
+// the block c, by the format parameter or the Accept header, defaulting to
+// the codec of c. HTML requests are left to the gateway handler.
+func codecPathFormat(r *http.Request, c cid.Cid) (mc.Code, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		code, ok := codecPathFormats[format]
+		return code, ok
+	}
+	accept := r.Header.Get("Accept")
+	for _, code := range []mc.Code{mc.DagJson, mc.DagCbor, mc.Json, mc.Cbor} {
+		if strings.Contains(accept, codecPathContentTypes[code]) {
+			return code, true
+		}
+	}
+	if strings.Contains(accept, "text/html") {
+		return 0, false
+	}
+	code := mc.Code(c.Prefix().Codec)
+	_, ok := codecPathContentTypes[code]
+	return code, ok
+}
+
+// encodeCodecPath returns the node at the remainder of md, in the data of its
+// last block, encoded with format.
+func encodeCodecPath(backend gateway.IPFSBackend, r *http.Request, md gateway.ContentPathMetadata, format mc.Code) ([]byte, error) {
+	blockPath, err := path.NewPathFromSegments("ipfs", md.LastSegment.RootCid().String())
+	if err != nil {
+		return nil, err
+	}
+	imPath, err := path.NewImmutablePath(blockPath)
+	if err != nil {
+		return nil, err
+	}
+	_, block, err := backend.GetBlock(r.Context(), imPath)
+	if err != nil {
+		return nil, err
+	}
+	defer block.Close()
+
+	decoder, err := multicodec.LookupDecoder(md.LastSegment.RootCid().Prefix().Codec)
+	if err != nil {
+		return nil, err
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := decoder(nb, block); err != nil {
+		return nil, err
+	}
+	nd, err := node.LookupCodecPath(nb.Build(), imPath, md.LastSegmentRemainder)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := multicodec.LookupEncoder(uint64(format))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encoder(nd, &buf); err != nil {
+		return nil, fmt.Errorf("encoding %s as %s: %w", r.URL.Path, format, err)
+	}
+	return buf.Bytes(), nil
+}
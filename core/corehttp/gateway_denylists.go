@@ -0,0 +1,83 @@
+package corehttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ipfs-shipyard/nopfs"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/core/node"
+)
+
+// withGatewayDenylists answers the requests blocked by the denylists of their
+// hostname, see GatewaySpec.Denylists, with 410 Gone. The requested path is
+// checked first, then the CIDs it resolved to, reported in X-Ipfs-Roots.
+func withGatewayDenylists(d *node.GatewayDenylists, next http.Handler) http.Handler {
+	if d == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the hostname gateway rewrites the subdomain and DNSLink requests
+		// to their content path
+		if p, err := path.NewPath(r.URL.Path); err == nil {
+			if resp := d.IsPathBlocked(r.Host, p); resp.Status == nopfs.StatusBlocked {
+				http.Error(w, resp.ToError().Error(), http.StatusGone)
+				return
+			}
+		}
+		next.ServeHTTP(&denylistWriter{ResponseWriter: w, denylists: d, host: r.Host}, r)
+	})
+}
+
+// denylistWriter replaces the responses whose roots are blocked with 410
+// Gone.
+type denylistWriter struct {
+	http.ResponseWriter
+	denylists *node.GatewayDenylists
+	host      string
+	written   bool
+	blocked   bool
+}
+
+func (w *denylistWriter) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	w.written = true
+	for _, root := range strings.Split(w.Header().Get("X-Ipfs-Roots"), ",") {
+		c, err := cid.Decode(strings.TrimSpace(root))
+		if err != nil {
+			continue
+		}
+		if resp := w.denylists.IsCidBlocked(w.host, c); resp.Status == nopfs.StatusBlocked {
+			w.blocked = true
+			for k := range w.Header() {
+				w.Header().Del(k)
+			}
+			http.Error(w.ResponseWriter, resp.ToError().Error(), http.StatusGone)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *denylistWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.blocked {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *denylistWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.blocked {
+		f.Flush()
+	}
+}
+
+func (w *denylistWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,161 @@
+package corehttp
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/boxo/path/resolver"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/node"
+	mc "github.com/multiformats/go-multicodec"
+)
+
+// dirIndexBackend lists the directories from the GatewayDirectoryIndex, see
+// Gateway.DirectoryIndexCache, and caches the directories it enumerates. The
+// cached directories are listed without reading their blocks, and their
+// index.html is not looked up when they have none.
+type dirIndexBackend struct {
+	gateway.IPFSBackend
+	index *node.GatewayDirectoryIndex
+	dag   ipld.DAGService
+}
+
+func withGatewayDirectoryIndex(index *node.GatewayDirectoryIndex, bserv blockservice.BlockService, backend gateway.IPFSBackend) gateway.IPFSBackend {
+	if index == nil {
+		return backend
+	}
+	return &dirIndexBackend{IPFSBackend: backend, index: index, dag: merkledag.NewDAGService(bserv)}
+}
+
+func (b *dirIndexBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...gateway.ByteRange) (gateway.ContentPathMetadata, *gateway.GetResponse, error) {
+	if err := b.missingIndexHTML(p); err != nil {
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	md, c, ok := b.resolveDirectory(ctx, p)
+	if !ok {
+		return b.IPFSBackend.Get(ctx, p, ranges...)
+	}
+	if listing, ok := b.index.Get(ctx, c); ok {
+		ctx, cancel := context.WithCancel(ctx)
+		return md, gateway.NewGetResponseFromDirectoryListing(listing.DagSize, replayDirectory(ctx, listing), closeFunc(cancel)), nil
+	}
+
+	nd, err := b.dag.Get(ctx, c)
+	if err != nil {
+		return b.IPFSBackend.Get(ctx, p, ranges...)
+	}
+	pb, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		return b.IPFSBackend.Get(ctx, p, ranges...)
+	}
+	if fsNode, err := unixfs.ExtractFSNode(pb); err != nil || !fsNode.IsDir() {
+		return b.IPFSBackend.Get(ctx, p, ranges...)
+	}
+	dir, err := uio.NewDirectoryFromNode(b.dag, nd)
+	if err != nil {
+		return b.IPFSBackend.Get(ctx, p, ranges...)
+	}
+	dagSize, err := nd.Size()
+	if err != nil {
+		return b.IPFSBackend.Get(ctx, p, ranges...)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	entries := b.cacheDirectory(ctx, c, dagSize, dir.EnumLinksAsync(ctx))
+	return md, gateway.NewGetResponseFromDirectoryListing(dagSize, entries, closeFunc(cancel)), nil
+}
+
+func (b *dirIndexBackend) Head(ctx context.Context, p path.ImmutablePath) (gateway.ContentPathMetadata, *gateway.HeadResponse, error) {
+	if err := b.missingIndexHTML(p); err != nil {
+		return gateway.ContentPathMetadata{}, nil, err
+	}
+	if md, c, ok := b.resolveDirectory(ctx, p); ok {
+		if listing, ok := b.index.Get(ctx, c); ok {
+			return md, gateway.NewHeadResponseForDirectory(int64(listing.DagSize)), nil
+		}
+	}
+	return b.IPFSBackend.Head(ctx, p)
+}
+
+// resolveDirectory resolves p to the CID of a dag-pb node, which may be a
+// directory.
+func (b *dirIndexBackend) resolveDirectory(ctx context.Context, p path.ImmutablePath) (gateway.ContentPathMetadata, cid.Cid, bool) {
+	md, err := b.IPFSBackend.ResolvePath(ctx, p)
+	if err != nil || len(md.LastSegmentRemainder) > 0 {
+		return md, cid.Undef, false
+	}
+	c := md.LastSegment.RootCid()
+	return md, c, mc.Code(c.Prefix().Codec) == mc.DagPb
+}
+
+// missingIndexHTML returns the error of the lookup of the index.html of a
+// cached directory which has none, which the gateway does before listing it.
+func (b *dirIndexBackend) missingIndexHTML(p path.ImmutablePath) error {
+	segments := p.Segments()
+	if len(segments) != 3 || segments[2] != "index.html" {
+		return nil
+	}
+	c := p.RootCid()
+	if has, cached := b.index.HasIndexHTML(c); !cached || has {
+		return nil
+	}
+	return &resolver.ErrNoLink{Name: "index.html", Node: c}
+}
+
+// cacheDirectory forwards the entries of the directory c, and caches them
+// once they are all enumerated without error.
+func (b *dirIndexBackend) cacheDirectory(ctx context.Context, c cid.Cid, dagSize uint64, in <-chan unixfs.LinkResult) <-chan unixfs.LinkResult {
+	out := make(chan unixfs.LinkResult)
+	go func() {
+		defer close(out)
+		listing := &node.GatewayDirectoryListing{DagSize: dagSize}
+		for l := range in {
+			if l.Err != nil {
+				listing = nil
+			} else if listing != nil {
+				listing.Entries = append(listing.Entries, node.GatewayDirectoryEntry{Name: l.Link.Name, Cid: l.Link.Cid, Size: l.Link.Size})
+			}
+			select {
+			case out <- l:
+			case <-ctx.Done():
+				return
+			}
+		}
+		// the enumeration also ends when ctx is canceled
+		if listing == nil || ctx.Err() != nil {
+			return
+		}
+		if err := b.index.Put(ctx, c, listing); err != nil {
+			log.Errorf("caching the directory index of %s: %s", c, err)
+		}
+	}()
+	return out
+}
+
+// replayDirectory sends the cached entries of a directory.
+func replayDirectory(ctx context.Context, listing *node.GatewayDirectoryListing) <-chan unixfs.LinkResult {
+	out := make(chan unixfs.LinkResult)
+	go func() {
+		defer close(out)
+		for _, e := range listing.Entries {
+			select {
+			case out <- unixfs.LinkResult{Link: &ipld.Link{Name: e.Name, Cid: e.Cid, Size: e.Size}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func closeFunc(cancel context.CancelFunc) func() error {
+	return func() error {
+		cancel()
+		return nil
+	}
+}
@@ -0,0 +1,253 @@
+package corehttp
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+)
+
+// gatewayFeatures are the features of a public gateway which can be turned
+// off by hostname, see GatewaySpec.
+type gatewayFeatures struct {
+	trustless        bool
+	ipns             bool
+	directoryListing bool
+}
+
+// trustlessResponseFormats are the verifiable response formats, served with
+// GatewaySpec.TrustlessResponses.
+var trustlessResponseFormats = map[string]bool{
+	"application/vnd.ipld.raw":         true,
+	"application/vnd.ipld.car":         true,
+	"application/vnd.ipfs.ipns-record": true,
+}
+
+var trustlessFormatParams = map[string]string{
+	"raw":         "application/vnd.ipld.raw",
+	"car":         "application/vnd.ipld.car",
+	"ipns-record": "application/vnd.ipfs.ipns-record",
+}
+
+// newGatewayFeatures returns the features of the public gateways, or nil if
+// none turns one off.
+func newGatewayFeatures(cfg *config.Config) (*gatewayHostnames[*gatewayFeatures], error) {
+	features := map[string]*gatewayFeatures{}
+	restricted := false
+	for hostname, gw := range cfg.Gateway.PublicGateways {
+		if gw == nil {
+			continue
+		}
+		f := &gatewayFeatures{
+			trustless:        gw.TrustlessResponses.WithDefault(config.DefaultTrustlessResponses),
+			ipns:             gw.IPNSResolution.WithDefault(config.DefaultIPNSResolution),
+			directoryListing: gw.DirectoryListing.WithDefault(config.DefaultDirectoryListing),
+		}
+		deserialized := gw.DeserializedResponses.WithDefault(cfg.Gateway.DeserializedResponses.WithDefault(config.DefaultDeserializedResponses))
+		if !f.trustless && !deserialized {
+			return nil, fmt.Errorf("Gateway.PublicGateways[%q]: DeserializedResponses and TrustlessResponses are both disabled", hostname)
+		}
+		// the hostnames with every feature are kept, so that they are not
+		// matched by a pattern turning one off
+		features[hostname] = f
+		restricted = restricted || !f.trustless || !f.ipns || !f.directoryListing
+	}
+	if !restricted {
+		return nil, nil
+	}
+	return newGatewayHostnames(features), nil
+}
+
+func newGatewayFeaturesFromNode(n *core.IpfsNode) (*gatewayHostnames[*gatewayFeatures], error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return newGatewayFeatures(cfg)
+}
+
+// withGatewayFeatures refuses the requests for the features turned off on
+// their hostname: the trustless response formats, and the IPNS records PUT
+// with Gateway.IPNSPublishing, with 406 Not Acceptable, and the /ipns/ paths
+// and the generated directory listings with 403 Forbidden.
+func withGatewayFeatures(h *gatewayHostnames[*gatewayFeatures], next http.Handler) http.Handler {
+	if h == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := h.lookup(r.Host)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !f.trustless && (isTrustlessRequest(r) || isIPNSRecordPut(r)) {
+			http.Error(w, "trustless responses are disabled on this gateway", http.StatusNotAcceptable)
+			return
+		}
+		// the DNSLink websites are governed by NoDNSLink
+		_, dnslink := r.Context().Value(gateway.DNSLinkHostnameKey).(string)
+		if !f.ipns && !dnslink && strings.HasPrefix(r.URL.Path, "/ipns/") {
+			http.Error(w, "IPNS resolution is disabled on this gateway", http.StatusForbidden)
+			return
+		}
+		if !f.directoryListing {
+			w = &dirListingWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isTrustlessRequest tells whether r requests a trustless response format,
+// in the order the gateway looks for the response format: the first
+// explicit type of the Accept header, else the format parameter.
+func isTrustlessRequest(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, value := range strings.Split(header, ",") {
+			accept := strings.TrimSpace(value)
+			if strings.HasPrefix(accept, "application/vnd.ipld") ||
+				strings.HasPrefix(accept, "application/vnd.ipfs") ||
+				strings.HasPrefix(accept, "application/x-tar") ||
+				strings.HasPrefix(accept, "application/json") ||
+				strings.HasPrefix(accept, "application/cbor") {
+				mediatype, _, err := mime.ParseMediaType(accept)
+				return err == nil && trustlessResponseFormats[mediatype]
+			}
+		}
+	}
+	return trustlessResponseFormats[trustlessFormatParams[r.URL.Query().Get("format")]]
+}
+
+// dirListingWriter replaces the directory listings generated by the gateway,
+// recognized by their Etag, with 403 Forbidden. The index.html of the
+// directories is still served.
+type dirListingWriter struct {
+	http.ResponseWriter
+	written bool
+	refused bool
+}
+
+func (w *dirListingWriter) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	w.written = true
+	if strings.HasPrefix(w.Header().Get("Etag"), `"DirIndex-`) {
+		w.refused = true
+		for k := range w.Header() {
+			w.Header().Del(k)
+		}
+		http.Error(w.ResponseWriter, "directory listing is disabled on this gateway", http.StatusForbidden)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *dirListingWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.refused {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *dirListingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.refused {
+		f.Flush()
+	}
+}
+
+func (w *dirListingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// gatewayHostnames finds the settings of the public gateway serving a host,
+// which is either one of its hostnames or, for subdomain gateways, a
+// subdomain such as <cid>.ipfs.<hostname>. A * in a hostname matches within
+// a label, such as *.example.com or gw-*.example.com. The exact hostnames are
+// matched first, then the patterns, the most specific first, so that the
+// settings of overlapping patterns apply in a predictable order.
+type gatewayHostnames[T any] struct {
+	exact    map[string]T
+	patterns []gatewayHostnamePattern[T]
+}
+
+type gatewayHostnamePattern[T any] struct {
+	hostname string
+	re       *regexp.Regexp
+	value    T
+}
+
+func newGatewayHostnames[T any](values map[string]T) *gatewayHostnames[T] {
+	h := &gatewayHostnames[T]{exact: map[string]T{}}
+	for hostname, v := range values {
+		hostname = strings.ToLower(hostname)
+		if !strings.Contains(hostname, "*") {
+			h.exact[hostname] = v
+			continue
+		}
+		labels := strings.Split(hostname, ".")
+		for i, label := range labels {
+			parts := strings.Split(label, "*")
+			for j, part := range parts {
+				parts[j] = regexp.QuoteMeta(part)
+			}
+			labels[i] = strings.Join(parts, "[^.]+")
+		}
+		h.patterns = append(h.patterns, gatewayHostnamePattern[T]{
+			hostname: hostname,
+			re:       regexp.MustCompile("^" + strings.Join(labels, `\.`) + "$"),
+			value:    v,
+		})
+	}
+	sort.Slice(h.patterns, func(i, j int) bool {
+		a, b := h.patterns[i].hostname, h.patterns[j].hostname
+		if la, lb := len(strings.ReplaceAll(a, "*", "")), len(strings.ReplaceAll(b, "*", "")); la != lb {
+			return la > lb
+		}
+		return a < b
+	})
+	return h
+}
+
+func (h *gatewayHostnames[T]) match(hostname string) (T, bool) {
+	if v, ok := h.exact[hostname]; ok {
+		return v, true
+	}
+	for _, p := range h.patterns {
+		if p.re.MatchString(hostname) {
+			return p.value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func (h *gatewayHostnames[T]) lookup(host string) (T, bool) {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+	if v, ok := h.match(host); ok {
+		return v, true
+	}
+	labels := strings.Split(host, ".")
+	for i := 1; i < len(labels)-1; i++ {
+		if labels[i] != "ipfs" && labels[i] != "ipns" {
+			continue
+		}
+		if v, ok := h.match(strings.Join(labels[i+1:], ".")); ok {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
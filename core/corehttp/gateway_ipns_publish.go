@@ -0,0 +1,123 @@
+package corehttp
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ipnsRecordContentType = "application/vnd.ipfs.ipns-record"
+
+// Results of the IPNS records PUT to the gateway, as reported by the metrics.
+const (
+	gatewayIPNSPublishAccepted = "accepted"
+	gatewayIPNSPublishInvalid  = "invalid"
+	gatewayIPNSPublishFailed   = "failed"
+)
+
+var gatewayIPNSPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_ipns_publish_total",
+	Help: "IPNS records PUT to the gateway with Gateway.IPNSPublishing, by result (accepted, invalid or failed).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(gatewayIPNSPublished)
+}
+
+func gatewayIPNSPublishingFromNode(n *core.IpfsNode) (bool, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return false, err
+	}
+	return cfg.Gateway.IPNSPublishing.WithDefault(config.DefaultIPNSPublishing), nil
+}
+
+// withPutAllowed returns the headers of the gateway with PUT allowed in the
+// CORS requests, unless Access-Control-Allow-Methods is set in
+// Gateway.HTTPHeaders.
+func withPutAllowed(headers map[string][]string) map[string][]string {
+	const methods = "Access-Control-Allow-Methods"
+	if _, ok := headers[methods]; ok {
+		return headers
+	}
+	headers = maps.Clone(headers)
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+	headers[methods] = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut}
+	return headers
+}
+
+// isIPNSRecordPut tells whether r puts an IPNS record.
+func isIPNSRecordPut(r *http.Request) bool {
+	return r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/ipns/")
+}
+
+// withGatewayIPNSPublishing accepts the signed IPNS records PUT to
+// /ipns/{name}: the record is validated against the name, and put to the
+// routing system, which keeps it only if it is newer than the record it
+// knows. The other requests are served by next.
+func withGatewayIPNSPublishing(n *core.IpfsNode, enabled bool, next http.Handler) http.Handler {
+	if !enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isIPNSRecordPut(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		status, err := putIPNSRecord(n, r)
+		switch {
+		case err == nil:
+			gatewayIPNSPublished.WithLabelValues(gatewayIPNSPublishAccepted).Inc()
+			w.WriteHeader(http.StatusOK)
+		case status < http.StatusInternalServerError:
+			gatewayIPNSPublished.WithLabelValues(gatewayIPNSPublishInvalid).Inc()
+			http.Error(w, err.Error(), status)
+		default:
+			gatewayIPNSPublished.WithLabelValues(gatewayIPNSPublishFailed).Inc()
+			log.Debugf("gateway IPNS publishing of %s: %s", r.URL.Path, err)
+			http.Error(w, err.Error(), status)
+		}
+	})
+}
+
+// putIPNSRecord puts the record of r, and returns the status of the error.
+func putIPNSRecord(n *core.IpfsNode, r *http.Request) (int, error) {
+	mediatype, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediatype != ipnsRecordContentType {
+		return http.StatusUnsupportedMediaType, errors.New("the IPNS record must be sent as " + ipnsRecordContentType)
+	}
+	name, err := ipns.NameFromString(strings.TrimPrefix(r.URL.Path, "/ipns/"))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, int64(ipns.MaxRecordSize)+1))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(data) > ipns.MaxRecordSize {
+		return http.StatusRequestEntityTooLarge, ipns.ErrRecordSize
+	}
+	rec, err := ipns.UnmarshalRecord(data)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := ipns.ValidateWithName(rec, name); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if err := n.Routing.PutValue(r.Context(), string(name.RoutingKey()), data); err != nil {
+		return http.StatusBadGateway, err
+	}
+	return http.StatusOK, nil
+}
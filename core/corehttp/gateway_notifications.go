@@ -0,0 +1,238 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Events of Gateway.Notifications.
+const (
+	gatewayEventNotFound        = "not-found"
+	gatewayEventRetrievalFailed = "retrieval-failed"
+	gatewayEventSlow            = "slow"
+	gatewayEventBlocked         = "blocked"
+)
+
+var gatewayEvents = []string{gatewayEventNotFound, gatewayEventRetrievalFailed, gatewayEventSlow, gatewayEventBlocked}
+
+// Results of the notifications, as reported by the metrics.
+const (
+	gatewayNotificationSent    = "sent"
+	gatewayNotificationFailed  = "failed"
+	gatewayNotificationDropped = "dropped"
+)
+
+var gatewayNotifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_notifications_total",
+	Help: "Events of the gateway POSTed to Gateway.Notifications.URL, by result (sent, failed or dropped).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(gatewayNotifications)
+}
+
+// gatewayEvent is the JSON body POSTed to the webhook.
+type gatewayEvent struct {
+	Event  string
+	Time   time.Time
+	Method string
+	Host   string
+	// Path is the content path, after the subdomain and DNSLink gateways
+	// rewrote it.
+	Path   string
+	Status int
+	// DurationMs is the time to the response headers, in milliseconds.
+	DurationMs int64
+	Roots      string `json:",omitempty"`
+}
+
+// gatewayNotifier POSTs the events of Gateway.Notifications.
+type gatewayNotifier struct {
+	url           string
+	headers       map[string]string
+	events        map[string]bool
+	slowThreshold time.Duration
+	client        *http.Client
+	slots         chan struct{}
+}
+
+func newGatewayNotifier(cfg *config.Config) (*gatewayNotifier, error) {
+	c := cfg.Gateway.Notifications
+	if c == nil {
+		return nil, nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("Gateway.Notifications.URL: invalid URL %q", c.URL)
+	}
+	events := map[string]bool{}
+	for _, e := range c.Events {
+		events[e] = true
+	}
+	for e := range events {
+		if !slices.Contains(gatewayEvents, e) {
+			return nil, fmt.Errorf("Gateway.Notifications.Events: unknown event %q, must be not-found, retrieval-failed, slow or blocked", e)
+		}
+	}
+	if len(events) == 0 {
+		for _, e := range gatewayEvents {
+			events[e] = true
+		}
+	}
+	slowThreshold := c.SlowThreshold.WithDefault(config.DefaultGatewayNotificationsSlowThreshold)
+	if slowThreshold <= 0 {
+		return nil, fmt.Errorf("Gateway.Notifications.SlowThreshold must be positive")
+	}
+	maxConcurrent := c.MaxConcurrent.WithDefault(config.DefaultGatewayNotificationsMaxConcurrent)
+	if maxConcurrent <= 0 {
+		return nil, fmt.Errorf("Gateway.Notifications.MaxConcurrent must be positive")
+	}
+	return &gatewayNotifier{
+		url:           u.String(),
+		headers:       c.Headers,
+		events:        events,
+		slowThreshold: slowThreshold,
+		client:        &http.Client{Timeout: c.Timeout.WithDefault(config.DefaultGatewayNotificationsTimeout)},
+		slots:         make(chan struct{}, maxConcurrent),
+	}, nil
+}
+
+// withGatewayNotifications sends the event of each request which was not
+// found, failed to be retrieved, was slow or was blocked by a denylist, once
+// served. The client of the gateway does not wait for the webhook.
+func withGatewayNotifications(s *gatewayNotifier, next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nw := &notifyingWriter{ResponseWriter: w, start: time.Now()}
+		next.ServeHTTP(nw, r)
+		if nw.status == 0 {
+			nw.status, nw.elapsed = http.StatusOK, time.Since(nw.start)
+		}
+		event := s.eventOf(nw.status, nw.elapsed)
+		if event == "" {
+			return
+		}
+		s.notify(gatewayEvent{
+			Event:      event,
+			Time:       nw.start,
+			Method:     r.Method,
+			Host:       r.Host,
+			Path:       r.URL.Path,
+			Status:     nw.status,
+			DurationMs: nw.elapsed.Milliseconds(),
+			Roots:      nw.roots,
+		})
+	})
+}
+
+// eventOf returns the event of a response, or "" when it is not sent.
+func (s *gatewayNotifier) eventOf(status int, elapsed time.Duration) string {
+	var event string
+	switch {
+	case status == http.StatusNotFound:
+		event = gatewayEventNotFound
+	case status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		event = gatewayEventRetrievalFailed
+	case status == http.StatusGone || status == http.StatusUnavailableForLegalReasons:
+		event = gatewayEventBlocked
+	case status < http.StatusBadRequest && elapsed >= s.slowThreshold:
+		event = gatewayEventSlow
+	}
+	if !s.events[event] {
+		return ""
+	}
+	return event
+}
+
+// notify POSTs e to the webhook in the background, or drops it when
+// MaxConcurrent requests are in flight.
+func (s *gatewayNotifier) notify(e gatewayEvent) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		gatewayNotifications.WithLabelValues(gatewayNotificationDropped).Inc()
+		return
+	}
+	go func() {
+		defer func() { <-s.slots }()
+		result := gatewayNotificationSent
+		if err := s.post(e); err != nil {
+			result = gatewayNotificationFailed
+			log.Debugf("gateway notification of %s %s: %s", e.Event, e.Path, err)
+		}
+		gatewayNotifications.WithLabelValues(result).Inc()
+	}()
+}
+
+func (s *gatewayNotifier) post(e gatewayEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyingWriter records the status, the roots and the time to the headers
+// of a response.
+type notifyingWriter struct {
+	http.ResponseWriter
+	start   time.Time
+	elapsed time.Duration
+	status  int
+	roots   string
+}
+
+func (w *notifyingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.elapsed = time.Since(w.start)
+		w.roots = w.Header().Get("X-Ipfs-Roots")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *notifyingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *notifyingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *notifyingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
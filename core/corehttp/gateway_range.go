@@ -0,0 +1,306 @@
+package corehttp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/path"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Block fetches of the gateway, as reported by the metrics: the fetches
+// started, and the ones which waited for a fetch started by another request.
+const (
+	gatewayBlockFetchStarted   = "started"
+	gatewayBlockFetchCoalesced = "coalesced"
+)
+
+var gatewayBlockFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_block_fetches_total",
+	Help: "Block fetches of the gateway with Gateway.RangeCoalescing, by result (started or coalesced).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(gatewayBlockFetches)
+}
+
+// coalescingBlockService shares the fetches of the same block between the
+// requests of the gateway, see Gateway.RangeCoalescing. A fetch goes on while
+// a request waits for it, whichever request started it, and is canceled once
+// none does.
+type coalescingBlockService struct {
+	blockservice.BlockService
+
+	mu      sync.Mutex
+	fetches map[cid.Cid]*blockFetch
+}
+
+type blockFetch struct {
+	done    chan struct{}
+	blk     blocks.Block
+	err     error
+	waiters int
+	// group are the fetches started together, by GetBlocks, which share the
+	// same context
+	group *blockFetchGroup
+}
+
+type blockFetchGroup struct {
+	cancel  context.CancelFunc
+	pending int
+}
+
+func newCoalescingBlockService(bs blockservice.BlockService) *coalescingBlockService {
+	return &coalescingBlockService{BlockService: bs, fetches: map[cid.Cid]*blockFetch{}}
+}
+
+// join returns the fetch of c, and whether it was started by this call.
+func (s *coalescingBlockService) join(c cid.Cid, group *blockFetchGroup) (*blockFetch, bool) {
+	if f, ok := s.fetches[c]; ok {
+		f.waiters++
+		gatewayBlockFetches.WithLabelValues(gatewayBlockFetchCoalesced).Inc()
+		return f, false
+	}
+	f := &blockFetch{done: make(chan struct{}), waiters: 1, group: group}
+	group.pending++
+	s.fetches[c] = f
+	gatewayBlockFetches.WithLabelValues(gatewayBlockFetchStarted).Inc()
+	return f, true
+}
+
+// finish records the result of the fetch of c.
+func (s *coalescingBlockService) finish(c cid.Cid, f *blockFetch, blk blocks.Block, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-f.done:
+		return
+	default:
+	}
+	f.blk, f.err = blk, err
+	close(f.done)
+	s.release(c, f)
+}
+
+// release forgets the fetch of c, done or abandoned, and cancels the fetches
+// of its group once they all are.
+func (s *coalescingBlockService) release(c cid.Cid, f *blockFetch) {
+	if s.fetches[c] == f {
+		delete(s.fetches, c)
+	}
+	f.group.pending--
+	if f.group.pending == 0 {
+		f.group.cancel()
+	}
+}
+
+// wait returns the result of the fetch of c, or abandons it when ctx is
+// done.
+func (s *coalescingBlockService) wait(ctx context.Context, c cid.Cid, f *blockFetch) (blocks.Block, error) {
+	select {
+	case <-f.done:
+		return f.blk, f.err
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f.waiters--
+	select {
+	case <-f.done:
+	default:
+		if f.waiters == 0 {
+			f.err = ctx.Err()
+			close(f.done)
+			s.release(c, f)
+		}
+	}
+	return nil, ctx.Err()
+}
+
+// fetchContext returns the context of the fetches started by a request,
+// which outlive it as long as other requests wait for them.
+func fetchContext(ctx context.Context) (context.Context, *blockFetchGroup) {
+	fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	return fctx, &blockFetchGroup{cancel: cancel}
+}
+
+func (s *coalescingBlockService) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	fctx, group := fetchContext(ctx)
+	s.mu.Lock()
+	f, started := s.join(c, group)
+	s.mu.Unlock()
+	if started {
+		go func() {
+			blk, err := s.BlockService.GetBlock(fctx, c)
+			s.finish(c, f, blk, err)
+		}()
+	} else {
+		group.cancel()
+	}
+	return s.wait(ctx, c, f)
+}
+
+func (s *coalescingBlockService) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Block {
+	fctx, group := fetchContext(ctx)
+	fetches := make(map[cid.Cid]*blockFetch, len(ks))
+	var started []cid.Cid
+	s.mu.Lock()
+	for _, c := range ks {
+		if _, ok := fetches[c]; ok {
+			continue
+		}
+		f, ok := s.join(c, group)
+		fetches[c] = f
+		if ok {
+			started = append(started, c)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(started) > 0 {
+		go func() {
+			for blk := range s.BlockService.GetBlocks(fctx, started) {
+				if f, ok := fetches[blk.Cid()]; ok {
+					s.finish(blk.Cid(), f, blk, nil)
+				}
+			}
+			// the blocks not found are not sent, like by the BlockService
+			for _, c := range started {
+				s.finish(c, fetches[c], nil, ipld.ErrNotFound{Cid: c})
+			}
+		}()
+	} else {
+		group.cancel()
+	}
+
+	out := make(chan blocks.Block)
+	var wg sync.WaitGroup
+	for c, f := range fetches {
+		wg.Add(1)
+		go func(c cid.Cid, f *blockFetch) {
+			defer wg.Done()
+			blk, err := s.wait(ctx, c, f)
+			if err != nil {
+				return
+			}
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+			}
+		}(c, f)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// rangePlanningBackend fetches the blocks of the byte range of a UnixFS file
+// ahead of the reads of the gateway, with the coalescingBlockService: the
+// blocks of the range are found from the sizes of the children of each node
+// of the file, and the children of a level of the DAG are fetched at once.
+// The concurrent requests for overlapping ranges share the fetches of their
+// common blocks.
+type rangePlanningBackend struct {
+	gateway.IPFSBackend
+	dag         ipld.DAGService
+	maxPlanSize uint64
+}
+
+func withGatewayRangePlanning(bs *coalescingBlockService, maxPlanSize uint64, backend gateway.IPFSBackend) gateway.IPFSBackend {
+	if bs == nil {
+		return backend
+	}
+	return &rangePlanningBackend{IPFSBackend: backend, dag: merkledag.NewDAGService(bs), maxPlanSize: maxPlanSize}
+}
+
+func (b *rangePlanningBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...gateway.ByteRange) (gateway.ContentPathMetadata, *gateway.GetResponse, error) {
+	md, resp, err := b.IPFSBackend.Get(ctx, p, ranges...)
+	if err == nil && len(ranges) > 0 && len(md.LastSegmentRemainder) == 0 {
+		// the request context ends once the response is written
+		go b.plan(ctx, md.LastSegment.RootCid(), ranges[0])
+	}
+	return md, resp, err
+}
+
+// plan fetches the blocks of the range of the file c, level by level.
+func (b *rangePlanningBackend) plan(ctx context.Context, c cid.Cid, ra gateway.ByteRange) {
+	if b.maxPlanSize == 0 {
+		return
+	}
+	root, err := b.dag.Get(ctx, c)
+	if err != nil {
+		return
+	}
+	fsn, err := unixfs.ExtractFSNode(root)
+	if err != nil || fsn.IsDir() || fsn.FileSize() == 0 {
+		return
+	}
+	from, to := ra.From, fsn.FileSize()-1
+	if ra.To != nil && *ra.To >= 0 && uint64(*ra.To) < to {
+		to = uint64(*ra.To)
+	} else if ra.To != nil && *ra.To < 0 && uint64(-*ra.To) <= to {
+		to -= uint64(-*ra.To) - 1
+	}
+	if from > to {
+		return
+	}
+	if to-from >= b.maxPlanSize {
+		to = from + b.maxPlanSize - 1
+	}
+
+	// offsets are the offsets in the file of the nodes of a level
+	level := []ipld.Node{root}
+	offsets := map[cid.Cid][]uint64{c: {0}}
+	for len(level) > 0 {
+		var children []cid.Cid
+		childOffsets := map[cid.Cid][]uint64{}
+		for _, nd := range level {
+			pn, ok := nd.(*merkledag.ProtoNode)
+			if !ok || len(pn.Links()) == 0 {
+				continue
+			}
+			fsn, err := unixfs.FSNodeFromBytes(pn.Data())
+			if err != nil || fsn.NumChildren() != len(pn.Links()) {
+				continue
+			}
+			for _, offset := range offsets[nd.Cid()] {
+				offset += uint64(len(fsn.Data()))
+				for i, l := range pn.Links() {
+					size := fsn.BlockSize(i)
+					if offset <= to && offset+size > from {
+						if _, ok := childOffsets[l.Cid]; !ok {
+							children = append(children, l.Cid)
+						}
+						childOffsets[l.Cid] = append(childOffsets[l.Cid], offset)
+					}
+					offset += size
+				}
+			}
+		}
+		if len(children) == 0 {
+			return
+		}
+
+		level = level[:0]
+		for opt := range b.dag.GetMany(ctx, children) {
+			if opt.Err != nil {
+				return
+			}
+			// the leaves are only fetched, the intermediate nodes are
+			// walked next
+			if len(opt.Node.Links()) > 0 {
+				level = append(level, opt.Node)
+			}
+		}
+		offsets = childOffsets
+	}
+}
@@ -0,0 +1,200 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingExchange serves the blocks of a blockstore, once gate is closed,
+// and counts the blocks requested.
+type countingExchange struct {
+	source blockstore.Blockstore
+	gate   chan struct{}
+
+	mu        sync.Mutex
+	requested map[cid.Cid]int
+	canceled  int
+}
+
+func newCountingExchange(source blockstore.Blockstore) *countingExchange {
+	gate := make(chan struct{})
+	close(gate)
+	return &countingExchange{source: source, gate: gate, requested: map[cid.Cid]int{}}
+}
+
+func (e *countingExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	e.mu.Lock()
+	e.requested[c]++
+	e.mu.Unlock()
+	select {
+	case <-e.gate:
+	case <-ctx.Done():
+		e.mu.Lock()
+		e.canceled++
+		e.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	return e.source.Get(ctx, c)
+}
+
+func (e *countingExchange) GetBlocks(ctx context.Context, ks []cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for _, c := range ks {
+			blk, err := e.GetBlock(ctx, c)
+			if err != nil {
+				return
+			}
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e *countingExchange) NotifyNewBlocks(context.Context, ...blocks.Block) error { return nil }
+func (e *countingExchange) Close() error                                           { return nil }
+
+func (e *countingExchange) requests() map[cid.Cid]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r := make(map[cid.Cid]int, len(e.requested))
+	for c, n := range e.requested {
+		r[c] = n
+	}
+	return r
+}
+
+func newTestBlockstore() blockstore.Blockstore {
+	return blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+}
+
+func TestCoalescingBlockService(t *testing.T) {
+	ctx := context.Background()
+	source := newTestBlockstore()
+	blk := blocks.NewBlock([]byte("shared block"))
+	require.NoError(t, source.Put(ctx, blk))
+
+	t.Run("the requests of a block wait for a single fetch", func(t *testing.T) {
+		ex := newCountingExchange(source)
+		ex.gate = make(chan struct{})
+		bs := newCoalescingBlockService(blockservice.New(newTestBlockstore(), ex))
+
+		// the first request gives up, the others still get the block
+		firstCtx, cancelFirst := context.WithCancel(ctx)
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := bs.GetBlock(firstCtx, blk.Cid())
+			firstErr <- err
+		}()
+		require.Eventually(t, func() bool { return ex.requests()[blk.Cid()] == 1 }, time.Second, time.Millisecond)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := bs.GetBlock(ctx, blk.Cid())
+				assert.NoError(t, err)
+				assert.Equal(t, blk.RawData(), got.RawData())
+			}()
+		}
+		require.Eventually(t, func() bool {
+			bs.mu.Lock()
+			defer bs.mu.Unlock()
+			return bs.fetches[blk.Cid()].waiters == 5
+		}, time.Second, time.Millisecond)
+		cancelFirst()
+		assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+		close(ex.gate)
+		wg.Wait()
+		assert.Equal(t, 1, ex.requests()[blk.Cid()])
+		assert.Zero(t, ex.canceled)
+	})
+
+	t.Run("the fetch is canceled once no request waits for it", func(t *testing.T) {
+		ex := newCountingExchange(source)
+		ex.gate = make(chan struct{})
+		bs := newCoalescingBlockService(blockservice.New(newTestBlockstore(), ex))
+
+		reqCtx, cancel := context.WithCancel(ctx)
+		out := bs.GetBlocks(reqCtx, []cid.Cid{blk.Cid(), blk.Cid()})
+		require.Eventually(t, func() bool { return ex.requests()[blk.Cid()] == 1 }, time.Second, time.Millisecond)
+		cancel()
+		for range out {
+			t.Fatal("no block is sent once the request is canceled")
+		}
+		require.Eventually(t, func() bool {
+			ex.mu.Lock()
+			defer ex.mu.Unlock()
+			return ex.canceled == 1
+		}, time.Second, time.Millisecond)
+
+		// a new request fetches it again
+		close(ex.gate)
+		got, err := bs.GetBlock(ctx, blk.Cid())
+		require.NoError(t, err)
+		assert.Equal(t, blk.Cid(), got.Cid())
+		assert.Equal(t, 2, ex.requests()[blk.Cid()])
+	})
+}
+
+func TestRangePlanning(t *testing.T) {
+	ctx := context.Background()
+	source := newTestBlockstore()
+	data := make([]byte, 100_000)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	// 256 bytes leaves, under 3 intermediate nodes of up to 174 leaves
+	root, err := importer.BuildDagFromReader(
+		merkledag.NewDAGService(blockservice.New(source, nil)),
+		chunker.NewSizeSplitter(bytes.NewReader(data), 256),
+	)
+	require.NoError(t, err)
+	require.Len(t, root.Links(), 3)
+
+	ex := newCountingExchange(source)
+	bs := newCoalescingBlockService(blockservice.New(newTestBlockstore(), ex))
+	b := &rangePlanningBackend{dag: merkledag.NewDAGService(bs), maxPlanSize: 64 << 20}
+
+	to := int64(1999)
+	b.plan(ctx, root.Cid(), gateway.ByteRange{From: 1000, To: &to})
+
+	inner, err := merkledag.NewDAGService(blockservice.New(source, nil)).Get(ctx, root.Links()[0].Cid)
+	require.NoError(t, err)
+	want := map[cid.Cid]int{root.Cid(): 1, inner.Cid(): 1}
+	// the bytes 1000 to 1999 are in the leaves 3 to 7
+	for _, l := range inner.Links()[3:8] {
+		want[l.Cid] = 1
+	}
+	assert.Equal(t, want, ex.requests())
+
+	// the plan is bounded
+	ex = newCountingExchange(source)
+	bs = newCoalescingBlockService(blockservice.New(newTestBlockstore(), ex))
+	b = &rangePlanningBackend{dag: merkledag.NewDAGService(bs), maxPlanSize: 512}
+	b.plan(ctx, root.Cid(), gateway.ByteRange{From: 0})
+	assert.Len(t, ex.requests(), 4, "the root, an intermediate node and 2 leaves")
+}
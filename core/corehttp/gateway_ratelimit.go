@@ -0,0 +1,348 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatewayRateLimitBuckets is the number of clients, and of CIDs, whose usage
+// is tracked. The least recently seen are forgotten first.
+const gatewayRateLimitBuckets = 100000
+
+// Limits of a gateway rate limit policy, as reported by the metrics.
+const (
+	gatewayLimitRequests = "requests"
+	gatewayLimitRanges   = "ranges"
+	gatewayLimitBytes    = "bytes"
+)
+
+var gatewayRateLimitRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_ratelimit_rejected_total",
+	Help: "Gateway requests answered with 429 Too Many Requests, by policy (ip or cid) and limit (requests, ranges or bytes).",
+}, []string{"policy", "limit"})
+
+func init() {
+	prometheus.MustRegister(gatewayRateLimitRejected)
+}
+
+// gatewayRateLimiter enforces the policies of Gateway.RateLimit.
+type gatewayRateLimiter struct {
+	policies []*gatewayRatePolicy
+	exempt   []netip.Prefix
+}
+
+// gatewayRatePolicy enforces the limits of Gateway.RateLimit.PerIP or
+// Gateway.RateLimit.PerCID on the requests with the same key.
+type gatewayRatePolicy struct {
+	name    string
+	subject string
+	key     func(*http.Request) string
+
+	rps       float64
+	burst     float64
+	bps       float64
+	maxRanges int64
+
+	mu      sync.Mutex
+	buckets *lru.Cache[string, *gatewayRateBucket]
+}
+
+// gatewayRateBucket is the usage of one client or CID: the token buckets of
+// its requests and bytes, and its requests with a Range header in flight.
+type gatewayRateBucket struct {
+	mu       sync.Mutex
+	requests float64
+	bytes    float64
+	last     time.Time
+	ranges   int64
+}
+
+type gatewayRateLimitedKey struct{}
+
+// newGatewayRateLimiter returns the rate limiter of Gateway.RateLimit, or nil
+// if there is no limit.
+func newGatewayRateLimiter(cfg *config.Config) (*gatewayRateLimiter, error) {
+	rl := cfg.Gateway.RateLimit
+	if rl == nil {
+		return nil, nil
+	}
+
+	l := &gatewayRateLimiter{}
+	for _, e := range rl.Exempt {
+		prefix, err := netip.ParsePrefix(e)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(e)
+			if addrErr != nil {
+				return nil, fmt.Errorf("Gateway.RateLimit.Exempt: invalid IP address or CIDR %q", e)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		l.exempt = append(l.exempt, prefix.Masked())
+	}
+
+	for _, p := range []struct {
+		option  string
+		name    string
+		subject string
+		policy  *config.GatewayRateLimitPolicy
+		key     func(*http.Request) string
+	}{
+		{"PerIP", "ip", "client", rl.PerIP, gatewayRequestIP},
+		{"PerCID", "cid", "content", rl.PerCID, gatewayRequestContent},
+	} {
+		if p.policy == nil {
+			continue
+		}
+		policy, err := newGatewayRatePolicy(p.policy)
+		if err != nil {
+			return nil, fmt.Errorf("Gateway.RateLimit.%s: %w", p.option, err)
+		}
+		if policy == nil {
+			continue
+		}
+		policy.name, policy.subject, policy.key = p.name, p.subject, p.key
+		l.policies = append(l.policies, policy)
+	}
+	if len(l.policies) == 0 {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// newGatewayRatePolicy returns the policy enforcing the limits of cfg, or nil
+// if it has none.
+func newGatewayRatePolicy(cfg *config.GatewayRateLimitPolicy) (*gatewayRatePolicy, error) {
+	rps := cfg.RequestsPerSecond.WithDefault(0)
+	burst := cfg.Burst.WithDefault(rps)
+	maxRanges := cfg.MaxConcurrentRanges.WithDefault(0)
+	if rps < 0 || burst < 0 || maxRanges < 0 {
+		return nil, fmt.Errorf("limits must not be negative")
+	}
+	if rps > 0 && burst < 1 {
+		return nil, fmt.Errorf("Burst must allow at least one request")
+	}
+	var bps uint64
+	if s := cfg.BytesPerSecond.WithDefault(""); s != "" {
+		var err error
+		if bps, err = humanize.ParseBytes(s); err != nil {
+			return nil, fmt.Errorf("invalid BytesPerSecond: %w", err)
+		}
+	}
+	if rps == 0 && maxRanges == 0 && bps == 0 {
+		return nil, nil
+	}
+
+	buckets, err := lru.New[string, *gatewayRateBucket](gatewayRateLimitBuckets)
+	if err != nil {
+		return nil, err
+	}
+	return &gatewayRatePolicy{
+		rps:       float64(rps),
+		burst:     float64(burst),
+		bps:       float64(bps),
+		maxRanges: maxRanges,
+		buckets:   buckets,
+	}, nil
+}
+
+// bucket returns the usage of key, starting with full token buckets.
+func (p *gatewayRatePolicy) bucket(key string, now time.Time) *gatewayRateBucket {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, ok := p.buckets.Get(key)
+	if !ok {
+		b = &gatewayRateBucket{requests: p.burst, bytes: p.bps, last: now}
+		p.buckets.Add(key, b)
+	}
+	return b
+}
+
+// admit takes a request, ranged when it has a Range header, from b. It
+// returns the limit it exceeds and when to retry, or an empty limit when it
+// is allowed.
+func (p *gatewayRatePolicy) admit(b *gatewayRateBucket, ranged bool, now time.Time) (string, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.requests = math.Min(p.burst, b.requests+elapsed*p.rps)
+	b.bytes = math.Min(p.bps, b.bytes+elapsed*p.bps)
+
+	// responses are charged once sent, so the bytes may be overdrawn
+	if p.bps > 0 && b.bytes <= 0 {
+		return gatewayLimitBytes, secondsDuration((1 - b.bytes) / p.bps)
+	}
+	if p.rps > 0 && b.requests < 1 {
+		return gatewayLimitRequests, secondsDuration((1 - b.requests) / p.rps)
+	}
+	if ranged && p.maxRanges > 0 && b.ranges >= p.maxRanges {
+		return gatewayLimitRanges, time.Second
+	}
+
+	if p.rps > 0 {
+		b.requests--
+	}
+	if ranged && p.maxRanges > 0 {
+		b.ranges++
+	}
+	return "", 0
+}
+
+func (p *gatewayRatePolicy) releaseRange(b *gatewayRateBucket) {
+	b.mu.Lock()
+	b.ranges--
+	b.mu.Unlock()
+}
+
+func (p *gatewayRatePolicy) charge(b *gatewayRateBucket, n int) {
+	b.mu.Lock()
+	b.bytes -= float64(n)
+	b.mu.Unlock()
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func (l *gatewayRateLimiter) exempted(r *http.Request) bool {
+	if len(l.exempt) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(gatewayRequestIP(r))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.exempt {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// withGatewayRateLimit answers the requests over the limits of l with 429 Too
+// Many Requests and a Retry-After header.
+func withGatewayRateLimit(l *gatewayRateLimiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the hostname gateway and the path gateway wrap the same requests
+		if r.Context().Value(gatewayRateLimitedKey{}) != nil || l.exempted(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		ranged := r.Header.Get("Range") != ""
+		lw := &rateLimitedWriter{ResponseWriter: w}
+		for _, p := range l.policies {
+			key := p.key(r)
+			if key == "" {
+				continue
+			}
+			b := p.bucket(key, now)
+			limit, retryAfter := p.admit(b, ranged, now)
+			if limit != "" {
+				gatewayRateLimitRejected.WithLabelValues(p.name, limit).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+				http.Error(w, fmt.Sprintf("gateway rate limit exceeded: too many %s for this %s", limit, p.subject), http.StatusTooManyRequests)
+				return
+			}
+			if ranged && p.maxRanges > 0 {
+				defer p.releaseRange(b)
+			}
+			if p.bps > 0 {
+				lw.charges = append(lw.charges, func(n int) { p.charge(b, n) })
+			}
+		}
+
+		if len(lw.charges) > 0 {
+			w = lw
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), gatewayRateLimitedKey{}, true)))
+	})
+}
+
+// gatewayRequestIP returns the IP address of the client of r.
+func gatewayRequestIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// gatewayRequestContent returns the CID or IPNS name requested by r, from its
+// path or, on subdomain gateways, from its host. CIDs are normalized to
+// CIDv1, to share the limits of their different encodings.
+func gatewayRequestContent(r *http.Request) string {
+	var ns, id string
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/ipfs/"); ok {
+		ns, id = "ipfs", rest
+	} else if rest, ok := strings.CutPrefix(r.URL.Path, "/ipns/"); ok {
+		ns, id = "ipns", rest
+	} else {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		labels := strings.SplitN(host, ".", 3)
+		if len(labels) < 3 || (labels[1] != "ipfs" && labels[1] != "ipns") {
+			return ""
+		}
+		ns, id = labels[1], labels[0]
+	}
+
+	id, _, _ = strings.Cut(id, "/")
+	if id == "" {
+		return ""
+	}
+	if ns == "ipfs" {
+		if c, err := cid.Decode(id); err == nil {
+			id = cid.NewCidV1(c.Type(), c.Hash()).String()
+		}
+	}
+	return ns + "/" + id
+}
+
+// rateLimitedWriter charges the bytes of a response to the byte budgets of
+// its client and content.
+type rateLimitedWriter struct {
+	http.ResponseWriter
+	charges []func(int)
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	for _, charge := range w.charges {
+		charge(n)
+	}
+	return n, err
+}
+
+func (w *rateLimitedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *rateLimitedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
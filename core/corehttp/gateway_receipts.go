@@ -0,0 +1,409 @@
+package corehttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// gatewayReceiptTrailer is the trailer with the summary of the blocks read to
+// serve a response.
+const gatewayReceiptTrailer = "X-Ipfs-Receipt"
+
+// receiptSignaturePrefix is prefixed to the payload signed, so that the
+// signatures of the receipts cannot be taken for other signatures of the
+// node.
+const receiptSignaturePrefix = "kubo-gateway-receipt:"
+
+// GatewayReceipt is the payload of the receipts of the gateway, returned by
+// the requests with ?receipt=true instead of their response.
+type GatewayReceipt struct {
+	// Peer is the node which served the response.
+	Peer   string
+	Time   time.Time
+	Host   string
+	Path   string
+	Status int
+	// Roots are the CIDs of the X-Ipfs-Roots header of the response, the
+	// first one being the root the blocks verify against.
+	Roots []string `json:",omitempty"`
+	Etag  string   `json:",omitempty"`
+	// Size and SHA256 are the size and the hex SHA-256 of the body of the
+	// response.
+	Size   int64
+	SHA256 string
+	// Blocks are the CIDs of the blocks read to serve the response, sorted.
+	Blocks []string
+}
+
+// SignedGatewayReceipt is a GatewayReceipt signed by the key of its peer.
+// The signature is of the payload prefixed with "kubo-gateway-receipt:".
+type SignedGatewayReceipt struct {
+	// Payload is the GatewayReceipt, as JSON.
+	Payload   []byte
+	Key       string
+	Signature []byte
+}
+
+// VerifyGatewayReceipt returns the receipt of a SignedGatewayReceipt, once
+// its signature is verified against the peer ID of its Key.
+func VerifyGatewayReceipt(data []byte) (*GatewayReceipt, error) {
+	var signed SignedGatewayReceipt
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("invalid receipt: %w", err)
+	}
+	id, err := peer.Decode(signed.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt key: %w", err)
+	}
+	pk, err := id.ExtractPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt key: %w", err)
+	}
+	if valid, err := pk.Verify(append([]byte(receiptSignaturePrefix), signed.Payload...), signed.Signature); err != nil || !valid {
+		return nil, errors.New("invalid receipt signature")
+	}
+	var receipt GatewayReceipt
+	if err := json.Unmarshal(signed.Payload, &receipt); err != nil {
+		return nil, fmt.Errorf("invalid receipt payload: %w", err)
+	}
+	if receipt.Peer != signed.Key {
+		return nil, fmt.Errorf("receipt of %s signed by %s", receipt.Peer, signed.Key)
+	}
+	return &receipt, nil
+}
+
+// receiptBlocks are the blocks read by a request of the gateway.
+type receiptBlocks struct {
+	mu   sync.Mutex
+	cids map[cid.Cid]struct{}
+}
+
+type receiptBlocksKey struct{}
+
+// recordReceiptBlock adds c to the blocks of the request of ctx, if any.
+func recordReceiptBlock(ctx context.Context, c cid.Cid) {
+	rb, ok := ctx.Value(receiptBlocksKey{}).(*receiptBlocks)
+	if !ok {
+		return
+	}
+	rb.mu.Lock()
+	rb.cids[c] = struct{}{}
+	rb.mu.Unlock()
+}
+
+// sorted returns the CIDs of the blocks, sorted so that the digest of the
+// same blocks is the same whatever the order they were read in.
+func (rb *receiptBlocks) sorted() []string {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	s := make([]string, 0, len(rb.cids))
+	for c := range rb.cids {
+		s = append(s, c.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+// receiptBlockService records the blocks read through it in the receipt of
+// the request. The sessions of the gateway read the blocks through the
+// Blockstore of the BlockService, and fetch the missing ones before putting
+// them there, which is why it is wrapped too.
+type receiptBlockService struct {
+	blockservice.BlockService
+	bs blockstore.Blockstore
+}
+
+func newReceiptBlockService(bs blockservice.BlockService) *receiptBlockService {
+	return &receiptBlockService{BlockService: bs, bs: &receiptBlockstore{Blockstore: bs.Blockstore()}}
+}
+
+func (s *receiptBlockService) Blockstore() blockstore.Blockstore {
+	return s.bs
+}
+
+func (s *receiptBlockService) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := s.BlockService.GetBlock(ctx, c)
+	if err == nil {
+		recordReceiptBlock(ctx, c)
+	}
+	return blk, err
+}
+
+func (s *receiptBlockService) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Block {
+	in := s.BlockService.GetBlocks(ctx, ks)
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for blk := range in {
+			recordReceiptBlock(ctx, blk.Cid())
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+type receiptBlockstore struct {
+	blockstore.Blockstore
+}
+
+func (bs *receiptBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := bs.Blockstore.Get(ctx, c)
+	if err == nil {
+		recordReceiptBlock(ctx, c)
+	}
+	return blk, err
+}
+
+func (bs *receiptBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	err := bs.Blockstore.Put(ctx, blk)
+	if err == nil {
+		recordReceiptBlock(ctx, blk.Cid())
+	}
+	return err
+}
+
+// gatewayReceipts serves the receipts of Gateway.Receipts.
+type gatewayReceipts struct {
+	key              crypto.PrivKey
+	id               peer.ID
+	maxTrailerBlocks int
+}
+
+func newGatewayReceipts(cfg *config.Config, sk crypto.PrivKey) (*gatewayReceipts, error) {
+	c := cfg.Gateway.Receipts
+	if !c.Enabled.WithDefault(config.DefaultGatewayReceiptsEnabled) {
+		return nil, nil
+	}
+	if sk == nil {
+		return nil, errors.New("Gateway.Receipts requires the identity of the node")
+	}
+	maxTrailerBlocks := c.MaxTrailerBlocks.WithDefault(config.DefaultGatewayReceiptsMaxTrailerBlocks)
+	if maxTrailerBlocks < 0 {
+		return nil, errors.New("Gateway.Receipts.MaxTrailerBlocks cannot be negative")
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	return &gatewayReceipts{key: sk, id: id, maxTrailerBlocks: int(maxTrailerBlocks)}, nil
+}
+
+func newGatewayReceiptsFromNode(n *core.IpfsNode) (*gatewayReceipts, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return newGatewayReceipts(cfg, n.PrivateKey)
+}
+
+// withGatewayReceipts records the blocks read to serve the GET requests.
+// They are summarized in the X-Ipfs-Receipt trailer of the responses to the
+// clients accepting trailers (TE: trailers), and the requests with
+// ?receipt=true are answered with the signed receipt of their response
+// instead of the response. The responses served without reading any block,
+// such as the ones of Gateway.ResponseCache, have no trailer.
+func withGatewayReceipts(s *gatewayReceipts, next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rb := &receiptBlocks{cids: map[cid.Cid]struct{}{}}
+		r = r.WithContext(context.WithValue(r.Context(), receiptBlocksKey{}, rb))
+
+		if r.URL.Query().Get("receipt") == "true" {
+			s.serveReceipt(w, r, rb, next)
+			return
+		}
+		if !acceptsTrailers(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tw := &receiptTrailerWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r)
+		if !tw.trailer {
+			return
+		}
+		if summary := s.summary(tw.roots, rb.sorted()); summary != "" {
+			w.Header().Set(gatewayReceiptTrailer, summary)
+		}
+	})
+}
+
+func acceptsTrailers(r *http.Request) bool {
+	for _, te := range r.Header.Values("TE") {
+		for _, v := range strings.Split(te, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// summary returns the value of the X-Ipfs-Receipt trailer:
+//
+//	root=<cid>; blocks=<n>; digest=sha256:<hex>; cids=<cid>,<cid>
+//
+// The digest is of the CIDs, sorted and joined with commas, which are only
+// listed up to maxTrailerBlocks.
+func (s *gatewayReceipts) summary(roots []string, cids []string) string {
+	if len(cids) == 0 {
+		return ""
+	}
+	list := strings.Join(cids, ",")
+	digest := sha256.Sum256([]byte(list))
+	var b strings.Builder
+	if len(roots) > 0 {
+		fmt.Fprintf(&b, "root=%s; ", roots[0])
+	}
+	fmt.Fprintf(&b, "blocks=%d; digest=sha256:%x", len(cids), digest)
+	if len(cids) <= s.maxTrailerBlocks {
+		fmt.Fprintf(&b, "; cids=%s", list)
+	}
+	return b.String()
+}
+
+// serveReceipt serves r to discard its response, and answers with its signed
+// receipt instead.
+func (s *gatewayReceipts) serveReceipt(w http.ResponseWriter, r *http.Request, rb *receiptBlocks, next http.Handler) {
+	start := time.Now()
+	rw := &receiptWriter{header: http.Header{}, hash: sha256.New()}
+	next.ServeHTTP(rw, r)
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	receipt := GatewayReceipt{
+		Peer:   s.id.String(),
+		Time:   start.UTC(),
+		Host:   r.Host,
+		Path:   r.URL.Path,
+		Status: rw.status,
+		Roots:  splitRoots(rw.header.Get("X-Ipfs-Roots")),
+		Etag:   rw.header.Get("Etag"),
+		Size:   rw.size,
+		SHA256: hex.EncodeToString(rw.hash.Sum(nil)),
+		Blocks: rb.sorted(),
+	}
+	payload, err := json.Marshal(receipt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sig, err := s.key.Sign(append([]byte(receiptSignaturePrefix), payload...))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(SignedGatewayReceipt{Payload: payload, Key: s.id.String(), Signature: sig})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(body)
+}
+
+func splitRoots(roots string) []string {
+	var s []string
+	for _, root := range strings.Split(roots, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			s = append(s, root)
+		}
+	}
+	return s
+}
+
+// receiptWriter hashes the response of a request with ?receipt=true, which is
+// not sent.
+type receiptWriter struct {
+	header http.Header
+	status int
+	size   int64
+	hash   hash.Hash
+}
+
+func (w *receiptWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *receiptWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *receiptWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.size += int64(len(p))
+	return w.hash.Write(p)
+}
+
+// receiptTrailerWriter declares the X-Ipfs-Receipt trailer of the successful
+// responses. Their Content-Length is removed, as HTTP/1.1 only sends the
+// trailers of the chunked responses.
+type receiptTrailerWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	trailer     bool
+	roots       []string
+}
+
+func (w *receiptTrailerWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK || status == http.StatusPartialContent {
+			h := w.Header()
+			h.Del("Content-Length")
+			h.Add("Trailer", gatewayReceiptTrailer)
+			w.trailer = true
+			w.roots = splitRoots(h.Get("X-Ipfs-Roots"))
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *receiptTrailerWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *receiptTrailerWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *receiptTrailerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,135 @@
+package corehttp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayReceipts(t *testing.T) {
+	ctx := context.Background()
+	source := newTestBlockstore()
+	local, remote := blocks.NewBlock([]byte("local block")), blocks.NewBlock([]byte("remote block"))
+	require.NoError(t, source.Put(ctx, remote))
+	bstore := newTestBlockstore()
+	require.NoError(t, bstore.Put(ctx, local))
+	bs := newReceiptBlockService(blockservice.New(bstore, newCountingExchange(source)))
+
+	// the content is read like the gateway does, through a session
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipfs/content" {
+			http.NotFound(w, r)
+			return
+		}
+		ses := blockservice.NewSession(r.Context(), bs)
+		for _, blk := range []blocks.Block{local, remote} {
+			_, err := ses.GetBlock(r.Context(), blk.Cid())
+			require.NoError(t, err)
+		}
+		w.Header().Set("X-Ipfs-Roots", local.Cid().String()+","+remote.Cid().String())
+		w.Header().Set("Etag", `"content"`)
+		w.Header().Set("Content-Length", "7")
+		_, _ = w.Write([]byte("content"))
+	})
+
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	cfg := &config.Config{}
+	cfg.Gateway.Receipts.Enabled = config.True
+	receipts, err := newGatewayReceipts(cfg, sk)
+	require.NoError(t, err)
+	handler := withGatewayReceipts(receipts, next)
+	id, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+
+	get := func(target string, header ...string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+	cids := []string{local.Cid().String(), remote.Cid().String()}
+	if cids[0] > cids[1] {
+		cids[0], cids[1] = cids[1], cids[0]
+	}
+
+	t.Run("summarizes the blocks in a trailer", func(t *testing.T) {
+		resp := get("/ipfs/content", "TE", "trailers")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Length"))
+		assert.Equal(t, []string{gatewayReceiptTrailer}, resp.Header.Values("Trailer"))
+		digest := sha256.Sum256([]byte(strings.Join(cids, ",")))
+		assert.Equal(t, "root="+local.Cid().String()+"; blocks=2; digest=sha256:"+hex.EncodeToString(digest[:])+"; cids="+strings.Join(cids, ","), resp.Trailer.Get(gatewayReceiptTrailer))
+
+		receipts.maxTrailerBlocks = 1
+		defer func() { receipts.maxTrailerBlocks = config.DefaultGatewayReceiptsMaxTrailerBlocks }()
+		resp = get("/ipfs/content", "TE", "trailers")
+		assert.NotContains(t, resp.Trailer.Get(gatewayReceiptTrailer), "cids=")
+	})
+
+	t.Run("sends no trailer to the clients not accepting them", func(t *testing.T) {
+		resp := get("/ipfs/content")
+		assert.Equal(t, "7", resp.Header.Get("Content-Length"))
+		assert.Empty(t, resp.Header.Get("Trailer"))
+		assert.Empty(t, resp.Trailer)
+	})
+
+	t.Run("signs the receipt of the response", func(t *testing.T) {
+		resp := get("/ipfs/content?receipt=true")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var signed SignedGatewayReceipt
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&signed))
+		data, err := json.Marshal(signed)
+		require.NoError(t, err)
+
+		receipt, err := VerifyGatewayReceipt(data)
+		require.NoError(t, err)
+		assert.Equal(t, id.String(), receipt.Peer)
+		assert.Equal(t, "/ipfs/content", receipt.Path)
+		assert.Equal(t, http.StatusOK, receipt.Status)
+		assert.Equal(t, []string{local.Cid().String(), remote.Cid().String()}, receipt.Roots)
+		assert.Equal(t, `"content"`, receipt.Etag)
+		assert.Equal(t, int64(7), receipt.Size)
+		digest := sha256.Sum256([]byte("content"))
+		assert.Equal(t, hex.EncodeToString(digest[:]), receipt.SHA256)
+		assert.Equal(t, cids, receipt.Blocks)
+
+		// the receipts altered are refused
+		signed.Payload = []byte(strings.Replace(string(signed.Payload), `"Status":200`, `"Status":404`, 1))
+		data, err = json.Marshal(signed)
+		require.NoError(t, err)
+		_, err = VerifyGatewayReceipt(data)
+		assert.ErrorContains(t, err, "invalid receipt signature")
+	})
+
+	t.Run("reports the status of the requests failed", func(t *testing.T) {
+		resp := get("/ipfs/missing?receipt=true")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var signed SignedGatewayReceipt
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&signed))
+		data, err := json.Marshal(signed)
+		require.NoError(t, err)
+		receipt, err := VerifyGatewayReceipt(data)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, receipt.Status)
+		assert.Empty(t, receipt.Blocks)
+	})
+}
@@ -0,0 +1,92 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/boxo/files"
+	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/boxo/path"
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of the evaluations of the _redirects files other than the status of
+// the rule applied, as reported by the metrics.
+const (
+	gatewayRedirectsNone    = "none"
+	gatewayRedirectsInvalid = "invalid"
+)
+
+var gatewayRedirectRules = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_redirects_rules_total",
+	Help: "Evaluations of the _redirects files by the gateway, by status of the rule applied (such as 200, 301 or 404), none when no rule applied, or invalid when the file could not be parsed.",
+}, []string{"status"})
+
+func init() {
+	prometheus.MustRegister(gatewayRedirectRules)
+}
+
+// redirectsMetricsBackend counts the rules of the _redirects files applied by
+// the gateway, which reads them to serve the paths missing from a DAG. The
+// rules are matched against the requested path again, like the gateway does,
+// and the file read is handed over to the gateway.
+type redirectsMetricsBackend struct {
+	gateway.IPFSBackend
+}
+
+func withGatewayRedirectsMetrics(backend gateway.IPFSBackend) gateway.IPFSBackend {
+	return &redirectsMetricsBackend{IPFSBackend: backend}
+}
+
+func (b *redirectsMetricsBackend) Get(ctx context.Context, p path.ImmutablePath, ranges ...gateway.ByteRange) (gateway.ContentPathMetadata, *gateway.GetResponse, error) {
+	if len(ranges) > 0 || !isRedirectsFile(p) {
+		return b.IPFSBackend.Get(ctx, p, ranges...)
+	}
+	contentPath, ok := ctx.Value(gateway.ContentPathKey).(path.Path)
+	if !ok {
+		return b.IPFSBackend.Get(ctx, p)
+	}
+	_, urlPath, ok := node.GatewayRedirectsPath(contentPath)
+	if !ok {
+		return b.IPFSBackend.Get(ctx, p)
+	}
+
+	md, n, err := b.IPFSBackend.GetAll(ctx, p)
+	if err != nil {
+		return md, nil, err
+	}
+	f, ok := n.(files.File)
+	if !ok {
+		n.Close()
+		return b.IPFSBackend.Get(ctx, p)
+	}
+	defer f.Close()
+	// the gateway refuses the files over MaxFileSizeInBytes, and so do the
+	// metrics
+	data, err := io.ReadAll(io.LimitReader(f, redirects.MaxFileSizeInBytes+1))
+	if err != nil {
+		return md, nil, err
+	}
+
+	status := gatewayRedirectsInvalid
+	if rules, err := redirects.Parse(bytes.NewReader(data)); err == nil {
+		status = gatewayRedirectsNone
+		if r := node.MatchGatewayRedirects(rules, urlPath); r != nil {
+			status = strconv.Itoa(r.Status)
+		}
+	}
+	gatewayRedirectRules.WithLabelValues(status).Inc()
+	return md, gateway.NewGetResponseFromReader(io.NopCloser(bytes.NewReader(data)), int64(len(data))), nil
+}
+
+// isRedirectsFile tells whether p is the _redirects file at the root of a
+// DAG.
+func isRedirectsFile(p path.ImmutablePath) bool {
+	parts := strings.Split(p.String(), "/")
+	return len(parts) == 4 && parts[3] == "_redirects"
+}
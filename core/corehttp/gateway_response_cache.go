@@ -0,0 +1,251 @@
+package corehttp
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ipfs/kubo/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatewayResponseCacheEntryShare bounds the size of a cached response to this
+// fraction of Gateway.ResponseCache.MaxSize, so a large response does not
+// evict all the others.
+const gatewayResponseCacheEntryShare = 8
+
+// Results of the requests, as reported by the metrics.
+const (
+	gatewayResponseCacheHit  = "hit"
+	gatewayResponseCacheMiss = "miss"
+)
+
+var gatewayResponseCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_response_cache_requests_total",
+	Help: "Gateway requests looked up in the response cache, by result (hit or miss).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(gatewayResponseCacheRequests)
+}
+
+// gatewayResponseCache is the LRU cache of Gateway.ResponseCache, bounded by
+// the size of the responses.
+type gatewayResponseCache struct {
+	ttl      time.Duration
+	maxSize  int64
+	maxEntry int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// gatewayCachedResponse is a response of the gateway, with the headers set by
+// the gateway handler.
+type gatewayCachedResponse struct {
+	key    string
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+// newGatewayResponseCache returns the cache of Gateway.ResponseCache, or nil
+// if it is disabled.
+func newGatewayResponseCache(cfg *config.Config) (*gatewayResponseCache, error) {
+	rc := cfg.Gateway.ResponseCache
+	if !rc.Enabled.WithDefault(config.DefaultGatewayResponseCacheEnabled) {
+		return nil, nil
+	}
+	maxSize, err := humanize.ParseBytes(rc.MaxSize.WithDefault(config.DefaultGatewayResponseCacheMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("Gateway.ResponseCache.MaxSize: %w", err)
+	}
+	ttl := rc.TTL.WithDefault(config.DefaultGatewayResponseCacheTTL)
+	if maxSize == 0 || ttl <= 0 {
+		return nil, nil
+	}
+	return &gatewayResponseCache{
+		ttl:      ttl,
+		maxSize:  int64(maxSize),
+		maxEntry: int64(maxSize) / gatewayResponseCacheEntryShare,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}, nil
+}
+
+// get returns the response cached for key, unless it expired.
+func (c *gatewayResponseCache) get(key string, now time.Time) *gatewayCachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	resp := e.Value.(*gatewayCachedResponse)
+	if now.Sub(resp.stored) >= c.ttl {
+		c.remove(e)
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return resp
+}
+
+// add caches resp, evicting the least recently used responses over the size
+// of the cache.
+func (c *gatewayResponseCache) add(resp *gatewayCachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[resp.key]; ok {
+		c.remove(e)
+	}
+	c.entries[resp.key] = c.lru.PushFront(resp)
+	c.size += resp.size()
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *gatewayResponseCache) remove(e *list.Element) {
+	resp := c.lru.Remove(e).(*gatewayCachedResponse)
+	delete(c.entries, resp.key)
+	c.size -= resp.size()
+}
+
+func (r *gatewayCachedResponse) size() int64 {
+	n := int64(len(r.key) + len(r.body))
+	for k, vs := range r.header {
+		for _, v := range vs {
+			n += int64(len(k) + len(v))
+		}
+	}
+	return n
+}
+
+// gatewayResponseCacheKey returns the key of the responses to r: the
+// requests with the same key are answered with the same response.
+func gatewayResponseCacheKey(r *http.Request) string {
+	return strings.Join([]string{
+		r.Host,
+		r.URL.RequestURI(),
+		r.Header.Get("Accept"),
+		r.Header.Get("X-Forwarded-Host"),
+		r.Header.Get("X-Forwarded-Proto"),
+	}, "\n")
+}
+
+// withGatewayResponseCache serves the GET and HEAD requests from the responses
+// cached by c, including their Range and conditional requests. The complete
+// 200 responses to the other GET requests are cached.
+func withGatewayResponseCache(c *gatewayResponseCache, next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		key := gatewayResponseCacheKey(r)
+		if resp := c.get(key, now); resp != nil {
+			gatewayResponseCacheRequests.WithLabelValues(gatewayResponseCacheHit).Inc()
+			for k, vs := range resp.header {
+				w.Header()[k] = vs
+			}
+			w.Header().Set("Age", strconv.Itoa(int(now.Sub(resp.stored).Seconds())))
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(resp.body))
+			return
+		}
+		gatewayResponseCacheRequests.WithLabelValues(gatewayResponseCacheMiss).Inc()
+
+		if r.Method != http.MethodGet || r.Header.Get("Range") != "" ||
+			r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &responseCacheWriter{ResponseWriter: w, outer: w.Header().Clone(), limit: c.maxEntry}
+		next.ServeHTTP(cw, r)
+		if resp := cw.response(key, now); resp != nil {
+			c.add(resp)
+		}
+	})
+}
+
+// responseCacheWriter keeps a copy of a response, unless it exceeds limit.
+type responseCacheWriter struct {
+	http.ResponseWriter
+	// outer are the headers set before the gateway handler, which are set
+	// again when a cached response is served.
+	outer  http.Header
+	header http.Header
+	status int
+	body   bytes.Buffer
+	limit  int64
+	over   bool
+}
+
+func (w *responseCacheWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseCacheWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.over {
+		if int64(w.body.Len()+len(p)) > w.limit {
+			w.over = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// response returns the response to cache, or nil if it is not cacheable.
+func (w *responseCacheWriter) response(key string, now time.Time) *gatewayCachedResponse {
+	if w.status != http.StatusOK || w.over {
+		return nil
+	}
+	cacheControl := w.header.Get("Cache-Control")
+	if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+		return nil
+	}
+	if n, err := strconv.Atoi(w.header.Get("Content-Length")); err == nil && n != w.body.Len() {
+		// the response was cut short
+		return nil
+	}
+
+	header := make(http.Header, len(w.header))
+	for k, vs := range w.header {
+		if k == "Content-Length" || strings.Join(w.outer[k], ",") == strings.Join(vs, ",") {
+			continue
+		}
+		header[k] = vs
+	}
+	return &gatewayCachedResponse{key: key, header: header, body: bytes.Clone(w.body.Bytes()), stored: now}
+}
+
+func (w *responseCacheWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseCacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
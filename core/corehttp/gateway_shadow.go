@@ -0,0 +1,215 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of the mirrored requests, as reported by the metrics.
+const (
+	gatewayShadowMatch          = "match"
+	gatewayShadowStatusMismatch = "status_mismatch"
+	gatewayShadowRootsMismatch  = "roots_mismatch"
+	gatewayShadowError          = "error"
+	gatewayShadowDropped        = "dropped"
+)
+
+var gatewayShadowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_shadow_requests_total",
+	Help: "Gateway requests mirrored to Gateway.Shadow.URL, by result (match, status_mismatch, roots_mismatch, error or dropped).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(gatewayShadowRequests)
+}
+
+// shadowedHeaders are the request headers sent to the secondary gateway,
+// which select the response.
+var shadowedHeaders = []string{"Accept", "Range"}
+
+// gatewayShadow mirrors the requests of Gateway.Shadow.
+type gatewayShadow struct {
+	base    *url.URL
+	percent int
+	client  *http.Client
+	slots   chan struct{}
+}
+
+// gatewayShadowResult is what is compared of a response: its status and the
+// CIDs of the path it resolved.
+type gatewayShadowResult struct {
+	status int
+	roots  string
+	err    error
+}
+
+func newGatewayShadow(cfg *config.Config) (*gatewayShadow, error) {
+	s := cfg.Gateway.Shadow
+	if s == nil {
+		return nil, nil
+	}
+	base, err := url.Parse(s.URL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("Gateway.Shadow.URL: invalid URL %q", s.URL)
+	}
+	percent := s.Percent.WithDefault(config.DefaultGatewayShadowPercent)
+	if percent < 0 || percent > 100 {
+		return nil, fmt.Errorf("Gateway.Shadow.Percent must be between 0 and 100")
+	}
+	maxConcurrent := s.MaxConcurrent.WithDefault(config.DefaultGatewayShadowMaxConcurrent)
+	if maxConcurrent <= 0 {
+		return nil, fmt.Errorf("Gateway.Shadow.MaxConcurrent must be positive")
+	}
+	return &gatewayShadow{
+		base:    base,
+		percent: int(percent),
+		client: &http.Client{
+			Timeout: s.Timeout.WithDefault(config.DefaultGatewayShadowTimeout),
+			// the redirects are compared, not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		slots: make(chan struct{}, maxConcurrent),
+	}, nil
+}
+
+// withGatewayShadow mirrors the sampled GET and HEAD requests to the
+// secondary gateway, while they are served, and compares the responses once
+// both are known. The client of the gateway does not wait for the secondary
+// gateway.
+func withGatewayShadow(s *gatewayShadow, next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || rand.Intn(100) >= s.percent {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			gatewayShadowRequests.WithLabelValues(gatewayShadowDropped).Inc()
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the request is not used once served
+		target := r.Host + r.RequestURI
+		req, err := s.mirroredRequest(r)
+		primary := make(chan gatewayShadowResult, 1)
+		go func() {
+			defer func() { <-s.slots }()
+			shadow := gatewayShadowResult{err: err}
+			if err == nil {
+				shadow = s.do(req)
+			}
+			s.compare(target, <-primary, shadow)
+		}()
+
+		sw := &shadowWriter{ResponseWriter: w}
+		defer func() {
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			primary <- gatewayShadowResult{status: sw.status, roots: sw.roots}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// mirroredRequest returns the request of r to the secondary gateway, with
+// the same host, so that the subdomain and DNSLink requests are served
+// alike. Its URI is the one of the client, before the subdomain gateway
+// rewrote the path.
+func (s *gatewayShadow) mirroredRequest(r *http.Request) (*http.Request, error) {
+	u := *s.base
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	path, query, _ := strings.Cut(uri, "?")
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = ""
+	u.RawQuery = query
+
+	req, err := http.NewRequestWithContext(context.Background(), r.Method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = r.Host
+	for _, h := range shadowedHeaders {
+		if v := r.Header.Values(h); len(v) > 0 {
+			req.Header[h] = slices.Clone(v)
+		}
+	}
+	return req, nil
+}
+
+func (s *gatewayShadow) do(req *http.Request) gatewayShadowResult {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return gatewayShadowResult{err: err}
+	}
+	// the body is not compared
+	resp.Body.Close()
+	return gatewayShadowResult{status: resp.StatusCode, roots: resp.Header.Get("X-Ipfs-Roots")}
+}
+
+func (s *gatewayShadow) compare(target string, primary, shadow gatewayShadowResult) {
+	result := gatewayShadowMatch
+	switch {
+	case shadow.err != nil:
+		result = gatewayShadowError
+		log.Debugf("gateway shadow of %s: %s", target, shadow.err)
+	case primary.status != shadow.status:
+		result = gatewayShadowStatusMismatch
+		log.Warnf("gateway shadow of %s: status %d, %d on %s", target, primary.status, shadow.status, s.base.Host)
+	// the implementations which do not send the roots are not compared
+	case primary.roots != "" && shadow.roots != "" && primary.roots != shadow.roots:
+		result = gatewayShadowRootsMismatch
+		log.Warnf("gateway shadow of %s: roots %s, %s on %s", target, primary.roots, shadow.roots, s.base.Host)
+	}
+	gatewayShadowRequests.WithLabelValues(result).Inc()
+}
+
+// shadowWriter records the status and the roots of a response.
+type shadowWriter struct {
+	http.ResponseWriter
+	status int
+	roots  string
+}
+
+func (w *shadowWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.roots = w.Header().Get("X-Ipfs-Roots")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *shadowWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *shadowWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *shadowWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,251 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Errors returned by a GatewayTransformer, answered with 400 Bad Request and
+// 422 Unprocessable Entity.
+var (
+	ErrGatewayTransformInvalid       = errors.New("invalid transformation")
+	ErrGatewayTransformUnprocessable = errors.New("content cannot be transformed")
+)
+
+// GatewayTransformer transforms the deserialized responses of the gateway, as
+// requested by query parameters, such as the images resized with ?w=200.
+type GatewayTransformer interface {
+	// Params are the query parameters of the transformation. The requests
+	// with one of them are transformed.
+	Params() []string
+	// ContentTypes are the types of the content transformed, as sniffed by
+	// http.DetectContentType. The other content is refused with 415
+	// Unsupported Media Type.
+	ContentTypes() []string
+	// Transform returns the content transformed as requested by the
+	// parameters, and its content type.
+	Transform(ctx context.Context, params url.Values, content []byte) ([]byte, string, error)
+}
+
+// Results of the transformations, as reported by the metrics.
+const (
+	gatewayTransformOK            = "ok"
+	gatewayTransformInvalid       = "invalid"
+	gatewayTransformUnsupported   = "unsupported"
+	gatewayTransformUnprocessable = "unprocessable"
+	gatewayTransformBusy          = "busy"
+	gatewayTransformFailed        = "failed"
+)
+
+var gatewayTransformResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_http_gw_transforms_total",
+	Help: "Responses of the gateway transformed with Gateway.Transform, by result (ok, invalid, unsupported, unprocessable, busy or failed).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(gatewayTransformResults)
+}
+
+// GatewayTransformOption transforms the responses of the gateway options
+// that follow it with transformers, such as the one of NewImageTransformer,
+// when Gateway.Transform is enabled. Like HostnameOption, it handles the
+// requests before the options that follow, and should follow HostnameOption
+// to transform the content of the subdomain gateways too.
+func GatewayTransformOption(transformers ...GatewayTransformer) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		t, err := newGatewayTransforms(cfg.Gateway.Transform, transformers)
+		if err != nil || t == nil {
+			return mux, err
+		}
+		childMux := http.NewServeMux()
+		mux.Handle("/", withGatewayTransforms(t, childMux))
+		return childMux, nil
+	}
+}
+
+type gatewayTransforms struct {
+	transformers []GatewayTransformer
+	maxInputSize int
+	slots        chan struct{}
+}
+
+func newGatewayTransforms(cfg config.GatewayTransform, transformers []GatewayTransformer) (*gatewayTransforms, error) {
+	if !cfg.Enabled.WithDefault(config.DefaultGatewayTransformEnabled) || len(transformers) == 0 {
+		return nil, nil
+	}
+	maxInputSize, err := humanize.ParseBytes(cfg.MaxInputSize.WithDefault(config.DefaultGatewayTransformMaxInputSize))
+	if err != nil {
+		return nil, fmt.Errorf("Gateway.Transform.MaxInputSize: %w", err)
+	}
+	maxConcurrent := cfg.MaxConcurrent.WithDefault(config.DefaultGatewayTransformMaxConcurrent)
+	if maxInputSize == 0 || maxConcurrent <= 0 {
+		return nil, fmt.Errorf("Gateway.Transform.MaxInputSize and Gateway.Transform.MaxConcurrent must be positive")
+	}
+	return &gatewayTransforms{
+		transformers: transformers,
+		maxInputSize: int(maxInputSize),
+		slots:        make(chan struct{}, maxConcurrent),
+	}, nil
+}
+
+// transformerOf returns the transformer of r, and its parameters, or nil
+// when r is not transformed.
+func (t *gatewayTransforms) transformerOf(r *http.Request) (GatewayTransformer, url.Values) {
+	if r.Method != http.MethodGet || !(strings.HasPrefix(r.URL.Path, "/ipfs/") || strings.HasPrefix(r.URL.Path, "/ipns/")) {
+		return nil, nil
+	}
+	query := r.URL.Query()
+	for _, tr := range t.transformers {
+		params := url.Values{}
+		for _, p := range tr.Params() {
+			if v, ok := query[p]; ok {
+				params[p] = v
+			}
+		}
+		if len(params) > 0 {
+			return tr, params
+		}
+	}
+	return nil, nil
+}
+
+// withGatewayTransforms serves the requests with the parameters of a
+// transformer with the content of the gateway, transformed. The whole content
+// is transformed, the Range of the request is ignored.
+func withGatewayTransforms(t *gatewayTransforms, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tr, params := t.transformerOf(r)
+		if tr == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case t.slots <- struct{}{}:
+			defer func() { <-t.slots }()
+		default:
+			gatewayTransformResults.WithLabelValues(gatewayTransformBusy).Inc()
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many transformations in progress", http.StatusServiceUnavailable)
+			return
+		}
+
+		// the full content is transformed, and the transformed response is
+		// validated against its own ETag below
+		ifNoneMatch := r.Header.Get("If-None-Match")
+		r = r.Clone(r.Context())
+		r.Header.Del("Range")
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+
+		tw := &transformWriter{header: http.Header{}, status: http.StatusOK, limit: t.maxInputSize}
+		next.ServeHTTP(tw, r)
+		if tw.status != http.StatusOK {
+			// errors, redirects and the like are served as they are
+			copyHeader(w.Header(), tw.header)
+			w.WriteHeader(tw.status)
+			_, _ = w.Write(tw.body.Bytes())
+			return
+		}
+		if tw.tooLarge {
+			gatewayTransformResults.WithLabelValues(gatewayTransformUnprocessable).Inc()
+			http.Error(w, fmt.Sprintf("%s: over %s", ErrGatewayTransformUnprocessable, humanize.IBytes(uint64(t.maxInputSize))), http.StatusUnprocessableEntity)
+			return
+		}
+		content := tw.body.Bytes()
+		if sniffed := http.DetectContentType(content); !slices.Contains(tr.ContentTypes(), sniffed) {
+			gatewayTransformResults.WithLabelValues(gatewayTransformUnsupported).Inc()
+			http.Error(w, fmt.Sprintf("cannot transform content of type %s", sniffed), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		out, contentType, err := tr.Transform(r.Context(), params, content)
+		if err != nil {
+			status, result := http.StatusInternalServerError, gatewayTransformFailed
+			switch {
+			case errors.Is(err, ErrGatewayTransformInvalid):
+				status, result = http.StatusBadRequest, gatewayTransformInvalid
+			case errors.Is(err, ErrGatewayTransformUnprocessable):
+				status, result = http.StatusUnprocessableEntity, gatewayTransformUnprocessable
+			}
+			gatewayTransformResults.WithLabelValues(result).Inc()
+			http.Error(w, err.Error(), status)
+			return
+		}
+		gatewayTransformResults.WithLabelValues(gatewayTransformOK).Inc()
+
+		h := w.Header()
+		copyHeader(h, tw.header)
+		for _, k := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "Content-Disposition", "Etag"} {
+			h.Del(k)
+		}
+		if etag := tw.header.Get("Etag"); etag != "" {
+			// the transformations are deterministic, so the transformed
+			// content is as immutable as the content
+			etag = `"` + strings.Trim(strings.TrimPrefix(etag, "W/"), `"`) + "." + params.Encode() + `"`
+			h.Set("Etag", etag)
+			if ifNoneMatch == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Length", strconv.Itoa(len(out)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(out)
+	})
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// transformWriter keeps the response of the gateway to transform it, up to
+// limit bytes, over which the response is aborted.
+type transformWriter struct {
+	header   http.Header
+	status   int
+	written  bool
+	body     bytes.Buffer
+	limit    int
+	tooLarge bool
+}
+
+func (w *transformWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *transformWriter) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	w.written = true
+	w.status = status
+}
+
+func (w *transformWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.body.Len()+len(p) > w.limit {
+		w.tooLarge = true
+		return 0, ErrGatewayTransformUnprocessable
+	}
+	return w.body.Write(p)
+}
@@ -0,0 +1,177 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strconv"
+
+	"github.com/ipfs/kubo/config"
+)
+
+const defaultImageQuality = 85
+
+// imageTransformer resizes and converts the PNG, JPEG and GIF images with
+// the w (width), h (height), fm (format) and q (JPEG quality) parameters.
+// The images keep their aspect ratio, within w and h, and are never enlarged.
+type imageTransformer struct {
+	maxPixels    int64
+	maxDimension int
+}
+
+// NewImageTransformer returns the image transformer of Gateway.Transform,
+// added to the gateway with GatewayTransformOption.
+func NewImageTransformer(cfg config.GatewayTransform) (GatewayTransformer, error) {
+	maxPixels := cfg.MaxPixels.WithDefault(config.DefaultGatewayTransformMaxPixels)
+	maxDimension := cfg.MaxDimension.WithDefault(config.DefaultGatewayTransformMaxDimension)
+	if maxPixels <= 0 || maxDimension <= 0 {
+		return nil, fmt.Errorf("Gateway.Transform.MaxPixels and Gateway.Transform.MaxDimension must be positive")
+	}
+	return &imageTransformer{maxPixels: maxPixels, maxDimension: int(maxDimension)}, nil
+}
+
+func (t *imageTransformer) Params() []string {
+	return []string{"w", "h", "fm", "q"}
+}
+
+func (t *imageTransformer) ContentTypes() []string {
+	return []string{"image/png", "image/jpeg", "image/gif"}
+}
+
+func (t *imageTransformer) Transform(ctx context.Context, params url.Values, content []byte) ([]byte, string, error) {
+	width, err := t.dimension(params, "w")
+	if err != nil {
+		return nil, "", err
+	}
+	height, err := t.dimension(params, "h")
+	if err != nil {
+		return nil, "", err
+	}
+	format := params.Get("fm")
+	switch format {
+	case "", "png", "jpeg", "gif":
+	case "jpg":
+		format = "jpeg"
+	default:
+		return nil, "", fmt.Errorf("%w: fm must be png, jpeg or gif", ErrGatewayTransformInvalid)
+	}
+	quality := defaultImageQuality
+	if q := params.Get("q"); q != "" {
+		if quality, err = strconv.Atoi(q); err != nil || quality < 1 || quality > 100 {
+			return nil, "", fmt.Errorf("%w: q must be between 1 and 100", ErrGatewayTransformInvalid)
+		}
+	}
+
+	// the size is checked before the image is decoded, as small images can
+	// declare huge dimensions
+	ic, srcFormat, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrGatewayTransformUnprocessable, err)
+	}
+	if int64(ic.Width)*int64(ic.Height) > t.maxPixels {
+		return nil, "", fmt.Errorf("%w: the image has more than %d pixels", ErrGatewayTransformUnprocessable, t.maxPixels)
+	}
+	if format == "" {
+		format = srcFormat
+	}
+	w, h := fitImage(ic.Width, ic.Height, width, height)
+	if format == srcFormat && w == ic.Width && h == ic.Height && !params.Has("q") {
+		// the image is already as requested
+		return content, "image/" + format, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrGatewayTransformUnprocessable, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if w != img.Bounds().Dx() || h != img.Bounds().Dy() {
+		img = resizeImage(img, w, h)
+	}
+
+	var out bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&out, img)
+	case "jpeg":
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		err = gif.Encode(&out, img, nil)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), "image/" + format, nil
+}
+
+// dimension returns the width or height parameter, 0 when it is not set.
+func (t *imageTransformer) dimension(params url.Values, name string) (int, error) {
+	v := params.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := strconv.Atoi(v)
+	if err != nil || d < 1 || d > t.maxDimension {
+		return 0, fmt.Errorf("%w: %s must be between 1 and %d", ErrGatewayTransformInvalid, name, t.maxDimension)
+	}
+	return d, nil
+}
+
+// fitImage returns the size of an image of srcW x srcH scaled to fit within
+// width x height, either of them 0 when not bounded, without enlarging it.
+func fitImage(srcW, srcH, width, height int) (int, int) {
+	scale := 1.0
+	if width > 0 && width < srcW {
+		scale = float64(width) / float64(srcW)
+	}
+	if height > 0 && height < srcH {
+		scale = min(scale, float64(height)/float64(srcH))
+	}
+	if scale == 1 {
+		return srcW, srcH
+	}
+	return max(int(float64(srcW)*scale+0.5), 1), max(int(float64(srcH)*scale+0.5), 1)
+}
+
+// resizeImage scales src down to w x h, averaging the source pixels covered
+// by each pixel.
+func resizeImage(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max((y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := max((x+1)*sw/w, x0+1)
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += uint64(rgba.Pix[i+c])
+					}
+					i += 4
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			o := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
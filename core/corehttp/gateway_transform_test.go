@@ -0,0 +1,126 @@
+package corehttp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestGatewayTransforms(t *testing.T) {
+	files := map[string][]byte{
+		"/ipfs/image.png": testPNG(t, 200, 100),
+		"/ipfs/huge.png":  testPNG(t, 1000, 1000),
+		"/ipfs/text.txt":  []byte("not an image"),
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Etag", `"`+r.URL.Path+`"`)
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	})
+
+	images, err := NewImageTransformer(config.GatewayTransform{MaxPixels: config.NewOptionalInteger(500_000), MaxDimension: config.NewOptionalInteger(1000)})
+	require.NoError(t, err)
+	tr, err := newGatewayTransforms(config.GatewayTransform{
+		Enabled:       config.True,
+		MaxInputSize:  config.NewOptionalString("1MiB"),
+		MaxConcurrent: config.NewOptionalInteger(1),
+	}, []GatewayTransformer{images})
+	require.NoError(t, err)
+	handler := withGatewayTransforms(tr, next)
+
+	get := func(target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("resizes and converts the images", func(t *testing.T) {
+		rec := get("/ipfs/image.png?w=50&fm=jpeg", "Range", "bytes=0-10")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "public, max-age=29030400, immutable", rec.Header().Get("Cache-Control"))
+		ic, format, err := image.DecodeConfig(rec.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 50, ic.Width)
+		assert.Equal(t, 25, ic.Height)
+
+		etag := rec.Header().Get("Etag")
+		assert.Equal(t, `"/ipfs/image.png.fm=jpeg&w=50"`, etag)
+		rec = get("/ipfs/image.png?w=50&fm=jpeg", "If-None-Match", etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+	})
+
+	t.Run("keeps the aspect ratio and never enlarges", func(t *testing.T) {
+		rec := get("/ipfs/image.png?w=100&h=10")
+		require.Equal(t, http.StatusOK, rec.Code)
+		ic, err := png.DecodeConfig(rec.Body)
+		require.NoError(t, err)
+		assert.Equal(t, image.Config{ColorModel: ic.ColorModel, Width: 20, Height: 10}, ic)
+
+		rec = get("/ipfs/image.png?w=400")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, files["/ipfs/image.png"], rec.Body.Bytes())
+	})
+
+	t.Run("serves the other requests as they are", func(t *testing.T) {
+		rec := get("/ipfs/text.txt")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "not an image", rec.Body.String())
+		rec = get("/ipfs/missing.png?w=10")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("refuses what it cannot transform", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, get("/ipfs/text.txt?w=10").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/ipfs/image.png?w=0").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/ipfs/image.png?w=1001").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/ipfs/image.png?fm=webp").Code)
+		rec := get("/ipfs/huge.png?w=10")
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "more than 500000 pixels")
+	})
+
+	t.Run("bounds the input and the concurrent transformations", func(t *testing.T) {
+		files["/ipfs/large.png"] = append(testPNG(t, 10, 10), make([]byte, 2<<20)...)
+		rec := get("/ipfs/large.png?w=5")
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "over 1.0 MiB")
+
+		tr.slots <- struct{}{}
+		defer func() { <-tr.slots }()
+		rec = get("/ipfs/image.png?w=5")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	})
+}
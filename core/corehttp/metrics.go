@@ -6,6 +6,7 @@ import (
 	"time"
 
 	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/node"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/zpages"
 
@@ -158,12 +159,98 @@ var peersTotalMetric = prometheus.NewDesc(
 	nil,
 )
 
+var (
+	bitswapPeerSentBytesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "bitswap", "peer_sent_bytes_total"),
+		"Bytes of Bitswap responses sent to a peer, for the peers selected by Bitswap.PeerMetricsTopN",
+		[]string{"peer"},
+		nil,
+	)
+	bitswapPeerRecvBytesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "bitswap", "peer_received_bytes_total"),
+		"Bytes of Bitswap messages received from a peer, for the peers selected by Bitswap.PeerMetricsTopN",
+		[]string{"peer"},
+		nil,
+	)
+	bitswapPeerSentBlocksMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "bitswap", "peer_sent_blocks_total"),
+		"Blocks sent to a peer over Bitswap, for the peers selected by Bitswap.PeerMetricsTopN",
+		[]string{"peer"},
+		nil,
+	)
+	bitswapPeerRecvBlocksMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "bitswap", "peer_received_blocks_total"),
+		"Blocks received from a peer over Bitswap, for the peers selected by Bitswap.PeerMetricsTopN",
+		[]string{"peer"},
+		nil,
+	)
+	bitswapSessionsActiveMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "bitswap", "sessions_active"),
+		"Number of Bitswap sessions that have not ended",
+		nil,
+		nil,
+	)
+	bitswapSessionsWantedMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "bitswap", "sessions_wanted_blocks"),
+		"Blocks requested by the active Bitswap sessions and not received yet",
+		nil,
+		nil,
+	)
+	bitswapSessionOldestAgeMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "bitswap", "session_oldest_age_seconds"),
+		"Age of the oldest active Bitswap session",
+		nil,
+		nil,
+	)
+)
+
+var (
+	retrievalProbeLatencyMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "probes", "retrieval_latency_seconds"),
+		"Time from publishing a probe block to retrieving it from a gateway of Probes.Retrieval.Gateways",
+		[]string{"gateway"},
+		nil,
+	)
+	retrievalProbeFailuresMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "probes", "retrieval_failures_total"),
+		"Probe blocks not retrieved from a gateway of Probes.Retrieval.Gateways within Probes.Retrieval.Timeout",
+		[]string{"gateway"},
+		nil,
+	)
+)
+
+var (
+	rpcCommandDurationMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "rpc", "command_duration_seconds"),
+		"Latency of the calls of an RPC command",
+		[]string{"command"},
+		nil,
+	)
+	rpcCommandErrorsMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "rpc", "command_errors_total"),
+		"Calls of an RPC command that failed",
+		[]string{"command"},
+		nil,
+	)
+)
+
 type IpfsNodeCollector struct {
 	Node *core.IpfsNode
 }
 
 func (IpfsNodeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- peersTotalMetric
+	ch <- bitswapPeerSentBytesMetric
+	ch <- bitswapPeerRecvBytesMetric
+	ch <- bitswapPeerSentBlocksMetric
+	ch <- bitswapPeerRecvBlocksMetric
+	ch <- bitswapSessionsActiveMetric
+	ch <- bitswapSessionsWantedMetric
+	ch <- bitswapSessionOldestAgeMetric
+	ch <- retrievalProbeLatencyMetric
+	ch <- retrievalProbeFailuresMetric
+	ch <- rpcCommandDurationMetric
+	ch <- rpcCommandErrorsMetric
 }
 
 func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
@@ -175,6 +262,53 @@ func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
 			tr,
 		)
 	}
+
+	if c.Node.BitswapPeers != nil {
+		for _, t := range c.Node.BitswapPeers.TopPeers() {
+			p := t.Peer.String()
+			ch <- prometheus.MustNewConstMetric(bitswapPeerSentBytesMetric, prometheus.CounterValue, float64(t.SentBytes), p)
+			ch <- prometheus.MustNewConstMetric(bitswapPeerRecvBytesMetric, prometheus.CounterValue, float64(t.RecvBytes), p)
+			ch <- prometheus.MustNewConstMetric(bitswapPeerSentBlocksMetric, prometheus.CounterValue, float64(t.SentBlocks), p)
+			ch <- prometheus.MustNewConstMetric(bitswapPeerRecvBlocksMetric, prometheus.CounterValue, float64(t.RecvBlocks), p)
+		}
+	}
+
+	if c.Node.BitswapStats != nil {
+		sessions := c.Node.BitswapStats.ActiveSessions()
+		var wanted int
+		var oldest time.Duration
+		for _, s := range sessions {
+			wanted += s.Wanted
+			if age := time.Since(s.Started); age > oldest {
+				oldest = age
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(bitswapSessionsActiveMetric, prometheus.GaugeValue, float64(len(sessions)))
+		ch <- prometheus.MustNewConstMetric(bitswapSessionsWantedMetric, prometheus.GaugeValue, float64(wanted))
+		ch <- prometheus.MustNewConstMetric(bitswapSessionOldestAgeMetric, prometheus.GaugeValue, oldest.Seconds())
+	}
+
+	if c.Node.RetrievalProber != nil {
+		for _, s := range c.Node.RetrievalProber.Status() {
+			buckets := make(map[float64]uint64, len(node.RetrievalProbeBuckets))
+			for i, bound := range node.RetrievalProbeBuckets {
+				buckets[bound] = s.LatencyBuckets[i]
+			}
+			ch <- prometheus.MustNewConstHistogram(retrievalProbeLatencyMetric, s.Probes-s.Failures, s.LatencySum.Seconds(), buckets, s.Gateway)
+			ch <- prometheus.MustNewConstMetric(retrievalProbeFailuresMetric, prometheus.CounterValue, float64(s.Failures), s.Gateway)
+		}
+	}
+
+	if c.Node.RPCStats != nil {
+		for _, s := range c.Node.RPCStats.Stats() {
+			buckets := make(map[float64]uint64, len(node.RPCCommandBuckets))
+			for i, bound := range node.RPCCommandBuckets {
+				buckets[bound] = s.LatencyBuckets[i]
+			}
+			ch <- prometheus.MustNewConstHistogram(rpcCommandDurationMetric, s.Calls, s.LatencySum.Seconds(), buckets, s.Command)
+			ch <- prometheus.MustNewConstMetric(rpcCommandErrorsMetric, prometheus.CounterValue, float64(s.Errors), s.Command)
+		}
+	}
 }
 
 func (c IpfsNodeCollector) PeersTotalValues() map[string]float64 {
@@ -74,3 +74,29 @@ func TestCheckVersionOption(t *testing.T) {
 		}
 	}
 }
+
+func TestAllowedPathsOption(t *testing.T) {
+	root := http.NewServeMux()
+	mux, err := AllowedPathsOption("user", []string{APIPath + "/pin", APIPath + "/files/"})(nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	for path, code := range map[string]int{
+		APIPath + "/pin":        http.StatusOK,
+		APIPath + "/pin/ls":     http.StatusOK,
+		APIPath + "/files":      http.StatusOK,
+		APIPath + "/files/ls":   http.StatusOK,
+		APIPath + "/version":    http.StatusOK,
+		APIPath + "/ping":       http.StatusForbidden,
+		APIPath + "/filesystem": http.StatusForbidden,
+		"/webui":                http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		root.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != code {
+			t.Errorf("%s: expected code %d but got %d", path, code, w.Code)
+		}
+	}
+}
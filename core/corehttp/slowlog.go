@@ -0,0 +1,27 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/kubo/core"
+)
+
+// SlowLogOption records the requests taking longer than the threshold of
+// kind, node.SlowOpAPI or node.SlowOpGateway, in the slow log of the node,
+// when Logging.SlowLog is enabled.
+func SlowLogOption(kind string) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		if n.SlowLog == nil {
+			return mux, nil
+		}
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			ctx, op := n.SlowLog.Begin(r.Context(), kind, r.Method+" "+r.URL.Path)
+			defer op.Release()
+			childMux.ServeHTTP(w, r.WithContext(ctx))
+			op.End(r.Context().Err())
+		})
+		return childMux, nil
+	}
+}
@@ -1,7 +1,13 @@
 package iface
 
 import (
+	"context"
+	"io"
+
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/core/coreiface/options"
 )
 
 // APIDagService extends ipld.DAGService
@@ -10,4 +16,28 @@ type APIDagService interface {
 
 	// Pinning returns special NodeAdder which recursively pins added nodes
 	Pinning() ipld.NodeAdder
+
+	// Export returns the DAG rooted at the given path as a CARv1 stream
+	Export(ctx context.Context, root path.Path, opts ...options.DagExportOption) (io.ReadCloser, error)
+
+	// Import adds the blocks of the CAR stream read from r and pins the roots
+	// of its header
+	Import(ctx context.Context, r io.Reader, opts ...options.DagImportOption) (DagImportResult, error)
+}
+
+// DagImportRoot is a root listed in the header of an imported CAR
+type DagImportRoot struct {
+	Cid cid.Cid
+
+	// PinErrorMsg is set when the root was to be pinned but could not be
+	PinErrorMsg string
+}
+
+// DagImportResult describes the blocks and roots of an imported CAR
+type DagImportResult struct {
+	// Roots are the roots of the header, pinned or not
+	Roots []DagImportRoot
+
+	BlockCount      uint64
+	BlockBytesCount uint64
 }
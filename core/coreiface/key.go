@@ -41,6 +41,14 @@ type KeyAPI interface {
 	// Remove removes keys from keystore. Returns ipns path of the removed key
 	Remove(ctx context.Context, name string) (Key, error)
 
+	// Import stores the private key in data under the specified name and
+	// returns the imported key
+	Import(ctx context.Context, name string, data []byte, opts ...options.KeyImportOption) (Key, error)
+
+	// Export returns the private key stored under the specified name, in the
+	// format specified with options.Key.ExportFormat
+	Export(ctx context.Context, name string, opts ...options.KeyExportOption) ([]byte, error)
+
 	// Sign signs the given data with the key named name. Returns the key used
 	// for signing, the signature, and an error.
 	Sign(ctx context.Context, name string, data []byte) (Key, []byte, error)
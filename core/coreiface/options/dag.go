@@ -0,0 +1,90 @@
+package options
+
+import (
+	"context"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+)
+
+// DagImportSettings represent the settings for APIDagService.Import
+type DagImportSettings struct {
+	PinRoots bool
+	OnBlock  func(context.Context, blocks.Block) error
+}
+
+// DagImportOption is the signature of an option for APIDagService.Import
+type DagImportOption func(*DagImportSettings) error
+
+// DagImportOptions compile a series of DagImportOption into a ready to use
+// DagImportSettings and set the default values.
+func DagImportOptions(opts ...DagImportOption) (*DagImportSettings, error) {
+	options := &DagImportSettings{
+		PinRoots: true,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// DagExportSettings represent the settings for APIDagService.Export
+type DagExportSettings struct {
+	Selector datamodel.Node
+}
+
+// DagExportOption is the signature of an option for APIDagService.Export
+type DagExportOption func(*DagExportSettings) error
+
+// DagExportOptions compile a series of DagExportOption into a ready to use
+// DagExportSettings and set the default values.
+func DagExportOptions(opts ...DagExportOption) (*DagExportSettings, error) {
+	options := &DagExportSettings{
+		Selector: selectorparse.CommonSelector_ExploreAllRecursively,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type dagOpts struct{}
+
+var Dag dagOpts
+
+// PinRoots is an option for Dag.Import which specifies whether to pin the
+// roots listed in the CAR header. Default: true
+func (dagOpts) PinRoots(pin bool) DagImportOption {
+	return func(settings *DagImportSettings) error {
+		settings.PinRoots = pin
+		return nil
+	}
+}
+
+// OnBlock is an option for Dag.Import which sets a function called with each
+// block of the CAR before it is added. An error aborts the import. Default:
+// none
+func (dagOpts) OnBlock(fn func(context.Context, blocks.Block) error) DagImportOption {
+	return func(settings *DagImportSettings) error {
+		settings.OnBlock = fn
+		return nil
+	}
+}
+
+// Selector is an option for Dag.Export which specifies the IPLD selector of
+// the blocks exported below the root. Default: the whole DAG
+func (dagOpts) Selector(selector datamodel.Node) DagExportOption {
+	return func(settings *DagExportSettings) error {
+		settings.Selector = selector
+		return nil
+	}
+}
@@ -5,6 +5,9 @@ const (
 	Ed25519Key = "ed25519"
 
 	DefaultRSALen = 2048
+
+	KeyFormatLibp2pCleartext = "libp2p-protobuf-cleartext"
+	KeyFormatPemCleartext    = "pem-pkcs8-cleartext"
 )
 
 type KeyGenerateSettings struct {
@@ -16,9 +19,20 @@ type KeyRenameSettings struct {
 	Force bool
 }
 
+type KeyImportSettings struct {
+	Format       string
+	AllowAnyType bool
+}
+
+type KeyExportSettings struct {
+	Format string
+}
+
 type (
 	KeyGenerateOption func(*KeyGenerateSettings) error
 	KeyRenameOption   func(*KeyRenameSettings) error
+	KeyImportOption   func(*KeyImportSettings) error
+	KeyExportOption   func(*KeyExportSettings) error
 )
 
 func KeyGenerateOptions(opts ...KeyGenerateOption) (*KeyGenerateSettings, error) {
@@ -50,6 +64,35 @@ func KeyRenameOptions(opts ...KeyRenameOption) (*KeyRenameSettings, error) {
 	return options, nil
 }
 
+func KeyImportOptions(opts ...KeyImportOption) (*KeyImportSettings, error) {
+	options := &KeyImportSettings{
+		Format:       KeyFormatLibp2pCleartext,
+		AllowAnyType: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func KeyExportOptions(opts ...KeyExportOption) (*KeyExportSettings, error) {
+	options := &KeyExportSettings{
+		Format: KeyFormatLibp2pCleartext,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
 type keyOpts struct{}
 
 var Key keyOpts
@@ -87,3 +130,39 @@ func (keyOpts) Force(force bool) KeyRenameOption {
 		return nil
 	}
 }
+
+// ImportFormat is an option for Key.Import which specifies the format of the
+// imported private key. Default is options.KeyFormatLibp2pCleartext
+//
+// Supported formats:
+// * options.KeyFormatLibp2pCleartext
+// * options.KeyFormatPemCleartext
+func (keyOpts) ImportFormat(format string) KeyImportOption {
+	return func(settings *KeyImportSettings) error {
+		settings.Format = format
+		return nil
+	}
+}
+
+// AllowAnyType is an option for Key.Import which specifies whether to allow
+// importing keys of other types than options.RSAKey and options.Ed25519Key.
+// Default is false
+func (keyOpts) AllowAnyType(allow bool) KeyImportOption {
+	return func(settings *KeyImportSettings) error {
+		settings.AllowAnyType = allow
+		return nil
+	}
+}
+
+// ExportFormat is an option for Key.Export which specifies the format of the
+// exported private key. Default is options.KeyFormatLibp2pCleartext
+//
+// Supported formats:
+// * options.KeyFormatLibp2pCleartext
+// * options.KeyFormatPemCleartext
+func (keyOpts) ExportFormat(format string) KeyExportOption {
+	return func(settings *KeyExportSettings) error {
+		settings.Format = format
+		return nil
+	}
+}
@@ -1,16 +1,23 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"math"
 	"strings"
 	"testing"
 
 	"github.com/ipfs/boxo/path"
 	coreiface "github.com/ipfs/kubo/core/coreiface"
+	opt "github.com/ipfs/kubo/core/coreiface/options"
 
+	"github.com/ipfs/go-cid"
 	ipldcbor "github.com/ipfs/go-ipld-cbor"
 	ipld "github.com/ipfs/go-ipld-format"
+	gocarv2 "github.com/ipld/go-car/v2"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
 	mh "github.com/multiformats/go-multihash"
 )
 
@@ -27,6 +34,8 @@ func (tp *TestSuite) TestDag(t *testing.T) {
 	t.Run("TestPath", tp.TestDagPath)
 	t.Run("TestTree", tp.TestTree)
 	t.Run("TestBatch", tp.TestBatch)
+	t.Run("TestExportImport", tp.TestDagExportImport)
+	t.Run("TestExportSelector", tp.TestDagExportSelector)
 }
 
 var treeExpected = map[string]struct{}{
@@ -197,3 +206,123 @@ func (tp *TestSuite) TestBatch(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func (tp *TestSuite) TestDagExportImport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.makeAPISwarm(t, ctx, false, false, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, dst := apis[0], apis[1]
+
+	leaf, err := ipldcbor.FromJSON(strings.NewReader(`"leaf"`), math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ipldcbor.FromJSON(strings.NewReader(`{"lnk": {"/": "`+leaf.Cid().String()+`"}}`), math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Dag().AddMany(ctx, []ipld.Node{leaf, root}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := src.Dag().Export(ctx, path.FromCid(root.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	car, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := dst.Dag().Import(ctx, bytes.NewReader(car), opt.Dag.PinRoots(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.BlockCount != 2 || len(res.Roots) != 1 || !res.Roots[0].Cid.Equals(root.Cid()) || res.Roots[0].PinErrorMsg != "" {
+		t.Errorf("unexpected import result: %+v", res)
+	}
+	if _, err := dst.Dag().Get(ctx, leaf.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	_, pinned, err := dst.Pin().IsPinned(ctx, path.FromCid(root.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned {
+		t.Error("the root was pinned without PinRoots")
+	}
+
+	res, err = dst.Dag().Import(ctx, bytes.NewReader(car))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Roots) != 1 || !res.Roots[0].Cid.Equals(root.Cid()) || res.Roots[0].PinErrorMsg != "" {
+		t.Fatalf("unexpected import roots: %+v", res.Roots)
+	}
+	_, pinned, err = dst.Pin().IsPinned(ctx, path.FromCid(root.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pinned {
+		t.Error("the root was not pinned")
+	}
+
+	if _, err := dst.Dag().Import(ctx, bytes.NewReader(car[:len(car)-4])); err == nil {
+		t.Error("expected an error importing a truncated CAR")
+	}
+}
+
+func (tp *TestSuite) TestDagExportSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := ipldcbor.FromJSON(strings.NewReader(`"leaf"`), math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ipldcbor.FromJSON(strings.NewReader(`{"lnk": {"/": "`+leaf.Cid().String()+`"}}`), math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Dag().AddMany(ctx, []ipld.Node{leaf, root}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := api.Dag().Export(ctx, path.FromCid(root.Cid()), opt.Dag.Selector(selectorparse.CommonSelector_MatchPoint))
+	if errors.Is(err, coreiface.ErrNotSupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	car, err := gocarv2.NewBlockReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []cid.Cid
+	for {
+		block, err := car.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		exported = append(exported, block.Cid())
+	}
+	if len(exported) != 1 || !exported[0].Equals(root.Cid()) {
+		t.Errorf("expected only the root block to be exported, got %v", exported)
+	}
+}
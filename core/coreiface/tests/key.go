@@ -2,6 +2,8 @@ package tests
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"strings"
 	"testing"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/ipfs/go-cid"
 	iface "github.com/ipfs/kubo/core/coreiface"
 	opt "github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	mbase "github.com/multiformats/go-multibase"
 	"github.com/stretchr/testify/assert"
@@ -40,6 +43,7 @@ func (tp *TestSuite) TestKey(t *testing.T) {
 	t.Run("TestRenameSameName", tp.TestRenameSameName)
 	t.Run("TestSign", tp.TestSign)
 	t.Run("TestVerify", tp.TestVerify)
+	t.Run("TestExportImport", tp.TestExportImport)
 }
 
 func (tp *TestSuite) TestListSelf(t *testing.T) {
@@ -425,3 +429,45 @@ func (tp *TestSuite) TestVerify(t *testing.T) {
 		}
 	})
 }
+
+func (tp *TestSuite) TestExportImport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	api, err := tp.makeAPI(t, ctx)
+	require.NoError(t, err)
+
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	pid, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+	data, err := crypto.MarshalPrivateKey(sk)
+	require.NoError(t, err)
+
+	key, err := api.Key().Import(ctx, "foo", data)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", key.Name())
+	assert.Equal(t, pid, key.ID())
+	_, err = api.Key().Import(ctx, "foo", data)
+	require.ErrorContains(t, err, "key with name 'foo' already exists")
+
+	// the private keys are only exported in-process
+	_, err = api.Key().Export(ctx, "foo")
+	if errors.Is(err, iface.ErrNotSupported) {
+		return
+	}
+	require.NoError(t, err)
+
+	_, err = api.Key().Export(ctx, "self")
+	require.ErrorContains(t, err, "cannot export key with name 'self'")
+
+	for _, format := range []string{opt.KeyFormatLibp2pCleartext, opt.KeyFormatPemCleartext} {
+		data, err := api.Key().Export(ctx, "foo", opt.Key.ExportFormat(format))
+		require.NoError(t, err)
+
+		imported, err := api.Key().Import(ctx, "bar-"+format, data, opt.Key.ImportFormat(format))
+		require.NoError(t, err)
+		assert.Equal(t, "bar-"+format, imported.Name())
+		assert.Equal(t, pid, imported.ID())
+	}
+}
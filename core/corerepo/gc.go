@@ -187,7 +187,7 @@ func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
 		select {
 		case <-ctx.Done():
 			return nil
-		case <-time.After(period):
+		case <-node.Clock.After(period):
 			// the private func maybeGC doesn't compute storageMax, storageGC, slackGC so that they are not re-computed for every cycle
 			if err := gc.maybeGC(ctx, 0); err != nil {
 				log.Error(err)
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/kubo/autoconf"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+	"go.uber.org/fx"
+)
+
+// AutoConfUpdates fetches the network defaults of AutoConf every
+// AutoConf.RefreshInterval while the node is online. The bootstrap peers
+// fetched are used by the next bootstrap round, the delegated routers and DNS
+// resolvers from the next start.
+func AutoConfUpdates(cfg config.AutoConf) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultAutoConfEnabled) {
+		return fx.Options()
+	}
+	if cfg.URL == "" {
+		return fx.Error(errors.New("AutoConf.URL must be set when AutoConf is enabled"))
+	}
+	if _, err := autoconf.TrustedKeys(cfg); err != nil {
+		return fx.Error(err)
+	}
+	interval := cfg.RefreshInterval.WithDefault(config.DefaultAutoConfRefreshInterval)
+	if interval <= 0 {
+		return fx.Error(fmt.Errorf("AutoConf.RefreshInterval must be positive"))
+	}
+
+	return fx.Invoke(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, r repo.Repo) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go autoConfLoop(ctx, r, cfg, interval)
+				return nil
+			},
+		})
+	})
+}
+
+func autoConfLoop(ctx context.Context, r repo.Repo, cfg config.AutoConf, interval time.Duration) {
+	// the document is fetched at start when the last attempt is older than
+	// the interval, such as on the first start
+	var wait time.Duration
+	if s, err := autoconf.LoadState(ctx, r.Datastore()); err == nil {
+		wait = max(interval-time.Since(s.LastAttempt), 0)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+		s, err := autoconf.Refresh(ctx, r.Datastore(), cfg)
+		if err != nil {
+			logger.Warnf("fetching the network defaults of AutoConf.URL: %s", err)
+		} else {
+			logger.Infof("network defaults of AutoConf.URL updated to version %d", s.Values.Version)
+		}
+		timer.Reset(interval)
+	}
+}
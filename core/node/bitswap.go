@@ -2,15 +2,37 @@ package node
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/ipfs/boxo/bitswap"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
 	"github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/bitswap/server"
+	"github.com/ipfs/boxo/bitswap/tracer"
 	blockstore "github.com/ipfs/boxo/blockstore"
 	exchange "github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/fetcher"
+	fetcherhelpers "github.com/ipfs/boxo/fetcher/helpers"
+	"github.com/ipfs/boxo/filestore"
+	"github.com/ipfs/boxo/mfs"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	provider "github.com/ipfs/boxo/provider"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	delay "github.com/ipfs/go-ipfs-delay"
+	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
 	"go.uber.org/fx"
 
 	"github.com/ipfs/kubo/core/node/helpers"
@@ -23,6 +45,21 @@ const (
 	DefaultEngineTaskWorkerCount       = 8
 	DefaultMaxOutstandingBytesPerPeer  = 1 << 20
 	DefaultProviderSearchDelay         = 1000 * time.Millisecond
+	DefaultRebroadcastInterval         = time.Minute
+	DefaultSimulateDontHaves           = true
+
+	DefaultServerFairnessMode             = "off"
+	DefaultServerFairnessIPv4PrefixLength = 24
+	DefaultServerFairnessIPv6PrefixLength = 48
+
+	// The provider query defaults are the limits of the Bitswap client, which
+	// can only be lowered for MaxConcurrentFinds and Timeout.
+	DefaultProviderQueryMaxProviders       = 10
+	DefaultProviderQueryMaxConcurrentFinds = 6
+	DefaultProviderQueryTimeout            = 10 * time.Second
+
+	DefaultFilestoreReadsConcurrency = 16
+	DefaultFilestoreReadsReadAhead   = "1MiB"
 )
 
 type bitswapOptionsOut struct {
@@ -43,12 +80,21 @@ func BitswapOptions(cfg *config.Config, provide bool) interface{} {
 		opts := []bitswap.Option{
 			bitswap.ProvideEnabled(provide),
 			bitswap.ProviderSearchDelay(internalBsCfg.ProviderSearchDelay.WithDefault(DefaultProviderSearchDelay)), // See https://github.com/ipfs/go-ipfs/issues/8807 for rationale
-			bitswap.EngineBlockstoreWorkerCount(int(internalBsCfg.EngineBlockstoreWorkerCount.WithDefault(DefaultEngineBlockstoreWorkerCount))),
-			bitswap.TaskWorkerCount(int(internalBsCfg.TaskWorkerCount.WithDefault(DefaultTaskWorkerCount))),
-			bitswap.EngineTaskWorkerCount(int(internalBsCfg.EngineTaskWorkerCount.WithDefault(DefaultEngineTaskWorkerCount))),
-			bitswap.MaxOutstandingBytesPerPeer(int(internalBsCfg.MaxOutstandingBytesPerPeer.WithDefault(DefaultMaxOutstandingBytesPerPeer))),
+			bitswap.EngineBlockstoreWorkerCount(int(internalBsCfg.EngineBlockstoreWorkerCount.WithDefault(cfg.Resources.Scale(DefaultEngineBlockstoreWorkerCount, 8)))),
+			bitswap.TaskWorkerCount(int(internalBsCfg.TaskWorkerCount.WithDefault(cfg.Resources.Scale(DefaultTaskWorkerCount, 2)))),
+			bitswap.EngineTaskWorkerCount(int(internalBsCfg.EngineTaskWorkerCount.WithDefault(cfg.Resources.Scale(DefaultEngineTaskWorkerCount, 2)))),
+			bitswap.MaxOutstandingBytesPerPeer(int(internalBsCfg.MaxOutstandingBytesPerPeer.WithDefault(cfg.Resources.Scale(DefaultMaxOutstandingBytesPerPeer, 256<<10)))),
 		}
 
+		var timeouts config.InternalBitswapClientTimeouts
+		if internalBsCfg.ClientTimeouts != nil {
+			timeouts = *internalBsCfg.ClientTimeouts
+		}
+		opts = append(opts,
+			bitswap.RebroadcastDelay(delay.Fixed(timeouts.RebroadcastInterval.WithDefault(DefaultRebroadcastInterval))),
+			bitswap.SetSimulateDontHavesOnTimeout(timeouts.SimulateDontHaves.WithDefault(DefaultSimulateDontHaves)),
+		)
+
 		return bitswapOptionsOut{BitswapOpts: opts}
 	}
 }
@@ -60,22 +106,369 @@ type onlineExchangeIn struct {
 	Host        host.Host
 	Rt          irouting.ProvideManyRouter
 	Bs          blockstore.GCBlockstore
-	BitswapOpts []bitswap.Option `group:"bitswap-options"`
+	BitswapOpts []bitswap.Option       `group:"bitswap-options"`
+	Tracers     []tracer.Tracer        `group:"bitswap-tracers"`
+	Networks    []exchange.Interface   `group:"bitswap-networks"`
+	Stats       *BitswapStatsCollector `optional:"true"`
+	Verifier    *BitswapVerifier       `optional:"true"`
+	BlockFilter *BitswapBlockFilter    `optional:"true"`
+	Reputation  *BitswapReputation     `optional:"true"`
+	Ignored     *IgnoredProviders      `optional:"true"`
+	Fairness    *bitswapFairness       `optional:"true"`
+	Filestore   *filestore.Filestore   `optional:"true"`
+	SlowLog     *SlowLog               `optional:"true"`
+	AccessLog   *GatewayAccessLog      `optional:"true"`
+	Repo        repo.Repo
+}
+
+// bitswapTracers calls every tracer of the "bitswap-tracers" group, as
+// bitswap only accepts one.
+type bitswapTracers []tracer.Tracer
+
+func (ts bitswapTracers) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	for _, t := range ts {
+		t.MessageReceived(p, msg)
+	}
+}
+
+func (ts bitswapTracers) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	for _, t := range ts {
+		t.MessageSent(p, msg)
+	}
 }
 
 // OnlineExchange creates new LibP2P backed block exchange (BitSwap).
 // Additional options to bitswap.New can be provided via the "bitswap-options"
-// group.
-func OnlineExchange() interface{} {
-	return func(in onlineExchangeIn, lc fx.Lifecycle) exchange.Interface {
-		bitswapNetwork := network.NewFromIpfsHost(in.Host, in.Rt)
+// group, and message tracers via the "bitswap-tracers" group. Exchanges of
+// other Bitswap networks in the "bitswap-networks" group are used alongside.
+func OnlineExchange(cfg *config.Config) interface{} {
+	var pqCfg config.InternalBitswapProviderQuery
+	if cfg.Internal.Bitswap != nil && cfg.Internal.Bitswap.ProviderQuery != nil {
+		pqCfg = *cfg.Internal.Bitswap.ProviderQuery
+	}
+	var fsCfg config.InternalBitswapFilestoreReads
+	if cfg.Internal.Bitswap != nil && cfg.Internal.Bitswap.FilestoreReads != nil {
+		fsCfg = *cfg.Internal.Bitswap.FilestoreReads
+	}
+
+	return func(in onlineExchangeIn, lc fx.Lifecycle) (exchange.Interface, error) {
+		rt, err := limitProviderQueries(in.Rt, pqCfg)
+		if err != nil {
+			return nil, err
+		}
+		if in.Ignored != nil {
+			rt = &ignoringContentRouting{ContentRouting: rt, ignored: in.Ignored, ps: in.Host.Peerstore()}
+		}
+		if in.Stats != nil {
+			rt = &statsContentRouting{ContentRouting: rt, stats: in.Stats}
+		}
+		if in.Reputation != nil {
+			rt = &reputationContentRouting{ContentRouting: rt, reputation: in.Reputation}
+		}
+		bitswapNetwork := network.NewFromIpfsHost(in.Host, rt)
+		if in.Reputation != nil {
+			bitswapNetwork = &reputationNetwork{BitSwapNetwork: bitswapNetwork, reputation: in.Reputation}
+		}
+		if in.Fairness != nil {
+			bitswapNetwork = &fairnessNetwork{BitSwapNetwork: bitswapNetwork, fairness: in.Fairness}
+		}
+		if in.BlockFilter != nil {
+			bitswapNetwork = &policyNetwork{BitSwapNetwork: bitswapNetwork, filter: in.BlockFilter}
+		}
+		if in.Verifier != nil {
+			bitswapNetwork = &verifyingNetwork{BitSwapNetwork: bitswapNetwork, verifier: in.Verifier}
+		}
 
-		exch := bitswap.New(helpers.LifecycleCtx(in.Mctx, lc), bitswapNetwork, in.Bs, in.BitswapOpts...)
+		opts := in.BitswapOpts
+		if len(in.Tracers) > 0 {
+			opts = append(opts, bitswap.WithTracer(bitswapTracers(in.Tracers)))
+		}
+		var bs blockstore.GCBlockstore = in.Bs
+		if in.Filestore != nil {
+			// the file paths of the filestore are relative to the parent
+			// directory of the repo
+			var root string
+			if r, ok := in.Repo.(interface{ Path() string }); ok {
+				root = filepath.Dir(r.Path())
+			}
+			if bs, err = newFilestoreReader(in.Bs, in.Filestore, root, fsCfg); err != nil {
+				return nil, err
+			}
+		}
+		exch := bitswap.New(helpers.LifecycleCtx(in.Mctx, lc), bitswapNetwork, bs, opts...)
 		lc.Append(fx.Hook{
 			OnStop: func(ctx context.Context) error {
 				return exch.Close()
 			},
 		})
-		return exch
+		var public exchange.Interface = exch
+		if in.SlowLog != nil {
+			public = &slowExchange{Interface: public, log: in.SlowLog}
+		}
+		if in.AccessLog != nil {
+			public = &accessLogExchange{Interface: public, log: in.AccessLog}
+		}
+		if in.Stats != nil {
+			public = &statsExchange{Interface: public, stats: in.Stats}
+		}
+		if len(in.Networks) > 0 {
+			return newMultiExchange(append([]exchange.Interface{public}, in.Networks...)...), nil
+		}
+		return public, nil
+	}
+}
+
+// limitProviderQueries applies Internal.Bitswap.ProviderQuery to the provider
+// queries Bitswap makes with rt.
+func limitProviderQueries(rt routing.ContentRouting, cfg config.InternalBitswapProviderQuery) (routing.ContentRouting, error) {
+	maxProviders := cfg.MaxProviders.WithDefault(DefaultProviderQueryMaxProviders)
+	maxFinds := cfg.MaxConcurrentFinds.WithDefault(DefaultProviderQueryMaxConcurrentFinds)
+	timeout := cfg.Timeout.WithDefault(DefaultProviderQueryTimeout)
+	if maxProviders <= 0 || maxFinds <= 0 || timeout <= 0 {
+		return nil, fmt.Errorf("invalid Internal.Bitswap.ProviderQuery: MaxProviders, MaxConcurrentFinds and Timeout must be positive")
+	}
+	if maxFinds > DefaultProviderQueryMaxConcurrentFinds {
+		logger.Warnf("Internal.Bitswap.ProviderQuery.MaxConcurrentFinds is capped at %d by Bitswap", DefaultProviderQueryMaxConcurrentFinds)
+	}
+	if timeout > DefaultProviderQueryTimeout {
+		logger.Warnf("Internal.Bitswap.ProviderQuery.Timeout is capped at %s by Bitswap", DefaultProviderQueryTimeout)
+	}
+	if maxProviders == DefaultProviderQueryMaxProviders && maxFinds >= DefaultProviderQueryMaxConcurrentFinds && timeout >= DefaultProviderQueryTimeout {
+		return rt, nil
+	}
+	return &limitedContentRouting{
+		ContentRouting: rt,
+		maxProviders:   int(maxProviders),
+		timeout:        timeout,
+		finds:          make(chan struct{}, maxFinds),
+	}, nil
+}
+
+// limitedContentRouting bounds the provider queries made through it.
+type limitedContentRouting struct {
+	routing.ContentRouting
+	maxProviders int
+	timeout      time.Duration
+	finds        chan struct{}
+}
+
+func (r *limitedContentRouting) FindProvidersAsync(ctx context.Context, c cid.Cid, _ int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		select {
+		case r.finds <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-r.finds }()
+
+		ctx, cancel := context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+		for p := range r.ContentRouting.FindProvidersAsync(ctx, c, r.maxProviders) {
+			select {
+			case out <- p:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
+
+// ErrBlockExchangeDisabled is returned when a block is not available locally
+// and Bitswap.Enabled or Bitswap.Libp2pEnabled is false.
+var ErrBlockExchangeDisabled = errors.New("block exchange disabled")
+
+// disabledExchange serves local blocks and fails immediately for the rest,
+// instead of leaving requests waiting on a network fetch that never happens.
+type disabledExchange struct {
+	exchange.Interface
+	bs     blockstore.Blockstore
+	reason string
+}
+
+// DisabledExchange is the exchange used when Bitswap.Enabled is false.
+func DisabledExchange(bs blockstore.Blockstore) exchange.Interface {
+	return &disabledExchange{Interface: offline.Exchange(bs), bs: bs, reason: "Bitswap.Enabled=false"}
+}
+
+func (e *disabledExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := e.Interface.GetBlock(ctx, c)
+	if ipld.IsNotFound(err) {
+		return nil, fmt.Errorf("%w (%s): %s is not available locally", ErrBlockExchangeDisabled, e.reason, c)
+	}
+	return blk, err
+}
+
+func (e *disabledExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	for _, c := range cids {
+		has, err := e.bs.Has(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			return nil, fmt.Errorf("%w (%s): %s is not available locally", ErrBlockExchangeDisabled, e.reason, c)
+		}
+	}
+	return e.Interface.GetBlocks(ctx, cids)
+}
+
+// bitswapServeMinReloadInterval spaces the reloads of the sets of
+// Bitswap.ServeStrategy following the changes of the pins and of MFS, each
+// walking all of the DAGs of the strategy.
+const bitswapServeMinReloadInterval = 10 * time.Second
+
+// bitswapServeSet is the set of multihashes the Bitswap server is allowed to
+// serve when Bitswap.ServeStrategy is not "all". Until the first load
+// completes nothing is served. The set holds every servable multihash in
+// memory, and is reloaded after the changes of the pins and of MFS, so the
+// blocks unpinned or removed from MFS are served until the next reload
+// completes.
+type bitswapServeSet struct {
+	lk     sync.RWMutex
+	hashes map[string]struct{}
+}
+
+func (s *bitswapServeSet) allow(_ peer.ID, c cid.Cid) bool {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	_, ok := s.hashes[string(c.Hash())]
+	return ok
+}
+
+func (s *bitswapServeSet) reload(ctx context.Context, keys provider.KeyChanFunc) error {
+	ch, err := keys(ctx)
+	if err != nil {
+		return err
+	}
+	hashes := make(map[string]struct{})
+	for c := range ch {
+		hashes[string(c.Hash())] = struct{}{}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.lk.Lock()
+	s.hashes = hashes
+	s.lk.Unlock()
+	return nil
+}
+
+type bitswapServeStrategyIn struct {
+	fx.In
+
+	Mctx               helpers.MetricsCtx
+	Pinner             pin.Pinner
+	FilesRoot          *mfs.Root
+	Changes            *ContentChanges
+	OfflineIPLDFetcher fetcher.Factory `name:"offlineIpldFetcher"`
+}
+
+// BitswapServeStrategy restricts the blocks served over Bitswap to the ones
+// selected by strategy. The pinner and MFS root depend on the exchange, so the
+// filter is handed to bitswap empty and filled once the node is constructed.
+func BitswapServeStrategy(strategy string, refreshInterval time.Duration) fx.Option {
+	filter, opt := bitswapServeFilter(strategy, refreshInterval)
+	if filter == nil {
+		return opt
+	}
+	return fx.Options(
+		fx.Provide(func() bitswapOptionsOut {
+			return bitswapOptionsOut{BitswapOpts: []bitswap.Option{bitswap.WithPeerBlockRequestFilter(filter)}}
+		}),
+		opt,
+	)
+}
+
+// bitswapServeFilter returns the request filter of strategy, nil for "all",
+// and the option keeping its set of servable blocks up to date.
+func bitswapServeFilter(strategy string, refreshInterval time.Duration) (server.PeerBlockRequestFilter, fx.Option) {
+	switch strategy {
+	case "all", "":
+		return nil, fx.Options()
+	case "pinned", "mfs", "pinned+mfs":
+	default:
+		return nil, fx.Error(fmt.Errorf("unknown Bitswap.ServeStrategy %q", strategy))
+	}
+
+	set := new(bitswapServeSet)
+	return set.allow, fx.Invoke(func(in bitswapServeStrategyIn, lc fx.Lifecycle) {
+		var keys provider.KeyChanFunc
+		switch strategy {
+		case "pinned":
+			keys = provider.NewPinnedProvider(false, in.Pinner, in.OfflineIPLDFetcher)
+		case "mfs":
+			keys = newMFSKeyChanFunc(in.FilesRoot, in.OfflineIPLDFetcher)
+		case "pinned+mfs":
+			keys = provider.NewPrioritizedProvider(
+				provider.NewPinnedProvider(false, in.Pinner, in.OfflineIPLDFetcher),
+				newMFSKeyChanFunc(in.FilesRoot, in.OfflineIPLDFetcher),
+			)
+		}
+
+		ctx := helpers.LifecycleCtx(in.Mctx, lc)
+		changed := make(chan struct{}, 1)
+		in.Changes.Notify(changed)
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				go func() {
+					ticker := time.NewTicker(refreshInterval)
+					defer ticker.Stop()
+					for {
+						loaded := time.Now()
+						if err := set.reload(ctx, keys); err != nil && ctx.Err() == nil {
+							logger.Errorf("loading Bitswap.ServeStrategy %q set: %s", strategy, err)
+						}
+						select {
+						case <-ticker.C:
+						case <-changed:
+							select {
+							case <-time.After(time.Until(loaded.Add(bitswapServeMinReloadInterval))):
+							case <-ticker.C:
+							case <-ctx.Done():
+								return
+							}
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+				return nil
+			},
+		})
+	})
+}
+
+// newMFSKeyChanFunc returns every block reachable from the MFS root that is
+// available locally.
+func newMFSKeyChanFunc(root *mfs.Root, fetchConfig fetcher.Factory) provider.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		rootNode, err := root.GetDirectory().GetNode()
+		if err != nil {
+			return nil, err
+		}
+
+		outCh := make(chan cid.Cid)
+		go func() {
+			defer close(outCh)
+			session := fetchConfig.NewSession(ctx)
+			err := fetcherhelpers.BlockAll(ctx, session, cidlink.Link{Cid: rootNode.Cid()}, func(res fetcher.FetchResult) error {
+				clink, ok := res.LastBlockLink.(cidlink.Link)
+				if !ok {
+					return nil
+				}
+				select {
+				case outCh <- clink.Cid:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				return nil
+			})
+			if err != nil && ctx.Err() == nil {
+				logger.Errorf("walking MFS root: %s", err)
+			}
+		}()
+		return outCh, nil
 	}
 }
@@ -0,0 +1,185 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/ipfs/boxo/bitswap"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/bitswap/server"
+	"github.com/ipfs/kubo/config"
+	asnutil "github.com/libp2p/go-libp2p-asn-util"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"go.uber.org/fx"
+)
+
+// bitswapFairness groups the peers served by Bitswap by network, and makes the
+// engine serve the peers of the groups with MaxOutstandingBytesPerPeer or more
+// bytes being sent last, so a datacenter running many peer IDs cannot take all
+// the task workers.
+type bitswapFairness struct {
+	groupOf  func(peer.ID) string
+	maxBytes int
+
+	mu       sync.Mutex
+	groups   map[peer.ID]string
+	inFlight map[string]int
+}
+
+type bitswapFairnessOut struct {
+	fx.Out
+
+	Fairness    *bitswapFairness
+	BitswapOpts []bitswap.Option `group:"bitswap-options,flatten"`
+}
+
+// BitswapServerFairness applies Internal.Bitswap.ServerFairness to the public
+// Bitswap server.
+func BitswapServerFairness(cfg config.InternalBitswap) fx.Option {
+	var fcfg config.InternalBitswapServerFairness
+	if cfg.ServerFairness != nil {
+		fcfg = *cfg.ServerFairness
+	}
+	mode := fcfg.Mode.WithDefault(DefaultServerFairnessMode)
+	if mode == "off" {
+		return fx.Options()
+	}
+	if mode != "ip-prefix" && mode != "asn" {
+		return fx.Error(fmt.Errorf("unknown Internal.Bitswap.ServerFairness.Mode %q", mode))
+	}
+	v4 := int(fcfg.IPv4PrefixLength.WithDefault(DefaultServerFairnessIPv4PrefixLength))
+	v6 := int(fcfg.IPv6PrefixLength.WithDefault(DefaultServerFairnessIPv6PrefixLength))
+	if v4 < 0 || v4 > 32 || v6 < 0 || v6 > 128 {
+		return fx.Error(fmt.Errorf("invalid Internal.Bitswap.ServerFairness prefix lengths /%d and /%d", v4, v6))
+	}
+	maxBytes := int(cfg.MaxOutstandingBytesPerPeer.WithDefault(DefaultMaxOutstandingBytesPerPeer))
+
+	return fx.Provide(func(h host.Host) bitswapFairnessOut {
+		f := newBitswapFairness(func(p peer.ID) string {
+			return hostPeerGroup(h, p, v4, v6, mode == "asn")
+		}, maxBytes)
+		return bitswapFairnessOut{
+			Fairness:    f,
+			BitswapOpts: []bitswap.Option{bitswap.WithTaskComparator(f.less)},
+		}
+	})
+}
+
+func newBitswapFairness(groupOf func(peer.ID) string, maxBytes int) *bitswapFairness {
+	return &bitswapFairness{
+		groupOf:  groupOf,
+		maxBytes: maxBytes,
+		groups:   make(map[peer.ID]string),
+		inFlight: make(map[string]int),
+	}
+}
+
+// hostPeerGroup is the group of the remote address of the first IP connection
+// to p. Peers only reachable through relays are alone in their group.
+func hostPeerGroup(h host.Host, p peer.ID, v4, v6 int, asn bool) string {
+	for _, c := range h.Network().ConnsToPeer(p) {
+		ip, err := manet.ToIP(c.RemoteMultiaddr())
+		if err == nil {
+			return ipGroup(ip, v4, v6, asn)
+		}
+	}
+	return p.String()
+}
+
+// ipGroup is the IP prefix of ip, or its autonomous system when asn is true
+// and ip is a known IPv6 address. There is no ASN table for IPv4.
+func ipGroup(ip net.IP, v4, v6 int, asn bool) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(v4, 32)).String() + "/" + strconv.Itoa(v4)
+	}
+	if asn {
+		if n := asnutil.AsnForIPv6(ip); n != 0 {
+			return "AS" + strconv.FormatUint(uint64(n), 10)
+		}
+	}
+	return ip.Mask(net.CIDRMask(v6, 128)).String() + "/" + strconv.Itoa(v6)
+}
+
+func (f *bitswapFairness) groupLocked(p peer.ID) string {
+	g, ok := f.groups[p]
+	if !ok {
+		g = f.groupOf(p)
+		f.groups[p] = g
+	}
+	return g
+}
+
+// less orders the tasks of the engine: the peers of the groups over
+// maxBytes go last, and the others by the bytes being sent to their group.
+func (f *bitswapFairness) less(ta, tb *server.TaskInfo) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	la := f.inFlight[f.groupLocked(ta.Peer)]
+	lb := f.inFlight[f.groupLocked(tb.Peer)]
+	if overA, overB := la >= f.maxBytes, lb >= f.maxBytes; overA != overB {
+		return overB
+	}
+	return la < lb
+}
+
+// sending counts size bytes being sent to p, and returns the group of p.
+func (f *bitswapFairness) sending(p peer.ID, size int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g := f.groupLocked(p)
+	f.inFlight[g] += size
+	return g
+}
+
+func (f *bitswapFairness) sent(g string, size int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.inFlight[g] -= size; f.inFlight[g] <= 0 {
+		delete(f.inFlight, g)
+	}
+}
+
+func (f *bitswapFairness) forget(p peer.ID) {
+	f.mu.Lock()
+	delete(f.groups, p)
+	f.mu.Unlock()
+}
+
+// fairnessNetwork counts the bytes being sent by the Bitswap server to each
+// group of a bitswapFairness.
+type fairnessNetwork struct {
+	network.BitSwapNetwork
+	fairness *bitswapFairness
+}
+
+func (n *fairnessNetwork) Start(receivers ...network.Receiver) {
+	wrapped := make([]network.Receiver, len(receivers))
+	for i, r := range receivers {
+		wrapped[i] = &fairnessReceiver{Receiver: r, fairness: n.fairness}
+	}
+	n.BitSwapNetwork.Start(wrapped...)
+}
+
+// SendMessage is only used by the server, the client sends its wants with
+// message senders.
+func (n *fairnessNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	size := msg.Size()
+	defer n.fairness.sent(n.fairness.sending(p, size), size)
+	return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+type fairnessReceiver struct {
+	network.Receiver
+	fairness *bitswapFairness
+}
+
+func (r *fairnessReceiver) PeerDisconnected(p peer.ID) {
+	r.fairness.forget(p)
+	r.Receiver.PeerDisconnected(p)
+}
@@ -0,0 +1,38 @@
+package node
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ipfs/boxo/bitswap/server"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPGroup(t *testing.T) {
+	require.Equal(t, "192.0.2.0/24", ipGroup(net.ParseIP("192.0.2.17"), 24, 48, false))
+	require.Equal(t, "192.0.0.0/16", ipGroup(net.ParseIP("192.0.2.17"), 16, 48, true))
+	require.Equal(t, "2001:db8:1::/48", ipGroup(net.ParseIP("2001:db8:1:2::5"), 24, 48, false))
+	require.Equal(t, "2001:db8:1::/48", ipGroup(net.ParseIP("2001:db8:1:2::5"), 24, 48, true), "unknown ASNs fall back to the prefix")
+}
+
+func TestBitswapFairness(t *testing.T) {
+	groups := map[peer.ID]string{"dc1": "dc", "dc2": "dc", "home": "home", "office": "office"}
+	f := newBitswapFairness(func(p peer.ID) string { return groups[p] }, 100)
+	task := func(p peer.ID) *server.TaskInfo { return &server.TaskInfo{Peer: p} }
+
+	require.False(t, f.less(task("dc1"), task("home")), "groups without bytes in flight are equal")
+
+	g := f.sending("dc1", 100)
+	require.True(t, f.less(task("home"), task("dc2")), "the peers of a group over the limit go last")
+	require.False(t, f.less(task("dc2"), task("home")))
+
+	home := f.sending("home", 10)
+	require.True(t, f.less(task("office"), task("home")), "groups with fewer bytes in flight go first")
+	require.True(t, f.less(task("home"), task("dc1")))
+
+	f.sent(g, 100)
+	f.sent(home, 10)
+	require.False(t, f.less(task("home"), task("dc2")))
+	require.Empty(t, f.inFlight)
+}
@@ -0,0 +1,214 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/filestore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+)
+
+// filestoreWindows is the number of read-ahead windows kept per concurrent
+// read, so that several files can be served in parallel.
+const filestoreWindows = 2
+
+// filestoreReader is the blockstore Bitswap serves blocks from when the
+// filestore or the urlstore is enabled. The blocks referencing a file or a URL
+// are read straight from it, without being copied to the blockstore, with a
+// bounded number of concurrent reads. As the blocks of a file are usually
+// requested in order, each read fetches the following bytes of the file too,
+// to serve the next blocks from memory.
+type filestoreReader struct {
+	blockstore.GCBlockstore
+
+	fstore    *filestore.Filestore
+	root      string
+	readAhead uint64
+	reads     chan struct{}
+
+	mu      sync.Mutex
+	windows []*filestoreWindow
+}
+
+// filestoreWindow holds the bytes of path read from offset.
+type filestoreWindow struct {
+	path   string
+	offset uint64
+	data   []byte
+}
+
+// newFilestoreReader applies Internal.Bitswap.FilestoreReads to the blocks
+// served from fstore. root is the directory
+// the file paths of the filestore are relative to, if known.
+func newFilestoreReader(bs blockstore.GCBlockstore, fstore *filestore.Filestore, root string, cfg config.InternalBitswapFilestoreReads) (*filestoreReader, error) {
+	concurrency := cfg.Concurrency.WithDefault(DefaultFilestoreReadsConcurrency)
+	if concurrency <= 0 {
+		return nil, fmt.Errorf("invalid Internal.Bitswap.FilestoreReads.Concurrency %d: must be positive", concurrency)
+	}
+	readAhead, err := humanize.ParseBytes(cfg.ReadAhead.WithDefault(DefaultFilestoreReadsReadAhead))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Internal.Bitswap.FilestoreReads.ReadAhead: %w", err)
+	}
+	return &filestoreReader{
+		GCBlockstore: bs,
+		fstore:       fstore,
+		root:         root,
+		readAhead:    readAhead,
+		reads:        make(chan struct{}, concurrency),
+	}, nil
+}
+
+func (r *filestoreReader) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	has, err := r.fstore.MainBlockstore().Has(ctx, c)
+	if err != nil || has {
+		return r.GCBlockstore.Get(ctx, c)
+	}
+	ref := filestore.List(ctx, r.fstore, c)
+	if ref.Status != filestore.StatusOk {
+		return r.GCBlockstore.Get(ctx, c)
+	}
+
+	if data := r.cached(ref); data != nil {
+		if blk, err := newVerifiedBlock(c, data); err == nil {
+			return blk, nil
+		}
+		// the file changed since it was read
+		r.forget(ref.FilePath)
+	}
+
+	select {
+	case r.reads <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-r.reads }()
+
+	data, err := r.read(ctx, ref)
+	if err != nil {
+		// let the filestore report why the reference is broken
+		return r.GCBlockstore.Get(ctx, c)
+	}
+	blk, err := newVerifiedBlock(c, data)
+	if err != nil {
+		r.forget(ref.FilePath)
+		return r.GCBlockstore.Get(ctx, c)
+	}
+	return blk, nil
+}
+
+// read returns the bytes of ref, reading ahead when enabled.
+func (r *filestoreReader) read(ctx context.Context, ref *filestore.ListRes) ([]byte, error) {
+	isURL := filestore.IsURL(ref.FilePath)
+	fm := r.fstore.FileManager()
+	if (isURL && !fm.AllowUrls) || (!isURL && !fm.AllowFiles) || (!isURL && r.root == "") || r.readAhead <= ref.Size {
+		blk, err := r.GCBlockstore.Get(ctx, ref.Key)
+		if err != nil {
+			return nil, err
+		}
+		return blk.RawData(), nil
+	}
+
+	var data []byte
+	var err error
+	if isURL {
+		data, err = readURLRange(ctx, ref.FilePath, ref.Offset, r.readAhead)
+	} else {
+		data, err = readFileRange(filepath.Join(r.root, filepath.FromSlash(ref.FilePath)), ref.Offset, r.readAhead)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) < ref.Size {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	r.mu.Lock()
+	if len(r.windows) == filestoreWindows*cap(r.reads) {
+		r.windows = r.windows[1:]
+	}
+	r.windows = append(r.windows, &filestoreWindow{path: ref.FilePath, offset: ref.Offset, data: data})
+	r.mu.Unlock()
+	return data[:ref.Size], nil
+}
+
+// cached returns the bytes of ref if they were read ahead.
+func (r *filestoreReader) cached(ref *filestore.ListRes) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.windows {
+		if w.path == ref.FilePath && w.offset <= ref.Offset && ref.Offset+ref.Size <= w.offset+uint64(len(w.data)) {
+			start := ref.Offset - w.offset
+			return w.data[start : start+ref.Size]
+		}
+	}
+	return nil
+}
+
+func (r *filestoreReader) forget(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	windows := r.windows[:0]
+	for _, w := range r.windows {
+		if w.path != path {
+			windows = append(windows, w)
+		}
+	}
+	r.windows = windows
+}
+
+// newVerifiedBlock returns the block of data, if it matches c.
+func newVerifiedBlock(c cid.Cid, data []byte) (blocks.Block, error) {
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, errors.New("data does not match the CID")
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// readFileRange reads up to size bytes of path from offset.
+func readFileRange(path string, offset, size uint64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, size)
+	n, err := f.ReadAt(buf, int64(offset))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// readURLRange reads up to size bytes of url from offset.
+func readURLRange(ctx context.Context, url string, offset, size uint64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent {
+		// a server ignoring the range would send the whole file
+		return nil, fmt.Errorf("expected HTTP 206, got %d", res.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, int64(size)))
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/filestore"
+	"github.com/ipfs/boxo/filestore/posinfo"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilestoreReader(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	data := make([]byte, 1000)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	path := filepath.Join(root, "file")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	fm := filestore.NewFileManager(ds, root)
+	fm.AllowFiles = true
+	fstore := filestore.NewFilestore(blockstore.NewBlockstore(ds), fm)
+	var cids []cid.Cid
+	for i := 0; i < 10; i++ {
+		n := &posinfo.FilestoreNode{
+			PosInfo: &posinfo.PosInfo{FullPath: path, Offset: uint64(i * 100)},
+			Node:    dag.NewRawNode(data[i*100 : (i+1)*100]),
+		}
+		require.NoError(t, fstore.Put(ctx, n))
+		cids = append(cids, n.Cid())
+	}
+
+	bs := blockstore.NewGCBlockstore(fstore, blockstore.NewGCLocker())
+	r, err := newFilestoreReader(bs, fstore, root, config.InternalBitswapFilestoreReads{
+		ReadAhead: config.NewOptionalString("400"),
+	})
+	require.NoError(t, err)
+
+	for i, c := range cids {
+		blk, err := r.Get(ctx, c)
+		require.NoError(t, err)
+		require.Equal(t, data[i*100:(i+1)*100], blk.RawData())
+	}
+	// the ten blocks were served from three reads of the file
+	require.Len(t, r.windows, 3)
+
+	// the blocks are verified, not served once the file changed
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	r.windows = nil
+	_, err = r.Get(ctx, cids[0])
+	require.Error(t, err)
+	require.Empty(t, r.windows)
+}
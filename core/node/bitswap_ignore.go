@@ -0,0 +1,132 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	asnutil "github.com/libp2p/go-libp2p-asn-util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/routing"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	mamask "github.com/whyrusleeping/multiaddr-filter"
+	"go.uber.org/fx"
+)
+
+// IgnoredProviders are the providers of Routing.IgnoreProviders, which
+// Bitswap never asks for blocks.
+type IgnoredProviders struct {
+	peers   map[peer.ID]struct{}
+	subnets []*net.IPNet
+	asns    map[uint32]struct{}
+}
+
+func newIgnoredProviders(entries []string) (*IgnoredProviders, error) {
+	l := &IgnoredProviders{
+		peers: make(map[peer.ID]struct{}),
+		asns:  make(map[uint32]struct{}),
+	}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		switch {
+		case strings.HasPrefix(e, "/"):
+			subnet, err := mamask.NewMask(e)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Routing.IgnoreProviders %q: %w", e, err)
+			}
+			l.subnets = append(l.subnets, subnet)
+		case strings.Contains(e, "/"):
+			_, subnet, err := net.ParseCIDR(e)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Routing.IgnoreProviders %q: %w", e, err)
+			}
+			l.subnets = append(l.subnets, subnet)
+		case len(e) > 2 && strings.EqualFold(e[:2], "AS"):
+			asn, err := strconv.ParseUint(e[2:], 10, 32)
+			if err != nil || asn == 0 {
+				return nil, fmt.Errorf("invalid Routing.IgnoreProviders %q: not an autonomous system number", e)
+			}
+			l.asns[uint32(asn)] = struct{}{}
+		default:
+			id, err := peer.Decode(e)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Routing.IgnoreProviders %q: %w", e, err)
+			}
+			l.peers[id] = struct{}{}
+		}
+	}
+	return l, nil
+}
+
+// Ignored returns whether the provider with the given addresses is ignored:
+// its peer ID is listed, or any of its addresses is in a listed subnet or
+// autonomous system.
+func (l *IgnoredProviders) Ignored(p peer.ID, addrs []ma.Multiaddr) bool {
+	if _, ok := l.peers[p]; ok {
+		return true
+	}
+	if len(l.subnets) == 0 && len(l.asns) == 0 {
+		return false
+	}
+	for _, a := range addrs {
+		ip, err := manet.ToIP(a)
+		if err != nil {
+			continue
+		}
+		for _, subnet := range l.subnets {
+			if subnet.Contains(ip) {
+				return true
+			}
+		}
+		if len(l.asns) > 0 && ip.To4() == nil {
+			if _, ok := l.asns[asnutil.AsnForIPv6(ip)]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IgnoreProviders provides the IgnoredProviders when Routing.IgnoreProviders
+// is set.
+func IgnoreProviders(entries []string) fx.Option {
+	if len(entries) == 0 {
+		return fx.Options()
+	}
+	return fx.Provide(func() (*IgnoredProviders, error) {
+		return newIgnoredProviders(entries)
+	})
+}
+
+// ignoringContentRouting drops the ignored providers found for Bitswap. The
+// addresses known in the peerstore are checked along with the found ones, so
+// that providers returned without addresses are recognized too.
+type ignoringContentRouting struct {
+	routing.ContentRouting
+	ignored *IgnoredProviders
+	ps      peerstore.Peerstore
+}
+
+func (r *ignoringContentRouting) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	in := r.ContentRouting.FindProvidersAsync(ctx, c, count)
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		for ai := range in {
+			if r.ignored.Ignored(ai.ID, append(r.ps.Addrs(ai.ID), ai.Addrs...)) {
+				logger.Debugf("ignoring provider %s of %s: listed in Routing.IgnoreProviders", ai.ID, c)
+				continue
+			}
+			select {
+			case out <- ai:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type staticRouting struct {
+	routing.ContentRouting
+	providers []peer.AddrInfo
+}
+
+func (r *staticRouting) FindProvidersAsync(context.Context, cid.Cid, int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo, len(r.providers))
+	for _, ai := range r.providers {
+		out <- ai
+	}
+	close(out)
+	return out
+}
+
+func TestIgnoredProviders(t *testing.T) {
+	listed, err := peer.Decode("12D3KooWGC6TvWhfapngX6wvJHMYvKpDMXPb3ZnCZ6dMoaMtimQ5")
+	require.NoError(t, err)
+
+	ignored, err := newIgnoredProviders([]string{
+		listed.String(),
+		"192.0.2.0/24",
+		"/ip4/198.51.100.0/ipcidr/24",
+		"AS15169",
+	})
+	require.NoError(t, err)
+
+	addr := func(s string) []ma.Multiaddr {
+		return []ma.Multiaddr{ma.StringCast(s)}
+	}
+	require.True(t, ignored.Ignored(listed, nil))
+	require.True(t, ignored.Ignored("other", addr("/ip4/192.0.2.7/tcp/4001")))
+	require.True(t, ignored.Ignored("other", addr("/ip4/198.51.100.7/udp/4001/quic-v1")))
+	require.True(t, ignored.Ignored("other", addr("/ip6/2001:4860:4860::8888/tcp/4001")))
+	require.False(t, ignored.Ignored("other", addr("/ip4/203.0.113.7/tcp/4001")))
+	require.False(t, ignored.Ignored("other", addr("/dns4/example.com/tcp/4001")))
+
+	for _, invalid := range []string{"192.0.2.0/33", "/ip4/192.0.2.0", "AS", "ASx", "not-a-peer"} {
+		_, err := newIgnoredProviders([]string{invalid})
+		require.Error(t, err, invalid)
+	}
+
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+	// the provider found without addresses is known in a listed subnet
+	ps.AddAddrs("known", addr("/ip4/192.0.2.9/tcp/4001"), time.Hour)
+
+	rt := &ignoringContentRouting{
+		ContentRouting: &staticRouting{providers: []peer.AddrInfo{
+			{ID: listed},
+			{ID: "known"},
+			{ID: "farm", Addrs: addr("/ip4/198.51.100.1/tcp/4001")},
+			{ID: "good", Addrs: addr("/ip4/203.0.113.1/tcp/4001")},
+		}},
+		ignored: ignored,
+		ps:      ps,
+	}
+	var found []peer.ID
+	for ai := range rt.FindProvidersAsync(context.Background(), cid.Cid{}, 10) {
+		found = append(found, ai.ID)
+	}
+	require.Equal(t, []peer.ID{"good"}, found)
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+// bitswapPeerIdle is how long a peer without Bitswap traffic is remembered.
+const bitswapPeerIdle = time.Hour
+
+// BitswapPeerTraffic is the Bitswap traffic exchanged with one peer.
+type BitswapPeerTraffic struct {
+	Peer       peer.ID
+	SentBytes  uint64
+	RecvBytes  uint64
+	SentBlocks uint64
+	RecvBlocks uint64
+}
+
+type bitswapPeerCounters struct {
+	BitswapPeerTraffic
+	last time.Time
+}
+
+// BitswapPeerTracker counts Bitswap traffic per peer. Only the busiest peers
+// are reported, to bound the cardinality of the exported metrics.
+type BitswapPeerTracker struct {
+	topN int
+
+	mu    sync.Mutex
+	peers map[peer.ID]*bitswapPeerCounters
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (t *BitswapPeerTracker) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	t.record(p, msg, false)
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (t *BitswapPeerTracker) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	t.record(p, msg, true)
+}
+
+func (t *BitswapPeerTracker) record(p peer.ID, msg bsmsg.BitSwapMessage, sent bool) {
+	size := uint64(msg.Size())
+	blocks := uint64(len(msg.Blocks()))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.peers[p]
+	if !ok {
+		c = &bitswapPeerCounters{BitswapPeerTraffic: BitswapPeerTraffic{Peer: p}}
+		t.peers[p] = c
+	}
+	c.last = time.Now()
+	if sent {
+		c.SentBytes += size
+		c.SentBlocks += blocks
+	} else {
+		c.RecvBytes += size
+		c.RecvBlocks += blocks
+	}
+}
+
+// TopPeers returns the traffic of the peers that exchanged the most bytes,
+// at most Bitswap.PeerMetricsTopN of them.
+func (t *BitswapPeerTracker) TopPeers() []BitswapPeerTraffic {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-bitswapPeerIdle)
+	traffic := make([]BitswapPeerTraffic, 0, len(t.peers))
+	for p, c := range t.peers {
+		if c.last.Before(cutoff) {
+			delete(t.peers, p)
+			continue
+		}
+		traffic = append(traffic, c.BitswapPeerTraffic)
+	}
+
+	sort.Slice(traffic, func(i, j int) bool {
+		return traffic[i].SentBytes+traffic[i].RecvBytes > traffic[j].SentBytes+traffic[j].RecvBytes
+	})
+	if len(traffic) > t.topN {
+		traffic = traffic[:t.topN]
+	}
+	return traffic
+}
+
+type bitswapPeerMetricsOut struct {
+	fx.Out
+
+	Tracker *BitswapPeerTracker
+	Tracer  tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// BitswapPeerMetrics tracks per-peer Bitswap traffic for the topN busiest
+// peers. It is disabled when topN is 0.
+func BitswapPeerMetrics(topN int) fx.Option {
+	if topN <= 0 {
+		return fx.Options()
+	}
+	return fx.Provide(func() bitswapPeerMetricsOut {
+		t := &BitswapPeerTracker{
+			topN:  topN,
+			peers: make(map[peer.ID]*bitswapPeerCounters),
+		}
+		return bitswapPeerMetricsOut{Tracker: t, Tracer: t}
+	})
+}
@@ -0,0 +1,225 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	humanize "github.com/dustin/go-humanize"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/network"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mc "github.com/multiformats/go-multicodec"
+	"go.uber.org/fx"
+)
+
+// BitswapBlockPolicyStats counts the blocks rejected by Bitswap.BlockPolicy.
+type BitswapBlockPolicyStats struct {
+	// ReceivedOversized and ReceivedRejectedCodec are the received blocks
+	// dropped for their size and for their codec.
+	ReceivedOversized     uint64
+	ReceivedRejectedCodec uint64
+	// ServedOversized and ServedRejectedCodec are the blocks not served for
+	// their size and for their codec.
+	ServedOversized     uint64
+	ServedRejectedCodec uint64
+}
+
+type codecPolicy struct {
+	reject  bool
+	maxSize uint64
+}
+
+// BitswapBlockFilter applies Bitswap.BlockPolicy to the blocks received and
+// served on the public Bitswap network. The rejected blocks are replaced with
+// DONT_HAVE, which makes Bitswap sessions retry received ones with other peers
+// and providers.
+type BitswapBlockFilter struct {
+	// maxSize is the size limit of the codecs without one, 0 for none.
+	maxSize uint64
+	codecs  map[uint64]codecPolicy
+
+	receivedOversized     atomic.Uint64
+	receivedRejectedCodec atomic.Uint64
+	servedOversized       atomic.Uint64
+	servedRejectedCodec   atomic.Uint64
+}
+
+// BitswapBlockPolicy provides the BitswapBlockFilter of cfg, when set.
+func BitswapBlockPolicy(cfg *config.BitswapBlockPolicy) fx.Option {
+	if cfg == nil {
+		return fx.Options()
+	}
+	return fx.Provide(func() (*BitswapBlockFilter, error) {
+		return newBitswapBlockFilter(cfg)
+	})
+}
+
+func parseBlockSize(s *config.OptionalString, name string) (uint64, error) {
+	v := s.WithDefault("")
+	if v == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if size == 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", name)
+	}
+	return size, nil
+}
+
+func newBitswapBlockFilter(cfg *config.BitswapBlockPolicy) (*BitswapBlockFilter, error) {
+	maxSize, err := parseBlockSize(cfg.MaxBlockSize, "Bitswap.BlockPolicy.MaxBlockSize")
+	if err != nil {
+		return nil, err
+	}
+	f := &BitswapBlockFilter{
+		maxSize: maxSize,
+		codecs:  make(map[uint64]codecPolicy, len(cfg.Codecs)),
+	}
+	for name, rule := range cfg.Codecs {
+		var code mc.Code
+		if err := code.Set(name); err != nil {
+			return nil, fmt.Errorf("invalid Bitswap.BlockPolicy.Codecs: %w", err)
+		}
+		size, err := parseBlockSize(rule.MaxBlockSize, "Bitswap.BlockPolicy.Codecs."+name+".MaxBlockSize")
+		if err != nil {
+			return nil, err
+		}
+		f.codecs[uint64(code)] = codecPolicy{
+			reject:  rule.Reject.WithDefault(false),
+			maxSize: size,
+		}
+	}
+	return f, nil
+}
+
+// Stats returns the blocks rejected so far.
+func (f *BitswapBlockFilter) Stats() BitswapBlockPolicyStats {
+	return BitswapBlockPolicyStats{
+		ReceivedOversized:     f.receivedOversized.Load(),
+		ReceivedRejectedCodec: f.receivedRejectedCodec.Load(),
+		ServedOversized:       f.servedOversized.Load(),
+		ServedRejectedCodec:   f.servedRejectedCodec.Load(),
+	}
+}
+
+// check reports whether b is refused for its size or for its codec.
+func (f *BitswapBlockFilter) check(b blocks.Block) (oversized, rejectedCodec bool) {
+	limit := f.maxSize
+	if rule, ok := f.codecs[b.Cid().Prefix().Codec]; ok {
+		if rule.reject {
+			return false, true
+		}
+		if rule.maxSize > 0 {
+			limit = rule.maxSize
+		}
+	}
+	return limit > 0 && uint64(len(b.RawData())) > limit, false
+}
+
+// filter returns msg with the refused blocks replaced with DONT_HAVE, counting
+// them in oversized and rejectedCodec.
+func (f *BitswapBlockFilter) filter(msg bsmsg.BitSwapMessage, oversized, rejectedCodec *atomic.Uint64) bsmsg.BitSwapMessage {
+	blks := msg.Blocks()
+	var refused []cid.Cid
+	keep := make([]blocks.Block, 0, len(blks))
+	for _, b := range blks {
+		over, codec := f.check(b)
+		switch {
+		case over:
+			oversized.Add(1)
+		case codec:
+			rejectedCodec.Add(1)
+		default:
+			keep = append(keep, b)
+			continue
+		}
+		refused = append(refused, b.Cid())
+	}
+	if len(refused) == 0 {
+		return msg
+	}
+
+	out := bsmsg.New(msg.Full())
+	for _, e := range msg.Wantlist() {
+		if e.Cancel {
+			out.Cancel(e.Cid)
+		} else {
+			out.AddEntry(e.Cid, e.Priority, e.WantType, e.SendDontHave)
+		}
+	}
+	for _, b := range keep {
+		out.AddBlock(b)
+	}
+	for _, bp := range msg.BlockPresences() {
+		out.AddBlockPresence(bp.Cid, bp.Type)
+	}
+	for _, c := range refused {
+		out.AddDontHave(c)
+	}
+	out.SetPendingBytes(msg.PendingBytes())
+	return out
+}
+
+func (f *BitswapBlockFilter) receive(p peer.ID, msg bsmsg.BitSwapMessage) bsmsg.BitSwapMessage {
+	out := f.filter(msg, &f.receivedOversized, &f.receivedRejectedCodec)
+	if out != msg {
+		logger.Debugf("bitswap: dropped %d blocks from %s refused by Bitswap.BlockPolicy", len(msg.Blocks())-len(out.Blocks()), p)
+	}
+	return out
+}
+
+func (f *BitswapBlockFilter) send(msg bsmsg.BitSwapMessage) bsmsg.BitSwapMessage {
+	return f.filter(msg, &f.servedOversized, &f.servedRejectedCodec)
+}
+
+// policyNetwork applies a BitswapBlockFilter to the messages of a Bitswap
+// network.
+type policyNetwork struct {
+	network.BitSwapNetwork
+	filter *BitswapBlockFilter
+}
+
+func (n *policyNetwork) Start(receivers ...network.Receiver) {
+	wrapped := make([]network.Receiver, len(receivers))
+	for i, r := range receivers {
+		wrapped[i] = &policyReceiver{Receiver: r, filter: n.filter}
+	}
+	n.BitSwapNetwork.Start(wrapped...)
+}
+
+func (n *policyNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	return n.BitSwapNetwork.SendMessage(ctx, p, n.filter.send(msg))
+}
+
+func (n *policyNetwork) NewMessageSender(ctx context.Context, p peer.ID, opts *network.MessageSenderOpts) (network.MessageSender, error) {
+	s, err := n.BitSwapNetwork.NewMessageSender(ctx, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &policySender{MessageSender: s, filter: n.filter}, nil
+}
+
+type policySender struct {
+	network.MessageSender
+	filter *BitswapBlockFilter
+}
+
+func (s *policySender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	return s.MessageSender.SendMsg(ctx, s.filter.send(msg))
+}
+
+type policyReceiver struct {
+	network.Receiver
+	filter *BitswapBlockFilter
+}
+
+func (r *policyReceiver) ReceiveMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.Receiver.ReceiveMessage(ctx, p, r.filter.receive(p, msg))
+}
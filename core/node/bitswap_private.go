@@ -0,0 +1,292 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	"github.com/ipfs/boxo/bitswap/network"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	exchange "github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/peering"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+)
+
+// PrivateBitswap is the Bitswap instance of Bitswap.PrivateNetwork.
+type PrivateBitswap struct {
+	Host    host.Host
+	Bitswap *bitswap.Bitswap
+}
+
+type privateBitswapIn struct {
+	fx.In
+
+	Mctx      helpers.MetricsCtx
+	ID        peer.ID
+	Peerstore peerstore.Peerstore
+	Bs        blockstore.GCBlockstore
+}
+
+type privateBitswapOut struct {
+	fx.Out
+
+	Private  *PrivateBitswap
+	Exchange exchange.Interface `group:"bitswap-networks"`
+}
+
+// PrivateBitswapNetwork starts a second libp2p host in the private network of
+// cfg, using the node identity, and runs a Bitswap instance on it. Its
+// exchange is combined with the public one by OnlineExchange. The private
+// instance does not provide to any routing system: wants are sent to the
+// connected members of the network.
+func PrivateBitswapNetwork(cfg *config.BitswapPrivateNetwork, refreshInterval time.Duration) fx.Option {
+	if cfg == nil {
+		return fx.Options()
+	}
+	filter, serveOpt := bitswapServeFilter(cfg.ServeStrategy.WithDefault(config.DefaultBitswapServeStrategy), refreshInterval)
+
+	return fx.Options(
+		serveOpt,
+		fx.Provide(func(in privateBitswapIn, lc fx.Lifecycle) (privateBitswapOut, error) {
+			var out privateBitswapOut
+
+			swarmKey, err := os.Open(cfg.SwarmKeyFile)
+			if err != nil {
+				return out, fmt.Errorf("Bitswap.PrivateNetwork: %w", err)
+			}
+			psk, err := pnet.DecodeV1PSK(swarmKey)
+			swarmKey.Close()
+			if err != nil {
+				return out, fmt.Errorf("Bitswap.PrivateNetwork: decoding swarm key: %w", err)
+			}
+
+			h, err := libp2p.New(
+				libp2p.Identity(in.Peerstore.PrivKey(in.ID)),
+				libp2p.PrivateNetwork(psk),
+				libp2p.Transport(tcp.NewTCPTransport),
+				libp2p.ListenAddrStrings(cfg.ListenAddrs...),
+				libp2p.DisableRelay(),
+			)
+			if err != nil {
+				return out, fmt.Errorf("Bitswap.PrivateNetwork: %w", err)
+			}
+
+			opts := []bitswap.Option{bitswap.ProvideEnabled(false)}
+			if filter != nil {
+				opts = append(opts, bitswap.WithPeerBlockRequestFilter(filter))
+			}
+			bsnet := network.NewFromIpfsHost(h, routinghelpers.Null{})
+			bs := bitswap.New(helpers.LifecycleCtx(in.Mctx, lc), bsnet, in.Bs, opts...)
+
+			ps := peering.NewPeeringService(h)
+			for _, ai := range cfg.Peers {
+				ps.AddPeer(ai)
+			}
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					return ps.Start()
+				},
+				OnStop: func(context.Context) error {
+					ps.Stop()
+					return errors.Join(bs.Close(), h.Close())
+				},
+			})
+
+			out.Private = &PrivateBitswap{Host: h, Bitswap: bs}
+			out.Exchange = bs
+			return out, nil
+		}),
+	)
+}
+
+// PublicExchange returns the Bitswap exchange of the public network, which
+// exch may wrap or combine with other Bitswap networks. The wrappers of the
+// exchange are unwrapped with their Unwrap method.
+func PublicExchange(exch exchange.Interface) exchange.Interface {
+	for {
+		if _, ok := exch.(*bitswap.Bitswap); ok {
+			return exch
+		}
+		u, ok := exch.(interface{ Unwrap() exchange.Interface })
+		if !ok {
+			return exch
+		}
+		exch = u.Unwrap()
+	}
+}
+
+// multiExchange fetches blocks from several exchanges at once and uses the
+// first copy received. New blocks are announced to all of them.
+type multiExchange struct {
+	multiFetcher
+	exchanges []exchange.Interface
+}
+
+var _ exchange.SessionExchange = (*multiExchange)(nil)
+
+// Unwrap returns the exchange of the public network.
+func (m *multiExchange) Unwrap() exchange.Interface {
+	return m.exchanges[0]
+}
+
+func newMultiExchange(exchanges ...exchange.Interface) *multiExchange {
+	m := &multiExchange{exchanges: exchanges}
+	for _, e := range exchanges {
+		m.multiFetcher = append(m.multiFetcher, e)
+	}
+	return m
+}
+
+func (m *multiExchange) NotifyNewBlocks(ctx context.Context, blks ...blocks.Block) error {
+	var errs []error
+	for _, e := range m.exchanges {
+		errs = append(errs, e.NotifyNewBlocks(ctx, blks...))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *multiExchange) Close() error {
+	var errs []error
+	for _, e := range m.exchanges {
+		errs = append(errs, e.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (m *multiExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	sessions := make(multiFetcher, len(m.exchanges))
+	for i, e := range m.exchanges {
+		sessions[i] = e
+		if sessEx, ok := e.(exchange.SessionExchange); ok {
+			sessions[i] = sessEx.NewSession(ctx)
+		}
+	}
+	return sessions
+}
+
+// GetWantlist returns the wants of every network, for Bitswap.PersistWantlist.
+func (m *multiExchange) GetWantlist() []cid.Cid {
+	var wants []cid.Cid
+	seen := cid.NewSet()
+	for _, e := range m.exchanges {
+		wl, ok := e.(bitswapWantlister)
+		if !ok {
+			continue
+		}
+		for _, c := range wl.GetWantlist() {
+			if seen.Visit(c) {
+				wants = append(wants, c)
+			}
+		}
+	}
+	return wants
+}
+
+type multiFetcher []exchange.Fetcher
+
+func (fs multiFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		blk blocks.Block
+		err error
+	}
+	results := make(chan result, len(fs))
+	for _, f := range fs {
+		go func(f exchange.Fetcher) {
+			blk, err := f.GetBlock(ctx, c)
+			results <- result{blk, err}
+		}(f)
+	}
+
+	var errs []error
+	for range fs {
+		r := <-results
+		if r.err == nil {
+			return r.blk, nil
+		}
+		errs = append(errs, r.err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (fs multiFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	chans := make([]<-chan blocks.Block, 0, len(fs))
+	for _, f := range fs {
+		ch, err := f.GetBlocks(ctx, cids)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		chans = append(chans, ch)
+	}
+
+	received := make(chan blocks.Block)
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch <-chan blocks.Block) {
+			defer wg.Done()
+			for blk := range ch {
+				select {
+				case received <- blk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(received)
+	}()
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		pending := cid.NewSet()
+		for _, c := range cids {
+			pending.Add(c)
+		}
+		for pending.Len() > 0 {
+			select {
+			case blk, ok := <-received:
+				if !ok {
+					return
+				}
+				if !pending.Has(blk.Cid()) {
+					continue
+				}
+				pending.Remove(blk.Cid())
+				select {
+				case out <- blk:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,206 @@
+package node
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+// BitswapQueueStat is the backlog of the Bitswap server.
+type BitswapQueueStat struct {
+	Peers      int
+	Tasks      int
+	BlockTasks int
+	HaveTasks  int
+	// Bytes is the size of the blocks queued to be sent.
+	Bytes      uint64
+	Priorities []BitswapQueuePriority
+}
+
+// BitswapQueuePriority is the backlog of the tasks with the same want
+// priority. The engine serves higher priorities first.
+type BitswapQueuePriority struct {
+	Priority int32
+	Tasks    int
+	Bytes    uint64
+}
+
+type queuedWant struct {
+	priority     int32
+	wantBlock    bool
+	sendDontHave bool
+}
+
+// BitswapQueue follows the tasks of the Bitswap engine: the wants received
+// from peers that were not answered yet. The engine does not expose its queue,
+// so the tasks are derived from the messages exchanged with peers.
+type BitswapQueue struct {
+	bs blockstore.Blockstore
+
+	mu    sync.Mutex
+	tasks map[peer.ID]map[cid.Cid]queuedWant
+}
+
+type bitswapQueueOut struct {
+	fx.Out
+
+	Queue  *BitswapQueue
+	Tracer tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// BitswapQueueTracker provides the BitswapQueue reported by
+// 'ipfs bitswap queue'.
+func BitswapQueueTracker(h host.Host, bs blockstore.GCBlockstore) bitswapQueueOut {
+	q := newBitswapQueue(bs)
+	h.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			if n.Connectedness(c.RemotePeer()) != network.Connected {
+				q.forget(c.RemotePeer())
+			}
+		},
+	})
+	return bitswapQueueOut{Queue: q, Tracer: q}
+}
+
+func newBitswapQueue(bs blockstore.Blockstore) *BitswapQueue {
+	return &BitswapQueue{
+		bs:    bs,
+		tasks: make(map[peer.ID]map[cid.Cid]queuedWant),
+	}
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (q *BitswapQueue) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	wants := msg.Wantlist()
+	if len(wants) == 0 && !msg.Full() {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tasks := q.tasks[p]
+	if tasks == nil || msg.Full() {
+		tasks = make(map[cid.Cid]queuedWant)
+		q.tasks[p] = tasks
+	}
+	for _, e := range wants {
+		if e.Cancel {
+			delete(tasks, e.Cid)
+			continue
+		}
+		tasks[e.Cid] = queuedWant{
+			priority:     e.Priority,
+			wantBlock:    e.WantType == pb.Message_Wantlist_Block,
+			sendDontHave: e.SendDontHave,
+		}
+	}
+	if len(tasks) == 0 {
+		delete(q.tasks, p)
+	}
+}
+
+// MessageSent implements the bitswap tracer interface. Only the server sends
+// messages through the tracer.
+func (q *BitswapQueue) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tasks := q.tasks[p]
+	if tasks == nil {
+		return
+	}
+	for _, b := range msg.Blocks() {
+		delete(tasks, b.Cid())
+	}
+	for _, c := range msg.Haves() {
+		delete(tasks, c)
+	}
+	for _, c := range msg.DontHaves() {
+		delete(tasks, c)
+	}
+	if len(tasks) == 0 {
+		delete(q.tasks, p)
+	}
+}
+
+func (q *BitswapQueue) forget(p peer.ID) {
+	q.mu.Lock()
+	delete(q.tasks, p)
+	q.mu.Unlock()
+}
+
+// Stat returns the backlog of the server, with the maxPriorities highest
+// priorities. Like the engine, it skips the wants of missing blocks that do
+// not ask for a DONT_HAVE.
+func (q *BitswapQueue) Stat(ctx context.Context, maxPriorities int) (BitswapQueueStat, error) {
+	type task struct {
+		p peer.ID
+		c cid.Cid
+		queuedWant
+	}
+	q.mu.Lock()
+	var tasks []task
+	for p, ts := range q.tasks {
+		for c, w := range ts {
+			tasks = append(tasks, task{p, c, w})
+		}
+	}
+	q.mu.Unlock()
+
+	var stat BitswapQueueStat
+	peers := make(map[peer.ID]struct{})
+	priorities := make(map[int32]*BitswapQueuePriority)
+	for _, t := range tasks {
+		size, err := q.bs.GetSize(ctx, t.c)
+		switch {
+		case ipld.IsNotFound(err):
+			if !t.sendDontHave {
+				continue
+			}
+			size = 0
+		case err != nil:
+			return BitswapQueueStat{}, err
+		}
+
+		stat.Tasks++
+		peers[t.p] = struct{}{}
+		pr, ok := priorities[t.priority]
+		if !ok {
+			pr = &BitswapQueuePriority{Priority: t.priority}
+			priorities[t.priority] = pr
+		}
+		pr.Tasks++
+		if !t.wantBlock {
+			stat.HaveTasks++
+			continue
+		}
+		stat.BlockTasks++
+		stat.Bytes += uint64(size)
+		pr.Bytes += uint64(size)
+	}
+
+	stat.Peers = len(peers)
+	stat.Priorities = make([]BitswapQueuePriority, 0, len(priorities))
+	for _, pr := range priorities {
+		stat.Priorities = append(stat.Priorities, *pr)
+	}
+	sort.Slice(stat.Priorities, func(i, j int) bool {
+		return stat.Priorities[i].Priority > stat.Priorities[j].Priority
+	})
+	if len(stat.Priorities) > maxPriorities {
+		stat.Priorities = stat.Priorities[:maxPriorities]
+	}
+	return stat, nil
+}
@@ -0,0 +1,58 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapQueue(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	stored := blocks.NewBlock([]byte("stored"))
+	require.NoError(t, bs.Put(ctx, stored))
+	missing := blocks.NewBlock([]byte("missing"))
+	ignored := blocks.NewBlock([]byte("missing without DONT_HAVE"))
+
+	q := newBitswapQueue(bs)
+	a, b := peer.ID("a"), peer.ID("b")
+
+	wants := bsmsg.New(false)
+	wants.AddEntry(stored.Cid(), 5, pb.Message_Wantlist_Block, true)
+	wants.AddEntry(missing.Cid(), 3, pb.Message_Wantlist_Block, true)
+	wants.AddEntry(ignored.Cid(), 3, pb.Message_Wantlist_Block, false)
+	q.MessageReceived(a, wants)
+	haves := bsmsg.New(false)
+	haves.AddEntry(stored.Cid(), 5, pb.Message_Wantlist_Have, true)
+	q.MessageReceived(b, haves)
+
+	stat, err := q.Stat(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, stat.Peers)
+	require.Equal(t, 3, stat.Tasks)
+	require.Equal(t, 2, stat.BlockTasks)
+	require.Equal(t, 1, stat.HaveTasks)
+	require.EqualValues(t, len(stored.RawData()), stat.Bytes)
+	require.Equal(t, []BitswapQueuePriority{{Priority: 5, Tasks: 2, Bytes: uint64(len(stored.RawData()))}}, stat.Priorities)
+
+	sent := bsmsg.New(false)
+	sent.AddBlock(stored)
+	sent.AddDontHave(missing.Cid())
+	q.MessageSent(a, sent)
+	cancel := bsmsg.New(false)
+	cancel.Cancel(stored.Cid())
+	q.MessageReceived(b, cancel)
+
+	stat, err = q.Stat(ctx, 10)
+	require.NoError(t, err)
+	require.Zero(t, stat.Tasks)
+	require.Empty(t, stat.Priorities)
+}
@@ -0,0 +1,129 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+// maxRecordedCids bounds the memory used to count distinct wanted CIDs.
+const maxRecordedCids = 1 << 20
+
+// BitswapRecordedWants summarizes the wants received in record-only mode.
+type BitswapRecordedWants struct {
+	Since time.Time
+	Peers int
+	// UniqueCids stops growing after 2^20 distinct CIDs.
+	UniqueCids int
+	WantHaves  uint64
+	WantBlocks uint64
+	Cancels    uint64
+	// LocalWants counts the wants for blocks in the local blockstore, and
+	// LocalBytes the size of the blocks that would have been sent for them.
+	LocalWants uint64
+	LocalBytes uint64
+}
+
+// BitswapWantRecorder logs and counts the wants received from other peers
+// while Bitswap runs in record-only mode, in which no block is served.
+type BitswapWantRecorder struct {
+	bs blockstore.Blockstore
+
+	mu    sync.Mutex
+	stats BitswapRecordedWants
+	peers map[peer.ID]struct{}
+	cids  map[string]struct{}
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (r *BitswapWantRecorder) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	for _, e := range msg.Wantlist() {
+		var size int
+		if !e.Cancel {
+			s, err := r.bs.GetSize(context.Background(), e.Cid)
+			if err == nil {
+				size = s
+			}
+		}
+		logger.Debugw("recorded bitswap want", "peer", p, "cid", e.Cid, "type", e.WantType, "cancel", e.Cancel, "local", size > 0)
+		r.record(p, e, size)
+	}
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (r *BitswapWantRecorder) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+func (r *BitswapWantRecorder) record(p peer.ID, e bsmsg.Entry, size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.peers[p] = struct{}{}
+	if e.Cancel {
+		r.stats.Cancels++
+		return
+	}
+	if len(r.cids) < maxRecordedCids {
+		r.cids[string(e.Cid.Hash())] = struct{}{}
+	}
+	if e.WantType == pb.Message_Wantlist_Have {
+		r.stats.WantHaves++
+	} else {
+		r.stats.WantBlocks++
+	}
+	if size > 0 {
+		r.stats.LocalWants++
+		if e.WantType == pb.Message_Wantlist_Block {
+			r.stats.LocalBytes += uint64(size)
+		}
+	}
+}
+
+// Stats returns the wants recorded since the daemon started.
+func (r *BitswapWantRecorder) Stats() BitswapRecordedWants {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.stats
+	st.Peers = len(r.peers)
+	st.UniqueCids = len(r.cids)
+	return st
+}
+
+type bitswapRecordOnlyOut struct {
+	fx.Out
+
+	Recorder    *BitswapWantRecorder
+	Tracer      tracer.Tracer    `group:"bitswap-tracers"`
+	BitswapOpts []bitswap.Option `group:"bitswap-options,flatten"`
+}
+
+// BitswapRecordOnly runs the Bitswap server in record-only mode: wants are
+// recorded, every block is treated as missing so none is sent, and the client
+// keeps fetching as usual.
+func BitswapRecordOnly(enabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	return fx.Provide(func(bs blockstore.Blockstore) bitswapRecordOnlyOut {
+		r := &BitswapWantRecorder{
+			bs:    bs,
+			stats: BitswapRecordedWants{Since: time.Now()},
+			peers: make(map[peer.ID]struct{}),
+			cids:  make(map[string]struct{}),
+		}
+		deny := func(peer.ID, cid.Cid) bool { return false }
+		return bitswapRecordOnlyOut{
+			Recorder:    r,
+			Tracer:      r,
+			BitswapOpts: []bitswap.Option{bitswap.WithPeerBlockRequestFilter(deny)},
+		}
+	})
+}
@@ -0,0 +1,425 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+)
+
+var bitswapReputationKey = datastore.NewKey("/local/bitswap/reputation")
+
+const (
+	// reputationSnapshotInterval is how often the reputations are saved while
+	// the node runs.
+	reputationSnapshotInterval = time.Minute
+	// maxReputationPeers is the number of peers whose reputation is kept, the
+	// most recently seen ones.
+	maxReputationPeers = 4096
+	// neutralReputation is the score of a peer without history. Only peers
+	// scored worse are penalized.
+	neutralReputation = 0.5
+	// reputationLatencyWeight is the weight of the latest latency in the
+	// moving average of a peer.
+	reputationLatencyWeight = 0.2
+)
+
+// PeerReputation is the track record of a peer on the public Bitswap network.
+type PeerReputation struct {
+	Peer peer.ID
+	// Delivered are the blocks received for wants sent to the peer, and
+	// Failed the want-blocks it answered with DONT_HAVE.
+	Delivered uint64
+	Failed    uint64
+	// Garbage are the blocks received that were not requested from the peer.
+	Garbage uint64
+	// Latency is the moving average of the time from a want to its block.
+	Latency  time.Duration
+	LastSeen time.Time
+	// Score is between 0 and 1, neutralReputation for a peer without
+	// history.
+	Score float64
+}
+
+// score is the delivery success rate of the peer, reduced by its garbage rate
+// and latency.
+func (r *PeerReputation) score() float64 {
+	success := float64(r.Delivered+1) / float64(r.Delivered+r.Failed+2)
+	garbage := float64(r.Garbage) / float64(r.Garbage+r.Delivered+1)
+	return 2 * neutralReputation * success * (1 - garbage) / (1 + r.Latency.Seconds())
+}
+
+type sentWant struct {
+	at        time.Time
+	block     bool
+	cancelled bool
+}
+
+type peerWants struct {
+	wants   map[cid.Cid]sentWant
+	pruneAt int
+}
+
+// BitswapReputation scores the peers of the public Bitswap network on their
+// block delivery success rate, latency and garbage rate. The HAVE responses
+// and provider records of the peers scored worse than a new peer are held
+// back, longer the worse the score, so Bitswap sessions pick better peers when
+// there are some.
+type BitswapReputation struct {
+	mu         sync.Mutex
+	peers      map[peer.ID]*PeerReputation
+	wants      map[peer.ID]*peerWants
+	maxPenalty time.Duration
+}
+
+func newBitswapReputation(maxPenalty time.Duration) *BitswapReputation {
+	return &BitswapReputation{
+		peers:      make(map[peer.ID]*PeerReputation),
+		wants:      make(map[peer.ID]*peerWants),
+		maxPenalty: maxPenalty,
+	}
+}
+
+// BitswapPeerReputation provides the BitswapReputation when
+// Bitswap.Reputation is enabled. The reputations are saved in the datastore
+// while the node runs and on shutdown.
+func BitswapPeerReputation(cfg *config.BitswapReputation) fx.Option {
+	if cfg == nil || !cfg.Enabled.WithDefault(config.DefaultBitswapReputationEnabled) {
+		return fx.Options()
+	}
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo) (*BitswapReputation, error) {
+		maxPenalty := cfg.MaxPenalty.WithDefault(config.DefaultBitswapReputationMaxPenalty)
+		if maxPenalty < 0 {
+			return nil, errors.New("invalid Bitswap.Reputation.MaxPenalty: must not be negative")
+		}
+		r := newBitswapReputation(maxPenalty)
+		ds := repo.Datastore()
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		lc.Append(fx.Hook{
+			OnStart: func(startCtx context.Context) error {
+				if err := r.load(startCtx, ds); err != nil {
+					return fmt.Errorf("loading bitswap reputations: %w", err)
+				}
+				go func() {
+					ticker := time.NewTicker(reputationSnapshotInterval)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ticker.C:
+							if err := r.save(ctx, ds); err != nil && ctx.Err() == nil {
+								logger.Errorf("saving bitswap reputations: %s", err)
+							}
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+				return nil
+			},
+			OnStop: func(stopCtx context.Context) error {
+				return r.save(stopCtx, ds)
+			},
+		})
+		return r, nil
+	})
+}
+
+// Reputations returns the reputations of the known peers, best scored first.
+func (r *BitswapReputation) Reputations() []PeerReputation {
+	r.mu.Lock()
+	out := make([]PeerReputation, 0, len(r.peers))
+	for _, rep := range r.peers {
+		e := *rep
+		e.Score = rep.score()
+		out = append(out, e)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Peer < out[j].Peer
+	})
+	return out
+}
+
+// penalty is how long the responses of p are held back.
+func (r *BitswapReputation) penalty(p peer.ID) time.Duration {
+	r.mu.Lock()
+	rep, ok := r.peers[p]
+	var score float64
+	if ok {
+		score = rep.score()
+	}
+	r.mu.Unlock()
+
+	if !ok || score >= neutralReputation {
+		return 0
+	}
+	return time.Duration(float64(r.maxPenalty) * (1 - score/neutralReputation))
+}
+
+func (r *BitswapReputation) peerLocked(p peer.ID, now time.Time) *PeerReputation {
+	rep, ok := r.peers[p]
+	if !ok {
+		rep = &PeerReputation{Peer: p}
+		r.peers[p] = rep
+	}
+	rep.LastSeen = now
+	return rep
+}
+
+// sent records the wants of msg sent to p.
+func (r *BitswapReputation) sent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	entries := msg.Wantlist()
+	if len(entries) == 0 {
+		return
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pw, ok := r.wants[p]
+	if !ok {
+		pw = &peerWants{wants: make(map[cid.Cid]sentWant)}
+		r.wants[p] = pw
+	}
+	for _, e := range entries {
+		if e.Cancel {
+			if w, ok := pw.wants[e.Cid]; ok {
+				pw.wants[e.Cid] = sentWant{at: now, block: w.block, cancelled: true}
+			}
+			continue
+		}
+		pw.wants[e.Cid] = sentWant{at: now, block: e.WantType == pb.Message_Wantlist_Block}
+	}
+	if len(pw.wants) > max(pw.pruneAt, minAskedPrune) {
+		for c, w := range pw.wants {
+			if w.cancelled && now.Sub(w.at) >= bitswapCancelGrace {
+				delete(pw.wants, c)
+			}
+		}
+		pw.pruneAt = 2 * len(pw.wants)
+	}
+}
+
+// received scores p on the blocks and DONT_HAVEs of msg.
+func (r *BitswapReputation) received(p peer.ID, msg bsmsg.BitSwapMessage) {
+	blks := msg.Blocks()
+	dontHaves := msg.DontHaves()
+	if len(blks) == 0 && len(dontHaves) == 0 {
+		return
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.peerLocked(p, now)
+	var wants map[cid.Cid]sentWant
+	if pw, ok := r.wants[p]; ok {
+		wants = pw.wants
+	}
+	for _, b := range blks {
+		w, ok := wants[b.Cid()]
+		switch {
+		case !ok:
+			rep.Garbage++
+		case w.cancelled:
+			// The block crossed the cancel, or came too late.
+			if now.Sub(w.at) >= bitswapCancelGrace {
+				rep.Garbage++
+			}
+		default:
+			rep.Delivered++
+			d := now.Sub(w.at)
+			if rep.Latency == 0 {
+				rep.Latency = d
+			} else {
+				rep.Latency += time.Duration(reputationLatencyWeight * float64(d-rep.Latency))
+			}
+		}
+		delete(wants, b.Cid())
+	}
+	for _, c := range dontHaves {
+		if w, ok := wants[c]; ok && w.block && !w.cancelled {
+			rep.Failed++
+			delete(wants, c)
+		}
+	}
+}
+
+func (r *BitswapReputation) disconnected(p peer.ID) {
+	r.mu.Lock()
+	delete(r.wants, p)
+	r.mu.Unlock()
+}
+
+func (r *BitswapReputation) load(ctx context.Context, ds datastore.Datastore) error {
+	val, err := ds.Get(ctx, bitswapReputationKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []PeerReputation
+	if err := json.Unmarshal(val, &saved); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range saved {
+		rep := saved[i]
+		r.peers[rep.Peer] = &rep
+	}
+	return nil
+}
+
+// save persists the reputations of the maxReputationPeers most recently seen
+// peers, and forgets the others.
+func (r *BitswapReputation) save(ctx context.Context, ds datastore.Datastore) error {
+	r.mu.Lock()
+	saved := make([]PeerReputation, 0, len(r.peers))
+	for _, rep := range r.peers {
+		saved = append(saved, *rep)
+	}
+	if len(saved) > maxReputationPeers {
+		sort.Slice(saved, func(i, j int) bool { return saved[i].LastSeen.After(saved[j].LastSeen) })
+		for _, rep := range saved[maxReputationPeers:] {
+			delete(r.peers, rep.Peer)
+		}
+		saved = saved[:maxReputationPeers]
+	}
+	r.mu.Unlock()
+
+	val, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return ds.Put(ctx, bitswapReputationKey, val)
+}
+
+// reputationNetwork scores the peers of a Bitswap network with a
+// BitswapReputation, and holds back the HAVEs of the poorly scored ones.
+type reputationNetwork struct {
+	network.BitSwapNetwork
+	reputation *BitswapReputation
+}
+
+func (n *reputationNetwork) Start(receivers ...network.Receiver) {
+	wrapped := make([]network.Receiver, len(receivers))
+	for i, r := range receivers {
+		wrapped[i] = &reputationReceiver{Receiver: r, reputation: n.reputation}
+	}
+	n.BitSwapNetwork.Start(wrapped...)
+}
+
+func (n *reputationNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	n.reputation.sent(p, msg)
+	return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+func (n *reputationNetwork) NewMessageSender(ctx context.Context, p peer.ID, opts *network.MessageSenderOpts) (network.MessageSender, error) {
+	s, err := n.BitSwapNetwork.NewMessageSender(ctx, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &reputationSender{MessageSender: s, reputation: n.reputation, peer: p}, nil
+}
+
+type reputationSender struct {
+	network.MessageSender
+	reputation *BitswapReputation
+	peer       peer.ID
+}
+
+func (s *reputationSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	s.reputation.sent(s.peer, msg)
+	return s.MessageSender.SendMsg(ctx, msg)
+}
+
+type reputationReceiver struct {
+	network.Receiver
+	reputation *BitswapReputation
+}
+
+func (r *reputationReceiver) ReceiveMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.reputation.received(p, msg)
+	if len(msg.Blocks()) == 0 && len(msg.Haves()) > 0 {
+		if d := r.reputation.penalty(p); d > 0 {
+			time.AfterFunc(d, func() { r.Receiver.ReceiveMessage(ctx, p, msg) })
+			return
+		}
+	}
+	r.Receiver.ReceiveMessage(ctx, p, msg)
+}
+
+func (r *reputationReceiver) PeerDisconnected(p peer.ID) {
+	r.reputation.disconnected(p)
+	r.Receiver.PeerDisconnected(p)
+}
+
+// reputationContentRouting holds back the providers found for Bitswap that
+// are poorly scored, until their penalty elapses or the query ends.
+type reputationContentRouting struct {
+	routing.ContentRouting
+	reputation *BitswapReputation
+}
+
+func (r *reputationContentRouting) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	in := r.ContentRouting.FindProvidersAsync(ctx, c, count)
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		var held sync.WaitGroup
+		ended := make(chan struct{})
+		for ai := range in {
+			d := r.reputation.penalty(ai.ID)
+			if d == 0 {
+				select {
+				case out <- ai:
+				case <-ctx.Done():
+				}
+				continue
+			}
+			held.Add(1)
+			go func(ai peer.AddrInfo) {
+				defer held.Done()
+				t := time.NewTimer(d)
+				defer t.Stop()
+				select {
+				case <-t.C:
+				case <-ended:
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case out <- ai:
+				case <-ctx.Done():
+				}
+			}(ai)
+		}
+		close(ended)
+		held.Wait()
+	}()
+	return out
+}
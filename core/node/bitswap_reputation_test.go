@@ -0,0 +1,62 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapReputation(t *testing.T) {
+	ctx := context.Background()
+	inner := &fakeBitswapNetwork{}
+	r := newBitswapReputation(time.Second)
+	net := &reputationNetwork{BitSwapNetwork: inner, reputation: r}
+	recv := &recordingReceiver{}
+	net.Start(recv)
+	deliver := inner.receivers[0]
+
+	good, bad := peer.ID("good"), peer.ID("bad")
+	want := func(p peer.ID, blks ...blocks.Block) {
+		sender, err := net.NewMessageSender(ctx, p, nil)
+		require.NoError(t, err)
+		msg := bsmsg.New(false)
+		for _, b := range blks {
+			msg.AddEntry(b.Cid(), 1, pb.Message_Wantlist_Block, true)
+		}
+		require.NoError(t, sender.SendMsg(ctx, msg))
+	}
+	send := func(p peer.ID, blks ...blocks.Block) {
+		msg := bsmsg.New(false)
+		for _, b := range blks {
+			msg.AddBlock(b)
+		}
+		deliver.ReceiveMessage(ctx, p, msg)
+	}
+
+	requested := blocks.NewBlock([]byte("requested"))
+	want(good, requested)
+	send(good, requested)
+	send(bad, blocks.NewBlock([]byte("garbage")), blocks.NewBlock([]byte("more garbage")))
+
+	reps := r.Reputations()
+	require.Len(t, reps, 2)
+	require.Equal(t, good, reps[0].Peer, "best scored first")
+	require.EqualValues(t, 1, reps[0].Delivered)
+	require.EqualValues(t, 2, reps[1].Garbage)
+	require.Zero(t, r.penalty(good))
+	require.Zero(t, r.penalty(peer.ID("new")), "new peers are not penalized")
+	require.Positive(t, r.penalty(bad))
+
+	have := bsmsg.New(false)
+	have.AddHave(requested.Cid())
+	received := recv.count()
+	deliver.ReceiveMessage(ctx, bad, have)
+	require.Equal(t, received, recv.count(), "HAVEs of poorly scored peers are held back")
+	require.Eventually(t, func() bool { return recv.count() > received }, 2*time.Second, 10*time.Millisecond)
+}
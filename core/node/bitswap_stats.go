@@ -0,0 +1,573 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	exchange "github.com/ipfs/boxo/exchange"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"go.uber.org/fx"
+)
+
+const (
+	// bitswapDupWindow is how long after its first copy a block received
+	// again counts as a duplicate of a session.
+	bitswapDupWindow = time.Minute
+	// bitswapRecentSessions is the number of sessions reported.
+	bitswapRecentSessions = 20
+	// maxPendingWants bounds the requested blocks tracked for latencies.
+	maxPendingWants = 1 << 20
+)
+
+// bitswapLatencyBounds are the upper bounds of the histogram buckets.
+var bitswapLatencyBounds = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// BitswapHistogramBucket counts the durations up to UpperBound and above the
+// bound of the previous bucket. The last bucket has no upper bound and its
+// UpperBound is 0.
+type BitswapHistogramBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// BitswapHistogram is a distribution of durations.
+type BitswapHistogram struct {
+	Count   uint64
+	Sum     time.Duration
+	Buckets []BitswapHistogramBucket
+}
+
+func newBitswapHistogram() BitswapHistogram {
+	h := BitswapHistogram{Buckets: make([]BitswapHistogramBucket, len(bitswapLatencyBounds)+1)}
+	for i, b := range bitswapLatencyBounds {
+		h.Buckets[i].UpperBound = b
+	}
+	return h
+}
+
+func (h *BitswapHistogram) observe(d time.Duration) {
+	h.Count++
+	h.Sum += d
+	i := sort.Search(len(bitswapLatencyBounds), func(i int) bool { return d <= bitswapLatencyBounds[i] })
+	h.Buckets[i].Count++
+}
+
+func (h BitswapHistogram) clone() BitswapHistogram {
+	h.Buckets = append([]BitswapHistogramBucket(nil), h.Buckets...)
+	return h
+}
+
+// BitswapSessionStats counts the blocks a Bitswap session received.
+type BitswapSessionStats struct {
+	ID      uint64
+	Started time.Time
+	Active  bool
+	// Root is the first block requested by the session.
+	Root cid.Cid
+	// Wanted is the number of blocks requested by the session and not
+	// received yet.
+	Wanted int
+	// Peers is the number of peers that sent blocks to the session.
+	Peers  int
+	Blocks uint64
+	// DupBlocks are the copies of its blocks received after the first one.
+	DupBlocks uint64
+}
+
+// BitswapClientStats are the statistics collected on the Bitswap client.
+type BitswapClientStats struct {
+	// BlockLatency is the time from the first request of a block to the
+	// exchange to its receipt.
+	BlockLatency BitswapHistogram
+	// ProviderQueryFirst is the time provider queries took to find their
+	// first provider, and ProviderQueryDuration the time until they ended.
+	ProviderQueryFirst    BitswapHistogram
+	ProviderQueryDuration BitswapHistogram
+	// ProviderQueriesEmpty counts the provider queries that found no
+	// provider.
+	ProviderQueriesEmpty uint64
+	// Sessions are the most recent sessions that received blocks.
+	Sessions []BitswapSessionStats
+}
+
+type bitswapReceived struct {
+	at       time.Time
+	sessions []*BitswapSessionStats
+}
+
+// BitswapStatsCollector collects the latencies of block and provider
+// requests of the Bitswap client, and the duplicate blocks of its sessions.
+type BitswapStatsCollector struct {
+	mu    sync.Mutex
+	stats BitswapClientStats
+
+	pending  map[cid.Cid]time.Time
+	received map[cid.Cid]*bitswapReceived
+	// receivedOrder lists received in order, to forget them after
+	// bitswapDupWindow.
+	receivedOrder []cid.Cid
+
+	lastSession  uint64
+	sessions     map[uint64]*BitswapSessionStats
+	sessionWants map[cid.Cid][]*BitswapSessionStats
+	// outstanding are the wanted blocks of each active session.
+	outstanding map[uint64]map[cid.Cid]struct{}
+	// sessionPeers are the peers that sent blocks to each active session.
+	sessionPeers map[uint64]map[peer.ID]struct{}
+	cancels      map[uint64]context.CancelFunc
+	ended        []*BitswapSessionStats
+
+	// requests are the block requests made outside of sessions.
+	lastRequest uint64
+	requests    map[uint64]bitswapRequest
+}
+
+type bitswapRequest struct {
+	cids   []cid.Cid
+	cancel context.CancelFunc
+}
+
+func newBitswapStatsCollector() *BitswapStatsCollector {
+	return &BitswapStatsCollector{
+		stats: BitswapClientStats{
+			BlockLatency:          newBitswapHistogram(),
+			ProviderQueryFirst:    newBitswapHistogram(),
+			ProviderQueryDuration: newBitswapHistogram(),
+		},
+		pending:      make(map[cid.Cid]time.Time),
+		received:     make(map[cid.Cid]*bitswapReceived),
+		sessions:     make(map[uint64]*BitswapSessionStats),
+		sessionWants: make(map[cid.Cid][]*BitswapSessionStats),
+		outstanding:  make(map[uint64]map[cid.Cid]struct{}),
+		sessionPeers: make(map[uint64]map[peer.ID]struct{}),
+		cancels:      make(map[uint64]context.CancelFunc),
+		requests:     make(map[uint64]bitswapRequest),
+	}
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (c *BitswapStatsCollector) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+// want records when blocks were first requested from the exchange.
+func (c *BitswapStatsCollector) want(cids ...cid.Cid) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range cids {
+		if _, ok := c.pending[k]; !ok && len(c.pending) < maxPendingWants {
+			c.pending[k] = now
+		}
+	}
+}
+
+// newRequest records a request for cids outside of sessions, which cancel
+// ends.
+func (c *BitswapStatsCollector) newRequest(cancel context.CancelFunc, cids ...cid.Cid) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastRequest++
+	c.requests[c.lastRequest] = bitswapRequest{cids: cids, cancel: cancel}
+	return c.lastRequest
+}
+
+func (c *BitswapStatsCollector) endRequest(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.requests, id)
+}
+
+// unwant forgets the blocks of a request that ended, if no session still
+// wants them.
+func (c *BitswapStatsCollector) unwant(cids ...cid.Cid) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range cids {
+		if len(c.sessionWants[k]) == 0 {
+			delete(c.pending, k)
+		}
+	}
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (c *BitswapStatsCollector) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	blks := msg.Blocks()
+	if len(blks) == 0 {
+		return
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forgetReceived(now)
+	for _, b := range blks {
+		k := b.Cid()
+		if r, ok := c.received[k]; ok {
+			for _, s := range r.sessions {
+				s.DupBlocks++
+				c.sessionPeer(s, p)
+			}
+			continue
+		}
+		if at, ok := c.pending[k]; ok {
+			c.stats.BlockLatency.observe(now.Sub(at))
+			delete(c.pending, k)
+		}
+		sessions := c.sessionWants[k]
+		delete(c.sessionWants, k)
+		for _, s := range sessions {
+			s.Blocks++
+			delete(c.outstanding[s.ID], k)
+			c.sessionPeer(s, p)
+		}
+		c.received[k] = &bitswapReceived{at: now, sessions: sessions}
+		c.receivedOrder = append(c.receivedOrder, k)
+	}
+}
+
+func (c *BitswapStatsCollector) sessionPeer(s *BitswapSessionStats, p peer.ID) {
+	peers, ok := c.sessionPeers[s.ID]
+	if !ok {
+		return
+	}
+	if _, ok := peers[p]; !ok {
+		peers[p] = struct{}{}
+		s.Peers++
+	}
+}
+
+func (c *BitswapStatsCollector) forgetReceived(now time.Time) {
+	var i int
+	for ; i < len(c.receivedOrder); i++ {
+		k := c.receivedOrder[i]
+		if now.Sub(c.received[k].at) < bitswapDupWindow {
+			break
+		}
+		delete(c.received, k)
+	}
+	c.receivedOrder = c.receivedOrder[i:]
+}
+
+// newSession records a session, which cancel ends.
+func (c *BitswapStatsCollector) newSession(cancel context.CancelFunc) *BitswapSessionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSession++
+	s := &BitswapSessionStats{ID: c.lastSession, Started: time.Now(), Active: true}
+	c.sessions[s.ID] = s
+	c.outstanding[s.ID] = make(map[cid.Cid]struct{})
+	c.sessionPeers[s.ID] = make(map[peer.ID]struct{})
+	c.cancels[s.ID] = cancel
+	return s
+}
+
+func (c *BitswapStatsCollector) sessionWant(s *BitswapSessionStats, cids ...cid.Cid) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, ok := c.outstanding[s.ID]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	for _, k := range cids {
+		if !s.Root.Defined() {
+			s.Root = k
+		}
+		if _, ok := out[k]; ok {
+			continue
+		}
+		out[k] = struct{}{}
+		c.sessionWants[k] = append(c.sessionWants[k], s)
+		if _, ok := c.pending[k]; !ok && len(c.pending) < maxPendingWants {
+			c.pending[k] = now
+		}
+	}
+}
+
+func (c *BitswapStatsCollector) endSession(s *BitswapSessionStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.outstanding[s.ID] {
+		sessions := c.sessionWants[k]
+		for i, ws := range sessions {
+			if ws == s {
+				sessions = append(sessions[:i], sessions[i+1:]...)
+				break
+			}
+		}
+		if len(sessions) == 0 {
+			delete(c.sessionWants, k)
+			delete(c.pending, k)
+		} else {
+			c.sessionWants[k] = sessions
+		}
+	}
+	if cancel, ok := c.cancels[s.ID]; ok {
+		cancel()
+	}
+	delete(c.outstanding, s.ID)
+	delete(c.sessionPeers, s.ID)
+	delete(c.cancels, s.ID)
+	delete(c.sessions, s.ID)
+	s.Active = false
+	if s.Blocks > 0 {
+		c.ended = append(c.ended, s)
+		if len(c.ended) > bitswapRecentSessions {
+			c.ended = c.ended[1:]
+		}
+	}
+}
+
+func (c *BitswapStatsCollector) providerQuery(first, duration time.Duration, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if found {
+		c.stats.ProviderQueryFirst.observe(first)
+	} else {
+		c.stats.ProviderQueriesEmpty++
+	}
+	c.stats.ProviderQueryDuration.observe(duration)
+}
+
+// Stats returns the statistics collected so far.
+func (c *BitswapStatsCollector) Stats() BitswapClientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := BitswapClientStats{
+		BlockLatency:          c.stats.BlockLatency.clone(),
+		ProviderQueryFirst:    c.stats.ProviderQueryFirst.clone(),
+		ProviderQueryDuration: c.stats.ProviderQueryDuration.clone(),
+		ProviderQueriesEmpty:  c.stats.ProviderQueriesEmpty,
+	}
+	for _, s := range c.ended {
+		st.Sessions = append(st.Sessions, *s)
+	}
+	for _, s := range c.sessions {
+		if s.Blocks > 0 {
+			st.Sessions = append(st.Sessions, c.snapshot(s))
+		}
+	}
+	sort.Slice(st.Sessions, func(i, j int) bool { return st.Sessions[i].ID > st.Sessions[j].ID })
+	if len(st.Sessions) > bitswapRecentSessions {
+		st.Sessions = st.Sessions[:bitswapRecentSessions]
+	}
+	return st
+}
+
+func (c *BitswapStatsCollector) snapshot(s *BitswapSessionStats) BitswapSessionStats {
+	st := *s
+	st.Wanted = len(c.outstanding[s.ID])
+	return st
+}
+
+// ActiveSessions returns the sessions that have not ended, oldest first.
+func (c *BitswapStatsCollector) ActiveSessions() []BitswapSessionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessions := make([]BitswapSessionStats, 0, len(c.sessions))
+	for _, s := range c.sessions {
+		sessions = append(sessions, c.snapshot(s))
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// CancelSession ends the active session id: its pending requests fail and
+// its wants are cancelled.
+func (c *BitswapStatsCollector) CancelSession(id uint64) error {
+	c.mu.Lock()
+	cancel, ok := c.cancels[id]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no active bitswap session %d", id)
+	}
+	cancel()
+	return nil
+}
+
+// CancelRetrievals ends the active sessions whose first requested block is
+// root, and the requests for root made outside of sessions. It returns the
+// number of sessions and requests ended.
+func (c *BitswapStatsCollector) CancelRetrievals(root cid.Cid) int {
+	var cancels []context.CancelFunc
+	c.mu.Lock()
+	for id, s := range c.sessions {
+		if s.Root.Equals(root) {
+			cancels = append(cancels, c.cancels[id])
+		}
+	}
+	for _, r := range c.requests {
+		for _, k := range r.cids {
+			if k.Equals(root) {
+				cancels = append(cancels, r.cancel)
+				break
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return len(cancels)
+}
+
+// statsExchange times the block requests made to the wrapped Bitswap
+// exchange and attributes the blocks fetched to its sessions.
+type statsExchange struct {
+	exchange.Interface
+	stats *BitswapStatsCollector
+}
+
+var _ exchange.SessionExchange = (*statsExchange)(nil)
+
+// Unwrap returns the wrapped exchange.
+func (e *statsExchange) Unwrap() exchange.Interface {
+	return e.Interface
+}
+
+func (e *statsExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	e.stats.want(c)
+	defer e.stats.unwant(c)
+	defer e.stats.endRequest(e.stats.newRequest(cancel, c))
+	return e.Interface.GetBlock(ctx, c)
+}
+
+func (e *statsExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	e.stats.want(cids...)
+	id := e.stats.newRequest(cancel, cids...)
+	end := func() {
+		e.stats.endRequest(id)
+		e.stats.unwant(cids...)
+		cancel()
+	}
+
+	in, err := e.Interface.GetBlocks(ctx, cids)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer end()
+		for b := range in {
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				// Drain so the request can end.
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e *statsExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	ctx, cancel := context.WithCancel(ctx)
+	var f exchange.Fetcher = e.Interface
+	if sessEx, ok := e.Interface.(exchange.SessionExchange); ok {
+		f = sessEx.NewSession(ctx)
+	}
+	s := e.stats.newSession(cancel)
+	context.AfterFunc(ctx, func() { e.stats.endSession(s) })
+	return &statsFetcher{Fetcher: f, stats: e.stats, session: s}
+}
+
+// GetWantlist returns the wants of the wrapped exchange, for
+// Bitswap.PersistWantlist.
+func (e *statsExchange) GetWantlist() []cid.Cid {
+	if wl, ok := e.Interface.(bitswapWantlister); ok {
+		return wl.GetWantlist()
+	}
+	return nil
+}
+
+type statsFetcher struct {
+	exchange.Fetcher
+	stats   *BitswapStatsCollector
+	session *BitswapSessionStats
+}
+
+func (f *statsFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	f.stats.sessionWant(f.session, c)
+	return f.Fetcher.GetBlock(ctx, c)
+}
+
+func (f *statsFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	f.stats.sessionWant(f.session, cids...)
+	return f.Fetcher.GetBlocks(ctx, cids)
+}
+
+// statsContentRouting times the provider queries made by Bitswap.
+type statsContentRouting struct {
+	routing.ContentRouting
+	stats *BitswapStatsCollector
+}
+
+func (r *statsContentRouting) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	start := time.Now()
+	in := r.ContentRouting.FindProvidersAsync(ctx, c, count)
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		var first time.Duration
+		found := false
+		for p := range in {
+			if !found {
+				first = time.Since(start)
+				found = true
+			}
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				// Drain so the query can end.
+				for range in {
+				}
+				r.stats.providerQuery(first, time.Since(start), found)
+				return
+			}
+		}
+		r.stats.providerQuery(first, time.Since(start), found)
+	}()
+	return out
+}
+
+type bitswapStatsOut struct {
+	fx.Out
+
+	Collector *BitswapStatsCollector
+	Tracer    tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// BitswapStats collects the statistics of the Bitswap client reported by
+// 'ipfs bitswap stat --verbose'.
+func BitswapStats() bitswapStatsOut {
+	c := newBitswapStatsCollector()
+	return bitswapStatsOut{Collector: c, Tracer: c}
+}
@@ -0,0 +1,135 @@
+package node
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testinstance "github.com/ipfs/boxo/bitswap/testinstance"
+	tn "github.com/ipfs/boxo/bitswap/testnet"
+	mockrouting "github.com/ipfs/boxo/routing/mock"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	delay "github.com/ipfs/go-ipfs-delay"
+	"github.com/ipfs/kubo/config"
+	tnet "github.com/libp2p/go-libp2p-testing/net"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingRouting answers provider queries once they are cancelled.
+type blockingRouting struct {
+	routing.ContentRouting
+	count   atomic.Int64
+	running atomic.Int64
+	peak    atomic.Int64
+}
+
+func (r *blockingRouting) FindProvidersAsync(ctx context.Context, _ cid.Cid, count int) <-chan peer.AddrInfo {
+	r.count.Store(int64(count))
+	n := r.running.Add(1)
+	for {
+		peak := r.peak.Load()
+		if n <= peak || r.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		defer r.running.Add(-1)
+		<-ctx.Done()
+	}()
+	return out
+}
+
+func TestLimitProviderQueries(t *testing.T) {
+	t.Run("defaults leave the routing as is", func(t *testing.T) {
+		rt := &blockingRouting{}
+		limited, err := limitProviderQueries(rt, config.InternalBitswapProviderQuery{})
+		require.NoError(t, err)
+		require.Equal(t, rt, limited)
+	})
+
+	t.Run("queries are limited", func(t *testing.T) {
+		rt := &blockingRouting{}
+		limited, err := limitProviderQueries(rt, config.InternalBitswapProviderQuery{
+			MaxProviders:       config.NewOptionalInteger(20),
+			MaxConcurrentFinds: config.NewOptionalInteger(2),
+			Timeout:            config.NewOptionalDuration(50 * time.Millisecond),
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		var chans []<-chan peer.AddrInfo
+		for i := 0; i < 4; i++ {
+			chans = append(chans, limited.FindProvidersAsync(context.Background(), cid.Cid{}, 10))
+		}
+		for _, ch := range chans {
+			for range ch {
+			}
+		}
+
+		require.EqualValues(t, 20, rt.count.Load())
+		require.EqualValues(t, 2, rt.peak.Load())
+		// Two rounds of two queries ending on timeout.
+		require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("invalid limits are rejected", func(t *testing.T) {
+		_, err := limitProviderQueries(&blockingRouting{}, config.InternalBitswapProviderQuery{
+			MaxConcurrentFinds: config.NewOptionalInteger(0),
+		})
+		require.Error(t, err)
+	})
+}
+
+// findCounter counts the provider searches made with the clients of the
+// routing server.
+type findCounter struct {
+	mockrouting.Server
+	finds atomic.Int64
+}
+
+func (f *findCounter) Client(p tnet.Identity) mockrouting.Client {
+	return &findCounterClient{Client: f.Server.Client(p), counter: f}
+}
+
+type findCounterClient struct {
+	mockrouting.Client
+	counter *findCounter
+}
+
+func (c *findCounterClient) FindProvidersAsync(ctx context.Context, k cid.Cid, max int) <-chan peer.AddrInfo {
+	c.counter.finds.Add(1)
+	return c.Client.FindProvidersAsync(ctx, k, max)
+}
+
+func TestBitswapClientTimeouts(t *testing.T) {
+	// the wants are searched for again every RebroadcastInterval
+	finds := func(t *testing.T, timeouts *config.InternalBitswapClientTimeouts) int64 {
+		cfg := &config.Config{}
+		cfg.Internal.Bitswap = &config.InternalBitswap{
+			ProviderSearchDelay: *config.NewOptionalDuration(time.Hour),
+			ClientTimeouts:      timeouts,
+		}
+		opts := BitswapOptions(cfg, false).(func() bitswapOptionsOut)().BitswapOpts
+
+		rs := &findCounter{Server: mockrouting.NewServer()}
+		ig := testinstance.NewTestInstanceGenerator(tn.VirtualNetwork(rs, delay.Fixed(0)), nil, opts)
+		defer ig.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := ig.Next().Exchange.GetBlock(ctx, blocks.NewBlock([]byte("missing block")).Cid())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		return rs.finds.Load()
+	}
+
+	require.Zero(t, finds(t, nil))
+	require.Greater(t, finds(t, &config.InternalBitswapClientTimeouts{
+		RebroadcastInterval: config.NewOptionalDuration(50 * time.Millisecond),
+	}), int64(5))
+}
@@ -0,0 +1,303 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+const (
+	// bitswapCancelGrace is how long after a want was cancelled its block is
+	// still accepted from the peer, as they may have crossed.
+	bitswapCancelGrace = time.Minute
+	// minAskedPrune is the number of wants tracked for a peer before
+	// cancelled ones are pruned.
+	minAskedPrune = 256
+)
+
+// BitswapVerifierStats counts the invalid blocks received over Bitswap and
+// the peers banned for them.
+type BitswapVerifierStats struct {
+	// InvalidBlocks are the blocks received from peers they were not
+	// requested from. Bitswap derives the CID of a block from its data, so
+	// corrupted data shows as an unrequested block.
+	InvalidBlocks uint64
+	// Bans is the number of times a peer was banned, and BannedPeers the
+	// number of peers banned now.
+	Bans        uint64
+	BannedPeers int
+	// RetriedWants are the wants sent or to be sent to banned peers, which
+	// were retried with other peers.
+	RetriedWants uint64
+}
+
+type askedWant struct {
+	at        time.Time
+	cancelled bool
+}
+
+// BitswapVerifier bans the peers that send blocks that were not requested
+// from them: their blocks and HAVEs are dropped, and the wants sent to them
+// are answered with DONT_HAVE, which makes Bitswap sessions retry them with
+// other peers and providers.
+type BitswapVerifier struct {
+	// banDuration is how long the blocks of a peer that sent a block not
+	// requested from it are ignored.
+	banDuration time.Duration
+
+	mu        sync.Mutex
+	asked     map[peer.ID]map[cid.Cid]askedWant
+	pruneAt   map[peer.ID]int
+	banned    map[peer.ID]time.Time
+	receivers []network.Receiver
+	stats     BitswapVerifierStats
+}
+
+func newBitswapVerifier(banDuration time.Duration) *BitswapVerifier {
+	return &BitswapVerifier{
+		banDuration: banDuration,
+		asked:       make(map[peer.ID]map[cid.Cid]askedWant),
+		pruneAt:     make(map[peer.ID]int),
+		banned:      make(map[peer.ID]time.Time),
+	}
+}
+
+// BitswapBlockVerifier bans the peers sending invalid blocks from the public
+// Bitswap network, see 'ipfs bitswap stat'.
+func BitswapBlockVerifier(cfg *config.BitswapBlockVerification) fx.Option {
+	if cfg == nil {
+		cfg = &config.BitswapBlockVerification{}
+	}
+	if !cfg.Enabled.WithDefault(config.DefaultBitswapBlockVerificationEnabled) {
+		return fx.Options()
+	}
+	return fx.Provide(func() (*BitswapVerifier, error) {
+		banDuration := cfg.BanDuration.WithDefault(config.DefaultBitswapBlockVerificationBanDuration)
+		if banDuration <= 0 {
+			return nil, errors.New("invalid Bitswap.BlockVerification.BanDuration: must be positive")
+		}
+		return newBitswapVerifier(banDuration), nil
+	})
+}
+
+// Stats returns the invalid blocks and bans counted so far.
+func (v *BitswapVerifier) Stats() BitswapVerifierStats {
+	now := time.Now()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	st := v.stats
+	for p := range v.banned {
+		if v.isBanned(p, now) {
+			st.BannedPeers++
+		}
+	}
+	return st
+}
+
+func (v *BitswapVerifier) isBanned(p peer.ID, now time.Time) bool {
+	until, ok := v.banned[p]
+	if ok && now.After(until) {
+		delete(v.banned, p)
+		return false
+	}
+	return ok
+}
+
+func (v *BitswapVerifier) wasAsked(p peer.ID, c cid.Cid, now time.Time) bool {
+	w, ok := v.asked[p][c]
+	return ok && (!w.cancelled || now.Sub(w.at) < bitswapCancelGrace)
+}
+
+// send records the wants of msg, and returns msg without them when p is
+// banned, answering them with DONT_HAVE instead.
+func (v *BitswapVerifier) send(p peer.ID, msg bsmsg.BitSwapMessage) bsmsg.BitSwapMessage {
+	wants := msg.Wantlist()
+	if len(wants) == 0 {
+		return msg
+	}
+	now := time.Now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.isBanned(p, now) {
+		msg = msg.Clone()
+		var retried []cid.Cid
+		for _, e := range wants {
+			if !e.Cancel {
+				msg.Remove(e.Cid)
+				retried = append(retried, e.Cid)
+			}
+		}
+		if len(retried) > 0 {
+			v.stats.RetriedWants += uint64(len(retried))
+			go v.dontHave(p, retried)
+		}
+		return msg
+	}
+
+	asked, ok := v.asked[p]
+	if !ok {
+		asked = make(map[cid.Cid]askedWant)
+		v.asked[p] = asked
+	}
+	for _, e := range wants {
+		asked[e.Cid] = askedWant{at: now, cancelled: e.Cancel}
+	}
+	if len(asked) > max(v.pruneAt[p], minAskedPrune) {
+		for c, w := range asked {
+			if w.cancelled && now.Sub(w.at) >= bitswapCancelGrace {
+				delete(asked, c)
+			}
+		}
+		v.pruneAt[p] = 2 * len(asked)
+	}
+	return msg
+}
+
+// dontHave makes the receivers handle DONT_HAVEs from p for cids.
+func (v *BitswapVerifier) dontHave(p peer.ID, cids []cid.Cid) {
+	msg := bsmsg.New(false)
+	for _, c := range cids {
+		msg.AddDontHave(c)
+	}
+	v.mu.Lock()
+	receivers := v.receivers
+	v.mu.Unlock()
+	for _, r := range receivers {
+		r.ReceiveMessage(context.Background(), p, msg)
+	}
+}
+
+// receive bans p when msg has blocks not requested from it, and returns msg
+// without the blocks and HAVEs of banned peers. The outstanding wants sent to
+// a peer when it gets banned are answered with DONT_HAVE.
+func (v *BitswapVerifier) receive(p peer.ID, msg bsmsg.BitSwapMessage) bsmsg.BitSwapMessage {
+	blks := msg.Blocks()
+	if len(blks) == 0 && len(msg.Haves()) == 0 {
+		return msg
+	}
+	now := time.Now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var retry []cid.Cid
+	if !v.isBanned(p, now) {
+		var invalid int
+		for _, b := range blks {
+			if !v.wasAsked(p, b.Cid(), now) {
+				invalid++
+			} else if w := v.asked[p][b.Cid()]; !w.cancelled {
+				// The want was answered.
+				v.asked[p][b.Cid()] = askedWant{at: now, cancelled: true}
+			}
+		}
+		if invalid == 0 {
+			return msg
+		}
+		logger.Warnf("bitswap: banning %s for %s after receiving %d blocks that were not requested from it", p, v.banDuration, invalid)
+		v.stats.InvalidBlocks += uint64(invalid)
+		v.stats.Bans++
+		v.banned[p] = now.Add(v.banDuration)
+		for c, w := range v.asked[p] {
+			if !w.cancelled {
+				retry = append(retry, c)
+			}
+		}
+		v.stats.RetriedWants += uint64(len(retry))
+		v.forget(p)
+	}
+
+	out := bsmsg.New(msg.Full())
+	for _, e := range msg.Wantlist() {
+		if e.Cancel {
+			out.Cancel(e.Cid)
+		} else {
+			out.AddEntry(e.Cid, e.Priority, e.WantType, e.SendDontHave)
+		}
+	}
+	for _, c := range msg.DontHaves() {
+		out.AddDontHave(c)
+	}
+	for _, c := range retry {
+		out.AddDontHave(c)
+	}
+	return out
+}
+
+func (v *BitswapVerifier) forget(p peer.ID) {
+	delete(v.asked, p)
+	delete(v.pruneAt, p)
+}
+
+// verifyingNetwork applies a BitswapVerifier to the messages of a Bitswap
+// network.
+type verifyingNetwork struct {
+	network.BitSwapNetwork
+	verifier *BitswapVerifier
+}
+
+func (n *verifyingNetwork) Start(receivers ...network.Receiver) {
+	n.verifier.mu.Lock()
+	n.verifier.receivers = receivers
+	n.verifier.mu.Unlock()
+
+	wrapped := make([]network.Receiver, len(receivers))
+	for i, r := range receivers {
+		wrapped[i] = &verifyingReceiver{Receiver: r, verifier: n.verifier}
+	}
+	n.BitSwapNetwork.Start(wrapped...)
+}
+
+func (n *verifyingNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	msg = n.verifier.send(p, msg)
+	if msg.Empty() {
+		return nil
+	}
+	return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+func (n *verifyingNetwork) NewMessageSender(ctx context.Context, p peer.ID, opts *network.MessageSenderOpts) (network.MessageSender, error) {
+	s, err := n.BitSwapNetwork.NewMessageSender(ctx, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingSender{MessageSender: s, verifier: n.verifier, peer: p}, nil
+}
+
+type verifyingSender struct {
+	network.MessageSender
+	verifier *BitswapVerifier
+	peer     peer.ID
+}
+
+func (s *verifyingSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	msg = s.verifier.send(s.peer, msg)
+	if msg.Empty() {
+		return nil
+	}
+	return s.MessageSender.SendMsg(ctx, msg)
+}
+
+type verifyingReceiver struct {
+	network.Receiver
+	verifier *BitswapVerifier
+}
+
+func (r *verifyingReceiver) ReceiveMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.Receiver.ReceiveMessage(ctx, p, r.verifier.receive(p, msg))
+}
+
+func (r *verifyingReceiver) PeerDisconnected(p peer.ID) {
+	r.verifier.mu.Lock()
+	r.verifier.forget(p)
+	r.verifier.mu.Unlock()
+	r.Receiver.PeerDisconnected(p)
+}
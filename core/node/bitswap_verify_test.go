@@ -0,0 +1,120 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/network"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBitswapNetwork struct {
+	network.BitSwapNetwork
+	receivers []network.Receiver
+	sent      []bsmsg.BitSwapMessage
+}
+
+func (n *fakeBitswapNetwork) Start(r ...network.Receiver) { n.receivers = r }
+
+func (n *fakeBitswapNetwork) NewMessageSender(context.Context, peer.ID, *network.MessageSenderOpts) (network.MessageSender, error) {
+	return &fakeMessageSender{net: n}, nil
+}
+
+type fakeMessageSender struct {
+	network.MessageSender
+	net *fakeBitswapNetwork
+}
+
+func (s *fakeMessageSender) SendMsg(_ context.Context, msg bsmsg.BitSwapMessage) error {
+	s.net.sent = append(s.net.sent, msg)
+	return nil
+}
+
+type recordingReceiver struct {
+	network.Receiver
+	mu       sync.Mutex
+	received []bsmsg.BitSwapMessage
+}
+
+func (r *recordingReceiver) ReceiveMessage(_ context.Context, _ peer.ID, msg bsmsg.BitSwapMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, msg)
+}
+
+func (r *recordingReceiver) last() bsmsg.BitSwapMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.received[len(r.received)-1]
+}
+
+func (r *recordingReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+func TestBitswapVerifier(t *testing.T) {
+	ctx := context.Background()
+	inner := &fakeBitswapNetwork{}
+	const banDuration = 5 * time.Minute
+	v := newBitswapVerifier(banDuration)
+	net := &verifyingNetwork{BitSwapNetwork: inner, verifier: v}
+	recv := &recordingReceiver{}
+	net.Start(recv)
+	deliver := inner.receivers[0]
+
+	p := peer.ID("peer")
+	sender, err := net.NewMessageSender(ctx, p, nil)
+	require.NoError(t, err)
+	want := func(blks ...blocks.Block) {
+		msg := bsmsg.New(false)
+		for _, b := range blks {
+			msg.AddEntry(b.Cid(), 1, pb.Message_Wantlist_Block, true)
+		}
+		require.NoError(t, sender.SendMsg(ctx, msg))
+	}
+	send := func(blks ...blocks.Block) {
+		msg := bsmsg.New(false)
+		for _, b := range blks {
+			msg.AddBlock(b)
+		}
+		deliver.ReceiveMessage(ctx, p, msg)
+	}
+
+	requested := blocks.NewBlock([]byte("requested"))
+	want(requested)
+	send(requested)
+	require.Len(t, recv.last().Blocks(), 1, "requested blocks are accepted")
+	require.Zero(t, v.Stats().InvalidBlocks)
+
+	pending := blocks.NewBlock([]byte("pending"))
+	want(pending)
+	send(blocks.NewBlock([]byte("garbage")))
+	msg := recv.last()
+	require.Empty(t, msg.Blocks(), "unrequested blocks are dropped")
+	require.Equal(t, []cid.Cid{pending.Cid()}, msg.DontHaves(), "outstanding wants are retried")
+	require.Equal(t, BitswapVerifierStats{InvalidBlocks: 1, Bans: 1, BannedPeers: 1, RetriedWants: 1}, v.Stats())
+	v.mu.Lock()
+	require.WithinDuration(t, time.Now().Add(banDuration), v.banned[p], time.Second, "peers are banned for the configured duration")
+	v.mu.Unlock()
+
+	send(pending)
+	require.Empty(t, recv.last().Blocks(), "blocks of banned peers are dropped")
+
+	sentBefore := len(inner.sent)
+	received := recv.count()
+	later := blocks.NewBlock([]byte("later"))
+	want(later)
+	require.Len(t, inner.sent, sentBefore, "wants are not sent to banned peers")
+	require.Eventually(t, func() bool { return recv.count() > received }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []cid.Cid{later.Cid()}, recv.last().DontHaves())
+	require.EqualValues(t, 2, v.Stats().RetriedWants)
+}
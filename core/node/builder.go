@@ -33,6 +33,10 @@ type BuildCfg struct {
 	// DO NOT SET THIS UNLESS YOU'RE TESTING.
 	DisableEncryptedConnections bool
 
+	// Purpose, if set, is one of Purposes: the optional subsystems the
+	// purpose does not need are left out of the node.
+	Purpose string
+
 	Routing libp2p.RoutingOption
 	Host    libp2p.HostOption
 	Repo    repo.Repo
@@ -0,0 +1,13 @@
+//go:build !testclock
+
+package node
+
+import "github.com/benbjohnson/clock"
+
+// Clock returns the clock of the reprovide sweeps, the scheduled tasks, the
+// periodic GC, and the lifetimes of the published IPNS records and of the
+// cached resolutions. The builds with the testclock tag can replace it with a
+// mock clock for tests.
+func Clock() clock.Clock {
+	return clock.New()
+}
@@ -0,0 +1,29 @@
+//go:build testclock
+
+package node
+
+import (
+	"os"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// TestClockEnv is the environment variable that, when set, replaces the
+// clock of the time-dependent subsystems of the node with a mock clock that
+// only moves when advanced through /debug/clock, for tests. It is only read by
+// the builds with the testclock tag.
+const TestClockEnv = "IPFS_TEST_CLOCK"
+
+// Clock returns the clock of the reprovide sweeps, the scheduled tasks, the
+// periodic GC, and the lifetimes of the published IPNS records and of the
+// cached resolutions.
+func Clock() clock.Clock {
+	if os.Getenv(TestClockEnv) == "" {
+		return clock.New()
+	}
+	logger.Warnf("%s is set: time-dependent subsystems use a mock clock", TestClockEnv)
+	m := clock.NewMock()
+	m.Set(time.Now())
+	return m
+}
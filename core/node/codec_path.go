@@ -0,0 +1,187 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/boxo/fetcher"
+	fetcherhelpers "github.com/ipfs/boxo/fetcher/helpers"
+	"github.com/ipfs/boxo/path"
+	pathresolver "github.com/ipfs/boxo/path/resolver"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	mc "github.com/multiformats/go-multicodec"
+)
+
+// ErrCodecPath is returned when a segment of a path does not exist in the
+// data of a block with an IPLD codec, such as dag-cbor. It matches
+// resolver.ErrNoLink, like the segments missing from UnixFS directories, and
+// starts with its message.
+type ErrCodecPath struct {
+	// Name is the missing segment, and Node the block it is missing from.
+	Name string
+	Node cid.Cid
+	// Path is the path up to the missing segment.
+	Path   string
+	Reason string
+}
+
+func (e *ErrCodecPath) Error() string {
+	noLink := &pathresolver.ErrNoLink{Name: e.Name, Node: e.Node}
+	return fmt.Sprintf("%s: %s does not exist: %s", noLink.Error(), e.Path, e.Reason)
+}
+
+// Is implements errors.Is for resolver.ErrNoLink.
+func (e *ErrCodecPath) Is(err error) bool {
+	_, ok := err.(*pathresolver.ErrNoLink)
+	return ok
+}
+
+// IsCodecBlock tells whether the paths within the block c are resolved in its
+// data, segment by segment, by the path resolvers of the node: the blocks
+// other than dag-pb, raw and libp2p-key, such as dag-cbor and dag-json.
+func IsCodecBlock(c cid.Cid) bool {
+	switch mc.Code(c.Prefix().Codec) {
+	case mc.DagPb, mc.Raw, mc.Libp2pKey:
+		return false
+	default:
+		return true
+	}
+}
+
+// EscapePathSegment escapes a map key into a path segment. Only '%' and '/'
+// are escaped, as %25 and %2F.
+func EscapePathSegment(key string) string {
+	return strings.NewReplacer("%", "%25", "/", "%2F").Replace(key)
+}
+
+// UnescapePathSegment returns the map key or list index of a path segment.
+// Segments which are not valid escapes, such as "100%", are taken literally.
+func UnescapePathSegment(segment string) string {
+	if key, err := url.PathUnescape(segment); err == nil {
+		return key
+	}
+	return segment
+}
+
+// LookupCodecPath returns the node at segments within nd, the data of the
+// root block of p. Links are not followed.
+func LookupCodecPath(nd datamodel.Node, p path.ImmutablePath, segments []string) (datamodel.Node, error) {
+	base := "/" + p.Namespace() + "/" + p.RootCid().String()
+	for i, segment := range segments {
+		var err error
+		if nd, err = lookupCodecSegment(nd, segment); err != nil {
+			return nil, newErrCodecPath(p.RootCid(), base, segments[:i+1], err)
+		}
+	}
+	return nd, nil
+}
+
+// lookupCodecSegment returns the entry of a map or of a list at segment.
+func lookupCodecSegment(nd datamodel.Node, segment string) (datamodel.Node, error) {
+	key := UnescapePathSegment(segment)
+	switch nd.Kind() {
+	case datamodel.Kind_Map:
+		v, err := nd.LookupByString(key)
+		if err != nil {
+			return nil, fmt.Errorf("no key %q in the map", key)
+		}
+		return v, nil
+	case datamodel.Kind_List:
+		i, err := strconv.ParseInt(key, 10, 64)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("%q is not an index of the list", key)
+		}
+		if i >= nd.Length() {
+			return nil, fmt.Errorf("index %d is out of the list of length %d", i, nd.Length())
+		}
+		return nd.LookupByIndex(i)
+	default:
+		return nil, fmt.Errorf("%s values have no segments", nd.Kind())
+	}
+}
+
+// newErrCodecPath returns the error of the last of segments, missing from the
+// block c.
+func newErrCodecPath(c cid.Cid, base string, segments []string, reason error) *ErrCodecPath {
+	return &ErrCodecPath{
+		Name:   UnescapePathSegment(segments[len(segments)-1]),
+		Node:   c,
+		Path:   joinSegments(base, segments),
+		Reason: reason.Error(),
+	}
+}
+
+func joinSegments(base string, segments []string) string {
+	return strings.TrimSuffix(base, "/") + "/" + strings.Join(segments, "/")
+}
+
+// codecPathResolver resolves the paths within the blocks of IPLD codecs
+// segment by segment, with escaped map keys, index addressing in lists and
+// errors naming the missing segment. The paths within the other blocks are
+// resolved by the wrapped resolver.
+type codecPathResolver struct {
+	pathresolver.Resolver
+	fetcher fetcher.Factory
+}
+
+func newCodecPathResolver(f fetcher.Factory) pathresolver.Resolver {
+	return &codecPathResolver{Resolver: pathresolver.NewBasicResolver(f), fetcher: f}
+}
+
+// ResolveToLastNode returns the CID of the last block of fpath, and the
+// segments of fpath within its data.
+func (r *codecPathResolver) ResolveToLastNode(ctx context.Context, fpath path.ImmutablePath) (cid.Cid, []string, error) {
+	c, segments := fpath.RootCid(), fpath.Segments()[2:]
+	if !IsCodecBlock(c) || len(segments) == 0 {
+		return r.Resolver.ResolveToLastNode(ctx, fpath)
+	}
+
+	session := r.fetcher.NewSession(ctx)
+	base := "/" + fpath.Namespace() + "/" + c.String()
+	nd, err := fetcherhelpers.Block(ctx, session, cidlink.Link{Cid: c})
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	start := 0
+	for i, segment := range segments {
+		if nd, err = lookupCodecSegment(nd, segment); err != nil {
+			return cid.Undef, nil, newErrCodecPath(c, base, segments[:i+1], err)
+		}
+		if nd.Kind() != datamodel.Kind_Link {
+			continue
+		}
+
+		lnk, err := nd.AsLink()
+		if err != nil {
+			return cid.Undef, nil, err
+		}
+		clnk, ok := lnk.(cidlink.Link)
+		if !ok {
+			return cid.Undef, nil, fmt.Errorf("%s is a link that is not a CID link: %v", joinSegments(base, segments[:i+1]), lnk)
+		}
+		c, start = clnk.Cid, i+1
+		if start == len(segments) {
+			return c, nil, nil
+		}
+		if !IsCodecBlock(c) {
+			p, err := path.NewPathFromSegments(append([]string{fpath.Namespace(), c.String()}, segments[start:]...)...)
+			if err != nil {
+				return cid.Undef, nil, err
+			}
+			imPath, err := path.NewImmutablePath(p)
+			if err != nil {
+				return cid.Undef, nil, err
+			}
+			return r.Resolver.ResolveToLastNode(ctx, imPath)
+		}
+		if nd, err = fetcherhelpers.Block(ctx, session, clnk); err != nil {
+			return cid.Undef, nil, err
+		}
+	}
+	return c, segments[start:], nil
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// ContentChanges notifies the changes of the pins and of the MFS root, which
+// select the content kept by the node, to the services derived from them,
+// such as the SearchIndex and the sets of Bitswap.ServeStrategy.
+type ContentChanges struct {
+	lk   sync.Mutex
+	subs []chan<- struct{}
+}
+
+// NewContentChanges returns the ContentChanges of the node.
+func NewContentChanges() *ContentChanges {
+	return new(ContentChanges)
+}
+
+// Notify registers ch, sent a value after each change unless one is already
+// pending, so that a buffered channel coalesces the changes.
+func (c *ContentChanges) Notify(ch chan<- struct{}) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.subs = append(c.subs, ch)
+}
+
+// Changed notifies the registered channels of a change.
+func (c *ContentChanges) Changed() {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	for _, ch := range c.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ContentChangesPinner decorates the pinner to notify the ContentChanges of
+// the changes of the pins.
+func ContentChangesPinner(p pin.Pinner, c *ContentChanges) pin.Pinner {
+	return &contentChangesPinner{Pinner: p, changes: c}
+}
+
+type contentChangesPinner struct {
+	pin.Pinner
+	changes *ContentChanges
+}
+
+func (p *contentChangesPinner) Pin(ctx context.Context, node ipld.Node, recursive bool, name string) error {
+	err := p.Pinner.Pin(ctx, node, recursive, name)
+	p.changes.Changed()
+	return err
+}
+
+func (p *contentChangesPinner) PinWithMode(ctx context.Context, c cid.Cid, mode pin.Mode, name string) error {
+	err := p.Pinner.PinWithMode(ctx, c, mode, name)
+	p.changes.Changed()
+	return err
+}
+
+func (p *contentChangesPinner) Unpin(ctx context.Context, c cid.Cid, recursive bool) error {
+	err := p.Pinner.Unpin(ctx, c, recursive)
+	p.changes.Changed()
+	return err
+}
+
+func (p *contentChangesPinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error {
+	err := p.Pinner.Update(ctx, from, to, unpin)
+	p.changes.Changed()
+	return err
+}
@@ -0,0 +1,244 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	exchange "github.com/ipfs/boxo/exchange"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	ihelper "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var contentEquivalenceFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ipfs_content_equivalence_fetches_total",
+	Help: "Blocks that could not be fetched and were looked up in the content equivalence index and service, by result (rechunked, unknown or error).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(contentEquivalenceFetches)
+}
+
+// equivalentExchange serves the blocks the wrapped exchange cannot fetch
+// within the fetch timeout by rechunking a UnixFS file with the same bytes,
+// found in the ContentEquivalence index or service, into the requested CID.
+type equivalentExchange struct {
+	exchange.Interface
+	*contentEquivalence
+}
+
+var _ exchange.SessionExchange = (*equivalentExchange)(nil)
+
+// Unwrap returns the wrapped exchange.
+func (e *equivalentExchange) Unwrap() exchange.Interface {
+	return e.Interface
+}
+
+// ContentEquivalenceExchange wraps rem so that the blocks it cannot fetch are
+// rebuilt from their equivalents when Experimental.ContentEquivalence is set.
+// The exchange is returned as-is otherwise.
+func ContentEquivalenceExchange(cfg *config.Config, bs blockstore.Blockstore, rem exchange.Interface) (exchange.Interface, error) {
+	if !cfg.Experimental.ContentEquivalence {
+		return rem, nil
+	}
+	ceq := &cfg.ContentEquivalence
+	if ceq.URL == "" && len(ceq.Index) == 0 {
+		return nil, errors.New("Experimental.ContentEquivalence requires ContentEquivalence.URL or ContentEquivalence.Index")
+	}
+	if ceq.URL != "" {
+		if !strings.HasPrefix(ceq.URL, "http://") && !strings.HasPrefix(ceq.URL, "https://") {
+			return nil, fmt.Errorf("invalid ContentEquivalence.URL %q: must be an http or https URL", ceq.URL)
+		}
+		if !strings.Contains(ceq.URL, "{cid}") {
+			return nil, fmt.Errorf("invalid ContentEquivalence.URL %q: must contain {cid}", ceq.URL)
+		}
+	}
+	index := make(map[cid.Cid]config.ContentEquivalent, len(ceq.Index))
+	for k, eq := range ceq.Index {
+		c, err := cid.Decode(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ContentEquivalence.Index CID %q: %w", k, err)
+		}
+		index[c] = eq
+	}
+
+	timeout := ceq.FetchTimeout.WithDefault(config.DefaultContentEquivalenceFetchTimeout)
+	return &equivalentExchange{
+		Interface: rem,
+		contentEquivalence: &contentEquivalence{
+			url:          ceq.URL,
+			index:        index,
+			fetchTimeout: timeout,
+			client:       &http.Client{Timeout: timeout},
+			bs:           bs,
+			// the equivalents are fetched with the wrapped exchange, so
+			// that they are not looked up in turn
+			dserv: dag.NewDAGService(blockservice.New(bs, rem)),
+		},
+	}, nil
+}
+
+func (e *equivalentExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return e.getBlock(ctx, e.Interface, c)
+}
+
+func (e *equivalentExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	var f exchange.Fetcher = e.Interface
+	if sessEx, ok := e.Interface.(exchange.SessionExchange); ok {
+		f = sessEx.NewSession(ctx)
+	}
+	return &equivalentFetcher{Fetcher: f, contentEquivalence: e.contentEquivalence}
+}
+
+// equivalentFetcher is a session of an equivalentExchange. Only single block
+// fetches fall back to the equivalents: the blocks of a rechunked DAG are all
+// stored at once, when its root is fetched.
+type equivalentFetcher struct {
+	exchange.Fetcher
+	*contentEquivalence
+}
+
+func (f *equivalentFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return f.getBlock(ctx, f.Fetcher, c)
+}
+
+type contentEquivalence struct {
+	url          string
+	index        map[cid.Cid]config.ContentEquivalent
+	fetchTimeout time.Duration
+	client       *http.Client
+	bs           blockstore.Blockstore
+	dserv        ipld.DAGService
+}
+
+func (ce *contentEquivalence) getBlock(ctx context.Context, f exchange.Fetcher, c cid.Cid) (blocks.Block, error) {
+	fctx, cancel := context.WithTimeout(ctx, ce.fetchTimeout)
+	blk, err := f.GetBlock(fctx, c)
+	cancel()
+	if err == nil || ctx.Err() != nil {
+		return blk, err
+	}
+
+	eq, ok, lerr := ce.lookup(ctx, c)
+	switch {
+	case lerr != nil:
+		logger.Warnf("looking up the equivalents of %s: %s", c, lerr)
+		contentEquivalenceFetches.WithLabelValues("error").Inc()
+		return nil, err
+	case !ok:
+		contentEquivalenceFetches.WithLabelValues("unknown").Inc()
+		return nil, err
+	}
+	for _, s := range eq.Cids {
+		alt, aerr := cid.Decode(s)
+		if aerr == nil {
+			blk, aerr = ce.rechunk(ctx, c, alt, eq)
+		}
+		if aerr == nil {
+			contentEquivalenceFetches.WithLabelValues("rechunked").Inc()
+			return blk, nil
+		}
+		logger.Warnf("rechunking %s into %s: %s", s, c, aerr)
+	}
+	contentEquivalenceFetches.WithLabelValues("error").Inc()
+	return nil, err
+}
+
+// lookup returns the equivalents of c from the index, or else from the
+// equivalence service.
+func (ce *contentEquivalence) lookup(ctx context.Context, c cid.Cid) (config.ContentEquivalent, bool, error) {
+	if eq, ok := ce.index[c]; ok {
+		return eq, true, nil
+	}
+	if ce.url == "" {
+		return config.ContentEquivalent{}, false, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(ce.url, "{cid}", c.String()), nil)
+	if err != nil {
+		return config.ContentEquivalent{}, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := ce.client.Do(req)
+	if err != nil {
+		return config.ContentEquivalent{}, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return config.ContentEquivalent{}, false, nil
+	default:
+		return config.ContentEquivalent{}, false, fmt.Errorf("equivalence service responded %s", resp.Status)
+	}
+	var eq config.ContentEquivalent
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&eq); err != nil {
+		return config.ContentEquivalent{}, false, fmt.Errorf("invalid equivalence service response: %w", err)
+	}
+	return eq, len(eq.Cids) > 0, nil
+}
+
+// rechunk reads the UnixFS file alt and imports its bytes with the settings
+// of eq. The resulting blocks are stored, and the root is returned when its
+// CID is c, which verifies the bytes of alt.
+func (ce *contentEquivalence) rechunk(ctx context.Context, c, alt cid.Cid, eq config.ContentEquivalent) (blocks.Block, error) {
+	nd, err := ce.dserv.Get(ctx, alt)
+	if err != nil {
+		return nil, err
+	}
+	r, err := uio.NewDagReader(ctx, nd, ce.dserv)
+	if err != nil {
+		return nil, err
+	}
+	chnk, err := chunker.FromString(r, eq.Chunker.WithDefault("default"))
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := dag.PrefixForCidVersion(int(c.Version()))
+	if err != nil {
+		return nil, err
+	}
+	prefix.MhType = c.Prefix().MhType
+
+	// the rechunked blocks are stored without going through the exchange
+	params := ihelper.DagBuilderParams{
+		Dagserv:    dag.NewDAGService(blockservice.New(ce.bs, offline.Exchange(ce.bs))),
+		RawLeaves:  eq.RawLeaves.WithDefault(c.Version() == 1),
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: prefix,
+	}
+	db, err := params.New(chnk)
+	if err != nil {
+		return nil, err
+	}
+	var root ipld.Node
+	if eq.Trickle.WithDefault(false) {
+		root, err = trickle.Layout(db)
+	} else {
+		root, err = balanced.Layout(db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !root.Cid().Equals(c) {
+		return nil, fmt.Errorf("the bytes of %s chunk into %s", alt, root.Cid())
+	}
+	return root, nil
+}
@@ -0,0 +1,121 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	ihelper "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+// importFile imports data as a CIDv1 UnixFS file with raw leaves into bs.
+func importFile(t *testing.T, bs blockstore.Blockstore, data []byte, chunk string) cid.Cid {
+	chnk, err := chunker.FromString(bytes.NewReader(data), chunk)
+	require.NoError(t, err)
+	prefix, err := dag.PrefixForCidVersion(1)
+	require.NoError(t, err)
+	params := ihelper.DagBuilderParams{
+		Dagserv:    dag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		RawLeaves:  true,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: prefix,
+	}
+	db, err := params.New(chnk)
+	require.NoError(t, err)
+	nd, err := balanced.Layout(db)
+	require.NoError(t, err)
+	return nd.Cid()
+}
+
+func TestContentEquivalenceExchange(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 1000)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	newBlockstore := func() blockstore.Blockstore {
+		return blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	}
+	// want is the same bytes as alt, chunked differently, and is missing
+	want := importFile(t, newBlockstore(), data, "size-50")
+
+	t.Run("rechunks an equivalent of the index", func(t *testing.T) {
+		bs := newBlockstore()
+		alt := importFile(t, bs, data, "size-100")
+		cfg := &config.Config{}
+		cfg.Experimental.ContentEquivalence = true
+		cfg.ContentEquivalence.Index = map[string]config.ContentEquivalent{
+			want.String(): {Cids: []string{alt.String()}, Chunker: config.NewOptionalString("size-50")},
+		}
+		ex, err := ContentEquivalenceExchange(cfg, bs, offline.Exchange(bs))
+		require.NoError(t, err)
+
+		blk, err := ex.GetBlock(ctx, want)
+		require.NoError(t, err)
+		require.Equal(t, want, blk.Cid())
+		nd, err := dag.DecodeProtobufBlock(blk)
+		require.NoError(t, err)
+		require.Len(t, nd.Links(), 20)
+		for _, l := range nd.Links() {
+			has, err := bs.Has(ctx, l.Cid)
+			require.NoError(t, err)
+			require.True(t, has)
+		}
+	})
+
+	t.Run("asks the equivalence service and verifies the bytes", func(t *testing.T) {
+		bs := newBlockstore()
+		alt := importFile(t, bs, data, "size-100")
+		var chunk string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/equivalents/"+want.String() {
+				http.NotFound(w, r)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(config.ContentEquivalent{Cids: []string{alt.String()}, Chunker: config.NewOptionalString(chunk)})
+		}))
+		defer srv.Close()
+		cfg := &config.Config{}
+		cfg.Experimental.ContentEquivalence = true
+		cfg.ContentEquivalence.URL = srv.URL + "/equivalents/{cid}"
+		ex, err := ContentEquivalenceExchange(cfg, bs, offline.Exchange(bs))
+		require.NoError(t, err)
+
+		// the bytes of alt do not chunk into want with the wrong chunker
+		chunk = "size-60"
+		_, err = ex.GetBlock(ctx, want)
+		require.Error(t, err)
+
+		chunk = "size-50"
+		blk, err := ex.GetBlock(ctx, want)
+		require.NoError(t, err)
+		require.Equal(t, want, blk.Cid())
+
+		// the service knows no equivalent of other CIDs
+		_, err = ex.GetBlock(ctx, importFile(t, newBlockstore(), data[:500], "size-50"))
+		require.Error(t, err)
+	})
+
+	t.Run("requires a source of equivalents", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Experimental.ContentEquivalence = true
+		bs := newBlockstore()
+		_, err := ContentEquivalenceExchange(cfg, bs, offline.Exchange(bs))
+		require.Error(t, err)
+	})
+}
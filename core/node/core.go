@@ -24,12 +24,21 @@ import (
 	dagpb "github.com/ipld/go-codec-dagpb"
 	"go.uber.org/fx"
 
+	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
 )
 
 // BlockService creates new blockservice which provides an interface to fetch content-addressable blocks
-func BlockService(lc fx.Lifecycle, bs blockstore.Blockstore, rem exchange.Interface) blockservice.BlockService {
+func BlockService(lc fx.Lifecycle, cfg *config.Config, bs blockstore.Blockstore, rem exchange.Interface) (blockservice.BlockService, error) {
+	rem, err := PrioritizedExchange(cfg.Bitswap.PriorityClasses, rem)
+	if err != nil {
+		return nil, err
+	}
+	rem, err = ContentEquivalenceExchange(cfg, bs, rem)
+	if err != nil {
+		return nil, err
+	}
 	bsvc := blockservice.New(bs, rem)
 
 	lc.Append(fx.Hook{
@@ -38,7 +47,7 @@ func BlockService(lc fx.Lifecycle, bs blockstore.Blockstore, rem exchange.Interf
 		},
 	})
 
-	return bsvc
+	return bsvc, nil
 }
 
 // Pinning creates new pinner which tells GC which blocks should be kept
@@ -130,13 +139,15 @@ type PathResolversOut struct {
 	OfflineUnixFSPathResolver pathresolver.Resolver `name:"offlineUnixFSPathResolver"`
 }
 
-// PathResolverConfig creates path resolvers with the given fetchers.
+// PathResolverConfig creates path resolvers with the given fetchers. The
+// paths within the blocks of IPLD codecs such as dag-cbor are resolved by
+// codecPathResolver.
 func PathResolverConfig(fetchers FetchersIn) PathResolversOut {
 	return PathResolversOut{
-		IPLDPathResolver:          pathresolver.NewBasicResolver(fetchers.IPLDFetcher),
-		UnixFSPathResolver:        pathresolver.NewBasicResolver(fetchers.UnixfsFetcher),
-		OfflineIPLDPathResolver:   pathresolver.NewBasicResolver(fetchers.OfflineIPLDFetcher),
-		OfflineUnixFSPathResolver: pathresolver.NewBasicResolver(fetchers.OfflineUnixfsFetcher),
+		IPLDPathResolver:          newCodecPathResolver(fetchers.IPLDFetcher),
+		UnixFSPathResolver:        newCodecPathResolver(fetchers.UnixfsFetcher),
+		OfflineIPLDPathResolver:   newCodecPathResolver(fetchers.OfflineIPLDFetcher),
+		OfflineUnixFSPathResolver: newCodecPathResolver(fetchers.OfflineUnixfsFetcher),
 	}
 }
 
@@ -146,8 +157,8 @@ func Dag(bs blockservice.BlockService) format.DAGService {
 }
 
 // Files loads persisted MFS root
-func Files(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, dag format.DAGService) (*mfs.Root, error) {
-	dsk := datastore.NewKey("/local/filesroot")
+func Files(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, dag format.DAGService, changes *ContentChanges) (*mfs.Root, error) {
+	dsk := filesRootKey
 	pf := func(ctx context.Context, c cid.Cid) error {
 		rootDS := repo.Datastore()
 		if err := rootDS.Sync(ctx, blockstore.BlockPrefix); err != nil {
@@ -160,7 +171,11 @@ func Files(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, dag format.
 		if err := rootDS.Put(ctx, dsk, c.Bytes()); err != nil {
 			return err
 		}
-		return rootDS.Sync(ctx, dsk)
+		if err := rootDS.Sync(ctx, dsk); err != nil {
+			return err
+		}
+		changes.Changed()
+		return nil
 	}
 
 	var nd *merkledag.ProtoNode
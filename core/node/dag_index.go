@@ -0,0 +1,286 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	mc "github.com/multiformats/go-multicodec"
+	"go.uber.org/fx"
+
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+)
+
+var dagIndexKey = datastore.NewKey("/local/dagindex")
+
+// DagIndexer indexes the fields of the dag-cbor and dag-json documents
+// imported with ipfs dag put and ipfs dag import, and queries them for ipfs
+// dag query.
+//
+// The index kept in the repo with DagIndex.Enabled can be replaced by a
+// PluginFx, for instance with a full-text search engine, by decorating the
+// DagIndexer with fx.Decorate.
+type DagIndexer interface {
+	// Index indexes the fields of a block. Blocks that are not dag-cbor or
+	// dag-json documents are ignored.
+	Index(ctx context.Context, blk blocks.Block) error
+
+	// Query returns the documents matching all the terms, which are words
+	// searched in any indexed field, or "field:words" to search them in one
+	// field only.
+	Query(ctx context.Context, terms []string) ([]cid.Cid, error)
+}
+
+// DagIndexing provides the DagIndexer when DagIndex.Enabled is set.
+func DagIndexing(cfg config.DagIndex) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultDagIndexEnabled) {
+		return fx.Options()
+	}
+	return fx.Provide(func(repo repo.Repo) DagIndexer {
+		return &dagIndex{ds: repo.Datastore(), fields: cfg.Fields}
+	})
+}
+
+// dagIndex is an inverted index of the words of the fields of the
+// documents, stored in the datastore under /local/dagindex/<field>/<word>/<cid>.
+type dagIndex struct {
+	ds     datastore.Batching
+	fields []string
+}
+
+func (x *dagIndex) Index(ctx context.Context, blk blocks.Block) error {
+	codec := mc.Code(blk.Cid().Prefix().Codec)
+	if codec != mc.DagCbor && codec != mc.DagJson {
+		return nil
+	}
+	decoder, err := multicodec.LookupDecoder(uint64(codec))
+	if err != nil {
+		return err
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := decoder(nb, bytes.NewReader(blk.RawData())); err != nil {
+		return fmt.Errorf("DagIndex: decoding %s: %w", blk.Cid(), err)
+	}
+	n := nb.Build()
+
+	b, err := x.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, field := range x.fields {
+		words := make(map[string]struct{})
+		dagIndexSelect(n, strings.Split(field, "/"), func(v datamodel.Node) {
+			dagIndexWords(v, func(w string) {
+				words[w] = struct{}{}
+			})
+		})
+		for w := range words {
+			if err := b.Put(ctx, dagIndexWordKey(field, w).ChildString(blk.Cid().String()), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return b.Commit(ctx)
+}
+
+func (x *dagIndex) Query(ctx context.Context, terms []string) ([]cid.Cid, error) {
+	if len(terms) == 0 {
+		return nil, errors.New("empty query")
+	}
+	var result map[cid.Cid]struct{}
+	for _, term := range terms {
+		fields, words := x.fields, dagIndexTerm(term)
+		if field, value, ok := strings.Cut(term, ":"); ok && x.indexed(field) {
+			fields, words = []string{field}, dagIndexTerm(value)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("no word to search in %q", term)
+		}
+
+		// a term matches the documents with all its words in one of the fields
+		matched := make(map[cid.Cid]struct{})
+		for _, field := range fields {
+			var found map[cid.Cid]struct{}
+			for _, w := range words {
+				docs, err := x.documents(ctx, field, w)
+				if err != nil {
+					return nil, err
+				}
+				found = dagIndexIntersect(found, docs)
+				if len(found) == 0 {
+					break
+				}
+			}
+			for c := range found {
+				matched[c] = struct{}{}
+			}
+		}
+		result = dagIndexIntersect(result, matched)
+		if len(result) == 0 {
+			return nil, nil
+		}
+	}
+
+	out := make([]cid.Cid, 0, len(result))
+	for c := range result {
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (x *dagIndex) indexed(field string) bool {
+	for _, f := range x.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// documents returns the documents with the word in the field.
+func (x *dagIndex) documents(ctx context.Context, field, word string) (map[cid.Cid]struct{}, error) {
+	res, err := x.ds.Query(ctx, query.Query{Prefix: dagIndexWordKey(field, word).String() + "/", KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	docs := make(map[cid.Cid]struct{})
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		c, err := cid.Decode(datastore.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		docs[c] = struct{}{}
+	}
+	return docs, nil
+}
+
+func dagIndexWordKey(field, word string) datastore.Key {
+	return dagIndexKey.ChildString(url.PathEscape(field)).ChildString(word)
+}
+
+// dagIndexIntersect returns the intersection of the sets, where a nil a is
+// the set of all the documents.
+func dagIndexIntersect(a, b map[cid.Cid]struct{}) map[cid.Cid]struct{} {
+	if a == nil {
+		return b
+	}
+	for c := range a {
+		if _, ok := b[c]; !ok {
+			delete(a, c)
+		}
+	}
+	return a
+}
+
+// dagIndexSelect calls found with the values matching the selector.
+func dagIndexSelect(n datamodel.Node, sel []string, found func(datamodel.Node)) {
+	if len(sel) == 0 {
+		found(n)
+		return
+	}
+	switch n.Kind() {
+	case datamodel.Kind_Map:
+		if sel[0] == "*" {
+			for it := n.MapIterator(); !it.Done(); {
+				_, v, err := it.Next()
+				if err != nil {
+					return
+				}
+				dagIndexSelect(v, sel[1:], found)
+			}
+			return
+		}
+		if v, err := n.LookupByString(sel[0]); err == nil {
+			dagIndexSelect(v, sel[1:], found)
+		}
+	case datamodel.Kind_List:
+		if sel[0] == "*" {
+			for it := n.ListIterator(); !it.Done(); {
+				_, v, err := it.Next()
+				if err != nil {
+					return
+				}
+				dagIndexSelect(v, sel[1:], found)
+			}
+			return
+		}
+		if i, err := strconv.ParseInt(sel[0], 10, 64); err == nil {
+			if v, err := n.LookupByIndex(i); err == nil {
+				dagIndexSelect(v, sel[1:], found)
+			}
+		}
+	}
+}
+
+// dagIndexWords calls add with the words of a value, or of the elements of a
+// list of values.
+func dagIndexWords(n datamodel.Node, add func(string)) {
+	switch n.Kind() {
+	case datamodel.Kind_String:
+		s, _ := n.AsString()
+		for _, w := range dagIndexSplit(s) {
+			add(w)
+		}
+	case datamodel.Kind_Int:
+		i, _ := n.AsInt()
+		add(strconv.FormatInt(i, 10))
+	case datamodel.Kind_Float:
+		f, _ := n.AsFloat()
+		for _, w := range dagIndexSplit(strconv.FormatFloat(f, 'f', -1, 64)) {
+			add(w)
+		}
+	case datamodel.Kind_Bool:
+		b, _ := n.AsBool()
+		add(strconv.FormatBool(b))
+	case datamodel.Kind_Link:
+		if l, err := n.AsLink(); err == nil {
+			if cl, ok := l.(cidlink.Link); ok {
+				add(cl.Cid.String())
+			}
+		}
+	case datamodel.Kind_List:
+		for it := n.ListIterator(); !it.Done(); {
+			_, v, err := it.Next()
+			if err != nil {
+				return
+			}
+			dagIndexWords(v, add)
+		}
+	}
+}
+
+// dagIndexTerm returns the words searched by a query term, which are the CID
+// itself when the term is a CID, like the links indexed.
+func dagIndexTerm(term string) []string {
+	if c, err := cid.Decode(term); err == nil {
+		return []string{c.String()}
+	}
+	return dagIndexSplit(term)
+}
+
+// dagIndexSplit splits a text into lowercase words.
+func dagIndexSplit(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -1,20 +1,195 @@
 package node
 
 import (
+	"context"
 	"math"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/ipfs/boxo/gateway"
+	"github.com/ipfs/kubo/autoconf"
 	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
 	doh "github.com/libp2p/go-doh-resolver"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
 )
 
-func DNSResolver(cfg *config.Config) (*madns.Resolver, error) {
+type DNSResolverOut struct {
+	fx.Out
+
+	Resolver *madns.Resolver
+	Cache    *DNSCache // nil when DNS.CacheSize is 0
+}
+
+func DNSResolver(cfg *config.Config, r repo.Repo, clk clock.Clock) (DNSResolverOut, error) {
+	cacheSize := cfg.DNS.CacheSize.WithDefault(cfg.Resources.Scale(config.DefaultDNSCacheSize, 64))
+
 	var dohOpts []doh.Option
 	if !cfg.DNS.MaxCacheTTL.IsDefault() {
 		dohOpts = append(dohOpts, doh.WithMaxCacheTTL(cfg.DNS.MaxCacheTTL.WithDefault(time.Duration(math.MaxUint32)*time.Second)))
 	}
+	if cacheSize > 0 {
+		// the lookups are cached by the DNSCache, where they can be purged
+		dohOpts = append(dohOpts, doh.WithCacheDisabled())
+	}
+
+	resolvers := autoconf.Load(context.TODO(), cfg, r.Datastore()).Resolvers(cfg)
+	rslv, err := gateway.NewDNSResolver(resolvers, dohOpts...)
+	if err != nil || cacheSize <= 0 {
+		return DNSResolverOut{Resolver: rslv}, err
+	}
+
+	ttl := cfg.DNS.CacheTTL.WithDefault(config.DefaultDNSCacheTTL)
+	if maxTTL := cfg.DNS.MaxCacheTTL.WithDefault(ttl); maxTTL < ttl {
+		ttl = maxTTL
+	}
+	cache, err := NewDNSCache(rslv, int(cacheSize), ttl, clk)
+	if err != nil {
+		return DNSResolverOut{}, err
+	}
+	rslv, err = madns.NewResolver(madns.WithDefaultResolver(cache))
+	if err != nil {
+		return DNSResolverOut{}, err
+	}
+	return DNSResolverOut{Resolver: rslv, Cache: cache}, nil
+}
+
+var (
+	dnsCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ipfs_dns_cache_lookups_total",
+		Help: "DNS lookups, by record type (txt or ip) and result (hit, miss or error).",
+	}, []string{"type", "result"})
+	dnsCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ipfs_dns_cache_entries",
+		Help: "Lookups cached by the DNS resolver.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(dnsCacheLookups, dnsCacheEntries)
+}
+
+type dnsCacheKey struct {
+	txt    bool
+	domain string
+}
+
+type dnsCacheEntry struct {
+	ips     []net.IPAddr
+	txt     []string
+	expires time.Time
+}
+
+// DNSCache caches the successful lookups of a resolver for DNS.CacheTTL, in
+// DNSLink resolutions and /dns* multiaddrs alike, so that they can be purged
+// with 'ipfs dns purge' when a record changed.
+type DNSCache struct {
+	madns.BasicResolver
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu    sync.Mutex
+	cache *lru.Cache[dnsCacheKey, dnsCacheEntry]
+}
+
+// NewDNSCache wraps rslv with a cache of size lookups, kept for ttl.
+func NewDNSCache(rslv madns.BasicResolver, size int, ttl time.Duration, clk clock.Clock) (*DNSCache, error) {
+	cache, err := lru.New[dnsCacheKey, dnsCacheEntry](size)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSCache{BasicResolver: rslv, ttl: ttl, clock: clk, cache: cache}, nil
+}
+
+func (c *DNSCache) LookupIPAddr(ctx context.Context, domain string) ([]net.IPAddr, error) {
+	k := dnsCacheKey{domain: dnsCacheDomain(domain)}
+	if e, ok := c.get(k); ok {
+		dnsCacheLookups.WithLabelValues("ip", "hit").Inc()
+		return e.ips, nil
+	}
+	ips, err := c.BasicResolver.LookupIPAddr(ctx, domain)
+	if err != nil {
+		dnsCacheLookups.WithLabelValues("ip", "error").Inc()
+		return nil, err
+	}
+	dnsCacheLookups.WithLabelValues("ip", "miss").Inc()
+	c.add(k, dnsCacheEntry{ips: ips})
+	return ips, nil
+}
+
+func (c *DNSCache) LookupTXT(ctx context.Context, domain string) ([]string, error) {
+	k := dnsCacheKey{txt: true, domain: dnsCacheDomain(domain)}
+	if e, ok := c.get(k); ok {
+		dnsCacheLookups.WithLabelValues("txt", "hit").Inc()
+		return e.txt, nil
+	}
+	txt, err := c.BasicResolver.LookupTXT(ctx, domain)
+	if err != nil {
+		dnsCacheLookups.WithLabelValues("txt", "error").Inc()
+		return nil, err
+	}
+	dnsCacheLookups.WithLabelValues("txt", "miss").Inc()
+	c.add(k, dnsCacheEntry{txt: txt})
+	return txt, nil
+}
+
+func (c *DNSCache) get(k dnsCacheKey) (dnsCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache.Get(k)
+	if ok && !c.clock.Now().Before(e.expires) {
+		c.cache.Remove(k)
+		dnsCacheEntries.Set(float64(c.cache.Len()))
+		return e, false
+	}
+	return e, ok
+}
+
+func (c *DNSCache) add(k dnsCacheKey, e dnsCacheEntry) {
+	if c.ttl <= 0 {
+		return
+	}
+	e.expires = c.clock.Now().Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Add(k, e)
+	dnsCacheEntries.Set(float64(c.cache.Len()))
+}
+
+// Purge removes the lookups of the domains and of their subdomains, such as
+// the _dnslink subdomain, from the cache, or every lookup when no domain is
+// given. It returns the number of lookups removed.
+func (c *DNSCache) Purge(domains ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer func() { dnsCacheEntries.Set(float64(c.cache.Len())) }()
+	if len(domains) == 0 {
+		n := c.cache.Len()
+		c.cache.Purge()
+		return n
+	}
+	var n int
+	for _, k := range c.cache.Keys() {
+		for _, d := range domains {
+			d = dnsCacheDomain(d)
+			if k.domain == d || strings.HasSuffix(k.domain, "."+d) {
+				c.cache.Remove(k)
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
 
-	return gateway.NewDNSResolver(cfg.DNS.Resolvers, dohOpts...)
+// dnsCacheDomain normalizes a domain name, which may or may not be fully
+// qualified.
+func dnsCacheDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
 }
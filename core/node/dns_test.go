@@ -0,0 +1,72 @@
+package node
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// countingResolver resolves every domain to its own name in a TXT record.
+type countingResolver struct {
+	lookups int
+}
+
+func (r *countingResolver) LookupIPAddr(ctx context.Context, domain string) ([]net.IPAddr, error) {
+	r.lookups++
+	return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+func (r *countingResolver) LookupTXT(ctx context.Context, domain string) ([]string, error) {
+	r.lookups++
+	return []string{domain}, nil
+}
+
+func TestDNSCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("lookups are cached for the TTL", func(t *testing.T) {
+		r := &countingResolver{}
+		clk := clock.NewMock()
+		c, err := NewDNSCache(r, 10, time.Minute, clk)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			txt, err := c.LookupTXT(ctx, "_dnslink.example.com.")
+			require.NoError(t, err)
+			require.Equal(t, []string{"_dnslink.example.com."}, txt)
+			_, err = c.LookupIPAddr(ctx, "example.com")
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, r.lookups)
+
+		clk.Add(time.Minute)
+		_, err = c.LookupTXT(ctx, "_dnslink.example.com")
+		require.NoError(t, err)
+		require.Equal(t, 3, r.lookups)
+	})
+
+	t.Run("purge removes the domains and their subdomains", func(t *testing.T) {
+		r := &countingResolver{}
+		c, err := NewDNSCache(r, 10, time.Hour, clock.NewMock())
+		require.NoError(t, err)
+
+		for _, d := range []string{"_dnslink.example.com", "example.com", "_dnslink.example.net", "notexample.com"} {
+			_, err := c.LookupTXT(ctx, d)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, c.Purge("Example.com."))
+
+		_, err = c.LookupTXT(ctx, "_dnslink.example.net")
+		require.NoError(t, err)
+		_, err = c.LookupTXT(ctx, "example.com")
+		require.NoError(t, err)
+		require.Equal(t, 5, r.lookups)
+
+		require.Equal(t, 3, c.Purge())
+		require.Equal(t, 0, c.Purge())
+	})
+}
@@ -0,0 +1,279 @@
+package node
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	"github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	"github.com/ipfs/go-cid"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+// FetchPlanner probes which peers have the blocks of a DAG with Bitswap
+// want-have requests, without fetching the blocks, to plan a retrieval. The
+// answers are received by Bitswap, and seen by the planner as a tracer.
+type FetchPlanner struct {
+	h     host.Host
+	bsnet network.BitSwapNetwork
+
+	mu     sync.Mutex
+	probes map[peer.ID][]*haveProbe
+}
+
+// haveProbe collects the answers of a peer to the want-haves of a probe.
+type haveProbe struct {
+	wanted  map[cid.Cid]struct{}
+	have    map[cid.Cid]bool
+	done    chan struct{}
+	pending int
+}
+
+type fetchPlannerOut struct {
+	fx.Out
+
+	Planner *FetchPlanner
+	Tracer  tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// BitswapFetchPlanner provides the FetchPlanner of 'ipfs fetch plan'.
+func BitswapFetchPlanner(h host.Host) fetchPlannerOut {
+	p := newFetchPlanner(h, network.NewFromIpfsHost(h, routinghelpers.Null{}))
+	return fetchPlannerOut{Planner: p, Tracer: p}
+}
+
+func newFetchPlanner(h host.Host, bsnet network.BitSwapNetwork) *FetchPlanner {
+	return &FetchPlanner{
+		h:      h,
+		bsnet:  bsnet,
+		probes: make(map[peer.ID][]*haveProbe),
+	}
+}
+
+// Probe connects to ai, sends it a want-have for each of cids, and returns
+// whether it has them. The CIDs the peer did not answer for before ctx is done
+// are missing from the result, as are all of them when the peer only speaks
+// Bitswap 1.1 or older, which has no want-have.
+func (fp *FetchPlanner) Probe(ctx context.Context, ai peer.AddrInfo, cids []cid.Cid) (map[cid.Cid]bool, error) {
+	p := ai.ID
+	probe := &haveProbe{
+		wanted: make(map[cid.Cid]struct{}, len(cids)),
+		have:   make(map[cid.Cid]bool, len(cids)),
+		done:   make(chan struct{}),
+	}
+	msg := bsmsg.New(false)
+	for _, c := range cids {
+		if _, ok := probe.wanted[c]; ok {
+			continue
+		}
+		probe.wanted[c] = struct{}{}
+		msg.AddEntry(c, 0, pb.Message_Wantlist_Have, true)
+	}
+	probe.pending = len(probe.wanted)
+
+	fp.mu.Lock()
+	fp.probes[p] = append(fp.probes[p], probe)
+	fp.mu.Unlock()
+	defer fp.forget(p, probe)
+
+	if probe.pending == 0 {
+		return probe.have, nil
+	}
+	if err := fp.h.Connect(ctx, ai); err != nil {
+		return nil, err
+	}
+	if err := fp.bsnet.SendMessage(ctx, p, msg); err != nil {
+		return nil, err
+	}
+	select {
+	case <-probe.done:
+	case <-ctx.Done():
+	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	have := make(map[cid.Cid]bool, len(probe.have))
+	for c, ok := range probe.have {
+		have[c] = ok
+	}
+	return have, nil
+}
+
+func (fp *FetchPlanner) forget(p peer.ID, probe *haveProbe) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	probes := fp.probes[p]
+	for i, pr := range probes {
+		if pr == probe {
+			probes = append(probes[:i], probes[i+1:]...)
+			break
+		}
+	}
+	if len(probes) == 0 {
+		delete(fp.probes, p)
+	} else {
+		fp.probes[p] = probes
+	}
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (fp *FetchPlanner) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	probes := fp.probes[p]
+	if len(probes) == 0 {
+		return
+	}
+	for _, probe := range probes {
+		for _, c := range msg.Haves() {
+			probe.answer(c, true)
+		}
+		for _, c := range msg.DontHaves() {
+			probe.answer(c, false)
+		}
+		// the small blocks are sent instead of a HAVE
+		for _, b := range msg.Blocks() {
+			probe.answer(b.Cid(), true)
+		}
+	}
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (fp *FetchPlanner) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+func (probe *haveProbe) answer(c cid.Cid, have bool) {
+	if _, ok := probe.wanted[c]; !ok {
+		return
+	}
+	if _, ok := probe.have[c]; ok {
+		return
+	}
+	probe.have[c] = have
+	probe.pending--
+	if probe.pending == 0 {
+		close(probe.done)
+	}
+}
+
+// FetchPlanSubtree is a subtree of the DAG planned for retrieval.
+type FetchPlanSubtree struct {
+	Cid cid.Cid
+	// Size is the cumulative size of the blocks of the subtree.
+	Size uint64
+	// Peers are the peers that have the root of the subtree, and Peer the one
+	// it is planned to be fetched from, empty when no peer has it.
+	Peers []peer.ID
+	Peer  peer.ID `json:",omitempty"`
+}
+
+// FetchPlanPeer is the share of the retrieval planned from a peer.
+type FetchPlanPeer struct {
+	ID peer.ID
+	// Subtrees is the number of subtrees the peer has, and Bytes their size.
+	Subtrees int
+	Bytes    uint64
+	// PlannedSubtrees and PlannedBytes are the subtrees planned to be fetched
+	// from the peer.
+	PlannedSubtrees int
+	PlannedBytes    uint64
+}
+
+// FetchPlan is the estimated retrieval of a DAG.
+type FetchPlan struct {
+	Root      cid.Cid
+	RootPeers []peer.ID
+	Subtrees  []FetchPlanSubtree
+	Peers     []FetchPlanPeer
+	// Probed is the number of peers probed, and Parallelism the number of
+	// peers the subtrees are planned to be fetched from in parallel.
+	Probed      int
+	Parallelism int
+	// TotalBytes is the size of the DAG, and MissingBytes the size of the
+	// subtrees no peer has.
+	TotalBytes   uint64
+	MissingBytes uint64
+}
+
+// PlanFetch spreads the subtrees over the peers that have them, from the
+// answers of the peers to Probe: each subtree, from the largest, is planned
+// from the peer with the fewest bytes planned yet.
+func PlanFetch(root cid.Cid, rootSize uint64, subtrees []FetchPlanSubtree, haves map[peer.ID]map[cid.Cid]bool) *FetchPlan {
+	plan := &FetchPlan{
+		Root:       root,
+		RootPeers:  []peer.ID{},
+		Subtrees:   subtrees,
+		Peers:      []FetchPlanPeer{},
+		Probed:     len(haves),
+		TotalBytes: rootSize,
+	}
+
+	ids := make([]peer.ID, 0, len(haves))
+	for p := range haves {
+		ids = append(ids, p)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	byID := make(map[peer.ID]*FetchPlanPeer, len(ids))
+	for _, p := range ids {
+		if haves[p][root] {
+			plan.RootPeers = append(plan.RootPeers, p)
+		}
+	}
+
+	order := make([]int, len(subtrees))
+	for i := range subtrees {
+		order[i] = i
+		plan.TotalBytes += subtrees[i].Size
+	}
+	sort.SliceStable(order, func(i, j int) bool { return subtrees[order[i]].Size > subtrees[order[j]].Size })
+	for _, i := range order {
+		st := &plan.Subtrees[i]
+		st.Peers = []peer.ID{}
+		var best *FetchPlanPeer
+		for _, p := range ids {
+			if !haves[p][st.Cid] {
+				continue
+			}
+			st.Peers = append(st.Peers, p)
+			pp := byID[p]
+			if pp == nil {
+				pp = &FetchPlanPeer{ID: p}
+				byID[p] = pp
+			}
+			pp.Subtrees++
+			pp.Bytes += st.Size
+			if best == nil || pp.PlannedBytes < best.PlannedBytes {
+				best = pp
+			}
+		}
+		if best == nil {
+			plan.MissingBytes += st.Size
+			continue
+		}
+		st.Peer = best.ID
+		best.PlannedSubtrees++
+		best.PlannedBytes += st.Size
+	}
+
+	for _, p := range ids {
+		pp := byID[p]
+		if pp == nil {
+			continue
+		}
+		plan.Peers = append(plan.Peers, *pp)
+		if pp.PlannedSubtrees > 0 {
+			plan.Parallelism++
+		}
+	}
+	sort.SliceStable(plan.Peers, func(i, j int) bool { return plan.Peers[i].PlannedBytes > plan.Peers[j].PlannedBytes })
+	if len(subtrees) == 0 && len(plan.RootPeers) > 0 {
+		// a single block is fetched from one peer
+		plan.Parallelism = 1
+	}
+	return plan
+}
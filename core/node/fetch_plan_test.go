@@ -0,0 +1,73 @@
+package node
+
+import (
+	"testing"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchPlannerAnswers(t *testing.T) {
+	have, dontHave, small := blocks.NewBlock([]byte("have")), blocks.NewBlock([]byte("dont-have")), blocks.NewBlock([]byte("small"))
+	fp := newFetchPlanner(nil, nil)
+	probe := &haveProbe{
+		wanted:  map[cid.Cid]struct{}{have.Cid(): {}, dontHave.Cid(): {}, small.Cid(): {}},
+		have:    map[cid.Cid]bool{},
+		done:    make(chan struct{}),
+		pending: 3,
+	}
+	a := peer.ID("a")
+	fp.probes[a] = []*haveProbe{probe}
+
+	msg := bsmsg.New(false)
+	msg.AddHave(have.Cid())
+	msg.AddDontHave(dontHave.Cid())
+	fp.MessageReceived(peer.ID("b"), msg)
+	require.Empty(t, probe.have, "the answers of other peers are ignored")
+	fp.MessageReceived(a, msg)
+	require.Equal(t, map[cid.Cid]bool{have.Cid(): true, dontHave.Cid(): false}, probe.have)
+
+	msg = bsmsg.New(false)
+	msg.AddBlock(small)
+	fp.MessageReceived(a, msg)
+	select {
+	case <-probe.done:
+	default:
+		t.Fatal("the probe is not done once every CID is answered")
+	}
+	require.True(t, probe.have[small.Cid()])
+}
+
+func TestPlanFetch(t *testing.T) {
+	root := blocks.NewBlock([]byte("root")).Cid()
+	st := func(s string, size uint64) FetchPlanSubtree {
+		return FetchPlanSubtree{Cid: blocks.NewBlock([]byte(s)).Cid(), Size: size}
+	}
+	subtrees := []FetchPlanSubtree{st("a", 100), st("b", 300), st("c", 200), st("d", 50)}
+	full, partial, none := peer.ID("full"), peer.ID("partial"), peer.ID("none")
+	haves := map[peer.ID]map[cid.Cid]bool{
+		full:    {root: true, subtrees[0].Cid: true, subtrees[1].Cid: true, subtrees[2].Cid: true},
+		partial: {subtrees[1].Cid: true, subtrees[2].Cid: true},
+		none:    {},
+	}
+
+	plan := PlanFetch(root, 10, subtrees, haves)
+	require.Equal(t, 3, plan.Probed)
+	require.Equal(t, []peer.ID{full}, plan.RootPeers)
+	require.EqualValues(t, 660, plan.TotalBytes)
+	require.EqualValues(t, 50, plan.MissingBytes, "no peer has d")
+	require.Equal(t, 2, plan.Parallelism)
+
+	// b goes to full, c to partial, then a to full
+	require.Equal(t, full, plan.Subtrees[1].Peer)
+	require.Equal(t, partial, plan.Subtrees[2].Peer)
+	require.Equal(t, full, plan.Subtrees[0].Peer)
+	require.Empty(t, plan.Subtrees[3].Peer)
+	require.Equal(t, []FetchPlanPeer{
+		{ID: full, Subtrees: 3, Bytes: 600, PlannedSubtrees: 2, PlannedBytes: 400},
+		{ID: partial, Subtrees: 2, Bytes: 500, PlannedSubtrees: 1, PlannedBytes: 200},
+	}, plan.Peers)
+}
@@ -0,0 +1,225 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/boxo/exchange"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+)
+
+// FetchPriority is the scheduling class of a block fetch. Higher classes are
+// admitted first when Bitswap.PriorityClasses limits concurrent fetches.
+type FetchPriority int
+
+const (
+	FetchPriorityBackground FetchPriority = iota
+	FetchPriorityNormal
+	FetchPriorityInteractive
+
+	numFetchPriorities = int(FetchPriorityInteractive) + 1
+)
+
+func (p FetchPriority) String() string {
+	switch p {
+	case FetchPriorityBackground:
+		return "background"
+	case FetchPriorityNormal:
+		return "normal"
+	case FetchPriorityInteractive:
+		return "interactive"
+	default:
+		return fmt.Sprintf("<invalid fetch priority %d>", int(p))
+	}
+}
+
+type fetchPriorityKey struct{}
+
+// WithFetchPriority returns a context whose block fetches are scheduled with
+// the given priority.
+func WithFetchPriority(ctx context.Context, p FetchPriority) context.Context {
+	return context.WithValue(ctx, fetchPriorityKey{}, p)
+}
+
+// FetchPriorityFromContext returns the priority set with WithFetchPriority.
+func FetchPriorityFromContext(ctx context.Context) (FetchPriority, bool) {
+	p, ok := ctx.Value(fetchPriorityKey{}).(FetchPriority)
+	return p, ok
+}
+
+// fetchScheduler bounds the number of in-flight fetches and admits waiting
+// fetches highest priority first. Some slots are reserved for interactive
+// fetches so they never queue behind a saturated background workload.
+type fetchScheduler struct {
+	lk       sync.Mutex
+	limit    int
+	reserved int
+	active   int
+	waiters  [numFetchPriorities][]chan struct{}
+}
+
+func newFetchScheduler(limit, reserved int) *fetchScheduler {
+	return &fetchScheduler{limit: limit, reserved: reserved}
+}
+
+func (s *fetchScheduler) canRun(p FetchPriority) bool {
+	if p == FetchPriorityInteractive {
+		return s.active < s.limit
+	}
+	return s.active < s.limit-s.reserved
+}
+
+func (s *fetchScheduler) acquire(ctx context.Context, p FetchPriority) error {
+	s.lk.Lock()
+	if s.canRun(p) {
+		s.active++
+		s.lk.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.waiters[p] = append(s.waiters[p], ready)
+	s.lk.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.lk.Lock()
+		removed := false
+		for i, w := range s.waiters[p] {
+			if w == ready {
+				s.waiters[p] = append(s.waiters[p][:i], s.waiters[p][i+1:]...)
+				removed = true
+				break
+			}
+		}
+		s.lk.Unlock()
+		if !removed {
+			// The slot was handed over while we were giving up.
+			s.release()
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *fetchScheduler) release() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.active--
+	for p := numFetchPriorities - 1; p >= 0; p-- {
+		for len(s.waiters[p]) > 0 && s.canRun(FetchPriority(p)) {
+			close(s.waiters[p][0])
+			s.waiters[p] = s.waiters[p][1:]
+			s.active++
+		}
+	}
+}
+
+// prioritizedExchange schedules block fetches of the wrapped exchange
+// according to the priority carried by the request context.
+type prioritizedExchange struct {
+	exchange.Interface
+	sched *fetchScheduler
+}
+
+var _ exchange.SessionExchange = (*prioritizedExchange)(nil)
+
+// Unwrap returns the wrapped exchange.
+func (e *prioritizedExchange) Unwrap() exchange.Interface {
+	return e.Interface
+}
+
+// PrioritizedExchange wraps rem so concurrent fetches are limited per
+// Bitswap.PriorityClasses. The exchange is returned as-is when scheduling is
+// disabled.
+func PrioritizedExchange(cfg config.BitswapPriorityClasses, rem exchange.Interface) (exchange.Interface, error) {
+	limit := cfg.MaxConcurrentFetches.WithDefault(0)
+	if limit == 0 {
+		return rem, nil
+	}
+	reserved := cfg.InteractiveReserved.WithDefault(limit / 4)
+	if limit < 0 || reserved < 0 || reserved >= limit {
+		return nil, fmt.Errorf("invalid Bitswap.PriorityClasses: MaxConcurrentFetches=%d InteractiveReserved=%d", limit, reserved)
+	}
+	return &prioritizedExchange{Interface: rem, sched: newFetchScheduler(int(limit), int(reserved))}, nil
+}
+
+func (e *prioritizedExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return scheduledGetBlock(ctx, e.sched, FetchPriorityNormal, e.Interface, c)
+}
+
+func (e *prioritizedExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return scheduledGetBlocks(ctx, e.sched, FetchPriorityNormal, e.Interface, cids)
+}
+
+func (e *prioritizedExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	var f exchange.Fetcher = e.Interface
+	if sessEx, ok := e.Interface.(exchange.SessionExchange); ok {
+		f = sessEx.NewSession(ctx)
+	}
+	p, ok := FetchPriorityFromContext(ctx)
+	if !ok {
+		p = FetchPriorityNormal
+	}
+	return &prioritizedFetcher{Fetcher: f, sched: e.sched, priority: p}
+}
+
+// prioritizedFetcher is a session whose calls default to the priority of the
+// context the session was created with.
+type prioritizedFetcher struct {
+	exchange.Fetcher
+	sched    *fetchScheduler
+	priority FetchPriority
+}
+
+func (f *prioritizedFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return scheduledGetBlock(ctx, f.sched, f.priority, f.Fetcher, c)
+}
+
+func (f *prioritizedFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return scheduledGetBlocks(ctx, f.sched, f.priority, f.Fetcher, cids)
+}
+
+func scheduledGetBlock(ctx context.Context, sched *fetchScheduler, def FetchPriority, f exchange.Fetcher, c cid.Cid) (blocks.Block, error) {
+	p, ok := FetchPriorityFromContext(ctx)
+	if !ok {
+		p = def
+	}
+	if err := sched.acquire(ctx, p); err != nil {
+		return nil, err
+	}
+	defer sched.release()
+	return f.GetBlock(ctx, c)
+}
+
+func scheduledGetBlocks(ctx context.Context, sched *fetchScheduler, def FetchPriority, f exchange.Fetcher, cids []cid.Cid) (<-chan blocks.Block, error) {
+	p, ok := FetchPriorityFromContext(ctx)
+	if !ok {
+		p = def
+	}
+	if err := sched.acquire(ctx, p); err != nil {
+		return nil, err
+	}
+	in, err := f.GetBlocks(ctx, cids)
+	if err != nil {
+		sched.release()
+		return nil, err
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer sched.release()
+		for b := range in {
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
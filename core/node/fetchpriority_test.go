@@ -0,0 +1,68 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchScheduler(t *testing.T) {
+	t.Run("interactive fetches use reserved slots", func(t *testing.T) {
+		s := newFetchScheduler(2, 1)
+		ctx := context.Background()
+
+		require.NoError(t, s.acquire(ctx, FetchPriorityBackground))
+
+		// The second slot is reserved for interactive fetches.
+		short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, s.acquire(short, FetchPriorityNormal), context.DeadlineExceeded)
+
+		require.NoError(t, s.acquire(ctx, FetchPriorityInteractive))
+	})
+
+	t.Run("waiters are admitted highest priority first", func(t *testing.T) {
+		s := newFetchScheduler(1, 0)
+		ctx := context.Background()
+		require.NoError(t, s.acquire(ctx, FetchPriorityNormal))
+
+		order := make(chan FetchPriority, 2)
+		waitFor := func(p FetchPriority) {
+			go func() {
+				if err := s.acquire(ctx, p); err == nil {
+					order <- p
+				}
+			}()
+		}
+		waitFor(FetchPriorityBackground)
+		require.Eventually(t, func() bool { return waiting(s, FetchPriorityBackground) == 1 }, time.Second, time.Millisecond)
+		waitFor(FetchPriorityInteractive)
+		require.Eventually(t, func() bool { return waiting(s, FetchPriorityInteractive) == 1 }, time.Second, time.Millisecond)
+
+		s.release()
+		require.Equal(t, FetchPriorityInteractive, <-order)
+		s.release()
+		require.Equal(t, FetchPriorityBackground, <-order)
+	})
+
+	t.Run("cancelled waiters do not leak slots", func(t *testing.T) {
+		s := newFetchScheduler(1, 0)
+		ctx := context.Background()
+		require.NoError(t, s.acquire(ctx, FetchPriorityNormal))
+
+		short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.Error(t, s.acquire(short, FetchPriorityNormal))
+
+		s.release()
+		require.NoError(t, s.acquire(ctx, FetchPriorityNormal))
+	})
+}
+
+func waiting(s *fetchScheduler, p FetchPriority) int {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return len(s.waiters[p])
+}
@@ -0,0 +1,314 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	"github.com/ipfs/boxo/exchange"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/logging"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+// Cache results of the gateway access log records.
+const (
+	// GatewayCacheHit requests were served from the blockstore.
+	GatewayCacheHit = "hit"
+	// GatewayCacheMiss requests fetched blocks from the network.
+	GatewayCacheMiss = "miss"
+)
+
+// GatewayAccessRecord is the record of a gateway request written to the
+// access log.
+type GatewayAccessRecord struct {
+	Time   time.Time
+	Client string
+	Method string
+	Host   string
+	Path   string
+	Status int
+	// Root is the CID the requested path was resolved from, which is the
+	// record of the name for /ipns/ paths.
+	Root  string `json:",omitempty"`
+	Bytes int64
+	Cache string
+	// BlocksFetched are the blocks fetched from the network to serve the
+	// request, and Peers the peers which sent them.
+	BlocksFetched int      `json:",omitempty"`
+	Peers         []string `json:",omitempty"`
+	// TimeToFirstByte and Duration are nanoseconds.
+	TimeToFirstByte time.Duration
+	Duration        time.Duration
+	Referer         string `json:",omitempty"`
+	UserAgent       string `json:",omitempty"`
+}
+
+// GatewayAccessLog writes the access log of the gateway, see
+// Gateway.AccessLog. It attributes the blocks fetched from the network for
+// each request to the peers which sent them.
+type GatewayAccessLog struct {
+	mu  sync.Mutex
+	w   io.WriteCloser
+	enc *json.Encoder
+
+	wantsMu sync.Mutex
+	wants   map[cid.Cid]map[*GatewayFetches]struct{}
+}
+
+// GatewayFetches are the blocks fetched from the network for one gateway
+// request.
+type GatewayFetches struct {
+	mu     sync.Mutex
+	blocks int
+	peers  map[peer.ID]struct{}
+}
+
+type gatewayFetchesKey struct{}
+
+type gatewayAccessLogOut struct {
+	fx.Out
+
+	Log    *GatewayAccessLog
+	Tracer tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// GatewayAccessLogging writes the access log of the gateway when
+// Gateway.AccessLog is enabled. The requests are recorded by the HTTP server
+// of the gateway, and their block fetches by OnlineExchange.
+func GatewayAccessLogging(cfg config.GatewayAccessLog) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultGatewayAccessLogEnabled) {
+		return fx.Options()
+	}
+	maxSize, err := humanize.ParseBytes(cfg.MaxFileSize.WithDefault(config.DefaultLoggingMaxFileSize))
+	if err != nil {
+		return fx.Error(fmt.Errorf("Gateway.AccessLog.MaxFileSize: %w", err))
+	}
+	maxBackups := cfg.MaxBackups.WithDefault(config.DefaultLoggingMaxBackups)
+	if maxBackups < 0 {
+		return fx.Error(fmt.Errorf("Gateway.AccessLog.MaxBackups must not be negative"))
+	}
+	file := cfg.File.WithDefault(config.DefaultGatewayAccessLogFile)
+	if file == "" {
+		return fx.Error(fmt.Errorf("Gateway.AccessLog.File must not be empty"))
+	}
+
+	return fx.Provide(func(lc fx.Lifecycle, r repo.Repo) (gatewayAccessLogOut, error) {
+		path := file
+		if root, ok := r.(interface{ Path() string }); ok && !filepath.IsAbs(path) {
+			path = filepath.Join(root.Path(), path)
+		}
+		w, err := logging.OpenRotatingFile(path, int64(maxSize), cfg.RotateInterval.WithDefault(config.DefaultLoggingRotateInterval), int(maxBackups))
+		if err != nil {
+			return gatewayAccessLogOut{}, fmt.Errorf("Gateway.AccessLog.File: %w", err)
+		}
+		l := newGatewayAccessLog(w)
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				return l.close()
+			},
+		})
+		return gatewayAccessLogOut{Log: l, Tracer: l}, nil
+	})
+}
+
+func newGatewayAccessLog(w io.WriteCloser) *GatewayAccessLog {
+	return &GatewayAccessLog{
+		w:     w,
+		enc:   json.NewEncoder(w),
+		wants: make(map[cid.Cid]map[*GatewayFetches]struct{}),
+	}
+}
+
+// Track returns a context whose block fetches are counted in the returned
+// GatewayFetches.
+func (l *GatewayAccessLog) Track(ctx context.Context) (context.Context, *GatewayFetches) {
+	f := &GatewayFetches{peers: make(map[peer.ID]struct{})}
+	return context.WithValue(ctx, gatewayFetchesKey{}, f), f
+}
+
+// Write appends rec to the access log.
+func (l *GatewayAccessLog) Write(rec *GatewayAccessRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(rec); err != nil {
+		logger.Errorf("writing the gateway access log: %s", err)
+	}
+}
+
+func (l *GatewayAccessLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Close()
+}
+
+// Result returns the number of blocks fetched and the peers which sent them.
+func (f *GatewayFetches) Result() (int, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	peers := make([]string, 0, len(f.peers))
+	for p := range f.peers {
+		peers = append(peers, p.String())
+	}
+	sort.Strings(peers)
+	return f.blocks, peers
+}
+
+func (l *GatewayAccessLog) want(f *GatewayFetches, cids ...cid.Cid) {
+	l.wantsMu.Lock()
+	defer l.wantsMu.Unlock()
+	for _, c := range cids {
+		fetches := l.wants[c]
+		if fetches == nil {
+			fetches = make(map[*GatewayFetches]struct{})
+			l.wants[c] = fetches
+		}
+		fetches[f] = struct{}{}
+	}
+}
+
+func (l *GatewayAccessLog) unwant(f *GatewayFetches, cids ...cid.Cid) {
+	l.wantsMu.Lock()
+	defer l.wantsMu.Unlock()
+	for _, c := range cids {
+		delete(l.wants[c], f)
+		if len(l.wants[c]) == 0 {
+			delete(l.wants, c)
+		}
+	}
+}
+
+// MessageReceived implements the bitswap tracer interface, attributing the
+// blocks received to the requests waiting for them.
+func (l *GatewayAccessLog) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	received := msg.Blocks()
+	if len(received) == 0 {
+		return
+	}
+
+	l.wantsMu.Lock()
+	defer l.wantsMu.Unlock()
+	for _, b := range received {
+		for f := range l.wants[b.Cid()] {
+			f.mu.Lock()
+			f.peers[p] = struct{}{}
+			f.mu.Unlock()
+		}
+	}
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (l *GatewayAccessLog) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+func (f *GatewayFetches) fetched() {
+	f.mu.Lock()
+	f.blocks++
+	f.mu.Unlock()
+}
+
+// accessLogExchange counts the blocks fetched for the gateway requests
+// tracked by the access log.
+type accessLogExchange struct {
+	exchange.Interface
+	log *GatewayAccessLog
+}
+
+var _ exchange.SessionExchange = (*accessLogExchange)(nil)
+
+// Unwrap returns the wrapped exchange.
+func (e *accessLogExchange) Unwrap() exchange.Interface {
+	return e.Interface
+}
+
+func (e *accessLogExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return accessLogGetBlock(ctx, e.log, e.Interface, c)
+}
+
+func (e *accessLogExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return accessLogGetBlocks(ctx, e.log, e.Interface, cids)
+}
+
+func (e *accessLogExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	if sessEx, ok := e.Interface.(exchange.SessionExchange); ok {
+		return &accessLogFetcher{Fetcher: sessEx.NewSession(ctx), log: e.log}
+	}
+	return e
+}
+
+// GetWantlist returns the wants of the wrapped exchange, for
+// Bitswap.PersistWantlist.
+func (e *accessLogExchange) GetWantlist() []cid.Cid {
+	if wl, ok := e.Interface.(bitswapWantlister); ok {
+		return wl.GetWantlist()
+	}
+	return nil
+}
+
+type accessLogFetcher struct {
+	exchange.Fetcher
+	log *GatewayAccessLog
+}
+
+func (f *accessLogFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return accessLogGetBlock(ctx, f.log, f.Fetcher, c)
+}
+
+func (f *accessLogFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return accessLogGetBlocks(ctx, f.log, f.Fetcher, cids)
+}
+
+func accessLogGetBlock(ctx context.Context, l *GatewayAccessLog, f exchange.Fetcher, c cid.Cid) (blocks.Block, error) {
+	fetches, ok := ctx.Value(gatewayFetchesKey{}).(*GatewayFetches)
+	if !ok {
+		return f.GetBlock(ctx, c)
+	}
+	l.want(fetches, c)
+	defer l.unwant(fetches, c)
+	b, err := f.GetBlock(ctx, c)
+	if err == nil {
+		fetches.fetched()
+	}
+	return b, err
+}
+
+func accessLogGetBlocks(ctx context.Context, l *GatewayAccessLog, f exchange.Fetcher, cids []cid.Cid) (<-chan blocks.Block, error) {
+	fetches, ok := ctx.Value(gatewayFetchesKey{}).(*GatewayFetches)
+	if !ok {
+		return f.GetBlocks(ctx, cids)
+	}
+	l.want(fetches, cids...)
+	in, err := f.GetBlocks(ctx, cids)
+	if err != nil {
+		l.unwant(fetches, cids...)
+		return nil, err
+	}
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer l.unwant(fetches, cids...)
+		for b := range in {
+			fetches.fetched()
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				// Drain so the request can end.
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,302 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ipfs-shipyard/nopfs"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+	"go.uber.org/fx"
+)
+
+// gatewayDenylistReloadDelay is how long the changes of a denylist file are
+// awaited before it is reloaded, so a file being written is loaded once.
+const gatewayDenylistReloadDelay = 100 * time.Millisecond
+
+// GatewayDenylists are the denylists of the hostnames of
+// Gateway.PublicGateways, which only apply to the requests for them. They are
+// reloaded when their files change.
+type GatewayDenylists struct {
+	hosts   []*gatewayHostDenylists
+	watcher *fsnotify.Watcher
+}
+
+// gatewayHostDenylists are the denylists of a hostname, in the order of
+// GatewaySpec.Denylists.
+type gatewayHostDenylists struct {
+	hostname string
+	pattern  *regexp.Regexp
+	files    []*gatewayDenylistFile
+}
+
+// gatewayDenylistFile is a denylist file of a hostname, and its matches.
+type gatewayDenylistFile struct {
+	path string
+
+	mu     sync.RWMutex
+	list   *nopfs.Denylist
+	loaded time.Time
+	err    error
+	reload *time.Timer
+
+	blocked atomic.Uint64
+	allowed atomic.Uint64
+}
+
+// GatewayDenylistStatus is the state of a denylist of a hostname, as
+// reported by ipfs gateway denylist status.
+type GatewayDenylistStatus struct {
+	Hostname string
+	File     string
+	// Entries are the rules loaded from the file.
+	Entries int
+	// Blocked and Allowed count the requests which matched a rule of the
+	// file, since the daemon started.
+	Blocked uint64
+	Allowed uint64
+	Loaded  time.Time
+	// Error is the reason the last change of the file was not loaded, in
+	// which case the previous rules are still applied.
+	Error string `json:",omitempty"`
+}
+
+// GatewayDenylisting loads the denylists of Gateway.PublicGateways, if any.
+func GatewayDenylisting(cfg config.Gateway) fx.Option {
+	hostnames := make([]string, 0, len(cfg.PublicGateways))
+	for hostname, gw := range cfg.PublicGateways {
+		if gw != nil && len(gw.Denylists) > 0 {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	if len(hostnames) == 0 {
+		return fx.Options()
+	}
+	sort.Strings(hostnames)
+
+	return fx.Provide(func(lc fx.Lifecycle, r repo.Repo) (*GatewayDenylists, error) {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		d := &GatewayDenylists{watcher: watcher}
+		for _, hostname := range hostnames {
+			h := &gatewayHostDenylists{hostname: hostname, pattern: gatewayHostnamePattern(hostname)}
+			for _, file := range cfg.PublicGateways[hostname].Denylists {
+				if root, ok := r.(interface{ Path() string }); ok && !filepath.IsAbs(file) {
+					file = filepath.Join(root.Path(), file)
+				}
+				f := &gatewayDenylistFile{path: filepath.Clean(file)}
+				if err := f.load(); err != nil {
+					d.close()
+					return nil, fmt.Errorf("Gateway.PublicGateways[%q].Denylists: %w", hostname, err)
+				}
+				if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+					d.close()
+					return nil, fmt.Errorf("watching %s: %w", f.path, err)
+				}
+				h.files = append(h.files, f)
+			}
+			d.hosts = append(d.hosts, h)
+		}
+
+		go d.watch()
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				d.close()
+				return nil
+			},
+		})
+		return d, nil
+	})
+}
+
+// gatewayHostnamePattern returns the pattern of a hostname with wildcard
+// labels, such as *.example.com, or nil.
+func gatewayHostnamePattern(hostname string) *regexp.Regexp {
+	if !strings.Contains(hostname, "*") {
+		return nil
+	}
+	labels := strings.Split(hostname, ".")
+	for i, label := range labels {
+		if label == "*" {
+			labels[i] = "[^.]+"
+		} else {
+			labels[i] = regexp.QuoteMeta(label)
+		}
+	}
+	return regexp.MustCompile("^" + strings.Join(labels, `\.`) + "$")
+}
+
+func (h *gatewayHostDenylists) matches(hostname string) bool {
+	if h.pattern != nil {
+		return h.pattern.MatchString(hostname)
+	}
+	return h.hostname == hostname
+}
+
+// lookup returns the denylists of the hostname of a request, which is either
+// the one of a public gateway, or a subdomain of it such as
+// <cid>.ipfs.example.com.
+func (d *GatewayDenylists) lookup(host string) *gatewayHostDenylists {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, h := range d.hosts {
+		if h.matches(host) {
+			return h
+		}
+	}
+	labels := strings.Split(host, ".")
+	for i := 1; i < len(labels)-1; i++ {
+		if labels[i] != "ipfs" && labels[i] != "ipns" {
+			continue
+		}
+		gateway := strings.Join(labels[i+1:], ".")
+		for _, h := range d.hosts {
+			if h.matches(gateway) {
+				return h
+			}
+		}
+	}
+	return nil
+}
+
+// IsPathBlocked tells whether p is blocked by the denylists of host. The
+// first denylist with a rule matching p decides.
+func (d *GatewayDenylists) IsPathBlocked(host string, p path.Path) nopfs.StatusResponse {
+	return d.check(host, func(dl *nopfs.Denylist) nopfs.StatusResponse { return dl.IsPathBlocked(p) })
+}
+
+// IsCidBlocked tells whether c is blocked by the denylists of host.
+func (d *GatewayDenylists) IsCidBlocked(host string, c cid.Cid) nopfs.StatusResponse {
+	return d.check(host, func(dl *nopfs.Denylist) nopfs.StatusResponse { return dl.IsCidBlocked(c) })
+}
+
+func (d *GatewayDenylists) check(host string, check func(*nopfs.Denylist) nopfs.StatusResponse) nopfs.StatusResponse {
+	h := d.lookup(host)
+	if h == nil {
+		return nopfs.StatusResponse{Status: nopfs.StatusNotFound}
+	}
+	for _, f := range h.files {
+		f.mu.RLock()
+		resp := check(f.list)
+		f.mu.RUnlock()
+		switch resp.Status {
+		case nopfs.StatusBlocked:
+			f.blocked.Add(1)
+			return resp
+		case nopfs.StatusAllowed:
+			f.allowed.Add(1)
+			return resp
+		}
+	}
+	return nopfs.StatusResponse{Status: nopfs.StatusNotFound}
+}
+
+// Status returns the state of the denylists, by hostname.
+func (d *GatewayDenylists) Status() []GatewayDenylistStatus {
+	var out []GatewayDenylistStatus
+	for _, h := range d.hosts {
+		for _, f := range h.files {
+			f.mu.RLock()
+			st := GatewayDenylistStatus{
+				Hostname: h.hostname,
+				File:     f.path,
+				Entries:  len(f.list.Entries),
+				Blocked:  f.blocked.Load(),
+				Allowed:  f.allowed.Load(),
+				Loaded:   f.loaded,
+			}
+			if f.err != nil {
+				st.Error = f.err.Error()
+			}
+			f.mu.RUnlock()
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// watch reloads the denylist files when they are written, replaced or
+// removed.
+func (d *GatewayDenylists) watch() {
+	for {
+		select {
+		case ev, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Clean(ev.Name)
+			for _, h := range d.hosts {
+				for _, f := range h.files {
+					if f.path == name {
+						f.scheduleReload()
+					}
+				}
+			}
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Errorf("watching the gateway denylists: %s", err)
+		}
+	}
+}
+
+func (d *GatewayDenylists) close() {
+	d.watcher.Close()
+	for _, h := range d.hosts {
+		for _, f := range h.files {
+			f.mu.Lock()
+			if f.reload != nil {
+				f.reload.Stop()
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+func (f *gatewayDenylistFile) scheduleReload() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reload != nil {
+		f.reload.Reset(gatewayDenylistReloadDelay)
+		return
+	}
+	f.reload = time.AfterFunc(gatewayDenylistReloadDelay, func() {
+		if err := f.load(); err != nil {
+			logger.Errorf("reloading the gateway denylist %s, its previous rules are kept: %s", f.path, err)
+		}
+	})
+}
+
+// load replaces the rules of f with the ones of its file. When the file
+// cannot be loaded, the previous rules are kept.
+func (f *gatewayDenylistFile) load() error {
+	list, err := nopfs.NewDenylist(f.path, false)
+	if err == nil {
+		// the rules are loaded, and the file is watched by GatewayDenylists
+		_ = list.Close()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		f.err = err
+		return err
+	}
+	f.list, f.loaded, f.err = list, time.Now(), nil
+	return nil
+}
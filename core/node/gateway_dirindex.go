@@ -0,0 +1,263 @@
+package node
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+	"go.uber.org/fx"
+)
+
+var gatewayDirIndexKey = datastore.NewKey("/local/gateway/dirindex")
+
+// gatewayDirIndexEntryShare bounds the size of a directory to the share of
+// Gateway.DirectoryIndexCache.MaxSize, so a single huge directory does not
+// evict all the others.
+const gatewayDirIndexEntryShare = 8
+
+// GatewayDirectoryListing are the entries of a directory listed by the
+// gateway, as they are cached.
+type GatewayDirectoryListing struct {
+	// DagSize is the cumulative size of the DAG of the directory.
+	DagSize uint64
+	Entries []GatewayDirectoryEntry
+}
+
+// GatewayDirectoryEntry is an entry of a GatewayDirectoryListing.
+type GatewayDirectoryEntry struct {
+	Name string
+	Cid  cid.Cid
+	Size uint64
+}
+
+// GatewayDirectoryIndexEntry is a directory cached by the
+// GatewayDirectoryIndex, as reported by ipfs gateway dirindex ls.
+type GatewayDirectoryIndexEntry struct {
+	Cid     cid.Cid
+	Entries int
+	// Size is the space used by the directory in the datastore.
+	Size uint64
+}
+
+// GatewayDirectoryIndex caches the entries of the directories listed by the
+// gateway in the datastore, see Gateway.DirectoryIndexCache. The directories
+// are immutable, so the entries are only evicted when the cache is full, the
+// least recently listed first, or removed with ipfs gateway dirindex rm. The
+// recency of the entries is not persisted: after a restart, they are evicted
+// in the order of the datastore.
+type GatewayDirectoryIndex struct {
+	ds         datastore.Datastore
+	maxSize    uint64
+	minEntries int
+
+	mu      sync.Mutex
+	size    uint64
+	lru     *list.List // of *gatewayDirIndexItem, most recent first
+	entries map[cid.Cid]*list.Element
+}
+
+type gatewayDirIndexItem struct {
+	GatewayDirectoryIndexEntry
+	// hasIndexHTML tells whether the directory has an index.html, which the
+	// gateway serves instead of listing the directory.
+	hasIndexHTML bool
+}
+
+// GatewayDirectoryIndexing provides the GatewayDirectoryIndex when
+// Gateway.DirectoryIndexCache is enabled.
+func GatewayDirectoryIndexing(cfg config.GatewayDirectoryIndexCache) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultGatewayDirectoryIndexCacheEnabled) {
+		return fx.Options()
+	}
+	maxSize, err := humanize.ParseBytes(cfg.MaxSize.WithDefault(config.DefaultGatewayDirectoryIndexCacheMaxSize))
+	if err != nil {
+		return fx.Error(fmt.Errorf("Gateway.DirectoryIndexCache.MaxSize: %w", err))
+	}
+	minEntries := cfg.MinEntries.WithDefault(config.DefaultGatewayDirectoryIndexCacheMinEntries)
+	if minEntries < 0 {
+		return fx.Error(fmt.Errorf("Gateway.DirectoryIndexCache.MinEntries must not be negative"))
+	}
+
+	return fx.Provide(func(mctx helpers.MetricsCtx, repo repo.Repo) (*GatewayDirectoryIndex, error) {
+		return NewGatewayDirectoryIndex(mctx, repo.Datastore(), maxSize, int(minEntries))
+	})
+}
+
+// NewGatewayDirectoryIndex loads the directories cached in ds, within
+// maxSize bytes, and caches the directories of at least minEntries entries.
+func NewGatewayDirectoryIndex(ctx context.Context, ds datastore.Datastore, maxSize uint64, minEntries int) (*GatewayDirectoryIndex, error) {
+	x := &GatewayDirectoryIndex{
+		ds:         ds,
+		maxSize:    maxSize,
+		minEntries: minEntries,
+		lru:        list.New(),
+		entries:    make(map[cid.Cid]*list.Element),
+	}
+	results, err := ds.Query(ctx, query.Query{Prefix: gatewayDirIndexKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		key := datastore.NewKey(r.Key)
+		c, err := cid.Decode(key.BaseNamespace())
+		var listing GatewayDirectoryListing
+		if err == nil {
+			err = json.Unmarshal(r.Value, &listing)
+		}
+		if err != nil {
+			_ = ds.Delete(ctx, key)
+			continue
+		}
+		x.add(ctx, newGatewayDirIndexItem(c, &listing, len(r.Value)))
+	}
+	return x, nil
+}
+
+func gatewayDirIndexEntryKey(c cid.Cid) datastore.Key {
+	return gatewayDirIndexKey.ChildString(c.String())
+}
+
+func newGatewayDirIndexItem(c cid.Cid, listing *GatewayDirectoryListing, size int) *gatewayDirIndexItem {
+	item := &gatewayDirIndexItem{GatewayDirectoryIndexEntry: GatewayDirectoryIndexEntry{Cid: c, Entries: len(listing.Entries), Size: uint64(size)}}
+	for _, e := range listing.Entries {
+		if e.Name == "index.html" {
+			item.hasIndexHTML = true
+			break
+		}
+	}
+	return item
+}
+
+// MinEntries is the number of entries from which a directory is cached.
+func (x *GatewayDirectoryIndex) MinEntries() int {
+	return x.minEntries
+}
+
+// Get returns the cached entries of the directory c, if any.
+func (x *GatewayDirectoryIndex) Get(ctx context.Context, c cid.Cid) (*GatewayDirectoryListing, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	el, ok := x.entries[c]
+	if !ok {
+		return nil, false
+	}
+	b, err := x.ds.Get(ctx, gatewayDirIndexEntryKey(c))
+	var listing GatewayDirectoryListing
+	if err == nil {
+		err = json.Unmarshal(b, &listing)
+	}
+	if err != nil {
+		logger.Debugf("gateway directory index of %s: %s", c, err)
+		x.remove(ctx, el)
+		return nil, false
+	}
+	x.lru.MoveToFront(el)
+	return &listing, true
+}
+
+// HasIndexHTML tells whether the directory c has an index.html, when it is
+// cached, without reading its entries.
+func (x *GatewayDirectoryIndex) HasIndexHTML(c cid.Cid) (has bool, cached bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	el, ok := x.entries[c]
+	if !ok {
+		return false, false
+	}
+	return el.Value.(*gatewayDirIndexItem).hasIndexHTML, true
+}
+
+// Put caches the entries of the directory c, evicting the least recently
+// listed directories over the size of the cache. The directories of fewer
+// than MinEntries entries, or too large for the cache, are not cached.
+func (x *GatewayDirectoryIndex) Put(ctx context.Context, c cid.Cid, listing *GatewayDirectoryListing) error {
+	if len(listing.Entries) < x.minEntries {
+		return nil
+	}
+	b, err := json.Marshal(listing)
+	if err != nil {
+		return err
+	}
+	if uint64(len(b)) > x.maxSize/gatewayDirIndexEntryShare {
+		return nil
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if el, ok := x.entries[c]; ok {
+		x.lru.MoveToFront(el)
+		return nil
+	}
+	if err := x.ds.Put(ctx, gatewayDirIndexEntryKey(c), b); err != nil {
+		return err
+	}
+	x.add(ctx, newGatewayDirIndexItem(c, listing, len(b)))
+	return nil
+}
+
+// add indexes item as the most recent entry, evicting the least recent ones
+// over the size of the cache. The lock must be held, or x not shared yet.
+func (x *GatewayDirectoryIndex) add(ctx context.Context, item *gatewayDirIndexItem) {
+	x.entries[item.Cid] = x.lru.PushFront(item)
+	x.size += item.Size
+	for x.size > x.maxSize {
+		x.remove(ctx, x.lru.Back())
+	}
+}
+
+// remove deletes the entry of el. The lock must be held.
+func (x *GatewayDirectoryIndex) remove(ctx context.Context, el *list.Element) {
+	e := x.lru.Remove(el).(*gatewayDirIndexItem)
+	delete(x.entries, e.Cid)
+	x.size -= e.Size
+	if err := x.ds.Delete(ctx, gatewayDirIndexEntryKey(e.Cid)); err != nil {
+		logger.Errorf("removing the gateway directory index of %s: %s", e.Cid, err)
+	}
+}
+
+// List returns the cached directories, the most recently listed first.
+func (x *GatewayDirectoryIndex) List() []GatewayDirectoryIndexEntry {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	out := make([]GatewayDirectoryIndexEntry, 0, x.lru.Len())
+	for el := x.lru.Front(); el != nil; el = el.Next() {
+		out = append(out, el.Value.(*gatewayDirIndexItem).GatewayDirectoryIndexEntry)
+	}
+	return out
+}
+
+// Remove drops the cached entries of the directory c, and tells whether it
+// was cached.
+func (x *GatewayDirectoryIndex) Remove(ctx context.Context, c cid.Cid) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	el, ok := x.entries[c]
+	if ok {
+		x.remove(ctx, el)
+	}
+	return ok
+}
+
+// Clear drops all the cached directories, and returns how many there were.
+func (x *GatewayDirectoryIndex) Clear(ctx context.Context) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	n := x.lru.Len()
+	for x.lru.Len() > 0 {
+		x.remove(ctx, x.lru.Back())
+	}
+	return n
+}
@@ -0,0 +1,96 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func testDirCid(t *testing.T, i int) cid.Cid {
+	mh, err := multihash.Sum([]byte(fmt.Sprint(i)), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	return cid.NewCidV1(cid.DagProtobuf, mh)
+}
+
+func testDirListing(entries int) *GatewayDirectoryListing {
+	listing := &GatewayDirectoryListing{DagSize: 1234}
+	for i := 0; i < entries; i++ {
+		listing.Entries = append(listing.Entries, GatewayDirectoryEntry{Name: fmt.Sprintf("file-%d.txt", i), Size: 10})
+	}
+	return listing
+}
+
+func TestGatewayDirectoryIndex(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("directories are persisted", func(t *testing.T) {
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		x, err := NewGatewayDirectoryIndex(ctx, ds, 1<<20, 2)
+		require.NoError(t, err)
+		require.NoError(t, x.Put(ctx, testDirCid(t, 0), testDirListing(3)))
+		require.NoError(t, x.Put(ctx, testDirCid(t, 1), testDirListing(1)))
+
+		x, err = NewGatewayDirectoryIndex(ctx, ds, 1<<20, 2)
+		require.NoError(t, err)
+		listing, ok := x.Get(ctx, testDirCid(t, 0))
+		require.True(t, ok)
+		require.Equal(t, uint64(1234), listing.DagSize)
+		require.Len(t, listing.Entries, 3)
+		_, ok = x.Get(ctx, testDirCid(t, 1))
+		require.False(t, ok, "directories of fewer than MinEntries entries are not cached")
+
+		has, cached := x.HasIndexHTML(testDirCid(t, 0))
+		require.True(t, cached)
+		require.False(t, has)
+	})
+
+	t.Run("the least recently listed directories are evicted", func(t *testing.T) {
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		x, err := NewGatewayDirectoryIndex(ctx, ds, 1<<20, 0)
+		require.NoError(t, err)
+		require.NoError(t, x.Put(ctx, testDirCid(t, 0), testDirListing(10)))
+		size := x.List()[0].Size
+
+		x, err = NewGatewayDirectoryIndex(ctx, ds, 8*size+size/2, 0)
+		require.NoError(t, err)
+		for i := 1; i <= 8; i++ {
+			if i == 8 {
+				_, ok := x.Get(ctx, testDirCid(t, 0))
+				require.True(t, ok)
+			}
+			require.NoError(t, x.Put(ctx, testDirCid(t, i), testDirListing(10)))
+		}
+		list := x.List()
+		require.Len(t, list, 8)
+		require.Equal(t, testDirCid(t, 8), list[0].Cid)
+		require.Equal(t, testDirCid(t, 0), list[1].Cid)
+		_, ok := x.Get(ctx, testDirCid(t, 1))
+		require.False(t, ok)
+		has, err := ds.Has(ctx, gatewayDirIndexEntryKey(testDirCid(t, 1)))
+		require.NoError(t, err)
+		require.False(t, has)
+	})
+
+	t.Run("directories are purged", func(t *testing.T) {
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		x, err := NewGatewayDirectoryIndex(ctx, ds, 1<<20, 0)
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, x.Put(ctx, testDirCid(t, i), testDirListing(1)))
+		}
+		require.True(t, x.Remove(ctx, testDirCid(t, 0)))
+		require.False(t, x.Remove(ctx, testDirCid(t, 0)))
+		require.Equal(t, 2, x.Clear(ctx))
+		require.Empty(t, x.List())
+
+		x, err = NewGatewayDirectoryIndex(ctx, ds, 1<<20, 0)
+		require.NoError(t, err)
+		require.Empty(t, x.List())
+	})
+}
@@ -0,0 +1,64 @@
+package node
+
+import (
+	"strings"
+
+	"github.com/ipfs/boxo/path"
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// GatewayRedirect is the rule of a _redirects file applied by the gateway to
+// a path missing from the DAG.
+type GatewayRedirect struct {
+	// Rule is the number of the rule among the rules of the file, from 1.
+	Rule int
+	From string
+	// To is the target of the rule, with its placeholders and splat
+	// expanded.
+	To     string
+	Status int
+}
+
+// IsRewrite tells whether the rule serves its target with 200 OK.
+func (r *GatewayRedirect) IsRewrite() bool {
+	return r.Status == 200
+}
+
+// IsCustomError tells whether the rule serves its target with its 4xx
+// status.
+func (r *GatewayRedirect) IsCustomError() bool {
+	return r.Status == 404 || r.Status == 410 || r.Status == 451
+}
+
+// GatewayRedirectsPath returns the path of the _redirects file applying to
+// p, at the root of its DAG, and the path of p matched against its rules. ok
+// is false when p is the root, to which no rule applies.
+func GatewayRedirectsPath(p path.Path) (redirectsPath path.Path, urlPath string, ok bool) {
+	parts := strings.Split(p.String(), "/")
+	if len(parts) <= 3 {
+		return nil, "", false
+	}
+	redirectsPath, err := path.NewPath(strings.Join(parts[:3], "/") + "/_redirects")
+	if err != nil {
+		return nil, "", false
+	}
+	return redirectsPath, strings.TrimSuffix("/"+strings.Join(parts[3:], "/"), "/"), true
+}
+
+// MatchGatewayRedirects returns the first of rules the gateway applies to
+// urlPath, or nil when none applies, in which case the gateway answers 404
+// Not Found. Like the gateway, the rules of the statuses it does not support
+// are skipped.
+func MatchGatewayRedirects(rules []redirects.Rule, urlPath string) *GatewayRedirect {
+	for i, rule := range rules {
+		from := rule.From
+		if !rule.MatchAndExpandPlaceholders(urlPath) {
+			continue
+		}
+		r := &GatewayRedirect{Rule: i + 1, From: from, To: rule.To, Status: rule.Status}
+		if r.IsRewrite() || r.IsCustomError() || (r.Status >= 301 && r.Status <= 308) {
+			return r
+		}
+	}
+	return nil
+}
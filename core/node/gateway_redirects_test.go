@@ -0,0 +1,62 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/ipfs/boxo/path"
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayRedirectsPath(t *testing.T) {
+	const root = "/ipfs/bafkqaaa"
+	for p, want := range map[string]string{
+		root + "/app/settings/": "/app/settings",
+		root + "/a":             "/a",
+		"/ipns/example.net/a/b": "/a/b",
+	} {
+		pp, err := path.NewPath(p)
+		require.NoError(t, err)
+		redirectsPath, urlPath, ok := GatewayRedirectsPath(pp)
+		require.True(t, ok, p)
+		assert.Equal(t, want, urlPath, p)
+		assert.Equal(t, "/_redirects", redirectsPath.String()[len(redirectsPath.String())-len("/_redirects"):])
+	}
+
+	pp, err := path.NewPath(root)
+	require.NoError(t, err)
+	_, _, ok := GatewayRedirectsPath(pp)
+	assert.False(t, ok, "no rule applies to the root")
+}
+
+func TestMatchGatewayRedirects(t *testing.T) {
+	rules, err := redirects.ParseString(`
+# comments are not rules
+/old/:name /new/:name 301
+/app/* /index.html 200
+/gone /gone.html 410
+/* /404.html 404
+`)
+	require.NoError(t, err)
+
+	r := MatchGatewayRedirects(rules, "/old/page")
+	require.NotNil(t, r)
+	assert.Equal(t, GatewayRedirect{Rule: 1, From: "/old/:name", To: "/new/page", Status: 301}, *r)
+	assert.False(t, r.IsRewrite() || r.IsCustomError())
+
+	r = MatchGatewayRedirects(rules, "/app/settings")
+	require.NotNil(t, r)
+	assert.Equal(t, 2, r.Rule)
+	assert.True(t, r.IsRewrite())
+
+	r = MatchGatewayRedirects(rules, "/gone")
+	require.NotNil(t, r)
+	assert.Equal(t, 3, r.Rule)
+	assert.True(t, r.IsCustomError())
+
+	r = MatchGatewayRedirects(rules, "/missing")
+	require.NotNil(t, r)
+	assert.Equal(t, "/404.html", r.To)
+	assert.Nil(t, MatchGatewayRedirects(rules[:2], "/missing"))
+}
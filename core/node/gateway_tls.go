@@ -0,0 +1,563 @@
+package node
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+	"github.com/miekg/dns"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/fx"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	// gatewayTLSDir is the directory of the repo keeping the ACME account and
+	// certificates.
+	gatewayTLSDir = "gateway-tls"
+
+	// gatewayTLSFilesCheckInterval is the time between two checks of the
+	// changes of Gateway.TLS.CertFile and KeyFile.
+	gatewayTLSFilesCheckInterval = 10 * time.Second
+
+	// gatewayACMERenewBefore is how long before its expiry a certificate
+	// obtained with the dns-01 challenge is renewed, and
+	// gatewayACMERetryInterval the time between two attempts.
+	gatewayACMERenewBefore   = 30 * 24 * time.Hour
+	gatewayACMERetryInterval = time.Hour
+
+	// gatewayACMEAccountKey and gatewayACMEDNSCert name the ACME account key,
+	// shared with autocert, and the certificate of the dns-01 challenge in
+	// the gateway-tls directory.
+	gatewayACMEAccountKey = "acme_account+key"
+	gatewayACMEDNSCert    = "dns-01+cert"
+)
+
+// GatewayTLS provides the certificates of the gateway addresses ending with
+// /tls/http, see Gateway.TLS.
+type GatewayTLS struct {
+	config *tls.Config
+	start  func()
+	once   sync.Once
+}
+
+// TLSConfig returns the configuration of the TLS listeners of the gateway.
+// The certificates obtained with ACME are renewed from the first call on, so
+// that the commands which do not serve the gateway do not renew them.
+func (g *GatewayTLS) TLSConfig() *tls.Config {
+	if g.start != nil {
+		g.once.Do(g.start)
+	}
+	return g.config
+}
+
+// HasGatewayTLSAddress tells whether one of the Addresses.Gateway ends with
+// /tls/http.
+func HasGatewayTLSAddress(addrs []string) bool {
+	for _, addr := range addrs {
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			continue
+		}
+		if _, ok := SplitGatewayTLSAddress(maddr); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitGatewayTLSAddress returns the address to listen on of a gateway
+// address ending with /tls/http, or its deprecated alias /https.
+func SplitGatewayTLSAddress(maddr ma.Multiaddr) (ma.Multiaddr, bool) {
+	rest, last := ma.SplitLast(maddr)
+	switch {
+	case last == nil:
+		return maddr, false
+	case last.Protocol().Code == ma.P_HTTPS:
+		return rest, true
+	case last.Protocol().Code != ma.P_HTTP:
+		return maddr, false
+	}
+	rest, last = ma.SplitLast(rest)
+	if last == nil || last.Protocol().Code != ma.P_TLS {
+		return maddr, false
+	}
+	return rest, true
+}
+
+// GatewayTLSCertificates provides the GatewayTLS when one of the
+// Addresses.Gateway ends with /tls/http.
+func GatewayTLSCertificates(cfg *config.Config) fx.Option {
+	if !HasGatewayTLSAddress(cfg.Addresses.Gateway) {
+		return fx.Options()
+	}
+	tlsCfg := cfg.Gateway.TLS
+	certFile := tlsCfg.CertFile.WithDefault("")
+	keyFile := tlsCfg.KeyFile.WithDefault("")
+	acmeEnabled := tlsCfg.ACME.Enabled.WithDefault(config.DefaultGatewayACMEEnabled)
+	switch {
+	case acmeEnabled && (certFile != "" || keyFile != ""):
+		return fx.Error(errors.New("Gateway.TLS: set either CertFile and KeyFile, or ACME"))
+	case !acmeEnabled && (certFile == "" || keyFile == ""):
+		return fx.Error(errors.New("Gateway.TLS: the addresses ending with /tls/http require CertFile and KeyFile, or ACME"))
+	}
+
+	if !acmeEnabled {
+		return fx.Provide(func(r repo.Repo) (*GatewayTLS, error) {
+			files := &gatewayTLSFiles{certFile: repoFilePath(r, certFile), keyFile: repoFilePath(r, keyFile)}
+			if err := files.load(); err != nil {
+				return nil, fmt.Errorf("Gateway.TLS: %w", err)
+			}
+			return &GatewayTLS{config: &tls.Config{
+				GetCertificate: files.getCertificate,
+				NextProtos:     []string{"h2", "http/1.1"},
+			}}, nil
+		})
+	}
+
+	challenge := tlsCfg.ACME.Challenge.WithDefault(config.DefaultGatewayACMEChallenge)
+	if challenge != "tls-alpn-01" && challenge != "dns-01" {
+		return fx.Error(fmt.Errorf("Gateway.TLS.ACME.Challenge: unknown challenge %q, use tls-alpn-01 or dns-01", challenge))
+	}
+	domains := GatewayACMEDomains(cfg.Gateway, challenge)
+	if len(domains) == 0 {
+		return fx.Error(errors.New("Gateway.TLS.ACME.Domains: no domain to certify, set them or Gateway.PublicGateways"))
+	}
+	for _, domain := range domains {
+		if strings.HasPrefix(domain, "*.") && challenge != "dns-01" {
+			return fx.Error(fmt.Errorf("Gateway.TLS.ACME.Domains: the wildcard name %q requires the dns-01 challenge", domain))
+		}
+	}
+	var contact []string
+	if email := tlsCfg.ACME.Email.WithDefault(""); email != "" {
+		contact = []string{"mailto:" + email}
+	}
+	ca := tlsCfg.ACME.CA.WithDefault(config.DefaultGatewayACMECA)
+
+	return fx.Provide(func(lc fx.Lifecycle, r repo.Repo) (*GatewayTLS, error) {
+		cache := autocert.DirCache(repoFilePath(r, gatewayTLSDir))
+		if challenge == "tls-alpn-01" {
+			m := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				Cache:      cache,
+				HostPolicy: autocert.HostWhitelist(domains...),
+				Client:     &acme.Client{DirectoryURL: ca},
+			}
+			if len(contact) > 0 {
+				m.Email = strings.TrimPrefix(contact[0], "mailto:")
+			}
+			return &GatewayTLS{config: m.TLSConfig()}, nil
+		}
+
+		updater, err := newRFC2136Updater(r, tlsCfg.ACME.DNS)
+		if err != nil {
+			return nil, err
+		}
+		issuer := &gatewayACMEIssuer{
+			client:  &acme.Client{DirectoryURL: ca},
+			cache:   cache,
+			domains: domains,
+			contact: contact,
+			dns:     updater,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				cancel()
+				return nil
+			},
+		})
+		return &GatewayTLS{
+			config: &tls.Config{
+				GetCertificate: issuer.getCertificate,
+				NextProtos:     []string{"h2", "http/1.1"},
+			},
+			start: func() { go issuer.loop(ctx) },
+		}, nil
+	})
+}
+
+// GatewayACMEDomains returns Gateway.TLS.ACME.Domains, or by default the
+// hostnames of Gateway.PublicGateways without a wildcard, with the wildcard
+// names of the subdomain gateways when they can be validated.
+func GatewayACMEDomains(cfg config.Gateway, challenge string) []string {
+	if len(cfg.TLS.ACME.Domains) > 0 {
+		return cfg.TLS.ACME.Domains
+	}
+	var domains []string
+	for hostname, gw := range cfg.PublicGateways {
+		if gw == nil || strings.Contains(hostname, "*") {
+			continue
+		}
+		domains = append(domains, hostname)
+		if gw.UseSubdomains && challenge == "dns-01" {
+			domains = append(domains, "*.ipfs."+hostname, "*.ipns."+hostname)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+func repoFilePath(r repo.Repo, path string) string {
+	if root, ok := r.(interface{ Path() string }); ok && !filepath.IsAbs(path) {
+		return filepath.Join(root.Path(), path)
+	}
+	return path
+}
+
+// gatewayTLSFiles serves the certificate of Gateway.TLS.CertFile and
+// KeyFile, loaded again when the files change.
+type gatewayTLSFiles struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func (f *gatewayTLSFiles) load() error {
+	modTime, err := f.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.cert, f.modTime, f.checked = &cert, modTime, time.Now()
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *gatewayTLSFiles) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, file := range []string{f.certFile, f.keyFile} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last, nil
+}
+
+func (f *gatewayTLSFiles) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	cert, modTime := f.cert, f.modTime
+	check := time.Since(f.checked) >= gatewayTLSFilesCheckInterval
+	if check {
+		f.checked = time.Now()
+	}
+	f.mu.Unlock()
+	if !check {
+		return cert, nil
+	}
+	// the certificate in use is kept while the files are being replaced
+	if last, err := f.lastModified(); err == nil && !last.Equal(modTime) {
+		if err := f.load(); err != nil {
+			logger.Errorf("reloading Gateway.TLS.CertFile and KeyFile: %s", err)
+			return cert, nil
+		}
+		f.mu.Lock()
+		cert = f.cert
+		f.mu.Unlock()
+	}
+	return cert, nil
+}
+
+// gatewayACMEIssuer obtains and renews a certificate of all the domains with
+// the dns-01 challenge, whose TXT records are published with RFC 2136
+// updates. autocert, used for the tls-alpn-01 challenge, cannot validate
+// wildcard names.
+type gatewayACMEIssuer struct {
+	client  *acme.Client
+	cache   autocert.Cache
+	domains []string
+	contact []string
+	dns     *rfc2136Updater
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func (i *gatewayACMEIssuer) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.cert == nil {
+		return nil, errors.New("the gateway certificate has not been obtained yet")
+	}
+	return i.cert, nil
+}
+
+func (i *gatewayACMEIssuer) loop(ctx context.Context) {
+	if err := i.loadCached(ctx); err != nil && !errors.Is(err, autocert.ErrCacheMiss) {
+		logger.Errorf("loading the gateway certificate: %s", err)
+	}
+	for {
+		wait := gatewayACMERetryInterval
+		if renewAt, ok := i.renewAt(); ok && time.Until(renewAt) > 0 {
+			wait = time.Until(renewAt)
+		} else if err := i.obtain(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Errorf("obtaining the gateway certificate for %s: %s", strings.Join(i.domains, ", "), err)
+		} else {
+			logger.Infof("obtained the gateway certificate for %s", strings.Join(i.domains, ", "))
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// renewAt returns when the certificate is to be renewed, if there is one
+// for all the domains.
+func (i *gatewayACMEIssuer) renewAt() (time.Time, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.cert == nil || i.cert.Leaf == nil {
+		return time.Time{}, false
+	}
+	for _, domain := range i.domains {
+		if !slices.Contains(i.cert.Leaf.DNSNames, domain) {
+			return time.Time{}, false
+		}
+	}
+	return i.cert.Leaf.NotAfter.Add(-gatewayACMERenewBefore), true
+}
+
+func (i *gatewayACMEIssuer) loadCached(ctx context.Context) error {
+	data, err := i.cache.Get(ctx, gatewayACMEDNSCert)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	i.cert = &cert
+	i.mu.Unlock()
+	return nil
+}
+
+func (i *gatewayACMEIssuer) accountKey(ctx context.Context) (crypto.Signer, error) {
+	data, err := i.cache.Get(ctx, gatewayACMEAccountKey)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("invalid ACME account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.cache.Put(ctx, gatewayACMEAccountKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (i *gatewayACMEIssuer) obtain(ctx context.Context) error {
+	if i.client.Key == nil {
+		key, err := i.accountKey(ctx)
+		if err != nil {
+			return fmt.Errorf("ACME account key: %w", err)
+		}
+		i.client.Key = key
+		if _, err := i.client.Register(ctx, &acme.Account{Contact: i.contact}, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+			i.client.Key = nil
+			return fmt.Errorf("ACME account: %w", err)
+		}
+	}
+
+	order, err := i.client.AuthorizeOrder(ctx, acme.DomainIDs(i.domains...))
+	if err != nil {
+		return err
+	}
+	for _, url := range order.AuthzURLs {
+		if err := i.authorize(ctx, url); err != nil {
+			return err
+		}
+	}
+	if order, err = i.client.WaitOrder(ctx, order.URI); err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: strings.TrimPrefix(i.domains[0], "*.")},
+		DNSNames: i.domains,
+	}, key)
+	if err != nil {
+		return err
+	}
+	chain, _, err := i.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	for _, c := range chain {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c})...)
+	}
+	if err := i.cache.Put(ctx, gatewayACMEDNSCert, data); err != nil {
+		return err
+	}
+	return i.loadCached(ctx)
+}
+
+// authorize publishes the TXT record of the dns-01 challenge of an
+// authorization, and removes it once the authorization is validated.
+func (i *gatewayACMEIssuer) authorize(ctx context.Context, url string) error {
+	authz, err := i.client.GetAuthorization(ctx, url)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("the certificate authority offers no dns-01 challenge for %s", authz.Identifier.Value)
+	}
+	value, err := i.client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return err
+	}
+	name := "_acme-challenge." + authz.Identifier.Value
+	if err := i.dns.setTXT(ctx, name, value); err != nil {
+		return fmt.Errorf("publishing the TXT record of %s: %w", name, err)
+	}
+	defer func() {
+		if err := i.dns.removeTXT(context.Background(), name, value); err != nil {
+			logger.Warnf("removing the TXT record of %s: %s", name, err)
+		}
+	}()
+	if _, err := i.client.Accept(ctx, chal); err != nil {
+		return err
+	}
+	_, err = i.client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+// rfc2136Updater publishes TXT records with RFC 2136 dynamic updates, see
+// Gateway.TLS.ACME.DNS.
+type rfc2136Updater struct {
+	server  string
+	zone    string
+	key     string
+	secret  string
+	tsigAlg string
+}
+
+func newRFC2136Updater(r repo.Repo, cfg config.GatewayACMEDNS) (*rfc2136Updater, error) {
+	if cfg.Server == "" || cfg.Zone == "" {
+		return nil, errors.New("Gateway.TLS.ACME.DNS: the dns-01 challenge requires Server and Zone")
+	}
+	u := &rfc2136Updater{
+		server:  cfg.Server,
+		zone:    dns.Fqdn(cfg.Zone),
+		tsigAlg: dns.Fqdn(cfg.TSIGAlgorithm.WithDefault("hmac-sha256")),
+	}
+	if cfg.TSIGKey != "" {
+		if cfg.TSIGSecretFile == "" {
+			return nil, errors.New("Gateway.TLS.ACME.DNS: TSIGKey requires TSIGSecretFile")
+		}
+		secret, err := os.ReadFile(repoFilePath(r, cfg.TSIGSecretFile))
+		if err != nil {
+			return nil, fmt.Errorf("Gateway.TLS.ACME.DNS.TSIGSecretFile: %w", err)
+		}
+		u.key = dns.Fqdn(strings.ToLower(cfg.TSIGKey))
+		u.secret = strings.TrimSpace(string(secret))
+	}
+	return u, nil
+}
+
+func (u *rfc2136Updater) setTXT(ctx context.Context, name, value string) error {
+	return u.update(ctx, name, value, true)
+}
+
+func (u *rfc2136Updater) removeTXT(ctx context.Context, name, value string) error {
+	return u.update(ctx, name, value, false)
+}
+
+func (u *rfc2136Updater) update(ctx context.Context, name, value string, insert bool) error {
+	rr := &dns.TXT{
+		Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+		Txt: []string{value},
+	}
+	m := new(dns.Msg)
+	m.SetUpdate(u.zone)
+	if insert {
+		m.Insert([]dns.RR{rr})
+	} else {
+		m.Remove([]dns.RR{rr})
+	}
+	c := &dns.Client{Net: "tcp"}
+	if u.key != "" {
+		c.TsigSecret = map[string]string{u.key: u.secret}
+		m.SetTsig(u.key, u.tsigAlg, 300, time.Now().Unix())
+	}
+	resp, _, err := c.ExchangeContext(ctx, m, u.server)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("the DNS server answered %s", dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
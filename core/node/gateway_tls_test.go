@@ -0,0 +1,159 @@
+package node
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/miekg/dns"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitGatewayTLSAddress(t *testing.T) {
+	for addr, want := range map[string]string{
+		"/ip4/0.0.0.0/tcp/443/tls/http": "/ip4/0.0.0.0/tcp/443",
+		"/ip4/0.0.0.0/tcp/443/https":    "/ip4/0.0.0.0/tcp/443",
+		"/ip4/127.0.0.1/tcp/8080":       "",
+		"/ip4/127.0.0.1/tcp/8080/http":  "",
+	} {
+		maddr := ma.StringCast(addr)
+		listen, ok := SplitGatewayTLSAddress(maddr)
+		if want == "" {
+			assert.False(t, ok, addr)
+			assert.True(t, listen.Equal(maddr), addr)
+			continue
+		}
+		assert.True(t, ok, addr)
+		assert.Equal(t, want, listen.String(), addr)
+	}
+	assert.True(t, HasGatewayTLSAddress([]string{"/ip4/127.0.0.1/tcp/8080", "/ip4/0.0.0.0/tcp/443/tls/http"}))
+	assert.False(t, HasGatewayTLSAddress([]string{"/ip4/127.0.0.1/tcp/8080"}))
+}
+
+func TestGatewayACMEDomains(t *testing.T) {
+	cfg := config.Gateway{PublicGateways: map[string]*config.GatewaySpec{
+		"dweb.example.com": {UseSubdomains: true},
+		"ipfs.example.com": {},
+		"*.example.net":    {},
+		"off.example.com":  nil,
+	}}
+	assert.Equal(t, []string{"dweb.example.com", "ipfs.example.com"}, GatewayACMEDomains(cfg, "tls-alpn-01"))
+	assert.Equal(t, []string{
+		"*.ipfs.dweb.example.com", "*.ipns.dweb.example.com", "dweb.example.com", "ipfs.example.com",
+	}, GatewayACMEDomains(cfg, "dns-01"))
+
+	cfg.TLS.ACME.Domains = []string{"example.org"}
+	assert.Equal(t, []string{"example.org"}, GatewayACMEDomains(cfg, "dns-01"))
+}
+
+func TestRFC2136Updater(t *testing.T) {
+	const key = "acme."
+	secret := base64.StdEncoding.EncodeToString([]byte("a secret shared with the server"))
+
+	var (
+		mu      sync.Mutex
+		records = map[string]bool{}
+	)
+	mux := dns.NewServeMux()
+	mux.HandleFunc("example.com.", func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		if req.IsTsig() == nil || w.TsigStatus() != nil {
+			resp.Rcode = dns.RcodeRefused
+		} else {
+			mu.Lock()
+			for _, rr := range req.Ns {
+				txt := rr.(*dns.TXT)
+				records[txt.Hdr.Name+" "+txt.Txt[0]] = rr.Header().Class == dns.ClassINET
+			}
+			mu.Unlock()
+			resp.SetTsig(key, dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		_ = w.WriteMsg(resp)
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &dns.Server{
+		Listener:   lis,
+		Handler:    mux,
+		TsigSecret: map[string]string{key: secret},
+		// the default one refuses the updates
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	ctx := context.Background()
+	u := &rfc2136Updater{server: lis.Addr().String(), zone: "example.com.", key: key, secret: secret, tsigAlg: dns.HmacSHA256}
+	require.NoError(t, u.setTXT(ctx, "_acme-challenge.example.com", "token"))
+	mu.Lock()
+	assert.True(t, records["_acme-challenge.example.com. token"])
+	mu.Unlock()
+	require.NoError(t, u.removeTXT(ctx, "_acme-challenge.example.com", "token"))
+	mu.Lock()
+	assert.False(t, records["_acme-challenge.example.com. token"], "the record is removed")
+	mu.Unlock()
+
+	u.secret = base64.StdEncoding.EncodeToString([]byte("another secret"))
+	assert.Error(t, u.setTXT(ctx, "_acme-challenge.example.com", "token"))
+}
+
+func TestGatewayTLSFilesReload(t *testing.T) {
+	dir := t.TempDir()
+	files := &gatewayTLSFiles{certFile: filepath.Join(dir, "cert.pem"), keyFile: filepath.Join(dir, "key.pem")}
+	writeTestCertificate(t, files.certFile, files.keyFile, "first.example.com")
+	require.NoError(t, files.load())
+
+	cert, err := files.getCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "first.example.com", leafName(t, cert))
+
+	writeTestCertificate(t, files.certFile, files.keyFile, "second.example.com")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(files.certFile, later, later))
+	files.checked = time.Time{}
+	cert, err = files.getCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "second.example.com", leafName(t, cert))
+}
+
+func writeTestCertificate(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+}
+
+func leafName(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -15,6 +16,7 @@ import (
 	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/node/libp2p"
 	"github.com/ipfs/kubo/p2p"
+	irouting "github.com/ipfs/kubo/routing"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p-pubsub/timecache"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -45,13 +47,20 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 		connmgr = fx.Options() // noop
 	case "", "basic":
 		grace := cfg.Swarm.ConnMgr.GracePeriod.WithDefault(config.DefaultConnMgrGracePeriod)
-		low := int(cfg.Swarm.ConnMgr.LowWater.WithDefault(config.DefaultConnMgrLowWater))
-		high := int(cfg.Swarm.ConnMgr.HighWater.WithDefault(config.DefaultConnMgrHighWater))
+		low := int(cfg.Swarm.ConnMgr.LowWater.WithDefault(cfg.Resources.Scale(config.DefaultConnMgrLowWater, 8)))
+		high := int(cfg.Swarm.ConnMgr.HighWater.WithDefault(cfg.Resources.Scale(config.DefaultConnMgrHighWater, 24)))
 		connmgr = fx.Provide(libp2p.ConnectionManager(low, high, grace))
 	default:
 		return fx.Error(fmt.Errorf("unrecognized Swarm.ConnMgr.Type: %q", connMgrType))
 	}
 
+	// the resource manager gets half of Resources.MaxMemoryMB, as it gets
+	// half of the memory of the host by default
+	swarmCfg := cfg.Swarm
+	if mb := cfg.Resources.MaxMemoryMB.WithDefault(0); mb > 0 && swarmCfg.ResourceMgr.MaxMemory == nil {
+		swarmCfg.ResourceMgr.MaxMemory = config.NewOptionalString(humanize.IBytes(uint64(mb) << 20 / 2))
+	}
+
 	// parse PubSub config
 
 	ps, disc := fx.Options(), fx.Options()
@@ -130,15 +139,24 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 		fx.Provide(libp2p.UserAgent()),
 
 		// Services (resource management)
-		fx.Provide(libp2p.ResourceManager(cfg.Swarm, userResourceOverrides)),
+		fx.Provide(libp2p.ResourceManager(swarmCfg, userResourceOverrides)),
+		libp2p.Allowlist(cfg.Swarm.Allowlist),
 		fx.Provide(libp2p.AddrFilters(cfg.Swarm.AddrFilters)),
-		fx.Provide(libp2p.AddrsFactory(cfg.Addresses.Announce, cfg.Addresses.AppendAnnounce, cfg.Addresses.NoAnnounce)),
+		fx.Provide(libp2p.AddrsFactory(cfg.Addresses.Announce, append(slices.Clip(cfg.Addresses.AppendAnnounce), cfg.Bitswap.HTTPAnnounce...), cfg.Addresses.NoAnnounce)),
 		fx.Provide(libp2p.SmuxTransport(cfg.Swarm.Transports)),
 		fx.Provide(libp2p.RelayTransport(enableRelayTransport)),
 		fx.Provide(libp2p.RelayService(enableRelayService, cfg.Swarm.RelayService)),
 		fx.Provide(libp2p.Transports(cfg.Swarm.Transports)),
+		fx.Provide(libp2p.DialPolicy(cfg.Swarm.DialPolicy)),
+		fx.Invoke(libp2p.DialMetrics),
+		libp2p.ServerModePressure(cfg.Routing.ServerModePressure),
+		libp2p.ProviderRecordsCounting(cfg.Routing),
+		fx.Provide(libp2p.PeerChurn),
+		libp2p.PersistPeerstore(cfg.Swarm.Peerstore),
 		fx.Provide(libp2p.ListenOn(cfg.Addresses.Swarm)),
 		fx.Invoke(libp2p.SetupDiscovery(cfg.Discovery.MDNS.Enabled)),
+		libp2p.MDNSRouting(cfg.Discovery.MDNS),
+		libp2p.StaticRouting(cfg.Routing),
 		fx.Provide(libp2p.ForceReachability(cfg.Internal.Libp2pForceReachability)),
 		fx.Provide(libp2p.HolePunching(cfg.Swarm.EnableHolePunching, enableRelayClient)),
 
@@ -146,9 +164,12 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 
 		fx.Provide(libp2p.Routing),
 		fx.Provide(libp2p.ContentRouting),
+		fx.Provide(irouting.NewEvents),
 
 		fx.Provide(libp2p.BaseRouting(cfg)),
+		libp2p.PersistRoutingTable(cfg.Routing.PersistTable.WithDefault(config.DefaultPersistRoutingTable)),
 		maybeProvide(libp2p.PubsubRouter, bcfg.getOpt("ipnsps")),
+		maybeInvoke(libp2p.IpnsPubsubFetch, bcfg.getOpt("ipnsps")),
 
 		maybeProvide(libp2p.BandwidthCounter, !cfg.Swarm.DisableBandwidthMetrics),
 		maybeProvide(libp2p.NatPortMap, !cfg.Swarm.DisableNatPortMap),
@@ -166,6 +187,7 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 func Storage(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 	cacheOpts := blockstore.DefaultCacheOpts()
 	cacheOpts.HasBloomFilterSize = cfg.Datastore.BloomFilterSize
+	cacheOpts.HasTwoQueueCacheSize = int(cfg.Resources.Scale(int64(cacheOpts.HasTwoQueueCacheSize), 1<<10))
 	if !bcfg.Permanent {
 		cacheOpts.HasBloomFilterSize = 0
 	}
@@ -234,7 +256,7 @@ func Online(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 
 	ipnsCacheSize := cfg.Ipns.ResolveCacheSize
 	if ipnsCacheSize == 0 {
-		ipnsCacheSize = DefaultIpnsCacheSize
+		ipnsCacheSize = int(cfg.Resources.Scale(DefaultIpnsCacheSize, 16))
 	}
 	if ipnsCacheSize < 0 {
 		return fx.Error(fmt.Errorf("cannot specify negative resolve cache size"))
@@ -268,26 +290,91 @@ func Online(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 
 	/* don't provide from bitswap when the strategic provider service is active */
 	shouldBitswapProvide := !cfg.Experimental.StrategicProviding
+	// the "reactive" strategy only announces the blocks once requested
+	if cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy) == "reactive" {
+		shouldBitswapProvide = false
+	}
+
+	providers := OnlineProviders(
+		cfg.Experimental.StrategicProviding,
+		cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy),
+		cfg.Reprovider.Filter,
+		cfg.Reprovider.ReactiveTTL.WithDefault(config.DefaultReproviderReactiveTTL),
+		cfg.Reprovider.Interval.WithDefault(config.DefaultReproviderInterval),
+		cfg.Routing.AcceleratedDHTClient.WithDefault(config.DefaultAcceleratedDHTClient),
+	)
+	// nothing is announced without the provider system, which keeps the
+	// keys of the reprovide strategy and the announcement queue
+	if !bcfg.runs("reprovider") {
+		providers = OfflineProviders()
+		shouldBitswapProvide = false
+	}
+
+	// record-only mode serves nothing, which makes Bitswap.ServeStrategy moot
+	serveOption := BitswapRecordOnly(true)
+	if !bcfg.getOpt("bitswaprecordonly") {
+		serveOption = BitswapServeStrategy(
+			cfg.Bitswap.ServeStrategy.WithDefault(config.DefaultBitswapServeStrategy),
+			cfg.Bitswap.ServeStrategyRefreshInterval.WithDefault(config.DefaultBitswapServeStrategyRefreshInterval),
+		)
+	}
+
+	exchangeOption := fx.Options(
+		fx.Provide(BitswapStats),
+		fx.Provide(BitswapQueueTracker),
+		fx.Provide(BitswapFetchPlanner),
+		BitswapBlockVerifier(cfg.Bitswap.BlockVerification),
+		BitswapBlockPolicy(cfg.Bitswap.BlockPolicy),
+		BitswapPeerReputation(cfg.Bitswap.Reputation),
+		IgnoreProviders(cfg.Routing.IgnoreProviders),
+		fx.Provide(OnlineExchange(cfg)),
+		PrivateBitswapNetwork(
+			cfg.Bitswap.PrivateNetwork,
+			cfg.Bitswap.ServeStrategyRefreshInterval.WithDefault(config.DefaultBitswapServeStrategyRefreshInterval),
+		),
+		PersistWantlist(cfg.Bitswap.PersistWantlist.WithDefault(config.DefaultBitswapPersistWantlist)),
+	)
+	switch {
+	case !cfg.Bitswap.Enabled.WithDefault(config.DefaultBitswapEnabled) && cfg.Bitswap.RemoteBlockstore != nil:
+		exchangeOption = fx.Provide(RemoteBlockstoreExchange(cfg.Bitswap.RemoteBlockstore))
+	case !cfg.Bitswap.Enabled.WithDefault(config.DefaultBitswapEnabled):
+		exchangeOption = fx.Provide(DisabledExchange)
+	case cfg.Bitswap.RemoteBlockstore != nil:
+		exchangeOption = fx.Error(errors.New("Bitswap.RemoteBlockstore requires Bitswap.Enabled to be false"))
+	case !cfg.Bitswap.Libp2pEnabled.WithDefault(config.DefaultBitswapLibp2pEnabled):
+		exchangeOption = HTTPOnlyExchange(shouldBitswapProvide)
+	}
+	if err := parseHTTPAnnounce(cfg.Bitswap.HTTPAnnounce); err != nil {
+		return fx.Error(err)
+	}
+
+	var internalBitswap config.InternalBitswap
+	if cfg.Internal.Bitswap != nil {
+		internalBitswap = *cfg.Internal.Bitswap
+	}
 
 	return fx.Options(
 		fx.Provide(BitswapOptions(cfg, shouldBitswapProvide)),
-		fx.Provide(OnlineExchange()),
+		serveOption,
+		BitswapPeerMetrics(int(cfg.Bitswap.PeerMetricsTopN.WithDefault(config.DefaultBitswapPeerMetricsTopN))),
+		BitswapServerFairness(internalBitswap),
+		exchangeOption,
 		fx.Provide(DNSResolver),
-		fx.Provide(Namesys(ipnsCacheSize, cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL))),
+		fx.Provide(Namesys(ipnsCacheSize, cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL), cfg.Ipns.PersistResolveCache.WithDefault(config.DefaultIpnsPersistResolveCache))),
 		fx.Provide(Peering),
 		PeerWith(cfg.Peering.Peers...),
 
-		fx.Invoke(IpnsRepublisher(repubPeriod, recordLifetime)),
+		maybeInvoke(IpnsRepublisher(repubPeriod, recordLifetime), bcfg.runs("ipnsrepublisher")),
+		maybeOption(IpnsThirdPartyRepublishing(cfg.Ipns), bcfg.runs("ipnsthirdparty")),
+		maybeOption(RetrievalProbes(cfg.Probes.Retrieval), bcfg.runs("probes")),
+		AutoConfUpdates(cfg.AutoConf),
+		SlowLogging(cfg.Logging.SlowLog),
+		maybeOption(ResumePendingPins(cfg.Pinning.ResumeInterrupted.WithDefault(config.DefaultPinningResumeInterrupted)), bcfg.runs("pendingpins")),
 
 		fx.Provide(p2p.New),
 
 		LibP2P(bcfg, cfg, userResourceOverrides),
-		OnlineProviders(
-			cfg.Experimental.StrategicProviding,
-			cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy),
-			cfg.Reprovider.Interval.WithDefault(config.DefaultReproviderInterval),
-			cfg.Routing.AcceleratedDHTClient.WithDefault(config.DefaultAcceleratedDHTClient),
-		),
+		providers,
 	)
 }
 
@@ -296,7 +383,7 @@ func Offline(cfg *config.Config) fx.Option {
 	return fx.Options(
 		fx.Provide(offline.Exchange),
 		fx.Provide(DNSResolver),
-		fx.Provide(Namesys(0, 0)),
+		fx.Provide(Namesys(0, 0, false)),
 		fx.Provide(libp2p.Routing),
 		fx.Provide(libp2p.ContentRouting),
 		fx.Provide(libp2p.OfflineRouting),
@@ -306,12 +393,19 @@ func Offline(cfg *config.Config) fx.Option {
 
 // Core groups basic IPFS services
 var Core = fx.Options(
+	fx.Provide(Clock),
 	fx.Provide(BlockService),
 	fx.Provide(Dag),
 	fx.Provide(FetcherConfig),
 	fx.Provide(PathResolverConfig),
 	fx.Provide(Pinning),
+	fx.Provide(NewContentChanges),
+	fx.Decorate(ContentChangesPinner),
 	fx.Provide(Files),
+	fx.Provide(RPCQuotas),
+	fx.Provide(NewRPCStats),
+	fx.Provide(Scheduler),
+	fx.Provide(NewProvideList),
 )
 
 func Networked(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.PartialLimitConfig) fx.Option {
@@ -331,6 +425,9 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 	if cfg == nil {
 		return bcfgOpts // error
 	}
+	if err := checkPurpose(bcfg.Purpose); err != nil {
+		return fx.Error(err)
+	}
 
 	userResourceOverrides, err := bcfg.Repo.UserResourceOverrides()
 	if err != nil {
@@ -363,5 +460,12 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 		Networked(bcfg, cfg, userResourceOverrides),
 
 		Core,
+		KeyAuditing(cfg.Security.KeyAudit),
+		maybeOption(SearchIndexing(cfg.Search), bcfg.runs("search")),
+		maybeOption(DagIndexing(cfg.DagIndex), bcfg.runs("dagindex")),
+		maybeOption(GatewayAccessLogging(cfg.Gateway.AccessLog), bcfg.runs("gatewayaccesslog")),
+		maybeOption(GatewayDenylisting(cfg.Gateway), bcfg.runs("gatewaydenylists")),
+		maybeOption(GatewayDirectoryIndexing(cfg.Gateway.DirectoryIndexCache), bcfg.runs("gatewaydirindex")),
+		maybeOption(GatewayTLSCertificates(cfg), bcfg.runs("gatewaytls")),
 	)
 }
@@ -51,6 +51,13 @@ func maybeInvoke(opt interface{}, enable bool) fx.Option {
 	return fx.Options()
 }
 
+func maybeOption(opt fx.Option, enable bool) fx.Option {
+	if enable {
+		return opt
+	}
+	return fx.Options()
+}
+
 // baseProcess creates a goprocess which is closed when the lifecycle signals it to stop
 func baseProcess(lc fx.Lifecycle) goprocess.Process {
 	p := goprocess.WithParent(goprocess.Background())
@@ -0,0 +1,83 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	blockstore "github.com/ipfs/boxo/blockstore"
+	exchange "github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/exchange/offline"
+	provider "github.com/ipfs/boxo/provider"
+	blocks "github.com/ipfs/go-block-format"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/fx"
+)
+
+// httpOnlyExchange is the exchange of the nodes providing their blocks over
+// HTTP only. Like Bitswap, it announces the blocks added to the node, through
+// the provider system as Bitswap does not run.
+type httpOnlyExchange struct {
+	disabledExchange
+	// provider is set before the node starts, it is not a dependency of the
+	// constructor as the providing strategies depend on the exchange.
+	provider provider.Provider
+}
+
+type httpOnlyExchangeOut struct {
+	fx.Out
+
+	Exchange exchange.Interface
+	HTTPOnly *httpOnlyExchange
+}
+
+// HTTPOnlyExchange is used when Bitswap.Libp2pEnabled is false. The blocks
+// added to the node are announced unless provide is false.
+func HTTPOnlyExchange(provide bool) fx.Option {
+	return fx.Options(
+		fx.Provide(func(bs blockstore.Blockstore) httpOnlyExchangeOut {
+			e := &httpOnlyExchange{disabledExchange: disabledExchange{
+				Interface: offline.Exchange(bs),
+				bs:        bs,
+				reason:    "Bitswap.Libp2pEnabled=false",
+			}}
+			return httpOnlyExchangeOut{Exchange: e, HTTPOnly: e}
+		}),
+		fx.Invoke(func(e *httpOnlyExchange, prov provider.System) {
+			if provide {
+				e.provider = prov
+			}
+		}),
+	)
+}
+
+func (e *httpOnlyExchange) NotifyNewBlocks(ctx context.Context, blks ...blocks.Block) error {
+	if e.provider == nil {
+		return nil
+	}
+	for _, b := range blks {
+		if err := e.provider.Provide(b.Cid()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseHTTPAnnounce checks that the addresses of Bitswap.HTTPAnnounce are
+// HTTP multiaddrs.
+func parseHTTPAnnounce(addrs []string) error {
+	for _, s := range addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return fmt.Errorf("invalid Bitswap.HTTPAnnounce address %q: %w", s, err)
+		}
+		isHTTP := false
+		ma.ForEach(a, func(c ma.Component) bool {
+			isHTTP = c.Protocol().Code == ma.P_HTTP || c.Protocol().Code == ma.P_HTTPS
+			return !isHTTP
+		})
+		if !isHTTP {
+			return fmt.Errorf("invalid Bitswap.HTTPAnnounce address %q: not an HTTP multiaddr", s)
+		}
+	}
+	return nil
+}
@@ -4,15 +4,19 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/ipfs/boxo/ipns"
 	util "github.com/ipfs/boxo/util"
+	"github.com/ipfs/go-datastore"
 	record "github.com/libp2p/go-libp2p-record"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	"go.uber.org/fx"
 
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/boxo/namesys/republisher"
+	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 )
@@ -27,27 +31,47 @@ func RecordValidator(ps peerstore.Peerstore) record.Validator {
 	}
 }
 
-// Namesys creates new name system
-func Namesys(cacheSize int, cacheMaxTTL time.Duration) func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo) (namesys.NameSystem, error) {
-	return func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo) (namesys.NameSystem, error) {
+// NamesysOut is the name system, and its cache when resolutions are cached.
+type NamesysOut struct {
+	fx.Out
+
+	Namesys   namesys.NameSystem
+	IpnsCache *IpnsCache
+}
+
+// Namesys creates new name system. Resolutions are cached by an IpnsCache
+// when cacheSize is positive, persisted in the datastore with
+// persistCache.
+func Namesys(cacheSize int, cacheMaxTTL time.Duration, persistCache bool) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo, clk clock.Clock) (NamesysOut, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo, clk clock.Clock) (NamesysOut, error) {
 		opts := []namesys.Option{
 			namesys.WithDatastore(repo.Datastore()),
 			namesys.WithDNSResolver(rslv),
 			namesys.WithMaxCacheTTL(cacheMaxTTL),
 		}
 
-		if cacheSize > 0 {
-			opts = append(opts, namesys.WithCache(cacheSize))
+		ns, err := namesys.NewNameSystem(rt, opts...)
+		if err != nil || cacheSize <= 0 {
+			return NamesysOut{Namesys: ns}, err
 		}
 
-		return namesys.NewNameSystem(rt, opts...)
+		var ds datastore.Datastore
+		if persistCache {
+			ds = repo.Datastore()
+		}
+		cache, err := NewIpnsCache(helpers.LifecycleCtx(mctx, lc), ns, cacheSize, cacheMaxTTL, ds, clk)
+		if err != nil {
+			return NamesysOut{}, err
+		}
+		return NamesysOut{Namesys: cache, IpnsCache: cache}, nil
 	}
 }
 
 // IpnsRepublisher runs new IPNS republisher service
-func IpnsRepublisher(repubPeriod time.Duration, recordLifetime time.Duration) func(lcProcess, namesys.NameSystem, repo.Repo, crypto.PrivKey) error {
-	return func(lc lcProcess, namesys namesys.NameSystem, repo repo.Repo, privKey crypto.PrivKey) error {
-		repub := republisher.NewRepublisher(namesys, repo.Datastore(), privKey, repo.Keystore())
+func IpnsRepublisher(repubPeriod time.Duration, recordLifetime time.Duration) func(lcProcess, namesys.NameSystem, repo.Repo, crypto.PrivKey, keyAuditIn) error {
+	return func(lc lcProcess, namesys namesys.NameSystem, repo repo.Repo, privKey crypto.PrivKey, audit keyAuditIn) error {
+		ns := audit.Audit.NameSystem(namesys, privKey, repo)
+		repub := republisher.NewRepublisher(ns, repo.Datastore(), privKey, repo.Keystore())
 
 		if repubPeriod != 0 {
 			if !util.Debug && (repubPeriod < time.Minute || repubPeriod > (time.Hour*24)) {
@@ -0,0 +1,248 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var ipnsCacheKey = datastore.NewKey("/local/ipns/cache")
+
+// IpnsCacheEntry is a name resolved by the IpnsCache.
+type IpnsCacheEntry struct {
+	// Name is the /ipns/ path resolved, Value the path it resolved to.
+	Name    string
+	Value   string
+	TTL     time.Duration
+	LastMod time.Time
+	// Expires is when the entry is resolved again: after its TTL, at most
+	// Ipns.MaxCacheTTL.
+	Expires time.Time
+}
+
+// IpnsCache caches the recursive resolutions of IPNS names and DNSLinks
+// for Ipns.ResolveCacheSize, so that they can be listed and purged with
+// 'ipfs name cache'. With Ipns.PersistResolveCache, the entries are saved to
+// the datastore and survive restarts. Streamed resolutions are not cached.
+type IpnsCache struct {
+	namesys.NameSystem
+	maxTTL time.Duration
+	ds     datastore.Datastore
+	clock  clock.Clock
+
+	mu    sync.Mutex
+	cache *lru.Cache[string, IpnsCacheEntry]
+}
+
+// NewIpnsCache wraps ns with a cache of size entries, which expire according
+// to clk. ds is nil when the entries are not persisted.
+func NewIpnsCache(ctx context.Context, ns namesys.NameSystem, size int, maxTTL time.Duration, ds datastore.Datastore, clk clock.Clock) (*IpnsCache, error) {
+	c := &IpnsCache{NameSystem: ns, maxTTL: maxTTL, ds: ds, clock: clk}
+	cache, err := lru.NewWithEvict(size, func(name string, _ IpnsCacheEntry) {
+		// the lock is held by the caller of the eviction
+		c.deletePersisted(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.cache = cache
+	if ds == nil {
+		return c, nil
+	}
+
+	results, err := ds.Query(ctx, query.Query{Prefix: ipnsCacheKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	res, err := results.Rest()
+	if err != nil {
+		return nil, err
+	}
+	now := c.clock.Now()
+	for _, r := range res {
+		var e IpnsCacheEntry
+		if err := json.Unmarshal(r.Value, &e); err != nil || !e.Expires.After(now) {
+			_ = ds.Delete(ctx, datastore.NewKey(r.Key))
+			continue
+		}
+		c.cache.Add(e.Name, e)
+	}
+	return c, nil
+}
+
+func (c *IpnsCache) Resolve(ctx context.Context, p path.Path, options ...namesys.ResolveOption) (namesys.Result, error) {
+	name, ok := cacheableName(p, options)
+	if !ok {
+		return c.NameSystem.Resolve(ctx, p, options...)
+	}
+
+	c.mu.Lock()
+	e, ok := c.cache.Get(name.String())
+	c.mu.Unlock()
+	if ok && c.clock.Now().Before(e.Expires) {
+		resolved, err := path.NewPath(e.Value)
+		if err != nil {
+			return namesys.Result{}, err
+		}
+		resolved, err = path.Join(resolved, p.Segments()[2:]...)
+		return namesys.Result{Path: resolved, TTL: e.TTL, LastMod: e.LastMod}, err
+	}
+
+	res, err := c.NameSystem.Resolve(ctx, name, options...)
+	if err != nil {
+		return res, err
+	}
+	c.set(name.String(), res.Path, res.TTL, res.LastMod)
+	res.Path, err = path.Join(res.Path, p.Segments()[2:]...)
+	return res, err
+}
+
+func (c *IpnsCache) Publish(ctx context.Context, sk ci.PrivKey, value path.Path, options ...namesys.PublishOption) error {
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return err
+	}
+	name := ipns.NameFromPeer(pid).AsPath().String()
+
+	// the EOL is computed once, as the wrapped NameSystem does
+	opts := namesys.ProcessPublishOptions(options)
+	options = append(options, namesys.PublishWithEOL(opts.EOL))
+	if err := c.NameSystem.Publish(ctx, sk, value, options...); err != nil {
+		c.Purge(name)
+		return err
+	}
+
+	ttl := namesys.DefaultResolverCacheTTL
+	if opts.TTL >= 0 {
+		ttl = opts.TTL
+	}
+	if untilEOL := c.clock.Until(opts.EOL); untilEOL < ttl {
+		ttl = untilEOL
+	}
+	c.set(name, value, ttl, c.clock.Now())
+	return nil
+}
+
+// set caches value for the TTL of the resolution, at most maxTTL. The
+// resolutions without TTL, such as DNSLinks, are cached for
+// namesys.DefaultResolverCacheTTL.
+func (c *IpnsCache) set(name string, value path.Path, ttl time.Duration, lastMod time.Time) {
+	cacheTTL := ttl
+	if cacheTTL <= 0 {
+		cacheTTL = namesys.DefaultResolverCacheTTL
+	}
+	if cacheTTL > c.maxTTL {
+		cacheTTL = c.maxTTL
+	}
+	if cacheTTL <= 0 {
+		return
+	}
+	if lastMod.IsZero() {
+		lastMod = c.clock.Now()
+	}
+	e := IpnsCacheEntry{
+		Name:    name,
+		Value:   value.String(),
+		TTL:     ttl,
+		LastMod: lastMod,
+		Expires: c.clock.Now().Add(cacheTTL),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Add(name, e)
+	if c.ds == nil {
+		return
+	}
+	val, err := json.Marshal(e)
+	if err == nil {
+		err = c.ds.Put(context.Background(), ipnsCacheEntryKey(name), val)
+	}
+	if err != nil {
+		logger.Errorf("persisting IPNS cache entry of %s: %s", name, err)
+	}
+}
+
+// Entries returns the cached entries that did not expire, sorted by name.
+func (c *IpnsCache) Entries() []IpnsCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	var entries []IpnsCacheEntry
+	for _, name := range c.cache.Keys() {
+		if e, ok := c.cache.Peek(name); ok && e.Expires.After(now) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Purge removes the entries of names, /ipns/ paths or names, from the
+// cache, or every entry when no name is given. It returns the number of entries removed.
+func (c *IpnsCache) Purge(names ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(names) == 0 {
+		names = c.cache.Keys()
+	}
+	var purged int
+	for _, name := range names {
+		name = ipnsCacheName(name)
+		// removing calls the eviction callback, which deletes the persisted
+		// entry
+		if c.cache.Remove(name) {
+			purged++
+		}
+	}
+	return purged
+}
+
+func (c *IpnsCache) deletePersisted(name string) {
+	if c.ds == nil {
+		return
+	}
+	if err := c.ds.Delete(context.Background(), ipnsCacheEntryKey(name)); err != nil {
+		logger.Errorf("deleting persisted IPNS cache entry of %s: %s", name, err)
+	}
+}
+
+// cacheableName returns the /ipns/ name resolved by p, when the resolution is
+// recursive. IPNS names are normalized, so that every encoding of a name
+// shares its entry.
+func cacheableName(p path.Path, options []namesys.ResolveOption) (path.Path, bool) {
+	if !p.Mutable() || namesys.ProcessResolveOptions(options).Depth != namesys.DefaultDepthLimit {
+		return nil, false
+	}
+	name, err := path.NewPath(ipnsCacheName(p.Segments()[1]))
+	return name, err == nil
+}
+
+// ipnsCacheName returns the name of the entry of an /ipns/ path or name.
+func ipnsCacheName(name string) string {
+	name = strings.TrimPrefix(name, ipns.NamespacePrefix)
+	if n, err := ipns.NameFromString(name); err == nil {
+		return n.AsPath().String()
+	}
+	return ipns.NamespacePrefix + name
+}
+
+func ipnsCacheEntryKey(name string) datastore.Key {
+	// names are /ipns/<name>, and DNSLink names contain no slash
+	return ipnsCacheKey.Child(datastore.NewKey(name))
+}
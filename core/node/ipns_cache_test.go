@@ -0,0 +1,102 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+// countingNameSystem resolves every name to value with ttl.
+type countingNameSystem struct {
+	namesys.NameSystem
+	value    path.Path
+	ttl      time.Duration
+	resolves int
+}
+
+func (ns *countingNameSystem) Resolve(ctx context.Context, p path.Path, options ...namesys.ResolveOption) (namesys.Result, error) {
+	ns.resolves++
+	return namesys.Result{Path: ns.value, TTL: ns.ttl}, nil
+}
+
+func TestIpnsCache(t *testing.T) {
+	ctx := context.Background()
+	value, err := path.NewPath("/ipfs/bafkqaaa")
+	require.NoError(t, err)
+	name, err := path.NewPath("/ipns/example.com/sub")
+	require.NoError(t, err)
+
+	t.Run("resolutions are cached for their TTL", func(t *testing.T) {
+		ns := &countingNameSystem{value: value, ttl: time.Hour}
+		c, err := NewIpnsCache(ctx, ns, 10, time.Duration(1<<62), nil, clock.New())
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			res, err := c.Resolve(ctx, name)
+			require.NoError(t, err)
+			require.Equal(t, "/ipfs/bafkqaaa/sub", res.Path.String())
+		}
+		require.Equal(t, 1, ns.resolves)
+
+		entries := c.Entries()
+		require.Len(t, entries, 1)
+		require.Equal(t, "/ipns/example.com", entries[0].Name)
+		require.Equal(t, time.Hour, entries[0].TTL)
+
+		require.Equal(t, 1, c.Purge("example.com"))
+		_, err = c.Resolve(ctx, name)
+		require.NoError(t, err)
+		require.Equal(t, 2, ns.resolves)
+	})
+
+	t.Run("the TTL is capped by the max TTL", func(t *testing.T) {
+		ns := &countingNameSystem{value: value, ttl: time.Hour}
+		clk := clock.NewMock()
+		c, err := NewIpnsCache(ctx, ns, 10, time.Minute, nil, clk)
+		require.NoError(t, err)
+
+		_, err = c.Resolve(ctx, name)
+		require.NoError(t, err)
+		clk.Add(time.Minute)
+		_, err = c.Resolve(ctx, name)
+		require.NoError(t, err)
+		require.Equal(t, 2, ns.resolves)
+	})
+
+	t.Run("non-recursive resolutions are not cached", func(t *testing.T) {
+		ns := &countingNameSystem{value: value, ttl: time.Hour}
+		c, err := NewIpnsCache(ctx, ns, 10, time.Hour, nil, clock.New())
+		require.NoError(t, err)
+
+		_, err = c.Resolve(ctx, name, namesys.ResolveWithDepth(1))
+		require.NoError(t, err)
+		require.Empty(t, c.Entries())
+	})
+
+	t.Run("persisted entries survive restarts", func(t *testing.T) {
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		ns := &countingNameSystem{value: value, ttl: time.Hour}
+		c, err := NewIpnsCache(ctx, ns, 10, time.Hour, ds, clock.New())
+		require.NoError(t, err)
+		_, err = c.Resolve(ctx, name)
+		require.NoError(t, err)
+
+		restarted, err := NewIpnsCache(ctx, ns, 10, time.Hour, ds, clock.New())
+		require.NoError(t, err)
+		_, err = restarted.Resolve(ctx, name)
+		require.NoError(t, err)
+		require.Equal(t, 1, ns.resolves)
+
+		require.Equal(t, 1, restarted.Purge())
+		restarted, err = NewIpnsCache(ctx, ns, 10, time.Hour, ds, clock.New())
+		require.NoError(t, err)
+		require.Empty(t, restarted.Entries())
+	})
+}
@@ -0,0 +1,204 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+	irouting "github.com/ipfs/kubo/routing"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"go.uber.org/fx"
+)
+
+var ipnsThirdPartyKey = datastore.NewKey("/local/ipns/thirdparty")
+
+// ipnsThirdPartyTimeout bounds the lookup and the republish of each name.
+const ipnsThirdPartyTimeout = time.Minute
+
+// IpnsThirdPartyRecord is the state of a name of Ipns.RepublishThirdParty.
+type IpnsThirdPartyRecord struct {
+	Name string
+	// Value, Sequence and Validity come from the latest record kept, empty
+	// until one is found.
+	Value    string    `json:",omitempty"`
+	Sequence uint64    `json:",omitempty"`
+	Validity time.Time `json:",omitempty"`
+	// LastRepublish is the last time the record was republished, and Error
+	// why the last round failed.
+	LastRepublish time.Time `json:",omitempty"`
+	Error         string    `json:",omitempty"`
+}
+
+// IpnsThirdParty keeps the latest signed records of the names of
+// Ipns.RepublishThirdParty in the datastore and republishes them every
+// Ipns.RepublishThirdPartyPeriod, so that they stay resolvable while their
+// owners are offline, until they expire.
+type IpnsThirdParty struct {
+	ctx      context.Context
+	rt       routing.ValueStore
+	ds       datastore.Datastore
+	clock    clock.Clock
+	interval time.Duration
+	names    []ipns.Name
+
+	mu      sync.Mutex
+	records map[string]*IpnsThirdPartyRecord
+}
+
+// IpnsThirdPartyRepublishing provides the IpnsThirdParty when
+// Ipns.RepublishThirdParty is set.
+func IpnsThirdPartyRepublishing(cfg config.Ipns) fx.Option {
+	if len(cfg.RepublishThirdParty) == 0 {
+		return fx.Options()
+	}
+	interval := cfg.RepublishThirdPartyPeriod.WithDefault(config.DefaultIpnsRepublishThirdPartyPeriod)
+	if interval <= 0 {
+		return fx.Error(errors.New("invalid Ipns.RepublishThirdPartyPeriod: must be positive"))
+	}
+	var names []ipns.Name
+	for _, s := range cfg.RepublishThirdParty {
+		name, err := ipns.NameFromString(s)
+		if err != nil {
+			return fx.Error(fmt.Errorf("invalid Ipns.RepublishThirdParty %q: %w", s, err))
+		}
+		names = append(names, name)
+	}
+
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rt irouting.ProvideManyRouter, repo repo.Repo, clk clock.Clock) *IpnsThirdParty {
+		r := &IpnsThirdParty{
+			ctx:      helpers.LifecycleCtx(mctx, lc),
+			rt:       rt,
+			ds:       repo.Datastore(),
+			clock:    clk,
+			interval: interval,
+			names:    names,
+			records:  make(map[string]*IpnsThirdPartyRecord, len(names)),
+		}
+		for _, name := range names {
+			r.records[name.String()] = &IpnsThirdPartyRecord{Name: name.AsPath().String()}
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go r.loop()
+				return nil
+			},
+		})
+		return r
+	})
+}
+
+// Records returns the state of the names republished.
+func (r *IpnsThirdParty) Records() []IpnsThirdPartyRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := make([]IpnsThirdPartyRecord, 0, len(r.records))
+	for _, rec := range r.records {
+		records = append(records, *rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	return records
+}
+
+func (r *IpnsThirdParty) loop() {
+	for {
+		r.republishAll()
+		select {
+		case <-r.clock.After(r.interval):
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *IpnsThirdParty) republishAll() {
+	for _, name := range r.names {
+		if r.ctx.Err() != nil {
+			return
+		}
+		err := r.republish(name)
+		r.mu.Lock()
+		rec := r.records[name.String()]
+		if err != nil {
+			logger.Warnf("Ipns.RepublishThirdParty: %s: %s", name, err)
+			rec.Error = err.Error()
+		} else {
+			rec.LastRepublish = r.clock.Now()
+			rec.Error = ""
+		}
+		r.mu.Unlock()
+	}
+}
+
+// republish looks up the latest record of name, keeps it when it is newer
+// than the one kept, and republishes it.
+func (r *IpnsThirdParty) republish(name ipns.Name) error {
+	ctx, cancel := context.WithTimeout(r.ctx, ipnsThirdPartyTimeout)
+	defer cancel()
+
+	key := string(name.RoutingKey())
+	dsKey := ipnsThirdPartyKey.ChildString(name.String())
+
+	var candidates [][]byte
+	if kept, err := r.ds.Get(ctx, dsKey); err == nil {
+		candidates = append(candidates, kept)
+	} else if !errors.Is(err, datastore.ErrNotFound) {
+		return err
+	}
+	found, lookupErr := r.rt.GetValue(ctx, key)
+	if lookupErr == nil {
+		candidates = append(candidates, found)
+	}
+
+	// expired records fail the validation: only their owner can renew them
+	var valid [][]byte
+	for _, c := range candidates {
+		rec, err := ipns.UnmarshalRecord(c)
+		if err == nil {
+			err = ipns.ValidateWithName(rec, name)
+		}
+		if err == nil {
+			valid = append(valid, c)
+		}
+	}
+	if len(valid) == 0 {
+		if lookupErr != nil {
+			return fmt.Errorf("no valid record kept, and the lookup failed: %w", lookupErr)
+		}
+		return errors.New("no valid record kept or found")
+	}
+	i, err := ipns.Validator{}.Select(key, valid)
+	if err != nil {
+		return err
+	}
+	best := valid[i]
+	rec, err := ipns.UnmarshalRecord(best)
+	if err != nil {
+		return err
+	}
+	r.setRecord(name, rec)
+
+	if err := r.ds.Put(ctx, dsKey, best); err != nil {
+		return err
+	}
+	return r.rt.PutValue(ctx, key, best)
+}
+
+func (r *IpnsThirdParty) setRecord(name ipns.Name, rec *ipns.Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.records[name.String()]
+	if v, err := rec.Value(); err == nil {
+		state.Value = v.String()
+	}
+	state.Sequence, _ = rec.Sequence()
+	state.Validity, _ = rec.Validity()
+}
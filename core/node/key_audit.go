@@ -0,0 +1,234 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+var keyAuditKey = datastore.NewKey("/local/keyaudit")
+
+// Operations of the key audit log.
+const (
+	KeyOpGenerate = "generate"
+	KeyOpImport   = "import"
+	KeyOpExport   = "export"
+	KeyOpRename   = "rename"
+	KeyOpRemove   = "remove"
+	KeyOpSign     = "sign"
+	KeyOpPublish  = "ipns-publish"
+)
+
+type commandCtxKey struct{}
+
+// WithCommand returns a context carrying the path of the command run by a
+// request, such as "name publish".
+func WithCommand(ctx context.Context, command string) context.Context {
+	return context.WithValue(ctx, commandCtxKey{}, command)
+}
+
+// CommandFromContext returns the command set by WithCommand.
+func CommandFromContext(ctx context.Context) (string, bool) {
+	command, ok := ctx.Value(commandCtxKey{}).(string)
+	return command, ok
+}
+
+// KeyAuditEntry is a use of a key.
+type KeyAuditEntry struct {
+	Time      time.Time
+	Key       string
+	Operation string
+	// Detail is what the operation applied to, such as the path published.
+	Detail string `json:",omitempty"`
+	// Command and User are the command and the API.Authorizations entry of
+	// the request which used the key, empty for the uses of the daemon
+	// itself, such as the IPNS republisher.
+	Command string `json:",omitempty"`
+	User    string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// KeyAudit records the uses of the keys in the datastore, see
+// Security.KeyAudit. The entries of a key are kept under its name, in the
+// order they were recorded, for Security.KeyAudit.Retention.
+type KeyAudit struct {
+	ds        datastore.Datastore
+	clock     clock.Clock
+	retention time.Duration
+
+	mu   sync.Mutex
+	last int64
+}
+
+// KeyAuditing provides the KeyAudit when Security.KeyAudit is enabled.
+func KeyAuditing(cfg config.SecurityKeyAudit) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultSecurityKeyAuditEnabled) {
+		return fx.Options()
+	}
+	retention := cfg.Retention.WithDefault(config.DefaultSecurityKeyAuditRetention)
+	if retention <= 0 {
+		return fx.Error(errors.New("invalid Security.KeyAudit.Retention: must be positive"))
+	}
+
+	return fx.Provide(func(r repo.Repo, clk clock.Clock) *KeyAudit {
+		return &KeyAudit{ds: r.Datastore(), clock: clk, retention: retention}
+	})
+}
+
+// Record records the use of key by the operation, with the command and the
+// API.Authorizations entry of ctx. Nothing is recorded by a nil KeyAudit.
+func (a *KeyAudit) Record(ctx context.Context, key, operation, detail string, err error) {
+	if a == nil {
+		return
+	}
+	e := KeyAuditEntry{Time: a.clock.Now(), Key: key, Operation: operation, Detail: detail}
+	e.Command, _ = CommandFromContext(ctx)
+	e.User, _ = RPCUserFromContext(ctx)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		logger.Errorf("key audit: %s", err)
+		return
+	}
+
+	// the entries are ordered by their time, kept unique
+	a.mu.Lock()
+	ts := max(e.Time.UnixNano(), a.last+1)
+	a.last = ts
+	a.mu.Unlock()
+
+	// the entries outlive the request
+	ctx = context.WithoutCancel(ctx)
+	if err := a.ds.Put(ctx, keyAuditKey.ChildString(key).ChildString(fmt.Sprintf("%020d", ts)), data); err != nil {
+		logger.Errorf("key audit of %s: %s", key, err)
+		return
+	}
+	if err := a.prune(ctx, key); err != nil {
+		logger.Errorf("key audit of %s: %s", key, err)
+	}
+}
+
+// Entries returns the uses of key since the given time, oldest first.
+func (a *KeyAudit) Entries(ctx context.Context, key string, since time.Time) ([]KeyAuditEntry, error) {
+	results, err := a.ds.Query(ctx, query.Query{
+		Prefix: keyAuditKey.ChildString(key).String(),
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	entries := []KeyAuditEntry{}
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var e KeyAuditEntry
+		if err := json.Unmarshal(r.Value, &e); err != nil {
+			return nil, fmt.Errorf("invalid entry %s: %w", r.Key, err)
+		}
+		if !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// prune removes the entries of key older than the retention.
+func (a *KeyAudit) prune(ctx context.Context, key string) error {
+	results, err := a.ds.Query(ctx, query.Query{Prefix: keyAuditKey.ChildString(key).String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	expired := a.clock.Now().Add(-a.retention).UnixNano()
+	var keys []datastore.Key
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		k := datastore.NewKey(r.Key)
+		if ts, err := strconv.ParseInt(k.BaseNamespace(), 10, 64); err == nil && ts < expired {
+			keys = append(keys, k)
+		}
+	}
+	results.Close()
+	for _, k := range keys {
+		if err := a.ds.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyAuditIn is the KeyAudit of the services which use the keys, when
+// Security.KeyAudit is enabled.
+type keyAuditIn struct {
+	fx.In
+
+	Audit *KeyAudit `optional:"true"`
+}
+
+// NameSystem returns ns recording the IPNS records published with the keys
+// of r, and with its identity key self. A nil KeyAudit returns ns as is.
+func (a *KeyAudit) NameSystem(ns namesys.NameSystem, self crypto.PrivKey, r repo.Repo) namesys.NameSystem {
+	if a == nil || ns == nil {
+		return ns
+	}
+	return &keyAuditNameSystem{NameSystem: ns, audit: a, self: self, repo: r}
+}
+
+// keyAuditNameSystem records the IPNS records published with the keys, by
+// the commands and by the IPNS republisher.
+type keyAuditNameSystem struct {
+	namesys.NameSystem
+	audit *KeyAudit
+	self  crypto.PrivKey
+	repo  repo.Repo
+}
+
+func (ns *keyAuditNameSystem) Publish(ctx context.Context, sk crypto.PrivKey, value path.Path, opts ...namesys.PublishOption) error {
+	err := ns.NameSystem.Publish(ctx, sk, value, opts...)
+	ns.audit.Record(ctx, ns.keyName(sk), KeyOpPublish, value.String(), err)
+	return err
+}
+
+// keyName returns the name of sk in the keystore, or its peer ID when it is
+// not found.
+func (ns *keyAuditNameSystem) keyName(sk crypto.PrivKey) string {
+	if ns.self != nil && sk.Equals(ns.self) {
+		return "self"
+	}
+	ks := ns.repo.Keystore()
+	names, err := ks.List()
+	if err == nil {
+		for _, name := range names {
+			if k, err := ks.Get(name); err == nil && k.Equals(sk) {
+				return name
+			}
+		}
+	}
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return ""
+	}
+	return pid.String()
+}
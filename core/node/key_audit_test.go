@@ -0,0 +1,61 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyAudit(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	clk.Set(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	a := &KeyAudit{ds: dssync.MutexWrap(datastore.NewMapDatastore()), clock: clk, retention: 48 * time.Hour}
+
+	reqCtx := WithRPCUser(WithCommand(ctx, "name publish"), "alice")
+	a.Record(reqCtx, "mykey", KeyOpPublish, "/ipfs/bafkqaaa", nil)
+	a.Record(ctx, "other", KeyOpGenerate, "", nil)
+	// the entries recorded at the same time keep their order
+	a.Record(ctx, "mykey", KeyOpSign, "", errors.New("no key"))
+
+	entries, err := a.Entries(ctx, "mykey", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, KeyAuditEntry{
+		Time:      clk.Now(),
+		Key:       "mykey",
+		Operation: KeyOpPublish,
+		Detail:    "/ipfs/bafkqaaa",
+		Command:   "name publish",
+		User:      "alice",
+	}, entries[0])
+	require.Equal(t, KeyOpSign, entries[1].Operation)
+	require.Equal(t, "no key", entries[1].Error)
+	require.Empty(t, entries[1].Command)
+
+	clk.Add(24 * time.Hour)
+	a.Record(ctx, "mykey", KeyOpExport, "", nil)
+	entries, err = a.Entries(ctx, "mykey", clk.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, KeyOpExport, entries[0].Operation)
+
+	// the entries past the retention are removed
+	clk.Add(36 * time.Hour)
+	a.Record(ctx, "mykey", KeyOpRemove, "", nil)
+	entries, err = a.Entries(ctx, "mykey", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, KeyOpExport, entries[0].Operation)
+	require.Equal(t, KeyOpRemove, entries[1].Operation)
+
+	// nothing is recorded without Security.KeyAudit
+	var disabled *KeyAudit
+	disabled.Record(ctx, "mykey", KeyOpSign, "", nil)
+}
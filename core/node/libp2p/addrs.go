@@ -4,15 +4,27 @@ import (
 	"fmt"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	p2pbhost "github.com/libp2p/go-libp2p/p2p/host/basic"
 	ma "github.com/multiformats/go-multiaddr"
 	mamask "github.com/whyrusleeping/multiaddr-filter"
+	"go.uber.org/fx"
 )
 
-func AddrFilters(filters []string) func() (*ma.Filters, Libp2pOpts, error) {
-	return func() (filter *ma.Filters, opts Libp2pOpts, err error) {
+type addrFiltersIn struct {
+	fx.In
+
+	Allowlist *PeerAllowlist `optional:"true"`
+}
+
+func AddrFilters(filters []string) func(addrFiltersIn) (*ma.Filters, Libp2pOpts, error) {
+	return func(in addrFiltersIn) (filter *ma.Filters, opts Libp2pOpts, err error) {
 		filter = ma.NewFilters()
-		opts.Opts = append(opts.Opts, libp2p.ConnectionGater((*filtersConnectionGater)(filter)))
+		var gater connmgr.ConnectionGater = (*filtersConnectionGater)(filter)
+		if in.Allowlist != nil {
+			gater = &allowlistConnectionGater{ConnectionGater: gater, list: in.Allowlist}
+		}
+		opts.Opts = append(opts.Opts, libp2p.ConnectionGater(gater))
 		for _, s := range filters {
 			f, err := mamask.NewMask(s)
 			if err != nil {
@@ -0,0 +1,246 @@
+package libp2p
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+)
+
+// allowlistFetchTimeout bounds the fetch of Swarm.Allowlist.URL.
+const allowlistFetchTimeout = 30 * time.Second
+
+// PeerAllowlist is the set of peers allowed by Swarm.Allowlist. It is the
+// union of the configured peers and of the peers listed by the file and the
+// URL, which are reloaded periodically.
+type PeerAllowlist struct {
+	static []peer.ID
+	file   string
+	url    string
+
+	mu    sync.RWMutex
+	peers map[peer.ID]struct{}
+	// filePeers and urlPeers are the peers last loaded from the file and
+	// the URL.
+	filePeers map[peer.ID]struct{}
+	urlPeers  map[peer.ID]struct{}
+
+	rejected *prometheus.CounterVec
+	size     prometheus.Gauge
+}
+
+// Allowed reports whether p is on the allowlist.
+func (l *PeerAllowlist) Allowed(p peer.ID) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.peers[p]
+	return ok
+}
+
+// reload reads the file and fetches the URL again. The peers last loaded
+// from a source failing to load are kept, so that an unavailable source does
+// not disconnect the node from its peers.
+func (l *PeerAllowlist) reload(ctx context.Context) error {
+	var errs []error
+	filePeers, urlPeers := l.filePeers, l.urlPeers
+	if l.file != "" {
+		loaded := make(map[peer.ID]struct{})
+		if err := l.loadFile(loaded); err != nil {
+			errs = append(errs, err)
+		} else {
+			filePeers = loaded
+		}
+	}
+	if l.url != "" {
+		loaded := make(map[peer.ID]struct{})
+		if err := l.loadURL(ctx, loaded); err != nil {
+			errs = append(errs, err)
+		} else {
+			urlPeers = loaded
+		}
+	}
+
+	peers := make(map[peer.ID]struct{}, len(l.static)+len(filePeers)+len(urlPeers))
+	for _, p := range l.static {
+		peers[p] = struct{}{}
+	}
+	for _, source := range []map[peer.ID]struct{}{filePeers, urlPeers} {
+		for p := range source {
+			peers[p] = struct{}{}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.peers, l.filePeers, l.urlPeers = peers, filePeers, urlPeers
+	l.size.Set(float64(len(peers)))
+	if len(errs) > 0 {
+		return fmt.Errorf("loading Swarm.Allowlist: %v", errs)
+	}
+	return nil
+}
+
+func (l *PeerAllowlist) loadFile(peers map[peer.ID]struct{}) error {
+	f, err := os.Open(l.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return parseAllowlist(f, peers)
+}
+
+func (l *PeerAllowlist) loadURL(ctx context.Context, peers map[peer.ID]struct{}) error {
+	ctx, cancel := context.WithTimeout(ctx, allowlistFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", l.url, resp.Status)
+	}
+	return parseAllowlist(resp.Body, peers)
+}
+
+// parseAllowlist adds the peer IDs listed one per line by r to peers. Empty
+// lines and lines starting with # are skipped.
+func parseAllowlist(r io.Reader, peers map[peer.ID]struct{}) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := peer.Decode(line)
+		if err != nil {
+			return fmt.Errorf("invalid peer ID %q: %w", line, err)
+		}
+		peers[p] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// allowlistConnectionGater rejects the connections with the peers that are
+// not on the allowlist, before asking the gater it wraps.
+type allowlistConnectionGater struct {
+	connmgr.ConnectionGater
+	list *PeerAllowlist
+}
+
+var _ connmgr.ConnectionGater = (*allowlistConnectionGater)(nil)
+
+func (g *allowlistConnectionGater) InterceptPeerDial(p peer.ID) (allow bool) {
+	if !g.list.Allowed(p) {
+		g.list.rejected.WithLabelValues("outbound").Inc()
+		return false
+	}
+	return g.ConnectionGater.InterceptPeerDial(p)
+}
+
+func (g *allowlistConnectionGater) InterceptSecured(dir network.Direction, p peer.ID, connAddr network.ConnMultiaddrs) (allow bool) {
+	if !g.list.Allowed(p) {
+		g.list.rejected.WithLabelValues(strings.ToLower(dir.String())).Inc()
+		return false
+	}
+	return g.ConnectionGater.InterceptSecured(dir, p, connAddr)
+}
+
+// Allowlist only lets the peers allowed by Swarm.Allowlist connect with the
+// node, and closes the connections with the peers removed from it when it is
+// reloaded.
+func Allowlist(cfg config.SwarmAllowlist) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultSwarmAllowlistEnabled) {
+		return fx.Options()
+	}
+	interval := cfg.RefreshInterval.WithDefault(config.DefaultSwarmAllowlistRefreshInterval)
+	if interval <= 0 {
+		return fx.Error(fmt.Errorf("Swarm.Allowlist.RefreshInterval must be positive"))
+	}
+
+	l := &PeerAllowlist{
+		file: cfg.File.WithDefault(""),
+		url:  cfg.URL.WithDefault(""),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ipfs_swarm_allowlist_rejected_total",
+			Help: "Connections with peers missing from Swarm.Allowlist that were rejected, by direction.",
+		}, []string{"direction"}),
+		size: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ipfs_swarm_allowlist_peers",
+			Help: "Peers on Swarm.Allowlist.",
+		}),
+	}
+	for _, s := range cfg.Peers {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return fx.Error(fmt.Errorf("invalid peer ID in Swarm.Allowlist.Peers: %q: %w", s, err))
+		}
+		l.static = append(l.static, p)
+	}
+	mustRegister(l.rejected)
+	mustRegister(l.size)
+
+	return fx.Options(
+		fx.Provide(func() (*PeerAllowlist, error) {
+			// a missing or invalid file is a configuration error, while the
+			// URL may only be unavailable for now
+			if l.file != "" {
+				if err := l.loadFile(make(map[peer.ID]struct{})); err != nil {
+					return nil, fmt.Errorf("Swarm.Allowlist.File: %w", err)
+				}
+			}
+			if err := l.reload(context.Background()); err != nil {
+				log.Errorf("%s, retrying in %s", err, interval)
+			}
+			return l, nil
+		}),
+		fx.Invoke(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host) {
+			ctx := helpers.LifecycleCtx(mctx, lc)
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					go l.loop(ctx, h, interval)
+					return nil
+				},
+			})
+		}),
+	)
+}
+
+func (l *PeerAllowlist) loop(ctx context.Context, h host.Host, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if err := l.reload(ctx); err != nil {
+			log.Errorf("%s, keeping the previous peers", err)
+		}
+		for _, p := range h.Network().Peers() {
+			if !l.Allowed(p) {
+				log.Infof("disconnecting from %s, removed from Swarm.Allowlist", p)
+				_ = h.Network().ClosePeer(p)
+			}
+		}
+	}
+}
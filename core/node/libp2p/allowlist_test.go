@@ -0,0 +1,61 @@
+package libp2p
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerAllowlistReload(t *testing.T) {
+	static, fromFile, fromURL, replacement := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t), test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+
+	file := filepath.Join(t.TempDir(), "allowlist")
+	require.NoError(t, os.WriteFile(file, []byte(fromFile.String()+"\n"), 0o600))
+	var unavailable atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("# allowed peers\n" + fromURL.String() + "\n"))
+	}))
+	defer srv.Close()
+
+	l := &PeerAllowlist{
+		static: []peer.ID{static},
+		file:   file,
+		url:    srv.URL,
+		size:   prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}),
+	}
+	require.NoError(t, l.reload(context.Background()))
+	for _, p := range []peer.ID{static, fromFile, fromURL} {
+		assert.True(t, l.Allowed(p))
+	}
+
+	// the peers of the URL failing to load are kept, but not the ones
+	// removed from the file
+	require.NoError(t, os.WriteFile(file, []byte(replacement.String()+"\n"), 0o600))
+	unavailable.Store(true)
+	assert.Error(t, l.reload(context.Background()))
+	for _, p := range []peer.ID{static, replacement, fromURL} {
+		assert.True(t, l.Allowed(p))
+	}
+	assert.False(t, l.Allowed(fromFile))
+
+	// the file failing to load keeps its last peers too
+	require.NoError(t, os.WriteFile(file, []byte("not a peer ID\n"), 0o600))
+	unavailable.Store(false)
+	assert.Error(t, l.reload(context.Background()))
+	assert.True(t, l.Allowed(replacement))
+	assert.True(t, l.Allowed(fromURL))
+}
@@ -0,0 +1,202 @@
+package libp2p
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"go.uber.org/fx"
+)
+
+// Disconnect causes reported by PeerChurnTracker.
+const (
+	// DisconnectConnMgr is a disconnect that closely followed a connection
+	// manager trim.
+	DisconnectConnMgr = "connmgr"
+	// DisconnectTransient is the end of a limited (relayed) session.
+	DisconnectTransient = "transient"
+	// DisconnectShutdown is a disconnect caused by the node shutting down.
+	DisconnectShutdown = "shutdown"
+	// DisconnectOther covers everything else: remote closes, timeouts and
+	// other network errors.
+	DisconnectOther = "other"
+)
+
+const (
+	// churnHistoryLimit bounds the number of sessions remembered.
+	churnHistoryLimit = 10_000
+	// churnHistoryWindow is the longest window that can be reported on.
+	churnHistoryWindow = 24 * time.Hour
+	// churnTrimAttribution is how long after a connection manager trim a
+	// disconnect is still attributed to it.
+	churnTrimAttribution = 5 * time.Second
+)
+
+// PeerChurnStats summarizes peer sessions that ended within a window.
+type PeerChurnStats struct {
+	Window         time.Duration
+	Connected      int
+	Opened         int
+	Closed         int
+	ChurnPerMinute float64
+	MedianLifetime time.Duration
+	P90Lifetime    time.Duration
+	Causes         map[string]int
+}
+
+type peerSession struct {
+	since     time.Time
+	conns     int
+	transient bool
+}
+
+type closedSession struct {
+	at       time.Time
+	lifetime time.Duration
+	cause    string
+}
+
+// PeerChurnTracker records how long peers stay connected and why they leave.
+// A peer session spans from its first connection opening to its last one
+// closing.
+type PeerChurnTracker struct {
+	cm interface{ GetInfo() connmgr.CMInfo }
+
+	mu       sync.Mutex
+	stopping bool
+	sessions map[peer.ID]*peerSession
+	opened   []time.Time
+	closed   []closedSession
+}
+
+// PeerChurn creates a PeerChurnTracker and subscribes it to host connection
+// events.
+func PeerChurn(lc fx.Lifecycle, h host.Host) *PeerChurnTracker {
+	t := &PeerChurnTracker{
+		sessions: make(map[peer.ID]*peerSession),
+	}
+	t.cm, _ = h.ConnManager().(interface{ GetInfo() connmgr.CMInfo })
+
+	notifee := &network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			t.connected(c.RemotePeer(), c.Stat().Transient, time.Now())
+		},
+		DisconnectedF: func(_ network.Network, c network.Conn) {
+			t.disconnected(c.RemotePeer(), time.Now())
+		},
+	}
+	h.Network().Notify(notifee)
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			t.mu.Lock()
+			t.stopping = true
+			t.mu.Unlock()
+			return nil
+		},
+	})
+	return t
+}
+
+func (t *PeerChurnTracker) connected(p peer.ID, transient bool, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[p]
+	if !ok {
+		s = &peerSession{since: now, transient: true}
+		t.sessions[p] = s
+		t.opened = appendBounded(t.opened, now)
+	}
+	s.conns++
+	s.transient = s.transient && transient
+}
+
+func (t *PeerChurnTracker) disconnected(p peer.ID, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[p]
+	if !ok {
+		return
+	}
+	s.conns--
+	if s.conns > 0 {
+		return
+	}
+	delete(t.sessions, p)
+
+	t.closed = appendBounded(t.closed, closedSession{
+		at:       now,
+		lifetime: now.Sub(s.since),
+		cause:    t.cause(s, now),
+	})
+}
+
+func (t *PeerChurnTracker) cause(s *peerSession, now time.Time) string {
+	switch {
+	case t.stopping:
+		return DisconnectShutdown
+	case s.transient:
+		return DisconnectTransient
+	case t.cm != nil:
+		if last := t.cm.GetInfo().LastTrim; !last.IsZero() && now.Sub(last) < churnTrimAttribution {
+			return DisconnectConnMgr
+		}
+	}
+	return DisconnectOther
+}
+
+// appendBounded appends v and drops the oldest entries beyond
+// churnHistoryLimit.
+func appendBounded[T any](s []T, v T) []T {
+	s = append(s, v)
+	if len(s) > churnHistoryLimit {
+		s = append(s[:0], s[len(s)-churnHistoryLimit:]...)
+	}
+	return s
+}
+
+// Stats summarizes the sessions that opened and closed within the last
+// window.
+func (t *PeerChurnTracker) Stats(window time.Duration) PeerChurnStats {
+	if window <= 0 || window > churnHistoryWindow {
+		window = churnHistoryWindow
+	}
+	now := time.Now()
+	cutoff := now.Add(-window)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := PeerChurnStats{
+		Window:    window,
+		Connected: len(t.sessions),
+		Causes:    make(map[string]int),
+	}
+	for _, at := range t.opened {
+		if at.After(cutoff) {
+			stats.Opened++
+		}
+	}
+
+	var lifetimes []time.Duration
+	for _, c := range t.closed {
+		if c.at.After(cutoff) {
+			lifetimes = append(lifetimes, c.lifetime)
+			stats.Causes[c.cause]++
+		}
+	}
+	stats.Closed = len(lifetimes)
+	stats.ChurnPerMinute = float64(stats.Closed) / window.Minutes()
+	if len(lifetimes) > 0 {
+		sort.Slice(lifetimes, func(i, j int) bool { return lifetimes[i] < lifetimes[j] })
+		stats.MedianLifetime = lifetimes[len(lifetimes)/2]
+		stats.P90Lifetime = lifetimes[len(lifetimes)*9/10]
+	}
+	return stats
+}
@@ -0,0 +1,247 @@
+package libp2p
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/node/libp2p/fd"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/pbnjay/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+)
+
+const (
+	// dhtProtocolSuffix ends the protocols handled by DHT servers, WAN and
+	// LAN alike.
+	dhtProtocolSuffix = "/kad/1.0.0"
+	// serverModeRecovery is the fraction of the thresholds the usage must
+	// stay below before the node is promoted back, so that a usage hovering
+	// around a threshold does not flip the mode at every check.
+	serverModeRecovery = 0.8
+)
+
+// DHTServerGate stops serving the DHT protocols while the node is demoted to
+// a DHT client. It is placed between the DHTs and the host, and records the
+// stream handlers the DHTs register in server mode.
+type DHTServerGate struct {
+	mu       sync.Mutex
+	host     host.Host
+	handlers map[protocol.ID]network.StreamHandler
+	demoted  bool
+}
+
+// gatedHost is the host given to the DHTs.
+type gatedHost struct {
+	host.Host
+	gate *DHTServerGate
+}
+
+func (g *DHTServerGate) wrap(h host.Host) host.Host {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.host = h
+	return &gatedHost{Host: h, gate: g}
+}
+
+func (h *gatedHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	if !strings.HasSuffix(string(pid), dhtProtocolSuffix) {
+		h.Host.SetStreamHandler(pid, handler)
+		return
+	}
+	g := h.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.handlers[pid] = handler
+	if !g.demoted {
+		h.Host.SetStreamHandler(pid, handler)
+	}
+}
+
+func (h *gatedHost) RemoveStreamHandler(pid protocol.ID) {
+	if strings.HasSuffix(string(pid), dhtProtocolSuffix) {
+		h.gate.mu.Lock()
+		delete(h.gate.handlers, pid)
+		h.gate.mu.Unlock()
+	}
+	h.Host.RemoveStreamHandler(pid)
+}
+
+// Demoted tells whether the DHT servers of the node are demoted to clients.
+func (g *DHTServerGate) Demoted() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.demoted
+}
+
+// setDemoted removes or restores the DHT stream handlers. Removing them is
+// announced to the connected peers by identify, which stop sending DHT
+// queries, and the inbound DHT streams are reset as the DHT does itself when
+// switching to client mode.
+func (g *DHTServerGate) setDemoted(demoted bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.demoted == demoted || g.host == nil {
+		g.demoted = demoted
+		return
+	}
+	g.demoted = demoted
+
+	for pid, handler := range g.handlers {
+		if demoted {
+			g.host.RemoveStreamHandler(pid)
+		} else {
+			g.host.SetStreamHandler(pid, handler)
+		}
+	}
+	if !demoted {
+		return
+	}
+	for _, c := range g.host.Network().Conns() {
+		for _, s := range c.GetStreams() {
+			if _, ok := g.handlers[s.Protocol()]; ok && s.Stat().Direction == network.DirInbound {
+				_ = s.Reset()
+			}
+		}
+	}
+}
+
+// serverModePressure demotes and promotes the DHT servers according to the
+// resource usage of the process.
+type serverModePressure struct {
+	gate      *DHTServerGate
+	maxMemory uint64
+	maxFDs    int
+	cooldown  time.Duration
+
+	healthySince time.Time
+	demoted      prometheus.Gauge
+	demotions    *prometheus.CounterVec
+}
+
+// ServerModePressure returns the gate of the DHT servers and the monitor of
+// the resource usage when Routing.ServerModePressure is enabled.
+func ServerModePressure(cfg config.ServerModePressure) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultServerModePressure) {
+		return fx.Options()
+	}
+
+	maxMemoryString := cfg.MaxMemory.WithDefault(humanize.Bytes(memory.TotalMemory() / 4 * 3))
+	maxMemory, err := humanize.ParseBytes(maxMemoryString)
+	if err != nil {
+		return fx.Error(fmt.Errorf("Routing.ServerModePressure.MaxMemory: %w", err))
+	}
+	maxFDs := int(cfg.MaxFileDescriptors.WithDefault(int64(fd.GetNumFDs() / 4 * 3)))
+	interval := cfg.CheckInterval.WithDefault(config.DefaultServerModePressureCheck)
+	if interval <= 0 {
+		return fx.Error(fmt.Errorf("Routing.ServerModePressure.CheckInterval must be positive"))
+	}
+
+	gate := &DHTServerGate{handlers: make(map[protocol.ID]network.StreamHandler)}
+	p := &serverModePressure{
+		gate:      gate,
+		maxMemory: maxMemory,
+		maxFDs:    maxFDs,
+		cooldown:  cfg.Cooldown.WithDefault(config.DefaultServerModePressureCooldown),
+		demoted: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ipfs_dht_server_demoted",
+			Help: "1 while the DHT servers are demoted to clients by Routing.ServerModePressure.",
+		}),
+		demotions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ipfs_dht_server_demotions_total",
+			Help: "Demotions of the DHT servers to clients, by the resource crossing its threshold.",
+		}, []string{"resource"}),
+	}
+	mustRegister(p.demoted)
+	mustRegister(p.demotions)
+
+	return fx.Options(
+		fx.Supply(gate),
+		fx.Invoke(func(mctx helpers.MetricsCtx, lc fx.Lifecycle) {
+			ctx := helpers.LifecycleCtx(mctx, lc)
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					go p.loop(ctx, interval)
+					return nil
+				},
+			})
+		}),
+	)
+}
+
+func (p *serverModePressure) loop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		p.check(time.Now(), processMemory(), fd.GetOpenFDs())
+	}
+}
+
+// check demotes the DHT servers as soon as a resource crosses its threshold,
+// and promotes them back once both stayed below serverModeRecovery of their
+// thresholds for the cooldown. fds is negative when unknown.
+func (p *serverModePressure) check(now time.Time, mem uint64, fds int) {
+	var resource string
+	switch {
+	case mem > p.maxMemory:
+		resource = "memory"
+	case fds > p.maxFDs:
+		resource = "fds"
+	}
+	demoted := p.gate.Demoted()
+
+	if resource != "" {
+		p.healthySince = time.Time{}
+		if !demoted {
+			log.Warnf("demoting DHT server to client: %s above threshold (memory %s of %s, %d of %d file descriptors)",
+				resource, humanize.Bytes(mem), humanize.Bytes(p.maxMemory), fds, p.maxFDs)
+			p.gate.setDemoted(true)
+			p.demoted.Set(1)
+			p.demotions.WithLabelValues(resource).Inc()
+		}
+		return
+	}
+	if !demoted {
+		return
+	}
+
+	healthy := float64(mem) < float64(p.maxMemory)*serverModeRecovery && float64(fds) < float64(p.maxFDs)*serverModeRecovery
+	if !healthy {
+		p.healthySince = time.Time{}
+		return
+	}
+	if p.healthySince.IsZero() {
+		p.healthySince = now
+	}
+	if now.Sub(p.healthySince) >= p.cooldown {
+		log.Infof("promoting DHT client back to server (memory %s, %d file descriptors)", humanize.Bytes(mem), fds)
+		p.gate.setDemoted(false)
+		p.demoted.Set(0)
+		p.healthySince = time.Time{}
+	}
+}
+
+// processMemory returns the memory obtained by the Go runtime from the system
+// and not returned to it.
+func processMemory() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
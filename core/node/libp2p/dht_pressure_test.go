@@ -0,0 +1,45 @@
+package libp2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerModePressureHysteresis(t *testing.T) {
+	p := &serverModePressure{
+		gate:      &DHTServerGate{handlers: make(map[protocol.ID]network.StreamHandler)},
+		maxMemory: 1000,
+		maxFDs:    100,
+		cooldown:  time.Minute,
+		demoted:   prometheus.NewGauge(prometheus.GaugeOpts{Name: "demoted"}),
+		demotions: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "demotions"}, []string{"resource"}),
+	}
+	now := time.Now()
+
+	p.check(now, 500, 50)
+	assert.False(t, p.gate.Demoted())
+
+	p.check(now, 500, 101)
+	assert.True(t, p.gate.Demoted(), "demoted above the file descriptors threshold")
+
+	// below the thresholds but not below the recovery level
+	p.check(now.Add(time.Hour), 900, 50)
+	assert.True(t, p.gate.Demoted())
+
+	p.check(now.Add(time.Hour), 500, 50)
+	p.check(now.Add(time.Hour+30*time.Second), 500, 50)
+	assert.True(t, p.gate.Demoted(), "promoted only after the cooldown")
+
+	// pressure during the cooldown restarts it
+	p.check(now.Add(time.Hour+40*time.Second), 850, 50)
+	p.check(now.Add(time.Hour+70*time.Second), 500, 50)
+	assert.True(t, p.gate.Demoted())
+
+	p.check(now.Add(time.Hour+130*time.Second), 500, -1)
+	assert.False(t, p.gate.Demoted())
+}
@@ -0,0 +1,223 @@
+package libp2p
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DialRanker returns the dial ranker matching Swarm.DialPolicy. The libp2p
+// default ranker is returned when the policy is not customized.
+func DialRanker(cfg config.DialPolicy) (network.DialRanker, error) {
+	if cfg.IsDefault() {
+		return swarm.DefaultDialRanker, nil
+	}
+
+	r := dialRanker{
+		publicDelay:  cfg.PublicDelay.WithDefault(config.DefaultDialPublicDelay),
+		privateDelay: cfg.PrivateDelay.WithDefault(config.DefaultDialPrivateDelay),
+		relayDelay:   cfg.RelayDelay.WithDefault(config.DefaultDialRelayDelay),
+	}
+	switch t := cfg.PreferTransport.WithDefault(config.DefaultDialPreferTransport); t {
+	case "quic":
+	case "tcp":
+		r.preferTCP = true
+	default:
+		return nil, fmt.Errorf("unknown Swarm.DialPolicy.PreferTransport %q, must be \"quic\" or \"tcp\"", t)
+	}
+	switch v := cfg.PreferIPVersion.WithDefault(config.DefaultDialPreferIPVersion); v {
+	case "ip6":
+	case "ip4":
+		r.preferIP4 = true
+	default:
+		return nil, fmt.Errorf("unknown Swarm.DialPolicy.PreferIPVersion %q, must be \"ip6\" or \"ip4\"", v)
+	}
+	return r.rank, nil
+}
+
+// DialPolicy applies Swarm.DialPolicy to the swarm.
+func DialPolicy(cfg config.DialPolicy) func() (opts Libp2pOpts, err error) {
+	return func() (opts Libp2pOpts, err error) {
+		if cfg.IsDefault() {
+			return opts, nil
+		}
+		ranker, err := DialRanker(cfg)
+		if err != nil {
+			return opts, err
+		}
+		opts.Opts = append(opts.Opts, libp2p.SwarmOpts(swarm.WithDialRanker(ranker)))
+		return opts, nil
+	}
+}
+
+// dialRanker is a configurable variant of swarm.DefaultDialRanker. Within each
+// address class (private, public, relay) the preferred transport family and
+// IP version are dialed first, every following address is delayed by the
+// class delay, and relay addresses are postponed while direct ones exist.
+type dialRanker struct {
+	preferTCP    bool
+	preferIP4    bool
+	publicDelay  time.Duration
+	privateDelay time.Duration
+	relayDelay   time.Duration
+}
+
+func (r dialRanker) rank(addrs []ma.Multiaddr) []network.AddrDelay {
+	var relay, private, public, other []ma.Multiaddr
+	for _, a := range addrs {
+		switch {
+		case isRelayAddr(a):
+			relay = append(relay, a)
+		case manet.IsPrivateAddr(a):
+			private = append(private, a)
+		case hasProtocol(a, ma.P_IP4) || hasProtocol(a, ma.P_IP6):
+			public = append(public, a)
+		default:
+			other = append(other, a)
+		}
+	}
+
+	var relayOffset time.Duration
+	if len(public) > 0 || len(private) > 0 {
+		relayOffset = r.relayDelay
+	}
+
+	res := make([]network.AddrDelay, 0, len(addrs))
+	for _, a := range other {
+		res = append(res, network.AddrDelay{Addr: a})
+	}
+	res = append(res, r.rankClass(private, r.privateDelay, 0)...)
+	res = append(res, r.rankClass(public, r.publicDelay, 0)...)
+	res = append(res, r.rankClass(relay, r.publicDelay, relayOffset)...)
+	return res
+}
+
+func (r dialRanker) rankClass(addrs []ma.Multiaddr, step, offset time.Duration) []network.AddrDelay {
+	sort.SliceStable(addrs, func(i, j int) bool { return r.score(addrs[i]) < r.score(addrs[j]) })
+
+	res := make([]network.AddrDelay, 0, len(addrs))
+	var delay time.Duration
+	for i, a := range addrs {
+		if i > 0 {
+			delay += step
+		}
+		res = append(res, network.AddrDelay{Addr: a, Delay: offset + delay})
+	}
+	return res
+}
+
+// score orders addresses by transport family, then IP version, then port.
+// Lower is better; low ports are more likely to be listen addresses.
+func (r dialRanker) score(a ma.Multiaddr) int {
+	score := 0
+	if isTCPFamily(a) != r.preferTCP {
+		score += 1 << 20
+	}
+	if hasProtocol(a, ma.P_IP4) != r.preferIP4 {
+		score += 1 << 18
+	}
+	for _, code := range []int{ma.P_UDP, ma.P_TCP} {
+		if p, err := a.ValueForProtocol(code); err == nil {
+			if port, err := strconv.Atoi(p); err == nil {
+				score += port
+			}
+			break
+		}
+	}
+	return score
+}
+
+func isRelayAddr(a ma.Multiaddr) bool {
+	return hasProtocol(a, ma.P_CIRCUIT)
+}
+
+func isTCPFamily(a ma.Multiaddr) bool {
+	return hasProtocol(a, ma.P_TCP)
+}
+
+func hasProtocol(a ma.Multiaddr, code int) bool {
+	_, err := a.ValueForProtocol(code)
+	return err == nil
+}
+
+// dialTransportName returns the transport label used in dial metrics.
+func dialTransportName(a ma.Multiaddr) string {
+	switch {
+	case isRelayAddr(a):
+		return "relay"
+	case hasProtocol(a, ma.P_WEBTRANSPORT):
+		return "webtransport"
+	case hasProtocol(a, ma.P_WEBRTC_DIRECT):
+		return "webrtc-direct"
+	case hasProtocol(a, ma.P_QUIC_V1):
+		return "quic-v1"
+	case hasProtocol(a, ma.P_WS) || hasProtocol(a, ma.P_WSS):
+		return "websocket"
+	case hasProtocol(a, ma.P_TCP):
+		return "tcp"
+	default:
+		return "other"
+	}
+}
+
+func dialNetworkClass(a ma.Multiaddr) string {
+	switch {
+	case isRelayAddr(a):
+		return "relay"
+	case manet.IsPrivateAddr(a):
+		return "private"
+	default:
+		return "public"
+	}
+}
+
+// dialWonCounter returns the ipfs_swarm_dial_won_total counter, shared by
+// the nodes of the process.
+func dialWonCounter() *prometheus.CounterVec {
+	won := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ipfs_swarm_dial_won_total",
+			Help: "Outbound connections established, by winning transport, IP version and network class.",
+		},
+		[]string{"transport", "ip_version", "class"},
+	)
+	err := prometheus.Register(won)
+	are := prometheus.AlreadyRegisteredError{}
+	if errors.As(err, &are) {
+		return are.ExistingCollector.(*prometheus.CounterVec)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return won
+}
+
+// DialMetrics counts which transport established each outbound connection.
+func DialMetrics(h host.Host) {
+	won := dialWonCounter()
+
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			if c.Stat().Direction != network.DirOutbound {
+				return
+			}
+			addr := c.RemoteMultiaddr()
+			ipVersion := "ip6"
+			if hasProtocol(addr, ma.P_IP4) {
+				ipVersion = "ip4"
+			}
+			won.WithLabelValues(dialTransportName(addr), ipVersion, dialNetworkClass(addr)).Inc()
+		},
+	})
+}
@@ -0,0 +1,68 @@
+package libp2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/network"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialRanker(t *testing.T) {
+	quic4 := ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")
+	quic6 := ma.StringCast("/ip6/2001:db8::1/udp/4001/quic-v1")
+	tcp4 := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	lan := ma.StringCast("/ip4/192.168.1.2/tcp/4001")
+	relay := ma.StringCast("/ip4/5.6.7.8/tcp/4001/p2p/12D3KooWQF6Q3i1QkziJQ9mkNNcyFD8GPQz6R6oEvT75wgsVXm4v/p2p-circuit")
+
+	rank := func(t *testing.T, cfg config.DialPolicy, addrs ...ma.Multiaddr) []network.AddrDelay {
+		ranker, err := DialRanker(cfg)
+		require.NoError(t, err)
+		return ranker(addrs)
+	}
+
+	t.Run("prefers QUIC over IPv6 by default", func(t *testing.T) {
+		res := rank(t, config.DialPolicy{PublicDelay: config.NewOptionalDuration(100 * time.Millisecond)}, tcp4, quic4, quic6)
+		require.Equal(t, []network.AddrDelay{
+			{Addr: quic6, Delay: 0},
+			{Addr: quic4, Delay: 100 * time.Millisecond},
+			{Addr: tcp4, Delay: 200 * time.Millisecond},
+		}, res)
+	})
+
+	t.Run("prefers TCP and IPv4 when configured", func(t *testing.T) {
+		res := rank(t, config.DialPolicy{
+			PreferTransport: config.NewOptionalString("tcp"),
+			PreferIPVersion: config.NewOptionalString("ip4"),
+			PublicDelay:     config.NewOptionalDuration(time.Second),
+		}, quic6, quic4, tcp4)
+		require.Equal(t, []network.AddrDelay{
+			{Addr: tcp4, Delay: 0},
+			{Addr: quic4, Delay: time.Second},
+			{Addr: quic6, Delay: 2 * time.Second},
+		}, res)
+	})
+
+	t.Run("delays relays and ranks classes independently", func(t *testing.T) {
+		res := rank(t, config.DialPolicy{
+			PrivateDelay: config.NewOptionalDuration(time.Millisecond),
+			RelayDelay:   config.NewOptionalDuration(time.Minute),
+		}, relay, lan, quic4)
+		require.Equal(t, []network.AddrDelay{
+			{Addr: lan, Delay: 0},
+			{Addr: quic4, Delay: 0},
+			{Addr: relay, Delay: time.Minute},
+		}, res)
+	})
+
+	t.Run("rejects unknown preferences", func(t *testing.T) {
+		_, err := DialRanker(config.DialPolicy{PreferTransport: config.NewOptionalString("carrier-pigeon")})
+		require.Error(t, err)
+	})
+}
+
+func TestDialWonCounter(t *testing.T) {
+	require.Same(t, dialWonCounter(), dialWonCounter(), "the nodes of the process share the counter")
+}
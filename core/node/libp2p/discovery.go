@@ -2,9 +2,11 @@ package libp2p
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 
@@ -18,9 +20,16 @@ const discoveryConnTimeout = time.Second * 30
 type discoveryHandler struct {
 	ctx  context.Context
 	host host.Host
+
+	mu    sync.Mutex
+	found map[peer.ID]struct{}
 }
 
 func (dh *discoveryHandler) HandlePeerFound(p peer.AddrInfo) {
+	dh.mu.Lock()
+	dh.found[p.ID] = struct{}{}
+	dh.mu.Unlock()
+
 	log.Info("connecting to discovered peer: ", p)
 	ctx, cancel := context.WithTimeout(dh.ctx, discoveryConnTimeout)
 	defer cancel()
@@ -31,9 +40,23 @@ func (dh *discoveryHandler) HandlePeerFound(p peer.AddrInfo) {
 
 func DiscoveryHandler(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host) *discoveryHandler {
 	return &discoveryHandler{
-		ctx:  helpers.LifecycleCtx(mctx, lc),
-		host: host,
+		ctx:   helpers.LifecycleCtx(mctx, lc),
+		host:  host,
+		found: make(map[peer.ID]struct{}),
+	}
+}
+
+// connectedPeers returns the discovered peers the host is connected to.
+func (dh *discoveryHandler) connectedPeers() []peer.ID {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+	var peers []peer.ID
+	for p := range dh.found {
+		if dh.host.Network().Connectedness(p) == network.Connected {
+			peers = append(peers, p)
+		}
 	}
+	return peers
 }
 
 func SetupDiscovery(useMdns bool) func(helpers.MetricsCtx, fx.Lifecycle, host.Host, *discoveryHandler) error {
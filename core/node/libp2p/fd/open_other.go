@@ -0,0 +1,9 @@
+//go:build !linux
+
+package fd
+
+// GetOpenFDs returns the number of file descriptors open by the process, or
+// -1 if it is unknown.
+func GetOpenFDs() int {
+	return -1
+}
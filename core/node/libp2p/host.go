@@ -11,6 +11,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/routing"
 	routedhost "github.com/libp2p/go-libp2p/p2p/host/routed"
 
+	"github.com/ipfs/kubo/autoconf"
 	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
@@ -27,6 +28,9 @@ type P2PHostIn struct {
 	RoutingOption RoutingOption
 	ID            peer.ID
 	Peerstore     peerstore.Peerstore
+	DHTGate       *DHTServerGate `optional:"true"`
+	// ProviderRecords counts the provider records received by the DHTs
+	ProviderRecords *ProviderRecords `optional:"true"`
 
 	Opts [][]libp2p.Option `group:"libp2p"`
 }
@@ -49,7 +53,7 @@ func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHo
 	if err != nil {
 		return out, err
 	}
-	bootstrappers, err := cfg.BootstrapPeers()
+	bootstrappers, err := autoconf.Load(ctx, cfg, params.Repo.Datastore()).BootstrapPeers(cfg)
 	if err != nil {
 		return out, err
 	}
@@ -62,10 +66,14 @@ func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHo
 		OptimisticProvide:             cfg.Experimental.OptimisticProvide,
 		OptimisticProvideJobsPoolSize: cfg.Experimental.OptimisticProvideJobsPoolSize,
 		LoopbackAddressesOnLanDHT:     cfg.Routing.LoopbackAddressesOnLanDHT.WithDefault(config.DefaultLoopbackAddressesOnLanDHT),
+		ProviderRecords:               params.ProviderRecords,
 	}
 	opts = append(opts, libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
 		args := routingOptArgs
 		args.Host = h
+		if params.DHTGate != nil {
+			args.Host = params.DHTGate.wrap(h)
+		}
 		r, err := params.RoutingOption(args)
 		out.Routing = r
 		return r, err
@@ -81,6 +89,9 @@ func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHo
 	// this code is necessary just for tests: mock network constructions
 	// ignore the libp2p constructor options that actually construct the routing!
 	if out.Routing == nil {
+		if params.DHTGate != nil {
+			routingOptArgs.Host = params.DHTGate.wrap(out.Host)
+		}
 		r, err := params.RoutingOption(routingOptArgs)
 		if err != nil {
 			return P2PHostOut{}, err
@@ -0,0 +1,210 @@
+package libp2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	dshelp "github.com/ipfs/boxo/datastore/dshelp"
+	"github.com/ipfs/boxo/ipns"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	irouting "github.com/ipfs/kubo/routing"
+	namesys "github.com/libp2p/go-libp2p-pubsub-router"
+	record "github.com/libp2p/go-libp2p-record"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ipnsPubsubFetchTimeout bounds the lookup of the record of a name the node
+// just subscribed to.
+const ipnsPubsubFetchTimeout = time.Minute
+
+var ipnsPubsubResolveDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "ipfs_ipns_pubsub_resolve_duration_seconds",
+	Help:    "Time from the subscription to an IPNS name over pubsub to its first record, by source (pubsub or fetch).",
+	Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+}, []string{"source"})
+
+func init() {
+	prometheus.MustRegister(ipnsPubsubResolveDuration)
+}
+
+// IpnsPubsubName is the state of the subscription to an IPNS name over
+// pubsub.
+type IpnsPubsubName struct {
+	ID         peer.ID
+	Subscribed time.Time
+	// Resolved is when the first record was received, from pubsub or from
+	// the lookup made when subscribing, reported by ResolvedBy.
+	Resolved       time.Time     `json:",omitempty"`
+	ResolveLatency time.Duration `json:",omitempty"`
+	ResolvedBy     string        `json:",omitempty"`
+	// Sequence is the sequence number of the latest record, received
+	// LastSeen, and Updates the number of records received.
+	Sequence uint64    `json:",omitempty"`
+	LastSeen time.Time `json:",omitempty"`
+	Updates  int       `json:",omitempty"`
+}
+
+// fetchedRecord marks the context of the records put in the pubsub value
+// store by the lookup made when subscribing.
+type fetchedRecord struct{}
+
+// IpnsPubsubNames tracks the IPNS names subscribed to over pubsub. When a
+// name is first resolved, its record is also looked up with the other
+// routers, so that it resolves before its next publication.
+type IpnsPubsubNames struct {
+	ctx    context.Context
+	ps     *namesys.PubsubValueStore
+	router routing.ValueStore // set once the routing is built
+
+	mu    sync.Mutex
+	names map[string]*IpnsPubsubName
+}
+
+func newIpnsPubsubNames(ctx context.Context) *IpnsPubsubNames {
+	return &IpnsPubsubNames{ctx: ctx, names: make(map[string]*IpnsPubsubName)}
+}
+
+// IpnsPubsubFetch lets the IPNS names subscribed to over pubsub be looked
+// up with the routing of the node.
+func IpnsPubsubFetch(names *IpnsPubsubNames, r irouting.ProvideManyRouter) {
+	names.mu.Lock()
+	defer names.mu.Unlock()
+	names.router = r
+}
+
+// Names returns the state of the current subscriptions.
+func (n *IpnsPubsubNames) Names() []IpnsPubsubName {
+	subscribed := make(map[string]struct{})
+	for _, key := range n.ps.GetSubscriptions() {
+		subscribed[key] = struct{}{}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make([]IpnsPubsubName, 0, len(n.names))
+	for key, name := range n.names {
+		if _, ok := subscribed[key]; !ok {
+			// canceled with ipfs name pubsub cancel
+			delete(n.names, key)
+			continue
+		}
+		out = append(out, *name)
+	}
+	return out
+}
+
+// subscribing records the subscription to key, and looks its record up when
+// the node was not subscribed to it yet.
+func (n *IpnsPubsubNames) subscribing(key string) {
+	ns, k, err := record.SplitKey(key)
+	if err != nil || ns != "ipns" {
+		return
+	}
+	id, err := peer.IDFromBytes([]byte(k))
+	if err != nil {
+		return
+	}
+	for _, sub := range n.ps.GetSubscriptions() {
+		if sub == key {
+			return
+		}
+	}
+
+	n.mu.Lock()
+	n.names[key] = &IpnsPubsubName{ID: id, Subscribed: time.Now()}
+	router := n.router
+	n.mu.Unlock()
+	if router != nil {
+		go n.fetch(router, key)
+	}
+}
+
+func (n *IpnsPubsubNames) fetch(router routing.ValueStore, key string) {
+	ctx, cancel := context.WithTimeout(n.ctx, ipnsPubsubFetchTimeout)
+	defer cancel()
+	val, err := router.GetValue(ctx, key)
+	if err != nil {
+		log.Debugf("looking up the IPNS record of %s subscribed to over pubsub: %s", key, err)
+		return
+	}
+	// the record is kept, and published to the other subscribers, only
+	// when it is newer than the record received over pubsub meanwhile
+	if err := n.ps.PutValue(context.WithValue(ctx, fetchedRecord{}, true), key, val); err != nil {
+		log.Debugf("storing the IPNS record of %s subscribed to over pubsub: %s", key, err)
+	}
+}
+
+// received updates the state of the name of a record stored by the pubsub
+// value store.
+func (n *IpnsPubsubNames) received(ctx context.Context, key string, value []byte) {
+	rec, err := ipns.UnmarshalRecord(value)
+	if err != nil {
+		return
+	}
+	seq, err := rec.Sequence()
+	if err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	name, ok := n.names[key]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if name.Resolved.IsZero() {
+		name.Resolved = now
+		name.ResolveLatency = now.Sub(name.Subscribed)
+		name.ResolvedBy = "pubsub"
+		if ctx.Value(fetchedRecord{}) != nil {
+			name.ResolvedBy = "fetch"
+		}
+		ipnsPubsubResolveDuration.WithLabelValues(name.ResolvedBy).Observe(name.ResolveLatency.Seconds())
+	}
+	name.Sequence = seq
+	name.LastSeen = now
+	name.Updates++
+}
+
+// datastore is the datastore of the pubsub value store, where the records
+// it accepts are stored.
+func (n *IpnsPubsubNames) datastore() ds.Datastore {
+	return &ipnsPubsubDatastore{Datastore: dssync.MutexWrap(ds.NewMapDatastore()), names: n}
+}
+
+type ipnsPubsubDatastore struct {
+	ds.Datastore
+	names *IpnsPubsubNames
+}
+
+func (d *ipnsPubsubDatastore) Put(ctx context.Context, k ds.Key, value []byte) error {
+	if err := d.Datastore.Put(ctx, k, value); err != nil {
+		return err
+	}
+	if key, err := dshelp.BinaryFromDsKey(k); err == nil {
+		d.names.received(ctx, string(key), value)
+	}
+	return nil
+}
+
+// ipnsPubsubValueStore is the pubsub value store as seen by the routing,
+// where the names resolved are subscribed to.
+type ipnsPubsubValueStore struct {
+	*namesys.PubsubValueStore
+	names *IpnsPubsubNames
+}
+
+func (vs *ipnsPubsubValueStore) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	vs.names.subscribing(key)
+	return vs.PubsubValueStore.GetValue(ctx, key, opts...)
+}
+
+func (vs *ipnsPubsubValueStore) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	vs.names.subscribing(key)
+	return vs.PubsubValueStore.SearchValue(ctx, key, opts...)
+}
@@ -0,0 +1,210 @@
+package libp2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/boxo/bitswap/message"
+	pb "github.com/ipfs/boxo/bitswap/message/pb"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	irouting "github.com/ipfs/kubo/routing"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"go.uber.org/fx"
+)
+
+// mdnsProbeTimeout bounds the wait for the answers of the peers discovered
+// with mDNS, which are only on the local network.
+const mdnsProbeTimeout = 500 * time.Millisecond
+
+// mdnsProber asks the connected peers discovered with mDNS whether they have
+// a block, with a Bitswap HAVE request. The answers are received by the
+// Bitswap client, and seen through its tracer.
+type mdnsProber struct {
+	discovery *discoveryHandler
+	host      host.Host
+	net       bsnet.BitSwapNetwork
+
+	mu      sync.Mutex
+	waiters map[cid.Cid]map[*mdnsProbe]struct{}
+}
+
+// mdnsProbe collects the answers of the peers asked for a block.
+type mdnsProbe struct {
+	asked   map[peer.ID]bool
+	answers chan mdnsAnswer
+}
+
+type mdnsAnswer struct {
+	peer peer.ID
+	have bool
+}
+
+type mdnsRoutingOut struct {
+	fx.Out
+
+	Prober *mdnsProber
+	Tracer tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// MDNSRouting asks the peers discovered with mDNS for the blocks looked up,
+// before the other routers, when Discovery.MDNS.UseForRouting is set.
+func MDNSRouting(cfg config.MDNS) fx.Option {
+	if !cfg.Enabled || !cfg.UseForRouting.WithDefault(config.DefaultMDNSUseForRouting) {
+		return fx.Options()
+	}
+	return fx.Provide(func(h host.Host, dh *discoveryHandler) mdnsRoutingOut {
+		p := &mdnsProber{
+			discovery: dh,
+			host:      h,
+			net:       bsnet.NewFromIpfsHost(h, routinghelpers.Null{}),
+			waiters:   make(map[cid.Cid]map[*mdnsProbe]struct{}),
+		}
+		return mdnsRoutingOut{Prober: p, Tracer: p}
+	})
+}
+
+// probe returns the peers discovered with mDNS that have c, once they all
+// answered or after mdnsProbeTimeout.
+func (m *mdnsProber) probe(ctx context.Context, c cid.Cid) []peer.ID {
+	peers := m.discovery.connectedPeers()
+	if len(peers) == 0 {
+		return nil
+	}
+	pr := &mdnsProbe{asked: make(map[peer.ID]bool, len(peers)), answers: make(chan mdnsAnswer, len(peers))}
+	for _, p := range peers {
+		pr.asked[p] = true
+	}
+	m.mu.Lock()
+	if m.waiters[c] == nil {
+		m.waiters[c] = make(map[*mdnsProbe]struct{})
+	}
+	m.waiters[c][pr] = struct{}{}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.waiters[c], pr)
+		if len(m.waiters[c]) == 0 {
+			delete(m.waiters, c)
+		}
+		m.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, mdnsProbeTimeout)
+	defer cancel()
+	// the wants are not cancelled: the peers drop the HAVE requests they
+	// answered, and a cancel would also drop the wants of the Bitswap client
+	for _, p := range peers {
+		msg := bsmsg.New(false)
+		msg.AddEntry(c, 1, pb.Message_Wantlist_Have, true)
+		go func(p peer.ID) {
+			if err := m.net.SendMessage(ctx, p, msg); err != nil {
+				log.Debugf("Discovery.MDNS.UseForRouting: asking %s: %s", p, err)
+			}
+		}(p)
+	}
+
+	var have []peer.ID
+	for answered := 0; answered < len(peers); answered++ {
+		select {
+		case a := <-pr.answers:
+			if a.have {
+				have = append(have, a.peer)
+			}
+		case <-ctx.Done():
+			return have
+		}
+	}
+	return have
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (m *mdnsProber) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.waiters) == 0 {
+		return
+	}
+	answer := func(c cid.Cid, have bool) {
+		for pr := range m.waiters[c] {
+			if pr.asked[p] {
+				delete(pr.asked, p)
+				pr.answers <- mdnsAnswer{peer: p, have: have}
+			}
+		}
+	}
+	for _, b := range msg.Blocks() {
+		answer(b.Cid(), true)
+	}
+	for _, c := range msg.Haves() {
+		answer(c, true)
+	}
+	for _, c := range msg.DontHaves() {
+		answer(c, false)
+	}
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (m *mdnsProber) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+// mdnsRouter looks the providers up in the peers discovered with mDNS before
+// the wrapped router.
+type mdnsRouter struct {
+	irouting.ProvideManyRouter
+	prober *mdnsProber
+}
+
+// Unwrap returns the wrapped router.
+func (r *mdnsRouter) Unwrap() routing.Routing {
+	return r.ProvideManyRouter
+}
+
+func (r *mdnsRouter) Ready() bool {
+	if rr, ok := r.ProvideManyRouter.(routinghelpers.ReadyAbleRouter); ok {
+		return rr.Ready()
+	}
+	return true
+}
+
+func (r *mdnsRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		found := make(map[peer.ID]struct{})
+		for _, p := range r.prober.probe(ctx, c) {
+			found[p] = struct{}{}
+			select {
+			case out <- r.prober.host.Peerstore().PeerInfo(p):
+			case <-ctx.Done():
+				return
+			}
+		}
+		if count > 0 && len(found) >= count {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		remaining := count - len(found)
+		for ai := range r.ProvideManyRouter.FindProvidersAsync(ctx, c, count) {
+			if _, ok := found[ai.ID]; ok {
+				continue
+			}
+			select {
+			case out <- ai:
+			case <-ctx.Done():
+				return
+			}
+			if remaining--; count > 0 && remaining == 0 {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,71 @@
+package libp2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+type nullRouter struct {
+	routinghelpers.Null
+}
+
+func (nullRouter) ProvideMany(context.Context, []multihash.Multihash) error {
+	return nil
+}
+
+func (nullRouter) Ready() bool {
+	return true
+}
+
+func TestMDNSRouter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshLinked(3)
+	require.NoError(t, err)
+	require.NoError(t, mn.ConnectAllButSelf())
+	hosts := mn.Hosts()
+
+	// the first host looks the blocks up, the second is an mDNS peer with
+	// the block and the third one an mDNS peer without it
+	var probers []*mdnsProber
+	for _, h := range hosts {
+		bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		dh := &discoveryHandler{ctx: ctx, host: h, found: make(map[peer.ID]struct{})}
+		p := &mdnsProber{discovery: dh, host: h, net: bsnet.NewFromIpfsHost(h, routinghelpers.Null{}), waiters: make(map[cid.Cid]map[*mdnsProbe]struct{})}
+		exch := bitswap.New(ctx, bsnet.NewFromIpfsHost(h, routinghelpers.Null{}), bs, bitswap.WithTracer(p))
+		defer exch.Close()
+		probers = append(probers, p)
+		if len(probers) == 2 {
+			blk := blocks.NewBlock([]byte("lan"))
+			require.NoError(t, bs.Put(ctx, blk))
+			require.NoError(t, exch.NotifyNewBlocks(ctx, blk))
+		}
+	}
+	for _, h := range hosts[1:] {
+		probers[0].discovery.found[h.ID()] = struct{}{}
+	}
+
+	r := &mdnsRouter{ProvideManyRouter: nullRouter{}, prober: probers[0]}
+	start := time.Now()
+	var found []peer.ID
+	for ai := range r.FindProvidersAsync(ctx, blocks.NewBlock([]byte("lan")).Cid(), 0) {
+		found = append(found, ai.ID)
+	}
+	require.Equal(t, []peer.ID{hosts[1].ID()}, found)
+	require.Less(t, time.Since(start), mdnsProbeTimeout, "the peers without the block answer")
+}
@@ -2,9 +2,26 @@ package libp2p
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	ma "github.com/multiformats/go-multiaddr"
 	"go.uber.org/fx"
 )
 
@@ -21,3 +38,402 @@ func Peerstore(lc fx.Lifecycle) (peerstore.Peerstore, error) {
 
 	return pstore, nil
 }
+
+// peerstoreReconnectTimeout bounds the dial of a saved peer on startup.
+const peerstoreReconnectTimeout = 30 * time.Second
+
+var savedPeersKey = datastore.NewKey("/local/peerstore")
+
+// savedAddr is an address of a saved peer, with the outcome of the dials to
+// it.
+type savedAddr struct {
+	Addr      string
+	Successes int
+	Failures  int
+	// LastSeen is the last time the node connected with the address, or
+	// the peer listed it.
+	LastSeen time.Time
+}
+
+// confidence is the estimated chance, in percent, that a dial to the address
+// succeeds. An address never dialed has a confidence of 50.
+func (a *savedAddr) confidence() int {
+	return 100 * (a.Successes + 1) / (a.Successes + a.Failures + 2)
+}
+
+// savedPeer is the record of a peer in the repo.
+type savedPeer struct {
+	Addrs         []*savedAddr
+	AgentVersion  string   `json:",omitempty"`
+	Protocols     []string `json:",omitempty"`
+	LastConnected time.Time
+}
+
+func (s *savedPeer) addr(a ma.Multiaddr) *savedAddr {
+	str := a.String()
+	for _, sa := range s.Addrs {
+		if sa.Addr == str {
+			return sa
+		}
+	}
+	sa := &savedAddr{Addr: str}
+	s.Addrs = append(s.Addrs, sa)
+	return sa
+}
+
+// confidence is the confidence of the best address of the peer.
+func (s *savedPeer) confidence() int {
+	best := 0
+	for _, a := range s.Addrs {
+		if c := a.confidence(); c > best {
+			best = c
+		}
+	}
+	return best
+}
+
+// PeerstoreStats reports the peers saved in the repo by Swarm.Peerstore.
+type PeerstoreStats struct {
+	// SavedPeers and SavedAddrs count the peers and the addresses kept.
+	SavedPeers int
+	SavedAddrs int
+	// Restored is the number of peers loaded from the repo on startup, and
+	// Reconnected the number of them the node reconnected with.
+	Restored    int
+	Reconnected int
+	// PrunedPeers and PrunedAddrs count the peers and the addresses
+	// dropped since startup, for being too old or unreliable.
+	PrunedPeers int
+	PrunedAddrs int
+	// LastSave is the last time the peers were saved.
+	LastSave time.Time
+}
+
+// PersistentPeerstore saves the addresses and the metadata of the peers the
+// node connected with in the repo, and restores them on startup, so that the
+// node reconnects with recently useful peers without waiting for the
+// bootstrap peers and the DHT.
+type PersistentPeerstore struct {
+	host          host.Host
+	ds            datastore.Batching
+	retention     time.Duration
+	maxPeers      int
+	minConfidence int
+
+	mu          sync.Mutex
+	peers       map[peer.ID]*savedPeer
+	pruned      []peer.ID // pruned peers still in the repo
+	restored    int
+	reconnected int
+	prunedPeers int
+	prunedAddrs int
+	lastSave    time.Time
+}
+
+// PersistPeerstore saves the peers in the repo when Swarm.Peerstore.Persist
+// is set.
+func PersistPeerstore(cfg config.SwarmPeerstore) fx.Option {
+	if !cfg.Persist.WithDefault(config.DefaultPeerstorePersist) {
+		return fx.Options()
+	}
+	retention := cfg.Retention.WithDefault(config.DefaultPeerstoreRetention)
+	saveInterval := cfg.SaveInterval.WithDefault(config.DefaultPeerstoreSaveInterval)
+	maxPeers := cfg.MaxPeers.WithDefault(config.DefaultPeerstoreMaxPeers)
+	minConfidence := cfg.MinConfidence.WithDefault(config.DefaultPeerstoreMinConfidence)
+	reconnect := cfg.ReconnectPeers.WithDefault(config.DefaultPeerstoreReconnectPeers)
+	switch {
+	case retention <= 0:
+		return fx.Error(errors.New("Swarm.Peerstore.Retention must be positive"))
+	case saveInterval <= 0:
+		return fx.Error(errors.New("Swarm.Peerstore.SaveInterval must be positive"))
+	case maxPeers <= 0:
+		return fx.Error(errors.New("Swarm.Peerstore.MaxPeers must be positive"))
+	case minConfidence < 0 || minConfidence > 100:
+		return fx.Error(errors.New("Swarm.Peerstore.MinConfidence must be between 0 and 100"))
+	case reconnect < 0:
+		return fx.Error(errors.New("Swarm.Peerstore.ReconnectPeers must not be negative"))
+	}
+
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, repo repo.Repo) *PersistentPeerstore {
+		p := &PersistentPeerstore{
+			host:          h,
+			ds:            repo.Datastore(),
+			retention:     retention,
+			maxPeers:      int(maxPeers),
+			minConfidence: int(minConfidence),
+			peers:         make(map[peer.ID]*savedPeer),
+		}
+		h.Network().Notify(&network.NotifyBundle{
+			ConnectedF: func(_ network.Network, c network.Conn) {
+				p.connected(c, time.Now())
+			},
+		})
+
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		lc.Append(fx.Hook{
+			OnStart: func(startCtx context.Context) error {
+				if err := p.restore(startCtx, time.Now()); err != nil {
+					return fmt.Errorf("Swarm.Peerstore: restoring the peers: %w", err)
+				}
+				go p.reconnect(ctx, int(reconnect))
+				go p.loop(ctx, saveInterval)
+				return nil
+			},
+			OnStop: func(stopCtx context.Context) error {
+				return p.save(stopCtx, time.Now())
+			},
+		})
+		return p
+	})
+}
+
+// Stats returns the statistics of the peers saved.
+func (p *PersistentPeerstore) Stats() PeerstoreStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := PeerstoreStats{
+		SavedPeers:  len(p.peers),
+		Restored:    p.restored,
+		Reconnected: p.reconnected,
+		PrunedPeers: p.prunedPeers,
+		PrunedAddrs: p.prunedAddrs,
+		LastSave:    p.lastSave,
+	}
+	for _, s := range p.peers {
+		stats.SavedAddrs += len(s.Addrs)
+	}
+	return stats
+}
+
+// connected records a connection. Only the address of an outbound
+// connection is dialable, an inbound one coming from an ephemeral port.
+func (p *PersistentPeerstore) connected(c network.Conn, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.record(c.RemotePeer())
+	s.LastConnected = now
+	if c.Stat().Direction == network.DirOutbound {
+		a := s.addr(c.RemoteMultiaddr())
+		a.Successes++
+		a.LastSeen = now
+	}
+}
+
+func (p *PersistentPeerstore) record(id peer.ID) *savedPeer {
+	s, ok := p.peers[id]
+	if !ok {
+		s = &savedPeer{}
+		p.peers[id] = s
+	}
+	return s
+}
+
+// restore loads the saved peers, prunes them and adds them to the peerstore.
+func (p *PersistentPeerstore) restore(ctx context.Context, now time.Time) error {
+	res, err := p.ds.Query(ctx, query.Query{Prefix: savedPeersKey.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		k := datastore.RawKey(e.Key)
+		id, err := peer.Decode(k.BaseNamespace())
+		if err != nil {
+			log.Warnf("Swarm.Peerstore: ignoring %s: %s", k, err)
+			continue
+		}
+		s := new(savedPeer)
+		if err := json.Unmarshal(e.Value, s); err != nil {
+			log.Warnf("Swarm.Peerstore: ignoring the record of %s: %s", id, err)
+			p.pruned = append(p.pruned, id)
+			continue
+		}
+		if id != p.host.ID() {
+			p.peers[id] = s
+		}
+	}
+	p.prune(now)
+
+	ps := p.host.Peerstore()
+	for id, s := range p.peers {
+		addrs := make([]ma.Multiaddr, 0, len(s.Addrs))
+		for _, a := range s.Addrs {
+			if maddr, err := ma.NewMultiaddr(a.Addr); err == nil {
+				addrs = append(addrs, maddr)
+			}
+		}
+		ps.AddAddrs(id, addrs, peerstore.AddressTTL)
+		if s.AgentVersion != "" {
+			_ = ps.Put(id, "AgentVersion", s.AgentVersion)
+		}
+		if len(s.Protocols) > 0 {
+			protos := make([]protocol.ID, len(s.Protocols))
+			for i, pr := range s.Protocols {
+				protos[i] = protocol.ID(pr)
+			}
+			_ = ps.AddProtocols(id, protos...)
+		}
+	}
+	p.restored = len(p.peers)
+	return nil
+}
+
+// reconnect dials the n most confident saved peers, counting the failures of
+// their addresses.
+func (p *PersistentPeerstore) reconnect(ctx context.Context, n int) {
+	p.mu.Lock()
+	ids := make([]peer.ID, 0, len(p.peers))
+	for id := range p.peers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := p.peers[ids[i]], p.peers[ids[j]]
+		if ca, cb := a.confidence(), b.confidence(); ca != cb {
+			return ca > cb
+		}
+		return a.LastConnected.After(b.LastConnected)
+	})
+	p.mu.Unlock()
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id peer.ID) {
+			defer wg.Done()
+			dctx, cancel := context.WithTimeout(ctx, peerstoreReconnectTimeout)
+			defer cancel()
+			err := p.host.Connect(dctx, peer.AddrInfo{ID: id})
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err == nil {
+				p.reconnected++
+				return
+			}
+			log.Debugf("Swarm.Peerstore: reconnecting with %s: %s", id, err)
+			var derr *swarm.DialError
+			if s, ok := p.peers[id]; ok && errors.As(err, &derr) {
+				for _, te := range derr.DialErrors {
+					s.addr(te.Address).Failures++
+				}
+			}
+		}(id)
+	}
+	wg.Wait()
+}
+
+func (p *PersistentPeerstore) loop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if err := p.save(ctx, time.Now()); err != nil {
+			log.Errorf("Swarm.Peerstore: saving the peers: %s", err)
+		}
+	}
+}
+
+// save updates the records of the connected peers from the peerstore, prunes
+// the records and writes them to the repo.
+func (p *PersistentPeerstore) save(ctx context.Context, now time.Time) error {
+	ps := p.host.Peerstore()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range p.host.Network().Peers() {
+		s := p.record(id)
+		s.LastConnected = now
+		for _, a := range ps.Addrs(id) {
+			s.addr(a).LastSeen = now
+		}
+		if av, err := ps.Get(id, "AgentVersion"); err == nil {
+			if av, ok := av.(string); ok {
+				s.AgentVersion = av
+			}
+		}
+		if protos, err := ps.GetProtocols(id); err == nil {
+			s.Protocols = s.Protocols[:0]
+			for _, pr := range protos {
+				s.Protocols = append(s.Protocols, string(pr))
+			}
+		}
+	}
+	p.prune(now)
+
+	b, err := p.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range p.pruned {
+		if err := b.Delete(ctx, savedPeersKey.ChildString(id.String())); err != nil {
+			return err
+		}
+	}
+	for id, s := range p.peers {
+		v, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(ctx, savedPeersKey.ChildString(id.String()), v); err != nil {
+			return err
+		}
+	}
+	if err := b.Commit(ctx); err != nil {
+		return err
+	}
+	p.pruned = nil
+	p.lastSave = now
+	return nil
+}
+
+// prune drops the addresses not seen within the retention or below the
+// minimum confidence, then the peers left without addresses or not connected
+// within the retention, and the least recently connected peers above
+// maxPeers.
+func (p *PersistentPeerstore) prune(now time.Time) {
+	cutoff := now.Add(-p.retention)
+	for id, s := range p.peers {
+		addrs := s.Addrs[:0]
+		for _, a := range s.Addrs {
+			if a.LastSeen.Before(cutoff) || a.confidence() < p.minConfidence {
+				p.prunedAddrs++
+				continue
+			}
+			addrs = append(addrs, a)
+		}
+		s.Addrs = addrs
+		if len(s.Addrs) == 0 || s.LastConnected.Before(cutoff) {
+			p.drop(id)
+		}
+	}
+	if len(p.peers) <= p.maxPeers {
+		return
+	}
+	ids := make([]peer.ID, 0, len(p.peers))
+	for id := range p.peers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return p.peers[ids[i]].LastConnected.After(p.peers[ids[j]].LastConnected)
+	})
+	for _, id := range ids[p.maxPeers:] {
+		p.drop(id)
+	}
+}
+
+func (p *PersistentPeerstore) drop(id peer.ID) {
+	delete(p.peers, id)
+	p.pruned = append(p.pruned, id)
+	p.prunedPeers++
+}
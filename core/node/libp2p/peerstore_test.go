@@ -0,0 +1,54 @@
+package libp2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentPeerstorePrune(t *testing.T) {
+	now := time.Now()
+	ids := make([]peer.ID, 4)
+	for i := range ids {
+		var err error
+		ids[i], err = peer.Decode([]string{
+			"12D3KooWGC6TvWhfapngX6wvJHMYvKpDMXPb3ZnCZ6dMoaMtimQ5",
+			"12D3KooWPGpKb5QLF5JHd4DHV8uqyRaxuCeRBuFxZdibLugiPvPz",
+			"12D3KooWDySPBtvLgBXdV4dmaGqfYW6w4JdWEgKDVAgxGECs8j7v",
+			"12D3KooWLcxrhe4GSxfU2PRTjjJuYKsk2PykPc6nWGXP8wxVvG3q",
+		}[i])
+		require.NoError(t, err)
+	}
+
+	p := &PersistentPeerstore{retention: time.Hour, maxPeers: 2, minConfidence: 30, peers: map[peer.ID]*savedPeer{
+		// a reliable peer, with a failing address
+		ids[0]: {LastConnected: now, Addrs: []*savedAddr{
+			{Addr: "/ip4/1.2.3.4/tcp/4001", Successes: 3, LastSeen: now},
+			{Addr: "/ip4/1.2.3.4/udp/4001/quic-v1", Failures: 3, LastSeen: now},
+		}},
+		// an address not seen within the retention
+		ids[1]: {LastConnected: now.Add(-time.Minute), Addrs: []*savedAddr{
+			{Addr: "/ip4/1.2.3.5/tcp/4001", LastSeen: now.Add(-2 * time.Hour)},
+		}},
+		// the least recently connected peer above maxPeers
+		ids[2]: {LastConnected: now.Add(-30 * time.Minute), Addrs: []*savedAddr{
+			{Addr: "/ip4/1.2.3.6/tcp/4001", LastSeen: now},
+		}},
+		ids[3]: {LastConnected: now.Add(-time.Minute), Addrs: []*savedAddr{
+			{Addr: "/ip4/1.2.3.7/tcp/4001", LastSeen: now},
+		}},
+	}}
+	p.prune(now)
+
+	assert.Len(t, p.peers, 2)
+	require.Contains(t, p.peers, ids[0])
+	assert.Contains(t, p.peers, ids[3])
+	assert.Len(t, p.peers[ids[0]].Addrs, 1)
+	assert.Equal(t, 80, p.peers[ids[0]].confidence())
+	assert.ElementsMatch(t, []peer.ID{ids[1], ids[2]}, p.pruned)
+	assert.Equal(t, 2, p.prunedAddrs)
+	assert.Equal(t, 2, p.prunedPeers)
+}
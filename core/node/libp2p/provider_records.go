@@ -0,0 +1,243 @@
+package libp2p
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-flow-metrics"
+	"github.com/libp2p/go-libp2p-kad-dht/providers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-base32"
+	"go.uber.org/fx"
+)
+
+// providerRecordsScanInterval is the time between two counts of the provider
+// records stored in the datastore.
+const providerRecordsScanInterval = 10 * time.Minute
+
+// errProviderRecordsFull refuses the new provider records over
+// Routing.MaxProviderRecords.
+var errProviderRecordsFull = errors.New("provider record storage is full, see Routing.MaxProviderRecords")
+
+// ProviderRecords counts the provider records the DHTs of the node accept
+// from other peers, and caps the records stored with
+// Routing.MaxProviderRecords. The records stored are counted by scanning the
+// datastore, in between the new records are added to the last count.
+type ProviderRecords struct {
+	ds         datastore.Datastore
+	maxRecords int64
+
+	received atomic.Uint64
+	accepted atomic.Uint64
+	rejected atomic.Uint64
+	meter    flow.Meter
+	// added are the new records since the last scan
+	added atomic.Int64
+
+	mu           sync.Mutex
+	scanned      time.Time
+	records      int64
+	cids         int64
+	bytes        uint64
+	evicted      uint64
+	evictionRate float64
+}
+
+// ProviderRecordsStats are reported by ipfs stats providers.
+type ProviderRecordsStats struct {
+	// Received, Accepted and Rejected count the provider records sent by
+	// other peers since the daemon started.
+	Received uint64
+	Accepted uint64
+	Rejected uint64
+	// Rate is the records accepted per second, recently.
+	Rate float64
+	// Records is the number of records stored, UniqueCids the CIDs they
+	// provide and Bytes their size in the datastore, as of LastScan.
+	// Records includes the new records since.
+	Records    int64
+	UniqueCids int64
+	Bytes      uint64
+	// Evicted estimates the expired records removed since the daemon
+	// started, and EvictionRate the ones removed per second between the
+	// last two scans.
+	Evicted      uint64
+	EvictionRate float64
+	MaxRecords   int64
+	LastScan     time.Time
+}
+
+// ProviderRecordsCounting provides the ProviderRecords, used by the DHTs of
+// the node.
+func ProviderRecordsCounting(cfg config.Routing) fx.Option {
+	maxRecords := cfg.MaxProviderRecords.WithDefault(config.DefaultMaxProviderRecords)
+	if maxRecords < 0 {
+		return fx.Error(errors.New("Routing.MaxProviderRecords must not be negative"))
+	}
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo) *ProviderRecords {
+		r := &ProviderRecords{ds: repo.Datastore(), maxRecords: maxRecords}
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go r.loop(ctx)
+				return nil
+			},
+		})
+		return r
+	})
+}
+
+// wrap counts the records added to the provider store of a DHT.
+func (r *ProviderRecords) wrap(ps providers.ProviderStore, self peer.ID) providers.ProviderStore {
+	return &countingProviderStore{ProviderStore: ps, records: r, self: self}
+}
+
+type countingProviderStore struct {
+	providers.ProviderStore
+	records *ProviderRecords
+	self    peer.ID
+}
+
+func (s *countingProviderStore) AddProvider(ctx context.Context, key []byte, prov peer.AddrInfo) error {
+	// the records of the node itself are not received
+	if prov.ID == s.self {
+		return s.ProviderStore.AddProvider(ctx, key, prov)
+	}
+	r := s.records
+	r.received.Add(1)
+	// the records already stored are refreshed, even when the storage is full
+	exists, err := r.ds.Has(ctx, providerRecordKey(key, prov.ID))
+	if err != nil {
+		return err
+	}
+	if !exists && r.full() {
+		r.rejected.Add(1)
+		return errProviderRecordsFull
+	}
+	if err := s.ProviderStore.AddProvider(ctx, key, prov); err != nil {
+		return err
+	}
+	r.accepted.Add(1)
+	r.meter.Mark(1)
+	if !exists {
+		r.added.Add(1)
+	}
+	return nil
+}
+
+// providerRecordKey is the key of a provider record in the datastore, as
+// written by the providers.ProviderManager.
+func providerRecordKey(key []byte, p peer.ID) datastore.Key {
+	return datastore.NewKey(providers.ProvidersKeyPrefix + base32.RawStdEncoding.EncodeToString(key) + "/" + base32.RawStdEncoding.EncodeToString([]byte(p)))
+}
+
+func (r *ProviderRecords) full() bool {
+	if r.maxRecords == 0 {
+		return false
+	}
+	r.mu.Lock()
+	records := r.records
+	r.mu.Unlock()
+	return records+r.added.Load() >= r.maxRecords
+}
+
+func (r *ProviderRecords) loop(ctx context.Context) {
+	ticker := time.NewTicker(providerRecordsScanInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.scan(ctx); err != nil && ctx.Err() == nil {
+			log.Errorf("counting the provider records: %s", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scan counts the provider records in the datastore. The records are listed
+// in the order of their keys, so the records of a CID follow each other: the
+// leveldb and badger datastores list their keys in order natively.
+// The records expired since the last scan are the ones missing from the last
+// count and the new records.
+func (r *ProviderRecords) scan(ctx context.Context) error {
+	added := r.added.Load()
+	results, err := r.ds.Query(ctx, query.Query{
+		Prefix:       strings.TrimSuffix(providers.ProvidersKeyPrefix, "/"),
+		Orders:       []query.Order{query.OrderByKey{}},
+		KeysOnly:     true,
+		ReturnsSizes: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	var (
+		records, cids int64
+		bytes         uint64
+		lastCid       string
+	)
+	for res := range results.Next() {
+		if res.Error != nil {
+			return res.Error
+		}
+		records++
+		bytes += uint64(len(res.Key))
+		if res.Size > 0 {
+			bytes += uint64(res.Size)
+		}
+		k := strings.TrimPrefix(res.Key, providers.ProvidersKeyPrefix)
+		if i := strings.IndexByte(k, '/'); i >= 0 {
+			k = k[:i]
+		}
+		if k != lastCid {
+			cids++
+			lastCid = k
+		}
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.scanned.IsZero() {
+		var evicted uint64
+		if expected := r.records + added; expected > records {
+			evicted = uint64(expected - records)
+		}
+		r.evicted += evicted
+		r.evictionRate = float64(evicted) / now.Sub(r.scanned).Seconds()
+	}
+	r.added.Add(-added)
+	r.scanned, r.records, r.cids, r.bytes = now, records, cids, bytes
+	return nil
+}
+
+// Stats returns the counts of the provider records.
+func (r *ProviderRecords) Stats() ProviderRecordsStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ProviderRecordsStats{
+		Received:     r.received.Load(),
+		Accepted:     r.accepted.Load(),
+		Rejected:     r.rejected.Load(),
+		Rate:         r.meter.Snapshot().Rate,
+		Records:      r.records + r.added.Load(),
+		UniqueCids:   r.cids,
+		Bytes:        r.bytes,
+		Evicted:      r.evicted,
+		EvictionRate: r.evictionRate,
+		MaxRecords:   r.maxRecords,
+		LastScan:     r.scanned,
+	}
+}
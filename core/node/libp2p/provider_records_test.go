@@ -0,0 +1,64 @@
+package libp2p
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-kad-dht/providers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// datastoreProviderStore writes the provider records synchronously, where
+// the providers.ProviderManager writes them.
+type datastoreProviderStore struct {
+	providers.ProviderStore
+	ds datastore.Datastore
+}
+
+func (s *datastoreProviderStore) AddProvider(ctx context.Context, key []byte, prov peer.AddrInfo) error {
+	return s.ds.Put(ctx, providerRecordKey(key, prov.ID), []byte{1})
+}
+
+func TestProviderRecords(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	r := &ProviderRecords{ds: ds, maxRecords: 3}
+	self := peer.ID("self")
+	ps := r.wrap(&datastoreProviderStore{ds: ds}, self)
+	require.NoError(t, r.scan(ctx))
+
+	add := func(key string, p peer.ID) error {
+		return ps.AddProvider(ctx, []byte(key), peer.AddrInfo{ID: p})
+	}
+	require.NoError(t, add("a", "p1"))
+	require.NoError(t, add("a", "p2"))
+	require.NoError(t, add("b", "p1"))
+	require.NoError(t, add("b", self), "the records of the node itself are not capped")
+	assert.ErrorIs(t, add("c", "p1"), errProviderRecordsFull)
+	require.NoError(t, add("a", "p1"), "the stored records are refreshed when full")
+
+	stats := r.Stats()
+	assert.Equal(t, uint64(5), stats.Received)
+	assert.Equal(t, uint64(4), stats.Accepted)
+	assert.Equal(t, uint64(1), stats.Rejected)
+	assert.Equal(t, int64(3), stats.Records)
+
+	require.NoError(t, r.scan(ctx))
+	stats = r.Stats()
+	assert.Equal(t, int64(4), stats.Records, "the records of the node itself are stored")
+	assert.Equal(t, int64(2), stats.UniqueCids)
+	assert.NotZero(t, stats.Bytes)
+	assert.Zero(t, stats.Evicted)
+
+	// the provider store expires a record
+	require.NoError(t, ds.Delete(ctx, providerRecordKey([]byte("a"), "p2")))
+	require.NoError(t, r.scan(ctx))
+	stats = r.Stats()
+	assert.Equal(t, int64(3), stats.Records)
+	assert.Equal(t, uint64(1), stats.Evicted)
+	assert.Positive(t, stats.EvictionRate)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/routing"
 	"go.uber.org/fx"
 
+	"github.com/ipfs/kubo/autoconf"
 	config "github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
@@ -78,6 +79,9 @@ func BaseRouting(cfg *config.Config) interface{} {
 
 		if cr, ok := in.Router.(routinghelpers.ComposableRouter); ok {
 			for _, r := range cr.Routers() {
+				if u, ok := r.(interface{ Unwrap() routing.Routing }); ok {
+					r = u.Unwrap()
+				}
 				if dht, ok := r.(*ddht.DHT); ok {
 					dualDHT = dht
 					lc.Append(fx.Hook{
@@ -90,12 +94,13 @@ func BaseRouting(cfg *config.Config) interface{} {
 			}
 		}
 
-		if dualDHT != nil && cfg.Routing.AcceleratedDHTClient.WithDefault(config.DefaultAcceleratedDHTClient) {
+		accelerated := cfg.Routing.AcceleratedDHTClient
+		if dualDHT != nil && accelerated.WithDefault(config.DefaultAcceleratedDHTClient) {
 			cfg, err := in.Repo.Config()
 			if err != nil {
 				return out, err
 			}
-			bspeers, err := cfg.BootstrapPeers()
+			bspeers, err := autoconf.Load(context.TODO(), cfg, in.Repo.Datastore()).BootstrapPeers(cfg)
 			if err != nil {
 				return out, err
 			}
@@ -119,14 +124,30 @@ func BaseRouting(cfg *config.Config) interface{} {
 				},
 			})
 
-			// we want to also use the default HTTP routers, so wrap the FullRT client
-			// in a parallel router that calls them in parallel
-			httpRouters, err := constructDefaultHTTPRouters(cfg)
+			var dhtRouter routing.Routing = irouting.Instrument("dht-accelerated", fullRTClient)
+			var dhtClient routing.Routing = fullRTClient
+			if accelerated.ProvideOnly() {
+				// the crawled routing table only speeds up the provides, the
+				// lookups keep using the standard client
+				lookups := irouting.Instrument("dht", dualDHT)
+				dhtRouter = &irouting.Composer{
+					GetValueRouter:      lookups,
+					PutValueRouter:      lookups,
+					FindPeersRouter:     lookups,
+					FindProvidersRouter: lookups,
+					ProvideRouter:       dhtRouter,
+				}
+				dhtClient = dualDHT
+			}
+
+			// we want to also use the default HTTP routers, so wrap the DHT
+			// router in a parallel router that calls them in parallel
+			httpRouters, err := constructDefaultHTTPRouters(context.TODO(), cfg, in.Repo.Datastore())
 			if err != nil {
 				return out, err
 			}
 			routers := []*routinghelpers.ParallelRouter{
-				{Router: fullRTClient, DoNotWaitForSearchValue: true},
+				{Router: dhtRouter, DoNotWaitForSearchValue: true},
 			}
 			routers = append(routers, httpRouters...)
 			router := routinghelpers.NewComposableParallel(routers)
@@ -137,15 +158,20 @@ func BaseRouting(cfg *config.Config) interface{} {
 					Routing:  router,
 				},
 				DHT:           dualDHT,
-				DHTClient:     fullRTClient,
-				ContentRouter: fullRTClient,
+				DHTClient:     dhtClient,
+				ContentRouter: dhtClient,
 			}, nil
 		}
 
+		router := in.Router
+		if _, ok := router.(*ddht.DHT); ok {
+			// the DHT is the only router with Routing.Type=dht
+			router = irouting.Instrument("dht", router)
+		}
 		return processInitialRoutingOut{
 			Router: Router{
 				Priority: 1000,
-				Routing:  in.Router,
+				Routing:  router,
 			},
 			DHT:           dualDHT,
 			DHTClient:     dualDHT,
@@ -182,6 +208,9 @@ type p2pOnlineRoutingIn struct {
 
 	Routers   []Router `group:"routers"`
 	Validator record.Validator
+	Events    *irouting.Events `optional:"true"`
+	MDNS      *mdnsProber      `optional:"true"`
+	Static    *StaticProviders `optional:"true"`
 }
 
 // Routing will get all routers obtained from different methods
@@ -203,7 +232,17 @@ func Routing(in p2pOnlineRoutingIn) irouting.ProvideManyRouter {
 		})
 	}
 
-	return routinghelpers.NewComposableParallel(cRouters)
+	var r irouting.ProvideManyRouter = routinghelpers.NewComposableParallel(cRouters)
+	if in.MDNS != nil {
+		r = &mdnsRouter{ProvideManyRouter: r, prober: in.MDNS}
+	}
+	if in.Static != nil {
+		r = &staticRouter{ProvideManyRouter: r, providers: in.Static}
+	}
+	if in.Events != nil {
+		return in.Events.Wrap(r)
+	}
+	return r
 }
 
 // OfflineRouting provides a special Router to the routers list when we are creating a offline node.
@@ -224,29 +263,36 @@ type p2pPSRoutingIn struct {
 	PubSub    *pubsub.PubSub `optional:"true"`
 }
 
-func PubsubRouter(mctx helpers.MetricsCtx, lc fx.Lifecycle, in p2pPSRoutingIn) (p2pRouterOut, *namesys.PubsubValueStore, error) {
+func PubsubRouter(mctx helpers.MetricsCtx, lc fx.Lifecycle, in p2pPSRoutingIn) (p2pRouterOut, *namesys.PubsubValueStore, *IpnsPubsubNames, error) {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	names := newIpnsPubsubNames(ctx)
 	psRouter, err := namesys.NewPubsubValueStore(
-		helpers.LifecycleCtx(mctx, lc),
+		ctx,
 		in.Host,
 		in.PubSub,
 		in.Validator,
 		namesys.WithRebroadcastInterval(time.Minute),
+		namesys.WithDatastore(names.datastore()),
 	)
 	if err != nil {
-		return p2pRouterOut{}, nil, err
+		return p2pRouterOut{}, nil, nil, err
 	}
+	names.ps = psRouter
 
 	return p2pRouterOut{
 		Router: Router{
 			Routing: &routinghelpers.Compose{
-				ValueStore: &routinghelpers.LimitedValueStore{
-					ValueStore: psRouter,
-					Namespaces: []string{"ipns"},
-				},
+				// only the calls to the value store are recorded
+				ValueStore: irouting.Instrument("pubsub", &routinghelpers.Compose{
+					ValueStore: &routinghelpers.LimitedValueStore{
+						ValueStore: &ipnsPubsubValueStore{PubsubValueStore: psRouter, names: names},
+						Namespaces: []string{"ipns"},
+					},
+				}),
 			},
 			Priority: 100,
 		},
-	}, psRouter, nil
+	}, psRouter, names, nil
 }
 
 func autoRelayFeeder(cfgPeering config.Peering, peerChan chan<- peer.AddrInfo) fx.Option {
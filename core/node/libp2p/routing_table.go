@@ -0,0 +1,124 @@
+package libp2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/repo"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	ddht "github.com/libp2p/go-libp2p-kad-dht/dual"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"go.uber.org/fx"
+)
+
+var routingTableKey = datastore.NewKey("/local/routingtable")
+
+// ErrNoRoutingTable is returned when the node runs no DHT with a routing
+// table, such as with Routing.Type "delegated" or the accelerated DHT client.
+var ErrNoRoutingTable = errors.New("the node runs no DHT with a routing table")
+
+// RoutingTableSnapshot is the routing table of the WAN and LAN DHTs, with
+// the addresses of the peers.
+type RoutingTableSnapshot struct {
+	Saved time.Time
+	WAN   []peer.AddrInfo
+	LAN   []peer.AddrInfo
+}
+
+// SnapshotRoutingTable returns the routing table of d.
+func SnapshotRoutingTable(h host.Host, d *ddht.DHT) (RoutingTableSnapshot, error) {
+	if d == nil {
+		return RoutingTableSnapshot{}, ErrNoRoutingTable
+	}
+	list := func(d *dht.IpfsDHT) []peer.AddrInfo {
+		peers := d.RoutingTable().ListPeers()
+		infos := make([]peer.AddrInfo, 0, len(peers))
+		for _, id := range peers {
+			if addrs := h.Peerstore().Addrs(id); len(addrs) > 0 {
+				infos = append(infos, peer.AddrInfo{ID: id, Addrs: addrs})
+			}
+		}
+		return infos
+	}
+	return RoutingTableSnapshot{Saved: time.Now(), WAN: list(d.WAN), LAN: list(d.LAN)}, nil
+}
+
+// RestoreRoutingTable adds the peers of the snapshot to the routing table of
+// d, and returns how many were added. The peers that turn out unreachable
+// are evicted by the DHT as usual.
+func RestoreRoutingTable(h host.Host, d *ddht.DHT, s RoutingTableSnapshot) (int, error) {
+	if d == nil {
+		return 0, ErrNoRoutingTable
+	}
+	restore := func(d *dht.IpfsDHT, infos []peer.AddrInfo) int {
+		added := 0
+		for _, ai := range infos {
+			if ai.ID == h.ID() || len(ai.Addrs) == 0 {
+				continue
+			}
+			h.Peerstore().AddAddrs(ai.ID, ai.Addrs, peerstore.AddressTTL)
+			// replaceable, as the peer is not known to be alive yet
+			if ok, err := d.RoutingTable().TryAddPeer(ai.ID, false, true); err == nil && ok {
+				added++
+			}
+		}
+		return added
+	}
+	return restore(d.WAN, s.WAN) + restore(d.LAN, s.LAN), nil
+}
+
+// PersistRoutingTable saves the routing table of the DHT in the repo on
+// shutdown, and preloads it on startup, when Routing.PersistTable is set.
+func PersistRoutingTable(enabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	return fx.Invoke(func(lc fx.Lifecycle, h host.Host, d *ddht.DHT, repo repo.Repo) {
+		if d == nil {
+			log.Warn("Routing.PersistTable is set, but the node runs no DHT with a routing table")
+			return
+		}
+		ds := repo.Datastore()
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				v, err := ds.Get(ctx, routingTableKey)
+				if errors.Is(err, datastore.ErrNotFound) {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("Routing.PersistTable: %w", err)
+				}
+				var s RoutingTableSnapshot
+				if err := json.Unmarshal(v, &s); err != nil {
+					log.Warnf("Routing.PersistTable: ignoring the saved routing table: %s", err)
+					return nil
+				}
+				added, _ := RestoreRoutingTable(h, d, s)
+				log.Infof("Routing.PersistTable: preloaded %d peers saved %s", added, s.Saved.Format(time.RFC3339))
+				return nil
+			},
+			// the hook runs before the DHT is closed, its own hook being
+			// appended first
+			OnStop: func(ctx context.Context) error {
+				s, err := SnapshotRoutingTable(h, d)
+				if err != nil {
+					return err
+				}
+				v, err := json.Marshal(s)
+				if err != nil {
+					return err
+				}
+				if err := ds.Put(ctx, routingTableKey, v); err != nil {
+					return fmt.Errorf("Routing.PersistTable: %w", err)
+				}
+				return nil
+			},
+		})
+	})
+}
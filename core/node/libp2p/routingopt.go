@@ -7,10 +7,12 @@ import (
 	"time"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/autoconf"
 	"github.com/ipfs/kubo/config"
 	irouting "github.com/ipfs/kubo/routing"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
+	"github.com/libp2p/go-libp2p-kad-dht/providers"
 	record "github.com/libp2p/go-libp2p-record"
 	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
 	host "github.com/libp2p/go-libp2p/core/host"
@@ -27,6 +29,7 @@ type RoutingOptionArgs struct {
 	OptimisticProvide             bool
 	OptimisticProvideJobsPoolSize int
 	LoopbackAddressesOnLanDHT     bool
+	ProviderRecords               *ProviderRecords
 }
 
 type RoutingOption func(args RoutingOptionArgs) (routing.Routing, error)
@@ -44,14 +47,28 @@ func init() {
 	}
 }
 
-func constructDefaultHTTPRouters(cfg *config.Config) ([]*routinghelpers.ParallelRouter, error) {
+// constructDefaultHTTPRouters returns the routers of Routing.DelegatedRouters,
+// with the ones of AutoConf for "auto", or the default HTTP routers. Endpoints
+// set in the config are also used to find peers and IPNS records, and to
+// publish with Routing.DelegatedPublishing.
+func constructDefaultHTTPRouters(ctx context.Context, cfg *config.Config, ds datastore.Datastore) ([]*routinghelpers.ParallelRouter, error) {
+	endpoints := defaultHTTPRouters
+	delegated := autoconf.Load(ctx, cfg, ds).Routers(cfg)
+	configured := len(delegated) > 0
+	if configured {
+		endpoints = delegated
+	}
+	publish := cfg.Routing.DelegatedPublishing.WithDefault(config.DefaultDelegatedPublishing)
+
 	var routers []*routinghelpers.ParallelRouter
 	// Append HTTP routers for additional speed
-	for _, endpoint := range defaultHTTPRouters {
-		httpRouter, err := irouting.ConstructHTTPRouter(endpoint, cfg.Identity.PeerID, httpAddrsFromConfig(cfg.Addresses), cfg.Identity.PrivKey)
+	for _, endpoint := range endpoints {
+		addrs := append(httpAddrsFromConfig(cfg.Addresses), cfg.Bitswap.HTTPAnnounce...)
+		httpRouter, err := irouting.ConstructHTTPRouter(endpoint, cfg.Identity.PeerID, addrs, cfg.Identity.PrivKey)
 		if err != nil {
 			return nil, err
 		}
+		httpRouter = irouting.Instrument(endpoint, httpRouter)
 
 		r := &irouting.Composer{
 			GetValueRouter:      routinghelpers.Null{},
@@ -60,6 +77,10 @@ func constructDefaultHTTPRouters(cfg *config.Config) ([]*routinghelpers.Parallel
 			FindPeersRouter:     routinghelpers.Null{},
 			FindProvidersRouter: httpRouter,
 		}
+		if configured {
+			r.GetValueRouter = httpRouter
+			r.FindPeersRouter = httpRouter
+		}
 
 		routers = append(routers, &routinghelpers.ParallelRouter{
 			Router:                  r,
@@ -68,6 +89,22 @@ func constructDefaultHTTPRouters(cfg *config.Config) ([]*routinghelpers.Parallel
 			DoNotWaitForSearchValue: true,
 			ExecuteAfter:            0,
 		})
+
+		if publish {
+			// writes are batched and retried by the HTTP router, so they are
+			// not bound by the timeout of the reads
+			routers = append(routers, &routinghelpers.ParallelRouter{
+				Router: &irouting.Composer{
+					GetValueRouter:      routinghelpers.Null{},
+					PutValueRouter:      httpRouter,
+					ProvideRouter:       httpRouter,
+					FindPeersRouter:     routinghelpers.Null{},
+					FindProvidersRouter: routinghelpers.Null{},
+				},
+				IgnoreError:             true,
+				DoNotWaitForSearchValue: true,
+			})
+		}
 	}
 	return routers, nil
 }
@@ -84,13 +121,13 @@ func ConstructDefaultRouting(cfg *config.Config, routingOpt RoutingOption) Routi
 			return nil, err
 		}
 		routers = append(routers, &routinghelpers.ParallelRouter{
-			Router:                  dhtRouting,
+			Router:                  irouting.Instrument("dht", dhtRouting),
 			IgnoreError:             false,
 			DoNotWaitForSearchValue: true,
 			ExecuteAfter:            0,
 		})
 
-		httpRouters, err := constructDefaultHTTPRouters(cfg)
+		httpRouters, err := constructDefaultHTTPRouters(args.Ctx, cfg, args.Datastore)
 		if err != nil {
 			return nil, err
 		}
@@ -102,6 +139,18 @@ func ConstructDefaultRouting(cfg *config.Config, routingOpt RoutingOption) Routi
 	}
 }
 
+// ConstructDelegatedOnlyRouting returns the routers used when Routing.Type is
+// set to "delegated": the HTTP routers, without the DHT.
+func ConstructDelegatedOnlyRouting(cfg *config.Config) RoutingOption {
+	return func(args RoutingOptionArgs) (routing.Routing, error) {
+		routers, err := constructDefaultHTTPRouters(args.Ctx, cfg, args.Datastore)
+		if err != nil {
+			return nil, err
+		}
+		return routinghelpers.NewComposableParallel(routers), nil
+	}
+}
+
 // constructDHTRouting is used when Routing.Type = "dht"
 func constructDHTRouting(mode dht.ModeOpt) RoutingOption {
 	return func(args RoutingOptionArgs) (routing.Routing, error) {
@@ -124,6 +173,16 @@ func constructDHTRouting(mode dht.ModeOpt) RoutingOption {
 		if args.LoopbackAddressesOnLanDHT {
 			lanOptions = append(lanOptions, dht.AddressFilter(nil))
 		}
+		if args.ProviderRecords != nil {
+			// each DHT closes its provider store
+			for _, opts := range []*[]dht.Option{&wanOptions, &lanOptions} {
+				ps, err := providers.NewProviderManager(args.Host.ID(), args.Host.Peerstore(), args.Datastore)
+				if err != nil {
+					return nil, err
+				}
+				*opts = append(*opts, dht.ProviderStore(args.ProviderRecords.wrap(ps, args.Host.ID())))
+			}
+		}
 		return dual.New(
 			args.Ctx, args.Host,
 			dual.DHTOption(dhtOpts...),
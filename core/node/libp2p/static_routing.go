@@ -0,0 +1,229 @@
+package libp2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	irouting "github.com/ipfs/kubo/routing"
+	gocarv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-ipld-prime"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/routing"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multibase"
+	"go.uber.org/fx"
+)
+
+// StaticProviders maps CIDs, and prefixes of CIDs, to the peers known to
+// provide them, read from the index of Routing.StaticProviders.
+type StaticProviders struct {
+	// cids maps the multihashes of the CIDs of the index, whatever their
+	// version and codec, to their providers
+	cids map[string][]peer.AddrInfo
+	// prefixes are matched against the base32 CIDv1 of the CIDs looked up
+	prefixes []staticPrefix
+}
+
+type staticPrefix struct {
+	prefix    string
+	providers []peer.AddrInfo
+}
+
+// StaticRouting answers the provider lookups of the CIDs of the index of
+// Routing.StaticProviders without asking the other routers.
+func StaticRouting(cfg config.Routing) fx.Option {
+	file := cfg.StaticProviders.WithDefault("")
+	if file == "" {
+		return fx.Options()
+	}
+	return fx.Provide(func(h host.Host) (*StaticProviders, error) {
+		path := file
+		if !filepath.IsAbs(path) {
+			repoPath, err := config.PathRoot()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(repoPath, path)
+		}
+		sp, err := LoadStaticProviders(path)
+		if err != nil {
+			return nil, fmt.Errorf("Routing.StaticProviders: %w", err)
+		}
+		// the providers are dialed with the addresses of the index
+		for _, ai := range sp.all() {
+			h.Peerstore().AddAddrs(ai.ID, ai.Addrs, peerstore.PermanentAddrTTL)
+		}
+		return sp, nil
+	})
+}
+
+// LoadStaticProviders reads an index of providers: a JSON object, or a CAR
+// whose root is a dag-cbor or dag-json map of the same shape, of CIDs to the
+// multiaddrs of their providers, including their /p2p peer ID. A key ending
+// with "*" is a prefix of base32 CIDv1s.
+func LoadStaticProviders(file string) (*StaticProviders, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(file, ".car") {
+		if data, err = staticProvidersFromCAR(data); err != nil {
+			return nil, err
+		}
+	}
+	var index map[string][]string
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid index: %w", err)
+	}
+
+	sp := &StaticProviders{cids: make(map[string][]peer.AddrInfo)}
+	for k, addrs := range index {
+		mas := make([]ma.Multiaddr, 0, len(addrs))
+		for _, s := range addrs {
+			a, err := ma.NewMultiaddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid multiaddr %q of %q: %w", s, k, err)
+			}
+			mas = append(mas, a)
+		}
+		providers, err := peer.AddrInfosFromP2pAddrs(mas...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid providers of %q: %w", k, err)
+		}
+
+		if prefix, ok := strings.CutSuffix(k, "*"); ok {
+			sp.prefixes = append(sp.prefixes, staticPrefix{prefix: prefix, providers: providers})
+			continue
+		}
+		c, err := cid.Decode(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CID %q: %w", k, err)
+		}
+		sp.cids[string(c.Hash())] = append(sp.cids[string(c.Hash())], providers...)
+	}
+	return sp, nil
+}
+
+// staticProvidersFromCAR returns the root of a CAR encoded in dag-json.
+func staticProvidersFromCAR(data []byte) ([]byte, error) {
+	br, err := gocarv2.NewBlockReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(br.Roots) != 1 {
+		return nil, fmt.Errorf("the CAR must have one root, not %d", len(br.Roots))
+	}
+	for {
+		blk, err := br.Next()
+		if err != nil {
+			return nil, fmt.Errorf("root %s not found in the CAR: %w", br.Roots[0], err)
+		}
+		if !blk.Cid().Equals(br.Roots[0]) {
+			continue
+		}
+		decoder, err := multicodec.LookupDecoder(blk.Cid().Prefix().Codec)
+		if err != nil {
+			return nil, err
+		}
+		nb := basicnode.Prototype.Any.NewBuilder()
+		if err := decoder(nb, bytes.NewReader(blk.RawData())); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := ipld.EncodeStreaming(&buf, nb.Build(), dagjson.Encode); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// Providers returns the providers of c in the index.
+func (sp *StaticProviders) Providers(c cid.Cid) []peer.AddrInfo {
+	providers := sp.cids[string(c.Hash())]
+	if len(sp.prefixes) == 0 {
+		return providers
+	}
+	s, err := cid.NewCidV1(c.Type(), c.Hash()).StringOfBase(multibase.Base32)
+	if err != nil {
+		return providers
+	}
+	for _, p := range sp.prefixes {
+		if strings.HasPrefix(s, p.prefix) {
+			providers = append(providers, p.providers...)
+		}
+	}
+	return providers
+}
+
+func (sp *StaticProviders) all() []peer.AddrInfo {
+	var all []peer.AddrInfo
+	for _, providers := range sp.cids {
+		all = append(all, providers...)
+	}
+	for _, p := range sp.prefixes {
+		all = append(all, p.providers...)
+	}
+	return all
+}
+
+// staticRouter returns the providers of the index of Routing.StaticProviders
+// without asking the wrapped router, which is only asked for the CIDs missing
+// from the index.
+type staticRouter struct {
+	irouting.ProvideManyRouter
+	providers *StaticProviders
+}
+
+// Unwrap returns the wrapped router.
+func (r *staticRouter) Unwrap() routing.Routing {
+	return r.ProvideManyRouter
+}
+
+func (r *staticRouter) Ready() bool {
+	if rr, ok := r.ProvideManyRouter.(routinghelpers.ReadyAbleRouter); ok {
+		return rr.Ready()
+	}
+	return true
+}
+
+func (r *staticRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	providers := r.providers.Providers(c)
+	if len(providers) == 0 {
+		return r.ProvideManyRouter.FindProvidersAsync(ctx, c, count)
+	}
+
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		seen := make(map[peer.ID]struct{}, len(providers))
+		for _, ai := range providers {
+			if _, ok := seen[ai.ID]; ok {
+				continue
+			}
+			seen[ai.ID] = struct{}{}
+			select {
+			case out <- ai:
+			case <-ctx.Done():
+				return
+			}
+			if count > 0 && len(seen) == count {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,75 @@
+package libp2p
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	carblockstore "github.com/ipld/go-car/v2/blockstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticRouter(t *testing.T) {
+	ctx := context.Background()
+	const (
+		provider1 = "12D3KooWJHtzJLLaX6PZyBMkJuvKc7aXX2YaEj1xEf9R7NgYhjLb"
+		provider2 = "12D3KooWE4BVi1s3UMJPoGRfu8k4nvzZFcw6sWAcEH4p9eQSXGTL"
+	)
+	listed := blocks.NewBlock([]byte("listed")).Cid()
+	unlisted := blocks.NewBlock([]byte("unlisted")).Cid()
+	prefixed := cid.NewCidV1(cid.Raw, blocks.NewBlock([]byte("prefixed")).Cid().Hash())
+	index := `{
+		"` + listed.String() + `": ["/ip4/192.0.2.1/tcp/4001/p2p/` + provider1 + `"],
+		"` + prefixed.String()[:20] + `*": ["/ip4/192.0.2.2/tcp/4001/p2p/` + provider2 + `"]
+	}`
+
+	findProviders := func(sp *StaticProviders, c cid.Cid) []string {
+		r := &staticRouter{ProvideManyRouter: nullRouter{}, providers: sp}
+		var found []string
+		for ai := range r.FindProvidersAsync(ctx, c, 0) {
+			found = append(found, ai.ID.String())
+		}
+		return found
+	}
+
+	t.Run("reads a JSON index", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "providers.json")
+		require.NoError(t, os.WriteFile(file, []byte(index), 0o600))
+		sp, err := LoadStaticProviders(file)
+		require.NoError(t, err)
+
+		require.Equal(t, []string{provider1}, findProviders(sp, listed))
+		// the CIDv0 and other codecs of the same multihash are listed too
+		require.Equal(t, []string{provider1}, findProviders(sp, cid.NewCidV0(listed.Hash())))
+		require.Empty(t, findProviders(sp, unlisted))
+
+		// the prefixes are matched against the base32 CIDv1
+		require.Equal(t, []string{provider2}, findProviders(sp, prefixed))
+	})
+
+	t.Run("reads a CAR index", func(t *testing.T) {
+		rootCid := cid.NewCidV1(cid.DagJSON, blocks.NewBlock([]byte(index)).Cid().Hash())
+		root, err := blocks.NewBlockWithCid([]byte(index), rootCid)
+		require.NoError(t, err)
+
+		file := filepath.Join(t.TempDir(), "providers.car")
+		bs, err := carblockstore.OpenReadWrite(file, []cid.Cid{rootCid}, carblockstore.WriteAsCarV1(true))
+		require.NoError(t, err)
+		require.NoError(t, bs.Put(ctx, root))
+		require.NoError(t, bs.Finalize())
+
+		sp, err := LoadStaticProviders(file)
+		require.NoError(t, err)
+		require.Equal(t, []string{provider1}, findProviders(sp, listed))
+	})
+
+	t.Run("rejects providers without peer ID", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "providers.json")
+		require.NoError(t, os.WriteFile(file, []byte(`{"`+listed.String()+`": ["/ip4/192.0.2.1/tcp/4001"]}`), 0o600))
+		_, err := LoadStaticProviders(file)
+		require.Error(t, err)
+	})
+}
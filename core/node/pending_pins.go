@@ -0,0 +1,151 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	blockstore "github.com/ipfs/boxo/blockstore"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+)
+
+var pendingPinsKey = datastore.NewKey("/local/pins/pending")
+
+// PendingPin is a pin being added.
+type PendingPin struct {
+	Cid       cid.Cid
+	Recursive bool
+	Name      string `json:",omitempty"`
+}
+
+// PendingPins saves the pins being added with 'ipfs pin add', for
+// Pinning.ResumeInterrupted. The pins still saved when the daemon starts were
+// interrupted by its shutdown and are resumed.
+type PendingPins struct {
+	ctx    context.Context
+	ds     datastore.Datastore
+	bs     blockstore.GCLocker
+	pinner pin.Pinner
+	dag    ipld.DAGService
+	prov   provider.System
+}
+
+// ResumePendingPins returns the pending pins, and resumes the interrupted
+// ones on startup, when Pinning.ResumeInterrupted is enabled.
+func ResumePendingPins(enabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, bs blockstore.GCLocker, pinner pin.Pinner, dag ipld.DAGService, prov provider.System) *PendingPins {
+		p := &PendingPins{
+			ctx:    helpers.LifecycleCtx(mctx, lc),
+			ds:     repo.Datastore(),
+			bs:     bs,
+			pinner: pinner,
+			dag:    dag,
+			prov:   prov,
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(startCtx context.Context) error {
+				pending, err := p.Entries(startCtx)
+				if err != nil {
+					return err
+				}
+				if len(pending) > 0 {
+					go p.resume(pending)
+				}
+				return nil
+			},
+		})
+		return p
+	})
+}
+
+// Add saves pin until Done is called for its CID.
+func (p *PendingPins) Add(ctx context.Context, pin PendingPin) error {
+	val, err := json.Marshal(pin)
+	if err != nil {
+		return err
+	}
+	return p.ds.Put(ctx, pendingPinKey(pin.Cid), val)
+}
+
+// Done forgets the pin of c once added, or failed with err. Pins failed
+// because the daemon is stopping are kept, to be resumed on the next start.
+func (p *PendingPins) Done(ctx context.Context, c cid.Cid, err error) error {
+	if err != nil && p.ctx.Err() != nil {
+		return nil
+	}
+	return p.ds.Delete(ctx, pendingPinKey(c))
+}
+
+// Entries returns the pending pins.
+func (p *PendingPins) Entries(ctx context.Context) ([]PendingPin, error) {
+	results, err := p.ds.Query(ctx, query.Query{Prefix: pendingPinsKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	res, err := results.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]PendingPin, 0, len(res))
+	for _, r := range res {
+		var pp PendingPin
+		if err := json.Unmarshal(r.Value, &pp); err != nil {
+			return nil, fmt.Errorf("pending pin %s: %w", r.Key, err)
+		}
+		pending = append(pending, pp)
+	}
+	return pending, nil
+}
+
+func (p *PendingPins) resume(pending []PendingPin) {
+	logger.Infof("resuming %d interrupted pins", len(pending))
+	for _, pp := range pending {
+		err := p.pin(pp)
+		if p.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Errorf("resuming pin of %s: %s", pp.Cid, err)
+		} else {
+			logger.Infof("resumed pin of %s", pp.Cid)
+		}
+		if err := p.Done(p.ctx, pp.Cid, err); err != nil {
+			logger.Errorf("forgetting pending pin of %s: %s", pp.Cid, err)
+		}
+	}
+}
+
+// pin adds pp as 'ipfs pin add' does.
+func (p *PendingPins) pin(pp PendingPin) error {
+	ctx := WithFetchPriority(p.ctx, FetchPriorityBackground)
+	nd, err := p.dag.Get(ctx, pp.Cid)
+	if err != nil {
+		return err
+	}
+
+	defer p.bs.PinLock(ctx).Unlock(ctx)
+	if err := p.pinner.Pin(ctx, nd, pp.Recursive, pp.Name); err != nil {
+		return err
+	}
+	if err := p.prov.Provide(nd.Cid()); err != nil {
+		return err
+	}
+	return p.pinner.Flush(ctx)
+}
+
+func pendingPinKey(c cid.Cid) datastore.Key {
+	return pendingPinsKey.ChildString(c.String())
+}
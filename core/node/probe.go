@@ -0,0 +1,263 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	irouting "github.com/ipfs/kubo/routing"
+	"github.com/libp2p/go-libp2p/core/routing"
+	mh "github.com/multiformats/go-multihash"
+	"go.uber.org/fx"
+)
+
+const (
+	// retrievalProbeSize is the size of the random block published by each
+	// probe.
+	retrievalProbeSize = 256
+	// retrievalProbeRetry is the time between two requests to a gateway that
+	// did not return the block yet.
+	retrievalProbeRetry = 2 * time.Second
+)
+
+// RetrievalProbeBuckets are the upper bounds, in seconds, of the buckets of
+// the publish to retrievable latency histogram.
+var RetrievalProbeBuckets = []float64{1, 2, 5, 10, 30, 60, 120, 300, 600}
+
+// RetrievalProbeStatus is the state of the probes retrieving from one gateway.
+type RetrievalProbeStatus struct {
+	Gateway string
+	// Probes is the number of completed probes, Failures the number of them
+	// that did not retrieve the block within Probes.Retrieval.Timeout.
+	Probes   uint64
+	Failures uint64
+	// LatencyBuckets counts the successful probes per upper bound of
+	// RetrievalProbeBuckets, cumulatively, and LatencySum is their total
+	// latency, as in a Prometheus histogram.
+	LatencyBuckets []uint64
+	LatencySum     time.Duration
+	LastRun        time.Time
+	LastCid        string
+	LastLatency    time.Duration
+	LastError      string
+}
+
+// RetrievalProber runs Probes.Retrieval: it publishes a random block every
+// Interval and measures when each gateway returns it.
+type RetrievalProber struct {
+	ctx      context.Context
+	bs       blockstore.Blockstore
+	router   irouting.ProvideManyRouter
+	client   *http.Client
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	gateways []*RetrievalProbeStatus
+}
+
+// RetrievalProbes returns the retrieval prober when Probes.Retrieval is
+// enabled.
+func RetrievalProbes(cfg config.RetrievalProbe) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultRetrievalProbeEnabled) {
+		return fx.Options()
+	}
+	if len(cfg.Gateways) == 0 {
+		return fx.Error(fmt.Errorf("Probes.Retrieval.Gateways must be set when Probes.Retrieval is enabled"))
+	}
+	interval := cfg.Interval.WithDefault(config.DefaultRetrievalProbeInterval)
+	timeout := cfg.Timeout.WithDefault(config.DefaultRetrievalProbeTimeout)
+	if interval <= 0 || timeout <= 0 {
+		return fx.Error(fmt.Errorf("Probes.Retrieval.Interval and Probes.Retrieval.Timeout must be positive"))
+	}
+
+	return fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, bs blockstore.Blockstore, router irouting.ProvideManyRouter) *RetrievalProber {
+		p := &RetrievalProber{
+			ctx:      helpers.LifecycleCtx(mctx, lc),
+			bs:       bs,
+			router:   router,
+			client:   &http.Client{},
+			interval: interval,
+			timeout:  timeout,
+		}
+		for _, gw := range cfg.Gateways {
+			p.gateways = append(p.gateways, &RetrievalProbeStatus{
+				Gateway:        strings.TrimSuffix(gw, "/"),
+				LatencyBuckets: make([]uint64, len(RetrievalProbeBuckets)),
+			})
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go p.loop()
+				return nil
+			},
+		})
+		return p
+	})
+}
+
+func (p *RetrievalProber) loop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.ctx.Done():
+			return
+		}
+		p.probe()
+	}
+}
+
+// probe publishes a new random block and waits for every gateway to return
+// it, or for the timeout.
+func (p *RetrievalProber) probe() {
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	blk, err := newProbeBlock()
+	if err == nil {
+		err = p.bs.Put(ctx, blk)
+	}
+	if err == nil {
+		defer func() {
+			// the block only matters while it is retrieved
+			_ = p.bs.DeleteBlock(p.ctx, blk.Cid())
+		}()
+		// without routing, the block can still be found over Bitswap
+		if err = p.router.Provide(ctx, blk.Cid(), true); errors.Is(err, routing.ErrNotSupported) {
+			err = nil
+		} else if err != nil {
+			err = fmt.Errorf("announcing probe block: %w", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, gw := range p.gateways {
+		if err != nil {
+			p.record(gw, blk, start, 0, err)
+			continue
+		}
+		wg.Add(1)
+		go func(gw *RetrievalProbeStatus) {
+			defer wg.Done()
+			latency, err := p.retrieve(ctx, gw.Gateway, blk, start)
+			p.record(gw, blk, start, latency, err)
+		}(gw)
+	}
+	wg.Wait()
+}
+
+// retrieve requests blk from gateway until it is returned, and returns the
+// time since start.
+func (p *RetrievalProber) retrieve(ctx context.Context, gateway string, blk blocks.Block, start time.Time) (time.Duration, error) {
+	for {
+		err := fetchProbeBlock(ctx, p.client, gateway, blk)
+		if err == nil {
+			return time.Since(start), nil
+		}
+		logger.Debugf("retrieval probe of %s from %s: %s", blk.Cid(), gateway, err)
+
+		timer := time.NewTimer(retrievalProbeRetry)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, fmt.Errorf("not retrieved within %s: %w", p.timeout, err)
+		}
+	}
+}
+
+func (p *RetrievalProber) record(gw *RetrievalProbeStatus, blk blocks.Block, start time.Time, latency time.Duration, err error) {
+	if p.ctx.Err() != nil {
+		// the daemon is stopping
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	gw.Probes++
+	gw.LastRun = start
+	gw.LastCid = ""
+	if blk != nil {
+		gw.LastCid = blk.Cid().String()
+	}
+	gw.LastLatency = latency
+	gw.LastError = ""
+	if err != nil {
+		logger.Warnf("retrieval probe from %s failed: %s", gw.Gateway, err)
+		gw.Failures++
+		gw.LastError = err.Error()
+		return
+	}
+	gw.LatencySum += latency
+	for i, bound := range RetrievalProbeBuckets {
+		if latency.Seconds() <= bound {
+			gw.LatencyBuckets[i]++
+		}
+	}
+}
+
+// Status returns the state of the probes of every gateway, in configuration
+// order.
+func (p *RetrievalProber) Status() []RetrievalProbeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := make([]RetrievalProbeStatus, len(p.gateways))
+	for i, gw := range p.gateways {
+		st[i] = *gw
+		st[i].LatencyBuckets = append([]uint64(nil), gw.LatencyBuckets...)
+	}
+	return st
+}
+
+func newProbeBlock() (blocks.Block, error) {
+	data := make([]byte, retrievalProbeSize)
+	if _, err := rand.Read(data); err != nil {
+		return nil, err
+	}
+	hash, err := mh.Sum(data, mh.SHA2_256, -1)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.NewBlockWithCid(data, cid.NewCidV1(cid.Raw, hash))
+}
+
+// fetchProbeBlock requests blk from a trustless gateway and checks the
+// response.
+func fetchProbeBlock(ctx context.Context, client *http.Client, gateway string, blk blocks.Block) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gateway+"/ipfs/"+blk.Cid().String()+"?format=raw", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, retrievalProbeSize+1))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, blk.RawData()) {
+		return fmt.Errorf("gateway returned different data")
+	}
+	return nil
+}
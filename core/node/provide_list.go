@@ -0,0 +1,144 @@
+package node
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"time"
+
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+
+	"github.com/ipfs/kubo/repo"
+)
+
+var provideListKey = datastore.NewKey("/local/provide/list")
+
+// ProvideListEntry is a CID of the provide list. Expires is zero for entries
+// provided until removed.
+type ProvideListEntry struct {
+	Cid     cid.Cid
+	Expires time.Time
+}
+
+// ProvideList is the list of CIDs maintained with 'ipfs provide add' and
+// 'ipfs provide rm'. They are reprovided in addition to the keys of
+// Reprovider.Strategy, whether they are pinned or not, until they expire.
+type ProvideList struct {
+	ds     datastore.Datastore
+	prefix datastore.Key
+}
+
+// NewProvideList creates the ProvideList persisted in the repo.
+func NewProvideList(repo repo.Repo) *ProvideList {
+	return &ProvideList{ds: repo.Datastore(), prefix: provideListKey}
+}
+
+// Add adds c to the list, or updates its expiry if already listed. A zero ttl
+// keeps it listed until removed.
+func (l *ProvideList) Add(ctx context.Context, c cid.Cid, ttl time.Duration) (ProvideListEntry, error) {
+	e := ProvideListEntry{Cid: c}
+	var expires int64
+	if ttl > 0 {
+		e.Expires = time.Now().Add(ttl)
+		expires = e.Expires.UnixNano()
+	}
+	return e, l.ds.Put(ctx, l.entryKey(c), binary.AppendVarint(nil, expires))
+}
+
+// Remove removes c from the list. It returns datastore.ErrNotFound if c is
+// not listed.
+func (l *ProvideList) Remove(ctx context.Context, c cid.Cid) error {
+	k := l.entryKey(c)
+	has, err := l.ds.Has(ctx, k)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return datastore.ErrNotFound
+	}
+	return l.ds.Delete(ctx, k)
+}
+
+// Entries returns the entries of the list sorted by CID. Expired entries are
+// dropped.
+func (l *ProvideList) Entries(ctx context.Context) ([]ProvideListEntry, error) {
+	results, err := l.ds.Query(ctx, query.Query{Prefix: l.prefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	res, err := results.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]ProvideListEntry, 0, len(res))
+	for _, r := range res {
+		k := datastore.NewKey(r.Key)
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		e := ProvideListEntry{Cid: c}
+		if expires, _ := binary.Varint(r.Value); expires != 0 {
+			e.Expires = time.Unix(0, expires)
+			if !e.Expires.After(now) {
+				if err := l.ds.Delete(ctx, k); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Cid.KeyString() < entries[j].Cid.KeyString()
+	})
+	return entries, nil
+}
+
+// KeyChanFunc returns the CIDs of the list, for the reprovider.
+func (l *ProvideList) KeyChanFunc() provider.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		entries, err := l.Entries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ch := make(chan cid.Cid)
+		go func() {
+			defer close(ch)
+			for _, e := range entries {
+				select {
+				case ch <- e.Cid:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return ch, nil
+	}
+}
+
+// expiry returns the expiry of c, and whether it is listed and not expired.
+func (l *ProvideList) expiry(ctx context.Context, c cid.Cid) (time.Time, bool, error) {
+	v, err := l.ds.Get(ctx, l.entryKey(c))
+	if err == datastore.ErrNotFound {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	expires, _ := binary.Varint(v)
+	if expires == 0 {
+		return time.Time{}, true, nil
+	}
+	t := time.Unix(0, expires)
+	return t, t.After(time.Now()), nil
+}
+
+func (l *ProvideList) entryKey(c cid.Cid) datastore.Key {
+	return l.prefix.ChildString(c.String())
+}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/boxo/fetcher"
 	pin "github.com/ipfs/boxo/pinning/pinner"
 	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 	"go.uber.org/fx"
@@ -16,10 +18,16 @@ import (
 
 func ProviderSys(reprovideInterval time.Duration, acceleratedDHTClient bool) fx.Option {
 	const magicThroughputReportCount = 128
-	return fx.Provide(func(lc fx.Lifecycle, cr irouting.ProvideManyRouter, keyProvider provider.KeyChanFunc, repo repo.Repo, bs blockstore.Blockstore) (provider.System, error) {
+	return fx.Provide(func(lc fx.Lifecycle, cr irouting.ProvideManyRouter, keyProvider provider.KeyChanFunc, list *ProvideList, repo repo.Repo, bs blockstore.Blockstore, clk clock.Clock) (provider.System, error) {
+		// the provide list is reprovided along with the keys of the strategy
+		keyProvider = provider.NewPrioritizedProvider(countKeys("provide-list", list.KeyChanFunc()), keyProvider)
+		// the announcements of new keys and of reprovides are counted for
+		// ipfs stats provide
+		announces := newAnnounceCounter(cr)
 		opts := []provider.Option{
-			provider.Online(cr),
-			provider.ReproviderInterval(reprovideInterval),
+			provider.Online(announces),
+			// reprovides are spread over the interval by the SweepingProvider
+			provider.ReproviderInterval(0),
 			provider.KeyProvider(keyProvider),
 		}
 		if !acceleratedDHTClient {
@@ -107,10 +115,11 @@ https://github.com/ipfs/kubo/blob/master/docs/config.md#routingaccelerateddhtcli
 					return false
 				}, magicThroughputReportCount))
 		}
-		sys, err := provider.New(repo.Datastore(), opts...)
+		inner, err := provider.New(repo.Datastore(), opts...)
 		if err != nil {
 			return nil, err
 		}
+		sys := newSweepingProvider(inner, announces, keyProvider, repo.Datastore(), reprovideInterval, clk)
 
 		lc.Append(fx.Hook{
 			OnStop: func(ctx context.Context) error {
@@ -125,7 +134,7 @@ https://github.com/ipfs/kubo/blob/master/docs/config.md#routingaccelerateddhtcli
 // ONLINE/OFFLINE
 
 // OnlineProviders groups units managing provider routing records online
-func OnlineProviders(useStrategicProviding bool, reprovideStrategy string, reprovideInterval time.Duration, acceleratedDHTClient bool) fx.Option {
+func OnlineProviders(useStrategicProviding bool, reprovideStrategy string, filter *config.ReproviderFilter, reactiveTTL time.Duration, reprovideInterval time.Duration, acceleratedDHTClient bool) fx.Option {
 	if useStrategicProviding {
 		return OfflineProviders()
 	}
@@ -138,8 +147,22 @@ func OnlineProviders(useStrategicProviding bool, reprovideStrategy string, repro
 		keyProvider = fx.Provide(newProvidingStrategy(true, true))
 	case "pinned":
 		keyProvider = fx.Provide(newProvidingStrategy(true, false))
+	case "roots+entry-points":
+		keyProvider = fx.Provide(entryPointsStrategy)
 	case "flat":
-		keyProvider = fx.Provide(provider.NewBlockstoreProvider)
+		keyProvider = fx.Provide(func(bs blockstore.Blockstore) provider.KeyChanFunc {
+			return countKeys("blockstore", provider.NewBlockstoreProvider(bs))
+		})
+	case "custom":
+		if filter == nil {
+			return fx.Error(fmt.Errorf("reprovider strategy %q requires Reprovider.Filter", reprovideStrategy))
+		}
+		keyProvider = fx.Provide(newCustomProvidingStrategy(filter))
+	case "reactive":
+		if reactiveTTL <= 0 {
+			return fx.Error(fmt.Errorf("Reprovider.ReactiveTTL must be positive"))
+		}
+		keyProvider = ReactiveProviding(reactiveTTL)
 	default:
 		return fx.Error(fmt.Errorf("unknown reprovider strategy %q", reprovideStrategy))
 	}
@@ -155,25 +178,57 @@ func OfflineProviders() fx.Option {
 	return fx.Provide(provider.NewNoopProvider)
 }
 
+type providingStrategyIn struct {
+	fx.In
+	Pinner      pin.Pinner
+	Blockstore  blockstore.Blockstore
+	IPLDFetcher fetcher.Factory `name:"ipldFetcher"`
+}
+
 func newProvidingStrategy(onlyPinned, onlyRoots bool) interface{} {
-	type input struct {
-		fx.In
-		Pinner      pin.Pinner
-		Blockstore  blockstore.Blockstore
-		IPLDFetcher fetcher.Factory `name:"ipldFetcher"`
+	return func(in providingStrategyIn) provider.KeyChanFunc {
+		return providingStrategy(onlyPinned, onlyRoots, in)
 	}
-	return func(in input) provider.KeyChanFunc {
-		if onlyRoots {
-			return provider.NewPinnedProvider(true, in.Pinner, in.IPLDFetcher)
-		}
+}
 
-		if onlyPinned {
-			return provider.NewPinnedProvider(false, in.Pinner, in.IPLDFetcher)
-		}
+func providingStrategy(onlyPinned, onlyRoots bool, in providingStrategyIn) provider.KeyChanFunc {
+	if onlyRoots {
+		return countKeys("roots", provider.NewPinnedProvider(true, in.Pinner, in.IPLDFetcher))
+	}
 
-		return provider.NewPrioritizedProvider(
-			provider.NewPinnedProvider(true, in.Pinner, in.IPLDFetcher),
-			provider.NewBlockstoreProvider(in.Blockstore),
-		)
+	if onlyPinned {
+		return countKeys("pinned", provider.NewPinnedProvider(false, in.Pinner, in.IPLDFetcher))
+	}
+
+	return provider.NewPrioritizedProvider(
+		countKeys("roots", provider.NewPinnedProvider(true, in.Pinner, in.IPLDFetcher)),
+		countKeys("blockstore", provider.NewBlockstoreProvider(in.Blockstore)),
+	)
+}
+
+// newCustomProvidingStrategy filters the keys of the Reprovider.Filter.Base
+// strategy with the criteria of Reprovider.Filter.
+func newCustomProvidingStrategy(cfg *config.ReproviderFilter) interface{} {
+	return func(in providingStrategyIn) (provider.KeyChanFunc, error) {
+		f, err := newReproviderFilter(cfg, in.Blockstore)
+		if err != nil {
+			return nil, err
+		}
+		var base provider.KeyChanFunc
+		switch b := cfg.Base.WithDefault(config.DefaultReproviderFilterBase); b {
+		case "all", "":
+			base = providingStrategy(false, false, in)
+		case "roots":
+			base = providingStrategy(true, true, in)
+		case "pinned":
+			base = providingStrategy(true, false, in)
+		case "roots+entry-points":
+			base = entryPointsStrategy(in)
+		case "flat":
+			base = countKeys("blockstore", provider.NewBlockstoreProvider(in.Blockstore))
+		default:
+			return nil, fmt.Errorf("unknown Reprovider.Filter.Base %q", b)
+		}
+		return countKeys("custom", f.keyChanFunc(base)), nil
 	}
 }
@@ -0,0 +1,105 @@
+package node
+
+import (
+	"context"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	mc "github.com/multiformats/go-multicodec"
+)
+
+// entryPointsStrategy lists the keys of the "roots+entry-points" strategy: the
+// roots of the pins first, then the entry points of the recursive pins.
+func entryPointsStrategy(in providingStrategyIn) provider.KeyChanFunc {
+	return provider.NewPrioritizedProvider(
+		countKeys("roots", provider.NewPinnedProvider(true, in.Pinner, in.IPLDFetcher)),
+		countKeys("entry-points", newEntryPointsProvider(in.Pinner, in.Blockstore)),
+	)
+}
+
+// newEntryPointsProvider lists the keys of the "roots+entry-points" strategy
+// within the recursive pins: the directory nodes of their UnixFS DAGs,
+// including the HAMT shards, and the root block of each file, which are the
+// blocks looked up to start fetching a path. The other blocks of the files
+// are not listed. The DAGs are walked in the blockstore only.
+func newEntryPointsProvider(pinner pin.Pinner, bs blockstore.Blockstore) provider.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			visited := cid.NewSet()
+			for sc := range pinner.RecursiveKeys(ctx, false) {
+				if sc.Err != nil {
+					logger.Errorf("reprovide the entry points of recursive pins: %s", sc.Err)
+					return
+				}
+				if !walkEntryPoints(ctx, bs, sc.Pin.Key, visited, out) {
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// walkEntryPoints sends the entry points of the DAG of root to out, skipping
+// the visited ones. It returns false when ctx is done.
+func walkEntryPoints(ctx context.Context, bs blockstore.Blockstore, root cid.Cid, visited *cid.Set, out chan<- cid.Cid) bool {
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !visited.Visit(c) {
+			continue
+		}
+
+		links, err := entryPointLinks(ctx, bs, c)
+		if err != nil {
+			if !ipld.IsNotFound(err) {
+				logger.Debugf("reprovide the entry points of %s: %s", c, err)
+			}
+			continue
+		}
+		select {
+		case out <- c:
+		case <-ctx.Done():
+			return false
+		}
+		stack = append(stack, links...)
+	}
+	return true
+}
+
+// entryPointLinks returns the links of c to walk for entry points: the ones of
+// the UnixFS directories. The files, and the blocks which are not UnixFS, are
+// entry points without links.
+func entryPointLinks(ctx context.Context, bs blockstore.Blockstore, c cid.Cid) ([]cid.Cid, error) {
+	if mc.Code(c.Prefix().Codec) != mc.DagPb {
+		return nil, nil
+	}
+	blk, err := bs.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := merkledag.DecodeProtobufBlock(blk)
+	if err != nil {
+		return nil, err
+	}
+	fsNode, err := unixfs.ExtractFSNode(nd)
+	if err != nil {
+		return nil, nil
+	}
+	if !fsNode.IsDir() {
+		return nil, nil
+	}
+	links := make([]cid.Cid, 0, len(nd.Links()))
+	for _, l := range nd.Links() {
+		links = append(links, l.Cid)
+	}
+	return links, nil
+}
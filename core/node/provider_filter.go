@@ -0,0 +1,313 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+	mc "github.com/multiformats/go-multicodec"
+)
+
+// reproviderFilterBatch is the number of CIDs POSTed at once to
+// Reprovider.Filter.URL.
+const reproviderFilterBatch = 100
+
+// reproviderFilter selects the keys announced by the "custom" reprovider
+// strategy, see Reprovider.Filter.
+type reproviderFilter struct {
+	codecs           map[uint64]struct{}
+	prefixes         []string
+	minSize, maxSize uint64
+	command          []string
+	url              string
+	timeout          time.Duration
+
+	bs     blockstore.Blockstore
+	client *http.Client
+}
+
+func newReproviderFilter(cfg *config.ReproviderFilter, bs blockstore.Blockstore) (*reproviderFilter, error) {
+	f := &reproviderFilter{
+		prefixes: cfg.Prefixes,
+		command:  cfg.Command,
+		url:      cfg.URL.WithDefault(""),
+		timeout:  cfg.Timeout.WithDefault(config.DefaultReproviderFilterTimeout),
+		bs:       bs,
+		client:   &http.Client{},
+	}
+	if f.timeout <= 0 {
+		return nil, errors.New("invalid Reprovider.Filter.Timeout: must be positive")
+	}
+	if len(cfg.Codecs) > 0 {
+		f.codecs = make(map[uint64]struct{}, len(cfg.Codecs))
+		for _, name := range cfg.Codecs {
+			var code mc.Code
+			if err := code.Set(name); err != nil {
+				return nil, fmt.Errorf("invalid Reprovider.Filter.Codecs: %w", err)
+			}
+			f.codecs[uint64(code)] = struct{}{}
+		}
+	}
+	for _, p := range cfg.Prefixes {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid Reprovider.Filter.Prefixes %q: %w", p, err)
+		}
+	}
+	var err error
+	if f.minSize, err = parseBlockSize(cfg.MinSize, "Reprovider.Filter.MinSize"); err != nil {
+		return nil, err
+	}
+	if f.maxSize, err = parseBlockSize(cfg.MaxSize, "Reprovider.Filter.MaxSize"); err != nil {
+		return nil, err
+	}
+	if f.maxSize > 0 && f.minSize > f.maxSize {
+		return nil, errors.New("invalid Reprovider.Filter: MinSize is larger than MaxSize")
+	}
+	return f, nil
+}
+
+// keyChanFunc returns the keys of base matching the filter. When the command
+// or the URL of the filter fail, the remaining keys are not announced.
+func (f *reproviderFilter) keyChanFunc(base provider.KeyChanFunc) provider.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		in, err := base(ctx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		var judge *filterCommand
+		if len(f.command) > 0 {
+			if judge, err = startFilterCommand(ctx, f.command, f.timeout); err != nil {
+				cancel()
+				return nil, fmt.Errorf("starting Reprovider.Filter.Command: %w", err)
+			}
+		}
+
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			defer cancel()
+			if judge != nil {
+				defer judge.close()
+			}
+
+			send := func(c cid.Cid) bool {
+				select {
+				case out <- c:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+			var batch []cid.Cid
+			flush := func() bool {
+				if len(batch) == 0 {
+					return true
+				}
+				answers, err := f.ask(ctx, batch)
+				if err != nil {
+					logger.Errorf("Reprovider.Filter.URL: %s: the remaining keys are not announced", err)
+					return false
+				}
+				for i, c := range batch {
+					if answers[i] && !send(c) {
+						return false
+					}
+				}
+				batch = batch[:0]
+				return true
+			}
+
+			for c := range in {
+				ok, err := f.matches(ctx, c)
+				if err == nil && ok && judge != nil {
+					ok, err = judge.judge(c)
+				}
+				if err != nil {
+					logger.Errorf("Reprovider.Filter: %s: the remaining keys are not announced", err)
+					return
+				}
+				switch {
+				case !ok:
+				case f.url == "":
+					if !send(c) {
+						return
+					}
+				default:
+					batch = append(batch, c)
+					if len(batch) == reproviderFilterBatch && !flush() {
+						return
+					}
+				}
+			}
+			flush()
+		}()
+		return out, nil
+	}
+}
+
+// matches applies the criteria of the filter checked locally.
+func (f *reproviderFilter) matches(ctx context.Context, c cid.Cid) (bool, error) {
+	if f.codecs != nil {
+		if _, ok := f.codecs[c.Prefix().Codec]; !ok {
+			return false, nil
+		}
+	}
+	if len(f.prefixes) > 0 {
+		s := c.String()
+		matched := false
+		for _, p := range f.prefixes {
+			if ok, _ := path.Match(p, s); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if f.minSize > 0 || f.maxSize > 0 {
+		size, err := f.bs.GetSize(ctx, c)
+		if ipld.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if uint64(size) < f.minSize || (f.maxSize > 0 && uint64(size) > f.maxSize) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ask POSTs cids to the URL of the filter and returns its answers.
+func (f *reproviderFilter) ask(ctx context.Context, cids []cid.Cid) ([]bool, error) {
+	var body strings.Builder
+	for _, c := range cids {
+		body.WriteString(c.String())
+		body.WriteByte('\n')
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	answers := make([]bool, 0, len(cids))
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(answers) <= len(cids) {
+		yes, err := parseFilterAnswer(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, yes)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(answers) != len(cids) {
+		return nil, fmt.Errorf("got %d answers for %d CIDs", len(answers), len(cids))
+	}
+	return answers, nil
+}
+
+func parseFilterAnswer(line string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected answer %q, expected yes or no", line)
+	}
+}
+
+// filterCommand is a running Reprovider.Filter.Command.
+type filterCommand struct {
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	stdin   io.WriteCloser
+	answers chan string
+	timeout time.Duration
+}
+
+func startFilterCommand(ctx context.Context, command []string, timeout time.Duration) (*filterCommand, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	answers := make(chan string)
+	go func() {
+		defer close(answers)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case answers <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return &filterCommand{cmd: cmd, cancel: cancel, stdin: stdin, answers: answers, timeout: timeout}, nil
+}
+
+// judge writes c to the command and reads its answer.
+func (j *filterCommand) judge(c cid.Cid) (bool, error) {
+	if _, err := fmt.Fprintln(j.stdin, c); err != nil {
+		return false, fmt.Errorf("writing to Reprovider.Filter.Command: %w", err)
+	}
+	timer := time.NewTimer(j.timeout)
+	defer timer.Stop()
+	select {
+	case line, ok := <-j.answers:
+		if !ok {
+			return false, errors.New("Reprovider.Filter.Command exited")
+		}
+		return parseFilterAnswer(line)
+	case <-timer.C:
+		return false, errors.New("Reprovider.Filter.Command did not answer in time")
+	}
+}
+
+func (j *filterCommand) close() {
+	j.stdin.Close()
+	j.cancel()
+	_ = j.cmd.Wait()
+}
@@ -0,0 +1,147 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap/message"
+	"github.com/ipfs/boxo/bitswap/tracer"
+	"github.com/ipfs/boxo/blockstore"
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+)
+
+var reactiveProvideKey = datastore.NewKey("/local/provide/reactive")
+
+// reactiveQueueSize bounds the requests waiting to be checked against the
+// blockstore. Requests arriving while it is full are dropped: a key requested
+// often is requested again.
+const reactiveQueueSize = 1024
+
+// ReactiveProvider selects the keys of the "reactive" Reprovider.Strategy: a
+// block is announced the first time a peer asks for it over Bitswap, and is
+// reprovided until no peer asked for it within Reprovider.ReactiveTTL. The
+// blocks never asked for are never announced, which spares the announcements
+// of archives whose content is rarely read.
+type ReactiveProvider struct {
+	requested *ProvideList
+	bs        blockstore.Blockstore
+	ttl       time.Duration
+	sys       provider.System
+	wants     chan cid.Cid
+
+	mu sync.Mutex
+	// expires caches the expiry written for the keys requested since the
+	// node started, to skip the keys requested again shortly after.
+	expires map[cid.Cid]time.Time
+}
+
+type reactiveProviderOut struct {
+	fx.Out
+
+	Provider *ReactiveProvider
+	Tracer   tracer.Tracer `group:"bitswap-tracers"`
+}
+
+// ReactiveProviding provides the ReactiveProvider of the "reactive"
+// Reprovider.Strategy.
+func ReactiveProviding(ttl time.Duration) fx.Option {
+	return fx.Options(
+		fx.Provide(func(repo repo.Repo, bs blockstore.Blockstore) reactiveProviderOut {
+			r := &ReactiveProvider{
+				requested: &ProvideList{ds: repo.Datastore(), prefix: reactiveProvideKey},
+				bs:        bs,
+				ttl:       ttl,
+				wants:     make(chan cid.Cid, reactiveQueueSize),
+				expires:   make(map[cid.Cid]time.Time),
+			}
+			return reactiveProviderOut{Provider: r, Tracer: r}
+		}),
+		fx.Provide(func(r *ReactiveProvider) provider.KeyChanFunc {
+			return countKeys("reactive", r.requested.KeyChanFunc())
+		}),
+		// the provider system is set once built, as it is built with the
+		// keys of the ReactiveProvider
+		fx.Invoke(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, r *ReactiveProvider, sys provider.System) {
+			r.sys = sys
+			ctx := helpers.LifecycleCtx(mctx, lc)
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					go r.run(ctx)
+					return nil
+				},
+			})
+		}),
+	)
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (r *ReactiveProvider) MessageReceived(_ peer.ID, msg message.BitSwapMessage) {
+	for _, e := range msg.Wantlist() {
+		if e.Cancel {
+			continue
+		}
+		select {
+		case r.wants <- e.Cid:
+		default:
+		}
+	}
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (r *ReactiveProvider) MessageSent(peer.ID, message.BitSwapMessage) {}
+
+func (r *ReactiveProvider) run(ctx context.Context) {
+	for {
+		select {
+		case c := <-r.wants:
+			if err := r.request(ctx, c, time.Now()); err != nil && ctx.Err() == nil {
+				logger.Errorf("reactive reprovider: %s: %s", c, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// request records a request for c, and announces c when it is the first
+// request since the previous one expired.
+func (r *ReactiveProvider) request(ctx context.Context, c cid.Cid, now time.Time) error {
+	r.mu.Lock()
+	expires, ok := r.expires[c]
+	r.mu.Unlock()
+	// the expiry is only pushed back once a tenth of the TTL passed
+	if ok && expires.Sub(now) > r.ttl-r.ttl/10 {
+		return nil
+	}
+
+	has, err := r.bs.Has(ctx, c)
+	if err != nil || !has {
+		return err
+	}
+	if !ok {
+		_, ok, err = r.requested.expiry(ctx, c)
+		if err != nil {
+			return err
+		}
+	} else {
+		ok = expires.After(now)
+	}
+	e, err := r.requested.Add(ctx, c, r.ttl)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.expires[c] = e.Expires
+	r.mu.Unlock()
+	if ok {
+		return nil
+	}
+	return r.sys.Provide(c)
+}
@@ -0,0 +1,208 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore/query"
+	irouting "github.com/ipfs/kubo/routing"
+	kb "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
+)
+
+// provideQueuePrefix is where the provider system queues the new keys to
+// announce.
+var provideQueuePrefix = provider.DefaultKeyPrefix.ChildString("queue")
+
+// ProvideFailures counts the keys that could not be announced, by cause.
+type ProvideFailures struct {
+	// Timeout counts the announcements the routers did not complete in time.
+	Timeout uint64
+	// NoPeers counts the announcements without peers to send them to, such
+	// as while the routing table is empty.
+	NoPeers uint64
+	// Quota counts the announcements rejected by rate limits, such as the
+	// ones of delegated routers.
+	Quota uint64
+	Other uint64
+}
+
+// ProvideStats details the announcements of the provider system since the
+// node started.
+type ProvideStats struct {
+	// QueueDepth is the number of new keys waiting to be announced.
+	QueueDepth uint64
+	// Announced counts the keys announced, and Failures the ones that could
+	// not be, new keys and reprovides alike.
+	Announced uint64
+	Failures  ProvideFailures
+	// Keys counts the keys listed by each source of the reprovider strategy
+	// during the last sweep: provide-list, roots, pinned, blockstore and
+	// custom. A key listed by several sources counts in each of them.
+	Keys map[string]uint64 `json:",omitempty"`
+}
+
+// announceCounter counts the announcements made through a router, by
+// outcome.
+type announceCounter struct {
+	irouting.ProvideManyRouter
+
+	mu        sync.Mutex
+	announced uint64
+	failures  ProvideFailures
+}
+
+func newAnnounceCounter(r irouting.ProvideManyRouter) *announceCounter {
+	return &announceCounter{ProvideManyRouter: r}
+}
+
+func (r *announceCounter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	err := r.ProvideManyRouter.Provide(ctx, c, announce)
+	if announce {
+		r.count(ctx, 1, err)
+	}
+	return err
+}
+
+func (r *announceCounter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	err := r.ProvideManyRouter.ProvideMany(ctx, keys)
+	r.count(ctx, uint64(len(keys)), err)
+	return err
+}
+
+// Ready implements provider.Ready, so that announcements wait for the
+// routing table to be filled.
+func (r *announceCounter) Ready() bool {
+	if rr, ok := r.ProvideManyRouter.(provider.Ready); ok {
+		return rr.Ready()
+	}
+	return true
+}
+
+// Unwrap returns the wrapped router.
+func (r *announceCounter) Unwrap() routing.Routing {
+	return r.ProvideManyRouter
+}
+
+func (r *announceCounter) count(ctx context.Context, n uint64, err error) {
+	// announcements are cancelled when the node stops, they did not fail
+	if err != nil && ctx.Err() == context.Canceled {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		r.announced += n
+	case isQuotaError(err):
+		r.failures.Quota += n
+	case errors.Is(err, kb.ErrLookupFailure):
+		r.failures.NoPeers += n
+	case isTimeoutError(err):
+		r.failures.Timeout += n
+	default:
+		r.failures.Other += n
+	}
+}
+
+func isQuotaError(err error) bool {
+	s := strings.ToLower(err.Error())
+	return strings.Contains(s, "429") || strings.Contains(s, "too many requests") ||
+		strings.Contains(s, "rate limit") || strings.Contains(s, "quota")
+}
+
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "timeout")
+}
+
+type keyCountsKey struct{}
+
+// keyCounts counts the keys listed by each source of a sweep.
+type keyCounts struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func (k *keyCounts) add(source string) {
+	k.mu.Lock()
+	k.counts[source]++
+	k.mu.Unlock()
+}
+
+func (k *keyCounts) snapshot() map[string]uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	out := make(map[string]uint64, len(k.counts))
+	for s, n := range k.counts {
+		out[s] = n
+	}
+	return out
+}
+
+// countKeys counts the keys listed by f as the ones of source, when they are
+// listed for a sweep.
+func countKeys(source string, f provider.KeyChanFunc) provider.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		ch, err := f(ctx)
+		counts, ok := ctx.Value(keyCountsKey{}).(*keyCounts)
+		if err != nil || !ok {
+			return ch, err
+		}
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			for c := range ch {
+				counts.add(source)
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// ProvideStats returns the details of the announcements since the node
+// started.
+func (p *SweepingProvider) ProvideStats(ctx context.Context) (ProvideStats, error) {
+	res, err := p.ds.Query(ctx, query.Query{Prefix: provideQueuePrefix.String(), KeysOnly: true})
+	if err != nil {
+		return ProvideStats{}, err
+	}
+	defer res.Close()
+	var depth uint64
+	for e := range res.Next() {
+		if e.Error != nil {
+			return ProvideStats{}, e.Error
+		}
+		depth++
+	}
+
+	p.router.mu.Lock()
+	stats := ProvideStats{
+		QueueDepth: depth,
+		Announced:  p.router.announced,
+		Failures:   p.router.failures,
+	}
+	p.router.mu.Unlock()
+
+	p.mu.Lock()
+	stats.Keys = p.lastKeys
+	p.mu.Unlock()
+	return stats, nil
+}
@@ -0,0 +1,330 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	provider "github.com/ipfs/boxo/provider"
+	"github.com/ipfs/boxo/verifcid"
+	"github.com/ipfs/go-datastore"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	// sweepBatches is the number of batches a sweep is split into, spread
+	// over the reprovide interval.
+	sweepBatches = 1000
+	// sweepInitialDelay is how long the first sweep waits after startup, to
+	// not start announcing right before the node is stopped.
+	sweepInitialDelay = time.Minute
+)
+
+var lastSweepKey = datastore.NewKey("/provider/sweep/last")
+
+// SweepStat is the progress of the current or last reprovide sweep.
+type SweepStat struct {
+	// Running tells whether a sweep is in progress. Started is when the
+	// current or last sweep started, and NextSweep when the next one starts.
+	Running   bool
+	Started   time.Time
+	NextSweep time.Time
+	// Provided and Failed count the keys announced by the current or last
+	// sweep, and the keys that could not be. Remaining is estimated from the
+	// size of the last sweep.
+	Provided  uint64
+	Failed    uint64
+	Remaining uint64
+	// AvgAnnounceLatency is the average time taken to announce a key during
+	// the current or last sweep.
+	AvgAnnounceLatency time.Duration
+	// LastSweepKeys and LastSweepDuration describe the last complete sweep.
+	LastSweepKeys     uint64
+	LastSweepDuration time.Duration
+}
+
+// SweepingProvider reannounces the keys of the reprovider strategy evenly over
+// the reprovide interval, in small batches, instead of all at once. New keys
+// and forced reprovides are handled by the wrapped system.
+type SweepingProvider struct {
+	provider.System
+
+	router      *announceCounter
+	keyProvider provider.KeyChanFunc
+	ds          datastore.Datastore
+	interval    time.Duration
+	clock       clock.Clock
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	stat  SweepStat
+	total uint64
+	// announceTime is the time spent announcing the keys of the current
+	// sweep, and totalTime the one of all sweeps.
+	announceTime, totalTime time.Duration
+	// lastKeys counts the keys of each source of the last complete sweep.
+	lastKeys map[string]uint64
+}
+
+func newSweepingProvider(sys provider.System, router *announceCounter, keyProvider provider.KeyChanFunc, ds datastore.Datastore, interval time.Duration, clk clock.Clock) *SweepingProvider {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &SweepingProvider{
+		System:      sys,
+		router:      router,
+		keyProvider: keyProvider,
+		ds:          ds,
+		interval:    interval,
+		clock:       clk,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	if interval > 0 {
+		p.wg.Add(1)
+		go p.run()
+	}
+	return p
+}
+
+func (p *SweepingProvider) run() {
+	defer p.wg.Done()
+
+	// resume the schedule of the sweeps across restarts
+	next := p.clock.Now().Add(sweepInitialDelay)
+	if last, err := p.lastSweep(); err != nil {
+		logger.Errorf("reading last reprovide sweep: %s", err)
+	} else if resume := last.Add(p.interval); resume.After(next) {
+		next = resume
+	}
+
+	timer := p.clock.Timer(p.clock.Until(next))
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		p.stat.NextSweep = next
+		p.mu.Unlock()
+
+		select {
+		case <-timer.C:
+		case <-p.ctx.Done():
+			return
+		}
+
+		start := p.clock.Now()
+		if err := p.sweep(start); err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			logger.Errorf("reprovide sweep: %s", err)
+		}
+		// a sweep taking longer than the interval is followed right away
+		// by the next one
+		next = start.Add(p.interval)
+		timer.Reset(p.clock.Until(next))
+	}
+}
+
+// sweep announces every key once, the batch starting at the i-th key being
+// due at i/n of the interval, n being the number of keys of the last sweep.
+func (p *SweepingProvider) sweep(start time.Time) error {
+	p.mu.Lock()
+	estimate := p.stat.LastSweepKeys
+	p.mu.Unlock()
+	if estimate == 0 {
+		var err error
+		if estimate, err = p.countKeys(); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	p.stat.Running = true
+	p.stat.Started = start
+	p.stat.Provided, p.stat.Failed, p.stat.Remaining = 0, 0, estimate
+	p.stat.AvgAnnounceLatency = 0
+	p.announceTime = 0
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	counts := &keyCounts{counts: make(map[string]uint64)}
+	keys, err := p.keyProvider(context.WithValue(ctx, keyCountsKey{}, counts))
+	if err != nil {
+		return err
+	}
+
+	batchSize := max(1, (estimate+sweepBatches-1)/sweepBatches)
+	batch := make([]multihash.Multihash, 0, batchSize)
+	var first, seen uint64
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if estimate > 0 {
+			due := start.Add(time.Duration(float64(p.interval) * float64(first) / float64(estimate)))
+			if err := p.sleepUntil(ctx, due); err != nil {
+				return err
+			}
+		}
+		if err := p.announce(ctx, batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		first = seen
+		return nil
+	}
+	for c := range keys {
+		if err := verifcid.ValidateCid(verifcid.DefaultAllowlist, c); err != nil {
+			logger.Errorf("insecure hash in reprovider, %s (%s)", c, err)
+			continue
+		}
+		batch = append(batch, c.Hash())
+		seen++
+		if uint64(len(batch)) == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dur := p.clock.Since(start)
+	p.mu.Lock()
+	p.stat.Running = false
+	p.stat.Remaining = 0
+	p.stat.LastSweepKeys = seen
+	p.stat.LastSweepDuration = dur
+	p.lastKeys = counts.snapshot()
+	p.mu.Unlock()
+
+	if dur > p.interval {
+		logger.Errorf("reprovide sweep of %d keys took %s, longer than Reprovider.Interval (%s): content may not be found on the network. Consider enabling Routing.AcceleratedDHTClient", seen, dur.Truncate(time.Second), p.interval)
+	}
+	if err := p.ds.Put(p.ctx, lastSweepKey, []byte(strconv.FormatInt(start.UnixNano(), 10))); err != nil {
+		logger.Errorf("storing last reprovide sweep: %s", err)
+	}
+	return nil
+}
+
+// announce provides keys to the router once it is ready. Failed announcements
+// are counted and not retried before the next sweep.
+func (p *SweepingProvider) announce(ctx context.Context, keys []multihash.Multihash) error {
+	for !p.router.Ready() {
+		if err := p.sleepUntil(ctx, p.clock.Now().Add(time.Minute)); err != nil {
+			return err
+		}
+	}
+
+	start := p.clock.Now()
+	err := p.router.ProvideMany(ctx, keys)
+	dur := p.clock.Since(start)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := uint64(len(keys))
+	if p.stat.Remaining > n {
+		p.stat.Remaining -= n
+	} else {
+		p.stat.Remaining = 0
+	}
+	if err != nil {
+		logger.Debugf("reprovide sweep: providing %d keys failed: %s", n, err)
+		p.stat.Failed += n
+		return nil
+	}
+	p.stat.Provided += n
+	p.announceTime += dur
+	p.stat.AvgAnnounceLatency = p.announceTime / time.Duration(p.stat.Provided)
+	p.total += n
+	p.totalTime += dur
+	return nil
+}
+
+func (p *SweepingProvider) countKeys() (uint64, error) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	keys, err := p.keyProvider(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for range keys {
+		n++
+	}
+	return n, ctx.Err()
+}
+
+func (p *SweepingProvider) lastSweep() (time.Time, error) {
+	val, err := p.ds.Get(p.ctx, lastSweepKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ns, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ns), nil
+}
+
+// SweepStat returns the progress of the current or last sweep.
+func (p *SweepingProvider) SweepStat() SweepStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stat
+}
+
+// Stat implements provider.System, counting the keys announced by sweeps as
+// reprovides.
+func (p *SweepingProvider) Stat() (provider.ReproviderStats, error) {
+	stats, err := p.System.Stat()
+	if err != nil {
+		return stats, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if total := stats.TotalProvides + p.total; total > 0 {
+		stats.AvgProvideDuration = (time.Duration(stats.TotalProvides)*stats.AvgProvideDuration + p.totalTime) / time.Duration(total)
+		stats.TotalProvides = total
+	}
+	if p.stat.LastSweepKeys > 0 {
+		stats.LastReprovideBatchSize = p.stat.LastSweepKeys
+		stats.LastReprovideDuration = p.stat.LastSweepDuration
+	}
+	return stats, nil
+}
+
+func (p *SweepingProvider) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	return p.System.Close()
+}
+
+func (p *SweepingProvider) sleepUntil(ctx context.Context, t time.Time) error {
+	timer := p.clock.Timer(p.clock.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var _ provider.System = (*SweepingProvider)(nil)
@@ -0,0 +1,86 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	provider "github.com/ipfs/boxo/provider"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	irouting "github.com/ipfs/kubo/routing"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+type sweepRouter struct {
+	irouting.ProvideManyRouter
+
+	mu       sync.Mutex
+	provided []time.Time
+}
+
+func (r *sweepRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for range keys {
+		r.provided = append(r.provided, time.Now())
+	}
+	return nil
+}
+
+func TestSweepingProvider(t *testing.T) {
+	var cids []cid.Cid
+	for i := 0; i < 10; i++ {
+		cids = append(cids, blocks.NewBlock([]byte{byte(i)}).Cid())
+	}
+	keys := func(ctx context.Context) (<-chan cid.Cid, error) {
+		ch := make(chan cid.Cid, len(cids))
+		for _, c := range cids {
+			ch <- c
+		}
+		close(ch)
+		return ch, nil
+	}
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	inner, err := provider.New(ds)
+	require.NoError(t, err)
+	r := &sweepRouter{}
+	interval := 500 * time.Millisecond
+	p := newSweepingProvider(inner, newAnnounceCounter(r), countKeys("pinned", keys), ds, interval, clock.New())
+	defer p.Close()
+
+	start := time.Now()
+	require.NoError(t, p.sweep(start))
+
+	// the keys are spread over the interval instead of provided at once
+	require.Len(t, r.provided, len(cids))
+	require.Less(t, r.provided[0].Sub(start), interval/4)
+	require.Greater(t, r.provided[len(cids)-1].Sub(start), interval/2)
+
+	stat := p.SweepStat()
+	require.False(t, stat.Running)
+	require.EqualValues(t, len(cids), stat.Provided)
+	require.EqualValues(t, len(cids), stat.LastSweepKeys)
+	require.Zero(t, stat.Remaining)
+
+	stats, err := p.Stat()
+	require.NoError(t, err)
+	require.EqualValues(t, len(cids), stats.TotalProvides)
+	require.EqualValues(t, len(cids), stats.LastReprovideBatchSize)
+
+	ps, err := p.ProvideStats(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, len(cids), ps.Announced)
+	require.Equal(t, map[string]uint64{"pinned": uint64(len(cids))}, ps.Keys)
+	require.Zero(t, ps.QueueDepth)
+
+	last, err := p.lastSweep()
+	require.NoError(t, err)
+	require.Equal(t, start.UnixNano(), last.UnixNano())
+}
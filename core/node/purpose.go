@@ -0,0 +1,47 @@
+package node
+
+import "fmt"
+
+// Purposes of ipfs daemon --single-command-server, which only builds the
+// subsystems needed to serve one of them.
+const (
+	PurposeGatewayOnly   = "gateway-only"
+	PurposePinWorkerOnly = "pin-worker-only"
+)
+
+// prunedSubsystems lists the subsystems left out of the node for each
+// purpose. A gateway-only node announces nothing, so it does not build the
+// provider system, which walks the keys of Reprovider.Strategy. The gateway
+// of a pin-worker-only node is left out by the daemon, which does not serve
+// it.
+var prunedSubsystems = map[string][]string{
+	PurposeGatewayOnly: {
+		"reprovider", "ipnsrepublisher", "ipnsthirdparty", "probes", "pendingpins", "search", "dagindex",
+	},
+	PurposePinWorkerOnly: {
+		"ipnsrepublisher", "ipnsthirdparty", "probes", "search", "dagindex", "gatewayaccesslog",
+		"gatewaydenylists", "gatewaydirindex", "gatewaytls",
+	},
+}
+
+// Purposes returns the purposes of ipfs daemon --single-command-server.
+func Purposes() []string {
+	return []string{PurposeGatewayOnly, PurposePinWorkerOnly}
+}
+
+func checkPurpose(purpose string) error {
+	if _, ok := prunedSubsystems[purpose]; purpose != "" && !ok {
+		return fmt.Errorf("unknown purpose %q, expected one of %q", purpose, Purposes())
+	}
+	return nil
+}
+
+// runs tells whether the subsystem is built for the purpose of the node.
+func (cfg *BuildCfg) runs(subsystem string) bool {
+	for _, s := range prunedSubsystems[cfg.Purpose] {
+		if s == subsystem {
+			return false
+		}
+	}
+	return true
+}
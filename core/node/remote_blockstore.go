@@ -0,0 +1,146 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	blockstore "github.com/ipfs/boxo/blockstore"
+	exchange "github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/boxo/exchange/offline"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// remoteBlockstoreExchange serves local blocks, and reads the rest from an
+// HTTP blockstore.
+type remoteBlockstoreExchange struct {
+	exchange.Interface
+	client   *http.Client
+	url      string
+	method   string
+	headers  map[string]string
+	requests chan struct{}
+}
+
+// RemoteBlockstoreExchange is the exchange used when Bitswap.Enabled is false
+// and Bitswap.RemoteBlockstore is set. The blocks read from the remote
+// blockstore are verified against their CID, and stored locally by the
+// blockservice like blocks fetched over Bitswap.
+func RemoteBlockstoreExchange(cfg *config.BitswapRemoteBlockstore) interface{} {
+	return func(bs blockstore.Blockstore) (exchange.Interface, error) {
+		if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+			return nil, fmt.Errorf("invalid Bitswap.RemoteBlockstore.URL %q: must be an http or https URL", cfg.URL)
+		}
+		if !strings.Contains(cfg.URL, "{cid}") && !strings.Contains(cfg.URL, "{cidv1}") {
+			return nil, fmt.Errorf("invalid Bitswap.RemoteBlockstore.URL %q: must contain {cid} or {cidv1}", cfg.URL)
+		}
+		method := strings.ToUpper(cfg.Method.WithDefault(config.DefaultBitswapRemoteBlockstoreMethod))
+		if method != http.MethodGet && method != http.MethodPost {
+			return nil, fmt.Errorf("invalid Bitswap.RemoteBlockstore.Method %q: must be GET or POST", method)
+		}
+		maxRequests := cfg.MaxConcurrentRequests.WithDefault(config.DefaultBitswapRemoteBlockstoreMaxConcurrentRequests)
+		if maxRequests <= 0 {
+			return nil, errors.New("invalid Bitswap.RemoteBlockstore.MaxConcurrentRequests: must be positive")
+		}
+
+		return &remoteBlockstoreExchange{
+			Interface: offline.Exchange(bs),
+			client:    &http.Client{Timeout: cfg.Timeout.WithDefault(config.DefaultBitswapRemoteBlockstoreTimeout)},
+			url:       cfg.URL,
+			method:    method,
+			headers:   cfg.Headers,
+			requests:  make(chan struct{}, maxRequests),
+		}, nil
+	}
+}
+
+func (e *remoteBlockstoreExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := e.Interface.GetBlock(ctx, c)
+	if !ipld.IsNotFound(err) {
+		return blk, err
+	}
+	return e.fetch(ctx, c)
+}
+
+func (e *remoteBlockstoreExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		var wg sync.WaitGroup
+		for _, c := range cids {
+			wg.Add(1)
+			go func(c cid.Cid) {
+				defer wg.Done()
+				blk, err := e.GetBlock(ctx, c)
+				if err != nil {
+					logger.Debugf("remote blockstore: %s", err)
+					return
+				}
+				select {
+				case out <- blk:
+				case <-ctx.Done():
+				}
+			}(c)
+		}
+		wg.Wait()
+	}()
+	return out, nil
+}
+
+// fetch reads c from the remote blockstore.
+func (e *remoteBlockstoreExchange) fetch(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	select {
+	case e.requests <- struct{}{}:
+		defer func() { <-e.requests }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	url := strings.NewReplacer(
+		"{cid}", c.String(),
+		"{cidv1}", cid.NewCidV1(c.Type(), c.Hash()).String(),
+	).Replace(e.url)
+	req, err := http.NewRequestWithContext(ctx, e.method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from Bitswap.RemoteBlockstore: %w", c, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s is not available locally nor in Bitswap.RemoteBlockstore", ErrBlockExchangeDisabled, c)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("reading %s from Bitswap.RemoteBlockstore: %s", c, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, network.MessageSizeMax+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from Bitswap.RemoteBlockstore: %w", c, err)
+	}
+	if len(data) > network.MessageSizeMax {
+		return nil, fmt.Errorf("block %s from Bitswap.RemoteBlockstore is larger than %d bytes", c, network.MessageSizeMax)
+	}
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("block %s from Bitswap.RemoteBlockstore does not match its CID", c)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
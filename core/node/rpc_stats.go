@@ -0,0 +1,122 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// rpcLatencySamples is the number of latest calls of each RPC command whose
+// latency percentiles are reported.
+const rpcLatencySamples = 1000
+
+// RPCCommandBuckets are the upper bounds, in seconds, of the buckets of the
+// latency histogram of the RPC commands.
+var RPCCommandBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// RPCCommandStat is the usage of one RPC command since the daemon started.
+type RPCCommandStat struct {
+	// Command is the path of the command, such as "pin/add".
+	Command string
+	// Calls is the number of calls, Errors the number of them that failed.
+	Calls  uint64
+	Errors uint64
+	// P50, P90 and P99 are percentiles of the latency of the last calls.
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	// LatencyBuckets counts the calls per upper bound of RPCCommandBuckets,
+	// cumulatively, and LatencySum is their total latency, as in a
+	// Prometheus histogram.
+	LatencyBuckets []uint64
+	LatencySum     time.Duration
+	// Users counts the calls made with each API.Authorizations token.
+	Users map[string]uint64 `json:",omitempty"`
+}
+
+// RPCStats counts the calls of the RPC commands, for ipfs stats api and the
+// Prometheus metrics.
+type RPCStats struct {
+	mu       sync.Mutex
+	commands map[string]*rpcCommandStat
+}
+
+type rpcCommandStat struct {
+	RPCCommandStat
+	// latencies holds the latency of the last calls, next being the index
+	// of the oldest once it is full.
+	latencies []time.Duration
+	next      int
+}
+
+func NewRPCStats() *RPCStats {
+	return &RPCStats{commands: make(map[string]*rpcCommandStat)}
+}
+
+// Record counts a call of command, made with the API.Authorizations token of
+// user when it is not empty.
+func (s *RPCStats) Record(command, user string, latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.commands[command]
+	if !ok {
+		c = &rpcCommandStat{RPCCommandStat: RPCCommandStat{
+			Command:        command,
+			LatencyBuckets: make([]uint64, len(RPCCommandBuckets)),
+		}}
+		s.commands[command] = c
+	}
+
+	c.Calls++
+	if failed {
+		c.Errors++
+	}
+	if user != "" {
+		if c.Users == nil {
+			c.Users = make(map[string]uint64)
+		}
+		c.Users[user]++
+	}
+	c.LatencySum += latency
+	for i, bound := range RPCCommandBuckets {
+		if latency.Seconds() <= bound {
+			c.LatencyBuckets[i]++
+		}
+	}
+	if len(c.latencies) < rpcLatencySamples {
+		c.latencies = append(c.latencies, latency)
+	} else {
+		c.latencies[c.next] = latency
+		c.next = (c.next + 1) % rpcLatencySamples
+	}
+}
+
+// Stats returns the usage of every command called, the most called first.
+func (s *RPCStats) Stats() []RPCCommandStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RPCCommandStat, 0, len(s.commands))
+	for _, c := range s.commands {
+		stat := c.RPCCommandStat
+		stat.LatencyBuckets = append([]uint64(nil), c.LatencyBuckets...)
+		if c.Users != nil {
+			stat.Users = make(map[string]uint64, len(c.Users))
+			for u, n := range c.Users {
+				stat.Users[u] = n
+			}
+		}
+		latencies := append([]time.Duration(nil), c.latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stat.P50 = latencies[len(latencies)*50/100]
+		stat.P90 = latencies[len(latencies)*90/100]
+		stat.P99 = latencies[len(latencies)*99/100]
+		out = append(out, stat)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Calls != out[j].Calls {
+			return out[i].Calls > out[j].Calls
+		}
+		return out[i].Command < out[j].Command
+	})
+	return out
+}
@@ -0,0 +1,373 @@
+package node
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/repo"
+)
+
+// ErrRPCQuotaExceeded is returned when a request would exceed one of the
+// API.Authorizations quotas of its token.
+var ErrRPCQuotaExceeded = errors.New("RPC quota exceeded")
+
+var rpcQuotaPinsKey = datastore.NewKey("/local/rpcquota/pins")
+
+type rpcUserCtxKey struct{}
+
+// WithRPCUser returns a context carrying the name of the API.Authorizations
+// entry an RPC request was authenticated with.
+func WithRPCUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, rpcUserCtxKey{}, user)
+}
+
+// RPCUserFromContext returns the API.Authorizations entry set by WithRPCUser.
+func RPCUserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(rpcUserCtxKey{}).(string)
+	return user, ok
+}
+
+// RPCQuotaUsage reports the limits and current usage of one token.
+type RPCQuotaUsage struct {
+	User              string
+	RequestsPerSecond int64
+	Rejected          uint64
+	ActiveAdds        int64
+	MaxConcurrentAdds int64
+	Pins              int64
+	MaxPins           int64
+	PinnedBytes       uint64
+	MaxPinnedBytes    uint64
+}
+
+// RPCQuotaTracker enforces API.Authorizations quotas. Pins are attributed to
+// the token that created them and the attribution is persisted in the repo.
+type RPCQuotaTracker struct {
+	ds     datastore.Datastore
+	pinner pin.Pinner
+	bs     blockstore.Blockstore
+
+	users map[string]*rpcQuotaUser
+}
+
+type rpcQuotaUser struct {
+	name           string
+	rps            int64
+	maxAdds        int64
+	maxPins        int64
+	maxPinnedBytes uint64
+
+	mu         sync.Mutex
+	tokens     float64
+	last       time.Time
+	activeAdds int64
+	rejected   uint64
+
+	// pinMu serializes pin accounting so concurrent pins cannot both fit
+	// into the remaining quota.
+	pinMu sync.Mutex
+}
+
+// RPCQuotas creates the RPCQuotaTracker for the quotas in API.Authorizations.
+func RPCQuotas(cfg *config.Config, repo repo.Repo, pinner pin.Pinner, bs blockstore.Blockstore) (*RPCQuotaTracker, error) {
+	q := &RPCQuotaTracker{
+		ds:     repo.Datastore(),
+		pinner: pinner,
+		bs:     bs,
+		users:  make(map[string]*rpcQuotaUser),
+	}
+	for name, scope := range cfg.API.Authorizations {
+		if scope == nil || scope.Quotas == nil {
+			continue
+		}
+		quotas := scope.Quotas
+		u := &rpcQuotaUser{
+			name:    name,
+			rps:     quotas.RequestsPerSecond.WithDefault(0),
+			maxAdds: quotas.MaxConcurrentAdds.WithDefault(0),
+			maxPins: quotas.MaxPins.WithDefault(0),
+		}
+		if s := quotas.MaxPinnedBytes.WithDefault(""); s != "" {
+			b, err := humanize.ParseBytes(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid API.Authorizations.%s.Quotas.MaxPinnedBytes: %w", name, err)
+			}
+			u.maxPinnedBytes = b
+		}
+		if u.rps < 0 || u.maxAdds < 0 || u.maxPins < 0 {
+			return nil, fmt.Errorf("API.Authorizations.%s.Quotas must not be negative", name)
+		}
+		u.tokens = float64(u.rps)
+		q.users[name] = u
+	}
+	return q, nil
+}
+
+// AllowRequest consumes one request from the rate limit of user.
+func (q *RPCQuotaTracker) AllowRequest(user string) bool {
+	u, ok := q.users[user]
+	if !ok || u.rps == 0 {
+		return true
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	if !u.last.IsZero() {
+		u.tokens += now.Sub(u.last).Seconds() * float64(u.rps)
+		if u.tokens > float64(u.rps) {
+			u.tokens = float64(u.rps)
+		}
+	}
+	u.last = now
+	if u.tokens < 1 {
+		u.rejected++
+		return false
+	}
+	u.tokens--
+	return true
+}
+
+// AcquireAdd reserves one of the concurrent add slots of user. The returned
+// function releases it.
+func (q *RPCQuotaTracker) AcquireAdd(user string) (func(), bool) {
+	u, ok := q.users[user]
+	if !ok || u.maxAdds == 0 {
+		return func() {}, true
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.activeAdds >= u.maxAdds {
+		u.rejected++
+		return nil, false
+	}
+	u.activeAdds++
+	return func() {
+		u.mu.Lock()
+		u.activeAdds--
+		u.mu.Unlock()
+	}, true
+}
+
+// HasPinQuota reports whether pin accounting applies to user.
+func (q *RPCQuotaTracker) HasPinQuota(user string) bool {
+	u, ok := q.users[user]
+	return ok && (u.maxPins > 0 || u.maxPinnedBytes > 0)
+}
+
+// PinQuotaUser returns the user of the RPC request of ctx when its pins are
+// counted against a quota.
+func (q *RPCQuotaTracker) PinQuotaUser(ctx context.Context) (string, bool) {
+	user, ok := RPCUserFromContext(ctx)
+	return user, ok && q.HasPinQuota(user)
+}
+
+// ChargePins attributes the given roots to user. The DAGs must be available
+// locally. If pinFn is not nil it is called to create the pins once they are
+// known to fit. If the roots would exceed the pin quotas of user nothing is
+// attributed and ErrRPCQuotaExceeded is returned.
+func (q *RPCQuotaTracker) ChargePins(ctx context.Context, user string, roots []cid.Cid, pinFn func(context.Context) error) error {
+	return q.charge(ctx, user, roots, cid.Undef, pinFn)
+}
+
+// ChargePinUpdate is ChargePins for the replacement of the pin of from by the
+// one of to, as done by 'ipfs pin update'. With unpin, the attribution of
+// from to user, if any, is released by the update.
+func (q *RPCQuotaTracker) ChargePinUpdate(ctx context.Context, user string, from, to cid.Cid, unpin bool, pinFn func(context.Context) error) error {
+	released := cid.Undef
+	if unpin && !from.Equals(to) {
+		released = from
+	}
+	return q.charge(ctx, user, []cid.Cid{to}, released, pinFn)
+}
+
+func (q *RPCQuotaTracker) charge(ctx context.Context, user string, roots []cid.Cid, released cid.Cid, pinFn func(context.Context) error) error {
+	u, ok := q.users[user]
+	if !ok || (u.maxPins == 0 && u.maxPinnedBytes == 0) {
+		if pinFn != nil {
+			return pinFn(ctx)
+		}
+		return nil
+	}
+
+	u.pinMu.Lock()
+	defer u.pinMu.Unlock()
+
+	charged, err := q.pins(ctx, u.name)
+	if err != nil {
+		return err
+	}
+	_, release := charged[released]
+	delete(charged, released)
+	var pinnedBytes uint64
+	for _, size := range charged {
+		pinnedBytes += size
+	}
+
+	pins := int64(len(charged))
+	added := make(map[cid.Cid]uint64)
+	for _, c := range roots {
+		if _, ok := charged[c]; ok {
+			continue
+		}
+		if _, ok := added[c]; ok {
+			continue
+		}
+		size, err := q.dagSize(ctx, c)
+		if err != nil {
+			return err
+		}
+		added[c] = size
+		pins++
+		pinnedBytes += size
+	}
+
+	if u.maxPins > 0 && pins > u.maxPins {
+		q.reject(u)
+		return fmt.Errorf("%w: token %q may have at most %d pins", ErrRPCQuotaExceeded, u.name, u.maxPins)
+	}
+	if u.maxPinnedBytes > 0 && pinnedBytes > u.maxPinnedBytes {
+		q.reject(u)
+		return fmt.Errorf("%w: token %q may pin at most %s", ErrRPCQuotaExceeded, u.name, humanize.IBytes(u.maxPinnedBytes))
+	}
+
+	if pinFn != nil {
+		if err := pinFn(ctx); err != nil {
+			return err
+		}
+	}
+	for c, size := range added {
+		if err := q.ds.Put(ctx, pinQuotaKey(u.name, c), binary.AppendUvarint(nil, size)); err != nil {
+			return err
+		}
+	}
+	if release {
+		return q.ds.Delete(ctx, pinQuotaKey(u.name, released))
+	}
+	return nil
+}
+
+func (q *RPCQuotaTracker) reject(u *rpcQuotaUser) {
+	u.mu.Lock()
+	u.rejected++
+	u.mu.Unlock()
+}
+
+// pins returns the roots attributed to user and their sizes. Attributions of
+// roots that are no longer pinned are dropped.
+func (q *RPCQuotaTracker) pins(ctx context.Context, user string) (map[cid.Cid]uint64, error) {
+	prefix := rpcQuotaPinsKey.ChildString(url.PathEscape(user))
+	results, err := q.ds.Query(ctx, query.Query{Prefix: prefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make(map[cid.Cid]uint64, len(entries))
+	for _, e := range entries {
+		k := datastore.NewKey(e.Key)
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		mode, pinned, err := q.pinner.IsPinned(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if !pinned || mode != "recursive" {
+			if err := q.ds.Delete(ctx, k); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		size, _ := binary.Uvarint(e.Value)
+		pins[c] = size
+	}
+	return pins, nil
+}
+
+// dagSize returns the size of the unique blocks of the DAG under c, using
+// only the local blockstore.
+func (q *RPCQuotaTracker) dagSize(ctx context.Context, c cid.Cid) (uint64, error) {
+	dag := merkledag.NewDAGService(blockservice.New(q.bs, offline.Exchange(q.bs)))
+
+	var size uint64
+	var sizeErr error
+	err := merkledag.Walk(ctx, merkledag.GetLinksWithDAG(dag), c, func(c cid.Cid) bool {
+		if sizeErr != nil {
+			return false
+		}
+		s, err := q.bs.GetSize(ctx, c)
+		if err != nil {
+			sizeErr = err
+			return false
+		}
+		size += uint64(s)
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, sizeErr
+}
+
+func pinQuotaKey(user string, c cid.Cid) datastore.Key {
+	return rpcQuotaPinsKey.ChildString(url.PathEscape(user)).ChildString(c.String())
+}
+
+// Usage reports the limits and usage of every token with quotas.
+func (q *RPCQuotaTracker) Usage(ctx context.Context) ([]RPCQuotaUsage, error) {
+	usage := make([]RPCQuotaUsage, 0, len(q.users))
+	for _, u := range q.users {
+		u.pinMu.Lock()
+		pins, err := q.pins(ctx, u.name)
+		u.pinMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		var pinnedBytes uint64
+		for _, size := range pins {
+			pinnedBytes += size
+		}
+
+		u.mu.Lock()
+		usage = append(usage, RPCQuotaUsage{
+			User:              u.name,
+			RequestsPerSecond: u.rps,
+			Rejected:          u.rejected,
+			ActiveAdds:        u.activeAdds,
+			MaxConcurrentAdds: u.maxAdds,
+			Pins:              int64(len(pins)),
+			MaxPins:           u.maxPins,
+			PinnedBytes:       pinnedBytes,
+			MaxPinnedBytes:    u.maxPinnedBytes,
+		})
+		u.mu.Unlock()
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].User < usage[j].User })
+	return usage, nil
+}
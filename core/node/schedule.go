@@ -0,0 +1,175 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"go.uber.org/fx"
+)
+
+// ScheduledTaskStatus is the state of one Schedule.Tasks entry.
+type ScheduledTaskStatus struct {
+	Name     string
+	Command  []string
+	Interval time.Duration
+	Running  bool
+	// Runs is the number of completed runs, Skipped the number of runs that
+	// were not started because the previous one was still running.
+	Runs         uint64
+	Skipped      uint64
+	NextRun      time.Time
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+}
+
+// TaskRunFunc runs the command of a scheduled task.
+type TaskRunFunc func(ctx context.Context, command []string) error
+
+// TaskScheduler runs the Schedule.Tasks of the daemon. Tasks only run once
+// Start was called with a way to execute commands.
+type TaskScheduler struct {
+	ctx   context.Context
+	clock clock.Clock
+
+	mu      sync.Mutex
+	tasks   []*scheduledTask
+	started bool
+}
+
+type scheduledTask struct {
+	at     time.Time // zero unless Schedule.Tasks[].At is set
+	status ScheduledTaskStatus
+}
+
+// Scheduler validates Schedule.Tasks and returns the scheduler running them.
+func Scheduler(mctx helpers.MetricsCtx, lc fx.Lifecycle, cfg *config.Config, clk clock.Clock) (*TaskScheduler, error) {
+	s := &TaskScheduler{ctx: helpers.LifecycleCtx(mctx, lc), clock: clk}
+	names := make(map[string]struct{})
+	for i, t := range cfg.Schedule.Tasks {
+		if t.Name == "" {
+			return nil, fmt.Errorf("Schedule.Tasks[%d]: missing Name", i)
+		}
+		if _, ok := names[t.Name]; ok {
+			return nil, fmt.Errorf("Schedule.Tasks[%d]: duplicate Name %q", i, t.Name)
+		}
+		names[t.Name] = struct{}{}
+		if len(t.Command) == 0 {
+			return nil, fmt.Errorf("Schedule.Tasks[%d] %q: missing Command", i, t.Name)
+		}
+		interval := t.Interval.WithDefault(config.DefaultScheduleTaskInterval)
+		if interval <= 0 {
+			return nil, fmt.Errorf("Schedule.Tasks[%d] %q: Interval must be positive", i, t.Name)
+		}
+
+		task := &scheduledTask{status: ScheduledTaskStatus{
+			Name:     t.Name,
+			Command:  t.Command,
+			Interval: interval,
+		}}
+		if at := t.At.WithDefault(""); at != "" {
+			var err error
+			task.at, err = time.Parse("15:04", at)
+			if err != nil {
+				return nil, fmt.Errorf("Schedule.Tasks[%d] %q: invalid At %q, expected HH:MM", i, t.Name, at)
+			}
+		}
+		s.tasks = append(s.tasks, task)
+	}
+	return s, nil
+}
+
+// Start schedules the tasks, executing their command with run.
+func (s *TaskScheduler) Start(run TaskRunFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("scheduler already started")
+	}
+	s.started = true
+
+	now := s.clock.Now()
+	for _, t := range s.tasks {
+		t.status.NextRun = t.firstRun(now)
+		go s.loop(t, run)
+	}
+	return nil
+}
+
+func (t *scheduledTask) firstRun(now time.Time) time.Time {
+	if t.at.IsZero() {
+		return now.Add(t.status.Interval)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.at.Hour(), t.at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func (s *TaskScheduler) loop(t *scheduledTask, run TaskRunFunc) {
+	for {
+		s.mu.Lock()
+		next := t.status.NextRun
+		s.mu.Unlock()
+
+		timer := s.clock.Timer(s.clock.Until(next))
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		}
+		s.runOnce(t, run)
+	}
+}
+
+// runOnce runs the task to completion. Runs that came due in the meantime are
+// skipped rather than started concurrently.
+func (s *TaskScheduler) runOnce(t *scheduledTask, run TaskRunFunc) {
+	start := s.clock.Now()
+	s.mu.Lock()
+	t.status.Running = true
+	t.status.LastRun = start
+	s.mu.Unlock()
+
+	logger.Infof("running scheduled task %q", t.status.Name)
+	err := run(s.ctx, t.status.Command)
+	if err != nil && s.ctx.Err() == nil {
+		logger.Errorf("scheduled task %q failed: %s", t.status.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	t.status.Running = false
+	t.status.Runs++
+	t.status.LastDuration = now.Sub(start)
+	t.status.LastError = ""
+	if err != nil {
+		t.status.LastError = err.Error()
+	}
+	t.status.NextRun = t.status.NextRun.Add(t.status.Interval)
+	for !t.status.NextRun.After(now) {
+		t.status.NextRun = t.status.NextRun.Add(t.status.Interval)
+		t.status.Skipped++
+	}
+}
+
+// Status returns the state of every scheduled task, in configuration order.
+// NextRun is zero when the scheduler was not started.
+func (s *TaskScheduler) Status() []ScheduledTaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := make([]ScheduledTaskStatus, len(s.tasks))
+	for i, t := range s.tasks {
+		st[i] = t.status
+	}
+	return st
+}
@@ -0,0 +1,390 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	offline "github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	uio "github.com/ipfs/boxo/ipld/unixfs/io"
+	pin "github.com/ipfs/boxo/pinning/pinner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+	"go.uber.org/fx"
+)
+
+var (
+	searchDirsKey  = datastore.NewKey("/local/search/dirs")
+	searchRootsKey = datastore.NewKey("/local/search/roots")
+	filesRootKey   = datastore.NewKey("/local/filesroot")
+)
+
+// ErrNotIndexed is returned when searching in a directory outside of the
+// pinned and MFS content.
+var ErrNotIndexed = errors.New("not in the search index: only the directories pinned recursively or in MFS are indexed")
+
+// SearchResult is a file or directory whose name matches a search.
+type SearchResult struct {
+	// Path is the path of the entry under the root searched, or its MFS
+	// path when MFS is set.
+	Path string
+	Cid  cid.Cid
+	Dir  bool `json:",omitempty"`
+	MFS  bool `json:",omitempty"`
+}
+
+// searchDir is the index of a directory, shared by all the roots it is
+// reachable from: Refs counts the roots and the parent directory entries
+// referencing it.
+type searchDir struct {
+	Refs    int
+	Entries []searchEntry
+}
+
+type searchEntry struct {
+	Name string
+	Cid  cid.Cid
+	Dir  bool `json:",omitempty"`
+}
+
+// searchRoot is an indexed root, pinned recursively or the root of MFS.
+type searchRoot struct {
+	Pinned bool `json:",omitempty"`
+	MFS    bool `json:",omitempty"`
+}
+
+// SearchIndex indexes the names of the entries of the UnixFS directories
+// pinned recursively or in MFS, for ipfs search. The directories are indexed
+// by CID, so that a change of the pins or of MFS only indexes the directories
+// that were not indexed yet, and releases those that are no longer
+// referenced. Only the blocks in the blockstore are read.
+type SearchIndex struct {
+	ctx    context.Context
+	ds     datastore.Datastore
+	dag    ipld.DAGService
+	pinner pin.Pinner
+
+	trigger chan struct{}
+	ready   chan struct{}
+}
+
+// SearchIndexing provides the SearchIndex when Search.Enabled is set.
+func SearchIndexing(cfg config.Search) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultSearchEnabled) {
+		return fx.Options()
+	}
+	return fx.Options(
+		fx.Provide(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, bs blockstore.Blockstore) *SearchIndex {
+			return &SearchIndex{
+				ctx:     helpers.LifecycleCtx(mctx, lc),
+				ds:      repo.Datastore(),
+				dag:     merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+				trigger: make(chan struct{}, 1),
+				ready:   make(chan struct{}),
+			}
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, x *SearchIndex, p pin.Pinner, changes *ContentChanges) {
+			x.pinner = p
+			changes.Notify(x.trigger)
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					go x.loop()
+					return nil
+				},
+			})
+		}),
+	)
+}
+
+func (x *SearchIndex) loop() {
+	for {
+		if err := x.update(); err != nil && x.ctx.Err() == nil {
+			logger.Errorf("Search: updating the index: %s", err)
+		}
+		select {
+		case <-x.ready:
+		default:
+			close(x.ready)
+		}
+		select {
+		case <-x.trigger:
+		case <-x.ctx.Done():
+			return
+		}
+	}
+}
+
+// update indexes the new recursive pins and MFS root, and releases the roots
+// that are gone.
+func (x *SearchIndex) update() error {
+	ctx := x.ctx
+	want := make(map[cid.Cid]searchRoot)
+	for p := range x.pinner.RecursiveKeys(ctx, false) {
+		if p.Err != nil {
+			return p.Err
+		}
+		want[p.Pin.Key] = searchRoot{Pinned: true}
+	}
+	val, err := x.ds.Get(ctx, filesRootKey)
+	switch {
+	case err == nil:
+		c, err := cid.Cast(val)
+		if err != nil {
+			return err
+		}
+		r := want[c]
+		r.MFS = true
+		want[c] = r
+	case !errors.Is(err, datastore.ErrNotFound):
+		return err
+	}
+
+	have, err := x.roots(ctx)
+	if err != nil {
+		return err
+	}
+	for c, r := range want {
+		if old, ok := have[c]; ok {
+			if old != r {
+				if err := x.putJSON(ctx, searchRootsKey.ChildString(c.String()), r); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := x.retain(ctx, c); err != nil {
+			logger.Warnf("Search: indexing %s: %s", c, err)
+			continue
+		}
+		if err := x.putJSON(ctx, searchRootsKey.ChildString(c.String()), r); err != nil {
+			return err
+		}
+	}
+	for c := range have {
+		if _, ok := want[c]; ok {
+			continue
+		}
+		if err := x.ds.Delete(ctx, searchRootsKey.ChildString(c.String())); err != nil {
+			return err
+		}
+		if err := x.release(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *SearchIndex) roots(ctx context.Context) (map[cid.Cid]searchRoot, error) {
+	res, err := x.ds.Query(ctx, query.Query{Prefix: searchRootsKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	roots := make(map[cid.Cid]searchRoot)
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		c, err := cid.Decode(datastore.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		var r searchRoot
+		if err := json.Unmarshal(e.Value, &r); err != nil {
+			return nil, err
+		}
+		roots[c] = r
+	}
+	return roots, nil
+}
+
+// retain adds a reference to the index of the directory c, indexing it and
+// its subdirectories when it is not indexed yet. Files are not indexed.
+func (x *SearchIndex) retain(ctx context.Context, c cid.Cid) error {
+	key := searchDirsKey.ChildString(c.String())
+	var dir searchDir
+	err := x.getJSON(ctx, key, &dir)
+	switch {
+	case err == nil:
+		dir.Refs++
+		return x.putJSON(ctx, key, &dir)
+	case !errors.Is(err, datastore.ErrNotFound):
+		return err
+	}
+
+	nd, err := x.dag.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	if !isUnixFSDir(nd) {
+		return nil
+	}
+	d, err := uio.NewDirectoryFromNode(x.dag, nd)
+	if err != nil {
+		return err
+	}
+	err = d.ForEachLink(ctx, func(l *ipld.Link) error {
+		e := searchEntry{Name: l.Name, Cid: l.Cid}
+		// the entries that are not in the blockstore are indexed as files
+		if child, err := x.dag.Get(ctx, l.Cid); err == nil && isUnixFSDir(child) {
+			if err := x.retain(ctx, l.Cid); err != nil {
+				return err
+			}
+			e.Dir = true
+		}
+		dir.Entries = append(dir.Entries, e)
+		return nil
+	})
+	if err != nil {
+		// release the subdirectories retained so far
+		for _, e := range dir.Entries {
+			if e.Dir {
+				if err := x.release(ctx, e.Cid); err != nil {
+					logger.Warnf("Search: releasing %s: %s", e.Cid, err)
+				}
+			}
+		}
+		return fmt.Errorf("listing %s: %w", c, err)
+	}
+	dir.Refs = 1
+	return x.putJSON(ctx, key, &dir)
+}
+
+// release removes a reference to the index of the directory c, and removes
+// it from the index when it was the last one.
+func (x *SearchIndex) release(ctx context.Context, c cid.Cid) error {
+	key := searchDirsKey.ChildString(c.String())
+	var dir searchDir
+	if err := x.getJSON(ctx, key, &dir); err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if dir.Refs--; dir.Refs > 0 {
+		return x.putJSON(ctx, key, &dir)
+	}
+	if err := x.ds.Delete(ctx, key); err != nil {
+		return err
+	}
+	for _, e := range dir.Entries {
+		if e.Dir {
+			if err := x.release(ctx, e.Cid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Search returns the entries whose name matches pattern, a glob as accepted
+// by path.Match, under the indexed root or subdirectory root, with paths
+// under prefix. When the pattern has a slash, it is matched against the
+// whole path under root instead. Without root, all the indexed roots are
+// searched.
+func (x *SearchIndex) Search(ctx context.Context, pattern string, root cid.Cid, prefix string, found func(SearchResult) error) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	select {
+	case <-x.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if root.Defined() {
+		return x.search(ctx, pattern, root, prefix, "", false, found, true)
+	}
+	roots, err := x.roots(ctx)
+	if err != nil {
+		return err
+	}
+	for c, r := range roots {
+		if r.Pinned {
+			if err := x.search(ctx, pattern, c, "/ipfs/"+c.String(), "", false, found, false); err != nil {
+				return err
+			}
+		}
+		if r.MFS {
+			if err := x.search(ctx, pattern, c, "", "", true, found, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (x *SearchIndex) search(ctx context.Context, pattern string, c cid.Cid, prefix, rel string, mfs bool, found func(SearchResult) error, mustExist bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var dir searchDir
+	if err := x.getJSON(ctx, searchDirsKey.ChildString(c.String()), &dir); err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			if mustExist {
+				return fmt.Errorf("%s is %w", c, ErrNotIndexed)
+			}
+			// a file, or a directory being indexed or released
+			return nil
+		}
+		return err
+	}
+	for _, e := range dir.Entries {
+		p := rel + "/" + e.Name
+		subject := e.Name
+		if strings.Contains(pattern, "/") {
+			subject = strings.TrimPrefix(p, "/")
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			if err := found(SearchResult{Path: prefix + p, Cid: e.Cid, Dir: e.Dir, MFS: mfs}); err != nil {
+				return err
+			}
+		}
+		if e.Dir {
+			if err := x.search(ctx, pattern, e.Cid, prefix, p, mfs, found, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (x *SearchIndex) getJSON(ctx context.Context, key datastore.Key, v any) error {
+	val, err := x.ds.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(val, v)
+}
+
+func (x *SearchIndex) putJSON(ctx context.Context, key datastore.Key, v any) error {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return x.ds.Put(ctx, key, val)
+}
+
+func isUnixFSDir(nd ipld.Node) bool {
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		return false
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return false
+	}
+	return fsn.Type() == ft.TDirectory || fsn.Type() == ft.THAMTShard
+}
@@ -0,0 +1,416 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	exchange "github.com/ipfs/boxo/exchange"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	irouting "github.com/ipfs/kubo/routing"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
+	"go.uber.org/fx"
+)
+
+// The kinds of operations recorded by the SlowLog.
+const (
+	SlowOpAPI        = "api"
+	SlowOpGateway    = "gateway"
+	SlowOpBlockFetch = "blockfetch"
+	SlowOpRouting    = "routing"
+)
+
+// slowOpLabel prefixes the pprof labels identifying the goroutines of the
+// operations of each kind in the goroutine profile, so that the goroutines of
+// an operation made by another keep the label of both.
+const slowOpLabel = "slowop."
+
+// SlowOperation is an operation that took longer than its threshold.
+type SlowOperation struct {
+	Started   time.Time
+	Kind      string
+	Operation string
+	Duration  time.Duration
+	Error     string `json:",omitempty"`
+	// Stacks are the stacks of the goroutines of the operation, captured
+	// when it reached its threshold, in the format of the goroutine profile.
+	Stacks string `json:",omitempty"`
+}
+
+// SlowLog keeps the most recent operations that took longer than the
+// threshold of their kind, see Logging.SlowLog.
+type SlowLog struct {
+	thresholds map[string]time.Duration
+	sampleRate int64
+	lastID     atomic.Uint64
+	reached    atomic.Int64
+
+	mu      sync.Mutex
+	entries []SlowOperation
+	next    int
+	full    bool
+}
+
+func newSlowLog(thresholds map[string]time.Duration, maxEntries int, sampleRate int64) *SlowLog {
+	return &SlowLog{
+		thresholds: thresholds,
+		sampleRate: sampleRate,
+		entries:    make([]SlowOperation, maxEntries),
+	}
+}
+
+// Operations returns the slow operations that started after since, oldest
+// first.
+func (l *SlowLog) Operations(since time.Time) []SlowOperation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var ops []SlowOperation
+	if l.full {
+		ops = append(ops, l.entries[l.next:]...)
+	}
+	ops = append(ops, l.entries[:l.next]...)
+
+	recent := ops[:0]
+	for _, op := range ops {
+		if !op.Started.Before(since) {
+			recent = append(recent, op)
+		}
+	}
+	return recent
+}
+
+func (l *SlowLog) add(op SlowOperation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = op
+	l.next++
+	if l.next == len(l.entries) {
+		l.next = 0
+		l.full = true
+	}
+}
+
+// Begin starts timing an operation of kind made by the calling goroutine
+// with ctx. Until Release is called, the goroutine, and the goroutines it
+// starts, are labeled so that their stacks can be captured when the
+// operation reaches its threshold. It returns ctx and a nil operation when
+// the operations of kind are not recorded.
+func (l *SlowLog) Begin(ctx context.Context, kind, name string) (context.Context, *SlowOp) {
+	if l == nil || l.thresholds[kind] <= 0 {
+		return ctx, nil
+	}
+	o := &SlowOp{
+		log:    l,
+		parent: ctx,
+		op:     SlowOperation{Started: time.Now(), Kind: kind, Operation: name},
+	}
+	id := strconv.FormatUint(l.lastID.Add(1), 10)
+	label := slowOpLabel + kind
+	ctx = pprof.WithLabels(ctx, pprof.Labels(label, id))
+	pprof.SetGoroutineLabels(ctx)
+	o.timer = time.AfterFunc(l.thresholds[kind], func() { o.thresholdReached(label, id) })
+	return ctx, o
+}
+
+// SlowOp is an operation timed by the SlowLog. Its methods do nothing on a
+// nil operation.
+type SlowOp struct {
+	log    *SlowLog
+	parent context.Context
+	timer  *time.Timer
+	once   sync.Once
+
+	mu     sync.Mutex
+	op     SlowOperation
+	stacks string
+}
+
+// Release restores the labels of the goroutine that began the operation.
+func (o *SlowOp) Release() {
+	if o != nil {
+		pprof.SetGoroutineLabels(o.parent)
+	}
+}
+
+// End records the operation if it took longer than its threshold.
+func (o *SlowOp) End(err error) {
+	if o == nil {
+		return
+	}
+	o.once.Do(func() {
+		o.timer.Stop()
+		d := time.Since(o.op.Started)
+		if d < o.log.thresholds[o.op.Kind] {
+			return
+		}
+		o.mu.Lock()
+		op := o.op
+		op.Duration = d
+		op.Stacks = o.stacks
+		o.mu.Unlock()
+		if err != nil {
+			op.Error = err.Error()
+		}
+		o.log.add(op)
+	})
+}
+
+func (o *SlowOp) thresholdReached(label, id string) {
+	rate := o.log.sampleRate
+	if rate <= 0 || (o.log.reached.Add(1)-1)%rate != 0 {
+		return
+	}
+	stacks := labeledStacks(label, id)
+	o.mu.Lock()
+	o.stacks = stacks
+	o.mu.Unlock()
+}
+
+// labeledStacks returns the records of the goroutine profile of the
+// goroutines with the label key set to value.
+func labeledStacks(key, value string) string {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return ""
+	}
+	label := []byte(strconv.Quote(key) + ":" + strconv.Quote(value))
+	var out bytes.Buffer
+	for _, record := range bytes.Split(buf.Bytes(), []byte("\n\n")) {
+		if bytes.Contains(record, label) {
+			out.Write(bytes.TrimSpace(record))
+			out.WriteString("\n\n")
+		}
+	}
+	return out.String()
+}
+
+// SlowLogging records the slow operations when Logging.SlowLog is enabled.
+// The slow block fetches are recorded by OnlineExchange, and the API and
+// gateway requests by the HTTP servers of the daemon.
+func SlowLogging(cfg config.LoggingSlowLog) fx.Option {
+	if !cfg.Enabled.WithDefault(config.DefaultLoggingSlowLogEnabled) {
+		return fx.Options()
+	}
+	maxEntries := cfg.MaxEntries.WithDefault(config.DefaultLoggingSlowLogMaxEntries)
+	if maxEntries <= 0 {
+		return fx.Error(fmt.Errorf("Logging.SlowLog.MaxEntries must be positive"))
+	}
+	thresholds := map[string]time.Duration{
+		SlowOpAPI:        cfg.APIThreshold.WithDefault(config.DefaultLoggingSlowLogAPIThreshold),
+		SlowOpGateway:    cfg.GatewayThreshold.WithDefault(config.DefaultLoggingSlowLogGatewayThreshold),
+		SlowOpBlockFetch: cfg.BlockFetchThreshold.WithDefault(config.DefaultLoggingSlowLogBlockFetchThreshold),
+		SlowOpRouting:    cfg.RoutingThreshold.WithDefault(config.DefaultLoggingSlowLogRoutingThreshold),
+	}
+	l := newSlowLog(thresholds, int(maxEntries), cfg.StackSampleRate.WithDefault(config.DefaultLoggingSlowLogStackSampleRate))
+
+	return fx.Options(
+		fx.Supply(l),
+		fx.Decorate(func(r irouting.ProvideManyRouter) irouting.ProvideManyRouter {
+			return &slowRouter{ProvideManyRouter: r, log: l}
+		}),
+	)
+}
+
+// slowExchange records the slow block requests made to the wrapped exchange.
+type slowExchange struct {
+	exchange.Interface
+	log *SlowLog
+}
+
+var _ exchange.SessionExchange = (*slowExchange)(nil)
+
+// Unwrap returns the wrapped exchange.
+func (e *slowExchange) Unwrap() exchange.Interface {
+	return e.Interface
+}
+
+func (e *slowExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, op := e.log.Begin(ctx, SlowOpBlockFetch, "GetBlock "+c.String())
+	defer op.Release()
+	b, err := e.Interface.GetBlock(ctx, c)
+	op.End(err)
+	return b, err
+}
+
+func (e *slowExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return slowGetBlocks(ctx, e.log, e.Interface, cids)
+}
+
+func (e *slowExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	if sessEx, ok := e.Interface.(exchange.SessionExchange); ok {
+		return &slowFetcher{Fetcher: sessEx.NewSession(ctx), log: e.log}
+	}
+	return e
+}
+
+// GetWantlist returns the wants of the wrapped exchange, for
+// Bitswap.PersistWantlist.
+func (e *slowExchange) GetWantlist() []cid.Cid {
+	if wl, ok := e.Interface.(bitswapWantlister); ok {
+		return wl.GetWantlist()
+	}
+	return nil
+}
+
+type slowFetcher struct {
+	exchange.Fetcher
+	log *SlowLog
+}
+
+func (f *slowFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, op := f.log.Begin(ctx, SlowOpBlockFetch, "GetBlock "+c.String())
+	defer op.Release()
+	b, err := f.Fetcher.GetBlock(ctx, c)
+	op.End(err)
+	return b, err
+}
+
+func (f *slowFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return slowGetBlocks(ctx, f.log, f.Fetcher, cids)
+}
+
+// slowGetBlocks records the requests for several blocks, which end when the
+// last of them is received or the request is cancelled.
+func slowGetBlocks(ctx context.Context, l *SlowLog, f exchange.Fetcher, cids []cid.Cid) (<-chan blocks.Block, error) {
+	ctx, op := l.Begin(ctx, SlowOpBlockFetch, fmt.Sprintf("GetBlocks (%d blocks)", len(cids)))
+	defer op.Release()
+	in, err := f.GetBlocks(ctx, cids)
+	if err != nil || op == nil {
+		op.End(err)
+		return in, err
+	}
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer func() { op.End(ctx.Err()) }()
+		for b := range in {
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				// Drain so the request can end.
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// slowRouter records the slow routing queries and provides.
+type slowRouter struct {
+	irouting.ProvideManyRouter
+	log *SlowLog
+}
+
+func (r *slowRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	ctx, op := r.log.Begin(ctx, SlowOpRouting, "Provide "+c.String())
+	defer op.Release()
+	err := r.ProvideManyRouter.Provide(ctx, c, announce)
+	op.End(err)
+	return err
+}
+
+func (r *slowRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	ctx, op := r.log.Begin(ctx, SlowOpRouting, fmt.Sprintf("ProvideMany (%d keys)", len(keys)))
+	defer op.Release()
+	err := r.ProvideManyRouter.ProvideMany(ctx, keys)
+	op.End(err)
+	return err
+}
+
+func (r *slowRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	ctx, op := r.log.Begin(ctx, SlowOpRouting, "FindProviders "+c.String())
+	defer op.Release()
+	in := r.ProvideManyRouter.FindProvidersAsync(ctx, c, count)
+	if op == nil {
+		return in
+	}
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		defer func() { op.End(ctx.Err()) }()
+		for p := range in {
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				// Drain so the query can end.
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *slowRouter) FindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	ctx, op := r.log.Begin(ctx, SlowOpRouting, "FindPeer "+p.String())
+	defer op.Release()
+	ai, err := r.ProvideManyRouter.FindPeer(ctx, p)
+	op.End(err)
+	return ai, err
+}
+
+func (r *slowRouter) PutValue(ctx context.Context, key string, value []byte, opts ...routing.Option) error {
+	ctx, op := r.log.Begin(ctx, SlowOpRouting, "PutValue "+routingKey(key))
+	defer op.Release()
+	err := r.ProvideManyRouter.PutValue(ctx, key, value, opts...)
+	op.End(err)
+	return err
+}
+
+func (r *slowRouter) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	ctx, op := r.log.Begin(ctx, SlowOpRouting, "GetValue "+routingKey(key))
+	defer op.Release()
+	v, err := r.ProvideManyRouter.GetValue(ctx, key, opts...)
+	op.End(err)
+	return v, err
+}
+
+func (r *slowRouter) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	ctx, op := r.log.Begin(ctx, SlowOpRouting, "SearchValue "+routingKey(key))
+	defer op.Release()
+	in, err := r.ProvideManyRouter.SearchValue(ctx, key, opts...)
+	if err != nil || op == nil {
+		op.End(err)
+		return in, err
+	}
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		defer func() { op.End(ctx.Err()) }()
+		for v := range in {
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				// Drain so the query can end.
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// routingKey formats the binary part of the record keys, such as
+// /ipns/<peer id>, as text.
+func routingKey(key string) string {
+	if len(key) > 6 && key[:6] == "/ipns/" {
+		if p, err := peer.IDFromBytes([]byte(key[6:])); err == nil {
+			return "/ipns/" + p.String()
+		}
+	}
+	return strconv.Quote(key)
+}
@@ -0,0 +1,57 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowLog(t *testing.T) {
+	l := newSlowLog(map[string]time.Duration{SlowOpRouting: 50 * time.Millisecond}, 2, 1)
+
+	// the operations of kinds without a threshold are not timed
+	_, op := l.Begin(context.Background(), SlowOpAPI, "GET /api/v0/id")
+	require.Nil(t, op)
+	op.Release()
+	op.End(nil)
+
+	slowQuery := func(name string, d time.Duration) {
+		ctx, op := l.Begin(context.Background(), SlowOpRouting, name)
+		defer op.Release()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+			}
+		}()
+		<-done
+		op.End(errors.New("not found"))
+	}
+
+	slowQuery("FindPeer fast", time.Millisecond)
+	require.Empty(t, l.Operations(time.Time{}))
+
+	slowQuery("FindPeer 1", 100*time.Millisecond)
+	ops := l.Operations(time.Time{})
+	require.Len(t, ops, 1)
+	require.Equal(t, SlowOpRouting, ops[0].Kind)
+	require.Equal(t, "FindPeer 1", ops[0].Operation)
+	require.Equal(t, "not found", ops[0].Error)
+	require.GreaterOrEqual(t, ops[0].Duration, 100*time.Millisecond)
+	// the stacks of the goroutine started by the operation were captured
+	require.Contains(t, ops[0].Stacks, "TestSlowLog.func")
+
+	// only the most recent operations are kept
+	slowQuery("FindPeer 2", 60*time.Millisecond)
+	slowQuery("FindPeer 3", 60*time.Millisecond)
+	ops = l.Operations(time.Time{})
+	require.Len(t, ops, 2)
+	require.Equal(t, "FindPeer 2", ops[0].Operation)
+	require.Equal(t, "FindPeer 3", ops[1].Operation)
+	require.Empty(t, l.Operations(time.Now()))
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ipfs/boxo/blockservice"
+	blockstore "github.com/ipfs/boxo/blockstore"
+	exchange "github.com/ipfs/boxo/exchange"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+)
+
+var persistedWantlistKey = datastore.NewKey("/local/bitswap/wantlist")
+
+// wantlistSnapshotInterval is how often the wantlist is saved while the node
+// runs, so wants survive cancellations during shutdown and crashes.
+const wantlistSnapshotInterval = time.Minute
+
+type bitswapWantlister interface {
+	GetWantlist() []cid.Cid
+}
+
+// LoadPersistedWantlist returns the wants saved by Bitswap.PersistWantlist
+// that are still missing from the blockstore.
+func LoadPersistedWantlist(ctx context.Context, ds datastore.Datastore, bs blockstore.Blockstore) ([]cid.Cid, error) {
+	val, err := ds.Get(ctx, persistedWantlistKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []cid.Cid
+	if err := json.Unmarshal(val, &saved); err != nil {
+		return nil, err
+	}
+
+	missing := saved[:0]
+	for _, c := range saved {
+		has, err := bs.Has(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			missing = append(missing, c)
+		}
+	}
+	return missing, nil
+}
+
+// ReissueWantlist fetches wants into the blockstore at background priority.
+// It returns the number of blocks retrieved before ctx was done.
+func ReissueWantlist(ctx context.Context, bsvc blockservice.BlockService, wants []cid.Cid) int {
+	ctx = WithFetchPriority(ctx, FetchPriorityBackground)
+	var fetched int
+	for range bsvc.GetBlocks(ctx, wants) {
+		fetched++
+	}
+	return fetched
+}
+
+// saveWantlist persists the current wantlist. An empty wantlist is usually
+// the result of requests being canceled, so the previous snapshot is pruned
+// of the blocks that arrived instead of being dropped.
+func saveWantlist(ctx context.Context, ds datastore.Datastore, bs blockstore.Blockstore, wl bitswapWantlister) error {
+	wants := wl.GetWantlist()
+	if len(wants) == 0 {
+		var err error
+		wants, err = LoadPersistedWantlist(ctx, ds, bs)
+		if err != nil {
+			return err
+		}
+		if len(wants) == 0 {
+			return ds.Delete(ctx, persistedWantlistKey)
+		}
+	}
+
+	val, err := json.Marshal(wants)
+	if err != nil {
+		return err
+	}
+	return ds.Put(ctx, persistedWantlistKey, val)
+}
+
+// PersistWantlist saves the Bitswap wantlist to the datastore while the node
+// runs and on shutdown, and re-issues the saved wants on startup.
+func PersistWantlist(enabled bool) fx.Option {
+	if !enabled {
+		return fx.Options()
+	}
+	return fx.Invoke(func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, bs blockstore.Blockstore, exch exchange.Interface, bsvc blockservice.BlockService) error {
+		wl, ok := exch.(bitswapWantlister)
+		if !ok {
+			return errors.New("Bitswap.PersistWantlist requires the bitswap exchange")
+		}
+		ds := repo.Datastore()
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		lc.Append(fx.Hook{
+			OnStart: func(startCtx context.Context) error {
+				wants, err := LoadPersistedWantlist(startCtx, ds, bs)
+				if err != nil {
+					return err
+				}
+				go func() {
+					if len(wants) > 0 {
+						logger.Infof("re-issuing %d persisted wants", len(wants))
+						fetched := ReissueWantlist(ctx, bsvc, wants)
+						logger.Infof("retrieved %d of %d persisted wants", fetched, len(wants))
+					}
+				}()
+				go func() {
+					ticker := time.NewTicker(wantlistSnapshotInterval)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ticker.C:
+							if err := saveWantlist(ctx, ds, bs, wl); err != nil && ctx.Err() == nil {
+								logger.Errorf("saving wantlist: %s", err)
+							}
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+				return nil
+			},
+			OnStop: func(stopCtx context.Context) error {
+				return saveWantlist(stopCtx, ds, bs, wl)
+			},
+		})
+		return nil
+	})
+}
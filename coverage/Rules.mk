@@ -12,6 +12,9 @@ endif
 
 .PHONY: $(d)/coverage_deps
 
+# the CLI tests advance the mock clock of the daemon, see core/node/clock_testclock.go
+$(d)/coverage_deps: GOTAGS += testclock
+
 # unit tests coverage
 UTESTS_$(d) := $(shell $(GOCC) list -f '{{if (or (len .TestGoFiles) (len .XTestGoFiles))}}{{.ImportPath}}{{end}}' $(go-flags-with-tags) ./... | grep -v go-ipfs/vendor | grep -v go-ipfs/Godeps)
 
@@ -0,0 +1,5 @@
+// Package logging extends the log output set up by go-log from the GOLOG_*
+// environment variables with the Logging section of the config: a log file
+// rotated by size and age, and the rate limiting of the warnings and errors
+// repeated by a subsystem.
+package logging
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/dustin/go-humanize"
+	golog "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/kubo/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Setup adds the log file and the rate limiting configured by cfg to the
+// outputs configured by the GOLOG_* environment variables. A relative
+// Logging.File is relative to repoPath. The returned closer closes the log
+// file.
+func Setup(cfg config.Logging, repoPath string) (io.Closer, error) {
+	file := cfg.File.WithDefault("")
+	rateLimited := cfg.RateLimit.Enabled.WithDefault(config.DefaultLoggingRateLimitEnabled)
+	if file == "" && !rateLimited {
+		return nopCloser{}, nil
+	}
+
+	logCfg := golog.GetConfig()
+	var outputs []string
+	if logCfg.Stderr {
+		outputs = append(outputs, "stderr")
+	}
+	if logCfg.Stdout {
+		outputs = append(outputs, "stdout")
+	}
+	if logCfg.File != "" {
+		outputs = append(outputs, logCfg.File)
+	}
+	if logCfg.URL != "" {
+		outputs = append(outputs, logCfg.URL)
+	}
+	ws, _, err := zap.Open(outputs...)
+	if err != nil {
+		return nil, fmt.Errorf("opening the log outputs: %w", err)
+	}
+	core := newCore(logCfg.Format, ws)
+
+	var closer io.Closer = nopCloser{}
+	if file != "" {
+		maxSize, err := humanize.ParseBytes(cfg.MaxFileSize.WithDefault(config.DefaultLoggingMaxFileSize))
+		if err != nil {
+			return nil, fmt.Errorf("Logging.MaxFileSize: %w", err)
+		}
+		maxBackups := cfg.MaxBackups.WithDefault(config.DefaultLoggingMaxBackups)
+		if maxBackups < 0 {
+			return nil, fmt.Errorf("Logging.MaxBackups must not be negative")
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(repoPath, file)
+		}
+		f, err := openRotatingFile(file, int64(maxSize), cfg.RotateInterval.WithDefault(config.DefaultLoggingRotateInterval), int(maxBackups))
+		if err != nil {
+			return nil, fmt.Errorf("Logging.File: %w", err)
+		}
+		closer = f
+
+		// the file is never colorized
+		format := logCfg.Format
+		if format == golog.ColorizedOutput {
+			format = golog.PlaintextOutput
+		}
+		core = zapcore.NewTee(core, newCore(format, f))
+	}
+
+	if rateLimited {
+		interval := cfg.RateLimit.Interval.WithDefault(config.DefaultLoggingRateLimitInterval)
+		if interval <= 0 {
+			closer.Close()
+			return nil, fmt.Errorf("Logging.RateLimit.Interval must be positive")
+		}
+		burst := cfg.RateLimit.Burst.WithDefault(config.DefaultLoggingRateLimitBurst)
+		core = newRateLimitedCore(core, interval, int(burst))
+	}
+
+	for k, v := range logCfg.Labels {
+		core = core.With([]zap.Field{zap.String(k, v)})
+	}
+	golog.SetPrimaryCore(core)
+	return closer, nil
+}
+
+// newCore returns a core writing every level to ws with the encoding of
+// go-log.
+func newCore(format golog.LogFormat, ws zapcore.WriteSyncer) zapcore.Core {
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch format {
+	case golog.PlaintextOutput:
+		encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encCfg)
+	case golog.JSONOutput:
+		encoder = zapcore.NewJSONEncoder(encCfg)
+	default:
+		encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encCfg)
+	}
+	return zapcore.NewCore(encoder, ws, zapcore.DebugLevel)
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRateLimitedCore(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	interval := 100 * time.Millisecond
+	core := newRateLimitedCore(obs, interval, 2)
+	dht := zap.New(core).Named("dht")
+	bitswap := zap.New(core).Named("bitswap")
+
+	for i := 0; i < 5; i++ {
+		dht.Error("lookup failed")
+		dht.Debug("lookup failed")
+	}
+	bitswap.Error("lookup failed")
+	dht.Error("another error")
+
+	messages := func() []string {
+		var m []string
+		for _, e := range logs.All() {
+			m = append(m, e.LoggerName+": "+e.Message)
+		}
+		return m
+	}
+	// the debug entries are not limited, and each subsystem has its own limit
+	require.Equal(t, []string{
+		"dht: lookup failed", "dht: lookup failed",
+		"dht: lookup failed", "dht: lookup failed", "dht: lookup failed", "dht: lookup failed", "dht: lookup failed",
+		"bitswap: lookup failed",
+		"dht: another error",
+	}, messages())
+
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage("lookup failed (repeated 3 times)").Len() == 1
+	}, time.Second, 10*time.Millisecond)
+	summary := logs.FilterMessage("lookup failed (repeated 3 times)").All()[0]
+	require.Equal(t, "dht", summary.LoggerName)
+	require.Equal(t, zapcore.ErrorLevel, summary.Level)
+
+	// the next window logs the entry again
+	dht.Error("lookup failed")
+	require.Equal(t, 4, logs.FilterMessage("lookup failed").FilterLevelExact(zapcore.ErrorLevel).Len())
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "ipfs.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f, err := openRotatingFile(path, 10, time.Hour, 2)
+	require.NoError(t, err)
+	f.now = func() time.Time { return now }
+	defer f.Close()
+
+	write := func(s string) {
+		_, err := f.Write([]byte(s))
+		require.NoError(t, err)
+		now = now.Add(time.Second)
+	}
+	rotated := func() []string {
+		files, err := filepath.Glob(path + ".*")
+		require.NoError(t, err)
+		return files
+	}
+
+	write("12345")
+	write("67890")
+	require.Empty(t, rotated())
+
+	// the file is rotated once full
+	write("abc")
+	require.Len(t, rotated(), 1)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(b))
+
+	// and once written to for the interval
+	now = now.Add(time.Hour)
+	write("def")
+	require.Len(t, rotated(), 2)
+
+	// only the most recent rotated files are kept
+	write("1234567890")
+	files := rotated()
+	require.Len(t, files, 2)
+	b, err = os.ReadFile(files[1])
+	require.NoError(t, err)
+	require.Equal(t, "def", string(b))
+}
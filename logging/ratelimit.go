@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// rateLimitedCore drops the warnings and errors repeated more than burst
+// times in an interval by a subsystem, and logs them once more with the
+// number of times they were repeated at the end of the interval.
+type rateLimitedCore struct {
+	zapcore.Core
+	limiter *rateLimiter
+}
+
+func newRateLimitedCore(core zapcore.Core, interval time.Duration, burst int) zapcore.Core {
+	return &rateLimitedCore{
+		Core: core,
+		limiter: &rateLimiter{
+			interval: interval,
+			burst:    burst,
+			windows:  make(map[repeatKey]*repeatWindow),
+			now:      time.Now,
+		},
+	}
+}
+
+func (c *rateLimitedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitedCore{Core: c.Core.With(fields), limiter: c.limiter}
+}
+
+func (c *rateLimitedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *rateLimitedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level >= zapcore.WarnLevel && !c.limiter.allow(ent, c.Core, fields) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// repeatKey identifies the identical entries of a subsystem.
+type repeatKey struct {
+	logger  string
+	level   zapcore.Level
+	message string
+}
+
+type repeatWindow struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+type rateLimiter struct {
+	interval time.Duration
+	burst    int
+	now      func() time.Time
+
+	mu        sync.Mutex
+	windows   map[repeatKey]*repeatWindow
+	lastSweep time.Time
+}
+
+// allow reports whether ent is written. The first entry suppressed in a
+// window schedules the summary written to core at the end of the window.
+func (l *rateLimiter) allow(ent zapcore.Entry, core zapcore.Core, fields []zapcore.Field) bool {
+	key := repeatKey{logger: ent.LoggerName, level: ent.Level, message: ent.Message}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.interval {
+		if ok && w.suppressed > 0 {
+			// the summary of the previous window is still pending
+			return true
+		}
+		l.windows[key] = &repeatWindow{start: now, count: 1}
+		return true
+	}
+	w.count++
+	if w.count <= l.burst {
+		return true
+	}
+	w.suppressed++
+	if w.suppressed == 1 {
+		time.AfterFunc(w.start.Add(l.interval).Sub(now), func() {
+			l.summarize(key, ent, core, fields)
+		})
+	}
+	return false
+}
+
+func (l *rateLimiter) summarize(key repeatKey, ent zapcore.Entry, core zapcore.Core, fields []zapcore.Field) {
+	l.mu.Lock()
+	w := l.windows[key]
+	delete(l.windows, key)
+	l.mu.Unlock()
+	if w == nil || w.suppressed == 0 {
+		return
+	}
+
+	ent.Time = l.now()
+	ent.Message = fmt.Sprintf("%s (repeated %d times)", ent.Message, w.suppressed)
+	_ = core.Write(ent, fields)
+}
+
+// sweep forgets the windows that ended without suppressing an entry, at most
+// once per interval.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.interval {
+		return
+	}
+	l.lastSweep = now
+	for key, w := range l.windows {
+		if w.suppressed == 0 && now.Sub(w.start) >= l.interval {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat suffixes the names of the rotated files, so that they
+// sort by rotation time.
+const rotatedTimeFormat = "20060102T150405.000"
+
+// rotatingFile is a log file that is renamed and replaced by an empty file
+// once it exceeds maxSize, or once it was written to for interval. Only the
+// maxBackups most recent rotated files are kept.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// OpenRotatingFile opens the file at path for appending, rotated like
+// Logging.File, for the logs written outside of go-log such as the access log
+// of the gateway.
+func OpenRotatingFile(path string, maxSize int64, interval time.Duration, maxBackups int) (io.WriteCloser, error) {
+	return openRotatingFile(path, maxSize, interval, maxBackups)
+}
+
+func openRotatingFile(path string, maxSize int64, interval time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = fi.Size()
+	r.opened = r.now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	full := r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize
+	expired := r.interval > 0 && r.now().Sub(r.opened) >= r.interval
+	if full || expired {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Sync()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(r.path, r.path+"."+r.now().Format(rotatedTimeFormat)); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	rotated, err := filepath.Glob(r.path + ".*")
+	if err != nil || len(rotated) <= r.maxBackups {
+		return nil
+	}
+	sort.Strings(rotated)
+	for _, old := range rotated[:len(rotated)-r.maxBackups] {
+		_ = os.Remove(old)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/ipfs/kubo/core/coreapi"
 	plugin "github.com/ipfs/kubo/plugin"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
+	irouting "github.com/ipfs/kubo/routing"
 
 	logging "github.com/ipfs/go-log"
 	opentracing "github.com/opentracing/opentracing-go"
@@ -292,6 +293,13 @@ func (loader *PluginLoader) Inject() error {
 				return err
 			}
 		}
+		if pl, ok := pl.(plugin.PluginRouter); ok {
+			err := injectRouterPlugin(pl)
+			if err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+		}
 		if pl, ok := pl.(plugin.PluginFx); ok {
 			err := injectFxPlugin(pl)
 			if err != nil {
@@ -371,6 +379,10 @@ func injectDatastorePlugin(pl plugin.PluginDatastore) error {
 	return fsrepo.AddDatastoreConfigHandler(pl.DatastoreTypeName(), pl.DatastoreConfigParser())
 }
 
+func injectRouterPlugin(pl plugin.PluginRouter) error {
+	return irouting.AddRouterType(pl.RouterTypeName(), pl.RouterConstructor())
+}
+
 func injectIPLDPlugin(pl plugin.PluginIPLD) error {
 	return pl.Register(multicodec.DefaultRegistry)
 }
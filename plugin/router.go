@@ -0,0 +1,15 @@
+package plugin
+
+import (
+	irouting "github.com/ipfs/kubo/routing"
+)
+
+// PluginRouter is an interface that can be implemented to add router types,
+// which can be used in Routing.Routers with Routing.Type set to "custom" and
+// composed with the other routers.
+type PluginRouter interface {
+	Plugin
+
+	RouterTypeName() string
+	RouterConstructor() irouting.RouterFromMap
+}
@@ -134,13 +134,18 @@ func parse(visited map[string]bool,
 
 		router = routinghelpers.NewComposableSequential(sr)
 	default:
-		return nil, fmt.Errorf("unknown router type %q", cfg.Type)
+		newRouter, ok := pluginRouters[cfg.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown router type %q", cfg.Type)
+		}
+		router, err = pluginRoutingFromConfig(cfg.Router, newRouter, extraDHT)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	router = newTracedRouter(routerName, router)
 	createdRouters[routerName] = router
 
 	log.Info("created router ", routerName, " with params ", cfg.Parameters)
@@ -212,7 +217,7 @@ func httpRoutingFromConfig(conf config.Router, extraHTTP *ExtraHTTPParams) (rout
 	}
 
 	cr := contentrouter.NewContentRoutingClient(
-		cli,
+		&retryingClient{Client: cli, attempts: writeAttempts, backoff: writeBackoff},
 		contentrouter.WithMaxProvideBatchSize(params.MaxProvideBatchSize),
 		contentrouter.WithMaxProvideConcurrency(params.MaxProvideConcurrency),
 	)
@@ -227,6 +232,7 @@ func httpRoutingFromConfig(conf config.Router, extraHTTP *ExtraHTTPParams) (rout
 		PeerRouting:       cr,
 		ValueStore:        cr,
 		ProvideManyRouter: cr,
+		endpoint:          params.Endpoint,
 	}, nil
 }
 
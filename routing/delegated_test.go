@@ -3,11 +3,14 @@ package routing
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"testing"
 
 	"github.com/ipfs/kubo/config"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/stretchr/testify/require"
 )
 
@@ -224,3 +227,33 @@ func generatePeerID() (string, string, error) {
 	pid, err := peer.IDFromPublicKey(pk)
 	return pid.String(), enc, err
 }
+
+func TestParserPluginRouter(t *testing.T) {
+	require := require.New(t)
+
+	var gotParams map[string]interface{}
+	require.NoError(AddRouterType("test-indexer", func(params map[string]interface{}, extra *ExtraPluginParams) (routing.Routing, error) {
+		gotParams = params
+		return routinghelpers.Null{}, nil
+	}))
+	require.Error(AddRouterType("test-indexer", nil))
+	require.Error(AddRouterType(string(config.RouterTypeHTTP), nil))
+
+	var routers config.Routers
+	require.NoError(json.Unmarshal([]byte(`{
+		"indexer": {"Type": "test-indexer", "Parameters": {"Endpoint": "internal"}},
+		"nameless": {"Type": "test-indexer"},
+		"all": {"Type": "parallel", "Parameters": {"Routers": [{"RouterName": "indexer"}, {"RouterName": "nameless"}]}}
+	}`), &routers))
+
+	router, err := Parse(routers, config.Methods{
+		config.MethodNameFindPeers:     config.Method{RouterName: "nameless"},
+		config.MethodNameFindProviders: config.Method{RouterName: "all"},
+		config.MethodNameGetIPNS:       config.Method{RouterName: "indexer"},
+		config.MethodNamePutIPNS:       config.Method{RouterName: "indexer"},
+		config.MethodNameProvide:       config.Method{RouterName: "all"},
+	}, &ExtraDHTParams{}, &ExtraHTTPParams{})
+	require.NoError(err)
+	require.IsType(&Composer{}, router)
+	require.Equal(map[string]interface{}{"Endpoint": "internal"}, gotParams)
+}
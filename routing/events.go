@@ -0,0 +1,319 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
+)
+
+// Types of the events of the routing operations. The types other than
+// EventStarted and EventFinished are the query events of the DHT.
+const (
+	EventStarted      = "started"
+	EventFinished     = "finished"
+	EventSendingQuery = "sending-query"
+	EventPeerResponse = "peer-response"
+	EventFinalPeer    = "final-peer"
+	EventQueryError   = "query-error"
+	EventProvider     = "provider"
+	EventValue        = "value"
+	EventAddingPeer   = "adding-peer"
+	EventDialingPeer  = "dialing-peer"
+)
+
+var queryEventTypes = map[routing.QueryEventType]string{
+	routing.SendingQuery: EventSendingQuery,
+	routing.PeerResponse: EventPeerResponse,
+	routing.FinalPeer:    EventFinalPeer,
+	routing.QueryError:   EventQueryError,
+	routing.Provider:     EventProvider,
+	routing.Value:        EventValue,
+	routing.AddingPeer:   EventAddingPeer,
+	routing.DialingPeer:  EventDialingPeer,
+}
+
+const (
+	// eventsHistory is the number of finished operations kept by Events.
+	eventsHistory = 100
+	// followBufferSize is the number of events buffered for each follower,
+	// the events it does not read in time are dropped.
+	followBufferSize = 256
+)
+
+// Event is a step of a routing operation of the node recorded by Events.
+type Event struct {
+	Time time.Time
+	// Op identifies the operation, all its events have the same Op.
+	Op     uint64
+	Type   string
+	Method string
+	Key    string `json:",omitempty"`
+	// Peer is the peer queried, or the provider found.
+	Peer string `json:",omitempty"`
+	// Peers are the closer peers returned by Peer.
+	Peers []string `json:",omitempty"`
+	// Results is the number of providers or values found by a finished
+	// operation.
+	Results  int           `json:",omitempty"`
+	Duration time.Duration `json:",omitempty"`
+	Error    string        `json:",omitempty"`
+}
+
+// Events records the operations of the router wrapped by Wrap, and streams
+// their events to the followers.
+type Events struct {
+	lastOp    atomic.Uint64
+	following atomic.Int32
+
+	mu        sync.Mutex
+	followers map[chan Event]struct{}
+	history   []Event
+	next      int
+}
+
+// NewEvents returns an Events without followers.
+func NewEvents() *Events {
+	return &Events{followers: make(map[chan Event]struct{})}
+}
+
+// History returns the summaries of the last finished operations, oldest
+// first.
+func (e *Events) History() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.history) < eventsHistory {
+		return append([]Event(nil), e.history...)
+	}
+	return append(append([]Event(nil), e.history[e.next:]...), e.history[:e.next]...)
+}
+
+// Follow returns the events of the operations started from now on, until
+// ctx is done. The events are sent with the query events of the DHT only
+// while there are followers.
+func (e *Events) Follow(ctx context.Context) <-chan Event {
+	ch := make(chan Event, followBufferSize)
+	e.mu.Lock()
+	e.followers[ch] = struct{}{}
+	e.mu.Unlock()
+	e.following.Add(1)
+	go func() {
+		<-ctx.Done()
+		e.following.Add(-1)
+		e.mu.Lock()
+		delete(e.followers, ch)
+		close(ch)
+		e.mu.Unlock()
+	}()
+	return ch
+}
+
+func (e *Events) publish(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if ev.Type == EventFinished {
+		if len(e.history) < eventsHistory {
+			e.history = append(e.history, ev)
+		} else {
+			e.history[e.next] = ev
+			e.next = (e.next + 1) % eventsHistory
+		}
+	}
+	for ch := range e.followers {
+		select {
+		case ch <- ev:
+		default:
+			log.Debugf("dropping routing event of operation %d for a slow follower", ev.Op)
+		}
+	}
+}
+
+// eventOp is a routing operation recorded by Events.
+type eventOp struct {
+	events  *Events
+	id      uint64
+	method  string
+	key     string
+	start   time.Time
+	cancel  context.CancelFunc
+	queried chan struct{}
+}
+
+// begin records the start of an operation. When the operation is followed,
+// the returned context collects its query events, which are still forwarded to
+// the subscriber of ctx, if any.
+func (e *Events) begin(ctx context.Context, method, key string) (context.Context, *eventOp) {
+	op := &eventOp{events: e, id: e.lastOp.Add(1), method: method, key: key, start: time.Now()}
+	if e.following.Load() == 0 {
+		return ctx, op
+	}
+	e.publish(op.event(EventStarted))
+
+	qctx, cancel := context.WithCancel(ctx)
+	qctx, queryEvents := routing.RegisterForQueryEvents(qctx)
+	op.cancel = cancel
+	op.queried = make(chan struct{})
+	go func() {
+		defer close(op.queried)
+		for qe := range queryEvents {
+			routing.PublishQueryEvent(ctx, qe)
+			ev := op.event(queryEventTypes[qe.Type])
+			ev.Peer = qe.ID.String()
+			for _, ai := range qe.Responses {
+				ev.Peers = append(ev.Peers, ai.ID.String())
+			}
+			if qe.Type == routing.QueryError {
+				ev.Error = qe.Extra
+			}
+			e.publish(ev)
+		}
+	}()
+	return qctx, op
+}
+
+func (op *eventOp) event(typ string) Event {
+	return Event{Time: time.Now(), Op: op.id, Type: typ, Method: op.method, Key: op.key}
+}
+
+// end records the end of the operation, after its query events.
+func (op *eventOp) end(results int, err error) {
+	if op.cancel != nil {
+		op.cancel()
+		<-op.queried
+	}
+	ev := op.event(EventFinished)
+	ev.Duration = time.Since(op.start)
+	ev.Results = results
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	op.events.publish(ev)
+}
+
+// Wrap returns r recording its operations in e.
+func (e *Events) Wrap(r ProvideManyRouter) ProvideManyRouter {
+	return &eventsRouter{ProvideManyRouter: r, events: e}
+}
+
+var _ ProvideManyRouter = &eventsRouter{}
+
+type eventsRouter struct {
+	ProvideManyRouter
+	events *Events
+}
+
+// Unwrap returns the recorded router.
+func (r *eventsRouter) Unwrap() routing.Routing {
+	return r.ProvideManyRouter
+}
+
+func (r *eventsRouter) Ready() bool {
+	if rr, ok := r.ProvideManyRouter.(routinghelpers.ReadyAbleRouter); ok {
+		return rr.Ready()
+	}
+	return true
+}
+
+func (r *eventsRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	ctx, op := r.events.begin(ctx, "Provide", c.String())
+	err := r.ProvideManyRouter.Provide(ctx, c, announce)
+	op.end(0, err)
+	return err
+}
+
+func (r *eventsRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	ctx, op := r.events.begin(ctx, "ProvideMany", fmt.Sprintf("%d keys", len(keys)))
+	err := r.ProvideManyRouter.ProvideMany(ctx, keys)
+	op.end(0, err)
+	return err
+}
+
+func (r *eventsRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	qctx, op := r.events.begin(ctx, "FindProviders", c.String())
+	in := r.ProvideManyRouter.FindProvidersAsync(qctx, c, count)
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		var results int
+		defer func() { op.end(results, ctx.Err()) }()
+		for p := range in {
+			results++
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *eventsRouter) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	ctx, op := r.events.begin(ctx, "FindPeer", id.String())
+	ai, err := r.ProvideManyRouter.FindPeer(ctx, id)
+	op.end(len(ai.Addrs), err)
+	return ai, err
+}
+
+func (r *eventsRouter) PutValue(ctx context.Context, key string, val []byte, opts ...routing.Option) error {
+	ctx, op := r.events.begin(ctx, "PutValue", eventKey(key))
+	err := r.ProvideManyRouter.PutValue(ctx, key, val, opts...)
+	op.end(0, err)
+	return err
+}
+
+func (r *eventsRouter) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	ctx, op := r.events.begin(ctx, "GetValue", eventKey(key))
+	val, err := r.ProvideManyRouter.GetValue(ctx, key, opts...)
+	results := 0
+	if err == nil {
+		results = 1
+	}
+	op.end(results, err)
+	return val, err
+}
+
+func (r *eventsRouter) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	qctx, op := r.events.begin(ctx, "SearchValue", eventKey(key))
+	in, err := r.ProvideManyRouter.SearchValue(qctx, key, opts...)
+	if err != nil {
+		op.end(0, err)
+		return nil, err
+	}
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		var results int
+		defer func() { op.end(results, ctx.Err()) }()
+		for v := range in {
+			results++
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// eventKey returns a printable routing key: the peer ID of the /ipns and /pk
+// keys is encoded, the other keys are escaped when they are binary.
+func eventKey(key string) string {
+	for _, ns := range []string{"/ipns/", "/pk/"} {
+		if rest, ok := strings.CutPrefix(key, ns); ok {
+			if id, err := peer.IDFromBytes([]byte(rest)); err == nil {
+				return ns + id.String()
+			}
+		}
+	}
+	return strings.Trim(fmt.Sprintf("%q", key), `"`)
+}
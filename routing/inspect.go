@@ -0,0 +1,140 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+)
+
+// RouterTree describes a router of Routing.Routers and the routers it
+// composes, as created by Parse.
+type RouterTree struct {
+	Name string
+	Type config.RouterType
+	// Endpoint is the URL of HTTP routers.
+	Endpoint string `json:",omitempty"`
+	// Mode is the mode of DHT routers, "accelerated" for the accelerated
+	// client.
+	Mode string `json:",omitempty"`
+	// Timeout, ExecuteAfter and IgnoreErrors apply to the routers of a
+	// parallel or sequential router.
+	Timeout      time.Duration `json:",omitempty"`
+	ExecuteAfter time.Duration `json:",omitempty"`
+	IgnoreErrors bool          `json:",omitempty"`
+	Routers      []*RouterTree `json:",omitempty"`
+}
+
+// MethodTree is the router handling a method of Routing.Methods.
+type MethodTree struct {
+	Method config.MethodName
+	Router *RouterTree
+}
+
+// Inspection is the routing composed from Routing.Routers and Routing.Methods.
+type Inspection struct {
+	Methods []MethodTree
+	// Warnings are the parts of the config that are valid but likely
+	// mistakes.
+	Warnings []string `json:",omitempty"`
+}
+
+// Inspect validates routers and methods as Parse does, without creating the
+// routers, and describes the router of each method.
+func Inspect(routers config.Routers, methods config.Methods) (*Inspection, error) {
+	if err := methods.Check(); err != nil {
+		return nil, err
+	}
+
+	out := &Inspection{}
+	used := make(map[string]bool)
+	for _, mn := range config.MethodNameList {
+		tree, err := inspect(make(map[string]bool), used, methods[mn].RouterName, routers, &out.Warnings)
+		if err != nil {
+			return nil, err
+		}
+		out.Methods = append(out.Methods, MethodTree{Method: mn, Router: tree})
+	}
+
+	var unused []string
+	for name := range routers {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		out.Warnings = append(out.Warnings, fmt.Sprintf("router %q is not used by any method", name))
+	}
+	return out, nil
+}
+
+func inspect(visited, used map[string]bool, routerName string, routersCfg config.Routers, warnings *[]string) (*RouterTree, error) {
+	if visited[routerName] {
+		return nil, fmt.Errorf("dependency loop creating router with name %q", routerName)
+	}
+	visited[routerName] = true
+	defer delete(visited, routerName)
+
+	cfg, ok := routersCfg[routerName]
+	if !ok {
+		return nil, fmt.Errorf("config for router with name %q not found", routerName)
+	}
+	first := !used[routerName]
+	used[routerName] = true
+
+	tree := &RouterTree{Name: routerName, Type: cfg.Type}
+	switch cfg.Type {
+	case config.RouterTypeHTTP:
+		params, ok := cfg.Parameters.(*config.HTTPRouterParams)
+		if !ok || params.Endpoint == "" {
+			return nil, NewParamNeededErr("Endpoint", cfg.Type)
+		}
+		tree.Endpoint = params.Endpoint
+	case config.RouterTypeDHT:
+		params, ok := cfg.Parameters.(*config.DHTRouterParams)
+		if !ok {
+			return nil, fmt.Errorf("incorrect params for DHT router %q", routerName)
+		}
+		switch {
+		case params.AcceleratedDHTClient:
+			tree.Mode = "accelerated"
+		case params.Mode == config.DHTModeAuto, params.Mode == config.DHTModeClient, params.Mode == config.DHTModeServer:
+			tree.Mode = string(params.Mode)
+		default:
+			return nil, fmt.Errorf("invalid DHT mode: %q", params.Mode)
+		}
+	case config.RouterTypeParallel, config.RouterTypeSequential:
+		params, ok := cfg.Parameters.(*config.ComposableRouterParams)
+		if !ok {
+			return nil, fmt.Errorf("incorrect params for %s router %q", cfg.Type, routerName)
+		}
+		if first && params.Timeout != nil {
+			*warnings = append(*warnings, fmt.Sprintf("Timeout of router %q is ignored, set it on the routers it composes", routerName))
+		}
+		if first && len(params.Routers) == 0 {
+			*warnings = append(*warnings, fmt.Sprintf("%s router %q composes no routers", cfg.Type, routerName))
+		}
+		for _, cr := range params.Routers {
+			child, err := inspect(visited, used, cr.RouterName, routersCfg, warnings)
+			if err != nil {
+				return nil, err
+			}
+			child.Timeout = cr.Timeout.Duration
+			child.IgnoreErrors = cr.IgnoreErrors
+			if cfg.Type == config.RouterTypeParallel {
+				// sequential routers have no delay
+				child.ExecuteAfter = cr.ExecuteAfter.WithDefault(0)
+			} else if first && cr.ExecuteAfter != nil {
+				*warnings = append(*warnings, fmt.Sprintf("ExecuteAfter of router %q in sequential router %q is ignored", cr.RouterName, routerName))
+			}
+			tree.Routers = append(tree.Routers, child)
+		}
+	default:
+		if _, ok := pluginRouters[cfg.Type]; !ok {
+			return nil, fmt.Errorf("unknown router type %q", cfg.Type)
+		}
+	}
+	return tree, nil
+}
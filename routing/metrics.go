@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// routerMetrics are the metrics of the calls to each router, labeled by the
+// router name and the method.
+type routerMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	results  *prometheus.CounterVec
+}
+
+var getRouterMetrics = sync.OnceValue(func() *routerMetrics {
+	m := &routerMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ipfs_routing_router_requests_total",
+			Help: "Calls to each router, by method and result: success, error, timeout, canceled or unsupported.",
+		}, []string{"router", "method", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ipfs_routing_router_request_duration_seconds",
+			Help:    "Duration of the calls to each router, by method.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"router", "method"}),
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ipfs_routing_router_results_total",
+			Help: "Providers, peer addresses and values returned by each router, by method.",
+		}, []string{"router", "method"}),
+	}
+	m.requests = registerOrExisting(m.requests)
+	m.duration = registerOrExisting(m.duration)
+	m.results = registerOrExisting(m.results)
+	return m
+})
+
+func registerOrExisting[C prometheus.Collector](c C) C {
+	if err := prometheus.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector.(C)
+		}
+		panic(err)
+	}
+	return c
+}
+
+func recordRouterMetrics(ev RouterEvent, err error) {
+	result := "success"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result = "timeout"
+	case errors.Is(err, context.Canceled):
+		result = "canceled"
+	case errors.Is(err, routing.ErrNotSupported):
+		result = "unsupported"
+	case err != nil:
+		result = "error"
+	}
+	m := getRouterMetrics()
+	m.requests.WithLabelValues(ev.Router, ev.Method, result).Inc()
+	m.duration.WithLabelValues(ev.Router, ev.Method).Observe(ev.Duration.Seconds())
+	if ev.Results > 0 {
+		m.results.WithLabelValues(ev.Router, ev.Method).Add(float64(ev.Results))
+	}
+}
+
+// Instrument wraps r so that its calls are recorded, under name, in the
+// ipfs_routing_router_* metrics and in the Trace of their context. The
+// optional interfaces of r used by composable routers are kept, and the wrapped
+// router is returned by Unwrap.
+func Instrument(name string, r routing.Routing) routing.Routing {
+	return newTracedRouter(name, r)
+}
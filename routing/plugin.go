@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/kubo/config"
+	record "github.com/libp2p/go-libp2p-record"
+	host "github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// ExtraPluginParams are the components of the node available to the routers
+// of plugins.
+type ExtraPluginParams struct {
+	Host      host.Host
+	Validator record.Validator
+	Datastore datastore.Batching
+	Context   context.Context
+}
+
+// RouterFromMap creates a router from the Parameters of its Routing.Routers
+// entry, decoded from JSON.
+type RouterFromMap func(params map[string]interface{}, extra *ExtraPluginParams) (routing.Routing, error)
+
+var pluginRouters = map[config.RouterType]RouterFromMap{}
+
+// AddRouterType registers a router type, usable in Routing.Routers when
+// Routing.Type is "custom".
+func AddRouterType(name string, r RouterFromMap) error {
+	typ := config.RouterType(name)
+	switch typ {
+	case config.RouterTypeHTTP, config.RouterTypeDHT, config.RouterTypeParallel, config.RouterTypeSequential:
+		return fmt.Errorf("router type %q is built in", name)
+	}
+	if _, ok := pluginRouters[typ]; ok {
+		return fmt.Errorf("already have a router type named %q", name)
+	}
+	pluginRouters[typ] = r
+	return nil
+}
+
+func pluginRoutingFromConfig(conf config.Router, newRouter RouterFromMap, extra *ExtraDHTParams) (routing.Routing, error) {
+	params, _ := conf.Parameters.(map[string]interface{})
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	return newRouter(params, &ExtraPluginParams{
+		Host:      extra.Host,
+		Validator: extra.Validator,
+		Datastore: extra.Datastore,
+		Context:   extra.Context,
+	})
+}
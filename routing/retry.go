@@ -0,0 +1,81 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	drclient "github.com/ipfs/boxo/routing/http/client"
+	"github.com/ipfs/boxo/routing/http/contentrouter"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+const (
+	// writeAttempts is the number of times a batch of provider records or
+	// an IPNS record is sent to an HTTP router before giving up.
+	writeAttempts = 4
+	// writeBackoff is the delay before the first retry, doubled on each.
+	writeBackoff = time.Second
+)
+
+// retryingClient retries the provider records and IPNS records an HTTP router
+// failed to store, with exponential backoff. Reads are not retried, as other
+// routers are queried in parallel.
+type retryingClient struct {
+	contentrouter.Client
+
+	attempts int
+	backoff  time.Duration
+}
+
+func (c *retryingClient) ProvideBitswap(ctx context.Context, keys []cid.Cid, ttl time.Duration) (time.Duration, error) {
+	var advisoryTTL time.Duration
+	err := c.retry(ctx, func() error {
+		var err error
+		advisoryTTL, err = c.Client.ProvideBitswap(ctx, keys, ttl)
+		return err
+	})
+	return advisoryTTL, err
+}
+
+func (c *retryingClient) PutIPNS(ctx context.Context, name ipns.Name, record *ipns.Record) error {
+	return c.retry(ctx, func() error {
+		return c.Client.PutIPNS(ctx, name, record)
+	})
+}
+
+func (c *retryingClient) retry(ctx context.Context, f func() error) error {
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt == c.attempts || !retryable(err) {
+			return err
+		}
+		log.Debugf("delegated routing write failed (attempt %d/%d), retrying in %s: %s", attempt, c.attempts, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// retryable tells whether err may not happen again: network errors, rate
+// limiting and server errors are retried, other HTTP errors are not.
+func retryable(err error) bool {
+	if errors.Is(err, routing.ErrNotSupported) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var httpErr *drclient.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package routing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
+)
+
+// RouterEvent is a call to a router of Routing.Routers recorded by a Trace.
+type RouterEvent struct {
+	Router   string
+	Method   string
+	Duration time.Duration
+	// Results is the number of providers or values returned by the router.
+	Results int    `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// Trace records the calls to the routers created by Parse, or wrapped by
+// Instrument, made with the context returned by WithTrace.
+type Trace struct {
+	mu     sync.Mutex
+	events []RouterEvent
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context recording the routers called with it in the
+// returned Trace.
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// Events returns the calls recorded so far, in the order they completed.
+func (t *Trace) Events() []RouterEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RouterEvent(nil), t.events...)
+}
+
+func (t *Trace) record(ev RouterEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, ev)
+}
+
+func traceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+var (
+	_ routing.Routing                  = &tracedRouter{}
+	_ routinghelpers.ProvideManyRouter = &tracedProvideManyRouter{}
+)
+
+// tracedRouter records the calls to a router in the routing metrics, and in
+// the Trace of their context, if any.
+type tracedRouter struct {
+	routing.Routing
+	name string
+}
+
+// tracedProvideManyRouter is a tracedRouter for routers that can provide
+// many keys at once.
+type tracedProvideManyRouter struct {
+	tracedRouter
+	pm routinghelpers.ProvideManyRouter
+}
+
+// newTracedRouter wraps r, keeping the optional interfaces composable
+// routers look for.
+func newTracedRouter(name string, r routing.Routing) routing.Routing {
+	tr := tracedRouter{Routing: r, name: name}
+	if pm, ok := r.(routinghelpers.ProvideManyRouter); ok {
+		return &tracedProvideManyRouter{tracedRouter: tr, pm: pm}
+	}
+	return &tr
+}
+
+// Unwrap returns the traced router.
+func (r *tracedRouter) Unwrap() routing.Routing {
+	return r.Routing
+}
+
+// done records a call in the routing metrics, and in the Trace of ctx if
+// any.
+func (r *tracedRouter) done(ctx context.Context, method string, start time.Time, results int, err error) {
+	ev := RouterEvent{Router: r.name, Method: method, Duration: time.Since(start), Results: results}
+	recordRouterMetrics(ev, err)
+	if t := traceFrom(ctx); t != nil {
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		t.record(ev)
+	}
+}
+
+func (r *tracedRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	start := time.Now()
+	err := r.Routing.Provide(ctx, c, announce)
+	r.done(ctx, "Provide", start, 0, err)
+	return err
+}
+
+func (r *tracedRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	start := time.Now()
+	in := r.Routing.FindProvidersAsync(ctx, c, count)
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		var results int
+		defer func() { r.done(ctx, "FindProviders", start, results, ctx.Err()) }()
+		for p := range in {
+			results++
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *tracedRouter) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	start := time.Now()
+	ai, err := r.Routing.FindPeer(ctx, id)
+	r.done(ctx, "FindPeer", start, len(ai.Addrs), err)
+	return ai, err
+}
+
+func (r *tracedRouter) PutValue(ctx context.Context, key string, val []byte, opts ...routing.Option) error {
+	start := time.Now()
+	err := r.Routing.PutValue(ctx, key, val, opts...)
+	r.done(ctx, "PutValue", start, 0, err)
+	return err
+}
+
+func (r *tracedRouter) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	start := time.Now()
+	val, err := r.Routing.GetValue(ctx, key, opts...)
+	results := 0
+	if err == nil {
+		results = 1
+	}
+	r.done(ctx, "GetValue", start, results, err)
+	return val, err
+}
+
+func (r *tracedRouter) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	start := time.Now()
+	in, err := r.Routing.SearchValue(ctx, key, opts...)
+	if err != nil {
+		r.done(ctx, "SearchValue", start, 0, err)
+		return nil, err
+	}
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		var results int
+		defer func() { r.done(ctx, "SearchValue", start, results, ctx.Err()) }()
+		for v := range in {
+			results++
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (r *tracedProvideManyRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	start := time.Now()
+	err := r.pm.ProvideMany(ctx, keys)
+	r.done(ctx, "ProvideMany", start, 0, err)
+	return err
+}
+
+func (r *tracedProvideManyRouter) Ready() bool {
+	if rr, ok := r.pm.(routinghelpers.ReadyAbleRouter); ok {
+		return rr.Ready()
+	}
+	return true
+}
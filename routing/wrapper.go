@@ -24,6 +24,13 @@ type httpRoutingWrapper struct {
 	routing.PeerRouting
 	routing.ValueStore
 	routinghelpers.ProvideManyRouter
+
+	endpoint string
+}
+
+// Endpoint returns the URL of the delegated routing server.
+func (c *httpRoutingWrapper) Endpoint() string {
+	return c.endpoint
 }
 
 func (c *httpRoutingWrapper) Bootstrap(ctx context.Context) error {
@@ -0,0 +1,8 @@
+// Package sandbox restricts the filesystem access of the daemon to the paths
+// of its repo and of its configuration, as configured by Security.Sandbox.
+//
+// The restriction is applied with Landlock on Linux. As Landlock restricts
+// the calling thread, and the threads it starts, the daemon restricts a
+// single thread and re-executes itself from it, the new process being
+// restricted as a whole.
+package sandbox
@@ -0,0 +1,107 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// accessRead are the accesses of the read only paths.
+	accessRead = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// accessFile are the accesses which apply to a file, the others only
+	// apply to a directory.
+	accessFile = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+
+	accessABI1 = accessRead | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG | unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO | unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	accessABI2 = accessABI1 | unix.LANDLOCK_ACCESS_FS_REFER
+	accessABI3 = accessABI2 | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// handledAccess returns the filesystem accesses restricted with the version
+// abi of the Landlock ABI. The later versions restrict the same accesses as
+// the version 3.
+func handledAccess(abi int) uint64 {
+	switch {
+	case abi >= 3:
+		return accessABI3
+	case abi == 2:
+		return accessABI2
+	default:
+		return accessABI1
+	}
+}
+
+func restartRestricted(exe string, rules []Rule) error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("Landlock is not available: %w", errno)
+	}
+	handled := handledAccess(int(abi))
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	rfd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("creating the Landlock ruleset: %w", errno)
+	}
+	ruleset := int(rfd)
+	defer unix.Close(ruleset)
+	for _, r := range rules {
+		if err := addRule(ruleset, r, handled); err != nil {
+			return fmt.Errorf("allowing %s: %w", r.Path, err)
+		}
+	}
+
+	// only the current thread is restricted, it is never released as the
+	// process is replaced, or exits on errors
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("setting no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("enforcing the Landlock ruleset: %w", errno)
+	}
+	return unix.Exec(exe, os.Args, append(os.Environ(), EnvSandboxed+"=1"))
+}
+
+// addRule allows the accesses of r to the ruleset. The missing paths are
+// skipped.
+func addRule(ruleset int, r Rule, handled uint64) error {
+	fd, err := unix.Open(r.Path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ENOTDIR) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+
+	access := handled
+	if !r.Write {
+		access = accessRead
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= accessFile
+	}
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: access & handled, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
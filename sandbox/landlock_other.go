@@ -0,0 +1,9 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+func restartRestricted(string, []Rule) error {
+	return errors.New("only supported on Linux, with Landlock")
+}
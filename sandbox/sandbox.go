@@ -0,0 +1,121 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/kubo/config"
+)
+
+// EnvSandboxed is set in the environment of the daemon restarted in the
+// sandbox.
+const EnvSandboxed = "IPFS_SANDBOXED"
+
+// ErrMount is returned for the FUSE mounts of a sandboxed daemon: Landlock
+// denies mount(2) to the processes it restricts, fusermount included.
+var ErrMount = errors.New("FUSE mounts are not supported in the sandbox")
+
+// systemPaths are the system files and directories read by the daemon: the
+// DNS and TLS configurations, the time zones, and the libraries loaded when
+// it is re-executed. /dev/null is also written.
+var systemPaths = []string{
+	"/etc/resolv.conf",
+	"/etc/hosts",
+	"/etc/nsswitch.conf",
+	"/etc/gai.conf",
+	"/etc/ssl",
+	"/etc/pki",
+	"/etc/ca-certificates",
+	"/usr/share/ca-certificates",
+	"/etc/localtime",
+	"/usr/share/zoneinfo",
+	"/etc/ld.so.cache",
+	"/lib",
+	"/lib64",
+	"/usr/lib",
+	"/usr/lib64",
+	"/proc/self",
+	"/proc/stat",
+	"/sys/fs/cgroup",
+	"/dev/urandom",
+}
+
+// Rule is a file or directory the daemon may access, and whether it may
+// write it. The access to a directory extends to its content.
+type Rule struct {
+	Path  string
+	Write bool
+}
+
+// Rules returns the paths the daemon of the repo at repoPath, with cfg, may
+// access. The relative paths of the config are relative to the repo.
+func Rules(cfg *config.Config, repoPath string) []Rule {
+	resolve := func(path string) string {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(repoPath, path)
+		}
+		return filepath.Clean(path)
+	}
+
+	rules := []Rule{{Path: filepath.Clean(repoPath), Write: true}, {Path: "/dev/null", Write: true}}
+	for _, p := range systemPaths {
+		rules = append(rules, Rule{Path: p})
+	}
+
+	// the directories of the rotated log files
+	for _, f := range []*config.OptionalString{cfg.Logging.File, cfg.Gateway.AccessLog.File} {
+		if p := f.WithDefault(""); p != "" {
+			rules = append(rules, Rule{Path: filepath.Dir(resolve(p)), Write: true})
+		}
+	}
+
+	// the files read, and the directories of the denylists, which are
+	// watched
+	read := []string{
+		cfg.Gateway.TLS.CertFile.WithDefault(""),
+		cfg.Gateway.TLS.KeyFile.WithDefault(""),
+		cfg.Gateway.TLS.ACME.DNS.TSIGSecretFile,
+		cfg.Swarm.Allowlist.File.WithDefault(""),
+	}
+	if pn := cfg.Bitswap.PrivateNetwork; pn != nil {
+		read = append(read, pn.SwarmKeyFile)
+	}
+	for _, gw := range cfg.Gateway.PublicGateways {
+		if gw == nil {
+			continue
+		}
+		for _, f := range gw.Denylists {
+			read = append(read, filepath.Dir(resolve(f)))
+		}
+	}
+	for _, p := range read {
+		if p != "" {
+			rules = append(rules, Rule{Path: resolve(p)})
+		}
+	}
+
+	for _, p := range cfg.Security.Sandbox.ReadOnlyPaths {
+		rules = append(rules, Rule{Path: resolve(p)})
+	}
+	for _, p := range cfg.Security.Sandbox.ReadWritePaths {
+		rules = append(rules, Rule{Path: resolve(p), Write: true})
+	}
+	return rules
+}
+
+// Enter restarts the daemon in the sandbox of cfg, with the extra rules,
+// unless it already runs in it. It only returns when the daemon runs in the
+// sandbox, or on errors.
+func Enter(cfg *config.Config, repoPath string, extra ...Rule) error {
+	if os.Getenv(EnvSandboxed) != "" {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	rules := append(Rules(cfg, repoPath), extra...)
+	rules = append(rules, Rule{Path: exe})
+	return restartRestricted(exe, rules)
+}
@@ -0,0 +1,36 @@
+package sandbox
+
+import (
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRules(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Logging.File = config.NewOptionalString("logs/ipfs.log")
+	cfg.Gateway.TLS.CertFile = config.NewOptionalString("/etc/kubo/cert.pem")
+	cfg.Gateway.PublicGateways = map[string]*config.GatewaySpec{
+		"example.net": {Denylists: []string{"denylists/example.deny"}},
+		"example.org": nil,
+	}
+	cfg.Security.Sandbox.ReadOnlyPaths = []string{"/srv/filestore"}
+	cfg.Security.Sandbox.ReadWritePaths = []string{"datastore"}
+
+	rules := Rules(cfg, "/var/lib/ipfs/")
+	for _, r := range []Rule{
+		{Path: "/var/lib/ipfs", Write: true},
+		{Path: "/etc/resolv.conf"},
+		{Path: "/var/lib/ipfs/logs", Write: true},
+		{Path: "/etc/kubo/cert.pem"},
+		{Path: "/var/lib/ipfs/denylists"},
+		{Path: "/srv/filestore"},
+		{Path: "/var/lib/ipfs/datastore", Write: true},
+	} {
+		assert.Contains(t, rules, r)
+	}
+	for _, r := range rules {
+		assert.NotEmpty(t, r.Path)
+	}
+}
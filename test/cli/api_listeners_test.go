@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freeTCPPort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestAPIListeners(t *testing.T) {
+	t.Parallel()
+
+	t.Run("origin isolation serves only the restricted listeners", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		adminPort, userPort := freeTCPPort(t), freeTCPPort(t)
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.API.Listeners = []config.APIListener{
+				{Name: "admin", Address: fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", adminPort), AllowedPaths: []string{"/api/v0"}},
+				{Name: "user", Address: fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", userPort), AllowedPaths: []string{"/api/v0/add", "/api/v0/cat"}},
+			}
+		})
+		node.StartDaemon("--api-origin-isolation")
+		defer node.StopDaemon()
+
+		// the CLI uses the first listener
+		assert.Equal(t, fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", adminPort), node.APIAddr().String())
+		cid := node.IPFSAddStr("served to users")
+
+		user := &harness.HTTPClient{Client: http.DefaultClient, BaseURL: fmt.Sprintf("http://127.0.0.1:%d", userPort)}
+		res := user.Post("/api/v0/cat?arg="+cid, nil)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "served to users", res.Body)
+		assert.Equal(t, http.StatusOK, user.Post("/api/v0/version", nil).StatusCode)
+
+		res = user.Post("/api/v0/config/show", nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.Contains(t, res.Body, `"user" listener`)
+		assert.Equal(t, http.StatusForbidden, user.Get("/webui").StatusCode)
+	})
+
+	t.Run("origin isolation requires listeners", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("daemon", "--api-origin-isolation")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "requires API.Listeners")
+	})
+}
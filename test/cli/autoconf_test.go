@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/autoconf"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoConf(t *testing.T) {
+	t.Parallel()
+
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(sk)
+	require.NoError(t, err)
+
+	const (
+		bootstrap1 = "/ip4/127.0.0.1/tcp/4001/p2p/12D3KooWGC6TvWhfapngX6wvJHMYvKpDMXPb3ZnCZ6dMoaMtimQ5"
+		bootstrap2 = "/ip4/127.0.0.2/tcp/4001/p2p/12D3KooWGC6TvWhfapngX6wvJHMYvKpDMXPb3ZnCZ6dMoaMtimQ5"
+	)
+	var (
+		mu  sync.Mutex
+		doc []byte
+	)
+	serve := func(v *autoconf.Values) {
+		d, err := autoconf.Sign(v, sk)
+		require.NoError(t, err)
+		mu.Lock()
+		doc = d
+		mu.Unlock()
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write(doc)
+	}))
+	t.Cleanup(srv.Close)
+	serve(&autoconf.Values{Version: 1, Bootstrap: []string{bootstrap1}})
+
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.AutoConf.Enabled = config.True
+		cfg.AutoConf.URL = srv.URL
+		cfg.AutoConf.TrustedKeys = []string{id.String()}
+		cfg.Bootstrap = []string{config.AutoPlaceholder}
+	})
+
+	t.Run("diff shows the changes of the document before it is fetched", func(t *testing.T) {
+		res := node.IPFS("autoconf", "diff")
+		assert.Contains(t, res.Stdout.String(), "version 0 -> 1")
+		assert.Contains(t, res.Stdout.String(), "+ bootstrap "+bootstrap1)
+	})
+
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	status := func() map[string]any {
+		var out map[string]any
+		res := node.IPFS("autoconf", "status", "--enc=json")
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+		return out
+	}
+	t.Run("the daemon fetches the document at start", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			return status()["Values"] != nil
+		}, 10*time.Second, 100*time.Millisecond)
+		out := status()
+		assert.Equal(t, []any{bootstrap1}, out["Bootstrap"])
+		assert.Empty(t, out["LastError"])
+	})
+
+	t.Run("diff lists the values added and removed", func(t *testing.T) {
+		serve(&autoconf.Values{Version: 2, Bootstrap: []string{bootstrap2}})
+		res := node.IPFS("autoconf", "diff")
+		assert.Contains(t, res.Stdout.String(), "version 1 -> 2")
+		assert.Contains(t, res.Stdout.String(), "- bootstrap "+bootstrap1)
+		assert.Contains(t, res.Stdout.String(), "+ bootstrap "+bootstrap2)
+	})
+
+	t.Run("a document signed by another key is refused", func(t *testing.T) {
+		other, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		require.NoError(t, err)
+		d, err := autoconf.Sign(&autoconf.Values{Version: 3}, other)
+		require.NoError(t, err)
+		mu.Lock()
+		doc = d
+		mu.Unlock()
+		res := node.RunIPFS("autoconf", "diff")
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "not signed by any of AutoConf.TrustedKeys")
+	})
+}
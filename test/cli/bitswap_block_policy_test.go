@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapBlockPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := &config.BitswapBlockPolicy{
+		MaxBlockSize: config.NewOptionalString("100B"),
+		Codecs: map[string]config.BitswapCodecPolicy{
+			"dag-cbor": {Reject: config.True},
+		},
+	}
+	initNodes := func(t *testing.T, withPolicy int) harness.Nodes {
+		nodes := harness.NewT(t).NewNodes(2).Init()
+		nodes[withPolicy].UpdateConfig(func(cfg *config.Config) {
+			cfg.Bitswap.BlockPolicy = policy
+		})
+		return nodes.StartDaemons().Connect()
+	}
+	addBlocks := func(n *harness.Node) (small, large, cbor string) {
+		small = n.PipeStrToIPFS("small", "block", "put").Stdout.Trimmed()
+		large = n.PipeStrToIPFS(strings.Repeat("large", 40), "block", "put").Stdout.Trimmed()
+		cbor = n.PipeStrToIPFS(`{"cbor": true}`, "dag", "put").Stdout.Trimmed()
+		return small, large, cbor
+	}
+	fetch := func(n *harness.Node, cid string) *harness.RunResult {
+		return n.RunIPFS("block", "get", "--timeout=3s", cid)
+	}
+	policyStats := func(n *harness.Node) node.BitswapBlockPolicyStats {
+		var out struct{ Policy node.BitswapBlockPolicyStats }
+		require.NoError(t, json.Unmarshal(n.IPFS("bitswap", "stat", "--enc=json").Stdout.Bytes(), &out))
+		return out.Policy
+	}
+
+	t.Run("refused blocks are not received", func(t *testing.T) {
+		t.Parallel()
+		nodes := initNodes(t, 1)
+		defer nodes.StopDaemons()
+
+		small, large, cbor := addBlocks(nodes[0])
+		assert.NoError(t, fetch(nodes[1], small).Err)
+		assert.Error(t, fetch(nodes[1], large).Err)
+		assert.Error(t, fetch(nodes[1], cbor).Err)
+
+		st := policyStats(nodes[1])
+		assert.NotZero(t, st.ReceivedOversized)
+		assert.NotZero(t, st.ReceivedRejectedCodec)
+		assert.Contains(t, nodes[1].IPFS("bitswap", "stat").Stdout.String(), "blocks refused by policy:")
+	})
+
+	t.Run("refused blocks are not served", func(t *testing.T) {
+		t.Parallel()
+		nodes := initNodes(t, 0)
+		defer nodes.StopDaemons()
+
+		small, large, cbor := addBlocks(nodes[0])
+		assert.NoError(t, fetch(nodes[1], small).Err)
+		assert.Error(t, fetch(nodes[1], large).Err)
+		assert.Error(t, fetch(nodes[1], cbor).Err)
+
+		st := policyStats(nodes[0])
+		assert.NotZero(t, st.ServedOversized)
+		assert.NotZero(t, st.ServedRejectedCodec)
+	})
+
+	t.Run("unknown codec fails daemon startup", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init()
+		n.SetIPFSConfig("Bitswap.BlockPolicy", map[string]any{"Codecs": map[string]any{"not-a-codec": map[string]any{"Reject": true}}})
+		res := n.RunIPFS("daemon")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "invalid Bitswap.BlockPolicy.Codecs")
+	})
+}
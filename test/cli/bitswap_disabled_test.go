@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitswapDisabled(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	provider, node := nodes[0], nodes[1]
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Bitswap.Enabled = config.False
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	local := node.IPFSAddStr("stored locally")
+	assert.Equal(t, "stored locally", node.IPFS("cat", local).Stdout.String())
+
+	remote := provider.IPFSAddStr("only on the provider")
+	start := time.Now()
+	res := node.RunIPFS("cat", remote)
+	assert.Error(t, res.Err)
+	assert.Contains(t, res.Stderr.String(), "block exchange disabled")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
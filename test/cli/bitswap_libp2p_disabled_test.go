@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapLibp2pDisabled(t *testing.T) {
+	t.Parallel()
+
+	t.Run("provides over HTTP only", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init()
+		provider, node := nodes[0], nodes[1]
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Bitswap.Libp2pEnabled = config.False
+			cfg.Bitswap.HTTPAnnounce = []string{"/dns4/example.com/tcp/443/https"}
+		})
+		nodes.StartDaemons()
+		// the HTTP address of node is not dialable
+		node.Connect(provider)
+		defer nodes.StopDaemons()
+
+		assert.Contains(t, node.IPFS("id", "-f", "<addrs>").Stdout.String(), "/dns4/example.com/tcp/443/https")
+
+		local := node.IPFSAddStr("stored locally")
+		assert.Equal(t, "stored locally", node.IPFS("cat", local).Stdout.String())
+
+		res := node.RunIPFS("cat", provider.IPFSAddStr("only on the provider"))
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "block exchange disabled (Bitswap.Libp2pEnabled=false)")
+	})
+
+	t.Run("invalid HTTPAnnounce fails daemon startup", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Bitswap.HTTPAnnounce = []string{"/ip4/127.0.0.1/tcp/8080"}
+		})
+		res := node.RunIPFS("daemon")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "not an HTTP multiaddr")
+	})
+}
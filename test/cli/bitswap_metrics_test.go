@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitswapPeerMetrics(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(3).Init()
+	nodes[0].UpdateConfig(func(cfg *config.Config) {
+		cfg.Bitswap.PeerMetricsTopN = config.NewOptionalInteger(1)
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	small := nodes[0].IPFSAddStr("small")
+	large := nodes[0].IPFSAddStr(string(make([]byte, 64<<10)))
+	nodes[1].IPFS("block", "get", small)
+	nodes[2].IPFS("cat", large)
+
+	metrics := nodes[0].APIClient().Get("/debug/metrics/prometheus").Body
+	assert.Contains(t, metrics, fmt.Sprintf("ipfs_bitswap_peer_sent_bytes_total{peer=%q}", nodes[2].PeerID().String()))
+	assert.NotContains(t, metrics, nodes[1].PeerID().String())
+}
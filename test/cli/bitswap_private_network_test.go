@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapPrivateNetwork(t *testing.T) {
+	t.Parallel()
+
+	h := harness.NewT(t)
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(h.Dir, "cluster.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("/key/swarm/psk/1.0.0/\n/base16/\n"+hex.EncodeToString(key)), 0o600))
+
+	// The nodes are not connected on the public network, only through the
+	// private one.
+	nodes := h.NewNodes(2).Init()
+	addrs := make([]string, len(nodes))
+	for i := range nodes {
+		addrs[i] = fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", harness.NewRandPort())
+	}
+	for i, node := range nodes {
+		other := nodes[1-i]
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Bootstrap = nil
+			cfg.Routing.Type = config.NewOptionalString("none")
+			cfg.Bitswap.PrivateNetwork = &config.BitswapPrivateNetwork{
+				SwarmKeyFile: keyFile,
+				ListenAddrs:  []string{addrs[i]},
+				Peers: []peer.AddrInfo{{
+					ID:    other.PeerID(),
+					Addrs: []ma.Multiaddr{ma.StringCast(addrs[1-i])},
+				}},
+			}
+		})
+	}
+	nodes.StartDaemons()
+	defer nodes.StopDaemons()
+
+	assert.Empty(t, nodes[0].Peers())
+
+	data := "exchanged on the private network"
+	cid := nodes[1].IPFSAddStr(data)
+	assert.Equal(t, data, nodes[0].IPFS("cat", "--timeout=30s", cid).Stdout.String())
+
+	// bitswap commands still report on the public instance
+	nodes[0].IPFS("bitswap", "stat")
+}
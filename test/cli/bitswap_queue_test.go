@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapQueue(t *testing.T) {
+	t.Parallel()
+
+	n := harness.NewT(t).NewNode().Init().StartDaemon()
+	defer n.StopDaemon()
+
+	var stat node.BitswapQueueStat
+	require.NoError(t, json.Unmarshal(n.IPFS("bitswap", "queue", "--enc=json").Stdout.Bytes(), &stat))
+	assert.Zero(t, stat.Tasks)
+	assert.Empty(t, stat.Priorities)
+	assert.Contains(t, n.IPFS("bitswap", "queue").Stdout.String(), "tasks: 0 (0 want-block, 0 want-have)")
+
+	res := n.RunIPFS("bitswap", "queue", "--priorities=-1")
+	assert.Error(t, res.Err)
+	assert.Contains(t, res.Stderr.String(), "--priorities must not be negative")
+}
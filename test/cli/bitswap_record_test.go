@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapRecordOnly(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	recorder, client := nodes[0], nodes[1]
+	recorder.StartDaemon("--bitswap-record-only")
+	client.StartDaemon()
+	defer nodes.StopDaemons()
+	nodes.Connect()
+
+	data := "recorded, not served"
+	cid := recorder.IPFSAddStr(data)
+
+	res := client.RunIPFS("block", "get", "--timeout=2s", cid)
+	assert.Error(t, res.Err)
+
+	var st node.BitswapRecordedWants
+	require.NoError(t, json.Unmarshal(recorder.IPFS("bitswap", "recorded-wants", "--enc=json").Stdout.Bytes(), &st))
+	assert.Equal(t, 1, st.Peers)
+	assert.Equal(t, 1, st.UniqueCids)
+	assert.NotZero(t, st.LocalWants)
+	assert.WithinDuration(t, time.Now(), st.Since, time.Minute)
+
+	res = client.RunIPFS("bitswap", "recorded-wants")
+	assert.Error(t, res.Err)
+	assert.Contains(t, res.Stderr.String(), "--bitswap-record-only")
+}
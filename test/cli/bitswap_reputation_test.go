@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapReputation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("peers are scored on their deliveries across restarts", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init()
+		nodes[1].UpdateConfig(func(cfg *config.Config) {
+			cfg.Bitswap.Reputation = &config.BitswapReputation{Enabled: config.True}
+		})
+		nodes.StartDaemons().Connect()
+		defer nodes.StopDaemons()
+
+		cid := nodes[0].IPFSAddStr("reputable content")
+		nodes[1].IPFS("block", "get", cid)
+
+		reputation := func() *node.PeerReputation {
+			var out struct{ Peers []node.PeerReputation }
+			require.NoError(t, json.Unmarshal(nodes[1].IPFS("bitswap", "reputation", "--enc=json").Stdout.Bytes(), &out))
+			for _, p := range out.Peers {
+				if p.Peer == nodes[0].PeerID() {
+					return &p
+				}
+			}
+			return nil
+		}
+		rep := reputation()
+		require.NotNil(t, rep)
+		assert.Equal(t, uint64(1), rep.Delivered)
+		assert.Zero(t, rep.Garbage)
+		assert.Greater(t, rep.Score, 0.5)
+		assert.Contains(t, nodes[1].IPFS("bitswap", "reputation").Stdout.String(), nodes[0].PeerID().String())
+
+		nodes[1].StopDaemon()
+		nodes[1].StartDaemon()
+		rep = reputation()
+		require.NotNil(t, rep)
+		assert.Equal(t, uint64(1), rep.Delivered)
+	})
+
+	t.Run("reputation is disabled by default", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init().StartDaemon()
+		defer n.StopDaemon()
+
+		res := n.RunIPFS("bitswap", "reputation")
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "Bitswap.Reputation.Enabled")
+	})
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapServeStrategy(t *testing.T) {
+	t.Parallel()
+
+	initNodes := func(t *testing.T, strategy string, refreshInterval time.Duration) harness.Nodes {
+		nodes := harness.NewT(t).NewNodes(2).Init()
+		nodes[0].UpdateConfig(func(cfg *config.Config) {
+			cfg.Bitswap.ServeStrategy = config.NewOptionalString(strategy)
+			cfg.Bitswap.ServeStrategyRefreshInterval = config.NewOptionalDuration(refreshInterval)
+		})
+		return nodes.StartDaemons().Connect()
+	}
+
+	fetch := func(node *harness.Node, cid string) *harness.RunResult {
+		return node.RunIPFS("block", "get", "--timeout=5s", cid)
+	}
+
+	t.Run("pinned only serves pinned blocks", func(t *testing.T) {
+		t.Parallel()
+		nodes := initNodes(t, "pinned", time.Second)
+		defer nodes.StopDaemons()
+
+		pinned := nodes[0].IPFSAddStr("pinned content")
+		unpinned := nodes[0].IPFSAddStr("unpinned content", "--pin=false")
+		time.Sleep(2 * time.Second)
+
+		assert.NoError(t, fetch(nodes[1], pinned).Err)
+		assert.Error(t, fetch(nodes[1], unpinned).Err)
+	})
+
+	t.Run("mfs only serves blocks reachable from MFS", func(t *testing.T) {
+		t.Parallel()
+		nodes := initNodes(t, "mfs", time.Second)
+		defer nodes.StopDaemons()
+
+		inMFS := nodes[0].IPFSAddStr("mfs content", "--pin=false", "--to-files=/file")
+		pinned := nodes[0].IPFSAddStr("pinned content")
+		time.Sleep(2 * time.Second)
+
+		assert.NoError(t, fetch(nodes[1], inMFS).Err)
+		assert.Error(t, fetch(nodes[1], pinned).Err)
+	})
+
+	t.Run("changes of the pins are applied without waiting for the refresh", func(t *testing.T) {
+		t.Parallel()
+		nodes := initNodes(t, "pinned", time.Hour)
+		defer nodes.StopDaemons()
+
+		cid := nodes[0].IPFSAddStr("pinned later", "--pin=false")
+		nodes[0].IPFS("pin", "add", cid)
+		assert.Eventually(t, func() bool { return fetch(nodes[1], cid).Err == nil }, 30*time.Second, time.Second)
+
+		// the block fetched is removed, to be fetched again
+		nodes[1].IPFS("block", "rm", cid)
+		nodes[0].IPFS("pin", "rm", cid)
+		assert.Eventually(t, func() bool {
+			if fetch(nodes[1], cid).Err == nil {
+				nodes[1].IPFS("block", "rm", cid)
+				return false
+			}
+			return true
+		}, 30*time.Second, time.Second)
+	})
+
+	t.Run("unknown strategy fails daemon startup", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.SetIPFSConfig("Bitswap.ServeStrategy", "roots")
+		res := node.RunIPFS("daemon")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "unknown Bitswap.ServeStrategy")
+	})
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapSessions(t *testing.T) {
+	t.Parallel()
+
+	n := harness.NewT(t).NewNode().Init().StartDaemon()
+	defer n.StopDaemon()
+
+	missing := n.PipeStrToIPFS(string(testutils.RandomBytes(100)), "add", "-qn").Stdout.Trimmed()
+	stat := n.Runner.Run(harness.RunRequest{
+		Path:    n.IPFSBin,
+		Args:    []string{"dag", "stat", "--progress=false", missing},
+		RunFunc: (*exec.Cmd).Start,
+	})
+	defer func() { _ = stat.Cmd.Process.Kill() }()
+
+	var sessions struct{ Sessions []node.BitswapSessionStats }
+	require.Eventually(t, func() bool {
+		require.NoError(t, json.Unmarshal(n.IPFS("bitswap", "sessions", "--enc=json").Stdout.Bytes(), &sessions))
+		return len(sessions.Sessions) > 0
+	}, time.Minute, 50*time.Millisecond)
+
+	s := sessions.Sessions[0]
+	assert.True(t, s.Active)
+	assert.Equal(t, missing, s.Root.String())
+	assert.Equal(t, 1, s.Wanted)
+	assert.Zero(t, s.Blocks)
+	assert.Contains(t, n.IPFS("bitswap", "sessions").Stdout.String(), missing)
+
+	metrics := n.APIClient().Get("/debug/metrics/prometheus").Body
+	assert.Contains(t, metrics, "ipfs_bitswap_sessions_active 1")
+	assert.Contains(t, metrics, "ipfs_bitswap_sessions_wanted_blocks 1")
+
+	n.IPFS("bitswap", "sessions", "cancel", strconv.FormatUint(s.ID, 10))
+	err := stat.Cmd.Wait()
+	assert.Error(t, err, "the command using the session fails")
+
+	assert.NotContains(t, n.IPFS("bitswap", "sessions").Stdout.String(), missing)
+	res := n.RunIPFS("bitswap", "sessions", "cancel", strconv.FormatUint(s.ID, 10))
+	assert.Equal(t, 1, res.ExitCode())
+	assert.Contains(t, res.Stderr.String(), "no active bitswap session")
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitswapStatVerbose(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons()
+	defer nodes.StopDaemons()
+	nodes.Connect()
+
+	cid := nodes[0].IPFSAddStr("measured")
+	nodes[1].IPFS("cat", cid)
+
+	var st struct {
+		BlocksReceived uint64
+		Client         *node.BitswapClientStats
+	}
+	require.NoError(t, json.Unmarshal(nodes[1].IPFS("bitswap", "stat", "--verbose", "--enc=json").Stdout.Bytes(), &st))
+	assert.Equal(t, uint64(1), st.BlocksReceived)
+	require.NotNil(t, st.Client)
+	assert.Equal(t, uint64(1), st.Client.BlockLatency.Count)
+	require.NotEmpty(t, st.Client.Sessions)
+	assert.Equal(t, uint64(1), st.Client.Sessions[0].Blocks)
+	assert.Zero(t, st.Client.Sessions[0].DupBlocks)
+
+	out := nodes[1].IPFS("bitswap", "stat", "--verbose").Stdout.String()
+	assert.Contains(t, out, "want to block latency [1, avg")
+	assert.Contains(t, out, "1 blocks, 0 dup blocks (0.0%)")
+
+	var short struct{ Client *node.BitswapClientStats }
+	require.NoError(t, json.Unmarshal(nodes[1].IPFS("bitswap", "stat", "--enc=json").Stdout.Bytes(), &short))
+	assert.Nil(t, short.Client)
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitswapPersistWantlist(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	provider, node := nodes[0], nodes[1]
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Bitswap.PersistWantlist = config.True
+	})
+	nodes.StartDaemons()
+	defer nodes.StopDaemons()
+
+	cid := provider.IPFSAddStr("wanted across restarts")
+
+	// Leave a want outstanding while the daemon shuts down.
+	go node.RunIPFS("block", "get", cid)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(node.IPFS("bitswap", "wantlist").Stdout.String(), cid)
+	}, 10*time.Second, 100*time.Millisecond)
+	node.StopDaemon()
+
+	node.StartDaemon()
+	assert.Contains(t, node.IPFS("bitswap", "wantlist").Stdout.String(), cid)
+
+	node.Connect(provider)
+	assert.Eventually(t, func() bool {
+		return node.RunIPFS("block", "stat", "--offline", cid).Err == nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	res := node.IPFS("bitswap", "reprovide-wantlist")
+	assert.Equal(t, "retrieved 0 of 0 persisted wants", res.Stdout.Trimmed())
+}
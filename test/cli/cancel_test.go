@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancel(t *testing.T) {
+	t.Parallel()
+
+	n := harness.NewT(t).NewNode().Init().StartDaemon()
+	defer n.StopDaemon()
+
+	for _, args := range [][]string{
+		{"dag", "stat", "--progress=false"},
+		{"block", "get"},
+	} {
+		args := args
+		t.Run("ipfs cancel ends ipfs "+args[0]+" "+args[1], func(t *testing.T) {
+			missing := n.PipeStrToIPFS(string(testutils.RandomBytes(100)), "add", "-qn").Stdout.Trimmed()
+			fetch := n.Runner.Run(harness.RunRequest{
+				Path:    n.IPFSBin,
+				Args:    append(args, missing),
+				RunFunc: (*exec.Cmd).Start,
+			})
+			defer func() { _ = fetch.Cmd.Process.Kill() }()
+
+			require.Eventually(t, func() bool {
+				return n.RunIPFS("cancel", missing).ExitCode() == 0
+			}, time.Minute, 50*time.Millisecond)
+			assert.Error(t, fetch.Cmd.Wait())
+
+			res := n.RunIPFS("cancel", missing)
+			assert.Equal(t, 1, res.ExitCode())
+			assert.Contains(t, res.Stderr.String(), "no retrieval of "+missing+" in progress")
+		})
+	}
+}
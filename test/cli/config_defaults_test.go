@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigDefaults(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Internal.Bitswap = &config.InternalBitswap{
+			EngineTaskWorkerCount: *config.NewOptionalInteger(4),
+		}
+	})
+
+	t.Run("annotates the defaults in force", func(t *testing.T) {
+		out := node.IPFS("config", "defaults").Stdout.String()
+		assert.Contains(t, out, "Internal.Bitswap.EngineBlockstoreWorkerCount = 128 (default)\n")
+		assert.Contains(t, out, "Internal.Bitswap.EngineTaskWorkerCount = 4\n")
+		assert.Contains(t, out, "Swarm.ConnMgr.HighWater = 96 (default)\n")
+		assert.NotContains(t, out, "PrivKey")
+	})
+
+	t.Run("lists the defaulted keys in JSON", func(t *testing.T) {
+		var out struct {
+			Config   map[string]interface{}
+			Defaults []string
+		}
+		require.NoError(t, json.Unmarshal(node.IPFS("config", "defaults", "--enc=json").Stdout.Bytes(), &out))
+		assert.Contains(t, out.Defaults, "Internal.Bitswap.TaskWorkerCount")
+		assert.NotContains(t, out.Defaults, "Internal.Bitswap.EngineTaskWorkerCount")
+		bitswap := out.Config["Internal"].(map[string]interface{})["Bitswap"].(map[string]interface{})
+		assert.Equal(t, float64(8), bitswap["TaskWorkerCount"])
+	})
+}
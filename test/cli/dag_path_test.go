@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDagPaths(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init().StartDaemon()
+	defer node.StopDaemon()
+
+	leaf := node.PipeStrToIPFS(`{"d": 1, "e": [10, 20]}`, "dag", "put").Stdout.Trimmed()
+	root := node.PipeStrToIPFS(`{"a": {"b": [{"c": {"/": "`+leaf+`"}}, 5]}, "we/ird": 1, "100%": 2}`, "dag", "put").Stdout.Trimmed()
+	client := node.GatewayClient()
+
+	for _, tc := range []struct {
+		path     string
+		value    string
+		resolved string
+	}{
+		{path: "/a/b/1", value: "5", resolved: "/ipfs/" + root + "/a/b/1"},
+		{path: "/a/b/0/c", value: `{"d":1,"e":[10,20]}`, resolved: "/ipfs/" + leaf},
+		{path: "/a/b/0/c/e/1", value: "20", resolved: "/ipfs/" + leaf + "/e/1"},
+		{path: "/we%2Fird", value: "1", resolved: "/ipfs/" + root + "/we%2Fird"},
+		{path: "/100%25", value: "2", resolved: "/ipfs/" + root + "/100%25"},
+	} {
+		p := "/ipfs/" + root + tc.path
+		t.Run(p, func(t *testing.T) {
+			assert.Equal(t, tc.value, node.IPFS("dag", "get", p).Stdout.Trimmed())
+			assert.Equal(t, tc.resolved, node.IPFS("resolve", p).Stdout.Trimmed())
+
+			res := client.Get(p + "?format=dag-json")
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, tc.value, res.Body)
+			assert.Equal(t, "application/vnd.ipld.dag-json", res.Headers.Get("Content-Type"))
+		})
+	}
+
+	for _, tc := range []struct {
+		path   string
+		reason string
+	}{
+		{path: "/a/x", reason: `/a/x does not exist: no key "x" in the map`},
+		{path: "/a/b/2", reason: "/a/b/2 does not exist: index 2 is out of the list of length 2"},
+		{path: "/a/b/first", reason: `/a/b/first does not exist: "first" is not an index of the list`},
+		{path: "/a/b/1/x", reason: "/a/b/1/x does not exist: int values have no segments"},
+	} {
+		p := "/ipfs/" + root + tc.path
+		t.Run(p+" does not exist", func(t *testing.T) {
+			for _, cmd := range [][]string{{"dag", "get", p}, {"dag", "resolve", p}, {"resolve", p}} {
+				res := node.RunIPFS(cmd...)
+				assert.Error(t, res.Err)
+				assert.Contains(t, res.Stderr.String(), root+tc.reason)
+			}
+
+			res := client.Get(p + "?format=dag-json")
+			assert.Equal(t, http.StatusNotFound, res.StatusCode)
+			assert.Contains(t, res.Body, root+tc.reason)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	dagcmd "github.com/ipfs/kubo/core/commands/dag"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDagPrefetch(t *testing.T) {
+	t.Parallel()
+
+	h := harness.NewT(t)
+	nodes := h.NewNodes(2).Init().StartDaemons()
+	defer nodes.StopDaemons()
+	nodes.Connect()
+
+	h.WriteFile("dir/sub/a.txt", "a")
+	h.WriteFile("dir/sub/b.txt", "b")
+	h.WriteFile("dir/c.txt", "c")
+	root := nodes[0].IPFS("add", "-r", "-Q", filepath.Join(h.Dir, "dir")).Stdout.Trimmed()
+	a := nodes[0].IPFS("add", "-Q", "--only-hash", filepath.Join(h.Dir, "dir", "sub", "a.txt")).Stdout.Trimmed()
+	c := nodes[0].IPFS("add", "-Q", "--only-hash", filepath.Join(h.Dir, "dir", "c.txt")).Stdout.Trimmed()
+
+	prefetch := func(args ...string) dagcmd.DagPrefetchOutput {
+		var out dagcmd.DagPrefetchOutput
+		res := nodes[1].IPFS(append([]string{"dag", "prefetch", "--enc=json"}, args...)...)
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+		return out
+	}
+	local := func(cid string) bool {
+		return nodes[1].RunIPFS("block", "stat", "--offline", cid).ExitCode() == 0
+	}
+
+	out := prefetch("--depth=1", root)
+	assert.Equal(t, dagcmd.DagPrefetchOutput{Blocks: 3, Size: out.Size, Depth: 1}, out)
+	assert.True(t, local(c))
+	assert.False(t, local(a))
+
+	out = prefetch("--sessions=2", root)
+	assert.Equal(t, 5, out.Blocks)
+	assert.Equal(t, 2, out.Depth)
+	assert.True(t, local(a))
+	assert.NotContains(t, nodes[1].IPFS("pin", "ls", "--type=recursive").Stdout.String(), root)
+
+	res := nodes[1].RunIPFS("dag", "prefetch", "--sessions=0", root)
+	assert.Equal(t, 1, res.ExitCode())
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDagQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fails without DagIndex.Enabled", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("dag", "query", "title:bridge")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "DagIndex.Enabled")
+	})
+
+	t.Run("finds the documents by the words of their fields", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.DagIndex.Enabled = config.True
+			cfg.DagIndex.Fields = []string{"title", "authors/*/name", "year", "tags"}
+		})
+
+		put := func(doc string) string {
+			return node.PipeStrToIPFS(doc, "dag", "put").Stdout.Trimmed()
+		}
+		bridges := put(`{"title": "Bridge Design", "authors": [{"name": "Othmar Ammann"}], "year": 1931, "tags": ["steel", "suspension"]}`)
+		tunnels := put(`{"title": "Tunnel design", "authors": [{"name": "Marc Brunel"}, {"name": "Othmar Ammann"}], "year": 1843}`)
+		put(`{"name": "Bridge", "title": 42}`)
+		query := func(terms ...string) []string {
+			return node.IPFS(append([]string{"dag", "query"}, terms...)...).Stdout.Lines()
+		}
+
+		assert.Equal(t, []string{bridges}, query("title:bridge"))
+		assert.ElementsMatch(t, []string{bridges, tunnels}, query("title:DESIGN"))
+		assert.ElementsMatch(t, []string{bridges, tunnels}, query("authors/*/name:othmar ammann"))
+		assert.Equal(t, []string{tunnels}, query("authors/*/name:brunel", "title:design"))
+		assert.Equal(t, []string{bridges}, query("steel"))
+		assert.Equal(t, []string{bridges}, query("year:1931"))
+		assert.Empty(t, query("title:bridge", "year:1843"))
+
+		t.Run("indexes the documents imported", func(t *testing.T) {
+			car := node.IPFS("dag", "export", bridges).Stdout.Bytes()
+			other := harness.NewT(t).NewNode().Init()
+			other.UpdateConfig(func(cfg *config.Config) {
+				cfg.DagIndex.Enabled = config.True
+				cfg.DagIndex.Fields = []string{"title"}
+			})
+			require.Empty(t, other.IPFS("dag", "query", "bridge").Stdout.Lines())
+			other.PipeToIPFS(bytes.NewReader(car), "dag", "import")
+			assert.Equal(t, []string{bridges}, other.IPFS("dag", "query", "bridge").Stdout.Lines())
+		})
+	})
+}
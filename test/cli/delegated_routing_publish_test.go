@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/routing/http/server"
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/assert"
+)
+
+// publishingRouter fails the first provide it receives, and records the
+// provided CIDs and the published IPNS names.
+type publishingRouter struct {
+	mu       sync.Mutex
+	attempts int
+	provided map[cid.Cid]bool
+	names    map[string]bool
+}
+
+func (r *publishingRouter) FindProviders(ctx context.Context, key cid.Cid, limit int) (iter.ResultIter[types.Record], error) {
+	return iter.FromSlice([]iter.Result[types.Record]{}), nil
+}
+
+// nolint deprecated
+func (r *publishingRouter) ProvideBitswap(ctx context.Context, req *server.BitswapWriteProvideRequest) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if r.attempts == 1 {
+		return 0, errors.New("unavailable")
+	}
+	for _, c := range req.Keys {
+		r.provided[c] = true
+	}
+	return 0, nil
+}
+
+func (r *publishingRouter) FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[*types.PeerRecord], error) {
+	return iter.FromSlice([]iter.Result[*types.PeerRecord]{}), nil
+}
+
+func (r *publishingRouter) GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *publishingRouter) PutIPNS(ctx context.Context, name ipns.Name, rec *ipns.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names[name.String()] = true
+	return nil
+}
+
+func (r *publishingRouter) isProvided(c cid.Cid) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.provided[c]
+}
+
+func (r *publishingRouter) isPublished(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.names[name]
+}
+
+func TestDelegatedRoutingPublishing(t *testing.T) {
+	t.Parallel()
+
+	r := &publishingRouter{provided: map[cid.Cid]bool{}, names: map[string]bool{}}
+	srv := httptest.NewServer(server.Handler(r))
+	t.Cleanup(srv.Close)
+
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Routing.Type = config.NewOptionalString("delegated")
+		cfg.Routing.DelegatedRouters = []string{srv.URL}
+		cfg.Routing.DelegatedPublishing = config.True
+	})
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	t.Run("provider records are retried until stored", func(t *testing.T) {
+		c, err := cid.Decode(node.IPFSAddStr(time.Now().String()))
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return r.isProvided(cid.NewCidV1(cid.Raw, c.Hash()))
+		}, 30*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("IPNS records are published", func(t *testing.T) {
+		c := node.IPFSAddStr("ipns")
+		node.IPFS("name", "publish", "--allow-offline", c)
+		name, err := ipns.NameFromString(node.PeerID().String())
+		assert.NoError(t, err)
+		assert.True(t, r.isPublished(name.String()))
+	})
+}
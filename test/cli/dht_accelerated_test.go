@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceleratedDHTClientProvideOnly(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes[0].IPFS("config", "Routing.AcceleratedDHTClient", "provide-only")
+	assert.Equal(t, "provide-only", nodes[0].IPFS("config", "Routing.AcceleratedDHTClient").Stdout.Trimmed())
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	// the lookups use the standard client, which also queries the LAN DHT
+	c := nodes[1].IPFSAddStr(string(testutils.RandomBytes(100)))
+	nodes[1].IPFS("routing", "provide", c)
+	res := nodes[0].IPFS("routing", "findprovs", "--num-providers=1", c)
+	assert.Equal(t, nodes[1].PeerID().String(), res.Stdout.Trimmed())
+
+	assert.Contains(t, nodes[0].IPFS("stats", "dht", "lan").Stdout.String(), nodes[1].PeerID().String())
+}
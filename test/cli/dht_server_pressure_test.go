@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHTServerModePressure(t *testing.T) {
+	t.Parallel()
+
+	protocols := func(n *harness.Node) []string {
+		var id struct{ Protocols []string }
+		require.NoError(t, json.Unmarshal(n.IPFS("id").Stdout.Bytes(), &id))
+		return id.Protocols
+	}
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes.ForEachPar(func(n *harness.Node) {
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Routing.Type = config.NewOptionalString("dhtserver")
+			cfg.Routing.ServerModePressure = config.ServerModePressure{
+				Enabled:       config.True,
+				CheckInterval: config.NewOptionalDuration(100 * time.Millisecond),
+			}
+		})
+	})
+	// the first node is always short on file descriptors
+	nodes[0].UpdateConfig(func(cfg *config.Config) {
+		cfg.Routing.ServerModePressure.MaxFileDescriptors = config.NewOptionalInteger(1)
+	})
+	nodes.StartDaemons()
+	defer nodes.StopDaemons()
+
+	assert.Eventually(t, func() bool {
+		metrics := nodes[0].APIClient().Get("/debug/metrics/prometheus").Body
+		return strings.Contains(metrics, "\nipfs_dht_server_demoted 1\n")
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.NotContains(t, protocols(nodes[0]), "/ipfs/kad/1.0.0")
+	assert.Contains(t, protocols(nodes[1]), "/ipfs/kad/1.0.0")
+	assert.Contains(t, nodes[0].APIClient().Get("/debug/metrics/prometheus").Body, `ipfs_dht_server_demotions_total{resource="fds"} 1`)
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagSlowLog(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requires Logging.SlowLog.Enabled", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init().StartDaemon()
+		defer node.StopDaemon()
+
+		res := node.RunIPFS("diag", "slowlog")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "Logging.SlowLog.Enabled")
+	})
+
+	t.Run("records the slow operations", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init()
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Logging.SlowLog = config.LoggingSlowLog{
+				Enabled:             config.True,
+				APIThreshold:        config.NewOptionalDuration(200 * time.Millisecond),
+				BlockFetchThreshold: config.NewOptionalDuration(200 * time.Millisecond),
+				StackSampleRate:     config.NewOptionalInteger(1),
+			}
+		})
+		n.StartDaemon()
+		defer n.StopDaemon()
+
+		// a block that nobody has
+		missing := "bafkreign3mpmu2qvrnbb7gha4lslml7t5opmyro2flnuwp6poj2sxyjtuy"
+		n.RunIPFS("block", "get", "--timeout=1s", missing)
+		n.IPFS("id")
+
+		list := func(args ...string) []node.SlowOperation {
+			res := n.IPFS(append([]string{"diag", "slowlog", "--enc=json"}, args...)...)
+			var out struct{ Operations []node.SlowOperation }
+			require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+			return out.Operations
+		}
+
+		ops := list("--kind=blockfetch")
+		require.Len(t, ops, 1)
+		assert.Equal(t, "GetBlock "+missing, ops[0].Operation)
+		assert.GreaterOrEqual(t, ops[0].Duration, 200*time.Millisecond)
+		assert.NotEmpty(t, ops[0].Error)
+		assert.Contains(t, ops[0].Stacks, "(*slowExchange).GetBlock")
+
+		ops = list("--kind=api")
+		require.Len(t, ops, 1)
+		assert.Equal(t, "POST /api/v0/block/get", ops[0].Operation)
+		// the stacks of the request include those of the block fetch
+		assert.Contains(t, ops[0].Stacks, "(*slowExchange).GetBlock")
+
+		assert.Len(t, list("--since=1h"), 2)
+		assert.Empty(t, list("--since=1ns"))
+
+		text := n.IPFS("diag", "slowlog", "--stacks").Stdout.String()
+		assert.Contains(t, text, "blockfetch")
+		assert.Contains(t, text, "    1 @ 0x")
+	})
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatures(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lists the experimental features", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.IPFS("config", "--json", "Experimental.FilestoreEnabled", "true")
+
+		var out struct {
+			Features []struct {
+				Name      string
+				Stability string
+				Enabled   bool
+				Requires  []string
+			}
+		}
+		require.NoError(t, json.Unmarshal(node.IPFS("features", "ls", "--enc=json").Stdout.Bytes(), &out))
+		features := map[string]bool{}
+		for _, f := range out.Features {
+			features[f.Name] = f.Enabled
+			if f.Name == "P2pHttpProxy" {
+				assert.Equal(t, []string{"Libp2pStreamMounting"}, f.Requires)
+			}
+		}
+		assert.True(t, features["FilestoreEnabled"])
+		assert.Contains(t, features, "Libp2pStreamMounting")
+		assert.False(t, features["Libp2pStreamMounting"])
+	})
+
+	t.Run("checks the requirements of the features toggled", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+
+		res := node.RunIPFS("features", "enable", "P2pHttpProxy")
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "requires Experimental.Libp2pStreamMounting")
+
+		node.IPFS("features", "enable", "libp2pstreammounting")
+		res = node.IPFS("features", "enable", "P2pHttpProxy")
+		assert.Equal(t, "Experimental.P2pHttpProxy enabled, restart the daemon for the change to take effect", res.Stdout.Trimmed())
+
+		res = node.RunIPFS("features", "disable", "Libp2pStreamMounting")
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "Experimental.P2pHttpProxy requires Experimental.Libp2pStreamMounting")
+
+		res = node.RunIPFS("features", "enable", "ShardingEnabled")
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "was removed")
+	})
+
+	t.Run("toggles runtime features while the daemon runs", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init().StartDaemon()
+		defer node.StopDaemon()
+
+		res := node.RunIPFS("p2p", "ls")
+		assert.Contains(t, res.Stderr.String(), "libp2p stream mounting not enabled")
+
+		res = node.IPFS("features", "enable", "Libp2pStreamMounting")
+		assert.Equal(t, "Experimental.Libp2pStreamMounting enabled", res.Stdout.Trimmed())
+		node.IPFS("p2p", "ls")
+	})
+}
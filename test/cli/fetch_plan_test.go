@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchPlan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fails offline", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("fetch", "plan", testutils.CIDEmptyDir)
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "this command must be run in online mode")
+	})
+
+	t.Run("spreads the subtrees over the peers that have them", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(3).Init().StartDaemons().Connect()
+		defer nodes.StopDaemons()
+
+		// the leaves are larger than the blocks sent instead of a HAVE
+		data := string(testutils.RandomBytes(8000))
+		root := nodes[0].IPFSAddStr(data, "--chunker=size-2000", "--raw-leaves")
+		nodes[1].IPFSAddStr(data, "--chunker=size-2000", "--raw-leaves", "--pin=false")
+		leaves := nodes[0].IPFS("refs", root).Stdout.Lines()
+		require.Len(t, leaves, 4)
+		nodes[1].IPFS("block", "rm", leaves[0], leaves[1])
+
+		res := nodes[2].IPFS("fetch", "plan", "--enc=json", root)
+		var plan struct {
+			RootPeers []string
+			Subtrees  []struct {
+				Size  uint64
+				Peers []string
+				Peer  string
+			}
+			Peers []struct {
+				ID              string
+				Subtrees        int
+				PlannedSubtrees int
+			}
+			Probed       int
+			Parallelism  int
+			TotalBytes   uint64
+			MissingBytes uint64
+		}
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &plan))
+		assert.Equal(t, 2, plan.Probed)
+		assert.ElementsMatch(t, []string{nodes[0].PeerID().String(), nodes[1].PeerID().String()}, plan.RootPeers)
+		assert.Equal(t, 2, plan.Parallelism)
+		assert.Zero(t, plan.MissingBytes)
+		assert.Greater(t, plan.TotalBytes, uint64(8000))
+		require.Len(t, plan.Subtrees, 4)
+		assert.Equal(t, []string{nodes[0].PeerID().String()}, plan.Subtrees[0].Peers)
+		assert.Equal(t, nodes[0].PeerID().String(), plan.Subtrees[0].Peer)
+		assert.Len(t, plan.Subtrees[3].Peers, 2)
+		require.Len(t, plan.Peers, 2)
+		for _, p := range plan.Peers {
+			assert.Equal(t, 2, p.PlannedSubtrees, "the subtrees are spread evenly")
+		}
+
+		for _, leaf := range leaves {
+			res := nodes[2].RunIPFS("block", "stat", "--offline", leaf)
+			assert.Error(t, res.Err, "the plan fetched a leaf")
+		}
+
+		out := nodes[2].IPFS("fetch", "plan", "-v", root).Stdout.String()
+		assert.Contains(t, out, "Parallelism:  2 peers")
+		assert.Contains(t, out, leaves[3])
+	})
+}
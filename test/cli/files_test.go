@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesLsRecursive(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init().StartDaemon()
+	defer node.StopDaemon()
+
+	node.IPFS("files", "mkdir", "-p", "/inv/a/b")
+	node.IPFS("files", "mkdir", "-p", "/inv/c")
+	for path, data := range map[string]string{
+		"/inv/top":     "12345",
+		"/inv/a/one":   "123",
+		"/inv/a/b/two": "1234567",
+	} {
+		node.PipeStrToIPFS(data, "files", "write", "--create", "--raw-leaves", path)
+	}
+
+	t.Run("lists the whole tree", func(t *testing.T) {
+		t.Parallel()
+		lines := strings.Fields(node.IPFS("files", "ls", "--recursive", "/inv").Stdout.String())
+		assert.ElementsMatch(t, []string{"a", "a/b", "a/b/two", "a/one", "c", "top"}, lines)
+	})
+
+	t.Run("streams cumulative sizes as NDJSON", func(t *testing.T) {
+		t.Parallel()
+		out := node.IPFS("files", "ls", "--recursive", "--du", "--enc=json", "/inv").Stdout.String()
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Len(t, lines, 6)
+
+		sizes := make(map[string]int64)
+		order := make(map[string]int)
+		for i, line := range lines {
+			var entry struct {
+				Entries []struct {
+					Name string
+					Size int64
+				}
+			}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			require.Len(t, entry.Entries, 1)
+			sizes[entry.Entries[0].Name] = entry.Entries[0].Size
+			order[entry.Entries[0].Name] = i
+		}
+		assert.Equal(t, map[string]int64{"a": 10, "a/b": 7, "a/b/two": 7, "a/one": 3, "c": 0, "top": 5}, sizes)
+		assert.Less(t, order["a/b/two"], order["a/b"])
+		assert.Less(t, order["a/b"], order["a"])
+	})
+
+	t.Run("du without recursive only lists the directory", func(t *testing.T) {
+		t.Parallel()
+		out := node.IPFS("files", "ls", "--du", "/inv").Stdout.String()
+		assert.Equal(t, "10\ta\n0\tc\n5\ttop\n", out)
+	})
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayAccessLog(t *testing.T) {
+	t.Parallel()
+
+	type record struct {
+		Client          string
+		Method          string
+		Host            string
+		Path            string
+		Status          int
+		Root            string
+		Bytes           int64
+		Cache           string
+		BlocksFetched   int
+		Peers           []string
+		TimeToFirstByte int64
+		Duration        int64
+	}
+	readRecords := func(t *testing.T, path string) []record {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		var records []record
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var rec record
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+			records = append(records, rec)
+		}
+		require.NoError(t, scanner.Err())
+		return records
+	}
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	gw, provider := nodes[0], nodes[1]
+	gw.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.AccessLog.Enabled = config.True
+		cfg.Gateway.PublicGateways = map[string]*config.GatewaySpec{
+			"example.com": {Paths: []string{"/ipfs"}, UseSubdomains: true},
+		}
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	const content = "served through the gateway"
+	cid := provider.IPFSAddStr(content, "--cid-version=1")
+	client := gw.GatewayClient()
+	path := filepath.Join(gw.Dir, config.DefaultGatewayAccessLogFile)
+
+	res := client.Get("/ipfs/" + cid)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	res = client.Get("/", func(r *http.Request) { r.Host = cid + ".ipfs.example.com" })
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	res = client.Get("/ipfs/not-a-cid")
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	records := readRecords(t, path)
+	require.Len(t, records, 3)
+
+	t.Run("blocks fetched from the network are attributed to their peers", func(t *testing.T) {
+		rec := records[0]
+		assert.Equal(t, "127.0.0.1", rec.Client)
+		assert.Equal(t, http.MethodGet, rec.Method)
+		assert.Equal(t, "/ipfs/"+cid, rec.Path)
+		assert.Equal(t, http.StatusOK, rec.Status)
+		assert.Equal(t, cid, rec.Root)
+		assert.Equal(t, int64(len(content)), rec.Bytes)
+		assert.Equal(t, "miss", rec.Cache)
+		assert.Positive(t, rec.BlocksFetched)
+		assert.Equal(t, []string{provider.PeerID().String()}, rec.Peers)
+		assert.Positive(t, rec.TimeToFirstByte)
+		assert.GreaterOrEqual(t, rec.Duration, rec.TimeToFirstByte)
+	})
+
+	t.Run("subdomain requests are logged once, as requested", func(t *testing.T) {
+		rec := records[1]
+		assert.Equal(t, cid+".ipfs.example.com", rec.Host)
+		assert.Equal(t, "/", rec.Path)
+		assert.Equal(t, cid, rec.Root)
+		assert.Equal(t, "hit", rec.Cache)
+		assert.Zero(t, rec.BlocksFetched)
+		assert.Empty(t, rec.Peers)
+	})
+
+	t.Run("failed requests are logged", func(t *testing.T) {
+		rec := records[2]
+		assert.Equal(t, http.StatusBadRequest, rec.Status)
+		assert.Empty(t, rec.Root)
+	})
+
+	t.Run("the bitswap commands reach bitswap through the access log", func(t *testing.T) {
+		res := gw.RunIPFS("bitswap", "stat")
+		assert.Equal(t, 0, res.ExitCode(), res.Stderr.String())
+		assert.Contains(t, res.Stdout.String(), "bitswap status")
+		res = gw.RunIPFS("bitswap", "wantlist")
+		assert.Equal(t, 0, res.ExitCode(), res.Stderr.String())
+	})
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayAuthorization(t *testing.T) {
+	t.Parallel()
+
+	// the endpoint allows requests with a token, redirects the ones asking
+	// for a login, and asks for a payment otherwise
+	var calls atomic.Int64
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Host          string
+			URI           string
+			Authorization string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Host != "paid.example.com" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		switch {
+		case req.Authorization == "Bearer token":
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(req.URI, "?login"):
+			http.Redirect(w, r, "https://login.example.com/", http.StatusFound)
+		default:
+			http.Error(w, "pay to read", http.StatusPaymentRequired)
+		}
+	}))
+	t.Cleanup(endpoint.Close)
+
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.PublicGateways = map[string]*config.GatewaySpec{
+			"paid.example.com": {
+				Paths:         []string{"/ipfs"},
+				Authorization: &config.GatewayAuthorization{Endpoint: endpoint.URL},
+			},
+			"free.example.com": {
+				Paths: []string{"/ipfs"},
+			},
+		}
+	})
+	node.StartDaemon()
+	defer node.StopDaemon()
+	cid := node.IPFSAddStr("paid content")
+
+	withHost := func(host string) func(*http.Request) {
+		return func(r *http.Request) { r.Host = host }
+	}
+	client := node.GatewayClient().DisableRedirects()
+
+	t.Run("requests without a token are denied", func(t *testing.T) {
+		res := client.Get("/ipfs/"+cid, withHost("paid.example.com"))
+		assert.Equal(t, http.StatusPaymentRequired, res.StatusCode)
+		assert.Contains(t, res.Body, "pay to read")
+	})
+
+	t.Run("requests with a token are served", func(t *testing.T) {
+		res := client.Get("/ipfs/"+cid, withHost("paid.example.com"), client.WithHeader("Authorization", "Bearer token"))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "paid content", res.Body)
+
+		// the decision is cached
+		before := calls.Load()
+		res = client.Get("/ipfs/"+cid, withHost("paid.example.com"), client.WithHeader("Authorization", "Bearer token"))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, before, calls.Load())
+	})
+
+	t.Run("requests can be redirected", func(t *testing.T) {
+		res := client.Get("/ipfs/"+cid+"?login", withHost("paid.example.com"))
+		require.Equal(t, http.StatusFound, res.StatusCode)
+		assert.Equal(t, "https://login.example.com/", res.Headers.Get("Location"))
+	})
+
+	t.Run("other gateways are not authorized", func(t *testing.T) {
+		before := calls.Load()
+		res := client.Get("/ipfs/"+cid, withHost("free.example.com"))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, before, calls.Load())
+	})
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayCARScopes(t *testing.T) {
+	t.Parallel()
+
+	const chunkSize = 256 << 10
+	h := harness.NewT(t)
+	node := h.NewNode().Init().StartDaemon("--offline")
+	defer node.StopDaemon()
+	client := node.GatewayClient()
+
+	data := testutils.RandomBytes(16 * chunkSize)
+	require.NoError(t, os.Mkdir(filepath.Join(node.Dir, "dir"), 0o755))
+	node.WriteBytes("dir/file", data)
+	dirCid := node.IPFS("add", "-r", "-Q", "--cid-version=1", fmt.Sprintf("--chunker=size-%d", chunkSize), filepath.Join(node.Dir, "dir")).Stdout.Trimmed()
+	fileCid := node.IPFS("resolve", "-r", "/ipfs/"+dirCid+"/file").Stdout.Trimmed()[len("/ipfs/"):]
+
+	// the CIDs of the leaves of the file, in order
+	var leaves []string
+	for _, l := range bytes.Split(bytes.TrimSpace(node.IPFS("refs", fileCid).Stdout.Bytes()), []byte("\n")) {
+		leaves = append(leaves, string(l))
+	}
+	require.Len(t, leaves, 16)
+
+	carBlocks := func(t *testing.T, url string) []string {
+		resp := client.Get(url, func(r *http.Request) {
+			r.Header.Set("Accept", "application/vnd.ipld.car; version=1; order=dfs; dups=n")
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode, resp.Body)
+		br, err := carv2.NewBlockReader(bytes.NewReader([]byte(resp.Body)))
+		require.NoError(t, err)
+		var blocks []string
+		for {
+			blk, err := br.Next()
+			if err != nil {
+				break
+			}
+			blocks = append(blocks, blk.Cid().String())
+		}
+		return blocks
+	}
+
+	t.Run("dag-scope=block returns the terminal block", func(t *testing.T) {
+		blocks := carBlocks(t, "/ipfs/"+dirCid+"/file?dag-scope=block")
+		assert.Equal(t, []string{dirCid, fileCid}, blocks)
+	})
+
+	t.Run("dag-scope=all returns the whole DAG", func(t *testing.T) {
+		blocks := carBlocks(t, "/ipfs/"+fileCid+"?dag-scope=all")
+		assert.Equal(t, append([]string{fileCid}, leaves...), blocks)
+	})
+
+	t.Run("entity-bytes returns the blocks of the range", func(t *testing.T) {
+		blocks := carBlocks(t, fmt.Sprintf("/ipfs/%s/file?dag-scope=entity&entity-bytes=%d:%d", dirCid, 4*chunkSize, 5*chunkSize+10))
+		assert.Equal(t, []string{dirCid, fileCid, leaves[4], leaves[5]}, blocks)
+	})
+
+	t.Run("entity-bytes with a negative offset returns the end of the file", func(t *testing.T) {
+		blocks := carBlocks(t, fmt.Sprintf("/ipfs/%s?dag-scope=entity&entity-bytes=-%d:*", fileCid, chunkSize))
+		assert.Equal(t, []string{fileCid, leaves[15]}, blocks)
+	})
+
+	t.Run("entity-bytes past the end of the file returns its root", func(t *testing.T) {
+		blocks := carBlocks(t, fmt.Sprintf("/ipfs/%s?dag-scope=entity&entity-bytes=%d:*", fileCid, 17*chunkSize))
+		assert.Equal(t, []string{fileCid}, blocks)
+	})
+
+	t.Run("invalid parameters are rejected", func(t *testing.T) {
+		for _, params := range []string{"dag-scope=unknown", "entity-bytes=10:5", "entity-bytes=x:*"} {
+			resp := client.Get("/ipfs/" + fileCid + "?format=car&" + params)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, params)
+		}
+	})
+}
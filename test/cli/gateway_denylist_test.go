@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayDenylists(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	denylist := filepath.Join(node.Dir, "example.deny")
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.PublicGateways = map[string]*config.GatewaySpec{
+			"example.com": {Paths: []string{"/ipfs", "/ipns"}, Denylists: []string{"example.deny"}},
+			"other.com":   {Paths: []string{"/ipfs", "/ipns"}},
+		}
+	})
+
+	dir := filepath.Join(node.Dir, "site")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "page.html"), []byte("page"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.html"), []byte("other"), 0o644))
+	require.NoError(t, os.WriteFile(denylist, nil, 0o644))
+	cidSite := node.IPFS("add", "-r", "-Q", "--offline", dir).Stdout.Trimmed()
+	cidPage := node.IPFS("add", "-Q", "--offline", filepath.Join(dir, "page.html")).Stdout.Trimmed()
+	cidOther := node.IPFS("add", "-Q", "--offline", filepath.Join(dir, "other.html")).Stdout.Trimmed()
+	require.NoError(t, os.WriteFile(denylist, []byte("/ipfs/"+cidPage+"\n"), 0o644))
+
+	node.StartDaemon()
+	defer node.StopDaemon()
+	client := node.GatewayClient()
+	get := func(host, path string) int {
+		return client.Get(path, func(r *http.Request) { r.Host = host }).StatusCode
+	}
+
+	t.Run("the denylists only apply to their hostname", func(t *testing.T) {
+		assert.Equal(t, http.StatusGone, get("example.com", "/ipfs/"+cidPage))
+		assert.Equal(t, http.StatusOK, get("other.com", "/ipfs/"+cidPage))
+		assert.Equal(t, http.StatusOK, get(node.GatewayURL()[len("http://"):], "/ipfs/"+cidPage))
+		assert.Equal(t, http.StatusOK, get("example.com", "/ipfs/"+cidOther))
+	})
+
+	t.Run("the CIDs a path resolves to are checked", func(t *testing.T) {
+		assert.Equal(t, http.StatusGone, get("example.com", "/ipfs/"+cidSite+"/page.html"))
+		assert.Equal(t, http.StatusOK, get("example.com", "/ipfs/"+cidSite+"/other.html"))
+	})
+
+	t.Run("the denylists are reloaded when they change", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(denylist, []byte("/ipfs/"+cidOther+"\n"), 0o644))
+		require.Eventually(t, func() bool {
+			return get("example.com", "/ipfs/"+cidOther) == http.StatusGone
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.Equal(t, http.StatusOK, get("example.com", "/ipfs/"+cidPage))
+	})
+
+	t.Run("ipfs gateway denylist status", func(t *testing.T) {
+		var out struct {
+			Denylists []struct {
+				Hostname string
+				File     string
+				Entries  int
+				Blocked  uint64
+				Error    string
+			}
+		}
+		res := node.IPFS("gateway", "denylist", "status", "--enc=json")
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+		require.Len(t, out.Denylists, 1)
+		d := out.Denylists[0]
+		assert.Equal(t, "example.com", d.Hostname)
+		assert.Equal(t, denylist, d.File)
+		assert.Equal(t, 1, d.Entries)
+		assert.GreaterOrEqual(t, d.Blocked, uint64(3))
+		assert.Empty(t, d.Error)
+
+		assert.Contains(t, node.IPFS("gateway", "denylist", "status").Stdout.String(), "example.com")
+	})
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayDirectoryIndexCache(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.NoFetch = true
+		cfg.Gateway.DirectoryIndexCache.Enabled = config.True
+		cfg.Gateway.DirectoryIndexCache.MinEntries = config.NewOptionalInteger(2)
+	})
+
+	mkdir := func(name string, files ...string) string {
+		dir := filepath.Join(node.Dir, name)
+		require.NoError(t, os.Mkdir(dir, 0o755))
+		for _, f := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte(f), 0o644))
+		}
+		return node.IPFS("add", "-r", "-Q", "--offline", dir).Stdout.Trimmed()
+	}
+	cidDir := mkdir("dir", "first.txt", "second.txt", "third.txt")
+	cidSmall := mkdir("small", "only.txt")
+
+	ls := func() []string {
+		var out struct {
+			Directories []struct {
+				Cid     map[string]string
+				Entries int
+			}
+		}
+		res := node.IPFS("gateway", "dirindex", "ls", "--enc=json")
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+		var cids []string
+		for _, d := range out.Directories {
+			cids = append(cids, d.Cid["/"])
+		}
+		return cids
+	}
+
+	node.StartDaemon()
+	client := node.GatewayClient()
+	res := client.Get("/ipfs/" + cidDir + "/")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, res.Body, "second.txt")
+	res = client.Get("/ipfs/" + cidSmall + "/")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	t.Run("the directories of at least MinEntries entries are cached", func(t *testing.T) {
+		assert.Equal(t, []string{cidDir}, ls())
+		assert.Contains(t, node.IPFS("gateway", "dirindex", "ls").Stdout.String(), cidDir)
+	})
+	node.StopDaemon()
+
+	// the blocks of the directory are removed, and the gateway does not fetch
+	node.IPFS("pin", "rm", cidDir)
+	node.IPFS("repo", "gc")
+
+	node.StartDaemon()
+	defer node.StopDaemon()
+	client = node.GatewayClient()
+
+	t.Run("the cached directories are listed after a restart without their blocks", func(t *testing.T) {
+		res := client.Get("/ipfs/" + cidDir + "/")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, res.Body, "first.txt")
+		assert.Contains(t, res.Body, "third.txt")
+	})
+
+	t.Run("ipfs gateway dirindex purge", func(t *testing.T) {
+		res := node.IPFS("gateway", "dirindex", "purge", cidDir)
+		assert.Equal(t, "purged 1 directories", res.Stdout.Trimmed())
+		assert.Empty(t, ls())
+		assert.NotEqual(t, http.StatusOK, client.Get("/ipfs/"+cidDir+"/").StatusCode)
+	})
+}
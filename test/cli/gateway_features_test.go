@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayHostnameFeatures(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	paths := []string{"/ipfs", "/ipns"}
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.PublicGateways = map[string]*config.GatewaySpec{
+			"web.example.com": {
+				Paths:              paths,
+				TrustlessResponses: config.False,
+				IPNSResolution:     config.False,
+				DirectoryListing:   config.False,
+			},
+			"*.example.com":    {Paths: paths, DirectoryListing: config.False},
+			"open.example.com": {Paths: paths},
+		}
+	})
+
+	dir := filepath.Join(node.Dir, "dir")
+	site := filepath.Join(node.Dir, "site")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.Mkdir(site, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("file"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(site, "index.html"), []byte("<p>site</p>"), 0o644))
+	cidDir := node.IPFS("add", "-r", "-Q", "--offline", dir).Stdout.Trimmed()
+	cidSite := node.IPFS("add", "-r", "-Q", "--offline", site).Stdout.Trimmed()
+	cidFile := node.IPFS("add", "-Q", "--offline", filepath.Join(dir, "file.txt")).Stdout.Trimmed()
+	peerID := node.PeerID().String()
+
+	node.StartDaemon()
+	defer node.StopDaemon()
+	client := node.GatewayClient()
+	get := func(host, path string, headers ...string) int {
+		return client.Get(path, func(r *http.Request) {
+			r.Host = host
+			for i := 0; i+1 < len(headers); i += 2 {
+				r.Header.Set(headers[i], headers[i+1])
+			}
+		}).StatusCode
+	}
+
+	t.Run("trustless responses can be turned off", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("web.example.com", "/ipfs/"+cidFile))
+		assert.Equal(t, http.StatusNotAcceptable, get("web.example.com", "/ipfs/"+cidFile+"?format=raw"))
+		assert.Equal(t, http.StatusNotAcceptable, get("web.example.com", "/ipfs/"+cidFile, "Accept", "application/vnd.ipld.car"))
+		assert.Equal(t, http.StatusOK, get("api.example.com", "/ipfs/"+cidFile+"?format=raw"))
+	})
+
+	t.Run("IPNS resolution can be turned off", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get("web.example.com", "/ipns/"+peerID))
+		// the name of the node is published to an empty directory by ipfs init
+		assert.Equal(t, http.StatusOK, get("open.example.com", "/ipns/"+peerID+"/"))
+	})
+
+	t.Run("directory listings can be turned off, including with a pattern", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get("web.example.com", "/ipfs/"+cidDir+"/"))
+		assert.Equal(t, http.StatusForbidden, get("api.example.com", "/ipfs/"+cidDir+"/"))
+		assert.Equal(t, http.StatusOK, get("web.example.com", "/ipfs/"+cidSite+"/"))
+		assert.Equal(t, http.StatusOK, get("web.example.com", "/ipfs/"+cidDir+"/file.txt"))
+	})
+
+	t.Run("exact hostnames take precedence over the patterns", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("open.example.com", "/ipfs/"+cidDir+"/"))
+	})
+
+	t.Run("the other hostnames are not restricted", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get(node.GatewayURL()[len("http://"):], "/ipfs/"+cidDir+"/"))
+		assert.Equal(t, http.StatusOK, get(node.GatewayURL()[len("http://"):], "/ipfs/"+cidFile+"?format=raw"))
+	})
+}
+
+func TestGatewayHostnameFeaturesValidation(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.PublicGateways = map[string]*config.GatewaySpec{
+			"example.com": {
+				Paths:                 []string{"/ipfs"},
+				DeserializedResponses: config.False,
+				TrustlessResponses:    config.False,
+			},
+		}
+	})
+	res := node.RunIPFS("daemon")
+	assert.NotEqual(t, 0, res.ExitCode())
+	assert.Contains(t, res.Stderr.String(), "DeserializedResponses and TrustlessResponses are both disabled")
+}
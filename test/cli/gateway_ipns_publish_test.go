@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayIPNSPublishing(t *testing.T) {
+	t.Parallel()
+
+	// newRecord returns a name and its record, signed by sk, pointing at
+	// /ipfs/cid
+	newRecord := func(t *testing.T, sk crypto.PrivKey, cid string, seq uint64) (ipns.Name, []byte) {
+		pid, err := peer.IDFromPrivateKey(sk)
+		require.NoError(t, err)
+		value, err := path.NewPath("/ipfs/" + cid)
+		require.NoError(t, err)
+		rec, err := ipns.NewRecord(sk, value, seq, time.Now().Add(time.Hour), time.Minute)
+		require.NoError(t, err)
+		data, err := ipns.MarshalRecord(rec)
+		require.NoError(t, err)
+		return ipns.NameFromPeer(pid), data
+	}
+	newKey := func(t *testing.T) crypto.PrivKey {
+		sk, _, err := crypto.GenerateEd25519Key(nil)
+		require.NoError(t, err)
+		return sk
+	}
+	put := func(client *harness.HTTPClient, name ipns.Name, data []byte, contentType string) *harness.HTTPResponse {
+		req, err := http.NewRequest(http.MethodPut, client.BuildURL("/ipns/"+name.String()), bytes.NewReader(data))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		return client.Do(req)
+	}
+
+	t.Run("the signed records are published", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Gateway.IPNSPublishing = config.True
+		})
+		cid := node.IPFSAddStr("published over HTTP")
+		node.StartDaemon("--offline")
+		defer node.StopDaemon()
+		client := node.GatewayClient()
+
+		sk := newKey(t)
+		name, data := newRecord(t, sk, cid, 1)
+		resp := put(client, name, data, "application/vnd.ipfs.ipns-record")
+		require.Equal(t, http.StatusOK, resp.StatusCode, resp.Body)
+		assert.Equal(t, "/ipfs/"+cid, node.IPFS("name", "resolve", name.String()).Stdout.Trimmed())
+
+		resp = client.Get("/ipns/"+name.String(), func(r *http.Request) {
+			r.Header.Set("Accept", "application/vnd.ipfs.ipns-record")
+		})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, data, []byte(resp.Body), "the record is served as sent")
+
+		// the preflight of the browsers allows PUT
+		req, err := http.NewRequest(http.MethodOptions, client.BuildURL("/ipns/"+name.String()), nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		resp = client.Do(req)
+		assert.Contains(t, resp.Headers.Values("Access-Control-Allow-Methods"), http.MethodPut)
+	})
+
+	t.Run("the invalid records are refused", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Gateway.IPNSPublishing = config.True
+		})
+		cid := node.IPFSAddStr("published over HTTP")
+		node.StartDaemon("--offline")
+		defer node.StopDaemon()
+		client := node.GatewayClient()
+
+		name, data := newRecord(t, newKey(t), cid, 1)
+		resp := put(client, name, data, "application/octet-stream")
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+		other, _ := newRecord(t, newKey(t), cid, 1)
+		resp = put(client, other, data, "application/vnd.ipfs.ipns-record")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "the record is not signed by the key of the name")
+
+		resp = put(client, name, []byte("not a record"), "application/vnd.ipfs.ipns-record")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		metrics := node.APIClient().Get("/debug/metrics/prometheus").Body
+		assert.Contains(t, metrics, `ipfs_http_gw_ipns_publish_total{result="invalid"} 3`)
+	})
+
+	t.Run("the records are not accepted by default", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		cid := node.IPFSAddStr("published over HTTP")
+		node.StartDaemon("--offline")
+		defer node.StopDaemon()
+
+		name, data := newRecord(t, newKey(t), cid, 1)
+		resp := put(node.GatewayClient(), name, data, "application/vnd.ipfs.ipns-record")
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
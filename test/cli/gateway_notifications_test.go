@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayNotifications(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	cid := node.IPFSAddStr("served slowly")
+	dir := node.PipeStrToIPFS("in a directory", "add", "-Q", "-w", "--stdin-name=file.txt").Stdout.Trimmed()
+
+	type event struct {
+		Event  string
+		Path   string
+		Status int
+		Roots  string
+	}
+	var (
+		mu     sync.Mutex
+		events []event
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer webhook-token", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var e event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}))
+	t.Cleanup(webhook.Close)
+
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.Notifications = &config.GatewayNotifications{
+			URL:     webhook.URL,
+			Headers: map[string]string{"Authorization": "Bearer webhook-token"},
+			Events:  []string{"not-found", "slow"},
+			// every response is slow
+			SlowThreshold: config.NewOptionalDuration(time.Nanosecond),
+		}
+	})
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	client := node.GatewayClient()
+	resp := client.Get("/ipfs/" + cid)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = client.Get("/ipfs/" + dir + "/missing.txt")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	// the events not listed are not sent
+	resp = client.Get("/ipfs/not-a-cid")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 2
+	}, 10*time.Second, 100*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	byEvent := map[string]event{}
+	for _, e := range events {
+		byEvent[e.Event] = e
+	}
+	require.Contains(t, byEvent, "slow")
+	assert.Equal(t, "/ipfs/"+cid, byEvent["slow"].Path)
+	assert.Equal(t, http.StatusOK, byEvent["slow"].Status)
+	assert.Equal(t, cid, byEvent["slow"].Roots)
+	require.Contains(t, byEvent, "not-found")
+	assert.Equal(t, "/ipfs/"+dir+"/missing.txt", byEvent["not-found"].Path)
+	assert.Equal(t, http.StatusNotFound, byEvent["not-found"].Status)
+}
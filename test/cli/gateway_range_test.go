@@ -1,13 +1,18 @@
 package cli
 
 import (
+	"bytes"
+	"crypto/rand"
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"testing"
 
+	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/test/cli/harness"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGatewayHAMTDirectory(t *testing.T) {
@@ -98,3 +103,38 @@ func TestGatewayHAMTRanges(t *testing.T) {
 		assert.Equal(t, "iana", resp.Body)
 	})
 }
+
+func TestGatewayRangeCoalescing(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes[1].UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.RangeCoalescing.Enabled = config.True
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	data := make([]byte, 4<<20)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	cid := nodes[0].IPFSAdd(bytes.NewReader(data), "--chunker=size-4096")
+
+	client := nodes[1].GatewayClient()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		from, to := i*100_000, i*100_000+300_000
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := client.Get("/ipfs/"+cid, func(r *http.Request) {
+				r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+			})
+			assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+			assert.Equal(t, string(data[from:to+1]), resp.Body, "the range %d-%d", from, to)
+		}()
+	}
+	wg.Wait()
+
+	metrics := nodes[1].APIClient().Get("/debug/metrics/prometheus").Body
+	assert.Contains(t, metrics, `ipfs_http_gw_block_fetches_total{result="started"}`)
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayRateLimit(t *testing.T) {
+	t.Parallel()
+
+	startNode := func(t *testing.T, rateLimit *config.GatewayRateLimit) *harness.Node {
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Gateway.RateLimit = rateLimit
+		})
+		node.StartDaemon()
+		t.Cleanup(func() { node.StopDaemon() })
+		return node
+	}
+
+	t.Run("requests over the rate of a client are rejected", func(t *testing.T) {
+		t.Parallel()
+		node := startNode(t, &config.GatewayRateLimit{
+			PerIP: &config.GatewayRateLimitPolicy{
+				RequestsPerSecond: config.NewOptionalInteger(1),
+				Burst:             config.NewOptionalInteger(3),
+			},
+		})
+		c := node.IPFSAddStr("limited per client")
+		client := node.GatewayClient()
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, client.Get("/ipfs/"+c).StatusCode)
+		}
+		res := client.Get("/ipfs/" + c)
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		assert.Equal(t, "1", res.Headers.Get("Retry-After"))
+		assert.Contains(t, res.Body, "too many requests for this client")
+	})
+
+	t.Run("the bytes of a CID are limited in all its encodings", func(t *testing.T) {
+		t.Parallel()
+		node := startNode(t, &config.GatewayRateLimit{
+			PerCID: &config.GatewayRateLimitPolicy{
+				BytesPerSecond: config.NewOptionalString("1KiB"),
+			},
+		})
+		limited := node.IPFSAddStr(strings.Repeat("a", 64<<10))
+		other := node.IPFSAddStr("another CID")
+		client := node.GatewayClient()
+
+		assert.Equal(t, http.StatusOK, client.Get("/ipfs/"+limited).StatusCode)
+
+		v1 := cid.MustParse(limited)
+		v1 = cid.NewCidV1(v1.Type(), v1.Hash())
+		res := client.Get("/ipfs/" + v1.String())
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		retryAfter := res.Headers.Get("Retry-After")
+		assert.NotEmpty(t, retryAfter)
+		assert.NotEqual(t, "1", retryAfter)
+		assert.Contains(t, res.Body, "too many bytes for this content")
+
+		assert.Equal(t, http.StatusOK, client.Get("/ipfs/"+other).StatusCode)
+	})
+
+	t.Run("exempt clients are not limited", func(t *testing.T) {
+		t.Parallel()
+		node := startNode(t, &config.GatewayRateLimit{
+			PerIP: &config.GatewayRateLimitPolicy{
+				RequestsPerSecond: config.NewOptionalInteger(1),
+			},
+			Exempt: []string{"127.0.0.0/8", "::1"},
+		})
+		c := node.IPFSAddStr("not limited")
+		client := node.GatewayClient()
+
+		for i := 0; i < 5; i++ {
+			require.Equal(t, http.StatusOK, client.Get("/ipfs/"+c).StatusCode)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/corehttp"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayReceipts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports the blocks served when enabled", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		data := string(testutils.RandomBytes(3000))
+		cid := node.IPFSAddStr(data, "--chunker=size-1000", "--raw-leaves")
+		blocks := append(node.IPFS("refs", cid).Stdout.Lines(), cid)
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Gateway.Receipts.Enabled = config.True
+		})
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		req, err := http.NewRequest(http.MethodGet, node.GatewayURL()+"/ipfs/"+cid, nil)
+		require.NoError(t, err)
+		req.Header.Set("TE", "trailers")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, data, string(body))
+		trailer := resp.Trailer.Get("X-Ipfs-Receipt")
+		assert.Contains(t, trailer, "root="+cid+"; blocks=4; ")
+		for _, c := range blocks {
+			assert.Contains(t, trailer, c)
+		}
+
+		res := node.GatewayClient().Get("/ipfs/" + cid + "?receipt=true")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		receipt, err := corehttp.VerifyGatewayReceipt([]byte(res.Body))
+		require.NoError(t, err)
+		assert.Equal(t, node.PeerID().String(), receipt.Peer)
+		assert.Equal(t, cid, receipt.Roots[0])
+		assert.ElementsMatch(t, blocks, receipt.Blocks)
+		digest := sha256.Sum256([]byte(data))
+		assert.Equal(t, hex.EncodeToString(digest[:]), receipt.SHA256)
+	})
+
+	t.Run("serves the responses as they are by default", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		cid := node.IPFSAddStr("hello")
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		res := node.GatewayClient().Get("/ipfs/" + cid + "?receipt=true")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "hello", res.Body)
+	})
+}
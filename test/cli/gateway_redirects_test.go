@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayRedirectsTest(t *testing.T) {
+	t.Parallel()
+	node := harness.NewT(t).NewNode().Init()
+
+	dir := filepath.Join(t.TempDir(), "site")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	for name, content := range map[string]string{
+		"index.html": "index",
+		"404.html":   "not found",
+		"_redirects": "/old/:name /new/:name 302\n/app/* /index.html 200\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	cid := strings.TrimSpace(node.IPFS("add", "-r", "-Q", "--cid-version=1", dir).Stdout.String())
+
+	t.Run("prints the rule applied to a path", func(t *testing.T) {
+		out := node.IPFS("gateway", "redirects", "test", cid, "/app/settings").Stdout.String()
+		assert.Equal(t, "rule 2: /app/* /index.html 200\n200 OK: /ipfs/"+cid+"/index.html\n", out)
+
+		out = node.IPFS("gateway", "redirects", "test", cid, "old/page").Stdout.String()
+		assert.Equal(t, "rule 1: /old/:name /new/page 302\n302 Found: redirect to /new/page\n", out)
+
+		res := node.IPFS("gateway", "redirects", "test", "--enc=json", cid, "/missing")
+		var test struct {
+			Path   string
+			Exists bool
+			Rule   *struct{ Rule int }
+			Status int
+		}
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &test))
+		assert.Equal(t, "/missing", test.Path)
+		assert.Nil(t, test.Rule)
+		assert.Equal(t, http.StatusNotFound, test.Status)
+	})
+
+	t.Run("does not evaluate the rules for the paths which exist", func(t *testing.T) {
+		out := node.IPFS("gateway", "redirects", "test", cid, "/404.html").Stdout.String()
+		assert.Equal(t, "/404.html exists, the _redirects rules are not evaluated\n200 OK\n", out)
+	})
+
+	t.Run("counts the rules applied by the gateway", func(t *testing.T) {
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		gwURL, err := url.Parse(node.GatewayURL())
+		require.NoError(t, err)
+		resp := node.GatewayClient().Get("/app/settings", func(r *http.Request) {
+			r.Host = cid + ".ipfs.localhost:" + gwURL.Port()
+		})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "index", resp.Body)
+
+		metrics := node.APIClient().Get("/debug/metrics/prometheus").Body
+		assert.Contains(t, metrics, `ipfs_http_gw_redirects_rules_total{status="200"} 1`)
+
+		// the command runs against the daemon too
+		out := node.IPFS("gateway", "redirects", "test", cid, "/app/x").Stdout.String()
+		assert.Contains(t, out, "rule 2: ")
+	})
+}
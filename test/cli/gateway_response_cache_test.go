@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayResponseCache(t *testing.T) {
+	t.Parallel()
+
+	// newNode returns a node whose gateway serves its blocks only, with a
+	// directory whose blocks are removed by repo gc once unpinned.
+	newNode := func(t *testing.T, ttl time.Duration) (*harness.Node, string) {
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Gateway.NoFetch = true
+			cfg.Gateway.ResponseCache.Enabled = config.True
+			cfg.Gateway.ResponseCache.TTL = config.NewOptionalDuration(ttl)
+		})
+		node.StartDaemon()
+
+		dir := filepath.Join(node.Dir, "dir")
+		require.NoError(t, os.Mkdir(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("cached content"), 0o644))
+		return node, node.IPFS("add", "-r", "-Q", dir).Stdout.Trimmed()
+	}
+	removeBlocks := func(node *harness.Node, cid string) {
+		node.IPFS("pin", "rm", cid)
+		node.IPFS("repo", "gc")
+	}
+	html := func(r *http.Request) { r.Header.Set("Accept", "text/html") }
+
+	t.Run("repeated requests are served from the cache", func(t *testing.T) {
+		t.Parallel()
+		node, cid := newNode(t, time.Hour)
+		defer node.StopDaemon()
+		client := node.GatewayClient()
+
+		listing := client.Get("/ipfs/"+cid+"/", html)
+		require.Equal(t, http.StatusOK, listing.StatusCode)
+		require.Contains(t, listing.Body, "file.txt")
+		file := client.Get("/ipfs/" + cid + "/file.txt")
+		require.Equal(t, http.StatusOK, file.StatusCode)
+		removeBlocks(node, cid)
+
+		res := client.Get("/ipfs/"+cid+"/", html)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, listing.Body, res.Body)
+		assert.Equal(t, listing.Headers.Get("Content-Type"), res.Headers.Get("Content-Type"))
+		assert.NotEmpty(t, res.Headers.Get("Age"))
+
+		res = client.Get("/ipfs/"+cid+"/file.txt", client.WithHeader("Range", "bytes=7-"))
+		assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Equal(t, "content", res.Body)
+
+		res = client.Get("/ipfs/"+cid+"/file.txt", client.WithHeader("If-None-Match", file.Headers.Get("Etag")))
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+		// the responses in another format are not the cached ones
+		res = client.Get("/ipfs/" + cid + "/file.txt?format=raw")
+		assert.NotEqual(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("responses expire after the TTL", func(t *testing.T) {
+		t.Parallel()
+		node, cid := newNode(t, time.Second)
+		defer node.StopDaemon()
+		client := node.GatewayClient()
+
+		require.Equal(t, http.StatusOK, client.Get("/ipfs/"+cid+"/file.txt").StatusCode)
+		removeBlocks(node, cid)
+		time.Sleep(time.Second)
+
+		assert.NotEqual(t, http.StatusOK, client.Get("/ipfs/"+cid+"/file.txt").StatusCode)
+	})
+}
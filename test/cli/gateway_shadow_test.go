@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatewayShadow(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	cidMatch := node.IPFSAddStr("served alike")
+	cidMismatch := node.IPFSAddStr("missing on the secondary gateway")
+
+	var (
+		mu       sync.Mutex
+		mirrored []*http.Request
+	)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		mirrored = append(mirrored, r)
+		mu.Unlock()
+		if r.URL.Path != "/ipfs/"+cidMatch {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Ipfs-Roots", cidMatch)
+		_, _ = w.Write([]byte("served alike"))
+	}))
+	t.Cleanup(secondary.Close)
+
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.Shadow = &config.GatewayShadow{
+			URL:     secondary.URL,
+			Percent: config.NewOptionalInteger(100),
+		}
+	})
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	client := node.GatewayClient()
+	resp := client.Get("/ipfs/"+cidMatch, func(r *http.Request) {
+		r.Header.Set("Accept", "text/plain")
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "served alike", resp.Body)
+	resp = client.Get("/ipfs/" + cidMismatch)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the client is served by the node")
+
+	metrics := func() string {
+		return node.APIClient().Get("/debug/metrics/prometheus").Body
+	}
+	assert.Eventually(t, func() bool {
+		m := metrics()
+		return strings.Contains(m, `ipfs_http_gw_shadow_requests_total{result="match"} 1`) &&
+			strings.Contains(m, `ipfs_http_gw_shadow_requests_total{result="status_mismatch"} 1`)
+	}, 10*time.Second, 100*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, mirrored, 2)
+	for _, r := range mirrored {
+		assert.Equal(t, resp.Resp.Request.Host, r.Host, "the host is mirrored")
+		if r.URL.Path == "/ipfs/"+cidMatch {
+			assert.Equal(t, "text/plain", r.Header.Get("Accept"))
+		}
+	}
+}
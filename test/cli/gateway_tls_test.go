@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayTLS(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the gateway serves HTTPS with the certificate files", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		certPEM := writeGatewayCertificate(t, node.Dir)
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Addresses.Gateway = []string{"/ip4/127.0.0.1/tcp/0/tls/http"}
+			cfg.Gateway.TLS.CertFile = config.NewOptionalString("gateway-cert.pem")
+			cfg.Gateway.TLS.KeyFile = config.NewOptionalString("gateway-key.pem")
+		})
+		cid := node.IPFSAddStr("served over HTTPS")
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		roots := x509.NewCertPool()
+		require.True(t, roots.AppendCertsFromPEM(certPEM))
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots},
+			ForceAttemptHTTP2: true,
+		}}
+		url := strings.Replace(node.GatewayURL(), "http://", "https://", 1)
+		resp, err := client.Get(url + "/ipfs/" + cid)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "served over HTTPS", string(body))
+		assert.Equal(t, 2, resp.ProtoMajor)
+	})
+
+	t.Run("the TLS addresses require a certificate", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Addresses.Gateway = []string{"/ip4/127.0.0.1/tcp/0/tls/http"}
+		})
+		res := node.RunIPFS("daemon")
+		assert.NotEqual(t, 0, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "require CertFile and KeyFile, or ACME")
+	})
+}
+
+// writeGatewayCertificate writes a self-signed certificate of 127.0.0.1 and
+// its key in the repo, and returns the certificate.
+func writeGatewayCertificate(t *testing.T, dir string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "gateway-cert.pem"), certPEM, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "gateway-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certPEM
+}
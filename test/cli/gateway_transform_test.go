@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"bytes"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayTransform(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 64, 32))))
+	img := buf.String()
+
+	t.Run("resizes the images when enabled", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		cid := node.IPFSAddStr(img)
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Gateway.Transform.Enabled = config.True
+		})
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		client := node.GatewayClient()
+		resp := client.Get("/ipfs/" + cid + "?w=16&fm=jpeg")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "image/jpeg", resp.Headers.Get("Content-Type"))
+		ic, _, err := image.DecodeConfig(strings.NewReader(resp.Body))
+		require.NoError(t, err)
+		assert.Equal(t, 16, ic.Width)
+		assert.Equal(t, 8, ic.Height)
+
+		resp = client.Get("/ipfs/" + cid + "?w=0")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("serves the images as they are by default", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		cid := node.IPFSAddStr(img)
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		resp := node.GatewayClient().Get("/ipfs/" + cid + "?w=16")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, img, resp.Body)
+	})
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSparse(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip("sparse files are only supported on Linux")
+	}
+
+	h := harness.NewT(t)
+	node := h.NewNode().Init()
+
+	const size = 16 << 20
+	src := filepath.Join(h.Dir, "disk.img")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("boot"), 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("data"), size/2)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(size))
+	require.NoError(t, f.Close())
+
+	cid := node.IPFS("add", "-Q", src).Stdout.Trimmed()
+
+	allocated := func(t *testing.T, fpath string) int64 {
+		fi, err := os.Stat(fpath)
+		require.NoError(t, err)
+		return fi.Sys().(*syscall.Stat_t).Blocks * 512
+	}
+
+	t.Run("ipfs get --sparse writes zeroed regions as holes", func(t *testing.T) {
+		out := filepath.Join(h.Dir, "sparse.img")
+		node.IPFS("get", "--sparse", "-o", out, cid)
+
+		want, err := os.ReadFile(src)
+		require.NoError(t, err)
+		got, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+		assert.Less(t, allocated(t, out), int64(size/4))
+	})
+
+	t.Run("ipfs get --sparse is rejected with --archive", func(t *testing.T) {
+		res := node.RunIPFS("get", "--sparse", "--archive", "-o", filepath.Join(h.Dir, "disk.tar"), cid)
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "only applies to extracted files")
+	})
+}
@@ -0,0 +1,82 @@
+package harness
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClockEnv is the environment variable read by node.Clock.
+const testClockEnv = "IPFS_TEST_CLOCK"
+
+// testClockTag is the build tag of the binaries reading testClockEnv.
+const testClockTag = "testclock"
+
+// TestClockBuilt tells whether the ipfs binary is built with the testclock
+// tag, as done for the unit tests of the Makefile. The daemons of the other
+// builds ignore UseTestClock.
+func (h *Harness) TestClockBuilt() bool {
+	info, err := buildinfo.ReadFile(h.IPFSBin)
+	if err != nil {
+		panic(err)
+	}
+	for _, s := range info.Settings {
+		if s.Key == "-tags" && slices.Contains(strings.Split(s.Value, ","), testClockTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipWithoutTestClock skips the test when the ipfs binary is not built with
+// the testclock tag, see TestClockBuilt.
+func (h *Harness) SkipWithoutTestClock(t *testing.T) {
+	if !h.TestClockBuilt() {
+		t.Skipf("%s is not built with -tags %s", h.IPFSBin, testClockTag)
+	}
+}
+
+// UseTestClock makes the daemon of the node use a mock clock for its
+// reprovide sweeps, scheduled tasks, periodic GC and IPNS lifetimes. The
+// clock starts at the time the daemon starts, and only moves when advanced
+// with AdvanceClock. The binary has to be built with the testclock tag, see
+// Harness.SkipWithoutTestClock.
+func (n *Node) UseTestClock() *Node {
+	n.Runner.Env[testClockEnv] = "1"
+	return n
+}
+
+// UseTestClock makes the daemons of the nodes use a mock clock, see
+// Node.UseTestClock.
+func (n Nodes) UseTestClock() Nodes {
+	for _, node := range n {
+		node.UseTestClock()
+	}
+	return n
+}
+
+// Clock returns the time of the mock clock of the running daemon.
+func (n *Node) Clock() time.Time {
+	return parseClock(n.APIClient().Get("/debug/clock"))
+}
+
+// AdvanceClock moves the mock clock of the running daemon forward by d,
+// firing the timers that came due, and returns its new time.
+func (n *Node) AdvanceClock(d time.Duration) time.Time {
+	return parseClock(n.APIClient().Post("/debug/clock?advance="+d.String(), nil))
+}
+
+func parseClock(res *HTTPResponse) time.Time {
+	if res.StatusCode != http.StatusOK {
+		panic(fmt.Sprintf("test clock: %d %s, is the daemon built with -tags testclock and started after UseTestClock?", res.StatusCode, res.Body))
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(res.Body))
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarnessTestClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("the clock only moves when advanced", func(t *testing.T) {
+		t.Parallel()
+		h := harness.NewT(t)
+		h.SkipWithoutTestClock(t)
+		n := h.NewNode().Init().UseTestClock().StartDaemon()
+		defer n.StopDaemon()
+
+		start := n.Clock()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, start, n.Clock())
+		assert.Equal(t, start.Add(time.Hour), n.AdvanceClock(time.Hour))
+	})
+
+	t.Run("the clock of the daemon is real by default", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init().StartDaemon()
+		defer n.StopDaemon()
+
+		assert.Equal(t, 404, n.APIClient().Get("/debug/clock").StatusCode)
+	})
+
+	t.Run("the builds without the testclock tag ignore IPFS_TEST_CLOCK", func(t *testing.T) {
+		t.Parallel()
+		h := harness.NewT(t)
+		if h.TestClockBuilt() {
+			t.Skip("the ipfs binary is built with -tags testclock")
+		}
+		n := h.NewNode().Init().UseTestClock().StartDaemon()
+		defer n.StopDaemon()
+
+		assert.Equal(t, 404, n.APIClient().Get("/debug/clock").StatusCode)
+	})
+
+	t.Run("scheduled tasks run when they come due", func(t *testing.T) {
+		t.Parallel()
+		h := harness.NewT(t)
+		h.SkipWithoutTestClock(t)
+		n := h.NewNode().Init().UseTestClock()
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Schedule.Tasks = []config.ScheduleTask{
+				{Name: "gc", Command: []string{"repo", "gc"}, Interval: config.NewOptionalDuration(24 * time.Hour)},
+			}
+		})
+		n.StartDaemon()
+		defer n.StopDaemon()
+
+		start := n.Clock()
+		assert.Zero(t, scheduleLs(t, n)["gc"].Runs)
+		n.AdvanceClock(24 * time.Hour)
+		var task node.ScheduledTaskStatus
+		assert.Eventually(t, func() bool {
+			task = scheduleLs(t, n)["gc"]
+			return task.Runs == 1
+		}, 10*time.Second, 50*time.Millisecond)
+		assert.Equal(t, start.Add(48*time.Hour), task.NextRun)
+	})
+
+	t.Run("reprovide sweeps start when they come due", func(t *testing.T) {
+		t.Parallel()
+		h := harness.NewT(t)
+		h.SkipWithoutTestClock(t)
+		n := h.NewNode().Init().UseTestClock()
+		n.SetIPFSConfig("Routing.Type", "none")
+		n.StartDaemon()
+		defer n.StopDaemon()
+		n.IPFSAddStr("reprovided")
+
+		var stat node.SweepStat
+		sweepStat := func() node.SweepStat {
+			var stat node.SweepStat
+			require.NoError(t, json.Unmarshal(n.IPFS("provide", "stat", "--enc=json").Stdout.Bytes(), &stat))
+			return stat
+		}
+		stat = sweepStat()
+		assert.True(t, stat.Started.IsZero())
+		assert.Equal(t, n.Clock().Add(time.Minute), stat.NextSweep)
+
+		start := n.AdvanceClock(time.Minute)
+		assert.Eventually(t, func() bool {
+			return sweepStat().Running
+		}, 10*time.Second, 50*time.Millisecond)
+
+		// the keys are announced in batches spread over Reprovider.Interval
+		n.AdvanceClock(22*time.Hour - time.Second)
+		assert.Eventually(t, func() bool {
+			stat = sweepStat()
+			return !stat.Running
+		}, 10*time.Second, 50*time.Millisecond)
+		assert.Equal(t, start, stat.Started)
+		assert.NotZero(t, stat.LastSweepKeys)
+		assert.Equal(t, start.Add(22*time.Hour), stat.NextSweep)
+	})
+
+	t.Run("cached IPNS resolutions expire", func(t *testing.T) {
+		t.Parallel()
+		h := harness.NewT(t)
+		h.SkipWithoutTestClock(t)
+		n := h.NewNode().Init().UseTestClock().StartDaemon()
+		defer n.StopDaemon()
+
+		c := n.IPFSAddStr("cached name")
+		n.IPFS("name", "publish", "--allow-offline", "--ttl=1h", c)
+		name := ipns.NameFromPeer(n.PeerID()).AsPath().String()
+		cached := func() []node.IpnsCacheEntry {
+			var out struct{ Entries []node.IpnsCacheEntry }
+			require.NoError(t, json.Unmarshal(n.IPFS("name", "cache", "ls", "--enc=json").Stdout.Bytes(), &out))
+			return out.Entries
+		}
+
+		entries := cached()
+		require.Len(t, entries, 1)
+		assert.Equal(t, name, entries[0].Name)
+		assert.Equal(t, n.Clock().Add(time.Hour), entries[0].Expires)
+		n.AdvanceClock(time.Hour)
+		assert.Empty(t, cached())
+	})
+}
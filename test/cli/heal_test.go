@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fails offline", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("heal", testutils.CIDEmptyDir)
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "this command must be run in online mode")
+	})
+
+	t.Run("fetches and announces the under-replicated subtrees", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons().Connect()
+		defer nodes.StopDaemons()
+
+		root := nodes[0].IPFSAddStr(strings.Repeat("a", 10)+strings.Repeat("b", 10), "--chunker=size-10", "--raw-leaves")
+		blocks := nodes[0].IPFS("refs", "-r", "-u", root).Stdout.Lines()
+		require.Len(t, blocks, 2)
+
+		out := nodes[1].IPFS("heal", "--min-providers=2", "--dry-run", root).Stdout.Lines()
+		assert.Equal(t, []string{root + ": 1 providers"}, out)
+		res := nodes[1].RunIPFS("block", "stat", "--offline", root)
+		assert.Error(t, res.Err, "the dry run fetched the root")
+
+		out = nodes[1].IPFS("heal", "--min-providers=2", "--depth=1", root).Stdout.Lines()
+		assert.Equal(t, []string{
+			root + ": 1 -> 2 providers, 1 blocks healed",
+			blocks[0] + ": 1 -> 2 providers, 1 blocks healed",
+			blocks[1] + ": 1 -> 2 providers, 1 blocks healed",
+		}, out)
+		nodes[1].IPFS("block", "stat", "--offline", blocks[1])
+
+		out = nodes[1].IPFS("heal", "--min-providers=2", root).Stdout.Lines()
+		assert.Equal(t, []string{root + ": 2 providers"}, out)
+	})
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyAudit(t *testing.T) {
+	t.Parallel()
+
+	audit := func(t *testing.T, n *harness.Node, args ...string) []node.KeyAuditEntry {
+		res := n.IPFS(append([]string{"key", "audit", "--enc=json"}, args...)...)
+		var out struct{ Entries []node.KeyAuditEntry }
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+		return out.Entries
+	}
+
+	t.Run("requires Security.KeyAudit.Enabled", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init()
+		res := n.RunIPFS("key", "audit", "self")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "Security.KeyAudit.Enabled")
+	})
+
+	t.Run("records the uses of the keys", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init()
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Security.KeyAudit.Enabled = config.True
+		})
+		cid := n.IPFSAddStr("published")
+
+		n.IPFS("key", "gen", "mykey")
+		n.PipeStrToIPFS("signed", "key", "sign", "--key=mykey")
+		n.IPFS("name", "publish", "--allow-offline", "--key=mykey", "/ipfs/"+cid)
+		n.IPFS("key", "rename", "mykey", "renamed")
+
+		entries := audit(t, n, "mykey")
+		require.Len(t, entries, 4)
+		ops := make([]string, len(entries))
+		for i, e := range entries {
+			ops[i] = e.Operation
+		}
+		assert.Equal(t, []string{node.KeyOpGenerate, node.KeyOpSign, node.KeyOpPublish, node.KeyOpRename}, ops)
+		assert.Equal(t, "key gen", entries[0].Command)
+		assert.Equal(t, "name publish", entries[2].Command)
+		assert.Equal(t, "/ipfs/"+cid, entries[2].Detail)
+		assert.Equal(t, "to renamed", entries[3].Detail)
+
+		entries = audit(t, n, "renamed")
+		require.Len(t, entries, 1)
+		assert.Equal(t, "from mykey", entries[0].Detail)
+
+		// the RPC requests are recorded with their API.Authorizations entry
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.API.Authorizations = map[string]*config.RPCAuthScope{
+				"alice": {AuthSecret: "bearer:alice", AllowedPaths: []string{"/api/v0"}},
+			}
+		})
+		n.StartDaemonWithAuthorization("Bearer alice")
+		defer n.StopDaemon()
+		n.PipeStrToIPFS("signed", "--api-auth=bearer:alice", "key", "sign")
+		entries = audit(t, n, "--api-auth=bearer:alice", "self")
+		require.Len(t, entries, 1)
+		assert.Equal(t, node.KeyOpSign, entries[0].Operation)
+		assert.Equal(t, "key sign", entries[0].Command)
+		assert.Equal(t, "alice", entries[0].User)
+		assert.Empty(t, entries[0].Error)
+	})
+}
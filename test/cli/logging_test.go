@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggingFile(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	node.Runner.Env["GOLOG_LOG_LEVEL"] = "error,cmd/ipfs=info"
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Logging.File = config.NewOptionalString("logs/ipfs.log")
+		cfg.Logging.RateLimit.Enabled = config.True
+	})
+	node.StartDaemon()
+	node.StopDaemon()
+
+	logs := node.ReadFile(filepath.Join(node.Dir, "logs", "ipfs.log"))
+	assert.Contains(t, logs, "Gracefully shut down daemon")
+	// the file is not colorized
+	assert.NotContains(t, logs, "\x1b[")
+}
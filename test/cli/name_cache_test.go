@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameCache(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Ipns.PersistResolveCache = config.True
+	})
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	c := node.IPFSAddStr("cached name")
+	node.IPFS("name", "publish", "--allow-offline", "--ttl=1h", c)
+	name := ipns.NameFromPeer(node.PeerID()).AsPath().String()
+
+	cached := func() map[string]string {
+		var out struct {
+			Entries []struct {
+				Name  string
+				Value string
+			}
+		}
+		require.NoError(t, json.Unmarshal(node.IPFS("name", "cache", "ls", "--enc=json").Stdout.Bytes(), &out))
+		entries := map[string]string{}
+		for _, e := range out.Entries {
+			entries[e.Name] = e.Value
+		}
+		return entries
+	}
+
+	assert.Equal(t, map[string]string{name: "/ipfs/" + c}, cached())
+	assert.Equal(t, "/ipfs/"+c, node.IPFS("name", "resolve", node.PeerID().String()).Stdout.Trimmed())
+
+	t.Run("persisted entries survive restarts", func(t *testing.T) {
+		node.StopDaemon()
+		node.StartDaemon()
+		assert.Equal(t, map[string]string{name: "/ipfs/" + c}, cached())
+	})
+
+	t.Run("purge removes entries", func(t *testing.T) {
+		assert.Equal(t, "purged 1 entries", node.IPFS("name", "cache", "purge", node.PeerID().String()).Stdout.Trimmed())
+		assert.Empty(t, cached())
+	})
+
+	t.Run("the cache requires the daemon", func(t *testing.T) {
+		node.StopDaemon()
+		res := node.RunIPFS("name", "cache", "ls")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "only available while the daemon runs")
+	})
+}
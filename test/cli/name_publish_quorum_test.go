@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/routing/http/server"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/commands/name"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rejectingRouter rejects the IPNS records published to it.
+type rejectingRouter struct {
+	publishingRouter
+}
+
+func (r *rejectingRouter) PutIPNS(ctx context.Context, name ipns.Name, rec *ipns.Record) error {
+	return errors.New("unavailable")
+}
+
+func TestNamePublishQuorum(t *testing.T) {
+	t.Parallel()
+
+	accepting := httptest.NewServer(server.Handler(&publishingRouter{names: map[string]bool{}}))
+	t.Cleanup(accepting.Close)
+	rejecting := httptest.NewServer(server.Handler(&rejectingRouter{}))
+	t.Cleanup(rejecting.Close)
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes[0].UpdateConfig(func(cfg *config.Config) {
+		cfg.Routing.DelegatedRouters = []string{accepting.URL, rejecting.URL}
+		cfg.Routing.DelegatedPublishing = config.True
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+	publishPath := "/ipfs/" + nodes[0].IPFSAddStr("quorum")
+
+	t.Run("reports the result of each target", func(t *testing.T) {
+		var entry name.IpnsEntry
+		res := nodes[0].IPFS("name", "publish", "--enc=json", publishPath)
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &entry))
+
+		results := map[string]bool{}
+		for _, target := range entry.Targets {
+			results[target.Target] = target.Acked
+		}
+		assert.Equal(t, map[string]bool{"dht": true, accepting.URL: true, rejecting.URL: false}, results)
+
+		out := nodes[0].IPFS("name", "publish", "--verbose", publishPath).Stdout.String()
+		assert.Contains(t, out, accepting.URL+": acked in ")
+		assert.Contains(t, out, rejecting.URL+": failed in ")
+	})
+
+	t.Run("succeeds when the quorum is reached", func(t *testing.T) {
+		res := nodes[0].RunIPFS("name", "publish", "--quorum=2", publishPath)
+		assert.NoError(t, res.Err)
+	})
+
+	t.Run("fails when the quorum is not reached", func(t *testing.T) {
+		res := nodes[0].RunIPFS("name", "publish", "--quorum=3", publishPath)
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "the record was accepted by 2 of 3 routing targets, 3 required")
+		assert.Contains(t, res.Stderr.String(), rejecting.URL+": ")
+	})
+}
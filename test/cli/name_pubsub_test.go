@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamePubsubState(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes.ForEachPar(func(node *harness.Node) {
+		node.IPFS("config", "Routing.Type", "dht")
+	})
+	nodes.StartDaemons("--enable-pubsub-experiment", "--enable-namesys-pubsub").Connect()
+	defer nodes.StopDaemons()
+	publisher, subscriber := nodes[0], nodes[1]
+
+	c := publisher.IPFSAddStr("published before the subscription")
+	publisher.IPFS("name", "publish", c)
+	name := ipns.NameFromPeer(publisher.PeerID()).AsPath().String()
+
+	type pubsubName struct {
+		Name       string
+		ResolvedBy string
+		Sequence   uint64
+		Updates    int
+	}
+	state := func() []pubsubName {
+		var out struct {
+			Enabled bool
+			Names   []pubsubName
+		}
+		require.NoError(t, json.Unmarshal(subscriber.IPFS("name", "pubsub", "state", "--enc=json").Stdout.Bytes(), &out))
+		require.True(t, out.Enabled)
+		return out.Names
+	}
+	assert.Empty(t, state())
+	assert.Equal(t, "enabled", subscriber.IPFS("name", "pubsub", "state").Stdout.Trimmed())
+
+	// the record published before the subscription is looked up when
+	// subscribing, instead of waiting for the next publication
+	subscriber.IPFS("name", "resolve", name)
+	require.Eventually(t, func() bool {
+		names := state()
+		return len(names) == 1 && names[0].Updates > 0
+	}, 30*time.Second, 100*time.Millisecond)
+	names := state()
+	assert.Equal(t, name, names[0].Name)
+	assert.Equal(t, "fetch", names[0].ResolvedBy)
+	assert.Equal(t, uint64(1), names[0].Sequence)
+
+	// the names canceled are no longer reported
+	subscriber.IPFS("name", "pubsub", "cancel", name)
+	assert.Empty(t, state())
+}
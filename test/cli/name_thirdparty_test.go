@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type thirdPartyRecord struct {
+	Name          string
+	Value         string
+	Sequence      uint64
+	LastRepublish time.Time
+	Error         string
+}
+
+func thirdPartyRecords(t *testing.T, n *harness.Node) []thirdPartyRecord {
+	var list struct{ Records []thirdPartyRecord }
+	res := n.IPFS("name", "thirdparty", "--enc=json")
+	require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &list))
+	return list.Records
+}
+
+func TestNameThirdParty(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fails without Ipns.RepublishThirdParty", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init().StartDaemon()
+		defer node.StopDaemon()
+
+		res := node.RunIPFS("name", "thirdparty")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "Ipns.RepublishThirdParty")
+	})
+
+	t.Run("keeps and republishes the record of another node", func(t *testing.T) {
+		t.Parallel()
+		h := harness.NewT(t)
+		h.SkipWithoutTestClock(t)
+		nodes := h.NewNodes(3).Init()
+		owner, keeper, reader := nodes[0], nodes[1], nodes[2]
+		name := ipns.NameFromPeer(owner.PeerID())
+
+		keeper.UpdateConfig(func(cfg *config.Config) {
+			cfg.Ipns.RepublishThirdParty = []string{name.String()}
+		})
+		keeper.UseTestClock().StartDaemon()
+		defer keeper.StopDaemon()
+
+		// the first round finds no peer to look the name up
+		require.Eventually(t, func() bool {
+			return thirdPartyRecords(t, keeper)[0].Error != ""
+		}, 30*time.Second, 100*time.Millisecond)
+
+		owner.StartDaemon()
+		owner.Connect(keeper)
+		publishPath := "/ipfs/" + owner.IPFSAddStr("third party")
+		owner.IPFS("name", "publish", publishPath)
+		owner.StopDaemon()
+
+		keeper.AdvanceClock(config.DefaultIpnsRepublishThirdPartyPeriod)
+		var rec thirdPartyRecord
+		require.Eventually(t, func() bool {
+			rec = thirdPartyRecords(t, keeper)[0]
+			return rec.Value != ""
+		}, 30*time.Second, 100*time.Millisecond)
+		assert.Equal(t, name.AsPath().String(), rec.Name)
+		assert.Equal(t, publishPath, rec.Value)
+
+		// the record is kept across restarts, while its owner is offline
+		keeper.StopDaemon()
+		keeper.StartDaemon()
+		require.Eventually(t, func() bool {
+			return thirdPartyRecords(t, keeper)[0].Value == publishPath
+		}, 30*time.Second, 100*time.Millisecond)
+
+		reader.StartDaemon()
+		defer reader.StopDaemon()
+		reader.Connect(keeper)
+		keeper.AdvanceClock(config.DefaultIpnsRepublishThirdPartyPeriod)
+		require.Eventually(t, func() bool {
+			res := reader.RunIPFS("name", "resolve", name.String())
+			return res.Err == nil && res.Stdout.Trimmed() == publishPath
+		}, 30*time.Second, 500*time.Millisecond)
+	})
+}
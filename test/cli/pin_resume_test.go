@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinningResumeInterrupted(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	provider, node := nodes[0], nodes[1]
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Pinning.ResumeInterrupted = config.True
+	})
+	nodes.StartDaemons()
+	defer nodes.StopDaemons()
+
+	cid := provider.IPFSAddStr("pinned across restarts")
+
+	// Interrupt the pin by shutting down the daemon.
+	go node.RunIPFS("pin", "add", "--name", "resumed", cid)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(node.IPFS("bitswap", "wantlist").Stdout.String(), cid)
+	}, 10*time.Second, 100*time.Millisecond)
+	node.StopDaemon()
+
+	node.StartDaemon()
+	node.Connect(provider)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(node.IPFS("pin", "ls", "--names", "--type=recursive").Stdout.String(), cid+" recursive resumed")
+	}, 10*time.Second, 100*time.Millisecond)
+
+	t.Run("canceled pins are not resumed", func(t *testing.T) {
+		missing := provider.IPFSAddStr("never pinned", "--pin=false")
+		provider.IPFS("block", "rm", missing)
+
+		res := node.RunIPFS("pin", "add", "--timeout=1s", missing)
+		assert.Error(t, res.Err)
+		node.StopDaemon()
+		node.StartDaemon()
+		node.Connect(provider)
+		time.Sleep(time.Second)
+		assert.NotContains(t, node.IPFS("bitswap", "wantlist").Stdout.String(), missing)
+	})
+}
@@ -1,7 +1,10 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -327,4 +330,47 @@ func TestPins(t *testing.T) {
 		lsOut = pinLs("-t=recursive", "--names")
 		require.Contains(t, lsOut, outBDetailed)
 	})
+
+	t.Run("test pin update --diff-only", func(t *testing.T) {
+		t.Parallel()
+
+		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons().Connect()
+		defer nodes.StopDaemons()
+		source, node := nodes[0], nodes[1]
+
+		a, b, c := RandomStr(1000), RandomStr(1000), RandomStr(1000)
+		for name, content := range map[string]string{"v1/a": a, "v1/b": b, "v2/a": a, "v2/b": b, "v2/c": c} {
+			require.NoError(t, os.MkdirAll(filepath.Join(source.Dir, filepath.Dir(name)), 0o755))
+			source.WriteBytes(name, []byte(content))
+		}
+		cidV1 := source.IPFS("add", "-r", "-Q", filepath.Join(source.Dir, "v1")).Stdout.Trimmed()
+		cidV2 := source.IPFS("add", "-r", "-Q", filepath.Join(source.Dir, "v2")).Stdout.Trimmed()
+
+		node.IPFS("pin", "add", cidV1)
+
+		res := node.IPFS("pin", "update", "--diff-only", "--enc=json", cidV1, cidV2)
+		var out struct {
+			Pins []string
+			Diff struct {
+				Reused, New, Fetched, Announced int
+				NewBytes                        uint64
+			}
+		}
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+		require.Equal(t, []string{cidV1, cidV2}, out.Pins)
+		// the new directory and file c
+		assert.Equal(t, 2, out.Diff.New)
+		assert.Equal(t, 2, out.Diff.Fetched)
+		assert.Equal(t, 2, out.Diff.Announced)
+		assert.Equal(t, 2, out.Diff.Reused)
+		assert.Greater(t, out.Diff.NewBytes, uint64(1000))
+
+		lsOut := pinLs(node, "-t=recursive")
+		require.Contains(t, lsOut, cidV2+" recursive")
+		require.NotContains(t, lsOut, cidV1+" recursive")
+
+		res = node.RunIPFS("pin", "update", "--diff-only", cidV1, cidV2)
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "is not pinned recursively")
+	})
 }
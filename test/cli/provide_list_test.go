@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvideList(t *testing.T) {
+	t.Parallel()
+
+	t.Run("add, ls and rm", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		cid := node.IPFSAddStr("provide list", "--pin=false")
+
+		assert.Equal(t, cid+"\tnever", node.IPFS("provide", "add", cid).Stdout.Trimmed())
+		assert.Equal(t, cid+"\tnever", node.IPFS("provide", "ls").Stdout.Trimmed())
+
+		node.IPFS("provide", "rm", cid)
+		assert.Empty(t, node.IPFS("provide", "ls").Stdout.Trimmed())
+
+		res := node.RunIPFS("provide", "rm", cid)
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "not in the provide list")
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		cid := node.IPFSAddStr("expiring", "--pin=false")
+
+		out := node.IPFS("provide", "add", "--ttl=1s", cid).Stdout.Trimmed()
+		assert.True(t, strings.HasPrefix(out, cid+"\t"))
+		assert.NotContains(t, out, "never")
+
+		time.Sleep(1500 * time.Millisecond)
+		assert.Empty(t, node.IPFS("provide", "ls").Stdout.Trimmed())
+	})
+
+	t.Run("missing blocks are refused", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("provide", "add", "bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "not found locally")
+	})
+
+	t.Run("unpinned content is provided", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init()
+		nodes.ForEachPar(func(n *harness.Node) {
+			n.SetIPFSConfig("Reprovider.Strategy", "pinned")
+		})
+		nodes.StartDaemons().Connect()
+		defer nodes.StopDaemons()
+
+		cid := nodes[0].IPFSAddStr(time.Now().String(), "--pin=false", "--local")
+		require.Empty(t, nodes[1].IPFS("routing", "findprovs", "-n=1", cid).Stdout.String())
+
+		// the CID is queued for providing right away
+		nodes[0].IPFS("provide", "add", cid)
+		require.Eventually(t, func() bool {
+			return nodes[1].IPFS("routing", "findprovs", "-n=1", cid).Stdout.Trimmed() == nodes[0].PeerID().String()
+		}, 20*time.Second, 500*time.Millisecond)
+	})
+}
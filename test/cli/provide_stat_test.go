@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvideStat(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	res := node.RunIPFS("provide", "stat")
+	assert.Error(t, res.Err)
+	assert.Contains(t, res.Stderr.String(), "online mode")
+
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	// the first sweep starts a minute after startup
+	assert.Contains(t, node.IPFS("provide", "stat").Stdout.String(), "not started")
+
+	var stat struct {
+		Running   bool
+		Provided  uint64
+		NextSweep string
+	}
+	require.NoError(t, json.Unmarshal(node.IPFS("provide", "stat", "--enc=json").Stdout.Bytes(), &stat))
+	assert.False(t, stat.Running)
+	assert.Zero(t, stat.Provided)
+	assert.NotEmpty(t, stat.NextSweep)
+}
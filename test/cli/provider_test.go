@@ -2,9 +2,13 @@ package cli
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/test/cli/harness"
 	"github.com/ipfs/kubo/test/cli/testutils"
 	"github.com/stretchr/testify/require"
@@ -146,6 +150,115 @@ func TestProvider(t *testing.T) {
 		expectProviders(t, cidBarDir, nodes[0].PeerID().String(), nodes[1:]...)
 	})
 
+	t.Run("Reprovides with 'roots+entry-points' strategy", func(t *testing.T) {
+		t.Parallel()
+
+		nodes := initNodes(t, 2, func(n *harness.Node) {
+			n.SetIPFSConfig("Reprovider.Strategy", "roots+entry-points")
+		})
+		defer nodes.StopDaemons()
+
+		dir := filepath.Join(nodes[0].Dir, "site")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "assets"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "assets", "large.bin"), testutils.RandomBytes(1000), 0o644))
+		cidFoo := nodes[0].IPFSAdd(bytes.NewReader(testutils.RandomBytes(1000)), "--offline", "--pin=false")
+		cidSite := nodes[0].IPFS("add", "-r", "-Q", "--offline", "--chunker=size-100", dir).Stdout.Trimmed()
+		resolve := func(p string) string {
+			return strings.TrimPrefix(nodes[0].IPFS("resolve", "/ipfs/"+cidSite+p).Stdout.Trimmed(), "/ipfs/")
+		}
+		cidAssets := resolve("/assets")
+		cidLarge := resolve("/assets/large.bin")
+		cidChunk := nodes[0].IPFS("refs", cidLarge).Stdout.Lines()[0]
+
+		nodes[0].IPFS("bitswap", "reprovide")
+
+		expectNoProviders(t, cidFoo, nodes[1:]...)
+		expectNoProviders(t, cidChunk, nodes[1:]...)
+		expectProviders(t, cidSite, nodes[0].PeerID().String(), nodes[1:]...)
+		expectProviders(t, cidAssets, nodes[0].PeerID().String(), nodes[1:]...)
+		expectProviders(t, cidLarge, nodes[0].PeerID().String(), nodes[1:]...)
+	})
+
+	t.Run("Reprovides with 'custom' strategy filtering by size", func(t *testing.T) {
+		t.Parallel()
+
+		small := testutils.RandomBytes(100)
+		large := testutils.RandomBytes(2000)
+
+		nodes := initNodes(t, 2, func(n *harness.Node) {
+			n.UpdateConfig(func(cfg *config.Config) {
+				cfg.Reprovider.Strategy = config.NewOptionalString("custom")
+				cfg.Reprovider.Filter = &config.ReproviderFilter{
+					Base:    config.NewOptionalString("pinned"),
+					MinSize: config.NewOptionalString("1KiB"),
+				}
+			})
+		})
+		defer nodes.StopDaemons()
+
+		cidSmall := nodes[0].IPFSAdd(bytes.NewReader(small), "--offline")
+		cidLarge := nodes[0].IPFSAdd(bytes.NewReader(large), "--offline")
+
+		nodes[0].IPFS("bitswap", "reprovide")
+
+		expectNoProviders(t, cidSmall, nodes[1:]...)
+		expectProviders(t, cidLarge, nodes[0].PeerID().String(), nodes[1:]...)
+	})
+
+	t.Run("Reprovides with 'custom' strategy filtering with a command", func(t *testing.T) {
+		t.Parallel()
+
+		foo := testutils.RandomBytes(1000)
+		bar := testutils.RandomBytes(1000)
+
+		h := harness.NewT(t)
+		nodes := h.NewNodes(2).Init()
+		cidBar := nodes[0].IPFSAdd(bytes.NewReader(bar), "--only-hash")
+		script := filepath.Join(nodes[0].Dir, "filter.sh")
+		require.NoError(t, os.WriteFile(script, []byte(`while read c; do
+  if [ "$c" = "`+cidBar+`" ]; then echo yes; else echo no; fi
+done
+`), 0o644))
+		nodes[0].UpdateConfig(func(cfg *config.Config) {
+			cfg.Reprovider.Strategy = config.NewOptionalString("custom")
+			cfg.Reprovider.Filter = &config.ReproviderFilter{
+				Base:    config.NewOptionalString("pinned"),
+				Command: []string{"sh", script},
+			}
+		})
+		nodes.StartDaemons().Connect()
+		defer nodes.StopDaemons()
+
+		cidFoo := nodes[0].IPFSAdd(bytes.NewReader(foo), "--offline")
+		nodes[0].IPFSAdd(bytes.NewReader(bar), "--offline")
+
+		nodes[0].IPFS("bitswap", "reprovide")
+
+		expectNoProviders(t, cidFoo, nodes[1:]...)
+		expectProviders(t, cidBar, nodes[0].PeerID().String(), nodes[1:]...)
+	})
+
+	t.Run("Provides on the first request with 'reactive' strategy", func(t *testing.T) {
+		t.Parallel()
+
+		nodes := harness.NewT(t).NewNodes(3).Init()
+		nodes[0].SetIPFSConfig("Reprovider.Strategy", "reactive")
+		// the requesting node does not provide the block it fetched
+		nodes[1].SetIPFSConfig("Experimental.StrategicProviding", true)
+		nodes.StartDaemons().Connect()
+		defer nodes.StopDaemons()
+
+		cid := nodes[0].IPFSAddStr(time.Now().String())
+		expectNoProviders(t, cid, nodes[2])
+
+		nodes[1].IPFS("block", "get", cid)
+
+		require.Eventually(t, func() bool {
+			res := nodes[2].IPFS("routing", "findprovs", "-n=1", cid)
+			return res.Stdout.Trimmed() == nodes[0].PeerID().String()
+		}, 30*time.Second, 100*time.Millisecond)
+	})
+
 	t.Run("Providing works without ticking", func(t *testing.T) {
 		t.Parallel()
 
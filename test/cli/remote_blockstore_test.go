@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteBlockstore(t *testing.T) {
+	t.Parallel()
+
+	h := harness.NewT(t)
+	source := h.NewNode().Init()
+	stored := source.IPFSAddStr("stored remotely")
+	corrupted := source.IPFSAddStr("corrupted remotely")
+	missing := source.IPFSAddStr("missing remotely")
+
+	blocks := map[string][]byte{
+		stored:    source.IPFS("block", "get", stored).Stdout.Bytes(),
+		corrupted: []byte("not the block"),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, ok := blocks[strings.TrimPrefix(r.URL.Path, "/blocks/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	node := h.NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Bitswap.Enabled = config.False
+		cfg.Bitswap.RemoteBlockstore = &config.BitswapRemoteBlockstore{
+			URL:     srv.URL + "/blocks/{cid}",
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+		}
+	})
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	assert.Equal(t, "stored remotely", node.IPFS("cat", stored).Stdout.String())
+	assert.Equal(t, 0, node.RunIPFS("block", "stat", "--offline", stored).ExitCode(), "blocks read remotely are stored locally")
+
+	res := node.RunIPFS("cat", corrupted)
+	assert.Error(t, res.Err)
+	assert.Contains(t, res.Stderr.String(), "does not match its CID")
+
+	start := time.Now()
+	res = node.RunIPFS("cat", missing)
+	assert.Error(t, res.Err)
+	assert.Contains(t, res.Stderr.String(), "not available locally nor in Bitswap.RemoteBlockstore")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	t.Run("requires Bitswap to be disabled", func(t *testing.T) {
+		t.Parallel()
+		n := h.NewNode().Init()
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Bitswap.RemoteBlockstore = &config.BitswapRemoteBlockstore{URL: srv.URL + "/blocks/{cid}"}
+		})
+		res := n.RunIPFS("daemon")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "Bitswap.RemoteBlockstore requires Bitswap.Enabled to be false")
+	})
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoExportImportCar(t *testing.T) {
+	t.Parallel()
+
+	h := harness.NewT(t)
+	source := h.NewNode().Init().StartDaemon()
+	defer source.StopDaemon()
+
+	large := source.IPFSAddStr(testutils.RandomStr(8<<10), "--chunker=size-1024")
+	named := source.IPFSAddStr("named pin", "--pin=false")
+	source.IPFS("pin", "add", "--name", "important", named)
+	direct := source.IPFSAddStr("direct pin", "--pin=false")
+	source.IPFS("pin", "add", "-r=false", direct)
+	unpinned := source.IPFSAddStr("not pinned", "--pin=false")
+
+	out := filepath.Join(h.Dir, "backup.car")
+	res := source.IPFS("repo", "export-car", "--all-pinned", "-o", out, "--shard-size", "4KiB")
+	shards := res.Stdout.Lines()
+	assert.Greater(t, len(shards), 1)
+
+	var manifest struct {
+		Pins []struct {
+			Cid  string
+			Type string
+			Name string
+		}
+		Shards []string
+	}
+	data, err := os.ReadFile(filepath.Join(h.Dir, "backup.manifest.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, shards, manifest.Shards)
+	// The three pins above plus the empty directory pinned by init.
+	assert.Len(t, manifest.Pins, 4)
+
+	target := h.NewNode().Init().StartDaemon()
+	defer target.StopDaemon()
+
+	target.IPFS(append([]string{"repo", "import-car"}, shards...)...)
+
+	assert.Contains(t, target.IPFS("pin", "ls", "--type=recursive").Stdout.String(), large)
+	assert.Contains(t, target.IPFS("pin", "ls", "--type=recursive", "--names").Stdout.String(), named+" recursive important")
+	assert.Contains(t, target.IPFS("pin", "ls", "--type=direct").Stdout.String(), direct)
+	assert.NoError(t, target.RunIPFS("cat", "--offline", large).Err)
+	assert.Error(t, target.RunIPFS("block", "stat", "--offline", unpinned).Err)
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrievalProbe(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes.ForEachPar(func(n *harness.Node) {
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Routing.Type = config.NewOptionalString("none")
+		})
+	})
+
+	// the second node is the probe node retrieving the blocks of the first
+	probe := nodes[1].StartDaemon()
+	defer probe.StopDaemon()
+	offline := "http://127.0.0.1:1"
+	nodes[0].UpdateConfig(func(cfg *config.Config) {
+		cfg.Probes.Retrieval = config.RetrievalProbe{
+			Enabled:  config.True,
+			Gateways: []string{probe.GatewayURL(), offline},
+			Interval: config.NewOptionalDuration(time.Second),
+			Timeout:  config.NewOptionalDuration(3 * time.Second),
+		}
+	})
+	nodes[0].StartDaemon()
+	defer nodes[0].StopDaemon()
+	nodes[0].Connect(probe)
+
+	retrieved := regexp.MustCompile(fmt.Sprintf(`ipfs_probes_retrieval_latency_seconds_count\{gateway=%q\} [1-9]`, probe.GatewayURL()))
+	failed := regexp.MustCompile(fmt.Sprintf(`ipfs_probes_retrieval_failures_total\{gateway=%q\} [1-9]`, offline))
+	assert.Eventually(t, func() bool {
+		metrics := nodes[0].APIClient().Get("/debug/metrics/prometheus").Body
+		return retrieved.MatchString(metrics) &&
+			strings.Contains(metrics, fmt.Sprintf("ipfs_probes_retrieval_failures_total{gateway=%q} 0", probe.GatewayURL())) &&
+			failed.MatchString(metrics)
+	}, 30*time.Second, 500*time.Millisecond)
+}
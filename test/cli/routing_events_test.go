@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/routing"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseRoutingEvents(t *testing.T, out []byte) []routing.Event {
+	var events []routing.Event
+	for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var ev routing.Event
+		require.NoError(t, json.Unmarshal(line, &ev))
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestRoutingEvents(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	// blocks are only announced by 'ipfs routing provide'
+	nodes[0].UpdateConfig(func(cfg *config.Config) {
+		cfg.Experimental.StrategicProviding = true
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+	cid := nodes[0].IPFSAddStr("routing events")
+
+	follow := nodes[0].Runner.Run(harness.RunRequest{
+		Path:    nodes[0].IPFSBin,
+		Args:    []string{"routing", "events", "--follow", "--enc=json"},
+		RunFunc: (*exec.Cmd).Start,
+	})
+	defer func() { _ = follow.Cmd.Process.Kill() }()
+
+	// the follower may subscribe after the first provides
+	var provide []routing.Event
+	require.Eventually(t, func() bool {
+		nodes[0].IPFS("routing", "provide", cid)
+		provide = nil
+		for _, ev := range parseRoutingEvents(t, follow.Stdout.Bytes()) {
+			if ev.Method == "Provide" && ev.Key == cid {
+				provide = append(provide, ev)
+			}
+		}
+		return len(provide) > 0 && provide[len(provide)-1].Type == routing.EventFinished
+	}, time.Minute, 500*time.Millisecond)
+
+	op := provide[len(provide)-1].Op
+	var types []string
+	for _, ev := range provide {
+		if ev.Op == op {
+			types = append(types, ev.Type)
+		}
+	}
+	assert.Equal(t, routing.EventStarted, types[0])
+	assert.Contains(t, types, routing.EventSendingQuery)
+	assert.Contains(t, types, routing.EventPeerResponse)
+	assert.Equal(t, routing.EventFinished, types[len(types)-1])
+
+	t.Run("lists the finished operations", func(t *testing.T) {
+		var found bool
+		for _, ev := range parseRoutingEvents(t, nodes[0].IPFS("routing", "events", "--enc=json").Stdout.Bytes()) {
+			assert.Equal(t, routing.EventFinished, ev.Type)
+			found = found || (ev.Method == "Provide" && ev.Key == cid && ev.Error == "")
+		}
+		assert.True(t, found)
+		assert.Contains(t, nodes[0].IPFS("routing", "events").Stdout.String(), "Provide "+cid+" finished in ")
+	})
+
+	t.Run("fails offline", func(t *testing.T) {
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("routing", "events")
+		assert.Error(t, res.Err)
+	})
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/routing/http/server"
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// providingRouter returns the same provider for every CID.
+type providingRouter struct {
+	provider peer.ID
+}
+
+func (r *providingRouter) FindProviders(ctx context.Context, key cid.Cid, limit int) (iter.ResultIter[types.Record], error) {
+	return iter.FromSlice([]iter.Result[types.Record]{
+		{Val: &types.PeerRecord{Schema: types.SchemaPeer, ID: &r.provider}},
+	}), nil
+}
+
+// nolint deprecated
+func (r *providingRouter) ProvideBitswap(ctx context.Context, req *server.BitswapWriteProvideRequest) (time.Duration, error) {
+	return 0, routing.ErrNotSupported
+}
+
+func (r *providingRouter) FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[*types.PeerRecord], error) {
+	return iter.FromSlice([]iter.Result[*types.PeerRecord]{}), nil
+}
+
+func (r *providingRouter) GetIPNS(ctx context.Context, name ipns.Name) (*ipns.Record, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *providingRouter) PutIPNS(ctx context.Context, name ipns.Name, rec *ipns.Record) error {
+	return routing.ErrNotSupported
+}
+
+func TestRoutingInspect(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init()
+	srv := httptest.NewServer(server.Handler(&providingRouter{provider: node.PeerID()}))
+	t.Cleanup(srv.Close)
+	down := httptest.NewServer(nil)
+	down.Close()
+
+	httpRouter := func(endpoint string) config.RouterParser {
+		return config.RouterParser{Router: config.Router{
+			Type:       config.RouterTypeHTTP,
+			Parameters: &config.HTTPRouterParams{Endpoint: endpoint},
+		}}
+	}
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Routing.Type = config.NewOptionalString("custom")
+		cfg.Routing.Routers = config.Routers{
+			"up":     httpRouter(srv.URL),
+			"down":   httpRouter(down.URL),
+			"unused": httpRouter(srv.URL),
+			"all": config.RouterParser{Router: config.Router{
+				Type: config.RouterTypeParallel,
+				Parameters: &config.ComposableRouterParams{
+					Routers: []config.ConfigRouter{
+						{RouterName: "up", Timeout: config.Duration{Duration: 10 * time.Second}},
+						{RouterName: "down", IgnoreErrors: true, ExecuteAfter: config.NewOptionalDuration(time.Second)},
+					},
+					Timeout: config.NewOptionalDuration(time.Minute),
+				},
+			}},
+		}
+		cfg.Routing.Methods = config.Methods{
+			config.MethodNameFindPeers:     {RouterName: "up"},
+			config.MethodNameFindProviders: {RouterName: "all"},
+			config.MethodNameGetIPNS:       {RouterName: "up"},
+			config.MethodNamePutIPNS:       {RouterName: "up"},
+			config.MethodNameProvide:       {RouterName: "all"},
+		}
+	})
+
+	t.Run("prints the router tree of each method offline", func(t *testing.T) {
+		out := node.IPFS("routing", "inspect").Stdout.String()
+		assert.Contains(t, out, "find-providers:\n  all (parallel)\n    up (http, "+srv.URL+", timeout 10s)\n    down (http, "+down.URL+", after 1s, ignore errors)\n")
+		assert.Contains(t, out, "find-peers:\n  up (http, "+srv.URL+")\n")
+		assert.Contains(t, out, `warning: router "unused" is not used by any method`)
+		assert.Contains(t, out, `warning: Timeout of router "all" is ignored`)
+	})
+
+	t.Run("invalid configs are reported", func(t *testing.T) {
+		bad := harness.NewT(t).NewNode().Init()
+		bad.UpdateConfig(func(cfg *config.Config) {
+			cfg.Routing.Type = config.NewOptionalString("custom")
+			cfg.Routing.Routers = config.Routers{"up": httpRouter(srv.URL)}
+			cfg.Routing.Methods = config.Methods{
+				config.MethodNameFindPeers:     {RouterName: "up"},
+				config.MethodNameFindProviders: {RouterName: "missing"},
+				config.MethodNameGetIPNS:       {RouterName: "up"},
+				config.MethodNamePutIPNS:       {RouterName: "up"},
+				config.MethodNameProvide:       {RouterName: "up"},
+			}
+		})
+		res := bad.RunIPFS("routing", "inspect")
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), `config for router with name "missing" not found`)
+	})
+
+	t.Run("--test traces the routers queried", func(t *testing.T) {
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		c := node.PipeStrToIPFS("not stored", "add", "-qn").Stdout.Trimmed()
+		var out struct {
+			Trace []struct {
+				Router  string
+				Method  string
+				Results int
+			}
+			Providers int
+		}
+		require.NoError(t, json.Unmarshal(node.IPFS("routing", "inspect", "--enc=json", "--test", c).Stdout.Bytes(), &out))
+		assert.Equal(t, 1, out.Providers)
+
+		results := map[string]int{}
+		for _, ev := range out.Trace {
+			assert.Equal(t, "FindProviders", ev.Method)
+			results[ev.Router] = ev.Results
+		}
+		assert.Equal(t, map[string]int{"up": 1, "down": 0, "all": 1}, results)
+	})
+}
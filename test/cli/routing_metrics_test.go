@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/routing/http/server"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/ipfs/kubo/test/cli/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutingMetrics(t *testing.T) {
+	t.Parallel()
+
+	cr := &fakeHTTPContentRouter{}
+	server := httptest.NewServer(server.Handler(cr))
+	t.Cleanup(server.Close)
+
+	node := harness.NewT(t).NewNode().Init()
+	node.Runner.Env["IPFS_HTTP_ROUTERS"] = server.URL
+	node.StartDaemon()
+	defer node.StopDaemon()
+
+	c := node.PipeStrToIPFS(string(testutils.RandomBytes(100)), "add", "-qn").Stdout.Trimmed()
+	node.IPFS("routing", "findprovs", "--num-providers=1", c)
+	assert.Equal(t, 1, cr.numFindProvidersCalls())
+
+	metrics := node.APIClient().Get("/debug/metrics/prometheus").Body
+	requests := func(router string) []string {
+		var lines []string
+		for _, line := range strings.Split(metrics, "\n") {
+			if strings.HasPrefix(line, "ipfs_routing_router_requests_total{") && strings.Contains(line, `router="`+router+`"`) {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+	assert.Contains(t, requests(server.URL), `ipfs_routing_router_requests_total{method="FindProviders",result="success",router="`+server.URL+`"} 1`)
+	assert.NotEmpty(t, requests("dht"))
+	assert.Contains(t, metrics, `ipfs_routing_router_request_duration_seconds_count{method="FindProviders",router="`+server.URL+`"} 1`)
+}
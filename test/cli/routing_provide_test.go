@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutingProvideRecursive(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	// blocks are only announced by 'ipfs routing provide'
+	nodes[0].UpdateConfig(func(cfg *config.Config) {
+		cfg.Experimental.StrategicProviding = true
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	root := nodes[0].IPFSAddStr(strings.Repeat("a", 10)+strings.Repeat("b", 10)+strings.Repeat("c", 10), "--chunker=size-10", "--raw-leaves")
+	blocks := nodes[0].IPFS("refs", "-r", "-u", root).Stdout.Lines()
+	assert.Len(t, blocks, 3)
+
+	out := nodes[0].IPFS("routing", "provide", "--recursive", "--batch-size=3", "--progress", root).Stdout.Trimmed()
+	assert.Equal(t, "provided 3 of 4 blocks\nprovided 4 of 4 blocks", out)
+
+	for _, c := range append(blocks, root) {
+		res := nodes[1].IPFS("routing", "findprovs", "--num-providers=1", c)
+		assert.Equal(t, nodes[0].PeerID().String(), res.Stdout.Trimmed())
+	}
+}
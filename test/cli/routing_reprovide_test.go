@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutingReprovide(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(3).Init()
+	nodes.ForEachPar(func(n *harness.Node) {
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Routing.Type = config.NewOptionalString("dht")
+		})
+	})
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	cid := nodes[0].IPFSAddStr("reprovided")
+
+	var out struct {
+		Cid     string
+		Blocks  int
+		Routers []struct {
+			Router    string
+			Announced int
+			Verified  bool
+			Found     bool
+			Queried   int
+			FoundOn   int
+		}
+	}
+	require.NoError(t, json.Unmarshal(nodes[0].IPFS("routing", "reprovide", "--enc=json", cid).Stdout.Bytes(), &out))
+	assert.Equal(t, cid, out.Cid)
+	assert.Equal(t, 1, out.Blocks)
+
+	var lan bool
+	for _, r := range out.Routers {
+		if r.Router != "dht-lan" {
+			continue
+		}
+		lan = true
+		assert.Equal(t, 1, r.Announced)
+		assert.True(t, r.Verified)
+		assert.True(t, r.Found)
+		assert.Equal(t, 2, r.Queried)
+		assert.Equal(t, 2, r.FoundOn)
+	}
+	assert.True(t, lan, "the LAN DHT is reported")
+
+	assert.Contains(t, nodes[0].IPFS("routing", "reprovide", cid).Stdout.String(), "dht-lan: announced 1 blocks; record on 2 of 2 closest peers")
+
+	missing := nodes[0].PipeStrToIPFS("missing", "add", "-qn").Stdout.Trimmed()
+	res := nodes[0].RunIPFS("routing", "reprovide", missing)
+	assert.Equal(t, 1, res.ExitCode())
+	assert.Contains(t, res.Stderr.String(), "not found locally")
+}
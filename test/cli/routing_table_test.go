@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutingTable(t *testing.T) {
+	t.Parallel()
+
+	type snapshot struct {
+		WAN []struct{ ID string }
+		LAN []struct{ ID string }
+	}
+	export := func(node *harness.Node) snapshot {
+		var s snapshot
+		require.NoError(t, json.Unmarshal(node.IPFS("routing", "table", "export").Stdout.Bytes(), &s))
+		return s
+	}
+	peers := func(s snapshot) []string {
+		var ids []string
+		for _, ai := range append(s.WAN, s.LAN...) {
+			ids = append(ids, ai.ID)
+		}
+		return ids
+	}
+
+	t.Run("exports and imports the routing table", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(3).Init()
+		nodes.ForEachPar(func(node *harness.Node) {
+			node.IPFS("config", "Routing.Type", "dht")
+		})
+		nodes.StartDaemons()
+		defer nodes.StopDaemons()
+		nodes[0].Connect(nodes[1])
+
+		require.Eventually(t, func() bool {
+			return len(peers(export(nodes[0]))) > 0
+		}, 20*time.Second, 100*time.Millisecond)
+		assert.Contains(t, peers(export(nodes[0])), nodes[1].PeerID().String())
+
+		// nodes[2] knows nodes[1] from the exported table, without being
+		// connected to it
+		file := nodes[0].IPFS("routing", "table", "export").Stdout.Bytes()
+		nodes[2].WriteBytes("routing-table.json", file)
+		res := nodes[2].IPFS("routing", "table", "import", "routing-table.json")
+		assert.Equal(t, "Added 1 peers to the routing table", res.Stdout.Trimmed())
+		assert.Contains(t, peers(export(nodes[2])), nodes[1].PeerID().String())
+	})
+
+	t.Run("Routing.PersistTable preloads the table saved on shutdown", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init()
+		nodes.ForEachPar(func(node *harness.Node) {
+			node.IPFS("config", "Routing.Type", "dht")
+			node.UpdateConfig(func(cfg *config.Config) {
+				cfg.Routing.PersistTable = config.True
+			})
+		})
+		nodes.StartDaemons().Connect()
+		defer nodes.StopDaemons()
+		require.Eventually(t, func() bool {
+			return len(peers(export(nodes[0]))) > 0
+		}, 20*time.Second, 100*time.Millisecond)
+		nodes[0].StopDaemon()
+
+		nodes[0].StartDaemon()
+		assert.Contains(t, peers(export(nodes[0])), nodes[1].PeerID().String())
+	})
+}
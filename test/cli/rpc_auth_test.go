@@ -2,6 +2,9 @@ package cli
 
 import (
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/ipfs/kubo/client/rpc/auth"
@@ -157,6 +160,92 @@ func TestRPCAuth(t *testing.T) {
 		resp := apiClient.Post("/api/v0/id", nil)
 		assert.Equal(t, 200, resp.StatusCode)
 
+		node.StopDaemon()
+	})
+	t.Run("Quotas limit requests and pins per token", func(t *testing.T) {
+		t.Parallel()
+
+		node := makeAndStartProtectedNode(t, map[string]*config.RPCAuthScope{
+			"userA": {
+				AuthSecret:   "bearer:userAToken",
+				AllowedPaths: []string{"/api/v0"},
+				Quotas: &config.RPCAuthQuotas{
+					MaxPins:        config.NewOptionalInteger(1),
+					MaxPinnedBytes: config.NewOptionalString("1KiB"),
+				},
+			},
+			"userB": {
+				AuthSecret:   "bearer:userBToken",
+				AllowedPaths: []string{"/api/v0"},
+				Quotas: &config.RPCAuthQuotas{
+					RequestsPerSecond: config.NewOptionalInteger(1),
+				},
+			},
+		})
+
+		res := node.RunPipeToIPFS(strings.NewReader("first"), "add", "-q", "--api-auth", "bearer:userAToken")
+		require.NoError(t, res.Err)
+		first := res.Stdout.Trimmed()
+
+		res = node.RunPipeToIPFS(strings.NewReader("second"), "add", "-q", "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "RPC quota exceeded")
+
+		// Unpinned content is allowed, and the quota frees up on unpin.
+		res = node.RunPipeToIPFS(strings.NewReader("second"), "add", "-q", "--pin=false", "--api-auth", "bearer:userAToken")
+		require.NoError(t, res.Err)
+		second := res.Stdout.Trimmed()
+		res = node.RunIPFS("pin", "add", second, "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		node.IPFS("pin", "rm", first, "--api-auth", "bearer:userAToken")
+		res = node.RunIPFS("pin", "add", second, "--api-auth", "bearer:userAToken")
+		require.NoError(t, res.Err)
+
+		// Too large for MaxPinnedBytes.
+		res = node.RunPipeToIPFS(strings.NewReader(strings.Repeat("x", 4096)), "add", "-q", "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "RPC quota exceeded")
+
+		// Other commands creating pins are charged too.
+		res = node.RunPipeToIPFS(strings.NewReader("third"), "add", "-q", "--pin=false", "--api-auth", "bearer:test-node-starter")
+		require.NoError(t, res.Err)
+		third := res.Stdout.Trimmed()
+		car := filepath.Join(node.Dir, "third.car")
+		require.NoError(t, os.WriteFile(car, node.IPFS("dag", "export", third, "--api-auth", "bearer:test-node-starter").Stdout.Bytes(), 0o644))
+		res = node.RunIPFS("dag", "import", "--pin-roots", car, "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "RPC quota exceeded")
+		res = node.RunIPFS("pin", "update", "--unpin=false", second, third, "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "RPC quota exceeded")
+		// but replacing a pin releases its quota
+		res = node.RunIPFS("pin", "update", second, third, "--api-auth", "bearer:userAToken")
+		require.NoError(t, res.Err)
+		res = node.RunIPFS("pin", "add", second, "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		pins := node.IPFS("pin", "ls", "--type=recursive", "-q", "--api-auth", "bearer:test-node-starter").Stdout.String()
+		res = node.RunPipeToIPFS(strings.NewReader(`{"a": 1}`), "dag", "put", "--pin", "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "RPC quota exceeded")
+		res = node.RunPipeToIPFS(strings.NewReader("block"), "block", "put", "--pin", "--api-auth", "bearer:userAToken")
+		require.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "RPC quota exceeded")
+		assert.Equal(t, pins, node.IPFS("pin", "ls", "--type=recursive", "-q", "--api-auth", "bearer:test-node-starter").Stdout.String())
+
+		apiClient := node.APIClient()
+		apiClient.Client = &http.Client{
+			Transport: auth.NewAuthorizedRoundTripper("Bearer userBToken", http.DefaultTransport),
+		}
+		var limited bool
+		for i := 0; i < 5 && !limited; i++ {
+			limited = apiClient.Post("/api/v0/id", nil).StatusCode == http.StatusTooManyRequests
+		}
+		assert.True(t, limited)
+
+		res = node.IPFS("stats", "quota", "--api-auth", "bearer:test-node-starter")
+		assert.Contains(t, res.Stdout.String(), "userA")
+		assert.Contains(t, res.Stdout.String(), "userB")
+
 		node.StopDaemon()
 	})
 }
@@ -0,0 +1,74 @@
+//go:build linux
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION); errno != 0 {
+		t.Skipf("Landlock is not available: %s", errno)
+	}
+
+	// the files of the filestore, next to the repo, are read by the daemon
+	newNode := func(t *testing.T, readOnly ...string) (*harness.Node, string) {
+		node := harness.NewT(t).NewNode().Init()
+		outside := filepath.Join(filepath.Dir(node.Dir), "outside")
+		require.NoError(t, os.Mkdir(outside, 0o700))
+		file := filepath.Join(outside, "file.txt")
+		require.NoError(t, os.WriteFile(file, []byte("outside of the repo"), 0o600))
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Experimental.FilestoreEnabled = true
+			cfg.Security.Sandbox.Enabled = config.True
+			for _, p := range readOnly {
+				cfg.Security.Sandbox.ReadOnlyPaths = append(cfg.Security.Sandbox.ReadOnlyPaths, fmt.Sprintf(p, outside))
+			}
+		})
+		return node, file
+	}
+
+	t.Run("the daemon only accesses the repo", func(t *testing.T) {
+		t.Parallel()
+		node, file := newNode(t)
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", node.Daemon.Cmd.Process.Pid))
+		require.NoError(t, err)
+		assert.Contains(t, string(status), "NoNewPrivs:\t1")
+
+		cid := node.IPFSAddStr("in the repo")
+		assert.Equal(t, "in the repo", node.IPFS("cat", cid).Stdout.String())
+		res := node.RunIPFS("add", "-q", "--nocopy", file)
+		assert.NotEqual(t, 0, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "permission denied")
+	})
+
+	t.Run("the daemon accesses Security.Sandbox.ReadOnlyPaths", func(t *testing.T) {
+		t.Parallel()
+		node, file := newNode(t, "%s")
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		cid := node.IPFS("add", "-q", "--nocopy", file).Stdout.Trimmed()
+		assert.Equal(t, "outside of the repo", node.IPFS("cat", cid).Stdout.String())
+	})
+	t.Run("the daemon refuses FUSE mounts", func(t *testing.T) {
+		t.Parallel()
+		node, _ := newNode(t)
+		res := node.RunIPFS("daemon", "--mount")
+		assert.NotEqual(t, 0, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "FUSE mounts are not supported in the sandbox")
+	})
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs tasks periodically and reports their status", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init()
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Schedule.Tasks = []config.ScheduleTask{
+				{Name: "gc", Command: []string{"repo", "gc"}, Interval: config.NewOptionalDuration(time.Second)},
+				{Name: "broken", Command: []string{"pin", "rm", "bafkqaaa"}, Interval: config.NewOptionalDuration(time.Second)},
+				{Name: "nightly", Command: []string{"pin", "verify"}, At: config.NewOptionalString("03:00")},
+			}
+		})
+		n.StartDaemon()
+		defer n.StopDaemon()
+
+		cid := n.IPFSAddStr("garbage", "--pin=false")
+
+		var tasks map[string]node.ScheduledTaskStatus
+		assert.Eventually(t, func() bool {
+			tasks = scheduleLs(t, n)
+			return tasks["gc"].Runs > 0 && tasks["broken"].Runs > 0
+		}, 10*time.Second, 100*time.Millisecond)
+
+		assert.Empty(t, tasks["gc"].LastError)
+		assert.Error(t, n.RunIPFS("block", "stat", "--offline", cid).Err)
+		assert.NotEmpty(t, tasks["broken"].LastError)
+
+		nightly := tasks["nightly"]
+		assert.Zero(t, nightly.Runs)
+		assert.Equal(t, 3, nightly.NextRun.Hour())
+		assert.Equal(t, 0, nightly.NextRun.Minute())
+		assert.WithinDuration(t, time.Now().Add(12*time.Hour), nightly.NextRun, 12*time.Hour)
+	})
+
+	t.Run("daemon refuses unknown commands", func(t *testing.T) {
+		t.Parallel()
+		n := harness.NewT(t).NewNode().Init()
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Schedule.Tasks = []config.ScheduleTask{{Name: "typo", Command: []string{"repo", "gcc"}}}
+		})
+		res := n.RunIPFS("daemon")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), `Schedule.Tasks "typo"`)
+	})
+}
+
+func scheduleLs(t *testing.T, n *harness.Node) map[string]node.ScheduledTaskStatus {
+	var out struct{ Tasks []node.ScheduledTaskStatus }
+	require.NoError(t, json.Unmarshal(n.IPFS("schedule", "ls", "--enc=json").Stdout.Bytes(), &out))
+	tasks := make(map[string]node.ScheduledTaskStatus)
+	for _, task := range out.Tasks {
+		tasks[task.Name] = task
+	}
+	return tasks
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fails without Search.Enabled", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("search", "*")
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "Search.Enabled")
+	})
+
+	t.Run("finds pinned and MFS files by name", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Search.Enabled = config.True
+		})
+		node.StartDaemon()
+		defer node.StopDaemon()
+
+		require.NoError(t, os.MkdirAll(filepath.Join(node.Dir, "data", "2024"), 0o755))
+		node.WriteBytes("data/2024/report-q1.pdf", []byte("q1"))
+		node.WriteBytes("data/2024/notes.txt", []byte("notes"))
+		node.WriteBytes("data/report.pdf", []byte("report"))
+		root := node.IPFS("add", "-r", "-Q", filepath.Join(node.Dir, "data")).Stdout.Trimmed()
+
+		search := func(args ...string) []string {
+			return node.IPFS(append([]string{"search"}, args...)...).Stdout.Lines()
+		}
+		require.Eventually(t, func() bool {
+			return len(search("report*.pdf")) == 2
+		}, 30*time.Second, 100*time.Millisecond)
+		assert.ElementsMatch(t, []string{"/ipfs/" + root + "/report.pdf", "/ipfs/" + root + "/2024/report-q1.pdf"}, search("report*.pdf"))
+		assert.Equal(t, []string{"/ipfs/" + root + "/2024/report-q1.pdf"}, search("2024/*.pdf", "--root="+root))
+		assert.Equal(t, []string{"/ipfs/" + root + "/2024/notes.txt"}, search("*.txt", "--root=/ipfs/"+root+"/2024"))
+
+		node.IPFS("files", "mkdir", "/docs")
+		node.IPFS("files", "cp", "/ipfs/"+root+"/2024", "/docs/2024")
+		require.Eventually(t, func() bool {
+			return len(search("notes.txt")) == 2
+		}, 30*time.Second, 100*time.Millisecond)
+		assert.ElementsMatch(t, []string{"/ipfs/" + root + "/2024/notes.txt", "/docs/2024/notes.txt"}, search("notes.txt"))
+
+		node.IPFS("pin", "rm", root)
+		require.Eventually(t, func() bool {
+			return len(search("report*.pdf")) == 1
+		}, 30*time.Second, 100*time.Millisecond)
+		assert.Equal(t, []string{"/docs/2024/report-q1.pdf"}, search("report*.pdf"))
+
+		res := node.RunIPFS("search", "*", "--root="+root)
+		assert.Error(t, res.Err)
+		assert.Contains(t, res.Stderr.String(), "not in the search index")
+	})
+}
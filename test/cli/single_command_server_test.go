@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleCommandServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("gateway-only serves the gateway without the optional subsystems", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Search.Enabled = config.True
+		})
+		node.StartDaemon("--single-command-server=gateway-only")
+		defer node.StopDaemon()
+
+		stdout := node.Daemon.Stdout.String()
+		assert.Contains(t, stdout, "Running as a single command server: gateway-only")
+		assert.Contains(t, stdout, "Gateway server listening")
+
+		c := node.IPFSAddStr("served by the gateway")
+		assert.Equal(t, "served by the gateway", node.GatewayClient().Get("/ipfs/"+c).Body)
+
+		// the search index is left out of the node
+		res := node.RunIPFS("search", "served")
+		assert.Equal(t, 1, res.ExitCode())
+
+		// and so is the provider system
+		res = node.RunIPFS("provide", "stat")
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "reprovide sweeps are not running")
+	})
+
+	t.Run("pin-worker-only does not serve the gateway", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		node.StartDaemon("--single-command-server=pin-worker-only")
+		defer node.StopDaemon()
+
+		stdout := node.Daemon.Stdout.String()
+		assert.Contains(t, stdout, "Running as a single command server: pin-worker-only")
+		assert.NotContains(t, stdout, "Gateway server listening")
+
+		assert.NotContains(t, stdout, "WebUI:")
+
+		c := node.IPFSAddStr("pinned by the worker", "--pin=false")
+		node.IPFS("pin", "add", c)
+		assert.Contains(t, node.IPFS("pin", "ls", "--type=recursive").Stdout.String(), c)
+
+		// the RPC API serves no WebUI either, instead of redirecting to it
+		client := node.APIClient()
+		client.Client = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		assert.Equal(t, http.StatusNotFound, client.Get("/webui").StatusCode)
+	})
+
+	t.Run("rejects unknown purposes", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init()
+		res := node.RunIPFS("daemon", "--single-command-server=dht-only")
+		require.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "--single-command-server must be one of")
+	})
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsAPI(t *testing.T) {
+	t.Parallel()
+
+	node := harness.NewT(t).NewNode().Init().StartDaemon()
+	defer node.StopDaemon()
+
+	node.IPFS("pin", "ls")
+	node.IPFS("pin", "ls")
+	assert.Error(t, node.RunIPFS("block", "stat", "bafkqaaa-not-a-cid").Err)
+
+	var out struct {
+		Commands []struct {
+			Command string
+			Calls   uint64
+			Errors  uint64
+			P99     int64
+		}
+	}
+	require.NoError(t, json.Unmarshal(node.IPFS("stats", "api", "--enc=json").Stdout.Bytes(), &out))
+	stats := map[string][2]uint64{}
+	for _, c := range out.Commands {
+		stats[c.Command] = [2]uint64{c.Calls, c.Errors}
+		assert.Positive(t, c.P99)
+	}
+	assert.Equal(t, [2]uint64{2, 0}, stats["pin/ls"])
+	assert.Equal(t, [2]uint64{1, 1}, stats["block/stat"])
+
+	assert.Contains(t, node.IPFS("stats", "api").Stdout.String(), "COMMAND")
+
+	metrics := node.APIClient().Get("/debug/metrics/prometheus").Body
+	assert.Contains(t, metrics, `ipfs_rpc_command_duration_seconds_count{command="pin/ls"} 2`)
+	assert.Contains(t, metrics, `ipfs_rpc_command_errors_total{command="block/stat"} 1`)
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsProvide(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes.StartDaemons().Connect()
+	defer nodes.StopDaemons()
+
+	type stats struct {
+		TotalProvides uint64
+		QueueDepth    *uint64
+		Announced     uint64
+		Failures      map[string]uint64
+	}
+	stat := func() stats {
+		var s stats
+		require.NoError(t, json.Unmarshal(nodes[0].IPFS("stats", "provide", "--enc=json").Stdout.Bytes(), &s))
+		return s
+	}
+
+	s := stat()
+	require.NotNil(t, s.QueueDepth)
+	assert.Equal(t, map[string]uint64{"Timeout": 0, "NoPeers": 0, "Quota": 0, "Other": 0}, s.Failures)
+
+	nodes[0].IPFSAddStr("stats provide")
+	require.Eventually(t, func() bool {
+		return stat().Announced > 0
+	}, time.Minute, 100*time.Millisecond)
+
+	out := nodes[0].IPFS("stats", "provide").Stdout.String()
+	assert.Contains(t, out, "QueueDepth:")
+	assert.Contains(t, out, "NoPeers:")
+}
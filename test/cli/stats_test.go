@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -21,4 +23,58 @@ func TestStats(t *testing.T) {
 		assert.Equal(t, 0, len(res.Stderr.Lines()))
 		assert.NotEqual(t, 0, len(res.Stdout.Lines()))
 	})
+	t.Run("stats peers", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons().Connect()
+		node1 := nodes[0]
+
+		node1.Disconnect(nodes[1])
+
+		type peerStats struct {
+			Opened int
+			Closed int
+			Causes map[string]int
+		}
+		assert.Eventually(t, func() bool {
+			var stats peerStats
+			res := node1.IPFS("stats", "peers", "--enc=json")
+			assert.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &stats))
+			return stats.Opened >= 1 && stats.Closed >= 1 && stats.Causes["other"] >= 1
+		}, 10*time.Second, 100*time.Millisecond)
+
+		res := node1.RunIPFS("stats", "peers", "--window", "-1h")
+		assert.Error(t, res.Err)
+	})
+	t.Run("stats providers", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init()
+		nodes[1].SetIPFSConfig("Routing.MaxProviderRecords", 1)
+		nodes.StartDaemons().Connect()
+
+		for _, data := range []string{"first", "second"} {
+			cid := nodes[0].IPFSAddStr(data)
+			nodes[0].IPFS("routing", "provide", cid)
+		}
+
+		type providersStats struct {
+			Received   uint64
+			Accepted   uint64
+			Rejected   uint64
+			Records    int64
+			MaxRecords int64
+		}
+		var stats providersStats
+		assert.Eventually(t, func() bool {
+			res := nodes[1].IPFS("stats", "providers", "--enc=json")
+			assert.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &stats))
+			return stats.Accepted >= 1 && stats.Rejected >= 1
+		}, 10*time.Second, 100*time.Millisecond)
+		assert.Equal(t, int64(1), stats.MaxRecords)
+		assert.Equal(t, int64(1), stats.Records)
+		assert.Contains(t, nodes[1].IPFS("stats", "providers").Stdout.String(), "Rejected:")
+
+		nodes.StopDaemons()
+		res := nodes[1].RunIPFS("stats", "providers")
+		assert.Error(t, res.Err)
+	})
 }
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwarmAllowlist(t *testing.T) {
+	t.Parallel()
+
+	nodes := harness.NewT(t).NewNodes(3).Init()
+	gated, allowed, other := nodes[0], nodes[1], nodes[2]
+	allowlist := filepath.Join(gated.Dir, "allowlist")
+	gated.WriteBytes("allowlist", []byte("# consortium members\n"+allowed.PeerID().String()+"\n"))
+	gated.UpdateConfig(func(cfg *config.Config) {
+		cfg.Swarm.Allowlist = config.SwarmAllowlist{
+			Enabled:         config.True,
+			File:            config.NewOptionalString(allowlist),
+			RefreshInterval: config.NewOptionalDuration(100 * time.Millisecond),
+		}
+	})
+	nodes.StartDaemons()
+	defer nodes.StopDaemons()
+
+	connect := func(from, to *harness.Node) error {
+		return from.RunIPFS("swarm", "connect", to.SwarmAddrsWithPeerIDs()[0].String()).Err
+	}
+	metrics := func() string {
+		return gated.APIClient().Get("/debug/metrics/prometheus").Body
+	}
+	peers := func() string {
+		return gated.IPFS("swarm", "peers").Stdout.String()
+	}
+
+	assert.NoError(t, connect(allowed, gated))
+	// the dialer may only see the inbound connection closed once established
+	_ = connect(other, gated)
+	assert.Error(t, connect(gated, other))
+	assert.Contains(t, peers(), allowed.PeerID().String())
+	assert.NotContains(t, peers(), other.PeerID().String())
+	assert.Contains(t, metrics(), `ipfs_swarm_allowlist_rejected_total{direction="inbound"}`)
+	assert.Contains(t, metrics(), `ipfs_swarm_allowlist_rejected_total{direction="outbound"} 1`)
+	assert.Contains(t, metrics(), "\nipfs_swarm_allowlist_peers 1\n")
+
+	t.Run("the allowlist is reloaded", func(t *testing.T) {
+		gated.WriteBytes("allowlist", []byte(other.PeerID().String()+"\n"))
+		assert.Eventually(t, func() bool {
+			return !strings.Contains(peers(), allowed.PeerID().String())
+		}, 10*time.Second, 100*time.Millisecond)
+		assert.NoError(t, connect(gated, other))
+	})
+}
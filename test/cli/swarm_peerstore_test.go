@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwarmPeerstore(t *testing.T) {
+	t.Parallel()
+
+	type peerstoreStats struct {
+		Peers          int
+		PeersWithAddrs int
+		Persist        bool
+		SavedPeers     int
+		Restored       int
+		Reconnected    int
+		PrunedPeers    int
+	}
+	stats := func(n *harness.Node) peerstoreStats {
+		var s peerstoreStats
+		require.NoError(t, json.Unmarshal(n.IPFS("swarm", "peerstore", "stats", "--enc=json").Stdout.Bytes(), &s))
+		return s
+	}
+
+	nodes := harness.NewT(t).NewNodes(2).Init()
+	nodes.ForEachPar(func(n *harness.Node) {
+		n.UpdateConfig(func(cfg *config.Config) {
+			cfg.Bootstrap = []string{}
+			cfg.Addresses.Swarm = []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", harness.NewRandPort())}
+			cfg.Discovery.MDNS.Enabled = false
+		})
+	})
+	node, peer := nodes[0], nodes[1]
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Swarm.Peerstore.Persist = config.True
+		cfg.Swarm.Peerstore.SaveInterval = config.NewOptionalDuration(100 * time.Millisecond)
+		// the address dialed twice then failing has a confidence of 60
+		cfg.Swarm.Peerstore.MinConfidence = config.NewOptionalInteger(65)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := peer.StartDaemon().IPFS("swarm", "peerstore", "stats").Stdout.String()
+		assert.Contains(t, s, "Persist:        disabled")
+	})
+
+	node.StartDaemon()
+	node.Connect(peer)
+	assert.Equal(t, 1, stats(node).Peers-1, "the peerstore holds the node itself and its peer")
+	node.StopDaemon()
+
+	t.Run("restored and reconnected after a restart", func(t *testing.T) {
+		node.StartDaemon()
+		assert.Equal(t, 1, stats(node).Restored)
+		assert.Eventually(t, func() bool {
+			return stats(node).Reconnected == 1
+		}, 10*time.Second, 100*time.Millisecond)
+		assert.Len(t, node.Peers(), 1)
+		node.StopDaemon()
+	})
+
+	t.Run("pruned when unreachable", func(t *testing.T) {
+		peer.StopDaemon()
+		node.StartDaemon()
+		defer node.StopDaemon()
+		assert.Equal(t, 1, stats(node).Restored)
+		assert.Eventually(t, func() bool {
+			s := stats(node)
+			return s.PrunedPeers == 1 && s.SavedPeers == 0
+		}, 10*time.Second, 100*time.Millisecond)
+	})
+}
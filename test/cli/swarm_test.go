@@ -87,4 +87,44 @@ func TestSwarm(t *testing.T) {
 		assert.ElementsMatch(t, outputIdentify.Addresses, otherNodeIDOutput.Addresses)
 		assert.ElementsMatch(t, outputIdentify.Protocols, otherNodeIDOutput.Protocols)
 	})
+
+	t.Run("ipfs swarm dial --explain reports per-address results", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init().StartDaemon()
+		otherNode := harness.NewT(t).NewNode().Init().StartDaemon()
+
+		type explainOutput struct {
+			ID        string
+			Connected bool
+			Addrs     []struct {
+				Address string
+				Result  string
+				Error   string
+			}
+		}
+		dial := func(addr string) (explainOutput, map[string]string, *harness.RunResult) {
+			res := node.RunIPFS("swarm", "dial", "--explain", "--enc=json", addr)
+			var output explainOutput
+			assert.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &output))
+			results := map[string]string{}
+			for _, a := range output.Addrs {
+				results[a.Address] = a.Result
+			}
+			return output, results, res
+		}
+
+		otherID := otherNode.PeerID().String()
+		output, results, res := dial("/ip4/127.0.0.1/tcp/1/p2p/" + otherID)
+		assert.Equal(t, otherID, output.ID)
+		assert.False(t, output.Connected)
+		assert.Equal(t, "failed", results["/ip4/127.0.0.1/tcp/1"])
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "dial "+otherID+" failure")
+
+		goodAddr := otherNode.SwarmAddrs()[0].String()
+		output, results, res = dial(goodAddr + "/p2p/" + otherID)
+		assert.Equal(t, 0, res.ExitCode())
+		assert.True(t, output.Connected)
+		assert.Equal(t, "connected", results[goodAddr])
+	})
 }
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymlinkPolicies(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (*harness.Harness, string) {
+		h := harness.NewT(t)
+		h.WriteFile("dir/file.txt", "file")
+		h.WriteFile("outside/other.txt", "other")
+		dir := filepath.Join(h.Dir, "dir")
+		require.NoError(t, os.Symlink("file.txt", filepath.Join(dir, "link")))
+		require.NoError(t, os.Symlink(filepath.Join(h.Dir, "outside"), filepath.Join(dir, "outside")))
+		return h, dir
+	}
+
+	t.Run("ipfs add preserves symlinks by default", func(t *testing.T) {
+		t.Parallel()
+		h, dir := setup(t)
+		node := h.NewNode().Init().StartDaemon()
+		defer node.StopDaemon()
+
+		cid := node.IPFS("add", "-r", "-Q", dir).Stdout.Trimmed()
+		out := filepath.Join(h.Dir, "out")
+		node.IPFS("get", "-o", out, cid)
+
+		target, err := os.Readlink(filepath.Join(out, "link"))
+		require.NoError(t, err)
+		assert.Equal(t, "file.txt", target)
+	})
+
+	t.Run("ipfs add --symlinks=forbid fails on symlinks", func(t *testing.T) {
+		t.Parallel()
+		h, dir := setup(t)
+		node := h.NewNode().Init().StartDaemon()
+		defer node.StopDaemon()
+
+		res := node.RunIPFS("add", "-r", "--symlinks=forbid", dir)
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "is a symlink")
+	})
+
+	t.Run("ipfs add --symlinks=follow adds symlink targets", func(t *testing.T) {
+		t.Parallel()
+		h, dir := setup(t)
+		node := h.NewNode().Init().StartDaemon()
+		defer node.StopDaemon()
+
+		cid := node.IPFS("add", "-r", "-Q", "--symlinks=follow", dir).Stdout.Trimmed()
+		out := filepath.Join(h.Dir, "out")
+		node.IPFS("get", "-o", out, cid)
+
+		data, err := os.ReadFile(filepath.Join(out, "link"))
+		require.NoError(t, err)
+		assert.Equal(t, "file", string(data))
+		data, err = os.ReadFile(filepath.Join(out, "outside", "other.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "other", string(data))
+	})
+
+	t.Run("ipfs add --symlinks=follow fails on symlink cycles", func(t *testing.T) {
+		t.Parallel()
+		h, dir := setup(t)
+		require.NoError(t, os.Symlink(".", filepath.Join(dir, "loop")))
+		node := h.NewNode().Init()
+
+		res := node.RunIPFS("add", "-r", "--symlinks=follow", dir)
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "parent directories")
+	})
+
+	t.Run("Import.Symlinks sets the default of ipfs add", func(t *testing.T) {
+		t.Parallel()
+		h, dir := setup(t)
+		node := h.NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Import.Symlinks = *config.NewOptionalString("forbid")
+		})
+
+		res := node.RunIPFS("add", "-r", dir)
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "is a symlink")
+
+		node.IPFS("add", "-r", "--symlinks=preserve", dir)
+	})
+
+	t.Run("ipfs get --symlinks=skip leaves symlinks out", func(t *testing.T) {
+		t.Parallel()
+		h, dir := setup(t)
+		node := h.NewNode().Init()
+		cid := node.IPFS("add", "-r", "-Q", dir).Stdout.Trimmed()
+
+		out := filepath.Join(h.Dir, "out")
+		node.IPFS("get", "--symlinks=skip", "-o", out, cid)
+		assert.FileExists(t, filepath.Join(out, "file.txt"))
+		assert.NoFileExists(t, filepath.Join(out, "link"))
+		assert.NoDirExists(t, filepath.Join(out, "outside"))
+	})
+
+	t.Run("Import.GetSymlinks sets the default of ipfs get", func(t *testing.T) {
+		t.Parallel()
+		h, dir := setup(t)
+		node := h.NewNode().Init()
+		node.UpdateConfig(func(cfg *config.Config) {
+			cfg.Import.GetSymlinks = *config.NewOptionalString("skip")
+		})
+		node.StartDaemon()
+		defer node.StopDaemon()
+		cid := node.IPFS("add", "-r", "-Q", dir).Stdout.Trimmed()
+
+		out := filepath.Join(h.Dir, "out")
+		node.IPFS("get", "-o", out, cid)
+		assert.FileExists(t, filepath.Join(out, "file.txt"))
+		assert.NoFileExists(t, filepath.Join(out, "link"))
+	})
+}
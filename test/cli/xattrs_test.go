@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestPreserveXattrs(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip("extended attributes are only supported on Linux")
+	}
+
+	h := harness.NewT(t)
+	node := h.NewNode().Init()
+	h.WriteFile("dir/sub/file.txt", "file")
+	dir := filepath.Join(h.Dir, "dir")
+	if err := unix.Setxattr(filepath.Join(dir, "sub", "file.txt"), "user.origin", []byte("backup"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %s", err)
+	}
+	require.NoError(t, unix.Setxattr(dir, "user.root", []byte("yes"), 0))
+
+	getXattr := func(t *testing.T, fpath, name string) string {
+		buf := make([]byte, 64)
+		n, err := unix.Getxattr(fpath, name, buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	t.Run("ipfs get --preserve-xattrs restores attributes recorded with ipfs add --preserve-xattrs", func(t *testing.T) {
+		cid := node.IPFS("add", "-r", "-Q", "--preserve-xattrs", dir).Stdout.Trimmed()
+		out := filepath.Join(h.Dir, "restored")
+		node.IPFS("get", "--preserve-xattrs", "-o", out, cid)
+
+		assert.Equal(t, "backup", getXattr(t, filepath.Join(out, "sub", "file.txt"), "user.origin"))
+		assert.Equal(t, "yes", getXattr(t, out, "user.root"))
+		assert.NoFileExists(t, filepath.Join(out, ".ipfs-xattrs.json"))
+	})
+
+	t.Run("ipfs get keeps the sidecar without --preserve-xattrs", func(t *testing.T) {
+		cid := node.IPFS("add", "-r", "-Q", "--preserve-xattrs", dir).Stdout.Trimmed()
+		out := filepath.Join(h.Dir, "plain")
+		node.IPFS("get", "-o", out, cid)
+
+		assert.FileExists(t, filepath.Join(out, ".ipfs-xattrs.json"))
+		_, err := unix.Getxattr(filepath.Join(out, "sub", "file.txt"), "user.origin", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("ipfs add --preserve-xattrs -w records files in the wrapping directory", func(t *testing.T) {
+		file := filepath.Join(dir, "sub", "file.txt")
+		cid := node.IPFS("add", "-Q", "-w", "--preserve-xattrs", file).Stdout.Trimmed()
+		out := filepath.Join(h.Dir, "wrapped")
+		node.IPFS("get", "--preserve-xattrs", "-o", out, cid)
+
+		assert.Equal(t, "backup", getXattr(t, filepath.Join(out, "file.txt"), "user.origin"))
+	})
+
+	t.Run("ipfs add --preserve-xattrs requires a directory", func(t *testing.T) {
+		res := node.RunIPFS("add", "--preserve-xattrs", filepath.Join(dir, "sub", "file.txt"))
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "--wrap-with-directory")
+	})
+
+	t.Run("recorded paths may not leave the extracted directory", func(t *testing.T) {
+		h.WriteFile("evil/.ipfs-xattrs.json", `{"Version":1,"Entries":{"../victim":{"user.x":"eA=="}}}`)
+		cid := node.IPFS("add", "-r", "-Q", "-H", filepath.Join(h.Dir, "evil")).Stdout.Trimmed()
+		res := node.RunIPFS("get", "--preserve-xattrs", "-o", filepath.Join(h.Dir, "evil-out"), cid)
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "outside of its directory")
+	})
+
+	t.Run("only user attributes and ACLs are restored by default", func(t *testing.T) {
+		h.WriteFile("hostile/tool", "#!/bin/sh")
+		// security.capability would grant capabilities to the tool if root
+		// restored it
+		h.WriteFile("hostile/.ipfs-xattrs.json", `{"Version":1,"Entries":{"tool":{"security.capability":"AQAAAgAgAAAAAAAAAAAAAAAAAAA=","trusted.x":"eA==","user.ok":"eWVz"}}}`)
+		cid := node.IPFS("add", "-r", "-Q", "-H", filepath.Join(h.Dir, "hostile")).Stdout.Trimmed()
+		out := filepath.Join(h.Dir, "hostile-out")
+		res := node.IPFS("get", "--preserve-xattrs", "-o", out, cid)
+
+		tool := filepath.Join(out, "tool")
+		assert.Equal(t, "yes", getXattr(t, tool, "user.ok"))
+		_, err := unix.Getxattr(tool, "security.capability", nil)
+		assert.Error(t, err)
+		_, err = unix.Getxattr(tool, "trusted.x", nil)
+		assert.Error(t, err)
+		assert.Contains(t, res.Stderr.String(), "skipped extended attribute security.capability")
+		assert.Contains(t, res.Stderr.String(), "--privileged-xattrs")
+
+		res = node.RunIPFS("get", "--privileged-xattrs", "-o", filepath.Join(h.Dir, "hostile-plain"), cid)
+		assert.Equal(t, 1, res.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "--privileged-xattrs requires --preserve-xattrs")
+	})
+}
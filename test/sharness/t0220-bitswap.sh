@@ -25,6 +25,9 @@ bitswap status
   data sent: 0
   dup blocks received: 0
   dup data received: 0
+  invalid blocks received: 0
+  peers banned for invalid blocks: 0 (0 bans)
+  wants retried after bans: 0
   wantlist [0 keys]
   partners [0]
 EOF
@@ -63,6 +66,9 @@ bitswap status
   data sent: 0
   dup blocks received: 0
   dup data received: 0
+  invalid blocks received: 0
+  peers banned for invalid blocks: 0 (0 bans)
+  wants retried after bans: 0
   wantlist [0 keys]
   partners [0]
 EOF
@@ -92,6 +98,9 @@ bitswap status
   data sent: 0 B
   dup blocks received: 0
   dup data received: 0 B
+  invalid blocks received: 0
+  peers banned for invalid blocks: 0 (0 bans)
+  wants retried after bans: 0
   wantlist [0 keys]
   partners [0]
 EOF